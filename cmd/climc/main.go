@@ -34,6 +34,7 @@ import (
 	_ "yunion.io/x/onecloud/cmd/climc/shell/monitor"
 	_ "yunion.io/x/onecloud/cmd/climc/shell/notifyv2"
 	_ "yunion.io/x/onecloud/cmd/climc/shell/servicetree"
+	_ "yunion.io/x/onecloud/cmd/climc/shell/stack"
 	_ "yunion.io/x/onecloud/cmd/climc/shell/suggestion"
 	_ "yunion.io/x/onecloud/cmd/climc/shell/yunionconf"
 )
@@ -227,6 +227,20 @@ func init() {
 		printObject(disk)
 		return nil
 	})
+	type DiskMigrateStorageOptions struct {
+		DISK    string `help:"ID or name of disk"`
+		STORAGE string `help:"ID or name of target storage, must be attached to the host of disk"`
+	}
+	R(&DiskMigrateStorageOptions{}, "disk-migrate-storage", "Migrate a kvm disk to another storage, online if guest is running", func(s *mcclient.ClientSession, args *DiskMigrateStorageOptions) error {
+		params := jsonutils.NewDict()
+		params.Add(jsonutils.NewString(args.STORAGE), "target_storage_id")
+		disk, err := modules.Disks.PerformAction(s, args.DISK, "migrate-storage", params)
+		if err != nil {
+			return err
+		}
+		printObject(disk)
+		return nil
+	})
 	type DiskResetOptions struct {
 		DISK      string `help:"ID or name of disk"`
 		SNAPSHOT  string `help:"snapshots ID of disk"`
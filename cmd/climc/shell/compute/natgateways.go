@@ -44,6 +44,28 @@ func init() {
 		return nil
 	})
 
+	R(&options.NatGatewayCreateOptions{}, "natgateway-create", "Create a NAT gateway", func(s *mcclient.ClientSession, opts *options.NatGatewayCreateOptions) error {
+		params, err := options.StructToParams(opts)
+		if err != nil {
+			return err
+		}
+		result, err := modules.NatGateways.Create(s, params)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	R(&options.NatGatewayIdOptions{}, "natgateway-delete", "Delete a NAT gateway", func(s *mcclient.ClientSession, args *options.NatGatewayIdOptions) error {
+		result, err := modules.NatGateways.Delete(s, args.ID, nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
 	R(&options.NatGatewayIdOptions{}, "natgateway-syncstatus", "Sync NAT gateway status", func(s *mcclient.ClientSession, args *options.NatGatewayIdOptions) error {
 		result, err := modules.NatGateways.PerformAction(s, args.ID, "syncstatus", nil)
 		if err != nil {
@@ -24,4 +24,6 @@ func init() {
 	cmd := shell.NewResourceCmd(&modules.RouteTableRouteAssociations).WithKeyword("routetable-association")
 	cmd.List(&options.RouteTableAssociationListOptions{})
 	cmd.Show(&options.RouteTableAssociationIdOptions{})
+	cmd.Create(&options.RouteTableAssociationCreateOptions{})
+	cmd.Delete(&options.RouteTableAssociationIdOptions{})
 }
@@ -89,7 +89,7 @@ func init() {
 		ZONE                  string `help:"Zone id of storage"`
 		Capacity              int64  `help:"Capacity of the Storage"`
 		MediumType            string `help:"Medium type" choices:"ssd|rotate"`
-		StorageType           string `help:"Storage type" choices:"local|nas|vsan|rbd|nfs|gpfs|baremetal"`
+		StorageType           string `help:"Storage type" choices:"local|nas|vsan|rbd|nfs|gpfs|cifs|baremetal"`
 		RbdMonHost            string `help:"Ceph mon_host config"`
 		RbdRadosMonOpTimeout  int64  `help:"ceph rados_mon_op_timeout"`
 		RbdRadosOsdOpTimeout  int64  `help:"ceph rados_osd_op_timeout"`
@@ -98,6 +98,12 @@ func init() {
 		RbdPool               string `help:"Ceph Pool Name"`
 		NfsHost               string `help:"NFS host"`
 		NfsSharedDir          string `help:"NFS shared dir"`
+		CifsHost              string `help:"CIFS/SMB file server"`
+		CifsSharedDir         string `help:"CIFS/SMB shared dir"`
+		CifsUsername          string `help:"CIFS/SMB username, mount as guest if not specified"`
+		CifsPassword          string `help:"CIFS/SMB password"`
+		CifsDomain            string `help:"CIFS/SMB domain or workgroup of the user"`
+		CifsVersion           string `help:"CIFS/SMB protocol version, e.g. 2.1, 3.0"`
 	}
 	R(&StorageCreateOptions{}, "storage-create", "Create a Storage", func(s *mcclient.ClientSession, args *StorageCreateOptions) error {
 		params, err := options.StructToParams(args)
@@ -113,6 +119,10 @@ func init() {
 			if len(args.NfsHost) == 0 || len(args.NfsSharedDir) == 0 {
 				return fmt.Errorf("Storage type nfs missing conf host or shared dir")
 			}
+		} else if args.StorageType == "cifs" {
+			if len(args.CifsHost) == 0 || len(args.CifsSharedDir) == 0 {
+				return fmt.Errorf("Storage type cifs missing conf host or shared dir")
+			}
 		}
 		storage, err := modules.Storages.Create(s, params)
 		if err != nil {
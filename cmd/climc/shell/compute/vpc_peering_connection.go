@@ -28,4 +28,5 @@ func init() {
 	cmd.Delete(&options.VpcPeeringConnectionIdOptions{})
 	cmd.Perform("sync", &options.VpcPeeringConnectionIdOptions{})
 	cmd.Perform("syncstatus", &options.VpcPeeringConnectionIdOptions{})
+	cmd.Perform("accept", &options.VpcPeeringConnectionIdOptions{})
 }
@@ -0,0 +1,109 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/util/timeutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+)
+
+func init() {
+	type ApplicationCredentialListOptions struct {
+		Scope   string `help:"scope" choices:"project|domain|system"`
+		User    string `help:"filter by user"`
+		Project string `help:"filter by project"`
+	}
+	R(&ApplicationCredentialListOptions{}, "application-credential-list", "List application credentials", func(s *mcclient.ClientSession, args *ApplicationCredentialListOptions) error {
+		query := jsonutils.NewDict()
+		if len(args.Scope) > 0 {
+			query.Add(jsonutils.NewString(args.Scope), "scope")
+		}
+		if len(args.User) > 0 {
+			query.Add(jsonutils.NewString(args.User), "user_id")
+		}
+		if len(args.Project) > 0 {
+			query.Add(jsonutils.NewString(args.Project), "project_id")
+		}
+		results, err := modules.ApplicationCredentials.List(s, query)
+		if err != nil {
+			return err
+		}
+		printList(results, modules.ApplicationCredentials.GetColumns(s))
+		return nil
+	})
+
+	type ApplicationCredentialCreateOptions struct {
+		NAME       string   `help:"Name of the application credential"`
+		Desc       string   `help:"Description"`
+		Project    string   `help:"Project the application credential is bound to, default to the current project"`
+		Role       []string `help:"Roles of the application credential, default to all roles of the user in the project"`
+		Secret     string   `help:"Secret of the application credential, at least 32 characters, generated if not specified"`
+		ExpiresAt  string   `help:"Expire time, e.g. 2021-01-01T00:00:00Z"`
+		AccessRule []string `help:"Access rules in the form of <service>:<method>:<path>, e.g. compute:GET:/servers/**"`
+	}
+	R(&ApplicationCredentialCreateOptions{}, "application-credential-create", "Create an application credential, the secret is only shown once", func(s *mcclient.ClientSession, args *ApplicationCredentialCreateOptions) error {
+		input := api.ApplicationCredentialCreateInput{}
+		input.Name = args.NAME
+		input.Description = args.Desc
+		input.ProjectId = args.Project
+		input.Roles = args.Role
+		input.Secret = args.Secret
+		if len(args.ExpiresAt) > 0 {
+			tm, err := timeutils.ParseTimeStr(args.ExpiresAt)
+			if err != nil {
+				return err
+			}
+			input.ExpiresAt = tm
+		}
+		for _, ruleStr := range args.AccessRule {
+			rule, err := api.ParseAccessRule(ruleStr)
+			if err != nil {
+				return err
+			}
+			input.AccessRules = append(input.AccessRules, rule)
+		}
+		result, err := modules.ApplicationCredentials.Create(s, jsonutils.Marshal(input))
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	type ApplicationCredentialOptions struct {
+		ID string `help:"ID or name of the application credential"`
+	}
+	R(&ApplicationCredentialOptions{}, "application-credential-show", "Show details of an application credential", func(s *mcclient.ClientSession, args *ApplicationCredentialOptions) error {
+		result, err := modules.ApplicationCredentials.Get(s, args.ID, nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	R(&ApplicationCredentialOptions{}, "application-credential-delete", "Delete an application credential and revoke its tokens", func(s *mcclient.ClientSession, args *ApplicationCredentialOptions) error {
+		result, err := modules.ApplicationCredentials.Delete(s, args.ID, nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+}
@@ -28,7 +28,7 @@ import (
 func init() {
 	type CredentialListOptions struct {
 		Scope      string `help:"scope" choices:"project|domain|system"`
-		Type       string `help:"credential type" choices:"totp|recovery|aksk"`
+		Type       string `help:"credential type" choices:"totp|recovery|aksk|webauthn|webauthn_recovery"`
 		User       string `help:"filter by user"`
 		UserDomain string `help:"the domain of user"`
 	}
@@ -155,6 +155,57 @@ func init() {
 		return nil
 	})
 
+	R(&CredentialTOTPOptions{}, "credential-list-webauthn", "List WebAuthn security keys of user", func(s *mcclient.ClientSession, args *CredentialTOTPOptions) error {
+		uid, err := modules.UsersV3.FetchId(s, args.USER, args.UserDomain)
+		if err != nil {
+			return err
+		}
+		creds, err := modules.Credentials.GetWebAuthnCredentials(s, uid)
+		if err != nil {
+			return err
+		}
+		for i := range creds {
+			lastUsed := "-"
+			if creds[i].LastUsedAt > 0 {
+				lastUsed = time.Unix(creds[i].LastUsedAt, 0).Format(time.RFC3339)
+			}
+			fmt.Printf("%s\t%s\tcreated_at: %s\tlast_used_at: %s\n", creds[i].Id, creds[i].Name, creds[i].CreatedAt.Format(time.RFC3339), lastUsed)
+		}
+		return nil
+	})
+
+	R(&CredentialTOTPOptions{}, "credential-remove-webauthn", "Remove all WebAuthn security keys and recovery codes of user", func(s *mcclient.ClientSession, args *CredentialTOTPOptions) error {
+		uid, err := modules.UsersV3.FetchId(s, args.USER, args.UserDomain)
+		if err != nil {
+			return err
+		}
+		err = modules.Credentials.RemoveWebAuthnCredentials(s, uid)
+		if err != nil {
+			return err
+		}
+		err = modules.Credentials.RemoveWebAuthnRecoveryCodes(s, uid)
+		if err != nil {
+			return err
+		}
+		fmt.Println("success")
+		return nil
+	})
+
+	R(&CredentialTOTPOptions{}, "credential-reset-webauthn-recovery-codes", "Generate new WebAuthn recovery codes for user", func(s *mcclient.ClientSession, args *CredentialTOTPOptions) error {
+		uid, err := modules.UsersV3.FetchId(s, args.USER, args.UserDomain)
+		if err != nil {
+			return err
+		}
+		codes, err := modules.Credentials.ResetWebAuthnRecoveryCodes(s, uid)
+		if err != nil {
+			return err
+		}
+		for _, code := range codes {
+			fmt.Println(code)
+		}
+		return nil
+	})
+
 	type CredentialAkSkOptions struct {
 		User          string `help:"User"`
 		UserDomain    string `help:"domain of user"`
@@ -87,6 +87,8 @@ func init() {
 		Disabled bool   `help:"Set the domain disabled"`
 
 		Displayname string `help:"display name"`
+
+		MfaPolicy string `help:"MFA policy of users in the domain" choices:"optional|required|webauthn|admin_webauthn"`
 	}
 	R(&DomainCreateOptions{}, "domain-create", "Create a new domain", func(s *mcclient.ClientSession, args *DomainCreateOptions) error {
 		params := jsonutils.NewDict()
@@ -102,6 +104,9 @@ func init() {
 		if len(args.Displayname) > 0 {
 			params.Add(jsonutils.NewString(args.Displayname), "displayname")
 		}
+		if len(args.MfaPolicy) > 0 {
+			params.Add(jsonutils.NewString(args.MfaPolicy), "mfa_policy")
+		}
 		result, err := modules.Domains.Create(s, params)
 		if err != nil {
 			return err
@@ -119,6 +124,8 @@ func init() {
 		Driver   string `help:"Set the domain Driver"`
 
 		Displayname string `help:"display name"`
+
+		MfaPolicy string `help:"MFA policy of users in the domain" choices:"optional|required|webauthn|admin_webauthn"`
 	}
 	R(&DomainUpdateOptions{}, "domain-update", "Update a domain", func(s *mcclient.ClientSession, args *DomainUpdateOptions) error {
 		obj, err := modules.Domains.Get(s, args.ID, nil)
@@ -148,6 +155,9 @@ func init() {
 		if len(args.Displayname) > 0 {
 			params.Add(jsonutils.NewString(args.Displayname), "displayname")
 		}
+		if len(args.MfaPolicy) > 0 {
+			params.Add(jsonutils.NewString(args.MfaPolicy), "mfa_policy")
+		}
 		result, err := modules.Domains.Patch(s, objId, params)
 		if err != nil {
 			return err
@@ -107,6 +107,24 @@ func init() {
 		return nil
 	})
 
+	R(&IdentityProviderDetailOptions{}, "idp-scim-token", "Issue a new SCIM bearer token for an identity provider, invalidating the previous one", func(s *mcclient.ClientSession, args *IdentityProviderDetailOptions) error {
+		result, err := modules.IdentityProviders.PerformAction(s, args.ID, "scim-token", nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	R(&IdentityProviderDetailOptions{}, "idp-disable-scim", "Disable SCIM provisioning of an identity provider", func(s *mcclient.ClientSession, args *IdentityProviderDetailOptions) error {
+		idp, err := modules.IdentityProviders.PerformAction(s, args.ID, "disable-scim", nil)
+		if err != nil {
+			return err
+		}
+		printObject(idp)
+		return nil
+	})
+
 	R(&IdentityProviderDetailOptions{}, "idp-sync", "Sync an identity provider", func(s *mcclient.ClientSession, args *IdentityProviderDetailOptions) error {
 		idp, err := modules.IdentityProviders.PerformAction(s, args.ID, "sync", nil)
 		if err != nil {
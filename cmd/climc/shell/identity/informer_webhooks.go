@@ -0,0 +1,150 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"yunion.io/x/jsonutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+)
+
+func init() {
+	type InformerWebhookListOptions struct {
+		Resource string `help:"filter by subscribed resource, e.g. servers"`
+		Enabled  bool   `help:"show enabled webhooks only"`
+		Disabled bool   `help:"show disabled webhooks only"`
+	}
+	R(&InformerWebhookListOptions{}, "informer-webhook-list", "List resource change event webhooks", func(s *mcclient.ClientSession, args *InformerWebhookListOptions) error {
+		query := jsonutils.NewDict()
+		if len(args.Resource) > 0 {
+			query.Add(jsonutils.NewString(args.Resource), "resource")
+		}
+		if args.Enabled {
+			query.Add(jsonutils.JSONTrue, "enabled")
+		} else if args.Disabled {
+			query.Add(jsonutils.JSONFalse, "enabled")
+		}
+		results, err := modules.InformerWebhooks.List(s, query)
+		if err != nil {
+			return err
+		}
+		printList(results, modules.InformerWebhooks.GetColumns(s))
+		return nil
+	})
+
+	type InformerWebhookCreateOptions struct {
+		NAME     string   `help:"Name of the webhook"`
+		URL      string   `help:"HTTP(S) endpoint receiving the CloudEvents"`
+		Desc     string   `help:"Description"`
+		Secret   string   `help:"HMAC-SHA256 signing secret, generated if not specified"`
+		Resource []string `help:"Subscribed resources (keyword plural), e.g. servers, subscribe all resources if not specified"`
+		Disabled bool     `help:"Create the webhook disabled"`
+	}
+	R(&InformerWebhookCreateOptions{}, "informer-webhook-create", "Create a resource change event webhook", func(s *mcclient.ClientSession, args *InformerWebhookCreateOptions) error {
+		input := api.InformerWebhookCreateInput{}
+		input.Name = args.NAME
+		input.Description = args.Desc
+		input.Url = args.URL
+		input.Secret = args.Secret
+		input.Resources = args.Resource
+		if args.Disabled {
+			enabled := false
+			input.Enabled = &enabled
+		}
+		result, err := modules.InformerWebhooks.Create(s, jsonutils.Marshal(input))
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	type InformerWebhookOptions struct {
+		ID string `help:"ID or name of the webhook"`
+	}
+	R(&InformerWebhookOptions{}, "informer-webhook-show", "Show details of a resource change event webhook", func(s *mcclient.ClientSession, args *InformerWebhookOptions) error {
+		result, err := modules.InformerWebhooks.Get(s, args.ID, nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	type InformerWebhookUpdateOptions struct {
+		ID           string   `help:"ID or name of the webhook"`
+		Name         string   `help:"New name of the webhook"`
+		Desc         string   `help:"Description"`
+		Url          string   `help:"HTTP(S) endpoint receiving the CloudEvents"`
+		Secret       string   `help:"HMAC-SHA256 signing secret"`
+		Resource     []string `help:"Subscribed resources (keyword plural)"`
+		AllResources bool     `help:"Subscribe all resources"`
+	}
+	R(&InformerWebhookUpdateOptions{}, "informer-webhook-update", "Update a resource change event webhook", func(s *mcclient.ClientSession, args *InformerWebhookUpdateOptions) error {
+		params := jsonutils.NewDict()
+		if len(args.Name) > 0 {
+			params.Add(jsonutils.NewString(args.Name), "name")
+		}
+		if len(args.Desc) > 0 {
+			params.Add(jsonutils.NewString(args.Desc), "description")
+		}
+		if len(args.Url) > 0 {
+			params.Add(jsonutils.NewString(args.Url), "url")
+		}
+		if len(args.Secret) > 0 {
+			params.Add(jsonutils.NewString(args.Secret), "secret")
+		}
+		if args.AllResources {
+			params.Add(jsonutils.NewArray(), "resources")
+		} else if len(args.Resource) > 0 {
+			params.Add(jsonutils.NewStringArray(args.Resource), "resources")
+		}
+		result, err := modules.InformerWebhooks.Update(s, args.ID, params)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	R(&InformerWebhookOptions{}, "informer-webhook-enable", "Enable a resource change event webhook", func(s *mcclient.ClientSession, args *InformerWebhookOptions) error {
+		result, err := modules.InformerWebhooks.PerformAction(s, args.ID, "enable", nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	R(&InformerWebhookOptions{}, "informer-webhook-disable", "Disable a resource change event webhook", func(s *mcclient.ClientSession, args *InformerWebhookOptions) error {
+		result, err := modules.InformerWebhooks.PerformAction(s, args.ID, "disable", nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	R(&InformerWebhookOptions{}, "informer-webhook-delete", "Delete a resource change event webhook", func(s *mcclient.ClientSession, args *InformerWebhookOptions) error {
+		result, err := modules.InformerWebhooks.Delete(s, args.ID, nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+}
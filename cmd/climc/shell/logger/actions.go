@@ -134,4 +134,9 @@ func init() {
 		nargs := ActionListOptions{BaseActionListOptions: args.BaseActionListOptions, Id: args.ID, Type: []string{"vcenter"}}
 		return doActionList(s, &nargs)
 	})
+
+	R(&TypeActionListOptions{}, "informer-webhook-action", "Show operation and delivery logs of informer webhook", func(s *mcclient.ClientSession, args *TypeActionListOptions) error {
+		nargs := ActionListOptions{BaseActionListOptions: args.BaseActionListOptions, Id: args.ID, Type: []string{"informer_webhook"}}
+		return doActionList(s, &nargs)
+	})
 }
@@ -70,4 +70,21 @@ func init() {
 		printObject(result)
 		return nil
 	})
+
+	type TaskCancelOptions struct {
+		ID     string `help:"ID of the task"`
+		Reason string `help:"reason of the cancellation"`
+	}
+	R(&TaskCancelOptions{}, "region-task-cancel", "Cancel a region task and its subtasks", func(s *mcclient.ClientSession, args *TaskCancelOptions) error {
+		params := jsonutils.NewDict()
+		if len(args.Reason) > 0 {
+			params.Add(jsonutils.NewString(args.Reason), "reason")
+		}
+		result, err := modules.ComputeTasks.PerformAction(s, args.ID, "cancel", params)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
 }
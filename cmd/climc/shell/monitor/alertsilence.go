@@ -0,0 +1,18 @@
+package monitor
+
+import (
+	"yunion.io/x/onecloud/cmd/climc/shell"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+	options "yunion.io/x/onecloud/pkg/mcclient/options/monitor"
+)
+
+func init() {
+	cmd := shell.NewResourceCmd(modules.AlertSilenceManager)
+	cmd.Create(new(options.AlertSilenceCreateOptions))
+	cmd.List(new(options.AlertSilenceListOptions))
+	cmd.Show(new(options.AlertSilenceShowOptions))
+	cmd.Update(new(options.AlertSilenceUpdateOptions))
+	cmd.Delete(new(options.AlertSilenceDeleteOptions))
+	cmd.Perform("enable", &options.AlertSilenceShowOptions{})
+	cmd.Perform("disable", &options.AlertSilenceShowOptions{})
+}
@@ -84,6 +84,7 @@ func init() {
 
 		ContactType string `help:"contact_type"`
 		ReceiverId  string `help:"receiver_id"`
+		DeadLetter  *bool  `help:"list notifications which have dead-lettered receivers" negative:"no-dead-letter"`
 	}
 	R(&NotificationListInput{}, "notify-list", "List notify message", func(s *mcclient.ClientSession, args *NotificationListInput) error {
 		params, err := options.ListStructToParams(args)
@@ -97,4 +98,35 @@ func init() {
 		printList(ret, modules.Notification.GetColumns(s))
 		return nil
 	})
+	type NotificationRedriveInput struct {
+		ID        string   `help:"Id of notification"`
+		Receivers []string `help:"Id of dead-lettered receivers or contacts to redrive, all if not specified"`
+	}
+	R(&NotificationRedriveInput{}, "notify-redrive", "Send a dead-lettered notify message again", func(s *mcclient.ClientSession, args *NotificationRedriveInput) error {
+		input := api.NotificationRedriveInput{
+			ReceiverIds: args.Receivers,
+		}
+		ret, err := modules.Notification.PerformAction(s, args.ID, "redrive", jsonutils.Marshal(input))
+		if err != nil {
+			return err
+		}
+		printObject(ret)
+		return nil
+	})
+	type NotificationRedriveAllInput struct {
+		ContactType string `help:"Redrive dead-lettered notify messages of the contact type only"`
+		Limit       int    `help:"Max count of notify messages to redrive" default:"100"`
+	}
+	R(&NotificationRedriveAllInput{}, "notify-redrive-all", "Send dead-lettered notify messages again", func(s *mcclient.ClientSession, args *NotificationRedriveAllInput) error {
+		input := api.NotificationManagerRedriveInput{
+			ContactType: args.ContactType,
+			Limit:       args.Limit,
+		}
+		ret, err := modules.Notification.PerformClassAction(s, "redrive", jsonutils.Marshal(input))
+		if err != nil {
+			return err
+		}
+		printObject(ret)
+		return nil
+	})
 }
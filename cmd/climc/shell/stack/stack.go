@@ -0,0 +1,160 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/stack"
+	"yunion.io/x/onecloud/pkg/util/fileutils2"
+)
+
+func loadStack(file string) (*stack.SStack, error) {
+	content, err := fileutils2.FileGetContents(file)
+	if err != nil {
+		return nil, err
+	}
+	return stack.ParseStack(content)
+}
+
+func printChange(change *stack.SChange, deletion bool) {
+	switch {
+	case change.Action == stack.ActionNoop:
+		return
+	case change.Action == stack.ActionCreate:
+		fmt.Printf("  + %s\n", change.Key)
+	case change.Action == stack.ActionUpdate && len(change.Reason) > 0:
+		fmt.Printf("  ~ %s (%s): %s\n", change.Key, change.Live.Id, change.Reason)
+	case change.Action == stack.ActionUpdate:
+		fmt.Printf("  ~ %s (%s)\n", change.Key, change.Live.Id)
+	case change.Action == stack.ActionDelete:
+		fmt.Printf("  - %s (%s)\n", change.Key, change.Live.Id)
+	case change.Action == stack.ActionReplace && deletion:
+		fmt.Printf("-/+ %s (%s): %s\n", change.Key, change.Live.Id, change.Reason)
+	}
+}
+
+func printPlan(plan *stack.SPlan) {
+	if !plan.HasChanges() {
+		fmt.Printf("No changes, stack %s is up-to-date.\n", plan.Stack.Name)
+		return
+	}
+	for _, change := range plan.Deletions {
+		printChange(change, true)
+	}
+	for _, change := range plan.Changes {
+		printChange(change, false)
+	}
+	summary := plan.Summary()
+	fmt.Printf("Plan: %d to create, %d to update, %d to replace, %d to delete.\n",
+		summary[stack.ActionCreate], summary[stack.ActionUpdate], summary[stack.ActionReplace], summary[stack.ActionDelete])
+}
+
+// confirm lists the resources to be deleted and asks the user to continue
+func confirm(plan *stack.SPlan, action string) bool {
+	if len(plan.Deletions) > 0 {
+		fmt.Println("The following resources will be deleted:")
+		for _, change := range plan.Deletions {
+			fmt.Printf("  %s (%s)\n", change.Key, change.Live.Id)
+		}
+	}
+	fmt.Printf("Do you want to %s stack %s? [y/N]: ", action, plan.Stack.Name)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func init() {
+	type StackPlanOptions struct {
+		FILE string `help:"Path of the stack file in YAML"`
+	}
+	R(&StackPlanOptions{}, "plan", "Show the changes to make the resources of a stack match the stack file", func(s *mcclient.ClientSession, args *StackPlanOptions) error {
+		stk, err := loadStack(args.FILE)
+		if err != nil {
+			return err
+		}
+		plan, err := stack.Plan(s, stk)
+		if err != nil {
+			return err
+		}
+		printPlan(plan)
+		return nil
+	})
+
+	type StackApplyOptions struct {
+		FILE        string `help:"Path of the stack file in YAML"`
+		Timeout     int    `help:"Seconds to wait for each resource to be ready" default:"1800"`
+		AutoApprove bool   `help:"Skip the confirmation of the plan"`
+	}
+	R(&StackApplyOptions{}, "apply", "Create, update and delete the resources of a stack to match the stack file", func(s *mcclient.ClientSession, args *StackApplyOptions) error {
+		stk, err := loadStack(args.FILE)
+		if err != nil {
+			return err
+		}
+		plan, err := stack.Plan(s, stk)
+		if err != nil {
+			return err
+		}
+		printPlan(plan)
+		if !plan.HasChanges() {
+			return nil
+		}
+		if !args.AutoApprove && !confirm(plan, "apply") {
+			fmt.Println("Apply cancelled.")
+			return nil
+		}
+		err = stack.Apply(s, plan, stack.SApplyOptions{Timeout: time.Duration(args.Timeout) * time.Second})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Stack %s applied.\n", stk.Name)
+		return nil
+	})
+
+	type StackDestroyOptions struct {
+		FILE        string `help:"Path of the stack file in YAML"`
+		Timeout     int    `help:"Seconds to wait for each resource to be deleted" default:"1800"`
+		AutoApprove bool   `help:"Skip the confirmation of the plan"`
+	}
+	R(&StackDestroyOptions{}, "destroy", "Delete all of the resources of a stack", func(s *mcclient.ClientSession, args *StackDestroyOptions) error {
+		stk, err := loadStack(args.FILE)
+		if err != nil {
+			return err
+		}
+		plan, err := stack.PlanDestroy(s, stk)
+		if err != nil {
+			return err
+		}
+		printPlan(plan)
+		if !plan.HasChanges() {
+			return nil
+		}
+		if !args.AutoApprove && !confirm(plan, "destroy") {
+			fmt.Println("Destroy cancelled.")
+			return nil
+		}
+		err = stack.Apply(s, plan, stack.SApplyOptions{Timeout: time.Duration(args.Timeout) * time.Second})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Stack %s destroyed.\n", stk.Name)
+		return nil
+	})
+}
@@ -60,27 +60,19 @@ type SAuthToken struct {
 	initTotp   bool
 	isSsoLogin bool
 
+	verifyWebAuthn  bool // 通过WebAuthn安全密钥验证
+	requireWebAuthn bool // 域MFA策略要求使用WebAuthn安全密钥
+	initWebAuthn    bool // 已注册WebAuthn安全密钥
+
 	retryCount     int    // 重试计数器
 	lockExpireTime uint32 // 锁定时间
 }
 
 func (t SAuthToken) encodeBytes() []byte {
 	msg := bytes.Buffer{}
-	if t.verifyTotp {
-		msg.WriteByte(TotpEnable)
-	} else {
-		msg.WriteByte(TotpDisable)
-	}
-	if t.enableTotp {
-		msg.WriteByte(TotpEnable)
-	} else {
-		msg.WriteByte(TotpDisable)
-	}
-	if t.initTotp {
-		msg.WriteByte(TotpEnable)
-	} else {
-		msg.WriteByte(TotpDisable)
-	}
+	msg.WriteByte(encodeFlags(t.verifyTotp, t.verifyWebAuthn))
+	msg.WriteByte(encodeFlags(t.enableTotp, t.requireWebAuthn))
+	msg.WriteByte(encodeFlags(t.initTotp, t.initWebAuthn))
 	if t.isSsoLogin {
 		msg.WriteByte(TotpEnable)
 	} else {
@@ -121,26 +113,32 @@ func Decode(t string) (*SAuthToken, error) {
 	return decodeBytes(tBytes)
 }
 
+// the first three bytes keep TOTP state in bit 0 and WebAuthn state in bit 1,
+// so that tokens issued before WebAuthn support decode as before
+func encodeFlags(totp bool, webauthn bool) byte {
+	flags := byte(TotpDisable)
+	if totp {
+		flags |= 0x1
+	}
+	if webauthn {
+		flags |= 0x2
+	}
+	return flags
+}
+
+func decodeFlags(b byte) (bool, bool) {
+	flags := b - TotpDisable
+	return flags&0x1 != 0, flags&0x2 != 0
+}
+
 func decodeBytes(tt []byte) (*SAuthToken, error) {
 	ret := SAuthToken{}
 	if len(tt) < 10 {
 		return nil, errors.Wrap(errors.ErrInvalidStatus, "too short")
 	}
-	if tt[0] == TotpEnable {
-		ret.verifyTotp = true
-	} else {
-		ret.verifyTotp = false
-	}
-	if tt[1] == TotpEnable {
-		ret.enableTotp = true
-	} else {
-		ret.enableTotp = false
-	}
-	if tt[2] == TotpEnable {
-		ret.initTotp = true
-	} else {
-		ret.initTotp = false
-	}
+	ret.verifyTotp, ret.verifyWebAuthn = decodeFlags(tt[0])
+	ret.enableTotp, ret.requireWebAuthn = decodeFlags(tt[1])
+	ret.initTotp, ret.initWebAuthn = decodeFlags(tt[2])
 	if tt[3] == TotpEnable {
 		ret.isSsoLogin = true
 	} else {
@@ -198,12 +196,16 @@ func (t SAuthToken) GetAuthCookie(token mcclient.TokenCredential) string {
 	info.Add(jsonutils.NewBool(t.initTotp), "totp_init")                      // 是否初始化TOTP密钥
 	info.Add(jsonutils.NewBool(t.enableTotp), "totp_on")                      // 用户totp 开启状态。 True（已开启）|False(未开启)
 	info.Add(jsonutils.NewBool(t.isSsoLogin), "is_sso")                       // 用户是否通过SSO登录
+	info.Add(jsonutils.NewBool(t.verifyWebAuthn), "webauthn_verified")        // 用户WebAuthn验证通过
+	info.Add(jsonutils.NewBool(t.requireWebAuthn), "webauthn_required")       // 是否必须使用WebAuthn安全密钥验证
+	info.Add(jsonutils.NewBool(t.initWebAuthn), "webauthn_init")              // 是否已注册WebAuthn安全密钥
 	info.Add(jsonutils.NewBool(options.Options.EnableTotp), "system_totp_on") // 全局totp 开启状态。 True（已开启）|False(未开启)
 	info.Add(jsonutils.NewString(token.GetUserId()), "user_id")
 	info.Add(jsonutils.NewString(token.GetUserName()), "user")
 	return info.String()
 }
 
+// 是否通过双因子认证。域MFA策略要求WebAuthn时，只接受WebAuthn安全密钥验证
 func (t SAuthToken) IsTotpVerified() bool {
 	if !options.Options.EnableTotp {
 		return true
@@ -211,9 +213,42 @@ func (t SAuthToken) IsTotpVerified() bool {
 	if !t.enableTotp {
 		return true
 	}
+	if t.requireWebAuthn {
+		return t.verifyWebAuthn
+	}
+	return t.verifyTotp || t.verifyWebAuthn
+}
+
+func (t SAuthToken) IsTotpPasscodeVerified() bool {
 	return t.verifyTotp
 }
 
+func (t SAuthToken) IsWebAuthnVerified() bool {
+	return t.verifyWebAuthn
+}
+
+func (t SAuthToken) IsWebAuthnRequired() bool {
+	return t.requireWebAuthn
+}
+
+func (t SAuthToken) IsWebAuthnInitialized() bool {
+	return t.initWebAuthn
+}
+
+// 域MFA策略强制开启双因子认证
+func (t *SAuthToken) SetTotpEnabled() {
+	t.enableTotp = true
+}
+
+func (t *SAuthToken) SetWebAuthnRequired() {
+	t.enableTotp = true
+	t.requireWebAuthn = true
+}
+
+func (t *SAuthToken) SetWebAuthnInitialized(init bool) {
+	t.initWebAuthn = init
+}
+
 func (t SAuthToken) IsTotpEnabled() bool {
 	return t.enableTotp
 }
@@ -278,6 +313,24 @@ func (t *SAuthToken) VerifyTotpPasscode(s *mcclient.ClientSession, uid, passcode
 	return errors.Wrap(httperrors.ErrInvalidCredential, "invalid passcode")
 }
 
+// 验证WebAuthn断言或恢复码，与TOTP共用重试计数器
+func (t *SAuthToken) VerifyWebAuthn(verify func() error) error {
+	if t.lockExpireTime > uint32(time.Now().Unix()) {
+		return errors.Wrapf(httperrors.ErrResourceBusy, "locked, retry after %d seconds", t.lockExpireTime-uint32(time.Now().Unix()))
+	}
+
+	err := verify()
+	if err == nil {
+		t.verifyWebAuthn = true
+		t.lockExpireTime = 0
+		t.retryCount = 0
+		return nil
+	}
+
+	t.updateRetryCount()
+	return err
+}
+
 func SignJWT(t jwt.Token) (string, error) {
 	jwkKey, err := jwk.New(privateKey)
 	if err != nil {
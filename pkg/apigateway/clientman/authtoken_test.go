@@ -54,3 +54,37 @@ func TestEncoeDecode(t *testing.T) {
 		t.Fatalf("token2 != token")
 	}
 }
+
+func TestEncodeDecodeWebAuthn(t *testing.T) {
+	token := SAuthToken{
+		token:           "token",
+		enableTotp:      true,
+		initTotp:        true,
+		verifyWebAuthn:  true,
+		requireWebAuthn: true,
+		initWebAuthn:    true,
+	}
+	token2, err := decodeBytes(token.encodeBytes())
+	if err != nil {
+		t.Fatalf("decodeBytes fail %s", err)
+	}
+	if *token2 != token {
+		t.Fatalf("token2 != token")
+	}
+
+	// tokens encoded before webauthn support
+	legacy := append([]byte{TotpEnable, TotpEnable, TotpDisable, TotpEnable, 0, 0, 0, 0, 0, 0}, []byte("token")...)
+	token3, err := decodeBytes(legacy)
+	if err != nil {
+		t.Fatalf("decodeBytes fail %s", err)
+	}
+	want := SAuthToken{
+		token:      "token",
+		verifyTotp: true,
+		enableTotp: true,
+		isSsoLogin: true,
+	}
+	if *token3 != want {
+		t.Fatalf("legacy token decode mismatch %#v", *token3)
+	}
+}
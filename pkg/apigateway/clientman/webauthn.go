@@ -0,0 +1,83 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientman
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+const WEBAUTHN_SESSION_TIMEOUT = 5 * time.Minute
+
+// WebAuthn注册或验证过程的挑战信息，加密后交给客户端保存，完成时原样提交
+type SWebAuthnSession struct {
+	Ceremony  string `json:"ceremony"`
+	Challenge string `json:"challenge"`
+	UserId    string `json:"user_id"`
+	TokenHash string `json:"token_hash"`
+	Expires   int64  `json:"expires"`
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// 挑战信息与当前登录会话绑定
+func (t SAuthToken) NewWebAuthnSession(ceremony string, challenge string, userId string) (string, error) {
+	if privateKey == nil {
+		return "", errors.Wrap(httperrors.ErrNotSupported, "webauthn requires ssl to be enabled")
+	}
+	sess := SWebAuthnSession{
+		Ceremony:  ceremony,
+		Challenge: challenge,
+		UserId:    userId,
+		TokenHash: tokenHash(t.token),
+		Expires:   time.Now().Add(WEBAUTHN_SESSION_TIMEOUT).Unix(),
+	}
+	return EncryptString([]byte(jsonutils.Marshal(&sess).String())), nil
+}
+
+func (t SAuthToken) DecodeWebAuthnSession(ceremony string, userId string, session string) (*SWebAuthnSession, error) {
+	if privateKey == nil {
+		return nil, errors.Wrap(httperrors.ErrNotSupported, "webauthn requires ssl to be enabled")
+	}
+	sessBytes, err := DecryptString(session)
+	if err != nil {
+		return nil, errors.Wrap(httperrors.ErrInvalidCredential, "invalid webauthn session")
+	}
+	sessJson, err := jsonutils.Parse(sessBytes)
+	if err != nil {
+		return nil, errors.Wrap(httperrors.ErrInvalidCredential, "invalid webauthn session")
+	}
+	sess := &SWebAuthnSession{}
+	err = sessJson.Unmarshal(sess)
+	if err != nil {
+		return nil, errors.Wrap(httperrors.ErrInvalidCredential, "invalid webauthn session")
+	}
+	if sess.Ceremony != ceremony || sess.UserId != userId || sess.TokenHash != tokenHash(t.token) {
+		return nil, errors.Wrap(httperrors.ErrInvalidCredential, "webauthn session mismatch")
+	}
+	if sess.Expires < time.Now().Unix() {
+		return nil, errors.Wrap(httperrors.ErrInvalidCredential, "webauthn session expired")
+	}
+	return sess, nil
+}
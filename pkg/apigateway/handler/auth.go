@@ -79,6 +79,11 @@ func (h *AuthHandlers) AddMethods() {
 		NewHP(h.resetTotpSecrets, "credential"),
 		NewHP(h.validatePasscode, "passcode"),
 		NewHP(h.resetTotpRecoveryQuestions, "recovery"),
+		NewHP(beginWebAuthnRegister, "webauthn", "register", "begin"),
+		NewHP(finishWebAuthnRegister, "webauthn", "register", "finish"),
+		NewHP(beginWebAuthnLogin, "webauthn", "login", "begin"),
+		NewHP(finishWebAuthnLogin, "webauthn", "login", "finish"),
+		NewHP(validateWebAuthnRecoveryCode, "webauthn", "recovery"),
 		NewHP(h.postLoginHandler, "login"),
 		NewHP(h.postLogoutHandler, "logout"),
 		NewHP(h.handleSsoLogin, "ssologin"),
@@ -93,18 +98,22 @@ func (h *AuthHandlers) AddMethods() {
 		NewHP(h.getResources, "scoped_resources"),
 		NewHP(fetchIdpBasicConfig, "idp", "<idp_id>", "info"),
 		NewHP(fetchIdpSAMLMetadata, "idp", "<idp_id>", "saml-metadata"),
+		NewHP(listWebAuthnCredentials, "webauthn", "credentials"),
 	)
 	h.AddByMethod(POST, FetchAuthToken,
 		NewHP(h.resetUserPassword, "password"),
 		NewHP(h.getPermissionDetails, "permissions"),
 		NewHP(h.doCreatePolicies, "policies"),
 		NewHP(handleUnlinkIdp, "unlink-idp"),
+		NewHP(resetWebAuthnRecoveryCodes, "webauthn", "recovery-codes"),
 	)
 	h.AddByMethod(PATCH, FetchAuthToken,
 		NewHP(h.doPatchPolicy, "policies", "<policy_id>"),
+		NewHP(updateWebAuthnCredential, "webauthn", "credentials", "<credential_id>"),
 	)
 	h.AddByMethod(DELETE, FetchAuthToken,
 		NewHP(h.doDeletePolicies, "policies"),
+		NewHP(deleteWebAuthnCredential, "webauthn", "credentials", "<credential_id>"),
 	)
 }
 
@@ -527,6 +536,16 @@ func (h *AuthHandlers) doLogin(ctx context.Context, w http.ResponseWriter, req *
 		}
 		isIdpLogin := body.Contains("idp_driver")
 		authToken = clientman.NewAuthToken(token.GetTokenString(), isUserEnableTotp(userInfo), isTotpInit, isIdpLogin)
+		err = initWebAuthnState(s, token.GetUserId(), authToken)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 根据用户所在域的MFA策略调整双因子认证要求，切换项目后也需要重新检查
+	err = applyDomainMfaPolicy(auth.GetAdminSession(ctx, FetchRegion(req), ""), token, authToken)
+	if err != nil {
+		return err
 	}
 
 	if !isUserAllowWebconsole(userInfo) {
@@ -561,6 +580,12 @@ func (h *AuthHandlers) doLogin(ctx context.Context, w http.ResponseWriter, req *
 }
 
 func (h *AuthHandlers) postLogoutHandler(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	if token, _, err := fetchAuthInfo(ctx, req); err == nil {
+		err = auth.Client().RevokeToken(token.GetTokenString(), token.GetTokenString())
+		if err != nil {
+			log.Errorf("revoke token of user %s fail: %s", token.GetUserName(), err)
+		}
+	}
 	clearAuthCookie(w)
 	appsrv.DisableClientCache(w)
 	appsrv.Send(w, "")
@@ -1104,7 +1129,7 @@ func (h *AuthHandlers) doDeletePolicies(ctx context.Context, w http.ResponseWrit
 重置密码
 1.验证新密码正确
 2.验证原密码正确，且idp_driver为空
-3.如果已开启MFA，验证 随机密码正确
+3.如果已开启MFA，验证 随机密码正确，或者本次会话已通过安全密钥验证
 4.重置密码，清除认证token
 */
 func (h *AuthHandlers) resetUserPassword(ctx context.Context, w http.ResponseWriter, req *http.Request) {
@@ -1157,8 +1182,8 @@ func (h *AuthHandlers) resetUserPassword(ctx context.Context, w http.ResponseWri
 	}
 
 	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
-	// 2.如果已开启MFA，验证 随机密码正确
-	if isMfaEnabled(user) {
+	// 2.如果已开启MFA，验证 随机密码正确。本次会话已通过安全密钥验证时无需随机密码
+	if isMfaEnabled(user) && !(len(passcode) == 0 && authToken.IsWebAuthnVerified()) {
 		err = authToken.VerifyTotpPasscode(s, t.GetUserId(), passcode)
 		if err != nil {
 			httperrors.InputParameterError(ctx, w, "invalid passcode")
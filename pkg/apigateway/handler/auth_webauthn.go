@@ -0,0 +1,498 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apigateway/clientman"
+	"yunion.io/x/onecloud/pkg/apigateway/options"
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/appctx"
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudcommon/policy"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
+	"yunion.io/x/onecloud/pkg/util/webauthn"
+)
+
+// relying party id默认为请求的域名
+func getWebAuthnRelyingParty(req *http.Request) webauthn.SRelyingParty {
+	rpId := options.Options.WebauthnRpId
+	if len(rpId) == 0 {
+		rpId = req.Host
+		if host, _, err := net.SplitHostPort(req.Host); err == nil {
+			rpId = host
+		}
+	}
+	return webauthn.SRelyingParty{
+		Id:      rpId,
+		Name:    options.Options.WebauthnRpName,
+		Origins: options.Options.WebauthnOrigins,
+	}
+}
+
+func toWebAuthnCredentials(creds []modules.SWebAuthnCredential) []webauthn.SCredential {
+	ret := make([]webauthn.SCredential, 0, len(creds))
+	for i := range creds {
+		ret = append(ret, creds[i].SCredential)
+	}
+	return ret
+}
+
+// 根据用户所在域的MFA策略，强制开启双因子认证或要求使用安全密钥
+func applyDomainMfaPolicy(s *mcclient.ClientSession, token mcclient.TokenCredential, authToken *clientman.SAuthToken) error {
+	domain, err := modules.Domains.Get(s, token.GetDomainId(), nil)
+	if err != nil {
+		return errors.Wrap(err, "Domains.Get")
+	}
+	mfaPolicy, _ := domain.GetString("mfa_policy")
+	switch mfaPolicy {
+	case api.MfaPolicyRequired:
+		authToken.SetTotpEnabled()
+	case api.MfaPolicyWebAuthn:
+		authToken.SetWebAuthnRequired()
+	case api.MfaPolicyAdminWebAuthn:
+		if policy.PolicyManager.IsScopeCapable(token, rbacutils.ScopeSystem) {
+			authToken.SetWebAuthnRequired()
+		}
+	}
+	return nil
+}
+
+// 已注册安全密钥的用户，登录时必须通过双因子认证
+func initWebAuthnState(s *mcclient.ClientSession, uid string, authToken *clientman.SAuthToken) error {
+	creds, err := modules.Credentials.GetWebAuthnCredentials(s, uid)
+	if err != nil {
+		return errors.Wrap(err, "GetWebAuthnCredentials")
+	}
+	authToken.SetWebAuthnInitialized(len(creds) > 0)
+	if len(creds) > 0 {
+		authToken.SetTotpEnabled()
+	}
+	return nil
+}
+
+// 未开启双因子认证、首次注册（未设置TOTP和安全密钥）或者本次会话已通过双因子认证，才允许注册新的安全密钥。
+// 已注册安全密钥的用户，必须先通过安全密钥或恢复码验证
+func isWebAuthnRegisterAllowed(authToken *clientman.SAuthToken) bool {
+	if !options.Options.EnableTotp || !authToken.IsTotpEnabled() {
+		return true
+	}
+	if !authToken.IsTotpInitialized() && !authToken.IsWebAuthnInitialized() {
+		return true
+	}
+	if authToken.IsWebAuthnVerified() {
+		return true
+	}
+	return !authToken.IsWebAuthnInitialized() && authToken.IsTotpPasscodeVerified()
+}
+
+func fetchWebAuthnBody(ctx context.Context, w http.ResponseWriter, req *http.Request) (mcclient.TokenCredential, *clientman.SAuthToken, jsonutils.JSONObject, bool) {
+	t, authToken, err := fetchAuthInfo(ctx, req)
+	if err != nil {
+		httperrors.InvalidCredentialError(ctx, w, "fetchAuthInfo fail: %s", err)
+		return nil, nil, nil, false
+	}
+	_, _, body := appsrv.FetchEnv(ctx, w, req)
+	if body == nil {
+		body = jsonutils.NewDict()
+	}
+	return t, authToken, body, true
+}
+
+// 开始注册安全密钥，返回navigator.credentials.create()的参数
+func beginWebAuthnRegister(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	t, authToken, _, ok := fetchWebAuthnBody(ctx, w, req)
+	if !ok {
+		return
+	}
+	if !isWebAuthnRegisterAllowed(authToken) {
+		httperrors.ForbiddenError(ctx, w, "two-factor authentication required before registering a security key")
+		return
+	}
+
+	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
+	creds, err := modules.Credentials.GetWebAuthnCredentials(s, t.GetUserId())
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	session, err := authToken.NewWebAuthnSession(webauthn.CeremonyCreate, challenge, t.GetUserId())
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+
+	user := webauthn.SUserEntity{
+		Id:          webauthn.EncodeBase64([]byte(t.GetUserId())),
+		Name:        t.GetUserName(),
+		DisplayName: t.GetUserName(),
+	}
+	rp := getWebAuthnRelyingParty(req)
+	opts := rp.NewCreationOptions(user, challenge, webauthn.UserVerificationPreferred, toWebAuthnCredentials(creds))
+
+	resp := jsonutils.NewDict()
+	resp.Add(jsonutils.NewString(session), "session")
+	resp.Add(jsonutils.Marshal(opts), "public_key")
+	appsrv.SendJSON(w, resp)
+}
+
+// 完成注册安全密钥。首个安全密钥注册成功时返回恢复码，恢复码只显示一次
+func finishWebAuthnRegister(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	t, authToken, body, ok := fetchWebAuthnBody(ctx, w, req)
+	if !ok {
+		return
+	}
+	if !isWebAuthnRegisterAllowed(authToken) {
+		httperrors.ForbiddenError(ctx, w, "two-factor authentication required before registering a security key")
+		return
+	}
+
+	sessionStr, _ := body.GetString("session")
+	sess, err := authToken.DecodeWebAuthnSession(webauthn.CeremonyCreate, t.GetUserId(), sessionStr)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	cred := webauthn.SRegistrationCredential{}
+	if body.Contains("credential") {
+		err = body.Unmarshal(&cred, "credential")
+	}
+	if err != nil || len(cred.Response.AttestationObject) == 0 {
+		httperrors.InputParameterError(ctx, w, "invalid credential")
+		return
+	}
+
+	rp := getWebAuthnRelyingParty(req)
+	newCred, err := rp.VerifyRegistration(cred, sess.Challenge, webauthn.UserVerificationPreferred)
+	if err != nil {
+		log.Warningf("VerifyRegistration for %s fail %s", t.GetUserName(), err)
+		httperrors.InputParameterError(ctx, w, "security key registration failed: %v", err)
+		return
+	}
+
+	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
+	creds, err := modules.Credentials.GetWebAuthnCredentials(s, t.GetUserId())
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	for i := range creds {
+		if creds[i].CredentialId == newCred.CredentialId {
+			httperrors.ConflictError(ctx, w, "security key already registered")
+			return
+		}
+	}
+	name, _ := body.GetString("name")
+	saved, err := modules.Credentials.CreateWebAuthnCredential(s, t.GetUserId(), name, *newCred)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+
+	resp := jsonutils.NewDict()
+	resp.Add(jsonutils.NewString(saved.Id), "id")
+	resp.Add(jsonutils.NewString(saved.Name), "name")
+	if len(creds) == 0 {
+		codes, err := modules.Credentials.ResetWebAuthnRecoveryCodes(s, t.GetUserId())
+		if err != nil {
+			httperrors.GeneralServerError(ctx, w, err)
+			return
+		}
+		resp.Add(jsonutils.NewStringArray(codes), "recovery_codes")
+	}
+
+	authToken.SetWebAuthnInitialized(true)
+	saveAuthCookie(w, authToken, t)
+
+	appsrv.SendJSON(w, resp)
+}
+
+// 开始安全密钥验证，返回navigator.credentials.get()的参数
+func beginWebAuthnLogin(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	t, authToken, _, ok := fetchWebAuthnBody(ctx, w, req)
+	if !ok {
+		return
+	}
+
+	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
+	creds, err := modules.Credentials.GetWebAuthnCredentials(s, t.GetUserId())
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	if len(creds) == 0 {
+		httperrors.NotFoundError(ctx, w, "no security key registered")
+		return
+	}
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	session, err := authToken.NewWebAuthnSession(webauthn.CeremonyGet, challenge, t.GetUserId())
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+
+	rp := getWebAuthnRelyingParty(req)
+	opts := rp.NewRequestOptions(challenge, webauthn.UserVerificationPreferred, toWebAuthnCredentials(creds))
+
+	resp := jsonutils.NewDict()
+	resp.Add(jsonutils.NewString(session), "session")
+	resp.Add(jsonutils.Marshal(opts), "public_key")
+	appsrv.SendJSON(w, resp)
+}
+
+// 验证安全密钥断言
+func finishWebAuthnLogin(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	t, authToken, body, ok := fetchWebAuthnBody(ctx, w, req)
+	if !ok {
+		return
+	}
+
+	sessionStr, _ := body.GetString("session")
+	sess, err := authToken.DecodeWebAuthnSession(webauthn.CeremonyGet, t.GetUserId(), sessionStr)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	assertion := webauthn.SAssertionCredential{}
+	if body.Contains("credential") {
+		err = body.Unmarshal(&assertion, "credential")
+	}
+	if err != nil || len(assertion.Response.Signature) == 0 {
+		httperrors.InputParameterError(ctx, w, "invalid credential")
+		return
+	}
+
+	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
+	creds, err := modules.Credentials.GetWebAuthnCredentials(s, t.GetUserId())
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	idx := webauthn.FindCredential(toWebAuthnCredentials(creds), assertion)
+	if idx < 0 {
+		httperrors.InvalidCredentialError(ctx, w, "unknown security key")
+		return
+	}
+
+	rp := getWebAuthnRelyingParty(req)
+	var signCount uint32
+	err = authToken.VerifyWebAuthn(func() error {
+		var err error
+		signCount, err = rp.VerifyAssertion(creds[idx].SCredential, assertion, sess.Challenge, webauthn.UserVerificationPreferred)
+		return err
+	})
+
+	saveAuthCookie(w, authToken, t)
+
+	if err != nil {
+		log.Warningf("VerifyAssertion for %s fail %s", t.GetUserName(), err)
+		httperrors.InvalidCredentialError(ctx, w, "security key verification failed: %v", err)
+		return
+	}
+
+	creds[idx].SignCount = signCount
+	creds[idx].LastUsedAt = time.Now().Unix()
+	err = modules.Credentials.UpdateWebAuthnCredential(s, creds[idx])
+	if err != nil {
+		log.Errorf("UpdateWebAuthnCredential %s fail %s", creds[idx].Id, err)
+	}
+
+	appsrv.SendJSON(w, jsonutils.NewDict())
+}
+
+// 安全密钥丢失时，使用恢复码完成双因子认证。每个恢复码只能使用一次
+func validateWebAuthnRecoveryCode(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	t, authToken, body, ok := fetchWebAuthnBody(ctx, w, req)
+	if !ok {
+		return
+	}
+	code, _ := body.GetString("code")
+	if len(code) == 0 {
+		httperrors.MissingParameterError(ctx, w, "code")
+		return
+	}
+
+	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
+	cnt := 0
+	err := authToken.VerifyWebAuthn(func() error {
+		var err error
+		cnt, err = modules.Credentials.ConsumeWebAuthnRecoveryCode(s, t.GetUserId(), code)
+		return err
+	})
+
+	saveAuthCookie(w, authToken, t)
+
+	if err != nil {
+		log.Warningf("ConsumeWebAuthnRecoveryCode for %s fail %s", t.GetUserName(), err)
+		httperrors.InvalidCredentialError(ctx, w, "invalid recovery code: %v", err)
+		return
+	}
+
+	resp := jsonutils.NewDict()
+	resp.Add(jsonutils.NewInt(int64(cnt)), "recovery_codes_left")
+	appsrv.SendJSON(w, resp)
+}
+
+// 重新生成恢复码，旧的恢复码全部失效
+func resetWebAuthnRecoveryCodes(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	t := AppContextToken(ctx)
+	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
+	creds, err := modules.Credentials.GetWebAuthnCredentials(s, t.GetUserId())
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	if len(creds) == 0 {
+		httperrors.NotFoundError(ctx, w, "no security key registered")
+		return
+	}
+	codes, err := modules.Credentials.ResetWebAuthnRecoveryCodes(s, t.GetUserId())
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	resp := jsonutils.NewDict()
+	resp.Add(jsonutils.NewStringArray(codes), "recovery_codes")
+	appsrv.SendJSON(w, resp)
+}
+
+// 列出当前用户的安全密钥
+func listWebAuthnCredentials(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	t := AppContextToken(ctx)
+	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
+	creds, err := modules.Credentials.GetWebAuthnCredentials(s, t.GetUserId())
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	data := jsonutils.NewArray()
+	for i := range creds {
+		cred := jsonutils.NewDict()
+		cred.Add(jsonutils.NewString(creds[i].Id), "id")
+		cred.Add(jsonutils.NewString(creds[i].Name), "name")
+		cred.Add(jsonutils.NewTimeString(creds[i].CreatedAt), "created_at")
+		if creds[i].LastUsedAt > 0 {
+			cred.Add(jsonutils.NewTimeString(time.Unix(creds[i].LastUsedAt, 0)), "last_used_at")
+		}
+		cred.Add(jsonutils.NewString(creds[i].Aaguid), "aaguid")
+		cred.Add(jsonutils.NewStringArray(creds[i].Transports), "transports")
+		data.Add(cred)
+	}
+	cnt, err := modules.Credentials.GetWebAuthnRecoveryCodeCount(s, t.GetUserId())
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	resp := jsonutils.NewDict()
+	resp.Add(data, "data")
+	resp.Add(jsonutils.NewInt(int64(cnt)), "recovery_codes_left")
+	appsrv.SendJSON(w, resp)
+}
+
+func fetchUserWebAuthnCredential(ctx context.Context, s *mcclient.ClientSession, uid string) (*modules.SWebAuthnCredential, []modules.SWebAuthnCredential, error) {
+	credId := appctx.AppContextParams(ctx)["<credential_id>"]
+	creds, err := modules.Credentials.GetWebAuthnCredentials(s, uid)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range creds {
+		if creds[i].Id == credId || creds[i].Name == credId {
+			return &creds[i], creds, nil
+		}
+	}
+	return nil, nil, httperrors.NewResourceNotFoundError2("security key", credId)
+}
+
+// 重命名安全密钥
+func updateWebAuthnCredential(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	t := AppContextToken(ctx)
+	body, err := appsrv.FetchJSON(req)
+	if err != nil {
+		httperrors.InvalidInputError(ctx, w, "fetch json for request: %v", err)
+		return
+	}
+	name, _ := body.GetString("name")
+	if len(name) == 0 {
+		httperrors.MissingParameterError(ctx, w, "name")
+		return
+	}
+
+	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
+	cred, _, err := fetchUserWebAuthnCredential(ctx, s, t.GetUserId())
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.NewString(name), "name")
+	_, err = modules.Credentials.Update(s, cred.Id, params)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	appsrv.SendJSON(w, jsonutils.NewDict())
+}
+
+// 删除安全密钥。删除最后一个安全密钥时，同时删除恢复码
+func deleteWebAuthnCredential(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	t, authToken, err := fetchAuthInfo(ctx, req)
+	if err != nil {
+		httperrors.InvalidCredentialError(ctx, w, "fetchAuthInfo fail: %s", err)
+		return
+	}
+
+	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
+	cred, creds, err := fetchUserWebAuthnCredential(ctx, s, t.GetUserId())
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	_, err = modules.Credentials.Delete(s, cred.Id, nil)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	if len(creds) == 1 {
+		err = modules.Credentials.RemoveWebAuthnRecoveryCodes(s, t.GetUserId())
+		if err != nil {
+			httperrors.GeneralServerError(ctx, w, err)
+			return
+		}
+		authToken.SetWebAuthnInitialized(false)
+		saveAuthCookie(w, authToken, t)
+	}
+	appsrv.SendJSON(w, jsonutils.NewDict())
+}
@@ -26,6 +26,7 @@ import (
 	"yunion.io/x/onecloud/pkg/apigateway/clientman"
 	"yunion.io/x/onecloud/pkg/apigateway/constants"
 	"yunion.io/x/onecloud/pkg/appctx"
+	"yunion.io/x/onecloud/pkg/cloudcommon/ratelimit"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
 )
@@ -132,6 +133,6 @@ func FetchAuthToken(f func(context.Context, http.ResponseWriter, *http.Request))
 			httperrors.InvalidCredentialError(ctx, w, "No token in header: %v", err)
 			return
 		}
-		f(ctx, w, r)
+		ratelimit.Limit(f)(ctx, w, r)
 	}
 }
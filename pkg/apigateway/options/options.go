@@ -28,6 +28,10 @@ type GatewayOptions struct {
 
 	EnableTotp bool `help:"Enable two-factor authentication" default:"true"`
 
+	WebauthnRpId    string   `help:"WebAuthn relying party id, default is the host of the request"`
+	WebauthnRpName  string   `help:"WebAuthn relying party name shown by authenticators" default:"Onecloud"`
+	WebauthnOrigins []string `help:"Allowed origins of WebAuthn ceremonies, default is https origins under the relying party id"`
+
 	SsoRedirectUrl     string `help:"SSO idp redirect URL"`
 	SsoAuthCallbackUrl string `help:"SSO idp auth callback URL"`
 	SsoLinkCallbackUrl string `help:"SSO idp link user callback URL"`
@@ -40,6 +44,9 @@ type GatewayOptions struct {
 	SessionLevelAuthCookie bool `default:"false" help:"YunionAuth cookie is valid during a browser session"`
 
 	common_options.CommonOptions `"request_worker_count->default":"32"`
+
+	// etcd cluster to share api rate limit buckets across replicas
+	common_options.EtcdOptions
 }
 
 var (
@@ -27,6 +27,7 @@ import (
 	api "yunion.io/x/onecloud/pkg/apis/apigateway"
 	app_common "yunion.io/x/onecloud/pkg/cloudcommon/app"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
+	"yunion.io/x/onecloud/pkg/cloudcommon/ratelimit"
 	"yunion.io/x/onecloud/pkg/mcclient"
 )
 
@@ -50,6 +51,13 @@ func StartService() {
 		log.Fatalf("Init client token manager: %v", err)
 	}
 
+	if len(opts.EtcdEndpoints) > 0 {
+		ratelimit.SetEtcdOptions(&opts.EtcdOptions)
+	}
+	if opts.EnableApiRateLimit {
+		ratelimit.Init(api.SERVICE_TYPE, opts.ApiRateLimitSyncIntervalSeconds)
+	}
+
 	serviceApp := app.NewApp(app_common.InitApp(baseOpts, false))
 	serviceApp.InitHandlers().Bind()
 
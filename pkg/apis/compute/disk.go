@@ -242,6 +242,11 @@ type DiskUpdateInput struct {
 	DiskType string `json:"disk_type"`
 }
 
+type DiskMigrateStorageInput struct {
+	// 目标存储（ID或Name），须挂载在磁盘当前所在的宿主机上
+	TargetStorageId string `json:"target_storage_id"`
+}
+
 type DiskSaveInput struct {
 	Name   string
 	Format string
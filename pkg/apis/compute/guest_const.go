@@ -100,6 +100,8 @@ const (
 	VM_RESIZE_DISK        = "resize_disk"
 	VM_RESIZE_DISK_FAILED = "resize_disk_fail"
 
+	VM_MIGRATE_DISK_STORAGE = "migrate_disk_storage"
+
 	VM_START_SAVE_DISK  = "start_save_disk"
 	VM_SAVE_DISK        = "save_disk"
 	VM_SAVE_DISK_FAILED = "save_disk_failed"
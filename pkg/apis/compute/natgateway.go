@@ -47,6 +47,18 @@ type NatGetewayListInput struct {
 	ManagedResourceListInput
 }
 
+type NatgatewayCreateInput struct {
+	apis.StatusInfrasResourceBaseCreateInput
+
+	VpcResourceInput
+
+	// NAT规格
+	NatSpec string `json:"nat_spec"`
+
+	// 绑定的弹性公网IP(ID或Name)
+	Eip string `json:"eip"`
+}
+
 type NatEntryListInput struct {
 	apis.StatusInfrasResourceBaseListInput
 	apis.ExternalizedResourceBaseListInput
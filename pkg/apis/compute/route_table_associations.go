@@ -21,3 +21,19 @@ type RouteTableAssociationListInput struct {
 	apis.ExternalizedResourceBaseListInput
 	RouteTableFilterList
 }
+
+// 关联路由表到本地VPC的子网
+//
+// 路由表仅能包含默认路由(0.0.0.0/0), 以子网为源地址生效;
+// 子网的掩码长度不能大于VPC内其他子网, 否则访问其他子网的流量也会被默认路由转发
+type RouteTableAssociationCreateInput struct {
+	apis.StatusStandaloneResourceCreateInput
+
+	// 路由表
+	RouteTableId string `json:"route_table_id"`
+
+	// 关联类型, 目前仅支持 Subnet
+	AssociationType string `json:"association_type"`
+	// 关联的子网
+	AssociatedResourceId string `json:"associated_resource_id"`
+}
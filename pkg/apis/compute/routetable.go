@@ -65,6 +65,12 @@ func (route *SRoute) Validate() error {
 			return errors.Wrapf(httperrors.ErrInputParameter, "invalid addr %s", route.Cidr)
 		}
 	}
+	if route.NextHopType == Next_HOP_TYPE_IP {
+		ip := net.ParseIP(route.NextHopId).To4()
+		if ip == nil {
+			return errors.Wrapf(httperrors.ErrInputParameter, "invalid next hop addr %s", route.NextHopId)
+		}
+	}
 	return nil
 }
 
@@ -102,6 +108,20 @@ func (routes *SRoutes) Validate() error {
 	return nil
 }
 
+// ValidateAssociated returns error if there are custom routes other than the
+// default one, which can not take effect for the associated networks only
+func (routes SRoutes) ValidateAssociated() error {
+	for _, route := range routes {
+		if route.Type == ROUTE_ENTRY_TYPE_SYSTEM {
+			continue
+		}
+		if route.Cidr != "0.0.0.0/0" {
+			return httperrors.NewInputParameterError("route table associated with networks can only have the default route, got %s", route.Cidr)
+		}
+	}
+	return nil
+}
+
 type RouteTableCreateInput struct {
 	apis.StatusInfrasResourceBaseCreateInput
 
@@ -37,10 +37,19 @@ type StorageCreateInput struct {
 	// | rbd 			| rbd_client_mount_timeout	| 否 		|	120		|单位: 秒	|
 	// | nfs 			| nfs_host					| 是 		|			|网络文件系统主机	|
 	// | nfs 			| nfs_shared_dir			| 是 		|			|网络文件系统共享目录	|
+	// | cifs 			| cifs_host					| 是 		|			|SMB/CIFS文件服务器	|
+	// | cifs 			| cifs_shared_dir			| 是 		|			|SMB/CIFS共享目录	|
+	// | cifs 			| cifs_username				| 否 		|			|访问共享的用户名	|
+	// | cifs 			| cifs_password				| 否 		|			|访问共享的密码	|
+	// | cifs 			| cifs_domain				| 否 		|			|用户所属的域	|
+	// | cifs 			| cifs_version				| 否 		|			|SMB协议版本, 如 2.1, 3.0	|
+	// | lvm 			| lvm_vg					| 是 		|			|LVM卷组	|
+	// | lvm 			| lvm_thin_pool				| 是 		|			|卷组中的精简池	|
 	// local: 本地存储
 	// rbd: ceph块存储, ceph存储创建时仅会检测是否重复创建，不会具体检测认证参数是否合法，只有挂载存储时
 	// 计算节点会验证参数，若挂载失败，宿主机和存储不会关联，可以通过查看存储日志查找挂载失败原因
-	// enum: local, rbd, nfs, gpfs
+	// lvm: 宿主机本地LVM精简池, 由计算节点上报创建
+	// enum: local, rbd, nfs, gpfs, cifs, lvm
 	// required: true
 	StorageType string `json:"storage_type"`
 
@@ -105,6 +114,37 @@ type StorageCreateInput struct {
 	// 网络文件系统共享目录, storage_type 为 nfs 时, 此参数必传
 	// example: /nfs_root/
 	NfsSharedDir string `json:"nfs_shared_dir"`
+
+	// SMB/CIFS文件服务器, storage_type 为 cifs 时, 此参数必传
+	// example: 192.168.222.2
+	CifsHost string `json:"cifs_host"`
+
+	// SMB/CIFS共享目录, storage_type 为 cifs 时, 此参数必传
+	// example: /vms
+	CifsSharedDir string `json:"cifs_shared_dir"`
+
+	// 访问共享的用户名, 为空时以guest身份挂载
+	// example: administrator
+	CifsUsername string `json:"cifs_username"`
+
+	// 访问共享的密码
+	CifsPassword string `json:"cifs_password"`
+
+	// 用户所属的域或工作组
+	// example: WORKGROUP
+	CifsDomain string `json:"cifs_domain"`
+
+	// SMB协议版本, 为空时由客户端和服务器协商
+	// example: 3.0
+	CifsVersion string `json:"cifs_version"`
+
+	// LVM卷组, storage_type 为 lvm 时, 此参数必传
+	// example: vg_data
+	LvmVg string `json:"lvm_vg"`
+
+	// LVM卷组中的精简池, storage_type 为 lvm 时, 此参数必传
+	// example: thinpool
+	LvmThinPool string `json:"lvm_thin_pool"`
 }
 
 type SStorageCapacityInfo struct {
@@ -51,3 +51,6 @@ type VpcPeeringConnectionListInput struct {
 type VpcPeeringConnectionUpdateInput struct {
 	apis.EnabledStatusInfrasResourceBaseUpdateInput
 }
+
+type VpcPeeringConnectionAcceptInput struct {
+}
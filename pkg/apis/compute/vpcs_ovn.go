@@ -46,6 +46,13 @@ const (
 	VpcInterExtMac2  = "ee:ee:ee:ee:ee:f1"
 )
 
+// Router port pairs of on-premise vpc peering connections take /30 subnets
+// from the inter cidr.  The first one is used by vpc ext router
+const (
+	VpcPeeringLinkMask     = 30
+	VpcPeeringLinkIndexMax = 1<<(32-17)/4 - 1
+)
+
 var (
 	vpcInterCidr   netutils.IPV4Prefix
 	vpcInterExtIP1 netutils.IPV4Addr
@@ -64,6 +71,13 @@ func VpcInterExtIP2() netutils.IPV4Addr {
 	return vpcInterExtIP2
 }
 
+// VpcPeeringLinkIPs returns addresses of router ports on the requester and
+// accepter side of vpc peering link with index idx, [1, VpcPeeringLinkIndexMax]
+func VpcPeeringLinkIPs(idx int) (netutils.IPV4Addr, netutils.IPV4Addr) {
+	netAddr := vpcInterCidr.Address + netutils.IPV4Addr(idx*4)
+	return netAddr + 1, netAddr + 2
+}
+
 const (
 	sVpcMappedCidr      = "100.64.0.0/17"
 	VpcMappedIPMask     = 17
@@ -15,6 +15,9 @@
 package identity
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
 	"time"
 )
 
@@ -25,6 +28,9 @@ const (
 	TOTP_TYPE             = "totp"
 	RECOVERY_SECRETS_TYPE = "recovery_secret"
 	OIDC_CREDENTIAL_TYPE  = "oidc"
+
+	WEBAUTHN_CREDENTIAL_TYPE = "webauthn"
+	WEBAUTHN_RECOVERY_TYPE   = "webauthn_recovery"
 )
 
 type SAccessKeySecretBlob struct {
@@ -39,6 +45,17 @@ func (info SAccessKeySecretBlob) IsValid() bool {
 	return false
 }
 
+// WebAuthn恢复码，只保存sha256摘要，每个恢复码只能使用一次
+type SWebAuthnRecoveryCodes struct {
+	Codes     []string `json:"codes"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+func HashWebAuthnRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
 type SAccessKeySecretInfo struct {
 	AccessKey string
 	SAccessKeySecretBlob
@@ -0,0 +1,32 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import "testing"
+
+func TestHashWebAuthnRecoveryCode(t *testing.T) {
+	hash := HashWebAuthnRecoveryCode("abcd-2345")
+	if len(hash) != 64 {
+		t.Fatalf("HashWebAuthnRecoveryCode got %q, want a sha256 hex digest", hash)
+	}
+	for _, code := range []string{"ABCD-2345", " abcd-2345\n"} {
+		if got := HashWebAuthnRecoveryCode(code); got != hash {
+			t.Errorf("HashWebAuthnRecoveryCode(%q) = %s, want %s", code, got, hash)
+		}
+	}
+	if got := HashWebAuthnRecoveryCode("abcd-2346"); got == hash {
+		t.Errorf("different codes got the same hash %s", got)
+	}
+}
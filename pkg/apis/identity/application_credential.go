@@ -0,0 +1,200 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/gotypes"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+const (
+	ACCESS_RULE_WILDCARD       = "*"
+	ACCESS_RULE_MULTI_WILDCARD = "**"
+)
+
+// SAccessRule restricts the API requests an application credential is allowed to issue
+type SAccessRule struct {
+	// 服务类型，例如compute, image等
+	Service string `json:"service"`
+	// HTTP方法，为空或*时匹配所有方法
+	Method string `json:"method"`
+	// API路径，*匹配一级路径，**匹配任意多级路径
+	// example: /servers/*
+	Path string `json:"path"`
+}
+
+func (rule *SAccessRule) Validate() error {
+	if len(rule.Service) == 0 {
+		return errors.Wrap(httperrors.ErrInputParameter, "missing access rule service")
+	}
+	if !strings.HasPrefix(rule.Path, "/") {
+		return errors.Wrapf(httperrors.ErrInputParameter, "access rule path %q should start with /", rule.Path)
+	}
+	rule.Method = strings.ToUpper(rule.Method)
+	return nil
+}
+
+func (rule SAccessRule) Match(service, method, path string) bool {
+	if rule.Service != service {
+		return false
+	}
+	if len(rule.Method) > 0 && rule.Method != ACCESS_RULE_WILDCARD && !strings.EqualFold(rule.Method, method) {
+		return false
+	}
+	return matchAccessRulePath(splitAccessRulePath(rule.Path), splitAccessRulePath(path))
+}
+
+func splitAccessRulePath(path string) []string {
+	segs := make([]string, 0)
+	for _, seg := range strings.Split(path, "/") {
+		if len(seg) > 0 {
+			segs = append(segs, seg)
+		}
+	}
+	return segs
+}
+
+func matchAccessRulePath(pattern, segs []string) bool {
+	if len(pattern) == 0 {
+		return len(segs) == 0
+	}
+	if pattern[0] == ACCESS_RULE_MULTI_WILDCARD {
+		for i := 0; i <= len(segs); i++ {
+			if matchAccessRulePath(pattern[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	if pattern[0] != ACCESS_RULE_WILDCARD && pattern[0] != segs[0] {
+		return false
+	}
+	return matchAccessRulePath(pattern[1:], segs[1:])
+}
+
+// ParseAccessRule parses access rule in the form of <service>:<method>:<path>
+func ParseAccessRule(str string) (SAccessRule, error) {
+	rule := SAccessRule{}
+	parts := strings.SplitN(str, ":", 3)
+	if len(parts) != 3 {
+		return rule, errors.Wrapf(httperrors.ErrInputParameter, "invalid access rule %q, expect <service>:<method>:<path>", str)
+	}
+	rule.Service = parts[0]
+	rule.Method = parts[1]
+	rule.Path = parts[2]
+	err := rule.Validate()
+	if err != nil {
+		return rule, err
+	}
+	return rule, nil
+}
+
+type SAccessRules []SAccessRule
+
+func (rules SAccessRules) String() string {
+	return jsonutils.Marshal(rules).String()
+}
+
+func (rules SAccessRules) IsZero() bool {
+	return len(rules) == 0
+}
+
+func (rules SAccessRules) Validate() error {
+	for i := range rules {
+		if err := rules[i].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match reports whether the request is allowed by any of the rules
+func (rules SAccessRules) Match(service, method, path string) bool {
+	for i := range rules {
+		if rules[i].Match(service, method, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	gotypes.RegisterSerializable(reflect.TypeOf(&SAccessRules{}), func() gotypes.ISerializable {
+		return &SAccessRules{}
+	})
+}
+
+type ApplicationCredentialCreateInput struct {
+	apis.StandaloneResourceCreateInput
+
+	// 应用凭证绑定的项目，默认为当前token的项目
+	ProjectId string `json:"project_id"`
+
+	// 应用凭证的角色列表（ID或Name），必须是用户在该项目的角色的子集，默认为用户在该项目的全部角色
+	Roles []string `json:"roles"`
+
+	// 过期时间，为空则永不过期
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// API访问规则，为空则不限制
+	AccessRules SAccessRules `json:"access_rules"`
+
+	// 应用凭证的密钥，为空则自动生成，指定时长度不少于32个字符，密钥只在创建时返回一次
+	Secret string `json:"secret"`
+}
+
+type ApplicationCredentialListInput struct {
+	apis.StandaloneResourceListInput
+
+	UserFilterListInput
+	ProjectFilterListInput
+}
+
+type ApplicationCredentialUpdateInput struct {
+	apis.StandaloneResourceBaseUpdateInput
+}
+
+type ApplicationCredentialDetails struct {
+	apis.StandaloneResourceDetails
+	SApplicationCredential
+
+	User     string `json:"user"`
+	Domain   string `json:"domain"`
+	DomainId string `json:"domain_id"`
+	Project  string `json:"project"`
+
+	Roles []SIdentityObject `json:"roles"`
+
+	// 应用凭证的密钥，只在创建时返回
+	Secret string `json:"secret,omitempty"`
+}
+
+// SApplicationCredentialInfo is the application credential a token is issued for
+type SApplicationCredentialInfo struct {
+	Id          string       `json:"id"`
+	Name        string       `json:"name"`
+	AccessRules SAccessRules `json:"access_rules,omitempty"`
+}
@@ -0,0 +1,54 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import "testing"
+
+func TestSAccessRuleMatch(t *testing.T) {
+	cases := []struct {
+		rule    string
+		service string
+		method  string
+		path    string
+		want    bool
+	}{
+		{"compute:GET:/servers", "compute", "GET", "/servers", true},
+		{"compute:GET:/servers", "compute", "POST", "/servers", false},
+		{"compute:GET:/servers", "image", "GET", "/servers", false},
+		{"compute::/servers", "compute", "DELETE", "/servers", true},
+		{"compute:*:/servers/*", "compute", "get", "/servers/abc", true},
+		{"compute:*:/servers/*", "compute", "GET", "/servers/abc/disks", false},
+		{"compute:*:/servers/*", "compute", "GET", "/servers", false},
+		{"compute:*:/servers/**", "compute", "GET", "/servers", true},
+		{"compute:*:/servers/**", "compute", "GET", "/servers/abc/disks/", true},
+		{"compute:*:/**/disks", "compute", "GET", "/servers/abc/disks", true},
+		{"compute:*:/**/disks", "compute", "GET", "/servers/abc/nics", false},
+	}
+	for _, c := range cases {
+		rule, err := ParseAccessRule(c.rule)
+		if err != nil {
+			t.Fatalf("ParseAccessRule %s: %s", c.rule, err)
+		}
+		if got := rule.Match(c.service, c.method, c.path); got != c.want {
+			t.Errorf("%s match %s %s %s: want %v got %v", c.rule, c.service, c.method, c.path, c.want, got)
+		}
+	}
+
+	for _, str := range []string{"compute:/servers", ":GET:/servers", "compute:GET:servers"} {
+		if _, err := ParseAccessRule(str); err == nil {
+			t.Errorf("invalid access rule %s should fail", str)
+		}
+	}
+}
@@ -37,6 +37,7 @@ const (
 	AUTH_METHOD_SAML     = "saml"
 	AUTH_METHOD_OIDC     = "oidc"
 	AUTH_METHOD_OAuth2   = "oauth2"
+	AUTH_METHOD_APPCRED  = "application_credential"
 
 	// AUTH_METHOD_ID_PASSWORD = 1
 	// AUTH_METHOD_ID_TOKEN    = 2
@@ -81,10 +82,21 @@ const (
 	IdentitySyncStatusIdle    = "idle"
 
 	MinimalSyncIntervalSeconds = 5 * 60 // 5 minutes
+
+	// 由用户自行决定是否开启多因子认证
+	MfaPolicyOptional = "optional"
+	// 域内所有用户必须通过多因子认证，TOTP和安全密钥均可
+	MfaPolicyRequired = "required"
+	// 域内所有用户必须通过安全密钥(WebAuthn)认证
+	MfaPolicyWebAuthn = "webauthn"
+	// 具备系统管理权限的用户必须通过安全密钥(WebAuthn)认证，其他用户同optional
+	MfaPolicyAdminWebAuthn = "admin_webauthn"
 )
 
 var (
-	AUTH_METHODS = []string{AUTH_METHOD_PASSWORD, AUTH_METHOD_TOKEN, AUTH_METHOD_AKSK, AUTH_METHOD_CAS}
+	AUTH_METHODS = []string{AUTH_METHOD_PASSWORD, AUTH_METHOD_TOKEN, AUTH_METHOD_AKSK, AUTH_METHOD_CAS, AUTH_METHOD_APPCRED}
+
+	MFA_POLICIES = []string{MfaPolicyOptional, MfaPolicyRequired, MfaPolicyWebAuthn, MfaPolicyAdminWebAuthn}
 
 	PASSWORD_PROTECTED_IDPS = []string{
 		IdentityDriverSQL,
@@ -31,4 +31,7 @@ type CredentialUpdateInput struct {
 
 	// enabled
 	Enabled *bool `json:"enabled"`
+
+	// 更新凭证内容，仅webauthn类型的凭证支持，用于记录签名计数和最近使用时间
+	Blob string `json:"blob"`
 }
@@ -40,6 +40,10 @@ type DomainUpdateInput struct {
 
 	// 是否启用
 	Enabled *bool `json:"enabled"`
+
+	// 域内用户的多因子认证策略
+	// enum: optional, required, webauthn, admin_webauthn
+	MfaPolicy string `json:"mfa_policy"`
 }
 
 type DomainCreateInput struct {
@@ -50,4 +54,8 @@ type DomainCreateInput struct {
 
 	// 是否启用
 	Enabled *bool `json:"enabled"`
+
+	// 域内用户的多因子认证策略
+	// enum: optional, required, webauthn, admin_webauthn
+	MfaPolicy string `json:"mfa_policy"`
 }
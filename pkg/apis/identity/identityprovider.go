@@ -44,6 +44,9 @@ type IdentityProviderDetails struct {
 	// 该认证源关联的所有域的组数量
 	GroupCount int `json:"group_count,allowempty"`
 
+	// 是否开启了SCIM用户供应
+	ScimEnabled bool `json:"scim_enabled"`
+
 	SIdentityProvider
 }
 
@@ -126,3 +129,8 @@ type GetIdpSsoRedirectUriOutput struct {
 type PerformDefaultSsoInput struct {
 	Enable *bool `json:"enable" help:"enable default sso" negative:"disable"`
 }
+
+type IdentityProviderScimTokenOutput struct {
+	// SCIM bearer token，仅返回一次
+	Token string `json:"token"`
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"yunion.io/x/onecloud/pkg/apis"
+)
+
+type InformerWebhookCreateInput struct {
+	apis.StandaloneResourceCreateInput
+
+	// 接收事件的HTTP(S)地址
+	// example: https://cmdb.example.com/onecloud/events
+	Url string `json:"url"`
+
+	// 签名密钥，为空则自动生成
+	Secret string `json:"secret"`
+
+	// 订阅的资源类型（keyword_plural），为空则订阅全部资源
+	// example: servers
+	Resources []string `json:"resources"`
+
+	// 是否启用，默认启用
+	Enabled *bool `json:"enabled"`
+}
+
+type InformerWebhookUpdateInput struct {
+	apis.StandaloneResourceBaseUpdateInput
+
+	// 接收事件的HTTP(S)地址
+	Url string `json:"url"`
+
+	// 签名密钥
+	Secret string `json:"secret"`
+
+	// 订阅的资源类型（keyword_plural），设置为空数组则订阅全部资源
+	Resources []string `json:"resources"`
+}
+
+type InformerWebhookListInput struct {
+	apis.StandaloneResourceListInput
+	apis.EnabledResourceBaseListInput
+
+	// 以订阅的资源类型过滤，同时返回订阅全部资源的webhook
+	Resource string `json:"resource"`
+}
+
+type InformerWebhookDetails struct {
+	apis.StandaloneResourceDetails
+	SInformerWebhook
+
+	// 签名密钥
+	Secret string `json:"secret"`
+}
@@ -227,6 +227,16 @@ func (input SJoinProjectsInput) Validate() error {
 	return nil
 }
 
+type UserConsumeWebAuthnRecoveryCodeInput struct {
+	// 恢复码
+	Code string `json:"code"`
+}
+
+type UserConsumeWebAuthnRecoveryCodeOutput struct {
+	// 剩余的恢复码数量
+	RecoveryCodesLeft int `json:"recovery_codes_left"`
+}
+
 type SProjectRole struct {
 	Project string `json:"project"`
 	Role    string `json:"role"`
@@ -0,0 +1,45 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"time"
+
+	"yunion.io/x/pkg/utils"
+)
+
+type RevocationEventListOutput struct {
+	Events []SRevocationEvent `json:"events"`
+}
+
+// Match tells whether the event applies to the token regardless of when it
+// was issued, i.e. all of the non-empty fields of the event match the token
+func (e SRevocationEvent) Match(userId, projectId string, auditIds []string) bool {
+	if len(e.AuditId) > 0 && !utils.IsInStringArray(e.AuditId, auditIds) {
+		return false
+	}
+	if len(e.UserId) > 0 && e.UserId != userId {
+		return false
+	}
+	if len(e.ProjectId) > 0 && e.ProjectId != projectId {
+		return false
+	}
+	return len(e.AuditId) > 0 || len(e.UserId) > 0 || len(e.ProjectId) > 0
+}
+
+// IsRevoked tells whether the token issued at issuedAt is revoked by the event
+func (e SRevocationEvent) IsRevoked(userId, projectId string, auditIds []string, issuedAt time.Time) bool {
+	return !issuedAt.After(e.IssuedBefore) && e.Match(userId, projectId, auditIds)
+}
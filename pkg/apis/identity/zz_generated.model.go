@@ -20,6 +20,17 @@ import (
 	"yunion.io/x/onecloud/pkg/apis"
 )
 
+// SApplicationCredential is an autogenerated struct via yunion.io/x/onecloud/pkg/keystone/models.SApplicationCredential.
+type SApplicationCredential struct {
+	apis.SStandaloneResourceBase
+	UserId      string        `json:"user_id"`
+	ProjectId   string        `json:"project_id"`
+	RoleIds     string        `json:"role_ids"`
+	SecretHash  string        `json:"secret_hash"`
+	ExpiresAt   time.Time     `json:"expires_at"`
+	AccessRules *SAccessRules `json:"access_rules"`
+}
+
 // SAssignment is an autogenerated struct via yunion.io/x/onecloud/pkg/keystone/models.SAssignment.
 type SAssignment struct {
 	apis.SResourceBase
@@ -60,6 +71,8 @@ type SDomain struct {
 	IsDomain *bool       `json:"is_domain,omitempty"`
 	DomainId string      `json:"domain_id"`
 	ParentId string      `json:"parent_id"`
+	// 域内用户的多因子认证策略
+	MfaPolicy string `json:"mfa_policy"`
 }
 
 // SEnabledIdentityBaseResource is an autogenerated struct via yunion.io/x/onecloud/pkg/keystone/models.SEnabledIdentityBaseResource.
@@ -142,6 +155,8 @@ type SIdentityProvider struct {
 	IsSso *bool `json:"is_sso,omitempty"`
 	// 是否是缺省SSO登录方式
 	IsDefault *bool `json:"is_default,omitempty"`
+	// SCIM bearer token的sha256摘要，为空表示未开启SCIM
+	ScimTokenHash string `json:"scim_token_hash"`
 }
 
 // SIdmapping is an autogenerated struct via yunion.io/x/onecloud/pkg/keystone/models.SIdmapping.
@@ -165,6 +180,16 @@ type SImpliedRole struct {
 	ImpliedRoleId string `json:"implied_role_id"`
 }
 
+// SInformerWebhook is an autogenerated struct via yunion.io/x/onecloud/pkg/keystone/models.SInformerWebhook.
+type SInformerWebhook struct {
+	apis.SStandaloneResourceBase
+	apis.SEnabledResourceBase
+	Url             string `json:"url"`
+	Resources       string `json:"resources"`
+	EncryptedSecret string `json:"encrypted_secret"`
+	KeyHash         string `json:"key_hash"`
+}
+
 // SLocalUser is an autogenerated struct via yunion.io/x/onecloud/pkg/keystone/models.SLocalUser.
 type SLocalUser struct {
 	apis.SResourceBase
@@ -229,6 +254,17 @@ type SRegion struct {
 	Extra          interface{} `json:"extra"`
 }
 
+// SRevocationEvent is an autogenerated struct via yunion.io/x/onecloud/pkg/keystone/models.SRevocationEvent.
+type SRevocationEvent struct {
+	Id           int64     `json:"id"`
+	AuditId      string    `json:"audit_id"`
+	UserId       string    `json:"user_id"`
+	ProjectId    string    `json:"project_id"`
+	IssuedBefore time.Time `json:"issued_before"`
+	RevokedAt    time.Time `json:"revoked_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
 // SRole is an autogenerated struct via yunion.io/x/onecloud/pkg/keystone/models.SRole.
 type SRole struct {
 	SIdentityBaseResource
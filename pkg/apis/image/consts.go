@@ -38,6 +38,10 @@ const (
 	ImageTypeISO      = TImageType("iso")
 
 	LocalFilePrefix = "file://"
+	S3Prefix        = "s3://"
+
+	IMAGE_STORAGE_DRIVER_LOCAL = "local"
+	IMAGE_STORAGE_DRIVER_S3    = "s3"
 
 	// image properties
 	IMAGE_OS_ARCH             = "os_arch"
@@ -172,6 +172,12 @@ type EvalMatch struct {
 	Metric    string            `json:"metric"`
 	Tags      map[string]string `json:"tags"`
 	Unit      string            `json:"unit"`
+	// ResId and ProjectId are the ids of the resource the series belongs to,
+	// they are filtered out of Tags and used to match alert silences
+	ResId     string `json:"res_id"`
+	ProjectId string `json:"project_id"`
+	// Silenced is set when the match is muted by an alert silence
+	Silenced bool `json:"silenced"`
 }
 
 type AlertTestRunOutput struct {
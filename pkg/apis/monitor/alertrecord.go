@@ -14,6 +14,8 @@ type AlertRecordListInput struct {
 	State    string   `json:"state"`
 	ResTypes []string `json:"res_types"`
 	Alerting bool     `json:"alerting"`
+	// 以是否被屏蔽过滤报警记录
+	Silenced *bool `json:"silenced"`
 }
 
 type AlertRecordDetails struct {
@@ -34,6 +36,8 @@ type AlertRecordCreateInput struct {
 	ResType   string       `json:"res_type"`
 	EvalData  []*EvalMatch `json:"eval_data"`
 	AlertRule AlertRecordRule
+	// 报警在屏蔽时间窗口内, 未发送通知
+	Silenced bool `json:"silenced"`
 }
 
 type AlertRecordRule struct {
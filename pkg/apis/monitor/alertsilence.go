@@ -0,0 +1,108 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"time"
+
+	"yunion.io/x/onecloud/pkg/apis"
+)
+
+const (
+	AlertSilenceCycleTypeDay  = "day"
+	AlertSilenceCycleTypeWeek = "week"
+
+	AlertSilenceMatcherOpEqual    = "="
+	AlertSilenceMatcherOpNotEqual = "!="
+	AlertSilenceMatcherOpRegex    = "=~"
+	AlertSilenceMatcherOpNotRegex = "!~"
+)
+
+type AlertSilenceMatcher struct {
+	// 报警指标标签名称
+	// example: host
+	Key string `json:"key"`
+	// 匹配方式
+	// enum: =,!=,=~,!~
+	Operator string `json:"operator"`
+	// 标签值, 正则匹配时为正则表达式
+	Value string `json:"value"`
+}
+
+type AlertSilenceCreateInput struct {
+	apis.StandaloneResourceCreateInput
+
+	// 屏蔽的报警ID, 为空则匹配所有报警
+	AlertId string `json:"alert_id"`
+	// 屏蔽的资源ID, 例如宿主机或虚拟机ID
+	ResId string `json:"res_id"`
+	// 屏蔽资源所属的项目ID
+	ProjectId string `json:"project_id"`
+	// 屏蔽的监控指标, 格式为 measurement 或 measurement.field
+	// example: cpu.usage_active
+	Metric string `json:"metric"`
+	// 报警指标标签匹配规则, 所有规则都匹配时才屏蔽
+	Matchers []AlertSilenceMatcher `json:"matchers"`
+
+	// 生效时间, 默认为当前时间
+	StartTime time.Time `json:"start_time"`
+	// 失效时间, 非周期性屏蔽必须指定
+	EndTime time.Time `json:"end_time"`
+
+	// 周期类型, 为空时在 start_time 和 end_time 之间一直生效
+	// enum: day,week
+	CycleType string `json:"cycle_type"`
+	// 每周的周几; 1-7, 1: Monday, 7: Sunday
+	// example: [7]
+	WeekDays []int `json:"week_days"`
+	// 周期窗口开始的小时(0-23)
+	// example: 2
+	Hour int `json:"hour"`
+	// 周期窗口开始的分钟(0-59)
+	Minute int `json:"minute"`
+	// 周期窗口持续时间, 单位分钟, 不超过一天
+	// example: 120
+	Duration int `json:"duration"`
+
+	// 屏蔽原因
+	Comment string `json:"comment"`
+}
+
+type AlertSilenceUpdateInput struct {
+	apis.StandaloneResourceBaseUpdateInput
+
+	// 失效时间
+	EndTime *time.Time `json:"end_time"`
+	// 屏蔽原因
+	Comment *string `json:"comment"`
+}
+
+type AlertSilenceListInput struct {
+	apis.EnabledResourceBaseListInput
+	apis.StandaloneResourceListInput
+
+	AlertId   string `json:"alert_id"`
+	ResId     string `json:"res_id"`
+	ProjectId string `json:"project_id"`
+}
+
+type AlertSilenceDetails struct {
+	apis.StandaloneResourceDetails
+
+	// 每周的周几; 1-7, 1: Monday, 7: Sunday
+	WeekDays []int `json:"week_days"`
+	// 当前是否处于屏蔽时间窗口
+	Active bool `json:"active"`
+}
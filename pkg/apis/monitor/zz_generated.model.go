@@ -88,6 +88,9 @@ type SAlertRecord struct {
 	State     string      `json:"state"`
 	EvalData  interface{} `json:"eval_data"`
 	AlertRule interface{} `json:"alert_rule"`
+	ResType   string      `json:"res_type"`
+	// Silenced is true if the notifications were muted by alert silences
+	Silenced bool `json:"silenced"`
 }
 
 // SAlertResource is an autogenerated struct via yunion.io/x/onecloud/pkg/monitor/models.SAlertResource.
@@ -111,6 +114,33 @@ type SAlertResourceJointsBase struct {
 	AlertResourceId string `json:"alert_resource_id"`
 }
 
+// SAlertSilence is an autogenerated struct via yunion.io/x/onecloud/pkg/monitor/models.SAlertSilence.
+type SAlertSilence struct {
+	apis.SEnabledResourceBase
+	apis.SStandaloneResourceBase
+	// matchers, empty matcher matches everything
+	AlertId   string      `json:"alert_id"`
+	ResId     string      `json:"res_id"`
+	ProjectId string      `json:"project_id"`
+	Metric    string      `json:"metric"`
+	Matchers  interface{} `json:"matchers"`
+	StartTime time.Time   `json:"start_time"`
+	EndTime   time.Time   `json:"end_time"`
+	// Cycle type, empty means the silence is active all the time between
+	// StartTime and EndTime
+	CycleType string `json:"cycle_type"`
+	// 0-7 1 is Monday 0 is unlimited
+	WeekDays uint8 `json:"week_days"`
+	// start of the cycle window, 0-23
+	Hour int `json:"hour"`
+	// start of the cycle window, 0-59
+	Minute int `json:"minute"`
+	// length of the cycle window in minutes
+	Duration  int    `json:"duration"`
+	Comment   string `json:"comment"`
+	CreatedBy string `json:"created_by"`
+}
+
 // SAlertnotification is an autogenerated struct via yunion.io/x/onecloud/pkg/monitor/models.SAlertnotification.
 type SAlertnotification struct {
 	SAlertJointsBase
@@ -36,7 +36,8 @@ const (
 	RECEIVER_NOTIFICATION_RECEIVED = "received"  // Received a task about sending a notification
 	RECEIVER_NOTIFICATION_SENT     = "sending"   // Nofity module has sent notification, but result unkown
 	RECEIVER_NOTIFICATION_OK       = "sent_ok"   // Notification was sent successfully
-	RECEIVER_NOTIFICATION_FAIL     = "sent_fail" // That sent a notification is failed
+	RECEIVER_NOTIFICATION_FAIL     = "sent_fail" // That sent a notification is failed, will be retried later
+	RECEIVER_NOTIFICATION_DEAD     = "dead"      // All attempts of sending a notification are failed
 
 	VERIFICATION_SENT          = "sent"      // Verification was sent
 	VERIFICATION_SENT_FAIL     = "sent_fail" // Verification was sent failed
@@ -62,6 +62,8 @@ type ReceiveDetail struct {
 	SendBy       string    `json:"send_by"`
 	Status       string    `json:"status"`
 	FailedReason string    `json:"failed_reason"`
+	Attempts     int       `json:"attempts"`
+	NextSendAt   time.Time `json:"next_send_at"`
 }
 
 type NotificationDetails struct {
@@ -80,4 +82,25 @@ type NotificationListInput struct {
 	ContactType string
 	ReceiverId  string
 	Tag         string
+
+	// description: list notifications which have dead-lettered receivers
+	DeadLetter *bool `json:"dead_letter"`
+}
+
+type NotificationRedriveInput struct {
+	// description: id of receivers to redrive, all dead-lettered receivers if empty
+	ReceiverIds []string `json:"receiver_ids"`
+}
+
+type NotificationManagerRedriveInput struct {
+	// description: redrive dead-lettered notifications of the contact type only
+	ContactType string `json:"contact_type"`
+	// description: max count of notifications to redrive
+	// default: 100
+	Limit int `json:"limit"`
+}
+
+type NotificationManagerRedriveOutput struct {
+	// description: ids of notifications redriven
+	Notifications []string `json:"notifications"`
 }
@@ -0,0 +1,182 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/apis"
+)
+
+const (
+	TOPIC_RESOURCE_SERVER       = "server"
+	TOPIC_RESOURCE_DISK         = "disk"
+	TOPIC_RESOURCE_CLOUDACCOUNT = "cloudaccount"
+	TOPIC_RESOURCE_ALERT        = "alert"
+
+	ACTION_CREATE  = "create"
+	ACTION_DELETE  = "delete"
+	ACTION_FAILED  = "failed"
+	ACTION_TRIGGER = "trigger"
+
+	DefaultResourceCreate  = "resource create"
+	DefaultResourceDelete  = "resource delete"
+	DefaultResourceFailure = "resource failure"
+	DefaultAlertTrigger    = "alert trigger"
+
+	SUBSCRIBER_TYPE_RECEIVER = "receiver"
+	SUBSCRIBER_TYPE_ROBOT    = "robot"
+	SUBSCRIBER_TYPE_WEBHOOK  = "webhook"
+)
+
+var (
+	TopicResources = []string{
+		TOPIC_RESOURCE_SERVER,
+		TOPIC_RESOURCE_DISK,
+		TOPIC_RESOURCE_CLOUDACCOUNT,
+		TOPIC_RESOURCE_ALERT,
+	}
+	TopicActions = []string{
+		ACTION_CREATE,
+		ACTION_DELETE,
+		ACTION_FAILED,
+		ACTION_TRIGGER,
+	}
+)
+
+type TopicCreateInput struct {
+	apis.StandaloneResourceCreateInput
+	apis.EnabledBaseResourceCreateInput
+
+	// description: resource types of the events
+	// required: true
+	// example: {"server", "disk"}
+	Resources []string `json:"resources"`
+
+	// description: actions of the events
+	// required: true
+	// example: {"create", "failed"}
+	Actions []string `json:"actions"`
+}
+
+type TopicUpdateInput struct {
+	apis.StandaloneResourceBaseUpdateInput
+
+	// description: resource types of the events
+	// example: {"server", "disk"}
+	Resources []string `json:"resources"`
+
+	// description: actions of the events
+	// example: {"create", "failed"}
+	Actions []string `json:"actions"`
+}
+
+type TopicListInput struct {
+	apis.StandaloneResourceListInput
+	apis.EnabledResourceBaseListInput
+
+	// description: list topics contain the resource type
+	// example: server
+	Resource string `json:"resource"`
+
+	// description: list topics contain the action
+	// example: create
+	Action string `json:"action"`
+}
+
+type TopicDetails struct {
+	apis.StandaloneResourceDetails
+
+	STopic
+
+	// description: resource types of the events
+	Resources []string `json:"resources"`
+	// description: actions of the events
+	Actions []string `json:"actions"`
+}
+
+type SubscriberCreateInput struct {
+	apis.StandaloneResourceCreateInput
+	apis.ScopedResourceCreateInput
+	apis.EnabledBaseResourceCreateInput
+
+	// description: id or name of topic
+	// required: true
+	// example: resource create
+	TopicId string `json:"topic_id"`
+
+	// description: subscriber type
+	// enum: receiver,robot,webhook
+	// required: true
+	// example: receiver
+	Type string `json:"type"`
+
+	// description: id or name of receiver, required if type is receiver
+	// example: adfb720ccdd34c638346ea4fa7a713a8
+	Receiver string `json:"receiver"`
+
+	// description: robot contact type, required if type is robot
+	// enum: feishu-robot,dingtalk-robot,workwx-robot
+	// example: dingtalk-robot
+	Robot string `json:"robot"`
+}
+
+type SubscriberListInput struct {
+	apis.StandaloneResourceListInput
+	apis.ScopedResourceBaseListInput
+	apis.EnabledResourceBaseListInput
+
+	// description: id or name of topic
+	TopicId string `json:"topic_id"`
+	// description: subscriber type
+	Type string `json:"type"`
+	// description: id or name of receiver
+	Receiver string `json:"receiver"`
+}
+
+type SubscriberDetails struct {
+	apis.StandaloneResourceDetails
+	apis.ScopedResourceBaseInfo
+
+	SSubscriber
+
+	Topic    string `json:"topic"`
+	Receiver string `json:"receiver"`
+}
+
+type NotificationManagerEventNotifyInput struct {
+	// description: resource type of the event
+	// required: true
+	// example: server
+	ResourceType string `json:"resource_type"`
+	// description: action of the event
+	// required: true
+	// example: create
+	Action string `json:"action"`
+	// description: domain id of the resource
+	ProjectDomainId string `json:"project_domain_id"`
+	// description: project id of the resource
+	ProjectId string `json:"project_id"`
+	// description: notification priority
+	// enum: fatal,important,normal
+	Priority string `json:"priority"`
+	// description: details of the resource
+	ResourceDetails jsonutils.JSONObject `json:"resource_details"`
+}
+
+type NotificationManagerEventNotifyOutput struct {
+	// description: ids of notifications sent
+	Notifications []string `json:"notifications"`
+}
@@ -82,6 +82,20 @@ type SSubContact struct {
 	VerifiedNote      string `json:"verified_note"`
 }
 
+// SSubscriber is an autogenerated struct via yunion.io/x/onecloud/pkg/notify/models.SSubscriber.
+type SSubscriber struct {
+	apis.SStandaloneResourceBase
+	apis.SScopedResourceBase
+	apis.SEnabledResourceBase
+	TopicId string `json:"topic_id"`
+	// receiver | robot | webhook
+	Type string `json:"type"`
+	// id of receiver if type is receiver
+	ReceiverId string `json:"receiver_id"`
+	// robot contact type if type is robot
+	Robot string `json:"robot"`
+}
+
 // STemplate is an autogenerated struct via yunion.io/x/onecloud/pkg/notify/models.STemplate.
 type STemplate struct {
 	apis.SStandaloneResourceBase
@@ -93,6 +107,16 @@ type STemplate struct {
 	Example      string `json:"example"`
 }
 
+// STopic is an autogenerated struct via yunion.io/x/onecloud/pkg/notify/models.STopic.
+type STopic struct {
+	apis.SStandaloneResourceBase
+	apis.SEnabledResourceBase
+	// bitmap of api.TopicResources
+	Resources uint32 `json:"resources"`
+	// bitmap of api.TopicActions
+	Actions uint32 `json:"actions"`
+}
+
 // SVerification is an autogenerated struct via yunion.io/x/onecloud/pkg/notify/models.SVerification.
 type SVerification struct {
 	apis.SStandaloneResourceBase
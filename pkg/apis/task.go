@@ -0,0 +1,31 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apis
+
+type TaskCancelInput struct {
+	// 取消原因
+	// required: false
+	Reason string `json:"reason"`
+}
+
+type TaskSetProgressInput struct {
+	// 任务进度百分比, 0-100
+	// required: true
+	Progress float32 `json:"progress"`
+
+	// 任务进度描述
+	// required: false
+	Message string `json:"message"`
+}
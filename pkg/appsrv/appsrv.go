@@ -41,6 +41,7 @@ import (
 	"yunion.io/x/onecloud/pkg/i18n"
 	"yunion.io/x/onecloud/pkg/proxy"
 	"yunion.io/x/onecloud/pkg/util/httputils"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 type Application struct {
@@ -223,7 +224,9 @@ func (app *Application) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	} else {
 		counter = &hi.counter5XX
 	}
-	duration := float64(time.Since(start).Nanoseconds()) / 1000000
+	elapsed := time.Since(start)
+	app.observeRequest(hi, r.Method, lrw.status, elapsed)
+	duration := float64(elapsed.Nanoseconds()) / 1000000
 	counter.hit += 1
 	counter.duration += duration
 	skipLog := false
@@ -317,6 +320,15 @@ func (app *Application) defaultHandle(w http.ResponseWriter, r *http.Request, ri
 								}
 							}()
 							ctx = context.WithValue(ctx, appctx.APP_CONTEXT_KEY_TRACE, span)
+							if !hand.skipLog {
+								var tspan *tracing.SSpan
+								ctx, tspan = tracing.StartServerSpan(ctx, r.Header, fmt.Sprintf("%s %s", r.Method, appParams.Name))
+								tspan.SetAttribute("service.app", app.GetName())
+								tspan.SetAttribute("http.method", r.Method)
+								tspan.SetAttribute("http.target", r.URL.Path)
+								tspan.SetAttribute("request.id", rid)
+								defer tspan.End()
+							}
 							hand.handler(ctx, &fw, r)
 						}()
 					} // otherwise, the task has been timeout
@@ -364,6 +376,7 @@ func (app *Application) addDefaultHandlers() {
 	app.AddDefaultHandler("POST", "/ping", PingHandler, "ping")
 	app.AddDefaultHandler("GET", "/ping", PingHandler, "ping")
 	app.AddDefaultHandler("GET", "/worker_stats", WorkerStatsHandler, "worker_stats")
+	app.AddDefaultHandler("GET", MetricsPath, MetricsHandler, "metrics")
 }
 
 func timeoutHandle(h http.Handler) http.HandlerFunc {
@@ -533,6 +546,7 @@ func getContentType(r *http.Request) TContentType {
 	}()
 	for k, v := range map[string]TContentType{
 		"application/json":                  ContentTypeJson,
+		"application/scim+json":             ContentTypeJson,
 		"application/x-www-form-urlencoded": ContentTypeForm,
 	} {
 		if strings.HasPrefix(contType, k) {
@@ -0,0 +1,133 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appsrv
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	METRICS_NAMESPACE = "onecloud"
+)
+
+var (
+	httpRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: METRICS_NAMESPACE,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of http requests by handler, method and status code",
+			Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"app", "handler", "method", "status"},
+	)
+
+	workerManagerQueueDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(METRICS_NAMESPACE, "worker_manager", "queue_depth"),
+		"Number of tasks waiting in the queue of the worker manager",
+		[]string{"name"}, nil,
+	)
+	workerManagerBacklogDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(METRICS_NAMESPACE, "worker_manager", "backlog"),
+		"Queue capacity of the worker manager",
+		[]string{"name"}, nil,
+	)
+	workerManagerMaxWorkersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(METRICS_NAMESPACE, "worker_manager", "max_workers"),
+		"Maximal number of concurrent workers of the worker manager",
+		[]string{"name"}, nil,
+	)
+	workerManagerWorkersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(METRICS_NAMESPACE, "worker_manager", "workers"),
+		"Number of workers of the worker manager by state",
+		[]string{"name", "state"}, nil,
+	)
+)
+
+func init() {
+	prometheus.MustRegister(httpRequestDuration)
+	prometheus.MustRegister(&workerManagerCollector{})
+}
+
+// RegisterMetricsCollector registers the collector to the registry exposed at MetricsPath,
+// registering the same collector twice is ignored
+func RegisterMetricsCollector(c prometheus.Collector) error {
+	err := prometheus.Register(c)
+	if err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (app *Application) observeRequest(hi *SHandlerInfo, method string, status int, duration time.Duration) {
+	httpRequestDuration.WithLabelValues(app.name, hi.GetName(nil), method, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+type workerManagerCollector struct{}
+
+func (c *workerManagerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- workerManagerQueueDesc
+	ch <- workerManagerBacklogDesc
+	ch <- workerManagerMaxWorkersDesc
+	ch <- workerManagerWorkersDesc
+}
+
+func (c *workerManagerCollector) Collect(ch chan<- prometheus.Metric) {
+	workerManagerLock.Lock()
+	managers := make([]*SWorkerManager, len(workerManagers))
+	copy(managers, workerManagers)
+	workerManagerLock.Unlock()
+
+	// worker managers may share the same name, sum them up
+	states := make(map[string]*SWorkerManagerStates)
+	for i := range managers {
+		state := managers[i].getState()
+		if total, ok := states[state.Name]; ok {
+			total.QueueCnt += state.QueueCnt
+			total.Backlog += state.Backlog
+			total.MaxWorkerCnt += state.MaxWorkerCnt
+			total.ActiveWorkerCnt += state.ActiveWorkerCnt
+			total.DetachWorkerCnt += state.DetachWorkerCnt
+		} else {
+			states[state.Name] = &state
+		}
+	}
+	for name, state := range states {
+		ch <- prometheus.MustNewConstMetric(workerManagerQueueDesc, prometheus.GaugeValue, float64(state.QueueCnt), name)
+		ch <- prometheus.MustNewConstMetric(workerManagerBacklogDesc, prometheus.GaugeValue, float64(state.Backlog), name)
+		ch <- prometheus.MustNewConstMetric(workerManagerMaxWorkersDesc, prometheus.GaugeValue, float64(state.MaxWorkerCnt), name)
+		ch <- prometheus.MustNewConstMetric(workerManagerWorkersDesc, prometheus.GaugeValue, float64(state.ActiveWorkerCnt), name, "active")
+		ch <- prometheus.MustNewConstMetric(workerManagerWorkersDesc, prometheus.GaugeValue, float64(state.DetachWorkerCnt), name, "detached")
+	}
+}
+
+// MetricsPath is the path of the prometheus metrics, not /metrics which
+// is the metric API of the monitor service
+const MetricsPath = "/prometheus-metrics"
+
+var metricsHandler = promhttp.Handler()
+
+func MetricsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	metricsHandler.ServeHTTP(w, r)
+}
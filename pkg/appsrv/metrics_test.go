@@ -0,0 +1,40 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appsrv
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	app := NewApplication("metrics-test", 2, false)
+	app.AddHandler("GET", "/hello", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		Send(w, "world")
+	})
+	app.AddDefaultHandler("GET", MetricsPath, MetricsHandler, "metrics")
+
+	assert.HTTPBodyContains(t, app.ServeHTTP, "GET", "/hello", nil, "world")
+	for _, want := range []string{
+		`onecloud_http_request_duration_seconds_count{app="metrics-test",handler="get_hello",method="GET",status="200"} 1`,
+		`onecloud_worker_manager_max_workers{name="HttpGetRequestWorkerManager"}`,
+		`onecloud_worker_manager_workers{name="HttpGetRequestWorkerManager",state="active"}`,
+	} {
+		assert.HTTPBodyContains(t, app.ServeHTTP, "GET", MetricsPath, nil, want)
+	}
+}
@@ -22,7 +22,9 @@ import (
 	"yunion.io/x/log"
 
 	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
+	"yunion.io/x/onecloud/pkg/cloudcommon/ratelimit"
 	"yunion.io/x/onecloud/pkg/util/seclib2"
 )
 
@@ -74,5 +76,8 @@ func ServeForeverExtended(app *appsrv.Application, options *common_options.BaseO
 		}
 		sslfile = options.SslKeyfile
 	}
+	if options.EnableApiRateLimit {
+		ratelimit.Init(consts.GetServiceType(), options.ApiRateLimitSyncIntervalSeconds)
+	}
 	app.ListenAndServeTLSWithCleanup2(addr, certfile, sslfile, onStop, isMaster)
 }
@@ -70,6 +70,10 @@ func InitAuth(options *common_options.CommonOptions, authComplete auth.AuthCompl
 
 	auth.Init(a, options.DebugClient, true, options.SslCertfile, options.SslKeyfile) // , authComplete)
 
+	if options.TokenRevocationSyncIntervalSeconds > 0 {
+		auth.StartTokenRevocationSync(time.Duration(options.TokenRevocationSyncIntervalSeconds) * time.Second)
+	}
+
 	users := options.NotifyAdminUsers
 	groups := options.NotifyAdminGroups
 	if len(users) == 0 && len(groups) == 0 {
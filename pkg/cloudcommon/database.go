@@ -20,6 +20,8 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
 	"yunion.io/x/sqlchemy"
@@ -30,6 +32,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/etcd"
 	"yunion.io/x/onecloud/pkg/cloudcommon/informer"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
+	"yunion.io/x/onecloud/pkg/cloudcommon/ratelimit"
 )
 
 const (
@@ -87,6 +90,8 @@ func InitDB(options *common_options.DBOptions) {
 	// lm := lockman.NewNoopLockManager()
 
 	if len(options.EtcdEndpoints) != 0 {
+		ratelimit.SetEtcdOptions(&options.EtcdOptions)
+
 		log.Infof("using etcd as resource informer backend")
 		tlsCfg, err := options.GetEtcdTLSConfig()
 		if err != nil {
@@ -124,6 +129,9 @@ func AppDBInit(app *appsrv.Application) {
 	}
 
 	app.AddDefaultHandler("GET", "/db_stats", DBStatsHandler, "db_stats")
+	if err := appsrv.RegisterMetricsCollector(&dbStatsCollector{}); err != nil {
+		log.Errorf("register db metrics collector: %v", err)
+	}
 }
 
 func DBStatsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
@@ -135,3 +143,49 @@ func DBStatsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request)
 	}
 	fmt.Fprintf(w, result.String())
 }
+
+var (
+	dbMaxOpenConnsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(appsrv.METRICS_NAMESPACE, "db", "max_open_connections"),
+		"Maximum number of open connections to the database",
+		nil, nil,
+	)
+	dbConnsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(appsrv.METRICS_NAMESPACE, "db", "connections"),
+		"Number of connections to the database by state",
+		[]string{"state"}, nil,
+	)
+	dbWaitCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(appsrv.METRICS_NAMESPACE, "db", "wait_count_total"),
+		"Total number of connections waited for",
+		nil, nil,
+	)
+	dbWaitDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(appsrv.METRICS_NAMESPACE, "db", "wait_duration_seconds_total"),
+		"Total time blocked waiting for a new connection",
+		nil, nil,
+	)
+)
+
+type dbStatsCollector struct{}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dbMaxOpenConnsDesc
+	ch <- dbConnsDesc
+	ch <- dbWaitCountDesc
+	ch <- dbWaitDurationDesc
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	dbConn := sqlchemy.GetDB()
+	if dbConn == nil {
+		return
+	}
+	stats := dbConn.Stats()
+	ch <- prometheus.MustNewConstMetric(dbMaxOpenConnsDesc, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbConnsDesc, prometheus.GaugeValue, float64(stats.OpenConnections), "open")
+	ch <- prometheus.MustNewConstMetric(dbConnsDesc, prometheus.GaugeValue, float64(stats.InUse), "in_use")
+	ch <- prometheus.MustNewConstMetric(dbConnsDesc, prometheus.GaugeValue, float64(stats.Idle), "idle")
+	ch <- prometheus.MustNewConstMetric(dbWaitCountDesc, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(dbWaitDurationDesc, prometheus.CounterValue, stats.WaitDuration.Seconds())
+}
@@ -36,6 +36,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/policy"
+	"yunion.io/x/onecloud/pkg/cloudcommon/ratelimit"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
@@ -79,9 +80,9 @@ func (dispatcher *DBModelDispatcher) ContextKeywordPlurals() [][]string {
 
 func (dispatcher *DBModelDispatcher) Filter(f appsrv.FilterHandler) appsrv.FilterHandler {
 	if consts.IsRbacEnabled() {
-		return auth.AuthenticateWithDelayDecision(f, true)
+		return auth.AuthenticateWithDelayDecision(ratelimit.Limit(f), true)
 	} else {
-		return auth.Authenticate(f)
+		return auth.Authenticate(ratelimit.Limit(f))
 	}
 }
 
@@ -0,0 +1,146 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
+	"yunion.io/x/onecloud/pkg/cloudcommon/informer"
+	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+)
+
+// sInformerWebhookProvider caches the enabled informer webhooks registered in keystone
+type sInformerWebhookProvider struct {
+	lock          sync.RWMutex
+	subscriptions []informer.SWebhookSubscription
+}
+
+func (provider *sInformerWebhookProvider) GetSubscriptions() []informer.SWebhookSubscription {
+	provider.lock.RLock()
+	defer provider.lock.RUnlock()
+
+	return provider.subscriptions
+}
+
+func (provider *sInformerWebhookProvider) sync(ctx context.Context) error {
+	s := auth.GetAdminSession(ctx, consts.GetRegion(), "v1")
+	params := jsonutils.NewDict()
+	params.Set("enabled", jsonutils.JSONTrue)
+	params.Set("scope", jsonutils.NewString("system"))
+	params.Set("limit", jsonutils.NewInt(0))
+	result, err := modules.InformerWebhooks.List(s, params)
+	if err != nil {
+		return err
+	}
+	subscriptions := make([]informer.SWebhookSubscription, 0, len(result.Data))
+	for i := range result.Data {
+		sub := informer.SWebhookSubscription{}
+		sub.Id, _ = result.Data[i].GetString("id")
+		sub.Name, _ = result.Data[i].GetString("name")
+		sub.Url, _ = result.Data[i].GetString("url")
+		sub.Secret, _ = result.Data[i].GetString("secret")
+		resources, _ := result.Data[i].GetString("resources")
+		if len(resources) > 0 {
+			sub.Resources = strings.Split(resources, ",")
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+
+	provider.subscriptions = subscriptions
+	return nil
+}
+
+func (provider *sInformerWebhookProvider) run(interval time.Duration) {
+	for {
+		if !auth.IsAuthed() {
+			// wait for the service to be authed before fetching from keystone
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		err := provider.sync(context.Background())
+		if err != nil {
+			log.Errorf("sync informer webhooks fail %s", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// sInformerWebhookObject identifies the informer webhook a delivery log is written to
+type sInformerWebhookObject struct {
+	informer.SWebhookSubscription
+}
+
+func (obj *sInformerWebhookObject) GetId() string {
+	return obj.Id
+}
+
+func (obj *sInformerWebhookObject) GetName() string {
+	return obj.Name
+}
+
+func (obj *sInformerWebhookObject) Keyword() string {
+	return "informer_webhook"
+}
+
+func logInformerWebhookDelivery(delivery *informer.SWebhookDelivery) {
+	notes := jsonutils.NewDict()
+	notes.Set("event_id", jsonutils.NewString(delivery.Event.Id))
+	notes.Set("event_type", jsonutils.NewString(delivery.Event.Type))
+	notes.Set("subject", jsonutils.NewString(delivery.Event.Subject))
+	notes.Set("source", jsonutils.NewString(delivery.Event.Source))
+	notes.Set("url", jsonutils.NewString(delivery.Subscription.Url))
+	notes.Set("attempts", jsonutils.NewInt(int64(delivery.Attempts)))
+	notes.Set("status_code", jsonutils.NewInt(int64(delivery.StatusCode)))
+	if len(delivery.Error) > 0 {
+		notes.Set("error", jsonutils.NewString(delivery.Error))
+	}
+	obj := &sInformerWebhookObject{SWebhookSubscription: delivery.Subscription}
+	logclient.AddSimpleActionLog(obj, logclient.ACT_WEBHOOK_DELIVERY, notes, auth.AdminCredential(), delivery.Success)
+}
+
+func initInformerWebhook(opt *common_options.DBOptions) {
+	interval := time.Duration(opt.InformerWebhookSyncIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	provider := &sInformerWebhookProvider{}
+	go provider.run(interval)
+
+	backend := informer.NewWebhookBackend(informer.SWebhookBackendOptions{
+		Source:      fmt.Sprintf("/onecloud/%s", consts.GetServiceType()),
+		Timeout:     time.Duration(opt.InformerWebhookTimeoutSeconds) * time.Second,
+		MaxAttempts: opt.InformerWebhookMaxAttempts,
+		WorkerCount: opt.InformerWebhookWorkerCount,
+		QueueSize:   opt.InformerWebhookQueueSize,
+		// the delivery logs are written to the actions of the logger service
+		IgnoreResources: []string{"actions"},
+	}, provider, logInformerWebhookDelivery)
+	informer.AddBackend(backend)
+	log.Infof("using webhook as resource informer backend")
+}
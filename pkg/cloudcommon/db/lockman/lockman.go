@@ -17,6 +17,7 @@ package lockman
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 type ILockedClass interface {
@@ -73,6 +74,7 @@ func Init(man ILockManager) {
 }
 
 func LockClass(ctx context.Context, manager ILockedClass, projectId string) {
+	defer observeLockWait(LOCK_TYPE_CLASS, time.Now())
 	_lockman.LockClass(ctx, manager, projectId)
 }
 
@@ -81,6 +83,7 @@ func ReleaseClass(ctx context.Context, manager ILockedClass, projectId string) {
 }
 
 func LockObject(ctx context.Context, model ILockedObject) {
+	defer observeLockWait(LOCK_TYPE_OBJECT, time.Now())
 	_lockman.LockObject(ctx, model)
 }
 
@@ -89,6 +92,7 @@ func ReleaseObject(ctx context.Context, model ILockedObject) {
 }
 
 func LockRawObject(ctx context.Context, resName string, resId string) {
+	defer observeLockWait(LOCK_TYPE_RAW, time.Now())
 	_lockman.LockRawObject(ctx, resName, resId)
 }
 
@@ -97,6 +101,7 @@ func ReleaseRawObject(ctx context.Context, resName string, resId string) {
 }
 
 func LockJointObject(ctx context.Context, model ILockedObject, model2 ILockedObject) {
+	defer observeLockWait(LOCK_TYPE_JOINT, time.Now())
 	_lockman.LockJointObject(ctx, model, model2)
 }
 
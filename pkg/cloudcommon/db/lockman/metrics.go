@@ -0,0 +1,47 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lockman
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	LOCK_TYPE_CLASS  = "class"
+	LOCK_TYPE_OBJECT = "object"
+	LOCK_TYPE_RAW    = "raw"
+	LOCK_TYPE_JOINT  = "joint"
+)
+
+var lockWaitDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "onecloud",
+		Subsystem: "lockman",
+		Name:      "wait_duration_seconds",
+		Help:      "Time spent on waiting for the lock by lock type",
+		Buckets:   []float64{0.0001, 0.001, 0.01, 0.1, 0.5, 1, 5, 10, 30, 60},
+	},
+	[]string{"type"},
+)
+
+func init() {
+	prometheus.MustRegister(lockWaitDuration)
+}
+
+func observeLockWait(lockType string, start time.Time) {
+	lockWaitDuration.WithLabelValues(lockType).Observe(time.Since(start).Seconds())
+}
@@ -158,6 +158,10 @@ func CheckSync(autoSync bool) bool {
 func EnsureAppInitSyncDB(app *appsrv.Application, opt *common_options.DBOptions, modelInitDBFunc func() error) {
 	cloudcommon.InitDB(opt)
 
+	if opt.EnableInformerWebhook {
+		initInformerWebhook(opt)
+	}
+
 	if !CheckSync(opt.AutoSyncTable) {
 		log.Fatalf("database schema not in sync!")
 	}
@@ -22,6 +22,21 @@ import (
 	"yunion.io/x/onecloud/pkg/util/rbacutils"
 )
 
+// IDomainDelegatedModel is implemented by domain resources some of whose
+// actions can also be taken by another domain, e.g. accepting a vpc peering
+// connection by the domain of the peer vpc
+type IDomainDelegatedModel interface {
+	IsDelegatedDomain(domainId string, action string, extra ...string) bool
+}
+
+func isDelegatedDomain(model IModel, ownerId mcclient.IIdentityProvider, action string, extra ...string) bool {
+	delegated, ok := model.(IDomainDelegatedModel)
+	if !ok || ownerId == nil || ownerId.GetProjectDomainId() == "" {
+		return false
+	}
+	return delegated.IsDelegatedDomain(ownerId.GetProjectDomainId(), action, extra...)
+}
+
 func IsObjectRbacAllowed(model IModel, userCred mcclient.TokenCredential, action string, extra ...string) error {
 	return isObjectRbacAllowed(model, userCred, action, extra...)
 }
@@ -45,6 +60,8 @@ func isObjectRbacAllowed(model IModel, userCred mcclient.TokenCredential, action
 			requireScope = rbacutils.ScopeUser
 		} else if ownerId != nil && objOwnerId != nil && (ownerId.GetProjectDomainId() == objOwnerId.GetProjectDomainId() || objOwnerId.GetProjectDomainId() == "" || (model.IsSharable(ownerId) && action == policy.PolicyActionGet)) {
 			requireScope = rbacutils.ScopeDomain
+		} else if isDelegatedDomain(model, ownerId, action, extra...) {
+			requireScope = rbacutils.ScopeDomain
 		} else {
 			requireScope = rbacutils.ScopeSystem
 		}
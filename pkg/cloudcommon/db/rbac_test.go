@@ -0,0 +1,126 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/policy"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
+)
+
+type sDelegatedTestModel struct {
+	SInfrasResourceBase
+
+	delegatedDomainId string
+}
+
+func (model *sDelegatedTestModel) IsDelegatedDomain(domainId string, action string, extra ...string) bool {
+	if action != policy.PolicyActionPerform || len(extra) == 0 || extra[0] != "accept" {
+		return false
+	}
+	return model.delegatedDomainId == domainId
+}
+
+func TestIsObjectRbacAllowedDelegatedDomain(t *testing.T) {
+	domainAdminPolicy, err := rbacutils.DecodePolicy(jsonutils.Marshal(map[string]string{"*": "allow"}))
+	if err != nil {
+		t.Fatalf("DecodePolicy: %v", err)
+	}
+	policy.DefaultPolicyFetcher = func(ctx context.Context, token mcclient.TokenCredential) (*mcclient.SFetchMatchPoliciesOutput, error) {
+		return &mcclient.SFetchMatchPoliciesOutput{
+			Policies: rbacutils.TPolicyGroup{
+				rbacutils.ScopeDomain: rbacutils.TPolicySet{domainAdminPolicy},
+			},
+		}, nil
+	}
+	policy.EnableGlobalRbac(time.Minute, false)
+
+	manager := &SInfrasResourceBaseManager{}
+	*manager = NewInfrasResourceBaseManager(sDelegatedTestModel{}, "delegated_test_tbl", "delegated_test", "delegated_tests")
+	manager.SetVirtualObject(manager)
+	model := &sDelegatedTestModel{delegatedDomainId: "domain-b"}
+	model.SetModelManager(manager, model)
+	model.DomainId = "domain-a"
+	// private to domain-a, so that IsSharable() needs no db query
+	model.PublicScope = string(rbacutils.ScopeDomain)
+
+	domainAdmin := func(domainId string) mcclient.TokenCredential {
+		return &mcclient.SSimpleToken{
+			Domain:          domainId,
+			DomainId:        domainId,
+			User:            "admin-" + domainId,
+			UserId:          "admin-" + domainId,
+			Project:         "project-" + domainId,
+			ProjectId:       "project-" + domainId,
+			ProjectDomain:   domainId,
+			ProjectDomainId: domainId,
+			Roles:           "domainadmin",
+			RoleIds:         "domainadmin",
+		}
+	}
+	cases := []struct {
+		name     string
+		domainId string
+		action   string
+		extra    []string
+		allowed  bool
+	}{
+		{
+			name:     "owner domain",
+			domainId: "domain-a",
+			action:   policy.PolicyActionPerform,
+			extra:    []string{"syncstatus"},
+			allowed:  true,
+		},
+		{
+			name:     "delegated domain delegated action",
+			domainId: "domain-b",
+			action:   policy.PolicyActionPerform,
+			extra:    []string{"accept"},
+			allowed:  true,
+		},
+		{
+			name:     "delegated domain other action",
+			domainId: "domain-b",
+			action:   policy.PolicyActionPerform,
+			extra:    []string{"syncstatus"},
+		},
+		{
+			name:     "delegated domain delete",
+			domainId: "domain-b",
+			action:   policy.PolicyActionDelete,
+		},
+		{
+			name:     "other domain",
+			domainId: "domain-c",
+			action:   policy.PolicyActionPerform,
+			extra:    []string{"accept"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := isObjectRbacAllowed(model, domainAdmin(c.domainId), c.action, c.extra...)
+			if allowed := err == nil; allowed != c.allowed {
+				t.Errorf("isObjectRbacAllowed() = %v, want allowed %v", err, c.allowed)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ package taskman
 import (
 	"context"
 	"reflect"
+	"time"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
@@ -44,27 +45,66 @@ var ITaskType reflect.Type
 var IBatchTaskType reflect.Type
 
 var taskTable map[string]reflect.Type
+var taskPolicyTable map[string]STaskPolicy
 
 func init() {
 	ITaskType = reflect.TypeOf((*ISingleTask)(nil)).Elem()
 	IBatchTaskType = reflect.TypeOf((*IBatchTask)(nil)).Elem()
 
 	taskTable = make(map[string]reflect.Type)
+	taskPolicyTable = make(map[string]STaskPolicy)
 }
 
-func RegisterTaskAndWorker(task interface{}, workerMan *appsrv.SWorkerManager) {
+// STaskPolicy describes how an interrupted or timed out stage of a task class
+// is recovered. The zero value never retries: an orphaned stage is failed
+// through its <Stage>Failed handler.
+type STaskPolicy struct {
+	// MaxAttempts is the max number of times a stage may be dispatched,
+	// including the first run, before it is failed
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled on each further retry
+	Backoff time.Duration
+	// StageTimeout is the max time a stage may stay current before it is
+	// considered stuck, zero means never time out
+	StageTimeout time.Duration
+}
+
+func (policy STaskPolicy) retryDelay(attempts int) time.Duration {
+	delay := policy.Backoff
+	for i := 1; i < attempts && delay < MAX_RETRY_BACKOFF; i++ {
+		delay *= 2
+	}
+	if delay > MAX_RETRY_BACKOFF {
+		delay = MAX_RETRY_BACKOFF
+	}
+	return delay
+}
+
+func (policy STaskPolicy) stageDeadline(now time.Time) time.Time {
+	if policy.StageTimeout <= 0 {
+		return time.Time{}
+	}
+	return now.Add(policy.StageTimeout)
+}
+
+func RegisterTaskWithPolicy(task interface{}, workerMan *appsrv.SWorkerManager, policy STaskPolicy) {
 	taskName := gotypes.GetInstanceTypeName(task)
 	if _, ok := taskTable[taskName]; ok {
 		log.Fatalf("Task %s already registered!", taskName)
 	}
 	taskType := reflect.Indirect(reflect.ValueOf(task)).Type()
 	taskTable[taskName] = taskType
+	taskPolicyTable[taskName] = policy
 	// log.Infof("Task %s registerd", taskName)
 	if workerMan != nil {
 		taskWorkerTable[taskName] = workerMan
 	}
 }
 
+func RegisterTaskAndWorker(task interface{}, workerMan *appsrv.SWorkerManager) {
+	RegisterTaskWithPolicy(task, workerMan, STaskPolicy{})
+}
+
 func RegisterTask(task interface{}) {
 	RegisterTaskAndWorker(task, nil)
 }
@@ -73,3 +113,7 @@ func isTaskExist(taskName string) bool {
 	_, ok := taskTable[taskName]
 	return ok
 }
+
+func getTaskPolicy(taskName string) STaskPolicy {
+	return taskPolicyTable[taskName]
+}
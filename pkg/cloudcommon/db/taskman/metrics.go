@@ -0,0 +1,71 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/util/timeutils"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+)
+
+var taskCountDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(appsrv.METRICS_NAMESPACE, "taskman", "tasks"),
+	"Number of incomplete tasks in the recovery window by task class and stage",
+	[]string{"class", "stage"}, nil,
+)
+
+func init() {
+	appsrv.RegisterMetricsCollector(&taskCollector{})
+}
+
+type taskCollector struct{}
+
+func (c *taskCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- taskCountDesc
+}
+
+func (c *taskCollector) Collect(ch chan<- prometheus.Metric) {
+	// services without database don't run tasks
+	if sqlchemy.GetDB() == nil {
+		return
+	}
+	q := TaskManager.Query("task_name", "stage")
+	q = q.AppendField(sqlchemy.COUNT("count"))
+	q = q.NotIn("stage", []string{TASK_STAGE_COMPLETE, TASK_STAGE_FAILED})
+	q = q.GE("created_at", timeutils.UtcNow().Add(-TASK_RECOVERY_WINDOW))
+	q = q.GroupBy(q.Field("task_name"), q.Field("stage"))
+	rows, err := q.Rows()
+	if err != nil {
+		log.Errorf("collect task metrics query fail %s", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			taskName string
+			stage    string
+			count    int
+		)
+		if err := rows.Scan(&taskName, &stage, &count); err != nil {
+			log.Errorf("collect task metrics scan fail %s", err)
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(taskCountDesc, prometheus.GaugeValue, float64(count), taskName, stage)
+	}
+}
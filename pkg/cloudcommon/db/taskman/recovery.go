@@ -0,0 +1,164 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"context"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/timeutils"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+const (
+	// tasks created earlier than this are never recovered
+	TASK_RECOVERY_WINDOW = 7 * 24 * time.Hour
+)
+
+var taskmanStartAt time.Time
+
+func init() {
+	taskmanStartAt = timeutils.UtcNow()
+}
+
+// RecoverTasks is a cron job which picks up the orphaned incomplete tasks:
+//   - on start, stages that were running when the previous process exited
+//   - stages that stay current longer than the StageTimeout of their task policy
+//
+// An orphaned stage is dispatched again if its task policy allows more
+// attempts, otherwise it is failed through the <Stage>Failed handler so that
+// the task objects leave their *_ing status.
+func (manager *STaskManager) RecoverTasks(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	now := timeutils.UtcNow()
+	q := manager.Query("id")
+	q = q.NotIn("stage", []string{TASK_STAGE_COMPLETE, TASK_STAGE_FAILED})
+	q = q.GE("created_at", now.Add(-TASK_RECOVERY_WINDOW))
+	conds := []sqlchemy.ICondition{
+		sqlchemy.AND(
+			sqlchemy.IsNotNull(q.Field("stage_deadline")),
+			sqlchemy.LT(q.Field("stage_deadline"), now),
+		),
+	}
+	if isStart {
+		conds = append(conds, sqlchemy.AND(
+			sqlchemy.IsTrue(q.Field("stage_running")),
+			sqlchemy.LT(q.Field("updated_at"), taskmanStartAt),
+		))
+	}
+	q = q.Filter(sqlchemy.OR(conds...))
+	rows, err := q.Rows()
+	if err != nil {
+		log.Errorf("RecoverTasks query fail %s", err)
+		return
+	}
+	taskIds := make([]string, 0)
+	for rows.Next() {
+		var taskId string
+		err = rows.Scan(&taskId)
+		if err != nil {
+			log.Errorf("RecoverTasks scan fail %s", err)
+			break
+		}
+		taskIds = append(taskIds, taskId)
+	}
+	rows.Close()
+
+	for _, taskId := range taskIds {
+		manager.recoverTask(ctx, taskId, isStart)
+	}
+}
+
+func (manager *STaskManager) recoverTask(ctx context.Context, taskId string, isStart bool) {
+	lockman.LockRawObject(ctx, "tasks", taskId)
+	defer lockman.ReleaseRawObject(ctx, "tasks", taskId)
+
+	task := manager.fetchTask(taskId)
+	if task == nil || !task.isStageOpen() {
+		return
+	}
+	now := timeutils.UtcNow()
+	policy := getTaskPolicy(task.TaskName)
+	if isLocalTaskRunning(taskId) {
+		// the stage is still alive in this process, push its deadline
+		// forward instead of dispatching it once more
+		_, err := db.Update(task, func() error {
+			task.StageDeadline = policy.stageDeadline(now)
+			return nil
+		})
+		if err != nil {
+			log.Errorf("task %s(%s) update fail %s", task.TaskName, task.Id, err)
+		}
+		return
+	}
+	reason := recoverReason(task, now, isStart)
+	if len(reason) == 0 {
+		// changed since it was queried
+		return
+	}
+
+	if !isTaskExist(task.TaskName) {
+		log.Errorf("task %s(%s) stage %s %s, task not registered", task.TaskName, task.Id, task.Stage, reason)
+		task.SetStageFailed(ctx, jsonutils.NewString("task "+reason))
+		return
+	}
+
+	if task.StageAttempts < policy.MaxAttempts {
+		delay := policy.retryDelay(task.StageAttempts)
+		_, err := db.Update(task, func() error {
+			task.StageRunning = false
+			task.StageDeadline = policy.stageDeadline(now.Add(delay))
+			return nil
+		})
+		if err != nil {
+			log.Errorf("task %s(%s) update fail %s", task.TaskName, task.Id, err)
+			return
+		}
+		log.Warningf("task %s(%s) stage %s %s after %d attempts, retry in %s", task.TaskName, task.Id, task.Stage, reason, task.StageAttempts, delay)
+		time.AfterFunc(delay, func() {
+			runTask(taskId, nil)
+		})
+	} else {
+		_, err := db.Update(task, func() error {
+			task.StageRunning = false
+			task.StageDeadline = time.Time{}
+			return nil
+		})
+		if err != nil {
+			log.Errorf("task %s(%s) update fail %s", task.TaskName, task.Id, err)
+			return
+		}
+		log.Errorf("task %s(%s) stage %s %s after %d attempts, fail it", task.TaskName, task.Id, task.Stage, reason, task.StageAttempts)
+		runTask(taskId, Error2TaskData(errors.Errorf("stage %s %s after %d attempts", task.Stage, reason, task.StageAttempts)))
+	}
+}
+
+// recoverReason tells why the stage of task should be recovered, empty if it
+// should be left alone
+func recoverReason(task *STask, now time.Time, isStart bool) string {
+	if !task.StageDeadline.IsZero() && task.StageDeadline.Before(now) {
+		return "timeout"
+	}
+	if isStart && task.StageRunning && task.UpdatedAt.Before(taskmanStartAt) {
+		return "interrupted"
+	}
+	return ""
+}
@@ -0,0 +1,169 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSTaskPolicyRetryDelay(t *testing.T) {
+	policy := STaskPolicy{Backoff: 10 * time.Second}
+	tests := []struct {
+		name     string
+		attempts int
+		want     time.Duration
+	}{
+		{
+			name:     "first retry waits backoff",
+			attempts: 1,
+			want:     10 * time.Second,
+		},
+		{
+			name:     "zero attempts waits backoff",
+			attempts: 0,
+			want:     10 * time.Second,
+		},
+		{
+			name:     "backoff doubles on each retry",
+			attempts: 3,
+			want:     40 * time.Second,
+		},
+		{
+			name:     "backoff is capped",
+			attempts: 100,
+			want:     MAX_RETRY_BACKOFF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.retryDelay(tt.attempts); got != tt.want {
+				t.Errorf("retryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSTaskPolicyStageDeadline(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		policy STaskPolicy
+		want   time.Time
+	}{
+		{
+			name:   "no timeout never expires",
+			policy: STaskPolicy{},
+			want:   time.Time{},
+		},
+		{
+			name:   "deadline after timeout",
+			policy: STaskPolicy{StageTimeout: time.Minute},
+			want:   now.Add(time.Minute),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.stageDeadline(now); !got.Equal(tt.want) {
+				t.Errorf("stageDeadline() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecoverReason(t *testing.T) {
+	now := taskmanStartAt.Add(time.Hour)
+	beforeStart := taskmanStartAt.Add(-time.Minute)
+	afterStart := taskmanStartAt.Add(time.Minute)
+	tests := []struct {
+		name      string
+		task      STask
+		updatedAt time.Time
+		isStart   bool
+		want      string
+	}{
+		{
+			name: "stage past deadline times out",
+			task: STask{StageDeadline: now.Add(-time.Second)},
+			want: "timeout",
+		},
+		{
+			name: "stage before deadline is left alone",
+			task: STask{StageDeadline: now.Add(time.Second)},
+			want: "",
+		},
+		{
+			name: "stage without deadline is left alone",
+			task: STask{},
+			want: "",
+		},
+		{
+			name:      "stage running in previous process is interrupted on start",
+			task:      STask{StageRunning: true},
+			updatedAt: beforeStart,
+			isStart:   true,
+			want:      "interrupted",
+		},
+		{
+			name:      "stage running in previous process is left alone by cron",
+			task:      STask{StageRunning: true},
+			updatedAt: beforeStart,
+			want:      "",
+		},
+		{
+			name:      "stage running in this process is not interrupted",
+			task:      STask{StageRunning: true},
+			updatedAt: afterStart,
+			isStart:   true,
+			want:      "",
+		},
+		{
+			name:      "waiting stage is not interrupted",
+			task:      STask{},
+			updatedAt: beforeStart,
+			isStart:   true,
+			want:      "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := tt.task
+			task.UpdatedAt = tt.updatedAt
+			if got := recoverReason(&task, now, tt.isStart); got != tt.want {
+				t.Errorf("recoverReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalTasks(t *testing.T) {
+	taskId := "test-local-task"
+	if isLocalTaskRunning(taskId) {
+		t.Fatalf("task should not be running before dispatched")
+	}
+	addLocalTask(taskId, 1)
+	addLocalTask(taskId, 1)
+	addLocalTask(taskId, -1)
+	if !isLocalTaskRunning(taskId) {
+		t.Errorf("task should be running while a stage run is pending")
+	}
+	addLocalTask(taskId, -1)
+	if isLocalTaskRunning(taskId) {
+		t.Errorf("task should not be running after all stage runs finish")
+	}
+	if _, ok := localTasks[taskId]; ok {
+		t.Errorf("finished task should be removed from local tasks")
+	}
+}
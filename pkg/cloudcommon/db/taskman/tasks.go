@@ -35,6 +35,7 @@ import (
 	"yunion.io/x/pkg/utils"
 	"yunion.io/x/sqlchemy"
 
+	"yunion.io/x/onecloud/pkg/apis"
 	"yunion.io/x/onecloud/pkg/appctx"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
@@ -44,6 +45,7 @@ import (
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
 	"yunion.io/x/onecloud/pkg/util/httputils"
 	"yunion.io/x/onecloud/pkg/util/rbacutils"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 const (
@@ -55,8 +57,12 @@ const (
 	TASK_STAGE_FAILED   = "failed"
 	TASK_STAGE_COMPLETE = "complete"
 
+	TASK_STATUS_CANCEL = "CANCEL"
+
 	MAX_REMOTE_NOTIFY_TRIES = 5
 
+	MAX_RETRY_BACKOFF = 1 * time.Hour
+
 	MULTI_OBJECTS_ID = "[--MULTI_OBJECTS--]"
 
 	TASK_INIT_STAGE = "on_init"
@@ -92,6 +98,20 @@ type STask struct {
 
 	Stage string `width:"64" charset:"ascii" nullable:"false" default:"on_init" list:"user"` // Column(VARCHAR(64, charset='ascii'), nullable=False, default='on_init')
 
+	// number of times the current stage has been dispatched
+	StageAttempts int `nullable:"false" default:"0" list:"user"`
+	// the current stage is considered stuck after the deadline
+	StageDeadline time.Time `nullable:"true" list:"user"`
+	// the current stage is being executed by a task worker
+	StageRunning bool `nullable:"false" default:"false" list:"user"`
+
+	// the task is canceled by user
+	Canceled bool `nullable:"false" default:"false" list:"user"`
+	// progress percentage of the task, 0-100
+	Progress float32 `nullable:"false" default:"0" list:"user"`
+	// description of the progress
+	ProgressMessage string `width:"256" charset:"utf8" nullable:"true" list:"user"`
+
 	taskObject  db.IStandaloneModel   `ignore:"true"`
 	taskObjects []db.IStandaloneModel `ignore:"true"`
 }
@@ -128,6 +148,48 @@ func (manager *STaskManager) PerformAction(ctx context.Context, userCred mcclien
 	return resp, nil
 }
 
+func (self *STask) AllowPerformCancel(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsProjectAllowPerform(userCred, self, "cancel")
+}
+
+// 取消任务, 同时取消当前阶段的子任务
+func (self *STask) PerformCancel(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.TaskCancelInput) (jsonutils.JSONObject, error) {
+	if !self.isStageOpen() {
+		return nil, httperrors.NewInvalidStatusError("task %s is %s", self.Id, self.Stage)
+	}
+	if self.Canceled {
+		return nil, httperrors.NewInvalidStatusError("task %s is being canceled", self.Id)
+	}
+	reason := input.Reason
+	if len(reason) == 0 {
+		reason = fmt.Sprintf("canceled by %s", userCred.GetUserName())
+	}
+	err := self.cancel(ctx, reason)
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	return nil, nil
+}
+
+func (self *STask) AllowPerformSetProgress(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowPerform(userCred, self, "set-progress")
+}
+
+// 更新任务进度
+func (self *STask) PerformSetProgress(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.TaskSetProgressInput) (jsonutils.JSONObject, error) {
+	if input.Progress < 0 || input.Progress > 100 {
+		return nil, httperrors.NewOutOfRangeError("progress should be in range of 0-100")
+	}
+	if !self.isStageOpen() {
+		return nil, httperrors.NewInvalidStatusError("task %s is %s", self.Id, self.Stage)
+	}
+	err := self.SetProgress(input.Progress, input.Message)
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	return nil, nil
+}
+
 func (manager *STask) PreCheckPerformAction(
 	ctx context.Context, userCred mcclient.TokenCredential,
 	action string, query jsonutils.JSONObject, data jsonutils.JSONObject,
@@ -272,6 +334,8 @@ func (manager *STaskManager) NewTask(
 		UserCred: userCred,
 		Params:   data,
 		Stage:    TASK_INIT_STAGE,
+
+		StageDeadline: getTaskPolicy(taskName).stageDeadline(timeutils.UtcNow()),
 	}
 	task.SetModelManager(manager, task)
 	err := manager.TableSpec().Insert(ctx, task)
@@ -322,6 +386,8 @@ func (manager *STaskManager) NewParallelTask(
 		UserCred: userCred,
 		Params:   data,
 		Stage:    TASK_INIT_STAGE,
+
+		StageDeadline: getTaskPolicy(taskName).stageDeadline(timeutils.UtcNow()),
 	}
 	task.SetModelManager(manager, task)
 	err := manager.TableSpec().Insert(ctx, task)
@@ -403,13 +469,14 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 
 	taskFailed := false
 
+	taskCancel := false
+
 	var data jsonutils.JSONObject
 	if odata != nil {
 		switch dictdata := odata.(type) {
 		case *jsonutils.JSONDict:
-			taskStatus, _ := odata.GetString("__status__")
-			if len(taskStatus) > 0 && taskStatus != "OK" {
-				taskFailed = true
+			taskFailed, taskCancel = taskDataStatus(odata)
+			if taskFailed {
 				dictdata.Set("__stage__", jsonutils.NewString(task.Stage))
 				if !dictdata.Contains("__reason__") {
 					reasonJson := dictdata.CopyExcludes("__status__", "__stage__")
@@ -431,6 +498,11 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 		data = jsonutils.NewDict()
 	}
 
+	if taskCancel && !task.isStageOpen() {
+		log.Infof("Task %s(%s) is %s, skip cancel", task.TaskName, task.Id, task.Stage)
+		return
+	}
+
 	var stageName string
 	if taskFailed {
 		stageName = fmt.Sprintf("%sFailed", task.Stage)
@@ -523,6 +595,11 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 
 	params[2] = reflect.ValueOf(data)
 
+	err := task.markStageRunning()
+	if err != nil {
+		log.Errorf("Task %s(%s) mark stage %s running fail %s", task.TaskName, task.Id, task.Stage, err)
+	}
+
 	filled := reflectutils.FillEmbededStructValue(taskValue.Elem(), reflect.Indirect(reflect.ValueOf(task)))
 	if !filled {
 		log.Errorf("Cannot locate baseTask embedded struct, give up...")
@@ -544,8 +621,28 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 		}
 	}()
 
+	if taskCancel {
+		// OnCancel is optional, it cleans up the works out of the region, e.g. the block jobs on host,
+		// then the failed handler of current stage is called to revert the status of the task objects
+		cancelFuncValue := taskValue.MethodByName("OnCancel")
+		if cancelFuncValue.IsValid() && !cancelFuncValue.IsNil() {
+			log.Debugf("Call %s OnCancel %#v", task.TaskName, params)
+			cancelFuncValue.Call(params)
+		}
+	}
+
 	log.Debugf("Call %s %s %#v", task.TaskName, stageName, params)
-	funcValue.Call(params)
+	func() {
+		ctx, span := tracing.StartSpan(ctx, fmt.Sprintf("%s.%s", task.TaskName, stageName), tracing.SPAN_KIND_INTERNAL)
+		span.SetAttribute("task.id", task.Id)
+		span.SetAttribute("task.object", fmt.Sprintf("%s/%s", task.ObjName, task.ObjId))
+		if taskFailed {
+			span.SetError(data.String())
+		}
+		defer span.End()
+		params[0] = reflect.ValueOf(ctx)
+		funcValue.Call(params)
+	}()
 
 	// call save request context
 	saveRequestContextFuncValue := taskValue.MethodByName("SaveRequestContext")
@@ -587,11 +684,23 @@ func (self *STask) GetRequestContext() appctx.AppContextData {
 	return ctxData
 }
 
+func (self *STask) markStageRunning() error {
+	_, err := db.Update(self, func() error {
+		self.StageAttempts += 1
+		self.StageRunning = true
+		return nil
+	})
+	return err
+}
+
+// SaveRequestContext is called at the end of every stage run, so it also
+// clears the running mark of the current stage
 func (self *STask) SaveRequestContext(data *appctx.AppContextData) {
 	_, err := db.Update(self, func() error {
 		params := self.Params.CopyExcludes(REQUEST_CONTEXT_KEY)
 		params.Add(jsonutils.Marshal(data), REQUEST_CONTEXT_KEY)
 		self.Params = params
+		self.StageRunning = false
 		return nil
 	})
 	if err != nil {
@@ -622,6 +731,15 @@ func (self *STask) SetStage(stageName string, data *jsonutils.JSONDict) error {
 			stageData.Add(jsonutils.NewTimeString(time.Now()), "complete_at")
 			stageList.Add(stageData)
 			self.Stage = stageName
+			self.StageAttempts = 0
+			if self.Stage == TASK_STAGE_COMPLETE {
+				self.Progress = 100
+			}
+			if self.isStageOpen() {
+				self.StageDeadline = getTaskPolicy(self.TaskName).stageDeadline(timeutils.UtcNow())
+			} else {
+				self.StageDeadline = time.Time{}
+			}
 		}
 		self.Params = params
 		return nil
@@ -632,6 +750,65 @@ func (self *STask) SetStage(stageName string, data *jsonutils.JSONDict) error {
 	return err
 }
 
+func (self *STask) SetProgress(progress float32, message string) error {
+	_, err := db.Update(self, func() error {
+		self.Progress = progress
+		self.ProgressMessage = message
+		return nil
+	})
+	if err != nil {
+		log.Errorf("set_progress fail %s", err)
+	}
+	return err
+}
+
+// taskDataStatus tells whether the data passed to a stage reports failure,
+// and whether the failure is caused by cancel
+func taskDataStatus(data jsonutils.JSONObject) (bool, bool) {
+	taskStatus, _ := data.GetString("__status__")
+	if len(taskStatus) == 0 || taskStatus == "OK" {
+		return false, false
+	}
+	return true, taskStatus == TASK_STATUS_CANCEL
+}
+
+func cancelTaskData(reason string) jsonutils.JSONObject {
+	data := jsonutils.NewDict()
+	data.Add(jsonutils.NewString(TASK_STATUS_CANCEL), "__status__")
+	data.Add(jsonutils.NewString(reason), "__reason__")
+	return data
+}
+
+// cancel marks the task canceled and fails its current stage, the open
+// subtasks waited by current stage are canceled first
+func (self *STask) cancel(ctx context.Context, reason string) error {
+	_, err := db.Update(self, func() error {
+		self.Canceled = true
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "db.Update")
+	}
+	subtasks := SubTaskManager.GetInitSubtasks(self.Id, self.Stage)
+	for i := range subtasks {
+		subtask := TaskManager.fetchTask(subtasks[i].SubtaskId)
+		if subtask == nil || !subtask.isStageOpen() || subtask.Canceled {
+			continue
+		}
+		err := subtask.cancel(ctx, reason)
+		if err != nil {
+			log.Errorf("cancel subtask %s(%s) fail %s", subtask.TaskName, subtask.Id, err)
+		}
+	}
+	log.Infof("XXX TASK %s(%s) canceled on stage %s: %s", self.TaskName, self.Id, self.Stage, reason)
+	runTask(self.Id, cancelTaskData(reason))
+	return nil
+}
+
+func (self *STask) isStageOpen() bool {
+	return self.Stage != TASK_STAGE_COMPLETE && self.Stage != TASK_STAGE_FAILED
+}
+
 func (self *STask) GetObjectIdStr() string {
 	if self.ObjId == MULTI_OBJECTS_ID {
 		return strings.Join(TaskObjectManager.GetObjectIds(self), ",")
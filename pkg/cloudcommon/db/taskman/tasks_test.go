@@ -0,0 +1,85 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"testing"
+
+	"yunion.io/x/jsonutils"
+)
+
+func TestTaskDataStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         jsonutils.JSONObject
+		wantFailed   bool
+		wantCanceled bool
+	}{
+		{
+			name: "empty data succeeds",
+			data: jsonutils.NewDict(),
+		},
+		{
+			name: "OK status succeeds",
+			data: jsonutils.Marshal(map[string]string{"__status__": "OK"}),
+		},
+		{
+			name:       "error status fails",
+			data:       jsonutils.Marshal(map[string]string{"__status__": "ERROR", "__reason__": "boom"}),
+			wantFailed: true,
+		},
+		{
+			name:         "cancel data fails by cancel",
+			data:         cancelTaskData("canceled by admin"),
+			wantFailed:   true,
+			wantCanceled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failed, canceled := taskDataStatus(tt.data)
+			if failed != tt.wantFailed || canceled != tt.wantCanceled {
+				t.Errorf("taskDataStatus() = %v, %v, want %v, %v", failed, canceled, tt.wantFailed, tt.wantCanceled)
+			}
+		})
+	}
+}
+
+func TestCancelTaskDataReason(t *testing.T) {
+	reason, _ := cancelTaskData("canceled by admin").GetString("__reason__")
+	if reason != "canceled by admin" {
+		t.Errorf("cancel reason = %q, want %q", reason, "canceled by admin")
+	}
+}
+
+func TestSTaskIsStageOpen(t *testing.T) {
+	tests := []struct {
+		stage string
+		want  bool
+	}{
+		{stage: "on_init", want: true},
+		{stage: "OnSyncComplete", want: true},
+		{stage: TASK_STAGE_COMPLETE, want: false},
+		{stage: TASK_STAGE_FAILED, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stage, func(t *testing.T) {
+			task := STask{Stage: tt.stage}
+			if got := task.isStageOpen(); got != tt.want {
+				t.Errorf("isStageOpen() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
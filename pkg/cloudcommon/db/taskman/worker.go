@@ -16,6 +16,7 @@ package taskman
 
 import (
 	"context"
+	"sync"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
@@ -27,6 +28,28 @@ import (
 var taskWorkMan *appsrv.SWorkerManager
 var taskWorkerTable map[string]*appsrv.SWorkerManager
 
+// localTasks counts the stage runs of each task queued or executing in this
+// process, so that recovery does not dispatch a stage which is still alive
+var (
+	localTasks     = make(map[string]int)
+	localTasksLock sync.Mutex
+)
+
+func addLocalTask(taskId string, delta int) {
+	localTasksLock.Lock()
+	defer localTasksLock.Unlock()
+	localTasks[taskId] += delta
+	if localTasks[taskId] <= 0 {
+		delete(localTasks, taskId)
+	}
+}
+
+func isLocalTaskRunning(taskId string) bool {
+	localTasksLock.Lock()
+	defer localTasksLock.Unlock()
+	return localTasks[taskId] > 0
+}
+
 func init() {
 	taskWorkMan = appsrv.NewWorkerManager("TaskWorkerManager", 4, 1024, true)
 	taskWorkerTable = make(map[string]*appsrv.SWorkerManager)
@@ -42,7 +65,9 @@ func runTask(taskId string, data jsonutils.JSONObject) {
 	if workerMan, ok := taskWorkerTable[taskName]; ok {
 		worker = workerMan
 	}
+	addLocalTask(taskId, 1)
 	worker.Run(func() {
+		defer addLocalTask(taskId, -1)
 		TaskManager.execTask(taskId, data)
 	}, nil, func(err error) {
 		panicutils.SendPanicMessage(context.TODO(), err)
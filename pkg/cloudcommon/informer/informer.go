@@ -16,6 +16,7 @@ package informer
 
 import (
 	"context"
+	"sync"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
@@ -28,6 +29,9 @@ const (
 
 var (
 	defaultBackend IInformerBackend
+
+	extraBackends     []IInformerBackend
+	extraBackendsLock sync.RWMutex
 )
 
 type IInformerBackend interface {
@@ -37,6 +41,12 @@ type IInformerBackend interface {
 	Delete(ctx context.Context, obj *ModelObject) error
 }
 
+// IResourceFilter is implemented by backends which decide the watched
+// resources by themselves instead of the clients registered in etcd
+type IResourceFilter interface {
+	IsResourceWatched(keywordPlural string) bool
+}
+
 func Init(be IInformerBackend) {
 	if defaultBackend != nil {
 		log.Fatalf("informer backend %q already init", be.GetType())
@@ -48,6 +58,26 @@ func Set(be IInformerBackend) {
 	defaultBackend = be
 }
 
+// AddBackend registers a backend running alongside the default one,
+// events are published to all of the backends
+func AddBackend(be IInformerBackend) {
+	extraBackendsLock.Lock()
+	defer extraBackendsLock.Unlock()
+
+	extraBackends = append(extraBackends, be)
+}
+
+func getBackends() []IInformerBackend {
+	extraBackendsLock.RLock()
+	defer extraBackendsLock.RUnlock()
+
+	ret := make([]IInformerBackend, 0, len(extraBackends)+1)
+	if be := GetDefaultBackend(); be != nil {
+		ret = append(ret, be)
+	}
+	return append(ret, extraBackends...)
+}
+
 func GetDefaultBackend() IInformerBackend {
 	if defaultBackend == nil {
 		log.V(10).Warningf("default informer backend is not init")
@@ -56,7 +86,13 @@ func GetDefaultBackend() IInformerBackend {
 }
 
 func IsInit() bool {
-	return defaultBackend != nil
+	if defaultBackend != nil {
+		return true
+	}
+	extraBackendsLock.RLock()
+	defer extraBackendsLock.RUnlock()
+
+	return len(extraBackends) > 0
 }
 
 type ModelObject struct {
@@ -84,33 +120,44 @@ func NewJointModel(obj interface{}, keywordPlural, masterId, slaveId string) *Mo
 	return model
 }
 
-func isResourceWatched(keywordPlural string) bool {
+func isResourceWatched(be IInformerBackend, keywordPlural string) bool {
+	if filter, ok := be.(IResourceFilter); ok {
+		return filter.IsResourceWatched(keywordPlural)
+	}
 	return GetWatchResources().Has(keywordPlural)
 }
 
-func Create(ctx context.Context, obj *ModelObject) error {
-	if !isResourceWatched(obj.KeywordPlural) {
-		return nil
+func inform(ctx context.Context, keywordPlural string, f func(ctx context.Context, be IInformerBackend) error) error {
+	backends := getBackends()
+	if len(backends) == 0 {
+		if !GetWatchResources().Has(keywordPlural) {
+			return nil
+		}
+		return ErrBackendNotInit
+	}
+	for i := range backends {
+		if !isResourceWatched(backends[i], keywordPlural) {
+			continue
+		}
+		run(ctx, backends[i], f)
 	}
-	return run(ctx, func(ctx context.Context, be IInformerBackend) error {
+	return nil
+}
+
+func Create(ctx context.Context, obj *ModelObject) error {
+	return inform(ctx, obj.KeywordPlural, func(ctx context.Context, be IInformerBackend) error {
 		return be.Create(ctx, obj)
 	})
 }
 
 func Update(ctx context.Context, obj *ModelObject, oldObj *jsonutils.JSONDict) error {
-	if !isResourceWatched(obj.KeywordPlural) {
-		return nil
-	}
-	return run(ctx, func(ctx context.Context, be IInformerBackend) error {
+	return inform(ctx, obj.KeywordPlural, func(ctx context.Context, be IInformerBackend) error {
 		return be.Update(ctx, obj, oldObj)
 	})
 }
 
 func Delete(ctx context.Context, obj *ModelObject) error {
-	if !isResourceWatched(obj.KeywordPlural) {
-		return nil
-	}
-	return run(ctx, func(ctx context.Context, be IInformerBackend) error {
+	return inform(ctx, obj.KeywordPlural, func(ctx context.Context, be IInformerBackend) error {
 		return be.Delete(ctx, obj)
 	})
 }
@@ -0,0 +1,326 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package informer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/stringutils"
+	"yunion.io/x/pkg/utils"
+
+	"yunion.io/x/onecloud/pkg/util/httputils"
+	"yunion.io/x/onecloud/pkg/util/nopanic"
+)
+
+const (
+	CloudEventsSpecVersion = "1.0"
+	CloudEventsContentType = "application/cloudevents+json; charset=utf-8"
+	CloudEventsTypePrefix  = "io.yunion.onecloud"
+
+	WebhookSignatureHeader          = "X-Onecloud-Signature"
+	WebhookSignatureTimestampHeader = "X-Onecloud-Signature-Timestamp"
+
+	webhookMaxBackoff = 5 * time.Minute
+)
+
+// SWebhookSubscription is an HTTP endpoint receiving resource events
+type SWebhookSubscription struct {
+	Id   string
+	Name string
+	Url  string
+	// key of the HMAC-SHA256 signature, no signature if empty
+	Secret string
+	// keyword plurals of the subscribed resources, all resources if empty
+	Resources []string
+}
+
+func (sub SWebhookSubscription) IsResourceSubscribed(keywordPlural string) bool {
+	return len(sub.Resources) == 0 || utils.IsInStringArray(keywordPlural, sub.Resources)
+}
+
+type IWebhookSubscriptionProvider interface {
+	GetSubscriptions() []SWebhookSubscription
+}
+
+// SCloudEvent is the structured content mode of CloudEvents 1.0 in JSON format
+type SCloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Id              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            SCloudEventData `json:"data"`
+}
+
+type SCloudEventData struct {
+	Object    *jsonutils.JSONDict `json:"object"`
+	OldObject *jsonutils.JSONDict `json:"old_object"`
+}
+
+// SWebhookDelivery is the final result of delivering an event to a subscription
+type SWebhookDelivery struct {
+	Subscription SWebhookSubscription
+	Event        *SCloudEvent
+	Attempts     int
+	StatusCode   int
+	Error        string
+	Success      bool
+}
+
+type WebhookDeliveryLogger func(delivery *SWebhookDelivery)
+
+type SWebhookBackendOptions struct {
+	// source attribute of the events, such as /onecloud/compute
+	Source      string
+	Timeout     time.Duration
+	MaxAttempts int
+	WorkerCount int
+	QueueSize   int
+	// keyword plurals of the resources never published, such as the records
+	// written by the delivery logger, which would otherwise loop forever
+	IgnoreResources []string
+}
+
+type sWebhookTask struct {
+	delivery *SWebhookDelivery
+	body     []byte
+}
+
+// WebhookBackend publishes resource events as CloudEvents to the subscribed
+// HTTP endpoints. Failed deliveries are retried with exponential backoff and
+// the final result of each delivery is reported to the delivery logger.
+type WebhookBackend struct {
+	opts     SWebhookBackendOptions
+	provider IWebhookSubscriptionProvider
+	logger   WebhookDeliveryLogger
+	client   *http.Client
+	queue    chan *sWebhookTask
+}
+
+func NewWebhookBackend(opts SWebhookBackendOptions, provider IWebhookSubscriptionProvider, logger WebhookDeliveryLogger) *WebhookBackend {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 1
+	}
+	if opts.WorkerCount <= 0 {
+		opts.WorkerCount = 1
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	be := &WebhookBackend{
+		opts:     opts,
+		provider: provider,
+		logger:   logger,
+		client:   httputils.GetClient(false, opts.Timeout),
+		queue:    make(chan *sWebhookTask, opts.QueueSize),
+	}
+	for i := 0; i < opts.WorkerCount; i++ {
+		go be.worker()
+	}
+	return be
+}
+
+func (b *WebhookBackend) GetType() string {
+	return "webhook"
+}
+
+func (b *WebhookBackend) IsResourceWatched(keywordPlural string) bool {
+	if utils.IsInStringArray(keywordPlural, b.opts.IgnoreResources) {
+		return false
+	}
+	for _, sub := range b.provider.GetSubscriptions() {
+		if sub.IsResourceSubscribed(keywordPlural) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *WebhookBackend) Create(ctx context.Context, obj *ModelObject) error {
+	return b.publish(obj.KeywordPlural, b.newEvent(EventTypeCreate, obj, nil))
+}
+
+func (b *WebhookBackend) Update(ctx context.Context, obj *ModelObject, oldObj *jsonutils.JSONDict) error {
+	return b.publish(obj.KeywordPlural, b.newEvent(EventTypeUpdate, obj, oldObj))
+}
+
+func (b *WebhookBackend) Delete(ctx context.Context, obj *ModelObject) error {
+	return b.publish(obj.KeywordPlural, b.newEvent(EventTypeDelete, obj, nil))
+}
+
+func getCloudEventType(eventType TEventType, keywordPlural string) string {
+	action := ""
+	switch eventType {
+	case EventTypeCreate:
+		action = "created"
+	case EventTypeUpdate:
+		action = "updated"
+	case EventTypeDelete:
+		action = "deleted"
+	}
+	return fmt.Sprintf("%s.%s.%s", CloudEventsTypePrefix, keywordPlural, action)
+}
+
+func (b *WebhookBackend) newEvent(eventType TEventType, obj *ModelObject, oldObj *jsonutils.JSONDict) *SCloudEvent {
+	subject := obj.Id
+	if obj.IsJoint {
+		subject = fmt.Sprintf("%s/%s", obj.MasterId, obj.SlaveId)
+	}
+	return &SCloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		Id:              stringutils.UUID4(),
+		Source:          fmt.Sprintf("%s/%s", b.opts.Source, obj.KeywordPlural),
+		Type:            getCloudEventType(eventType, obj.KeywordPlural),
+		Subject:         subject,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data: SCloudEventData{
+			Object:    obj.Object,
+			OldObject: oldObj,
+		},
+	}
+}
+
+func (b *WebhookBackend) publish(keywordPlural string, event *SCloudEvent) error {
+	if utils.IsInStringArray(keywordPlural, b.opts.IgnoreResources) {
+		return nil
+	}
+	body := []byte(jsonutils.Marshal(event).String())
+	for _, sub := range b.provider.GetSubscriptions() {
+		if !sub.IsResourceSubscribed(keywordPlural) {
+			continue
+		}
+		b.enqueue(&sWebhookTask{
+			delivery: &SWebhookDelivery{
+				Subscription: sub,
+				Event:        event,
+			},
+			body: body,
+		})
+	}
+	return nil
+}
+
+func (b *WebhookBackend) enqueue(task *sWebhookTask) {
+	select {
+	case b.queue <- task:
+	default:
+		task.delivery.Error = "delivery queue is full"
+		log.Errorf("webhook %s: drop event %s: %s", task.delivery.Subscription.Name, task.delivery.Event.Id, task.delivery.Error)
+		b.logDelivery(task.delivery)
+	}
+}
+
+func (b *WebhookBackend) worker() {
+	for task := range b.queue {
+		nopanic.Run(func() {
+			b.deliver(task)
+		})
+	}
+}
+
+func (b *WebhookBackend) logDelivery(delivery *SWebhookDelivery) {
+	if b.logger != nil {
+		b.logger(delivery)
+	}
+}
+
+// backoff of the n-th retry: 1s, 2s, 4s, ... up to 5 minutes
+func getWebhookBackoff(attempts int) time.Duration {
+	backoff := time.Second << uint(attempts-1)
+	if backoff > webhookMaxBackoff || backoff <= 0 {
+		backoff = webhookMaxBackoff
+	}
+	return backoff
+}
+
+func (b *WebhookBackend) deliver(task *sWebhookTask) {
+	delivery := task.delivery
+	delivery.Attempts += 1
+	statusCode, err := b.post(delivery.Subscription, task.body)
+	delivery.StatusCode = statusCode
+	if err == nil {
+		delivery.Success = true
+		delivery.Error = ""
+		b.logDelivery(delivery)
+		return
+	}
+	delivery.Error = err.Error()
+	if !isWebhookRetryable(statusCode) || delivery.Attempts >= b.opts.MaxAttempts {
+		log.Errorf("webhook %s: deliver event %s fail after %d attempts: %s", delivery.Subscription.Name, delivery.Event.Id, delivery.Attempts, delivery.Error)
+		b.logDelivery(delivery)
+		return
+	}
+	backoff := getWebhookBackoff(delivery.Attempts)
+	log.Warningf("webhook %s: deliver event %s fail: %s, retry in %s", delivery.Subscription.Name, delivery.Event.Id, delivery.Error, backoff)
+	time.AfterFunc(backoff, func() {
+		b.enqueue(task)
+	})
+}
+
+// network errors, server errors, 408 and 429 are retried
+func isWebhookRetryable(statusCode int) bool {
+	return statusCode == 0 || statusCode >= 500 || statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests
+}
+
+// SignWebhookPayload returns the hex encoded HMAC-SHA256 of "<timestamp>.<body>"
+func SignWebhookPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (b *WebhookBackend) post(sub SWebhookSubscription, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, sub.Url, bytes.NewReader(body))
+	if err != nil {
+		return -1, errors.Wrap(err, "http.NewRequest")
+	}
+	req.Header.Set("Content-Type", CloudEventsContentType)
+	if len(sub.Secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookSignatureTimestampHeader, timestamp)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhookPayload(sub.Secret, timestamp, body))
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "post event")
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, errors.Errorf("unexpected response status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package informer
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+)
+
+type testSubscriptionProvider []SWebhookSubscription
+
+func (p testSubscriptionProvider) GetSubscriptions() []SWebhookSubscription {
+	return p
+}
+
+func TestWebhookBackend(t *testing.T) {
+	var lock sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		requests += 1
+		body, _ := ioutil.ReadAll(r.Body)
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/cloudevents+json") {
+			t.Errorf("unexpected content type %s", r.Header.Get("Content-Type"))
+		}
+		sig := SignWebhookPayload("secret", r.Header.Get(WebhookSignatureTimestampHeader), body)
+		if r.Header.Get(WebhookSignatureHeader) != "sha256="+sig {
+			t.Errorf("signature mismatch")
+		}
+		event, err := jsonutils.Parse(body)
+		if err != nil {
+			t.Errorf("invalid event %s", body)
+		}
+		if typ, _ := event.GetString("type"); typ != "io.yunion.onecloud.servers.updated" {
+			t.Errorf("unexpected event type %s", typ)
+		}
+		if name, _ := event.GetString("data", "old_object", "name"); name != "old" {
+			t.Errorf("unexpected old object name %s", name)
+		}
+		// fail the first attempt to test retry
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	deliveries := make(chan *SWebhookDelivery, 2)
+	provider := testSubscriptionProvider{
+		{Id: "hook1", Name: "servers", Url: srv.URL, Secret: "secret", Resources: []string{"servers"}},
+		{Id: "hook2", Name: "disks", Url: srv.URL, Secret: "secret", Resources: []string{"disks"}},
+	}
+	be := NewWebhookBackend(SWebhookBackendOptions{
+		Source:      "/onecloud/compute",
+		MaxAttempts: 3,
+	}, provider, func(delivery *SWebhookDelivery) {
+		deliveries <- delivery
+	})
+
+	if !be.IsResourceWatched("servers") || be.IsResourceWatched("networks") {
+		t.Fatalf("IsResourceWatched mismatch")
+	}
+
+	obj := &ModelObject{
+		Object:        jsonutils.Marshal(map[string]string{"id": "server1", "name": "new"}).(*jsonutils.JSONDict),
+		KeywordPlural: "servers",
+		Id:            "server1",
+	}
+	oldObj := jsonutils.Marshal(map[string]string{"id": "server1", "name": "old"}).(*jsonutils.JSONDict)
+	be.Update(context.Background(), obj, oldObj)
+
+	select {
+	case delivery := <-deliveries:
+		if !delivery.Success || delivery.Attempts != 2 || delivery.Subscription.Id != "hook1" {
+			t.Fatalf("unexpected delivery %#v", delivery)
+		}
+		if delivery.Event.Subject != "server1" || delivery.Event.Source != "/onecloud/compute/servers" {
+			t.Fatalf("unexpected event %#v", delivery.Event)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("delivery timeout")
+	}
+	select {
+	case delivery := <-deliveries:
+		t.Fatalf("unexpected delivery to %s", delivery.Subscription.Name)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		4:  8 * time.Second,
+		20: webhookMaxBackoff,
+		80: webhookMaxBackoff,
+	}
+	for attempts, want := range cases {
+		if got := getWebhookBackoff(attempts); got != want {
+			t.Errorf("getWebhookBackoff(%d) want %s got %s", attempts, want, got)
+		}
+	}
+}
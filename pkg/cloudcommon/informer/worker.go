@@ -51,11 +51,7 @@ func (c noCancel) Value(key interface{}) interface{} {
 	return c.ctx.Value(key)
 }*/
 
-func run(ctx context.Context, f func(ctx context.Context, be IInformerBackend) error) error {
-	be := GetDefaultBackend()
-	if be == nil {
-		return ErrBackendNotInit
-	}
+func run(ctx context.Context, be IInformerBackend, f func(ctx context.Context, be IInformerBackend) error) {
 	wf := func() {
 		nopanic.Run(func() {
 			// outside context ignored cause of run in worker
@@ -65,5 +61,4 @@ func run(ctx context.Context, f func(ctx context.Context, be IInformerBackend) e
 		})
 	}
 	informerWorkerMan.Run(wf, nil, nil)
-	return nil
 }
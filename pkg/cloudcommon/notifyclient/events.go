@@ -49,6 +49,8 @@ var (
 	ActionPendingDelete SAction = "pending_delete"
 	ActionRebuildRoot   SAction = "rebuild_root"
 	ActionChangeConfig  SAction = "change_config"
+	ActionFailed        SAction = "failed"
+	ActionTrigger       SAction = "trigger"
 )
 
 type SEvent struct {
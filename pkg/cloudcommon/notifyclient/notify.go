@@ -33,6 +33,7 @@ import (
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/pkg/util/sets"
 
+	api "yunion.io/x/onecloud/pkg/apis/notify"
 	"yunion.io/x/onecloud/pkg/appsrv"
 	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
@@ -201,6 +202,55 @@ func NotifyWebhook(ctx context.Context, userCred mcclient.TokenCredential, obj d
 	RawNotifyWithCtx(ctx, []string{}, false, npk.NotifyByWebhook, npk.NotifyPriorityNormal, event.String(), msg)
 }
 
+type SEventNotifyParam struct {
+	Obj db.IModel
+	// ResourceType is the keyword of the model manager of Obj if empty
+	ResourceType string
+	Action       SAction
+	Priority     npk.TNotifyPriority
+}
+
+// EventNotify publishes the event of the resource to the topics of notify
+// service, which sends it to the subscribers of the matched topics.
+func EventNotify(ctx context.Context, userCred mcclient.TokenCredential, param SEventNotifyParam) {
+	ret, err := db.FetchCustomizeColumns(param.Obj.GetModelManager(), ctx, userCred, jsonutils.NewDict(), []interface{}{param.Obj}, stringutils2.SSortedStrings{}, false)
+	if err != nil {
+		log.Errorf("unable to EventNotify: %v", err)
+		return
+	}
+	if len(ret) == 0 {
+		log.Errorf("unable to EventNotify: details of model %q is empty", param.Obj.GetId())
+		return
+	}
+	input := api.NotificationManagerEventNotifyInput{
+		ResourceType:    param.ResourceType,
+		Action:          string(param.Action),
+		Priority:        string(param.Priority),
+		ResourceDetails: ret[0],
+	}
+	if len(input.ResourceType) == 0 {
+		input.ResourceType = param.Obj.GetModelManager().Keyword()
+	}
+	if len(input.Priority) == 0 {
+		input.Priority = string(npk.NotifyPriorityNormal)
+	}
+	if owner := param.Obj.GetOwnerId(); owner != nil {
+		input.ProjectDomainId = owner.GetProjectDomainId()
+		input.ProjectId = owner.GetProjectId()
+	}
+	notifyClientWorkerMan.Run(func() {
+		s, err := AdminSessionGenerator(context.Background(), consts.GetRegion(), "")
+		if err != nil {
+			log.Errorf("fail to get session: %v", err)
+			return
+		}
+		_, err = modules.Notification.PerformClassAction(s, "event-notify", jsonutils.Marshal(input))
+		if err != nil {
+			log.Errorf("unable to publish event %s %s of %s: %v", input.ResourceType, input.Action, param.Obj.GetId(), err)
+		}
+	}, nil, nil)
+}
+
 func NotifyWithCtx(ctx context.Context, recipientId []string, isGroup bool, priority npk.TNotifyPriority, event string, data jsonutils.JSONObject) {
 	notify(ctx, recipientId, isGroup, priority, event, data)
 }
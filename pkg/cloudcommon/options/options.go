@@ -39,6 +39,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
 	"yunion.io/x/onecloud/pkg/util/atexit"
 	"yunion.io/x/onecloud/pkg/util/httputils"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 const (
@@ -102,6 +103,11 @@ type BaseOptions struct {
 
 	GlobalHTTPProxy  string `help:"Global http proxy"`
 	GlobalHTTPSProxy string `help:"Global https proxy"`
+
+	EnableApiRateLimit              bool `help:"Enable api rate limits per user, project and access key, the limits are configured with the api-rate-limits parameter of the service in yunionconf" default:"false"`
+	ApiRateLimitSyncIntervalSeconds int  `help:"Interval in seconds to reload api rate limits from yunionconf" default:"60"`
+
+	OtlpTracesEndpoint string `help:"OTLP/HTTP endpoint to export tracing spans, e.g. http://127.0.0.1:4318/v1/traces, spans are not exported if empty"`
 }
 
 const (
@@ -118,6 +124,8 @@ type CommonOptions struct {
 	AdminProjectDomain string `help:"Domain of Admin project"`
 	AuthTokenCacheSize uint32 `help:"Auth token Cache Size" default:"2048"`
 
+	TokenRevocationSyncIntervalSeconds int `help:"interval to poll token revocation events from keystone, 0 to disable" default:"30"`
+
 	TenantCacheExpireSeconds int `help:"expire seconds of cached tenant/domain info. defailt 15 minutes" default:"900"`
 
 	SessionEndpointType string `help:"Client session end point type"`
@@ -154,6 +162,13 @@ type DBOptions struct {
 
 	EtcdLockPrefix string `help:"prefix of etcd lock records" default:"/onecloud/lockman"`
 	EtcdLockTTL    int    `help:"ttl of etcd lock records" default:"5"`
+
+	EnableInformerWebhook              bool `help:"publish resource change events as CloudEvents to the informer webhooks registered in keystone" default:"false"`
+	InformerWebhookTimeoutSeconds      int  `help:"timeout in seconds of each informer webhook delivery" default:"10"`
+	InformerWebhookMaxAttempts         int  `help:"maximal attempts of delivering an event to an informer webhook" default:"6"`
+	InformerWebhookWorkerCount         int  `help:"number of informer webhook delivery workers" default:"4"`
+	InformerWebhookQueueSize           int  `help:"size of the informer webhook delivery queue, events are dropped when the queue is full" default:"10240"`
+	InformerWebhookSyncIntervalSeconds int  `help:"interval in seconds of fetching informer webhooks from keystone" default:"60"`
 }
 
 type EtcdOptions struct {
@@ -316,6 +331,17 @@ func ParseOptions(optStruct interface{}, args []string, configFileName string, s
 
 	log.V(10).Debugf("Parsed options: %#v", optStruct)
 
+	if len(optionsRef.OtlpTracesEndpoint) > 0 {
+		tracing.Init(optionsRef.ApplicationID, optionsRef.OtlpTracesEndpoint)
+		atexit.Register(atexit.ExitHandler{
+			Prio:   atexit.PRIO_LOG_OTHER,
+			Reason: "flush tracing spans",
+			Func: func(atexit.ExitHandler) {
+				tracing.Flush()
+			},
+		})
+	}
+
 	if len(optionsRef.Region) > 0 {
 		consts.SetRegion(optionsRef.Region)
 	}
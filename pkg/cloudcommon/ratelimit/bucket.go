@@ -0,0 +1,145 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// buckets idle for so long are full again, forget them
+	BUCKET_IDLE_EXPIRE = time.Hour
+	BUCKET_GC_INTERVAL = 10 * time.Minute
+)
+
+type SLimit struct {
+	// tokens refilled per second
+	Rate float64
+	// capacity of the bucket
+	Burst int
+}
+
+// SBucketState is the state of a token bucket, it is refilled lazily on take
+type SBucketState struct {
+	Tokens float64
+	// unix time in nanoseconds of the last take
+	Last int64
+}
+
+func (limit SLimit) burst() float64 {
+	if limit.Burst < 1 {
+		return math.Max(1, math.Ceil(limit.Rate))
+	}
+	return float64(limit.Burst)
+}
+
+// take refills the bucket according to the time elapsed and takes one token
+// from it, the time to wait for the next token is returned if the bucket is empty
+func (b *SBucketState) take(limit SLimit, now time.Time) (bool, time.Duration) {
+	n, wait := b.takeN(limit, now, 1)
+	return n > 0, wait
+}
+
+// takeN takes at most n tokens from the bucket and returns the number of
+// tokens taken, the time to wait for the next token is returned if none
+func (b *SBucketState) takeN(limit SLimit, now time.Time, n int) (int, time.Duration) {
+	burst := limit.burst()
+	if b.Last == 0 {
+		b.Tokens = burst
+	} else if elapsed := now.UnixNano() - b.Last; elapsed > 0 {
+		b.Tokens = math.Min(burst, b.Tokens+float64(elapsed)/float64(time.Second)*limit.Rate)
+	}
+	if b.Last < now.UnixNano() {
+		b.Last = now.UnixNano()
+	}
+	if b.Tokens >= 1 {
+		taken := int(math.Min(float64(n), math.Floor(b.Tokens)))
+		b.Tokens -= float64(taken)
+		return taken, 0
+	}
+	wait := time.Duration((1 - b.Tokens) / limit.Rate * float64(time.Second))
+	return 0, wait
+}
+
+func (b *SBucketState) isIdle(now time.Time) bool {
+	return now.UnixNano()-b.Last > int64(BUCKET_IDLE_EXPIRE)
+}
+
+// SBucketRequest asks for a token from the bucket of Key
+type SBucketRequest struct {
+	Key   string
+	Limit SLimit
+}
+
+// IBucketStore keeps the states of token buckets
+type IBucketStore interface {
+	// Take takes a token from each of the buckets only if none of them is
+	// empty, otherwise nothing is taken and the time to wait is returned
+	Take(ctx context.Context, reqs []SBucketRequest, now time.Time) (bool, time.Duration, error)
+}
+
+// sLocalBucketStore keeps buckets in memory, limits are enforced per process
+type sLocalBucketStore struct {
+	lock    sync.Mutex
+	buckets map[string]*SBucketState
+	lastGC  time.Time
+}
+
+func newLocalBucketStore() *sLocalBucketStore {
+	return &sLocalBucketStore{
+		buckets: make(map[string]*SBucketState),
+		lastGC:  time.Now(),
+	}
+}
+
+func (s *sLocalBucketStore) Take(ctx context.Context, reqs []SBucketRequest, now time.Time) (bool, time.Duration, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if now.Sub(s.lastGC) > BUCKET_GC_INTERVAL {
+		for k, b := range s.buckets {
+			if b.isIdle(now) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastGC = now
+	}
+
+	// take from copies of the buckets and save them only if all allowed
+	states := make([]SBucketState, len(reqs))
+	allow := true
+	var wait time.Duration
+	for i, req := range reqs {
+		if bucket, ok := s.buckets[req.Key]; ok {
+			states[i] = *bucket
+		}
+		if ok, w := states[i].take(req.Limit, now); !ok {
+			allow = false
+			if w > wait {
+				wait = w
+			}
+		}
+	}
+	if !allow {
+		return false, wait, nil
+	}
+	for i, req := range reqs {
+		s.buckets[req.Key] = &states[i]
+	}
+	return true, 0, nil
+}
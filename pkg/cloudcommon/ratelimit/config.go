@@ -0,0 +1,73 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+	"yunion.io/x/onecloud/pkg/mcclient/modules/yunionconf"
+)
+
+const (
+	// name of the parameter of the service in yunionconf
+	RATE_LIMIT_PARAMETER_NAME = "api-rate-limits"
+)
+
+func (l *SRateLimiter) startSyncConfig(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	for {
+		if err := l.syncConfig(context.Background()); err != nil {
+			log.Errorf("sync rate limits of %s: %s", l.serviceType, err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func (l *SRateLimiter) syncConfig(ctx context.Context) error {
+	s := auth.GetAdminSession(ctx, "", "")
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.NewString("system"), "scope")
+	params.Add(jsonutils.NewString(RATE_LIMIT_PARAMETER_NAME), "name")
+	result, err := yunionconf.Parameters.ListInContext(s, params, &modules.ServicesV3, l.serviceType)
+	if err != nil {
+		return errors.Wrap(err, "list parameters")
+	}
+	cfg := SRateLimitConfig{}
+	if len(result.Data) > 0 {
+		value, err := result.Data[0].Get("value")
+		if err != nil {
+			return errors.Wrap(err, "get value")
+		}
+		err = value.Unmarshal(&cfg)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s %s", RATE_LIMIT_PARAMETER_NAME, value)
+		}
+	}
+	old := l.getConfig()
+	if !jsonutils.Marshal(&old).Equals(jsonutils.Marshal(&cfg)) {
+		log.Infof("rate limits of %s changed: %s", l.serviceType, jsonutils.Marshal(&cfg))
+	}
+	l.SetConfig(cfg)
+	return nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/clientv3"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/etcd"
+	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
+)
+
+const (
+	ETCD_RATE_LIMIT_PREFIX = "/onecloud/ratelimit/"
+
+	etcdTakeMaxTries = 5
+)
+
+// sEtcdBucketStore shares buckets across the replicas of a service, the
+// buckets are updated with compare-and-swap transactions. Tokens are taken
+// in chunks by sQuotaBucketStore, so that most requests are served without
+// a round trip to etcd.
+type sEtcdBucketStore struct {
+	client  *clientv3.Client
+	prefix  string
+	timeout time.Duration
+}
+
+func newEtcdBucketStore(opts *common_options.EtcdOptions, serviceType string) (*sEtcdBucketStore, error) {
+	tlsCfg, err := opts.GetEtcdTLSConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetEtcdTLSConfig")
+	}
+	cli, err := etcd.NewEtcdClient(&etcd.SEtcdOptions{
+		EtcdEndpoint:              opts.EtcdEndpoints,
+		EtcdUsername:              opts.EtcdUsername,
+		EtcdPassword:              opts.EtcdPassword,
+		EtcdTimeoutSeconds:        5,
+		EtcdRequestTimeoutSeconds: 2,
+		EtcdLeaseExpireSeconds:    5,
+		EtcdEnabldSsl:             opts.EtcdUseTLS,
+		TLSConfig:                 tlsCfg,
+	}, func() {
+		log.Errorf("ratelimit etcd session keepalive failed")
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "NewEtcdClient")
+	}
+	return &sEtcdBucketStore{
+		client:  cli.GetClient(),
+		prefix:  ETCD_RATE_LIMIT_PREFIX + serviceType + "/",
+		timeout: 2 * time.Second,
+	}, nil
+}
+
+// acquire takes at most n tokens from the shared bucket of key, it is the
+// source of the quota chunks of sQuotaBucketStore
+func (s *sEtcdBucketStore) acquire(ctx context.Context, key string, limit SLimit, n int, now time.Time) (int, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	key = s.prefix + key
+	for tried := 0; tried < etcdTakeMaxTries; tried++ {
+		resp, err := s.client.Get(ctx, key)
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "Get")
+		}
+		bucket := SBucketState{}
+		var cmp clientv3.Cmp
+		if len(resp.Kvs) > 0 {
+			bucket, err = parseBucketState(resp.Kvs[0].Value)
+			if err != nil {
+				log.Warningf("invalid bucket %s: %s, reset it", key, err)
+				bucket = SBucketState{}
+			}
+			cmp = clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision)
+		} else {
+			cmp = clientv3.Compare(clientv3.CreateRevision(key), "=", 0)
+		}
+		taken, wait := bucket.takeN(limit, now, n)
+		if taken == 0 && len(resp.Kvs) > 0 {
+			// refill is calculated on the fly, nothing to save for denied requests
+			return 0, wait, nil
+		}
+		txn, err := s.client.Txn(ctx).If(cmp).Then(
+			clientv3.OpPut(key, jsonutils.Marshal(&bucket).String()),
+		).Commit()
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "Txn")
+		}
+		if txn.Succeeded {
+			return taken, wait, nil
+		}
+		// updated by other replicas, retry with the new state
+	}
+	return 0, 0, errors.Errorf("bucket %s too much contention", key)
+}
+
+// startGC removes the buckets idle for long periodically, it is safe for
+// all the replicas doing this at the same time
+func (s *sEtcdBucketStore) startGC() {
+	for {
+		time.Sleep(BUCKET_GC_INTERVAL)
+		if err := s.gc(context.Background()); err != nil {
+			log.Errorf("ratelimit etcd buckets gc: %s", err)
+		}
+	}
+}
+
+func (s *sEtcdBucketStore) gc(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	resp, err := s.client.Get(ctx, s.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return errors.Wrap(err, "Get")
+	}
+	now := time.Now()
+	for _, kv := range resp.Kvs {
+		if bucket, err := parseBucketState(kv.Value); err == nil && !bucket.isIdle(now) {
+			continue
+		}
+		key := string(kv.Key)
+		_, err := s.client.Txn(ctx).If(
+			clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision),
+		).Then(clientv3.OpDelete(key)).Commit()
+		if err != nil {
+			return errors.Wrapf(err, "delete %s", strings.TrimPrefix(key, s.prefix))
+		}
+	}
+	return nil
+}
+
+func parseBucketState(val []byte) (SBucketState, error) {
+	bucket := SBucketState{}
+	obj, err := jsonutils.Parse(val)
+	if err != nil {
+		return bucket, errors.Wrap(err, "jsonutils.Parse")
+	}
+	err = obj.Unmarshal(&bucket)
+	if err != nil {
+		return bucket, errors.Wrap(err, "Unmarshal")
+	}
+	return bucket, nil
+}
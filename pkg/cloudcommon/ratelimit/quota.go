@@ -0,0 +1,161 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"yunion.io/x/pkg/errors"
+)
+
+const (
+	// tokens are taken from the shared buckets in chunks of the tokens
+	// refilled in QUOTA_CHUNK_DURATION, the unused ones expire after that
+	QUOTA_CHUNK_DURATION = time.Second
+	// a chunk never takes more than 1/QUOTA_CHUNK_BURST_DIVISOR of the burst,
+	// the other replicas still get their share
+	QUOTA_CHUNK_BURST_DIVISOR = 4
+
+	quotaTakeMaxTries = 3
+)
+
+// quotaAcquireFunc takes at most n tokens from the shared bucket of key and
+// returns the number of tokens taken
+type quotaAcquireFunc func(ctx context.Context, key string, limit SLimit, n int, now time.Time) (int, time.Duration, error)
+
+type sQuota struct {
+	tokens int
+	expire time.Time
+}
+
+func (q *sQuota) available(now time.Time) bool {
+	return q != nil && q.tokens > 0 && now.Before(q.expire)
+}
+
+// sQuotaBucketStore serves requests from chunks of tokens taken from the
+// shared buckets, a shared bucket is only accessed when the chunk of this
+// process is used up or expired. Unused tokens of expired chunks are lost,
+// so the limits are never exceeded across the replicas.
+type sQuotaBucketStore struct {
+	acquire quotaAcquireFunc
+
+	lock   sync.Mutex
+	quotas map[string]*sQuota
+	lastGC time.Time
+}
+
+func newQuotaBucketStore(acquire quotaAcquireFunc) *sQuotaBucketStore {
+	return &sQuotaBucketStore{
+		acquire: acquire,
+		quotas:  make(map[string]*sQuota),
+		lastGC:  time.Now(),
+	}
+}
+
+func quotaChunkSize(limit SLimit) int {
+	n := int(math.Ceil(limit.Rate * QUOTA_CHUNK_DURATION.Seconds()))
+	if max := int(limit.burst()) / QUOTA_CHUNK_BURST_DIVISOR; n > max {
+		n = max
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// missing returns the requests whose chunks are used up or expired
+func (s *sQuotaBucketStore) missing(reqs []SBucketRequest, now time.Time) []SBucketRequest {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if now.Sub(s.lastGC) > BUCKET_GC_INTERVAL {
+		for k, q := range s.quotas {
+			if !q.available(now) {
+				delete(s.quotas, k)
+			}
+		}
+		s.lastGC = now
+	}
+
+	ret := make([]SBucketRequest, 0)
+	for _, req := range reqs {
+		if !s.quotas[req.Key].available(now) {
+			ret = append(ret, req)
+		}
+	}
+	return ret
+}
+
+func (s *sQuotaBucketStore) add(key string, tokens int, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	q := s.quotas[key]
+	if !q.available(now) {
+		q = &sQuota{}
+		s.quotas[key] = q
+	}
+	q.tokens += tokens
+	q.expire = now.Add(QUOTA_CHUNK_DURATION)
+}
+
+// consume takes a token from each of the chunks only if none is used up
+func (s *sQuotaBucketStore) consume(reqs []SBucketRequest, now time.Time) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, req := range reqs {
+		if !s.quotas[req.Key].available(now) {
+			return false
+		}
+	}
+	for _, req := range reqs {
+		s.quotas[req.Key].tokens -= 1
+	}
+	return true
+}
+
+func (s *sQuotaBucketStore) Take(ctx context.Context, reqs []SBucketRequest, now time.Time) (bool, time.Duration, error) {
+	for tried := 0; tried < quotaTakeMaxTries; tried++ {
+		if s.consume(reqs, now) {
+			return true, 0, nil
+		}
+		allow := true
+		var wait time.Duration
+		for _, req := range s.missing(reqs, now) {
+			n, w, err := s.acquire(ctx, req.Key, req.Limit, quotaChunkSize(req.Limit), now)
+			if err != nil {
+				return false, 0, errors.Wrapf(err, "acquire %s", req.Key)
+			}
+			if n == 0 {
+				allow = false
+				if w > wait {
+					wait = w
+				}
+				continue
+			}
+			// kept for the following requests even if this one is denied
+			s.add(req.Key, n, now)
+		}
+		if !allow {
+			return false, wait, nil
+		}
+		// the chunks may be used up by concurrent requests, try again
+	}
+	return false, 0, errors.Error("too much contention on quota chunks")
+}
@@ -0,0 +1,345 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"yunion.io/x/log"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+)
+
+const (
+	LIMIT_KEY_USER       = "user"
+	LIMIT_KEY_PROJECT    = "project"
+	LIMIT_KEY_ACCESS_KEY = "access_key"
+
+	HANDLER_CLASS_LIST    = "list"
+	HANDLER_CLASS_GET     = "get"
+	HANDLER_CLASS_CREATE  = "create"
+	HANDLER_CLASS_UPDATE  = "update"
+	HANDLER_CLASS_DELETE  = "delete"
+	HANDLER_CLASS_PERFORM = "perform"
+	HANDLER_CLASS_ANY     = "*"
+)
+
+var (
+	limitKeys = []string{LIMIT_KEY_USER, LIMIT_KEY_PROJECT, LIMIT_KEY_ACCESS_KEY}
+)
+
+// SRateLimitRule limits the requests of the handler class from a user,
+// a project or an access key
+type SRateLimitRule struct {
+	// user, project or access_key
+	Key string
+	// id of the user, project or access key, empty matches all
+	Id string
+	// list, get, create, update, delete or perform, empty or * matches all
+	Class string
+	// requests allowed per second, zero or negative means unlimited
+	Rate float64
+	// maximal requests allowed in a burst, default to the rate
+	Burst int
+}
+
+func (rule *SRateLimitRule) getClass() string {
+	if len(rule.Class) == 0 {
+		return HANDLER_CLASS_ANY
+	}
+	return rule.Class
+}
+
+func (rule *SRateLimitRule) match(key, id, class string) (bool, int) {
+	if rule.Key != key {
+		return false, 0
+	}
+	score := 0
+	if len(rule.Id) > 0 {
+		if rule.Id != id {
+			return false, 0
+		}
+		score += 2
+	}
+	if ruleClass := rule.getClass(); ruleClass != HANDLER_CLASS_ANY {
+		if ruleClass != class {
+			return false, 0
+		}
+		score += 1
+	}
+	return true, score
+}
+
+// SRateLimitConfig is the value of the api-rate-limits parameter of the
+// service in yunionconf, e.g.
+// {"rules":[{"key":"user","class":"create","rate":1,"burst":10},{"key":"project","rate":50,"burst":100}]}
+type SRateLimitConfig struct {
+	Rules []SRateLimitRule
+	// requests with system admin privilege are not limited by default,
+	// e.g. the requests between services
+	IncludeSystemAdmin bool
+}
+
+// matchRule returns the most specific rule for the key and class, a rule
+// of the exact id wins over the one of the exact class
+func (cfg *SRateLimitConfig) matchRule(key, id, class string) *SRateLimitRule {
+	var ret *SRateLimitRule
+	maxScore := -1
+	for i := range cfg.Rules {
+		if ok, score := cfg.Rules[i].match(key, id, class); ok && score > maxScore {
+			ret = &cfg.Rules[i]
+			maxScore = score
+		}
+	}
+	return ret
+}
+
+type SRateLimiter struct {
+	serviceType string
+
+	lock   sync.RWMutex
+	config SRateLimitConfig
+
+	local *sLocalBucketStore
+	store IBucketStore
+}
+
+func NewRateLimiter(serviceType string, store IBucketStore) *SRateLimiter {
+	limiter := &SRateLimiter{
+		serviceType: serviceType,
+		local:       newLocalBucketStore(),
+	}
+	limiter.store = limiter.local
+	if store != nil {
+		limiter.store = store
+	}
+	return limiter
+}
+
+func (l *SRateLimiter) SetConfig(cfg SRateLimitConfig) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.config = cfg
+}
+
+func (l *SRateLimiter) getConfig() SRateLimitConfig {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	return l.config
+}
+
+func getAccessKey(token mcclient.TokenCredential) string {
+	if v3, ok := token.(*mcclient.TokenCredentialV3); ok {
+		return v3.Token.AccessKey.AccessKey
+	}
+	return ""
+}
+
+func getLimitId(token mcclient.TokenCredential, key string) string {
+	switch key {
+	case LIMIT_KEY_USER:
+		return token.GetUserId()
+	case LIMIT_KEY_PROJECT:
+		return token.GetProjectId()
+	case LIMIT_KEY_ACCESS_KEY:
+		return getAccessKey(token)
+	}
+	return ""
+}
+
+// Allow takes a token from the buckets of the user, the project and the
+// access key of the request, nothing is taken and the time to wait is
+// returned if any of them is empty
+func (l *SRateLimiter) Allow(ctx context.Context, token mcclient.TokenCredential, class string) (bool, time.Duration) {
+	cfg := l.getConfig()
+	if len(cfg.Rules) == 0 {
+		return true, 0
+	}
+	if !cfg.IncludeSystemAdmin && token.HasSystemAdminPrivilege() {
+		return true, 0
+	}
+
+	reqs := make([]SBucketRequest, 0, len(limitKeys))
+	for _, key := range limitKeys {
+		id := getLimitId(token, key)
+		if len(id) == 0 {
+			continue
+		}
+		rule := cfg.matchRule(key, id, class)
+		if rule == nil || rule.Rate <= 0 {
+			continue
+		}
+		reqs = append(reqs, SBucketRequest{
+			Key:   fmt.Sprintf("%s/%s/%s", key, id, rule.getClass()),
+			Limit: SLimit{Rate: rule.Rate, Burst: rule.Burst},
+		})
+	}
+	if len(reqs) == 0 {
+		return true, 0
+	}
+	now := time.Now()
+	allow, wait, err := l.store.Take(ctx, reqs, now)
+	if err != nil {
+		// degrade to limits per process instead of rejecting the request
+		log.Errorf("take tokens from buckets: %s", err)
+		allow, wait, _ = l.local.Take(ctx, reqs, now)
+	}
+	return allow, wait
+}
+
+// names of the handlers of appsrv/dispatcher
+var handlerClasses = map[string]string{
+	"list":                 HANDLER_CLASS_LIST,
+	"get_details":          HANDLER_CLASS_GET,
+	"head_details":         HANDLER_CLASS_GET,
+	"get_specific":         HANDLER_CLASS_GET,
+	"create":               HANDLER_CLASS_CREATE,
+	"perform_class_action": HANDLER_CLASS_PERFORM,
+	"perform_action":       HANDLER_CLASS_PERFORM,
+	"update":               HANDLER_CLASS_UPDATE,
+	"patch":                HANDLER_CLASS_UPDATE,
+	"update_spec":          HANDLER_CLASS_UPDATE,
+	"patch_spec":           HANDLER_CLASS_UPDATE,
+	"delete":               HANDLER_CLASS_DELETE,
+	"delete_spec":          HANDLER_CLASS_DELETE,
+}
+
+// names of the handlers in context, e.g. list_in_hosts
+var handlerInContextClasses = map[string]string{
+	"list_in_":   HANDLER_CLASS_LIST,
+	"create_in_": HANDLER_CLASS_CREATE,
+	"update_in_": HANDLER_CLASS_UPDATE,
+	"patch_in_":  HANDLER_CLASS_UPDATE,
+	"delete_in_": HANDLER_CLASS_DELETE,
+}
+
+// GetHandlerClass classifies the request by the name of the model
+// dispatcher handler, or by the method and the path parameters otherwise
+func GetHandlerClass(ctx context.Context, r *http.Request) string {
+	var params map[string]string
+	if appParams := appsrv.AppContextGetParams(ctx); appParams != nil {
+		if class, ok := handlerClasses[appParams.Name]; ok {
+			return class
+		}
+		for prefix, class := range handlerInContextClasses {
+			if strings.HasPrefix(appParams.Name, prefix) {
+				return class
+			}
+		}
+		params = appParams.Params
+	}
+	_, hasAction := params["<action>"]
+	_, hasId := params["<resid>"]
+	switch r.Method {
+	case "GET", "HEAD":
+		if hasId {
+			return HANDLER_CLASS_GET
+		}
+		return HANDLER_CLASS_LIST
+	case "POST":
+		if hasAction {
+			return HANDLER_CLASS_PERFORM
+		}
+		return HANDLER_CLASS_CREATE
+	case "PUT", "PATCH":
+		return HANDLER_CLASS_UPDATE
+	case "DELETE":
+		return HANDLER_CLASS_DELETE
+	}
+	return HANDLER_CLASS_ANY
+}
+
+var (
+	limiterLock    sync.RWMutex
+	defaultLimiter *SRateLimiter
+	etcdOptions    *common_options.EtcdOptions
+)
+
+func getLimiter() *SRateLimiter {
+	limiterLock.RLock()
+	defer limiterLock.RUnlock()
+
+	return defaultLimiter
+}
+
+// SetEtcdOptions makes the buckets shared across replicas with the etcd
+// cluster, it should be called before Init
+func SetEtcdOptions(opts *common_options.EtcdOptions) {
+	limiterLock.Lock()
+	defer limiterLock.Unlock()
+
+	etcdOptions = opts
+}
+
+// Init enables the rate limits of the service, the rules are reloaded from
+// yunionconf periodically
+func Init(serviceType string, syncIntervalSeconds int) {
+	limiterLock.Lock()
+	defer limiterLock.Unlock()
+
+	if defaultLimiter != nil {
+		return
+	}
+	var store IBucketStore
+	if etcdOptions != nil && len(etcdOptions.EtcdEndpoints) > 0 {
+		etcdStore, err := newEtcdBucketStore(etcdOptions, serviceType)
+		if err != nil {
+			log.Errorf("rate limit buckets in etcd: %s, fallback to local buckets", err)
+		} else {
+			log.Infof("share rate limit buckets with etcd")
+			go etcdStore.startGC()
+			store = newQuotaBucketStore(etcdStore.acquire)
+		}
+	}
+	defaultLimiter = NewRateLimiter(serviceType, store)
+	go defaultLimiter.startSyncConfig(time.Duration(syncIntervalSeconds) * time.Second)
+}
+
+// Limit rejects the requests exceeding the rate limits with 429, it must be
+// wrapped by the authentication middleware
+func Limit(f appsrv.FilterHandler) appsrv.FilterHandler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		limiter := getLimiter()
+		if limiter != nil {
+			token := auth.FetchUserCredential(ctx, nil)
+			if token != nil && !auth.IsGuestToken(token) {
+				class := GetHandlerClass(ctx, r)
+				if ok, wait := limiter.Allow(ctx, token, class); !ok {
+					retryAfter := int(math.Ceil(wait.Seconds()))
+					if retryAfter < 1 {
+						retryAfter = 1
+					}
+					w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+					httperrors.TooManyRequestsError(ctx, w, "too many %s requests, retry after %d seconds", class, retryAfter)
+					return
+				}
+			}
+		}
+		f(ctx, w, r)
+	}
+}
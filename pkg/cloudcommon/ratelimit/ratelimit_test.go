@@ -0,0 +1,324 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"yunion.io/x/onecloud/pkg/appctx"
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+func newToken(userId, projectId, accessKey string) *mcclient.TokenCredentialV3 {
+	token := &mcclient.TokenCredentialV3{}
+	token.Token.User.Id = userId
+	token.Token.Project.Id = projectId
+	token.Token.AccessKey.AccessKey = accessKey
+	return token
+}
+
+func TestBucketTake(t *testing.T) {
+	limit := SLimit{Rate: 2, Burst: 3}
+	now := time.Now()
+	bucket := SBucketState{}
+	for i := 0; i < 3; i++ {
+		if ok, _ := bucket.take(limit, now); !ok {
+			t.Fatalf("take %d of burst denied", i)
+		}
+	}
+	ok, wait := bucket.take(limit, now)
+	if ok {
+		t.Fatalf("take beyond burst allowed")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("wait %s, expect 500ms", wait)
+	}
+	if ok, _ := bucket.take(limit, now.Add(500*time.Millisecond)); !ok {
+		t.Errorf("take after refill denied")
+	}
+	// never refilled beyond burst
+	if bucket.take(limit, now.Add(time.Hour)); bucket.Tokens != 2 {
+		t.Errorf("tokens %f after long idle, expect 2", bucket.Tokens)
+	}
+}
+
+func TestMatchRule(t *testing.T) {
+	cfg := SRateLimitConfig{
+		Rules: []SRateLimitRule{
+			{Key: LIMIT_KEY_USER, Rate: 10},
+			{Key: LIMIT_KEY_USER, Class: HANDLER_CLASS_CREATE, Rate: 1},
+			{Key: LIMIT_KEY_USER, Id: "vip", Rate: 100},
+			{Key: LIMIT_KEY_USER, Id: "vip", Class: HANDLER_CLASS_CREATE, Rate: 0},
+		},
+	}
+	cases := []struct {
+		id    string
+		class string
+		rate  float64
+	}{
+		{"u1", HANDLER_CLASS_LIST, 10},
+		{"u1", HANDLER_CLASS_CREATE, 1},
+		{"vip", HANDLER_CLASS_LIST, 100},
+		{"vip", HANDLER_CLASS_CREATE, 0},
+	}
+	for _, c := range cases {
+		rule := cfg.matchRule(LIMIT_KEY_USER, c.id, c.class)
+		if rule == nil || rule.Rate != c.rate {
+			t.Errorf("%s %s: got rule %#v, expect rate %f", c.id, c.class, rule, c.rate)
+		}
+	}
+	if rule := cfg.matchRule(LIMIT_KEY_PROJECT, "p1", HANDLER_CLASS_LIST); rule != nil {
+		t.Errorf("project matches user rule %#v", rule)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	limiter := NewRateLimiter("compute", nil)
+	limiter.SetConfig(SRateLimitConfig{
+		Rules: []SRateLimitRule{
+			{Key: LIMIT_KEY_USER, Rate: 0.001, Burst: 2},
+			{Key: LIMIT_KEY_PROJECT, Class: HANDLER_CLASS_CREATE, Rate: 0.001, Burst: 3},
+			{Key: LIMIT_KEY_ACCESS_KEY, Rate: 0.001, Burst: 1},
+		},
+	})
+	ctx := context.Background()
+
+	u1 := newToken("u1", "p1", "")
+	u2 := newToken("u2", "p1", "")
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow(ctx, u1, HANDLER_CLASS_CREATE); !ok {
+			t.Fatalf("request %d of u1 denied", i)
+		}
+	}
+	if ok, wait := limiter.Allow(ctx, u1, HANDLER_CLASS_LIST); ok || wait <= 0 {
+		t.Errorf("u1 exceeds user limit, got allow %v wait %s", ok, wait)
+	}
+	// the bucket of the project is shared by its users
+	if ok, _ := limiter.Allow(ctx, u2, HANDLER_CLASS_CREATE); !ok {
+		t.Errorf("first create of u2 denied")
+	}
+	if ok, _ := limiter.Allow(ctx, u2, HANDLER_CLASS_CREATE); ok {
+		t.Errorf("project p1 exceeds create limit")
+	}
+
+	ak := newToken("u3", "p2", "ak1")
+	if ok, _ := limiter.Allow(ctx, ak, HANDLER_CLASS_LIST); !ok {
+		t.Errorf("first request of access key denied")
+	}
+	if ok, _ := limiter.Allow(ctx, ak, HANDLER_CLASS_LIST); ok {
+		t.Errorf("access key exceeds limit")
+	}
+}
+
+func TestAllowAllOrNothing(t *testing.T) {
+	limiter := NewRateLimiter("compute", nil)
+	limiter.SetConfig(SRateLimitConfig{
+		Rules: []SRateLimitRule{
+			{Key: LIMIT_KEY_USER, Id: "u1", Rate: 0.001, Burst: 1},
+			{Key: LIMIT_KEY_PROJECT, Rate: 0.001, Burst: 3},
+		},
+	})
+	ctx := context.Background()
+
+	u1 := newToken("u1", "p1", "")
+	u2 := newToken("u2", "p1", "")
+	if ok, _ := limiter.Allow(ctx, u1, HANDLER_CLASS_LIST); !ok {
+		t.Fatalf("first request of u1 denied")
+	}
+	// denied by the user bucket, the project bucket is left untouched
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.Allow(ctx, u1, HANDLER_CLASS_LIST); ok {
+			t.Fatalf("u1 exceeds user limit")
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow(ctx, u2, HANDLER_CLASS_LIST); !ok {
+			t.Errorf("request %d of u2 denied", i)
+		}
+	}
+	if ok, _ := limiter.Allow(ctx, u2, HANDLER_CLASS_LIST); ok {
+		t.Errorf("project p1 exceeds limit")
+	}
+}
+
+// sharedBuckets stands in for the buckets in etcd
+type sharedBuckets struct {
+	lock     sync.Mutex
+	buckets  map[string]*SBucketState
+	acquired int
+}
+
+func (b *sharedBuckets) acquire(ctx context.Context, key string, limit SLimit, n int, now time.Time) (int, time.Duration, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.acquired++
+	bucket, ok := b.buckets[key]
+	if !ok {
+		bucket = &SBucketState{}
+		b.buckets[key] = bucket
+	}
+	taken, wait := bucket.takeN(limit, now, n)
+	return taken, wait, nil
+}
+
+func TestQuotaChunkSize(t *testing.T) {
+	cases := []struct {
+		limit SLimit
+		want  int
+	}{
+		{SLimit{Rate: 0.001, Burst: 1}, 1},
+		{SLimit{Rate: 10, Burst: 100}, 10},
+		{SLimit{Rate: 100, Burst: 100}, 25},
+		{SLimit{Rate: 100}, 25},
+		{SLimit{Rate: 3, Burst: 2}, 1},
+	}
+	for _, c := range cases {
+		if got := quotaChunkSize(c.limit); got != c.want {
+			t.Errorf("quotaChunkSize(%#v) = %d, want %d", c.limit, got, c.want)
+		}
+	}
+}
+
+func TestQuotaBucketStore(t *testing.T) {
+	shared := &sharedBuckets{buckets: map[string]*SBucketState{}}
+	replicas := []*sQuotaBucketStore{
+		newQuotaBucketStore(shared.acquire),
+		newQuotaBucketStore(shared.acquire),
+	}
+	ctx := context.Background()
+	now := time.Now()
+	reqs := []SBucketRequest{{Key: "user/u1/*", Limit: SLimit{Rate: 100, Burst: 100}}}
+
+	allowed := 0
+	for i := 0; i < 200; i++ {
+		ok, _, err := replicas[i%2].Take(ctx, reqs, now)
+		if err != nil {
+			t.Fatalf("Take: %v", err)
+		}
+		if ok {
+			allowed++
+		}
+	}
+	if allowed != 100 {
+		t.Errorf("allowed %d requests across replicas, want 100", allowed)
+	}
+	// chunks of 25 tokens, then a denied acquire per request
+	if want := 4 + 100; shared.acquired != want {
+		t.Errorf("acquired %d times from shared buckets, want %d", shared.acquired, want)
+	}
+
+	// chunks expire, the shared bucket is refilled
+	later := now.Add(QUOTA_CHUNK_DURATION)
+	if ok, _, _ := replicas[0].Take(ctx, reqs, later); !ok {
+		t.Errorf("request after refill denied")
+	}
+}
+
+func TestQuotaBucketStoreAllOrNothing(t *testing.T) {
+	shared := &sharedBuckets{buckets: map[string]*SBucketState{}}
+	store := newQuotaBucketStore(shared.acquire)
+	ctx := context.Background()
+	now := time.Now()
+	user := SBucketRequest{Key: "user/u1/*", Limit: SLimit{Rate: 0.001, Burst: 1}}
+	project := SBucketRequest{Key: "project/p1/*", Limit: SLimit{Rate: 0.001, Burst: 2}}
+
+	if ok, _, _ := store.Take(ctx, []SBucketRequest{user, project}, now); !ok {
+		t.Fatalf("first request denied")
+	}
+	if ok, wait, _ := store.Take(ctx, []SBucketRequest{user, project}, now); ok || wait <= 0 {
+		t.Fatalf("request exceeding user limit got allow %v wait %s", ok, wait)
+	}
+	// the chunk of the project taken by the denied request is still usable
+	if ok, _, _ := store.Take(ctx, []SBucketRequest{project}, now); !ok {
+		t.Errorf("request of the project denied")
+	}
+	if ok, _, _ := store.Take(ctx, []SBucketRequest{project}, now); ok {
+		t.Errorf("project exceeds limit")
+	}
+}
+
+func TestLimit(t *testing.T) {
+	limiterLock.Lock()
+	defaultLimiter = NewRateLimiter("compute", nil)
+	limiterLock.Unlock()
+	defer func() {
+		limiterLock.Lock()
+		defaultLimiter = nil
+		limiterLock.Unlock()
+	}()
+	defaultLimiter.SetConfig(SRateLimitConfig{
+		Rules: []SRateLimitRule{
+			{Key: LIMIT_KEY_USER, Class: HANDLER_CLASS_PERFORM, Rate: 0.5, Burst: 1},
+		},
+	})
+
+	handler := Limit(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	ctx := context.WithValue(context.Background(), appctx.APP_CONTEXT_KEY_AUTH_TOKEN, mcclient.TokenCredential(newToken("u1", "p1", "")))
+	ctx = context.WithValue(ctx, appsrv.APP_CONTEXT_KEY_APP_PARAMS, &appsrv.SAppParams{Name: "perform_action"})
+
+	codes := []int{}
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		handler(ctx, w, httptest.NewRequest("POST", "/servers/s1/stop", nil))
+		codes = append(codes, w.Code)
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "2" {
+			t.Errorf("Retry-After %q, expect 2", w.Header().Get("Retry-After"))
+		}
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("got status %v", codes)
+	}
+
+	// other handler classes are not limited
+	ctx = context.WithValue(ctx, appsrv.APP_CONTEXT_KEY_APP_PARAMS, &appsrv.SAppParams{Name: "list"})
+	w := httptest.NewRecorder()
+	handler(ctx, w, httptest.NewRequest("GET", "/servers", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("list got status %d", w.Code)
+	}
+}
+
+func TestGetHandlerClass(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		params map[string]string
+		class  string
+	}{
+		{"list", "GET", nil, HANDLER_CLASS_LIST},
+		{"list_in_hosts", "GET", nil, HANDLER_CLASS_LIST},
+		{"get_details", "GET", nil, HANDLER_CLASS_GET},
+		{"perform_action", "POST", nil, HANDLER_CLASS_PERFORM},
+		{"delete_in_hosts", "DELETE", nil, HANDLER_CLASS_DELETE},
+		{"get_<apiver>_<resname>", "GET", map[string]string{"<resname>": "servers"}, HANDLER_CLASS_LIST},
+		{"get_<apiver>_<resname>_<resid>", "GET", map[string]string{"<resid>": "s1"}, HANDLER_CLASS_GET},
+		{"post_<apiver>_<resname>_<resid>_<action>", "POST", map[string]string{"<resid>": "s1", "<action>": "stop"}, HANDLER_CLASS_PERFORM},
+		{"post_<apiver>_<resname>", "POST", nil, HANDLER_CLASS_CREATE},
+	}
+	for _, c := range cases {
+		ctx := context.WithValue(context.Background(), appsrv.APP_CONTEXT_KEY_APP_PARAMS, &appsrv.SAppParams{Name: c.name, Params: c.params})
+		if class := GetHandlerClass(ctx, httptest.NewRequest(c.method, "/", nil)); class != c.class {
+			t.Errorf("%s %s: got class %s, expect %s", c.method, c.name, class, c.class)
+		}
+	}
+}
@@ -110,7 +110,8 @@ var (
 )
 
 var (
-	DnsPolicyValueEmpty = TDnsPolicyValue("")
+	DnsPolicyValueEmpty   = TDnsPolicyValue("")
+	DnsPolicyValueDefault = TDnsPolicyValue("Default")
 
 	DnsPolicyValueUnicom      = TDnsPolicyValue("unicom")
 	DnsPolicyValueTelecom     = TDnsPolicyValue("telecom")
@@ -702,10 +702,24 @@ func checkAssignHost(userCred mcclient.TokenCredential, preferHost string) error
 	return nil
 }
 
+// logical volumes can't be transferred to other hosts yet
+func checkLvmDisks(guest *models.SGuest) error {
+	for _, guestDisk := range guest.GetDisks() {
+		storage := guestDisk.GetDisk().GetStorage()
+		if storage != nil && storage.StorageType == api.STORAGE_LVM {
+			return httperrors.NewUnsupportOperationError("Cannot migrate guest with disks on %s storage", api.STORAGE_LVM)
+		}
+	}
+	return nil
+}
+
 func (self *SKVMGuestDriver) CheckMigrate(guest *models.SGuest, userCred mcclient.TokenCredential, input api.GuestMigrateInput) error {
 	if len(guest.BackupHostId) > 0 {
 		return httperrors.NewBadRequestError("Guest have backup, can't migrate")
 	}
+	if err := checkLvmDisks(guest); err != nil {
+		return err
+	}
 	if !input.IsRescueMode && guest.Status != api.VM_READY {
 		return httperrors.NewServerStatusError("Cannot normal migrate guest in status %s, try rescue mode or server-live-migrate?", guest.Status)
 	}
@@ -735,6 +749,9 @@ func (self *SKVMGuestDriver) CheckLiveMigrate(guest *models.SGuest, userCred mcc
 	if len(guest.BackupHostId) > 0 {
 		return httperrors.NewBadRequestError("Guest have backup, can't migrate")
 	}
+	if err := checkLvmDisks(guest); err != nil {
+		return err
+	}
 	if utils.IsInStringArray(guest.Status, []string{api.VM_RUNNING, api.VM_SUSPEND}) {
 		cdrom := guest.GetCdrom()
 		if cdrom != nil && len(cdrom.ImageId) > 0 {
@@ -66,6 +66,10 @@ func (self *SBaseHostDriver) RequestResetDisk(ctx context.Context, host *models.
 	return fmt.Errorf("Not Implement")
 }
 
+func (self *SBaseHostDriver) RequestMigrateDiskStorage(ctx context.Context, host *models.SHost, disk *models.SDisk, sourceStorage, targetStorage *models.SStorage, task taskman.ITask) error {
+	return httperrors.NewNotImplementedError("Not Implement RequestMigrateDiskStorage")
+}
+
 func (self *SBaseHostDriver) RequestCleanUpDiskSnapshots(ctx context.Context, host *models.SHost, disk *models.SDisk, params *jsonutils.JSONDict, task taskman.ITask) error {
 	return fmt.Errorf("Not Implement")
 }
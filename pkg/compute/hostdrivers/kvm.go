@@ -58,7 +58,7 @@ func (self *SKVMHostDriver) GetHypervisor() string {
 }
 
 func (self *SKVMHostDriver) ValidateAttachStorage(ctx context.Context, userCred mcclient.TokenCredential, host *models.SHost, storage *models.SStorage, data *jsonutils.JSONDict) error {
-	if !utils.IsInStringArray(storage.StorageType, append([]string{api.STORAGE_LOCAL}, api.SHARED_STORAGE...)) {
+	if !utils.IsInStringArray(storage.StorageType, append([]string{api.STORAGE_LOCAL, api.STORAGE_LVM}, api.SHARED_STORAGE...)) {
 		return httperrors.NewUnsupportOperationError("Unsupport attach %s storage for %s host", storage.StorageType, host.HostType)
 	}
 	if storage.StorageType == api.STORAGE_RBD {
@@ -111,7 +111,7 @@ func (self *SKVMHostDriver) RequestAttachStorage(ctx context.Context, hoststorag
 				"mount_point":  hoststorage.MountPoint,
 				"name":         storage.Name,
 				"storage_id":   storage.Id,
-				"storage_conf": storage.StorageConf,
+				"storage_conf": storage.GetHostStorageConf(),
 				"storage_type": storage.StorageType,
 			}
 			if len(storage.StoragecacheId) > 0 {
@@ -263,6 +263,13 @@ func (self *SKVMHostDriver) RequestAllocateDiskOnStorage(ctx context.Context, us
 			content.Set("snapshot_url", jsonutils.NewString(snapshot.Id))
 			content.Set("src_disk_id", jsonutils.NewString(snapshot.DiskId))
 			content.Set("src_pool", jsonutils.NewString(pool))
+		} else if snapshotStorage.StorageType == api.STORAGE_LVM {
+			// thin snapshots can only be cloned in the same volume group
+			if snapshotStorage.Id != storage.Id {
+				return fmt.Errorf("lvm snapshot %s can only create disk on storage %s", snapshot.Id, snapshotStorage.Name)
+			}
+			content.Set("snapshot_url", jsonutils.NewString(snapshot.Id))
+			content.Set("src_disk_id", jsonutils.NewString(snapshot.DiskId))
 		} else {
 			content.Set("snapshot_url", jsonutils.NewString(snapshot.Location))
 		}
@@ -319,6 +326,22 @@ func (self *SKVMHostDriver) RequestResizeDiskOnHost(ctx context.Context, host *m
 	return err
 }
 
+func (self *SKVMHostDriver) RequestMigrateDiskStorage(ctx context.Context, host *models.SHost, disk *models.SDisk, sourceStorage, targetStorage *models.SStorage, task taskman.ITask) error {
+	header := task.GetTaskRequestHeader()
+
+	url := fmt.Sprintf("/disks/%s/migrate-storage/%s", sourceStorage.Id, disk.Id)
+	body := jsonutils.NewDict()
+	content := jsonutils.NewDict()
+	content.Add(jsonutils.NewString(targetStorage.Id), "target_storage_id")
+	guest := disk.GetGuest()
+	if guest != nil {
+		content.Add(jsonutils.NewString(guest.Id), "server_id")
+	}
+	body.Add(content, "disk")
+	_, err := host.Request(ctx, task.GetUserCred(), "POST", url, header, body)
+	return err
+}
+
 func (self *SKVMHostDriver) RequestPrepareSaveDiskOnHost(ctx context.Context, host *models.SHost, disk *models.SDisk, imageId string, task taskman.ITask) error {
 	body := jsonutils.NewDict()
 	body.Add(jsonutils.Marshal(map[string]string{"image_id": imageId}), "disk")
@@ -868,6 +868,95 @@ func (disk *SDisk) PerformResize(ctx context.Context, userCred mcclient.TokenCre
 	return nil, nil
 }
 
+func (self *SDisk) AllowPerformMigrateStorage(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowPerform(userCred, self, "migrate-storage")
+}
+
+// 将KVM磁盘迁移到同一宿主机挂载的另一个存储，虚机运行时通过drive-mirror在线迁移
+func (self *SDisk) PerformMigrateStorage(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.DiskMigrateStorageInput) (jsonutils.JSONObject, error) {
+	if self.Status != api.DISK_READY {
+		return nil, httperrors.NewInvalidStatusError("Cannot migrate disk in status %s", self.Status)
+	}
+	if len(input.TargetStorageId) == 0 {
+		return nil, httperrors.NewMissingParameterError("target_storage_id")
+	}
+	storage := self.GetStorage()
+	if storage == nil {
+		return nil, httperrors.NewInternalServerError("disk has no valid storage")
+	}
+	if !utils.IsInStringArray(storage.StorageType, api.STORAGE_MIGRATABLE_TYPES) {
+		return nil, httperrors.NewUnsupportOperationError("Cannot migrate disk on %s storage", storage.StorageType)
+	}
+	targetObj, err := StorageManager.FetchByIdOrName(userCred, input.TargetStorageId)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, httperrors.NewResourceNotFoundError2(StorageManager.Keyword(), input.TargetStorageId)
+		}
+		return nil, httperrors.NewGeneralError(err)
+	}
+	targetStorage := targetObj.(*SStorage)
+	if targetStorage.Id == storage.Id {
+		return nil, httperrors.NewInputParameterError("disk already on storage %s", targetStorage.Name)
+	}
+	if !utils.IsInStringArray(targetStorage.StorageType, api.STORAGE_MIGRATABLE_TYPES) {
+		return nil, httperrors.NewUnsupportOperationError("Cannot migrate disk to %s storage", targetStorage.StorageType)
+	}
+	if targetStorage.Enabled.IsFalse() || !utils.IsInStringArray(targetStorage.Status, []string{api.STORAGE_ENABLED, api.STORAGE_ONLINE}) {
+		return nil, httperrors.NewInvalidStatusError("target storage %s is not online", targetStorage.Name)
+	}
+	if int64(self.DiskSize) > targetStorage.GetFreeCapacity() && !targetStorage.IsEmulated {
+		return nil, httperrors.NewOutOfResourceError("Not enough free space on storage %s", targetStorage.Name)
+	}
+	if cnt, err := self.GetSnapshotCount(); err != nil {
+		return nil, httperrors.NewInternalServerError("GetSnapshotCount fail %s", err)
+	} else if cnt > 0 {
+		return nil, httperrors.NewUnsupportOperationError("Disk has %d snapshots, please delete them first", cnt)
+	}
+
+	var host *SHost
+	guest := self.GetGuest()
+	if guest != nil {
+		if len(guest.BackupHostId) > 0 {
+			return nil, httperrors.NewUnsupportOperationError("Cannot migrate disk of guest with backup host")
+		}
+		if !utils.IsInStringArray(guest.Status, []string{api.VM_RUNNING, api.VM_READY}) {
+			return nil, httperrors.NewInvalidStatusError("Cannot migrate disk when guest in status %s", guest.Status)
+		}
+		host = guest.GetHost()
+	} else {
+		host = storage.GetMasterHost()
+	}
+	if host == nil || host.HostType != api.HOST_TYPE_HYPERVISOR {
+		return nil, httperrors.NewUnsupportOperationError("Only support migrate disk storage on kvm host")
+	}
+	if host.HostStatus != api.HOST_ONLINE {
+		return nil, httperrors.NewInvalidStatusError("host %s is not online", host.Name)
+	}
+	if host.GetHoststorageOfId(targetStorage.Id) == nil {
+		return nil, httperrors.NewInputParameterError("storage %s is not attached to host %s", targetStorage.Name, host.Name)
+	}
+
+	return nil, self.StartDiskMigrateStorageTask(ctx, userCred, host, targetStorage, guest, "")
+}
+
+func (self *SDisk) StartDiskMigrateStorageTask(ctx context.Context, userCred mcclient.TokenCredential, host *SHost, targetStorage *SStorage, guest *SGuest, parentTaskId string) error {
+	params := jsonutils.NewDict()
+	params.Set("host_id", jsonutils.NewString(host.Id))
+	params.Set("source_storage_id", jsonutils.NewString(self.StorageId))
+	params.Set("target_storage_id", jsonutils.NewString(targetStorage.Id))
+	if guest != nil {
+		params.Set("guest_id", jsonutils.NewString(guest.Id))
+		guest.SetStatus(userCred, api.VM_MIGRATE_DISK_STORAGE, fmt.Sprintf("migrate disk %s to storage %s", self.Name, targetStorage.Name))
+	}
+	self.SetStatus(userCred, api.DISK_START_MIGRATE_STORAGE, "")
+	task, err := taskman.TaskManager.NewTask(ctx, "DiskMigrateStorageTask", self, userCred, params, parentTaskId, "", nil)
+	if err != nil {
+		return err
+	}
+	task.ScheduleRun(nil)
+	return nil
+}
+
 func (disk *SDisk) getHypervisor() string {
 	storage := disk.GetStorage()
 	if storage != nil {
@@ -1990,7 +2079,7 @@ func (self *SDisk) getMoreDetails(ctx context.Context, userCred mcclient.TokenCr
 	storage := self.GetStorage()
 	if storage != nil {
 		manualSnapshotCount, _ := self.GetManualSnapshotCount()
-		if utils.IsInStringArray(storage.StorageType, append(api.SHARED_FILE_STORAGE, api.STORAGE_LOCAL)) {
+		if utils.IsInStringArray(storage.StorageType, append(api.SHARED_FILE_STORAGE, api.STORAGE_LOCAL, api.STORAGE_LVM)) {
 			out.ManualSnapshotCount = manualSnapshotCount
 			out.MaxManualSnapshotCount = options.Options.DefaultMaxManualSnapshotCount
 		}
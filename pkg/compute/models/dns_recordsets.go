@@ -19,6 +19,7 @@ import (
 	"database/sql"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"yunion.io/x/jsonutils"
@@ -80,11 +81,65 @@ type SDnsRecordSetValidateInfo struct {
 	TrafficPolicies []api.DnsRecordPolicy
 }
 
+var onPremiseDnsTypes = []cloudprovider.TDnsType{
+	cloudprovider.DnsTypeA,
+	cloudprovider.DnsTypeAAAA,
+	cloudprovider.DnsTypeCAA,
+	cloudprovider.DnsTypeCNAME,
+	cloudprovider.DnsTypeMX,
+	cloudprovider.DnsTypeNS,
+	cloudprovider.DnsTypeSRV,
+	cloudprovider.DnsTypeTXT,
+	cloudprovider.DnsTypePTR,
+}
+
+// validateOnPremiseDnsrecordPolicy validates traffic policy of private zone
+// records served by region dns.  Geo location policy value is the id or
+// name of the availability zone where the querying guest runs, weighted
+// policy value is the integer weight
+func validateOnPremiseDnsrecordPolicy(dnsType string, dnsZone *SDnsZone, policy api.DnsRecordPolicy) error {
+	if dnsZone.ZoneType != string(cloudprovider.PrivateZone) {
+		return httperrors.NewNotSupportedError("%s %s not supported", policy.Provider, dnsZone.ZoneType)
+	}
+	if ok, _ := utils.InArray(cloudprovider.TDnsType(dnsType), onPremiseDnsTypes); !ok {
+		return httperrors.NewNotSupportedError("%s %s not supported dns type %s", policy.Provider, dnsZone.ZoneType, dnsType)
+	}
+	switch cloudprovider.TDnsPolicyType(policy.PolicyType) {
+	case cloudprovider.DnsPolicyTypeSimple:
+	case cloudprovider.DnsPolicyTypeWeighted:
+		weight, err := strconv.Atoi(policy.PolicyValue)
+		if err != nil || weight < 0 || weight > 100 {
+			return httperrors.NewInputParameterError("invalid %s weight %s, range limited to [0,100]", policy.Provider, policy.PolicyValue)
+		}
+	case cloudprovider.DnsPolicyTypeByGeoLocation:
+		if len(policy.PolicyValue) == 0 || policy.PolicyValue == string(cloudprovider.DnsPolicyValueDefault) {
+			break
+		}
+		_, err := ZoneManager.FetchByIdOrName(nil, policy.PolicyValue)
+		if err != nil {
+			if errors.Cause(err) == sql.ErrNoRows {
+				return httperrors.NewResourceNotFoundError2("zone", policy.PolicyValue)
+			}
+			return httperrors.NewGeneralError(err)
+		}
+	default:
+		return httperrors.NewNotSupportedError("%s %s not supported policy type %s", policy.Provider, dnsZone.ZoneType, policy.PolicyType)
+	}
+	return nil
+}
+
 func validateDnsrecordPolicy(dnsType string, dnsZone *SDnsZone, trafficPolicies []api.DnsRecordPolicy) error {
 	for _, policy := range trafficPolicies {
 		if len(policy.Provider) == 0 {
 			return httperrors.NewGeneralError(fmt.Errorf("missing traffic policy provider"))
 		}
+		if policy.Provider == api.CLOUD_PROVIDER_ONECLOUD {
+			err := validateOnPremiseDnsrecordPolicy(dnsType, dnsZone, policy)
+			if err != nil {
+				return err
+			}
+			continue
+		}
 		factory, err := cloudprovider.GetProviderFactory(policy.Provider)
 		if err != nil {
 			return httperrors.NewGeneralError(errors.Wrapf(err, "invalid provider %s for traffic policy", policy.Provider))
@@ -785,34 +840,11 @@ func (self *SDnsRecordSet) PerformSetTrafficPolicies(ctx context.Context, userCr
 	if err != nil {
 		return nil, httperrors.NewGeneralError(errors.Wrapf(err, "GetDnsZone"))
 	}
+	err = validateDnsrecordPolicy(self.DnsType, dnsZone, input.TrafficPolicies)
+	if err != nil {
+		return nil, err
+	}
 	for _, policy := range input.TrafficPolicies {
-		if len(policy.Provider) == 0 {
-			return nil, httperrors.NewGeneralError(fmt.Errorf("missing traffic policy provider"))
-		}
-		factory, err := cloudprovider.GetProviderFactory(policy.Provider)
-		if err != nil {
-			return nil, httperrors.NewGeneralError(errors.Wrapf(err, "invalid provider %s for traffic policy", policy.Provider))
-		}
-		_dnsTypes := factory.GetSupportedDnsTypes()
-		dnsTypes, _ := _dnsTypes[cloudprovider.TDnsZoneType(dnsZone.ZoneType)]
-		if ok, _ := utils.InArray(cloudprovider.TDnsType(self.DnsType), dnsTypes); !ok {
-			return nil, httperrors.NewNotSupportedError("%s %s not supported dns type %s", policy.Provider, dnsZone.ZoneType, self.DnsType)
-		}
-		_policyTypes := factory.GetSupportedDnsPolicyTypes()
-		policyTypes, _ := _policyTypes[cloudprovider.TDnsZoneType(dnsZone.ZoneType)]
-		if ok, _ := utils.InArray(cloudprovider.TDnsPolicyType(policy.PolicyType), policyTypes); !ok {
-			return nil, httperrors.NewNotSupportedError("%s %s not supported policy type %s", policy.Provider, dnsZone.ZoneType, policy.PolicyType)
-		}
-		_policyValues := factory.GetSupportedDnsPolicyValues()
-		policyValues, _ := _policyValues[cloudprovider.TDnsPolicyType(policy.PolicyType)]
-		if len(policyValues) > 0 {
-			if len(policy.PolicyValue) == 0 {
-				return nil, httperrors.NewMissingParameterError(fmt.Sprintf("missing %s policy value", policy.Provider))
-			}
-			if isIn, _ := utils.InArray(cloudprovider.TDnsPolicyValue(policy.PolicyValue), policyValues); !isIn {
-				return nil, httperrors.NewNotSupportedError("%s %s %s not support %s", policy.Provider, dnsZone.ZoneType, policy.PolicyType, policy.PolicyValue)
-			}
-		}
 		err = self.setTrafficPolicy(ctx, userCred, policy.Provider, cloudprovider.TDnsPolicyType(policy.PolicyType), cloudprovider.TDnsPolicyValue(policy.PolicyValue), policy.PolicyOptions)
 		if err != nil {
 			return nil, httperrors.NewGeneralError(errors.Wrapf(err, "setTrafficPolicy"))
@@ -144,11 +144,18 @@ func (man *SNatDEntryManager) ValidateCreateData(ctx context.Context, userCred m
 		return nil, httperrors.NewInputParameterError("%v", err)
 	}
 
+	natgatewayObj, err := NatGatewayManager.FetchById(input.NatgatewayId)
+	if err != nil {
+		return nil, err
+	}
+	natgateway := natgatewayObj.(*SNatGateway)
+
 	// check that eip is suitable
 	if len(eip.AssociateId) != 0 {
 		if eip.AssociateId != input.NatgatewayId {
 			return nil, httperrors.NewInputParameterError("eip has been binding to another instance")
-		} else if !man.canBindIP(eip.IpAddr) {
+		} else if len(natgateway.ExternalId) > 0 && !man.canBindIP(eip.IpAddr) {
+			// on-premise nat gateway can share one eip among snat and dnat rules
 			return nil, httperrors.NewInputParameterError("eip has been binding to snat rules")
 		}
 	} else {
@@ -128,8 +128,84 @@ func (man *SNatGatewayManager) QueryDistinctExtraField(q *sqlchemy.SQuery, field
 	return q, httperrors.ErrNotFound
 }
 
-func (man *SNatGatewayManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data *jsonutils.JSONDict) (*jsonutils.JSONDict, error) {
-	return nil, httperrors.NewNotImplementedError("Not Implemented")
+func (man *SNatGatewayManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, input api.NatgatewayCreateInput) (api.NatgatewayCreateInput, error) {
+	if len(input.VpcId) == 0 {
+		return input, httperrors.NewMissingParameterError("vpc_id")
+	}
+	vpc, vpcInput, err := ValidateVpcResourceInput(userCred, input.VpcResourceInput)
+	if err != nil {
+		return input, err
+	}
+	input.VpcResourceInput = vpcInput
+	region, err := vpc.GetRegion()
+	if err != nil {
+		return input, httperrors.NewGeneralError(err)
+	}
+	input, err = region.GetDriver().ValidateCreateNatGatewayData(ctx, userCred, ownerId, input)
+	if err != nil {
+		return input, err
+	}
+	input.StatusInfrasResourceBaseCreateInput, err = man.SStatusInfrasResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, input.StatusInfrasResourceBaseCreateInput)
+	if err != nil {
+		return input, err
+	}
+	input.Status = api.NAT_STATUS_ALLOCATE
+	return input, nil
+}
+
+func (self *SNatGateway) PostCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data jsonutils.JSONObject) {
+	self.SStatusInfrasResourceBase.PostCreate(ctx, userCred, ownerId, query, data)
+
+	params := jsonutils.NewDict()
+	if eip, _ := data.GetString("eip"); len(eip) > 0 {
+		params.Set("eip", jsonutils.NewString(eip))
+	}
+	task, err := taskman.TaskManager.NewTask(ctx, "NatGatewayCreateTask", self, userCred, params, "", "", nil)
+	if err != nil {
+		log.Errorf("NatGatewayCreateTask newTask error %s", err)
+		self.SetStatus(userCred, api.NAT_STATUS_FAILED, err.Error())
+	} else {
+		task.ScheduleRun(nil)
+	}
+}
+
+// on-premise nat gateway has no cloud counterpart.  Its entries and eips are
+// released right away before the record itself is deleted
+func (self *SNatGateway) CustomizeDelete(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) error {
+	if len(self.ExternalId) > 0 {
+		return nil
+	}
+	dnats, err := self.GetDTable()
+	if err != nil {
+		return errors.Wrap(err, "fetch dnat table failed")
+	}
+	for i := range dnats {
+		err = dnats[i].RealDelete(ctx, userCred)
+		if err != nil {
+			return errors.Wrapf(err, "delete dnat %s failed", dnats[i].GetId())
+		}
+	}
+	snats, err := self.GetSTable()
+	if err != nil {
+		return errors.Wrap(err, "fetch snat table failed")
+	}
+	for i := range snats {
+		err = snats[i].RealDelete(ctx, userCred)
+		if err != nil {
+			return errors.Wrapf(err, "delete snat %s failed", snats[i].GetId())
+		}
+	}
+	eips, err := self.GetEips()
+	if err != nil {
+		return errors.Wrap(err, "fetch eips failed")
+	}
+	for i := range eips {
+		err = eips[i].Dissociate(ctx, userCred)
+		if err != nil {
+			return errors.Wrapf(err, "dissociate eip %s failed", eips[i].IpAddr)
+		}
+	}
+	return nil
 }
 
 func (self *SNatGateway) AllowPerformSnatResources(ctx context.Context, userCred mcclient.TokenCredential,
@@ -619,7 +695,10 @@ func (man *SNatEntryManager) ListItemFilter(
 
 	q, err = managedResourceFilterByAccount(q, query.ManagedResourceListInput, "natgateway_id", func() *sqlchemy.SQuery {
 		natgateways := NatGatewayManager.Query().SubQuery()
-		return natgateways.Query(natgateways.Field("id"))
+		vpcs := VpcManager.Query().SubQuery()
+		// natgateways has no manager_id column, it comes from the vpc
+		return natgateways.Query(natgateways.Field("id")).
+			Join(vpcs, sqlchemy.Equals(vpcs.Field("id"), natgateways.Field("vpc_id")))
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "managedResourceFilterByAccount")
@@ -164,23 +164,24 @@ func (man *SNatSEntryManager) ValidateCreateData(ctx context.Context, userCred m
 		return nil, httperrors.NewInputParameterError("Only one of that sourceCIDR and netword_id is needed")
 	}
 
+	// get natgateway
+	natgatewayObj, err := NatGatewayManager.FetchById(input.NatgatewayId)
+	if err != nil {
+		return nil, err
+	}
+	natgateway := natgatewayObj.(*SNatGateway)
+	// get vpc
+	vpc := natgateway.GetVpc()
+	if vpc == nil {
+		return nil, errors.Wrap(httperrors.ErrBadRequest, "invalid natgateway vpc")
+	}
+
 	if len(input.SourceCidr) != 0 {
 		//check sourceCidr and convert to netutils.IPV4Range
 		sourceIPV4Range, err := newIPv4RangeFromCIDR(input.SourceCidr)
 		if err != nil {
 			return nil, httperrors.NewInputParameterError("%v", err)
 		}
-		// get natgateway
-		model, err := man.FetchById(input.NatgatewayId)
-		if err != nil {
-			return nil, err
-		}
-		natgateway := model.(*SNatGateway)
-		// get vpc
-		vpc := natgateway.GetVpc()
-		if vpc == nil {
-			return nil, errors.Wrap(httperrors.ErrBadRequest, "invalid natgateway vpc")
-		}
 
 		vpcIPV4Range, err := newIPv4RangeFromCIDR(vpc.CidrBlock)
 		if err != nil {
@@ -196,6 +197,9 @@ func (man *SNatSEntryManager) ValidateCreateData(ctx context.Context, userCred m
 		if err != nil {
 			return nil, httperrors.NewInputParameterError("%v", err)
 		}
+		if networkVpc := network.GetVpc(); networkVpc == nil || networkVpc.Id != vpc.Id {
+			return nil, httperrors.NewInputParameterError("network %s is not in vpc %s", network.Name, vpc.Name)
+		}
 		data.Add(jsonutils.NewString(network.GetExternalId()), "network_ext_id")
 	}
 
@@ -215,7 +219,8 @@ func (man *SNatSEntryManager) ValidateCreateData(ctx context.Context, userCred m
 	if len(eip.AssociateId) != 0 {
 		if eip.AssociateId != input.NatgatewayId {
 			return nil, httperrors.NewInputParameterError("eip has been binding to another instance")
-		} else if !man.canBindIP(eip.IpAddr) {
+		} else if len(natgateway.ExternalId) > 0 && !man.canBindIP(eip.IpAddr) {
+			// on-premise nat gateway can share one eip among snat and dnat rules
 			return nil, httperrors.NewInputParameterError("eip has been binding to dnat rules")
 		}
 	} else {
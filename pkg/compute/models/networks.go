@@ -1529,6 +1529,22 @@ func (manager *SNetworkManager) ValidateCreateData(ctx context.Context, userCred
 			return input, httperrors.NewInputParameterError("Conflict address space with existing networks in vpc %q", vpc.GetName())
 		}
 	}
+	if region.Provider == api.CLOUD_PROVIDER_ONECLOUD && vpc.Id != api.DEFAULT_VPC_ID {
+		// networks of peered vpcs are routed to each other
+		peerVpcs, err := vpc.GetPeerVpcs()
+		if err != nil {
+			return input, httperrors.NewInternalServerError("fail to GetPeerVpcs of vpc: %v", err)
+		}
+		for i := range peerVpcs {
+			nets, err := peerVpcs[i].GetNetworks()
+			if err != nil {
+				return input, httperrors.NewInternalServerError("fail to GetNetworks of peer vpc: %v", err)
+			}
+			if isOverlapNetworks(nets, ipStart, ipEnd) {
+				return input, httperrors.NewInputParameterError("Conflict address space with existing networks in peer vpc %q", peerVpcs[i].GetName())
+			}
+		}
+	}
 
 	input.GuestIpStart = ipStart.String()
 	input.GuestIpEnd = ipEnd.String()
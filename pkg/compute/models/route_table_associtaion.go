@@ -16,7 +16,10 @@ package models
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 
+	"yunion.io/x/jsonutils"
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/sqlchemy"
 
@@ -24,6 +27,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
 	"yunion.io/x/onecloud/pkg/cloudprovider"
+	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/util/stringutils2"
 )
@@ -63,6 +67,89 @@ func (manager *SRouteTableAssociationManager) GetContextManagers() [][]db.IModel
 	}
 }
 
+// 关联路由表到子网, 仅适用于本地VPC
+func (manager *SRouteTableAssociationManager) ValidateCreateData(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	ownerId mcclient.IIdentityProvider,
+	query jsonutils.JSONObject,
+	input api.RouteTableAssociationCreateInput,
+) (api.RouteTableAssociationCreateInput, error) {
+	if len(input.RouteTableId) == 0 {
+		return input, httperrors.NewMissingParameterError("route_table_id")
+	}
+	_routeTable, err := RouteTableManager.FetchByIdOrName(userCred, input.RouteTableId)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return input, httperrors.NewResourceNotFoundError2("route_table", input.RouteTableId)
+		}
+		return input, httperrors.NewGeneralError(err)
+	}
+	routeTable := _routeTable.(*SRouteTable)
+	input.RouteTableId = routeTable.Id
+	vpc := routeTable.GetVpc()
+	if vpc == nil {
+		return input, httperrors.NewInternalServerError("route table %s has no vpc", routeTable.Name)
+	}
+	if vpc.IsManaged() || vpc.Id == api.DEFAULT_VPC_ID {
+		return input, httperrors.NewUnsupportOperationError("only route tables of onpremise vpc can be associated")
+	}
+
+	if len(input.AssociationType) == 0 {
+		input.AssociationType = string(cloudprovider.RouteTableAssociaToSubnet)
+	}
+	if input.AssociationType != string(cloudprovider.RouteTableAssociaToSubnet) {
+		return input, httperrors.NewInputParameterError("unsupported association type %s", input.AssociationType)
+	}
+	if len(input.AssociatedResourceId) == 0 {
+		return input, httperrors.NewMissingParameterError("associated_resource_id")
+	}
+	_network, err := NetworkManager.FetchByIdOrName(userCred, input.AssociatedResourceId)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return input, httperrors.NewResourceNotFoundError2("network", input.AssociatedResourceId)
+		}
+		return input, httperrors.NewGeneralError(err)
+	}
+	network := _network.(*SNetwork)
+	if netVpc := network.GetVpc(); netVpc == nil || netVpc.Id != vpc.Id {
+		return input, httperrors.NewInputParameterError("network %s is not in vpc %s", network.Name, vpc.Name)
+	}
+	input.AssociatedResourceId = network.Id
+
+	if routeTable.Routes != nil {
+		if err := routeTable.Routes.ValidateAssociated(); err != nil {
+			return input, err
+		}
+	}
+	networks, err := vpc.GetNetworks()
+	if err != nil {
+		return input, httperrors.NewGeneralError(err)
+	}
+	for i := range networks {
+		if networks[i].GuestIpMask < network.GuestIpMask {
+			return input, httperrors.NewInputParameterError("network %s has a longer prefix than network %s of the vpc", network.Name, networks[i].Name)
+		}
+	}
+
+	cnt, err := manager.Query().Equals("associated_resource_id", network.Id).CountWithError()
+	if err != nil {
+		return input, httperrors.NewGeneralError(err)
+	}
+	if cnt > 0 {
+		return input, httperrors.NewDuplicateResourceError("network %s already associated with a route table", network.Name)
+	}
+
+	if len(input.Name) == 0 {
+		input.Name = fmt.Sprintf("%s-%s", routeTable.Name, network.Name)
+	}
+	input.StatusStandaloneResourceCreateInput, err = manager.SStatusStandaloneResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, input.StatusStandaloneResourceCreateInput)
+	if err != nil {
+		return input, errors.Wrap(err, "SStatusStandaloneResourceBaseManager.ValidateCreateData")
+	}
+	return input, nil
+}
+
 func (manager *SRouteTableAssociationManager) ListItemFilter(
 	ctx context.Context,
 	q *sqlchemy.SQuery,
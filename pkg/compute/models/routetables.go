@@ -221,6 +221,11 @@ func (rt *SRouteTable) ValidateUpdateData(
 	if err != nil {
 		return input, errors.Wrap(err, "RouteTableManager.validateRoutes")
 	}
+	if input.Routes != nil {
+		if err := rt.validateAssociatedRoutes(*input.Routes); err != nil {
+			return input, err
+		}
+	}
 	input.StatusInfrasResourceBaseUpdateInput, err = rt.SStatusInfrasResourceBase.ValidateUpdateData(ctx, userCred, query, input.StatusInfrasResourceBaseUpdateInput)
 	if err != nil {
 		return input, errors.Wrap(err, "SStatusInfrasResourceBase.ValidateUpdateData")
@@ -228,6 +233,23 @@ func (rt *SRouteTable) ValidateUpdateData(
 	return input, nil
 }
 
+// validateAssociatedRoutes only allows the default route in route tables of
+// onpremise vpc associated with networks
+func (rt *SRouteTable) validateAssociatedRoutes(routes api.SRoutes) error {
+	vpc := rt.GetVpc()
+	if vpc == nil || vpc.IsManaged() {
+		return nil
+	}
+	cnt, err := RouteTableAssociationManager.Query().Equals("route_table_id", rt.Id).CountWithError()
+	if err != nil {
+		return httperrors.NewGeneralError(err)
+	}
+	if cnt == 0 {
+		return nil
+	}
+	return routes.ValidateAssociated()
+}
+
 func (rt *SRouteTable) AllowPerformAddRoutes(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data *jsonutils.JSONDict) bool {
 	return rt.IsOwner(userCred) || db.IsAdminAllowPerform(userCred, rt, "add-routes")
 }
@@ -265,6 +287,9 @@ func (rt *SRouteTable) PerformAddRoutes(ctx context.Context, userCred mcclient.T
 			}
 		}
 	}
+	if err := rt.validateAssociatedRoutes(routes); err != nil {
+		return nil, err
+	}
 	_, err := db.Update(rt, func() error {
 		rt.Routes = &routes
 		return nil
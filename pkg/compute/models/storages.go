@@ -87,6 +87,8 @@ type SStorage struct {
 	Cmtbound float32 `nullable:"true" default:"1" list:"domain" update:"domain"`
 	// 存储配置信息
 	StorageConf jsonutils.JSONObject `nullable:"true" get:"domain" list:"domain" update:"domain"`
+	// 加密保存的敏感存储配置, 不对外展示, 仅下发给宿主机
+	SecretConf string `length:"0" charset:"ascii" nullable:"true"`
 
 	// 存储缓存Id
 	StoragecacheId string `width:"36" charset:"ascii" nullable:"true" list:"domain" get:"domain" update:"domain" create:"domain_optional"`
@@ -121,6 +123,16 @@ func (self *SStorage) AllowGetDetails(ctx context.Context, userCred mcclient.Tok
 	return db.IsAdminAllowGet(userCred, self)
 }
 
+func (self *SStorage) AllowGetDetailsHostStorageConf(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) bool {
+	return db.IsAdminAllowGetSpec(userCred, self, "host-storage-conf")
+}
+
+// GetDetailsHostStorageConf is fetched by the host agent to mount the storage
+// on start, storage_conf in the details of storages leaves the secrets out
+func (self *SStorage) GetDetailsHostStorageConf(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	return self.GetHostStorageConf(), nil
+}
+
 func (self *SStorage) AllowUpdateItem(ctx context.Context, userCred mcclient.TokenCredential) bool {
 	return db.IsAdminAllowUpdate(userCred, self)
 }
@@ -273,6 +285,44 @@ func (self *SStorage) PostCreate(ctx context.Context, userCred mcclient.TokenCre
 	}
 }
 
+// SetSecretConf saves the sensitive options of storage conf encrypted, they
+// are never shown by the API and only sent to the hosts
+func (self *SStorage) SetSecretConf(conf *jsonutils.JSONDict) error {
+	secret, err := utils.EncryptAESBase64(self.Id, conf.String())
+	if err != nil {
+		return errors.Wrap(err, "EncryptAESBase64")
+	}
+	_, err = db.Update(self, func() error {
+		self.SecretConf = secret
+		return nil
+	})
+	return err
+}
+
+// GetHostStorageConf returns the storage conf sent to the hosts, including
+// the decrypted sensitive options
+func (self *SStorage) GetHostStorageConf() jsonutils.JSONObject {
+	if len(self.SecretConf) == 0 {
+		return self.StorageConf
+	}
+	secret, err := utils.DescryptAESBase64(self.Id, self.SecretConf)
+	if err != nil {
+		log.Errorf("unable to decrypt secret conf of storage %s: %v", self.Name, err)
+		return self.StorageConf
+	}
+	secretConf, err := jsonutils.ParseString(secret)
+	if err != nil {
+		log.Errorf("invalid secret conf of storage %s: %v", self.Name, err)
+		return self.StorageConf
+	}
+	conf := jsonutils.NewDict()
+	if self.StorageConf != nil {
+		conf.Update(self.StorageConf)
+	}
+	conf.Update(secretConf)
+	return conf
+}
+
 func (self *SStorage) SetStatus(userCred mcclient.TokenCredential, status string, reason string) error {
 	if self.Status == status {
 		return nil
@@ -1577,7 +1627,9 @@ func (manager *SStorageManager) StorageSnapshotsRecycle(ctx context.Context, use
 	storages := make([]SStorage, 0)
 	err := manager.Query().Equals("enabled", true).
 		In("status", []string{api.STORAGE_ENABLED, api.STORAGE_ONLINE}).
-		In("storage_type", api.SHARED_FILE_STORAGE).All(&storages)
+		In("storage_type", api.SHARED_FILE_STORAGE).
+		// datastores of esxi and other managed hosts are not served by host agents
+		IsNullOrEmpty("manager_id").All(&storages)
 	if err != nil {
 		log.Errorf("Get shared file storage failed %s", err)
 		return
@@ -26,10 +26,13 @@ import (
 
 	api "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/cloudcommon/policy"
 	"yunion.io/x/onecloud/pkg/cloudprovider"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
 	"yunion.io/x/onecloud/pkg/util/stringutils2"
 )
 
@@ -63,6 +66,9 @@ type SVpcPeeringConnection struct {
 	PeerVpcId        string `width:"36" charset:"ascii" nullable:"true" list:"domain" create:"required" json:"peer_vpc_id"`
 	PeerAccountId    string `width:"36" charset:"ascii" nullable:"true" list:"domain"`
 	Bandwidth        int    `nullable:"false" default:"0" list:"user" create:"optional"`
+
+	// 本地VPC对等连接的链路序号
+	LinkIndex int `nullable:"false" default:"0" list:"domain"`
 }
 
 func (manager *SVpcPeeringConnectionManager) GetContextManagers() [][]db.IModelManager {
@@ -107,6 +113,25 @@ func (manager *SVpcPeeringConnectionManager) ListItemFilter(
 	return q, nil
 }
 
+// FilterByOwner lets the domain of peer vpc see peering connections to its
+// vpcs, which are owned by the domain of the requester vpc
+func (manager *SVpcPeeringConnectionManager) FilterByOwner(q *sqlchemy.SQuery, owner mcclient.IIdentityProvider, scope rbacutils.TRbacScope) *sqlchemy.SQuery {
+	if owner == nil || len(owner.GetProjectDomainId()) == 0 {
+		return manager.SEnabledStatusInfrasResourceBaseManager.FilterByOwner(q, owner, scope)
+	}
+	switch scope {
+	case rbacutils.ScopeProject, rbacutils.ScopeDomain:
+		ownedQ := manager.SEnabledStatusInfrasResourceBaseManager.FilterByOwner(manager.Query("id"), owner, scope)
+		peerVpcQ := VpcManager.Query("id").Equals("domain_id", owner.GetProjectDomainId())
+		q = q.Filter(sqlchemy.OR(
+			sqlchemy.In(q.Field("id"), ownedQ.SubQuery()),
+			sqlchemy.In(q.Field("peer_vpc_id"), peerVpcQ.SubQuery()),
+		))
+		return q
+	}
+	return manager.SEnabledStatusInfrasResourceBaseManager.FilterByOwner(q, owner, scope)
+}
+
 // 创建
 func (manager *SVpcPeeringConnectionManager) ValidateCreateData(
 	ctx context.Context,
@@ -143,60 +168,67 @@ func (manager *SVpcPeeringConnectionManager) ValidateCreateData(
 	}
 	peerVpc := _peerVpc.(*SVpc)
 
-	// get account,providerFactory
-	account := vpc.GetCloudaccount()
-	peerAccount := peerVpc.GetCloudaccount()
-	if account.Provider != peerAccount.Provider {
-		return input, httperrors.NewNotSupportedError("vpc on different cloudprovider peering is not supported")
-	}
+	if !vpc.IsManaged() || !peerVpc.IsManaged() {
+		err := manager.validateOnPremiseCreateData(vpc, peerVpc)
+		if err != nil {
+			return input, err
+		}
+	} else {
+		// get account,providerFactory
+		account := vpc.GetCloudaccount()
+		peerAccount := peerVpc.GetCloudaccount()
+		if account.Provider != peerAccount.Provider {
+			return input, httperrors.NewNotSupportedError("vpc on different cloudprovider peering is not supported")
+		}
 
-	factory, err := cloudprovider.GetProviderFactory(account.Provider)
-	if err != nil {
-		return input, httperrors.NewGeneralError(errors.Wrapf(err, "cloudprovider.GetProviderFactory(%s)", account.Provider))
-	}
+		factory, err := cloudprovider.GetProviderFactory(account.Provider)
+		if err != nil {
+			return input, httperrors.NewGeneralError(errors.Wrapf(err, "cloudprovider.GetProviderFactory(%s)", account.Provider))
+		}
 
-	// check vpc ip range overlap
-	if !factory.IsSupportVpcPeeringVpcCidrOverlap() {
-		vpcIpv4Ranges := []netutils.IPV4AddrRange{}
-		peervpcIpv4Ranges := []netutils.IPV4AddrRange{}
-		vpcCidrBlocks := strings.Split(vpc.CidrBlock, ",")
-		peervpcCidrBlocks := strings.Split(peerVpc.CidrBlock, ",")
-		for i := range vpcCidrBlocks {
-			vpcIpv4Range, err := newIPv4RangeFromCIDR(vpcCidrBlocks[i])
-			if err != nil {
-				return input, httperrors.NewGeneralError(errors.Wrapf(err, "convert vpc cidr %s to ipv4range error", vpcCidrBlocks[i]))
+		// check vpc ip range overlap
+		if !factory.IsSupportVpcPeeringVpcCidrOverlap() {
+			vpcIpv4Ranges := []netutils.IPV4AddrRange{}
+			peervpcIpv4Ranges := []netutils.IPV4AddrRange{}
+			vpcCidrBlocks := strings.Split(vpc.CidrBlock, ",")
+			peervpcCidrBlocks := strings.Split(peerVpc.CidrBlock, ",")
+			for i := range vpcCidrBlocks {
+				vpcIpv4Range, err := newIPv4RangeFromCIDR(vpcCidrBlocks[i])
+				if err != nil {
+					return input, httperrors.NewGeneralError(errors.Wrapf(err, "convert vpc cidr %s to ipv4range error", vpcCidrBlocks[i]))
+				}
+				vpcIpv4Ranges = append(vpcIpv4Ranges, vpcIpv4Range)
 			}
-			vpcIpv4Ranges = append(vpcIpv4Ranges, vpcIpv4Range)
-		}
 
-		for i := range peervpcCidrBlocks {
-			peervpcIpv4Range, err := newIPv4RangeFromCIDR(peervpcCidrBlocks[i])
-			if err != nil {
-				return input, httperrors.NewGeneralError(errors.Wrapf(err, "convert vpc cidr %s to ipv4range error", peervpcCidrBlocks[i]))
+			for i := range peervpcCidrBlocks {
+				peervpcIpv4Range, err := newIPv4RangeFromCIDR(peervpcCidrBlocks[i])
+				if err != nil {
+					return input, httperrors.NewGeneralError(errors.Wrapf(err, "convert vpc cidr %s to ipv4range error", peervpcCidrBlocks[i]))
+				}
+				peervpcIpv4Ranges = append(peervpcIpv4Ranges, peervpcIpv4Range)
 			}
-			peervpcIpv4Ranges = append(peervpcIpv4Ranges, peervpcIpv4Range)
-		}
-		for i := range vpcIpv4Ranges {
-			for j := range peervpcIpv4Ranges {
-				if vpcIpv4Ranges[i].IsOverlap(peervpcIpv4Ranges[j]) {
-					return input, httperrors.NewNotSupportedError("ipv4 range overlap")
+			for i := range vpcIpv4Ranges {
+				for j := range peervpcIpv4Ranges {
+					if vpcIpv4Ranges[i].IsOverlap(peervpcIpv4Ranges[j]) {
+						return input, httperrors.NewNotSupportedError("ipv4 range overlap")
+					}
 				}
 			}
 		}
-	}
 
-	CrossCloudEnv := account.AccessUrl != peerAccount.AccessUrl
-	CrossRegion := vpc.CloudregionId != peerVpc.CloudregionId
-	if CrossCloudEnv && !factory.IsSupportCrossCloudEnvVpcPeering() {
-		return input, httperrors.NewNotSupportedError("cloudprovider %s %s %s %s %s not supported CrossCloud vpcpeering", account.Provider, account.AccessUrl, vpc.CloudregionId, peerAccount.AccessUrl, peerVpc.CloudregionId)
-	}
-	if CrossRegion && !factory.IsSupportCrossRegionVpcPeering() {
-		return input, httperrors.NewNotSupportedError("cloudprovider %s %s %s %s %s not supported CrossRegion vpcpeering", account.Provider, account.AccessUrl, vpc.CloudregionId, peerAccount.AccessUrl, peerVpc.CloudregionId)
-	}
-	if CrossRegion {
-		err := factory.ValidateCrossRegionVpcPeeringBandWidth(input.Bandwidth)
-		if err != nil {
-			return input, err
+		CrossCloudEnv := account.AccessUrl != peerAccount.AccessUrl
+		CrossRegion := vpc.CloudregionId != peerVpc.CloudregionId
+		if CrossCloudEnv && !factory.IsSupportCrossCloudEnvVpcPeering() {
+			return input, httperrors.NewNotSupportedError("cloudprovider %s %s %s %s %s not supported CrossCloud vpcpeering", account.Provider, account.AccessUrl, vpc.CloudregionId, peerAccount.AccessUrl, peerVpc.CloudregionId)
+		}
+		if CrossRegion && !factory.IsSupportCrossRegionVpcPeering() {
+			return input, httperrors.NewNotSupportedError("cloudprovider %s %s %s %s %s not supported CrossRegion vpcpeering", account.Provider, account.AccessUrl, vpc.CloudregionId, peerAccount.AccessUrl, peerVpc.CloudregionId)
+		}
+		if CrossRegion {
+			err := factory.ValidateCrossRegionVpcPeeringBandWidth(input.Bandwidth)
+			if err != nil {
+				return input, err
+			}
 		}
 	}
 
@@ -214,6 +246,86 @@ func (manager *SVpcPeeringConnectionManager) ValidateCreateData(
 	return input, nil
 }
 
+// validateOnPremiseCreateData checks peering between on-premise vpcs, which
+// is realized by vpcagent as a router port pair and routes between the vpc
+// routers
+func (manager *SVpcPeeringConnectionManager) validateOnPremiseCreateData(vpc, peerVpc *SVpc) error {
+	if vpc.IsManaged() || peerVpc.IsManaged() {
+		return httperrors.NewNotSupportedError("peering between on-premise and cloud vpc is not supported")
+	}
+	if vpc.Id == api.DEFAULT_VPC_ID || peerVpc.Id == api.DEFAULT_VPC_ID {
+		return httperrors.NewNotSupportedError("default vpc can not be peered")
+	}
+	if vpc.Id == peerVpc.Id {
+		return httperrors.NewInputParameterError("vpc can not be peered with itself")
+	}
+	if vpc.CloudregionId != peerVpc.CloudregionId {
+		return httperrors.NewNotSupportedError("on-premise vpc peering across region is not supported")
+	}
+
+	// routes between vpcs are for networks, they must not overlap
+	nets, err := vpc.GetNetworks()
+	if err != nil {
+		return httperrors.NewInternalServerError("fail to GetNetworks of vpc: %v", err)
+	}
+	peerNets, err := peerVpc.GetNetworks()
+	if err != nil {
+		return httperrors.NewInternalServerError("fail to GetNetworks of peer vpc: %v", err)
+	}
+	for i := range nets {
+		ipRange := nets[i].getIPRange()
+		if isOverlapNetworks(peerNets, ipRange.StartIp(), ipRange.EndIp()) {
+			return httperrors.NewNotSupportedError("network %s of vpc %s overlaps with networks of vpc %s",
+				nets[i].Name, vpc.Name, peerVpc.Name)
+		}
+	}
+
+	cnt, err := manager.Query().Equals("vpc_id", peerVpc.Id).Equals("peer_vpc_id", vpc.Id).CountWithError()
+	if err != nil {
+		return httperrors.NewGeneralError(err)
+	}
+	if cnt > 0 {
+		return httperrors.NewNotSupportedError("vpc %s and vpc %s have already connected", peerVpc.Name, vpc.Name)
+	}
+	return nil
+}
+
+// AllocateLinkIndex assigns a free link index to on-premise vpc peering
+// connection
+func (self *SVpcPeeringConnection) AllocateLinkIndex(ctx context.Context, userCred mcclient.TokenCredential) error {
+	if self.LinkIndex > 0 {
+		return nil
+	}
+	manager := VpcPeeringConnectionManager
+	lockman.LockClass(ctx, manager, "")
+	defer lockman.ReleaseClass(ctx, manager, "")
+
+	peers := []SVpcPeeringConnection{}
+	q := manager.Query().GT("link_index", 0)
+	err := db.FetchModelObjects(manager, q, &peers)
+	if err != nil {
+		return errors.Wrap(err, "db.FetchModelObjects")
+	}
+	used := map[int]struct{}{}
+	for i := range peers {
+		used[peers[i].LinkIndex] = struct{}{}
+	}
+	for idx := 1; idx <= api.VpcPeeringLinkIndexMax; idx++ {
+		if _, ok := used[idx]; ok {
+			continue
+		}
+		_, err := db.Update(self, func() error {
+			self.LinkIndex = idx
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "db.Update")
+		}
+		return nil
+	}
+	return errors.Error("no free link index for vpc peering")
+}
+
 func (self *SVpcPeeringConnection) PostCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data jsonutils.JSONObject) {
 	params := jsonutils.NewDict()
 	task, err := taskman.TaskManager.NewTask(ctx, "VpcPeeringConnectionCreateTask", self, userCred, params, "", "", nil)
@@ -313,9 +425,77 @@ func (self *SVpcPeeringConnection) AllowPerformSyncstatus(ctx context.Context, u
 
 // 同步状态
 func (self *SVpcPeeringConnection) PerformSyncstatus(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.VpcSyncstatusInput) (jsonutils.JSONObject, error) {
+	if len(self.ExternalId) == 0 {
+		return nil, httperrors.NewUnsupportOperationError("on-premise vpc peering has no status to sync")
+	}
 	return nil, StartResourceSyncStatusTask(ctx, userCred, self, "VpcPeeringConnectionSyncstatusTask", "")
 }
 
+func (self *SVpcPeeringConnection) AllowPerformAccept(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	if db.IsAdminAllowPerform(userCred, self, "accept") {
+		return true
+	}
+	peerVpc, err := self.GetPeerVpc()
+	if err != nil {
+		return false
+	}
+	return peerVpc.DomainId == userCred.GetProjectDomainId() && db.IsDomainAllowPerform(userCred, self, "accept")
+}
+
+// IsDelegatedDomain allows the domain of peer vpc to get and accept the
+// peering connection
+func (self *SVpcPeeringConnection) IsDelegatedDomain(domainId string, action string, extra ...string) bool {
+	switch action {
+	case policy.PolicyActionGet:
+	case policy.PolicyActionPerform:
+		if len(extra) == 0 || extra[0] != "accept" {
+			return false
+		}
+	default:
+		return false
+	}
+	peerVpc, err := self.GetPeerVpc()
+	if err != nil {
+		return false
+	}
+	return peerVpc.DomainId == domainId
+}
+
+// PreCheckPerformAction keeps the requester domain from accepting its own
+// peering connection.  Only the domain of peer vpc or system admin can
+func (self *SVpcPeeringConnection) PreCheckPerformAction(
+	ctx context.Context, userCred mcclient.TokenCredential,
+	action string, query jsonutils.JSONObject, data jsonutils.JSONObject,
+) error {
+	if err := self.SEnabledStatusInfrasResourceBase.PreCheckPerformAction(ctx, userCred, action, query, data); err != nil {
+		return err
+	}
+	if action != "accept" || db.IsAdminAllowPerform(userCred, self, "accept") {
+		return nil
+	}
+	peerVpc, err := self.GetPeerVpc()
+	if err != nil {
+		return httperrors.NewGeneralError(err)
+	}
+	if peerVpc.DomainId != userCred.GetProjectDomainId() {
+		return httperrors.NewForbiddenError("only domain of peer vpc %s(%s) can accept", peerVpc.Name, peerVpc.Id)
+	}
+	return nil
+}
+
+// 接受VPC对等连接
+func (self *SVpcPeeringConnection) PerformAccept(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.VpcPeeringConnectionAcceptInput) (jsonutils.JSONObject, error) {
+	if self.Status != api.VPC_PEERING_CONNECTION_STATUS_PENDING_ACCEPT {
+		return nil, httperrors.NewInvalidStatusError("vpc peering connection status is %s, not %s",
+			self.Status, api.VPC_PEERING_CONNECTION_STATUS_PENDING_ACCEPT)
+	}
+	err := self.SetStatus(userCred, api.VPC_PEERING_CONNECTION_STATUS_ACTIVE, "accept")
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	return nil, nil
+}
+
 func (manager *SVpcPeeringConnectionManager) OrderByExtraFields(
 	ctx context.Context,
 	q *sqlchemy.SQuery,
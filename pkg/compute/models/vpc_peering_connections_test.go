@@ -0,0 +1,81 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
+)
+
+func TestVpcPeeringConnectionFilterByOwner(t *testing.T) {
+	domainAdmin := &mcclient.SSimpleToken{
+		Domain:          "domain-b",
+		DomainId:        "domain-b",
+		User:            "admin",
+		UserId:          "admin",
+		Project:         "project-b",
+		ProjectId:       "project-b",
+		ProjectDomain:   "domain-b",
+		ProjectDomainId: "domain-b",
+		Roles:           "domainadmin",
+		RoleIds:         "domainadmin",
+	}
+	peerVpcCond := "`peer_vpc_id` IN (SELECT"
+	cases := []struct {
+		name    string
+		scope   rbacutils.TRbacScope
+		wantSql []string
+	}{
+		{
+			name:  "domain",
+			scope: rbacutils.ScopeDomain,
+			wantSql: []string{
+				"`domain_id` = ( ? )",
+				peerVpcCond,
+				"FROM `vpcs_tbl`",
+			},
+		},
+		{
+			name:  "project",
+			scope: rbacutils.ScopeProject,
+			wantSql: []string{
+				"`domain_id` = ( ? )",
+				peerVpcCond,
+			},
+		},
+		{
+			name:  "system",
+			scope: rbacutils.ScopeSystem,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			q := VpcPeeringConnectionManager.Query("id")
+			q = VpcPeeringConnectionManager.FilterByOwner(q, domainAdmin, c.scope)
+			sql := q.String()
+			for _, want := range c.wantSql {
+				if !strings.Contains(sql, want) {
+					t.Errorf("FilterByOwner() sql %s, want %s in it", sql, want)
+				}
+			}
+			if len(c.wantSql) == 0 && len(q.Variables()) > 0 {
+				t.Errorf("FilterByOwner() sql %s, want no owner filter", sql)
+			}
+		})
+	}
+}
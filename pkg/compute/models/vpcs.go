@@ -181,13 +181,32 @@ func (self *SVpc) GetAccepterVpcPeeringConnections() ([]SVpcPeeringConnection, e
 	}
 	return vpcPC, nil
 }
+
+// GetPeerVpcs returns vpcs connected with this one by peering connections in
+// either direction
+func (self *SVpc) GetPeerVpcs() ([]SVpc, error) {
+	requesters := self.getRequesterVpcPeeringConnectionQuery().SubQuery()
+	accepters := self.getAccepterVpcPeeringConnectionQuery().SubQuery()
+	q := VpcManager.Query()
+	q = q.Filter(sqlchemy.OR(
+		sqlchemy.In(q.Field("id"), requesters.Query(requesters.Field("peer_vpc_id")).SubQuery()),
+		sqlchemy.In(q.Field("id"), accepters.Query(accepters.Field("vpc_id")).SubQuery()),
+	))
+	vpcs := []SVpc{}
+	err := db.FetchModelObjects(VpcManager, q, &vpcs)
+	if err != nil {
+		return nil, errors.Wrap(err, "db.FetchModelObjects")
+	}
+	return vpcs, nil
+}
+
 func (self *SVpc) GetVpcPeeringConnectionCount() (int, error) {
 	q := self.getRequesterVpcPeeringConnectionQuery()
 	requesterPeerCount, err := q.CountWithError()
 	if err != nil {
 		return 0, err
 	}
-	q = self.getRequesterVpcPeeringConnectionQuery()
+	q = self.getAccepterVpcPeeringConnectionQuery()
 	accepterPeerCount, err := q.CountWithError()
 	if err != nil {
 		return 0, err
@@ -209,6 +209,14 @@ func (self *SBaseRegionDriver) DealNatGatewaySpec(spec string) string {
 	return spec
 }
 
+func (self *SBaseRegionDriver) ValidateCreateNatGatewayData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, input api.NatgatewayCreateInput) (api.NatgatewayCreateInput, error) {
+	return input, httperrors.NewNotImplementedError("Not implement ValidateCreateNatGatewayData")
+}
+
+func (self *SBaseRegionDriver) RequestCreateNatGateway(ctx context.Context, userCred mcclient.TokenCredential, natgateway *models.SNatGateway, task taskman.ITask) error {
+	return fmt.Errorf("Not implement RequestCreateNatGateway")
+}
+
 func (self *SBaseRegionDriver) RequestBingToNatgateway(ctx context.Context, task taskman.ITask,
 	natgateway *models.SNatGateway, needBind bool, eipID string) error {
 
@@ -90,10 +90,12 @@ func (self *SKVMRegionDriver) ValidateCreateLoadbalancerData(ctx context.Context
 		return nil, httperrors.NewInputParameterError("zone info missing")
 	}
 	if vpc.Id != api.DEFAULT_VPC_ID {
-		return nil, httperrors.NewInputParameterError("vpc lb is not allowed for now")
-	}
-
-	if clusterV.Model == nil {
+		// realized by vpcagent as ovn load balancers
+		if clusterV.Model != nil {
+			return nil, httperrors.NewInputParameterError("lbcluster is not applicable to vpc loadbalancer")
+		}
+		data.Set("network_type", jsonutils.NewString(api.LB_NETWORK_TYPE_VPC))
+	} else if clusterV.Model == nil {
 		clusters := models.LoadbalancerClusterManager.FindByZoneId(zone.Id)
 		if len(clusters) == 0 {
 			return nil, httperrors.NewInputParameterError("zone %s(%s) has no lbcluster", zone.Name, zone.Id)
@@ -137,7 +139,9 @@ func (self *SKVMRegionDriver) ValidateCreateLoadbalancerData(ctx context.Context
 	data.Set("cloudregion_id", jsonutils.NewString(region.GetId()))
 	data.Set("zone_id", jsonutils.NewString(zone.GetId()))
 	data.Set("vpc_id", jsonutils.NewString(vpc.GetId()))
-	data.Set("network_type", jsonutils.NewString(api.LB_NETWORK_TYPE_CLASSIC))
+	if vpc.Id == api.DEFAULT_VPC_ID {
+		data.Set("network_type", jsonutils.NewString(api.LB_NETWORK_TYPE_CLASSIC))
+	}
 	data.Set("address_type", jsonutils.NewString(api.LB_ADDR_TYPE_INTRANET))
 	return data, nil
 }
@@ -174,6 +178,10 @@ func (self *SKVMRegionDriver) ValidateCreateLoadbalancerBackendData(ctx context.
 		return nil, err
 	}
 
+	if lb != nil && lb.VpcId != "" && lb.VpcId != api.DEFAULT_VPC_ID && backendType != api.LB_BACKEND_GUEST {
+		return nil, httperrors.NewInputParameterError("vpc loadbalancer only supports backend type %s", api.LB_BACKEND_GUEST)
+	}
+
 	var basename string
 	switch backendType {
 	case api.LB_BACKEND_GUEST:
@@ -496,6 +504,15 @@ func (self *SKVMRegionDriver) ValidateCreateLoadbalancerListenerData(ctx context
 		return nil, err
 	}
 
+	isVpcLb := lb.VpcId != "" && lb.VpcId != api.DEFAULT_VPC_ID
+	if isVpcLb {
+		// ovn load balancers work at layer 4 only
+		if listenerType != api.LB_LISTENER_TYPE_TCP && listenerType != api.LB_LISTENER_TYPE_UDP {
+			return nil, httperrors.NewInputParameterError("vpc loadbalancer only supports %s and %s listener",
+				api.LB_LISTENER_TYPE_TCP, api.LB_LISTENER_TYPE_UDP)
+		}
+	}
+
 	if redirectType := redirectV.Value; redirectType != api.LB_REDIRECT_OFF {
 		if listenerType != api.LB_LISTENER_TYPE_HTTP && listenerType != api.LB_LISTENER_TYPE_HTTPS {
 			return nil, httperrors.NewInputParameterError("redirect can only be enabled for http/https listener")
@@ -531,8 +548,14 @@ func (self *SKVMRegionDriver) ValidateCreateLoadbalancerListenerData(ctx context
 
 	// health check default depends on input parameters
 	checkTypeV := models.LoadbalancerListenerManager.CheckTypeV(listenerType)
+	healthCheckV := validators.NewStringChoicesValidator("health_check", api.LB_BOOL_VALUES)
+	if isVpcLb {
+		healthCheckV.Default(api.LB_BOOL_OFF)
+	} else {
+		healthCheckV.Default(api.LB_BOOL_ON)
+	}
 	keyVHealth := map[string]validators.IValidator{
-		"health_check":      validators.NewStringChoicesValidator("health_check", api.LB_BOOL_VALUES).Default(api.LB_BOOL_ON),
+		"health_check":      healthCheckV,
 		"health_check_type": checkTypeV,
 
 		"health_check_domain":    validators.NewDomainNameValidator("health_check_domain").AllowEmpty(true).Default(""),
@@ -548,6 +571,9 @@ func (self *SKVMRegionDriver) ValidateCreateLoadbalancerListenerData(ctx context
 	if err := RunValidators(keyVHealth, data, false); err != nil {
 		return nil, err
 	}
+	if isVpcLb && healthCheckV.Value == api.LB_BOOL_ON {
+		return nil, httperrors.NewUnsupportOperationError("vpc loadbalancer listener does not support health check")
+	}
 
 	// acl check
 	if err := models.LoadbalancerListenerManager.ValidateAcl(aclStatusV, aclTypeV, aclV, data, api.CLOUD_PROVIDER_ONECLOUD); err != nil {
@@ -650,6 +676,11 @@ func (self *SKVMRegionDriver) ValidateUpdateLoadbalancerListenerData(ctx context
 	if err := RunValidators(keyV, data, true); err != nil {
 		return nil, err
 	}
+	if healthCheck, _ := data.GetString("health_check"); healthCheck == api.LB_BOOL_ON {
+		if lb := lblis.GetLoadbalancer(); lb != nil && lb.VpcId != "" && lb.VpcId != api.DEFAULT_VPC_ID {
+			return nil, httperrors.NewUnsupportOperationError("vpc loadbalancer listener does not support health check")
+		}
+	}
 
 	var (
 		redirectType = redirectV.Value
@@ -1232,9 +1263,87 @@ func (self *SKVMRegionDriver) RequestSyncSnapshotStatus(ctx context.Context, use
 func (self *SKVMRegionDriver) DealNatGatewaySpec(spec string) string {
 	return spec
 }
+
+func (self *SKVMRegionDriver) ValidateCreateNatGatewayData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, input api.NatgatewayCreateInput) (api.NatgatewayCreateInput, error) {
+	vpcObj, err := models.VpcManager.FetchById(input.VpcId)
+	if err != nil {
+		return input, httperrors.NewGeneralError(err)
+	}
+	vpc := vpcObj.(*models.SVpc)
+	if vpc.Id == api.DEFAULT_VPC_ID {
+		return input, httperrors.NewUnsupportOperationError("nat gateway is not supported in default vpc")
+	}
+	switch vpc.ExternalAccessMode {
+	case api.VPC_EXTERNAL_ACCESS_MODE_EIP, api.VPC_EXTERNAL_ACCESS_MODE_EIP_DISTGW:
+	default:
+		return input, httperrors.NewUnsupportOperationError("vpc %s external access mode %q does not support eip",
+			vpc.Name, vpc.ExternalAccessMode)
+	}
+
+	if len(input.Eip) == 0 {
+		return input, httperrors.NewMissingParameterError("eip")
+	}
+	eipObj, err := models.ElasticipManager.FetchByIdOrName(userCred, input.Eip)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return input, httperrors.NewResourceNotFoundError2("eip", input.Eip)
+		}
+		return input, httperrors.NewGeneralError(err)
+	}
+	eip := eipObj.(*models.SElasticip)
+	if len(eip.ManagerId) > 0 || eip.CloudregionId != vpc.CloudregionId {
+		return input, httperrors.NewInputParameterError("eip %s is not in the region of vpc %s", eip.Name, vpc.Name)
+	}
+	if eip.Mode != api.EIP_MODE_STANDALONE_EIP {
+		return input, httperrors.NewInputParameterError("eip %s is not a standalone eip", eip.Name)
+	}
+	if len(eip.AssociateId) > 0 {
+		return input, httperrors.NewInputParameterError("eip %s has been associated with %s %s",
+			eip.Name, eip.AssociateType, eip.AssociateId)
+	}
+	input.Eip = eip.Id
+	return input, nil
+}
+
+func (self *SKVMRegionDriver) RequestCreateNatGateway(ctx context.Context, userCred mcclient.TokenCredential, natgateway *models.SNatGateway, task taskman.ITask) error {
+	taskman.LocalTaskRun(task, func() (jsonutils.JSONObject, error) {
+		eipId, _ := task.GetParams().GetString("eip")
+		if len(eipId) == 0 {
+			return nil, nil
+		}
+		eipObj, err := models.ElasticipManager.FetchById(eipId)
+		if err != nil {
+			return nil, errors.Wrapf(err, "fetch eip %s", eipId)
+		}
+		lockman.LockObject(ctx, eipObj)
+		defer lockman.ReleaseObject(ctx, eipObj)
+		eip := eipObj.(*models.SElasticip)
+		err = eip.AssociateNatGateway(ctx, userCred, natgateway)
+		if err != nil {
+			return nil, errors.Wrapf(err, "associate eip %s", eip.Id)
+		}
+		return nil, nil
+	})
+	return nil
+}
+
 func (self *SKVMRegionDriver) RequestBindIPToNatgateway(ctx context.Context, task taskman.ITask, natgateway *models.SNatGateway,
 	eipId string) error {
 
+	taskman.LocalTaskRun(task, func() (jsonutils.JSONObject, error) {
+		eipObj, err := models.ElasticipManager.FetchById(eipId)
+		if err != nil {
+			return nil, errors.Wrapf(err, "fetch eip %s", eipId)
+		}
+		lockman.LockObject(ctx, eipObj)
+		defer lockman.ReleaseObject(ctx, eipObj)
+		eip := eipObj.(*models.SElasticip)
+		err = eip.AssociateNatGateway(ctx, task.GetUserCred(), natgateway)
+		if err != nil {
+			return nil, errors.Wrapf(err, "associate eip %s", eip.Id)
+		}
+		return nil, nil
+	})
 	return nil
 }
 
@@ -1255,6 +1364,24 @@ func (self *SKVMRegionDriver) RequestPreSnapshotPolicyApply(ctx context.Context,
 }
 
 func (self *SKVMRegionDriver) BindIPToNatgatewayRollback(ctx context.Context, eipId string) error {
+	eipObj, err := models.ElasticipManager.FetchById(eipId)
+	if err != nil {
+		return err
+	}
+	lockman.LockObject(ctx, eipObj)
+	defer lockman.ReleaseObject(ctx, eipObj)
+	eip := eipObj.(*models.SElasticip)
+	if eip.AssociateType != api.EIP_ASSOCIATE_TYPE_NAT_GATEWAY {
+		return nil
+	}
+	_, err = db.Update(eip, func() error {
+		eip.AssociateId = ""
+		eip.AssociateType = ""
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "rollback about binding eip %s failed", eip.Id)
+	}
 	return nil
 }
 
@@ -0,0 +1,112 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagedrivers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/compute/options"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+type SCifsStorageDriver struct {
+	SBaseStorageDriver
+}
+
+func init() {
+	driver := SCifsStorageDriver{}
+	models.RegisterStorageDriver(&driver)
+}
+
+func (self *SCifsStorageDriver) GetStorageType() string {
+	return api.STORAGE_CIFS
+}
+
+func (self *SCifsStorageDriver) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, input *api.StorageCreateInput) error {
+	input.StorageConf = jsonutils.NewDict()
+	if len(input.CifsHost) == 0 {
+		return httperrors.NewMissingParameterError("cifs_host")
+	}
+	if len(input.CifsSharedDir) == 0 {
+		return httperrors.NewMissingParameterError("cifs_shared_dir")
+	}
+	if len(input.CifsPassword) > 0 && len(input.CifsUsername) == 0 {
+		return httperrors.NewMissingParameterError("cifs_username")
+	}
+	// the options are joined by comma when mounting
+	for k, v := range map[string]string{
+		"cifs_username": input.CifsUsername,
+		"cifs_domain":   input.CifsDomain,
+		"cifs_version":  input.CifsVersion,
+	} {
+		if strings.ContainsAny(v, ",\n") {
+			return httperrors.NewInputParameterError("invalid %s %q", k, v)
+		}
+	}
+	if strings.Contains(input.CifsPassword, "\n") {
+		return httperrors.NewInputParameterError("invalid cifs_password")
+	}
+	conf := map[string]string{
+		"cifs_host":       input.CifsHost,
+		"cifs_shared_dir": "/" + strings.Trim(input.CifsSharedDir, "/"),
+	}
+	// cifs_password is saved encrypted in PostCreate
+	for k, v := range map[string]string{
+		"cifs_username": input.CifsUsername,
+		"cifs_domain":   input.CifsDomain,
+		"cifs_version":  input.CifsVersion,
+	} {
+		if len(v) > 0 {
+			conf[k] = v
+		}
+	}
+	input.StorageConf.Update(jsonutils.Marshal(conf))
+	return nil
+}
+
+func (self *SCifsStorageDriver) PostCreate(ctx context.Context, userCred mcclient.TokenCredential, storage *models.SStorage, data jsonutils.JSONObject) {
+	if password, _ := data.GetString("cifs_password"); len(password) > 0 {
+		secret := jsonutils.NewDict()
+		secret.Set("cifs_password", jsonutils.NewString(password))
+		if err := storage.SetSecretConf(secret); err != nil {
+			log.Errorf("save cifs_password of storage %s error: %v", storage.Name, err)
+		}
+	}
+	sc := &models.SStoragecache{}
+	sc.Path = options.Options.DefaultImageCacheDir
+	sc.ExternalId = storage.Id
+	sc.Name = "cifs-" + storage.Name + time.Now().Format("2006-01-02 15:04:05")
+	if err := models.StoragecacheManager.TableSpec().Insert(ctx, sc); err != nil {
+		log.Errorf("insert storagecache for storage %s error: %v", storage.Name, err)
+		return
+	}
+	_, err := db.Update(storage, func() error {
+		storage.StoragecacheId = sc.Id
+		storage.Status = api.STORAGE_ONLINE
+		return nil
+	})
+	if err != nil {
+		log.Errorf("update storagecache info for storage %s error: %v", storage.Name, err)
+	}
+}
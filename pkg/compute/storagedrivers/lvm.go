@@ -0,0 +1,131 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagedrivers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+type SLVMStorageDriver struct {
+	SBaseStorageDriver
+}
+
+func init() {
+	driver := SLVMStorageDriver{}
+	models.RegisterStorageDriver(&driver)
+}
+
+func (self *SLVMStorageDriver) GetStorageType() string {
+	return api.STORAGE_LVM
+}
+
+func (self *SLVMStorageDriver) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, input *api.StorageCreateInput) error {
+	if len(input.LvmVg) == 0 {
+		return httperrors.NewMissingParameterError("lvm_vg")
+	}
+	if len(input.LvmThinPool) == 0 {
+		return httperrors.NewMissingParameterError("lvm_thin_pool")
+	}
+	input.StorageConf = jsonutils.NewDict()
+	input.StorageConf.Update(jsonutils.Marshal(map[string]string{
+		"lvm_vg":        input.LvmVg,
+		"lvm_thin_pool": input.LvmThinPool,
+	}))
+	return nil
+}
+
+func (self *SLVMStorageDriver) PostCreate(ctx context.Context, userCred mcclient.TokenCredential, storage *models.SStorage, data jsonutils.JSONObject) {
+	// base images are cached as read only logical volumes in the thin pool of each storage
+	vg, _ := data.GetString("lvm_vg")
+	pool, _ := data.GetString("lvm_thin_pool")
+	sc := &models.SStoragecache{}
+	sc.SetModelManager(models.StoragecacheManager, sc)
+	sc.Name = fmt.Sprintf("imagecache-%s", storage.Id)
+	sc.Path = fmt.Sprintf("lvm:%s/%s", vg, pool)
+	sc.ExternalId = storage.Id
+	if err := models.StoragecacheManager.TableSpec().Insert(ctx, sc); err != nil {
+		log.Errorf("insert storagecache for storage %s error: %v", storage.Name, err)
+		return
+	}
+	_, err := db.Update(storage, func() error {
+		storage.StoragecacheId = sc.Id
+		storage.Status = api.STORAGE_ONLINE
+		return nil
+	})
+	if err != nil {
+		log.Errorf("update storagecache info for storage %s error: %v", storage.Name, err)
+	}
+}
+
+func (self *SLVMStorageDriver) ValidateSnapshotDelete(ctx context.Context, snapshot *models.SSnapshot) error {
+	return nil
+}
+
+func (self *SLVMStorageDriver) RequestCreateSnapshot(ctx context.Context, snapshot *models.SSnapshot, task taskman.ITask) error {
+	storage := snapshot.GetStorage()
+	host := storage.GetMasterHost()
+	if host == nil {
+		return errors.Errorf("storage %s can't get master host", storage.Id)
+	}
+	url := fmt.Sprintf("%s/disks/%s/snapshot/%s", host.ManagerUri, storage.Id, snapshot.DiskId)
+	header := task.GetTaskRequestHeader()
+	params := jsonutils.NewDict()
+	params.Set("snapshot_id", jsonutils.NewString(snapshot.Id))
+	_, _, err := httputils.JSONRequest(httputils.GetDefaultClient(), ctx, "POST", url, header, params, false)
+	if err != nil {
+		return errors.Wrap(err, "request create snapshot")
+	}
+	return nil
+}
+
+func (self *SLVMStorageDriver) RequestDeleteSnapshot(ctx context.Context, snapshot *models.SSnapshot, task taskman.ITask) error {
+	storage := snapshot.GetStorage()
+	host := storage.GetMasterHost()
+	if host == nil {
+		return errors.Errorf("storage %s can't get master host", storage.Id)
+	}
+	url := fmt.Sprintf("%s/disks/%s/delete-snapshot/%s", host.ManagerUri, storage.Id, snapshot.DiskId)
+	header := task.GetTaskRequestHeader()
+	params := jsonutils.NewDict()
+	params.Set("snapshot_id", jsonutils.NewString(snapshot.Id))
+	_, _, err := httputils.JSONRequest(httputils.GetDefaultClient(), ctx, "POST", url, header, params, false)
+	if err != nil {
+		return errors.Wrap(err, "request delete snapshot")
+	}
+	return nil
+}
+
+// thin snapshots don't depend on each other, they are always out of chain
+func (self *SLVMStorageDriver) SnapshotIsOutOfChain(disk *models.SDisk) bool {
+	return true
+}
+
+func (self *SLVMStorageDriver) OnDiskReset(ctx context.Context, userCred mcclient.TokenCredential, disk *models.SDisk, snapshot *models.SSnapshot, data jsonutils.JSONObject) error {
+	return nil
+}
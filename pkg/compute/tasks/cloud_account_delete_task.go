@@ -25,6 +25,7 @@ import (
 	api "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/cloudcommon/notifyclient"
 	"yunion.io/x/onecloud/pkg/compute/models"
 	"yunion.io/x/onecloud/pkg/util/logclient"
 )
@@ -78,6 +79,10 @@ func (self *CloudAccountDeleteTask) OnAllCloudProviderDeleteComplete(ctx context
 	}
 
 	account.RealDelete(ctx, self.UserCred)
+	notifyclient.EventNotify(ctx, self.UserCred, notifyclient.SEventNotifyParam{
+		Obj:    account,
+		Action: notifyclient.ActionDelete,
+	})
 
 	self.SetStageComplete(ctx, nil)
 
@@ -0,0 +1,203 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+)
+
+type DiskMigrateStorageTask struct {
+	SDiskBaseTask
+}
+
+func init() {
+	taskman.RegisterTask(DiskMigrateStorageTask{})
+}
+
+func (self *DiskMigrateStorageTask) getHost() *models.SHost {
+	hostId, _ := self.Params.GetString("host_id")
+	return models.HostManager.FetchHostById(hostId)
+}
+
+func (self *DiskMigrateStorageTask) getSourceStorage() *models.SStorage {
+	storageId, _ := self.Params.GetString("source_storage_id")
+	return models.StorageManager.FetchStorageById(storageId)
+}
+
+func (self *DiskMigrateStorageTask) getTargetStorage() *models.SStorage {
+	storageId, _ := self.Params.GetString("target_storage_id")
+	return models.StorageManager.FetchStorageById(storageId)
+}
+
+func (self *DiskMigrateStorageTask) getGuest() *models.SGuest {
+	guestId, _ := self.Params.GetString("guest_id")
+	if len(guestId) == 0 {
+		return nil
+	}
+	return models.GuestManager.FetchGuestById(guestId)
+}
+
+func (self *DiskMigrateStorageTask) taskFailed(ctx context.Context, disk *models.SDisk, reason jsonutils.JSONObject) {
+	self.taskFailedWithStatus(ctx, disk, api.DISK_READY, reason)
+}
+
+func (self *DiskMigrateStorageTask) taskFailedWithStatus(ctx context.Context, disk *models.SDisk, status string, reason jsonutils.JSONObject) {
+	disk.SetStatus(self.UserCred, status, reason.String())
+	if guest := self.getGuest(); guest != nil {
+		guest.StartSyncstatus(ctx, self.UserCred, "")
+	}
+	db.OpsLog.LogEvent(disk, db.ACT_MIGRATE_FAIL, reason, self.UserCred)
+	logclient.AddActionLogWithStartable(self, disk, logclient.ACT_MIGRATE, reason, self.UserCred, false)
+	self.SetStageFailed(ctx, reason)
+}
+
+func (self *DiskMigrateStorageTask) OnInit(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+	disk := obj.(*models.SDisk)
+	host := self.getHost()
+	if host == nil || host.HostStatus != api.HOST_ONLINE {
+		self.taskFailed(ctx, disk, jsonutils.NewString("host is not online"))
+		return
+	}
+	targetStorage := self.getTargetStorage()
+	if targetStorage == nil {
+		self.taskFailed(ctx, disk, jsonutils.NewString("target storage not found"))
+		return
+	}
+
+	disk.SetStatus(self.UserCred, api.DISK_MIGRATING_STORAGE, "")
+	log.Infof("Allocating disk %s on storage %s for migration ...", disk.Id, targetStorage.Name)
+	content := jsonutils.NewDict()
+	content.Add(jsonutils.NewString(disk.DiskFormat), "format")
+	content.Add(jsonutils.NewInt(int64(disk.DiskSize)), "size")
+	self.SetStage("OnTargetDiskAllocated", nil)
+	err := host.GetHostDriver().RequestAllocateDiskOnStorage(ctx, self.UserCred, host, targetStorage, disk, self, content)
+	if err != nil {
+		self.taskFailed(ctx, disk, jsonutils.NewString(fmt.Sprintf("RequestAllocateDiskOnStorage: %s", err)))
+	}
+}
+
+func (self *DiskMigrateStorageTask) OnTargetDiskAllocated(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	host := self.getHost()
+	sourceStorage := self.getSourceStorage()
+	targetStorage := self.getTargetStorage()
+	if host == nil || sourceStorage == nil || targetStorage == nil {
+		self.taskFailed(ctx, disk, jsonutils.NewString("host or storage not found"))
+		return
+	}
+	self.SetStage("OnDiskMigrated", nil)
+	err := host.GetHostDriver().RequestMigrateDiskStorage(ctx, host, disk, sourceStorage, targetStorage, self)
+	if err != nil {
+		self.cleanupTargetDisk(ctx, disk, jsonutils.NewString(fmt.Sprintf("RequestMigrateDiskStorage: %s", err)))
+	}
+}
+
+func (self *DiskMigrateStorageTask) OnTargetDiskAllocatedFailed(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	self.taskFailed(ctx, disk, data)
+}
+
+// 迁移失败时删除目标存储上已分配的磁盘，源磁盘保持不变
+func (self *DiskMigrateStorageTask) cleanupTargetDisk(ctx context.Context, disk *models.SDisk, reason jsonutils.JSONObject) {
+	host := self.getHost()
+	targetStorage := self.getTargetStorage()
+	if host == nil || targetStorage == nil {
+		self.taskFailed(ctx, disk, reason)
+		return
+	}
+	params := jsonutils.NewDict()
+	params.Set("reason", reason)
+	self.SetStage("OnTargetDiskCleanup", params)
+	err := host.GetHostDriver().RequestDeallocateDiskOnHost(ctx, host, targetStorage, disk, self)
+	if err != nil {
+		log.Errorf("cleanup disk %s on storage %s: %s", disk.Id, targetStorage.Name, err)
+		self.taskFailed(ctx, disk, reason)
+	}
+}
+
+func (self *DiskMigrateStorageTask) OnTargetDiskCleanup(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	reason, _ := self.Params.Get("reason")
+	self.taskFailed(ctx, disk, reason)
+}
+
+func (self *DiskMigrateStorageTask) OnTargetDiskCleanupFailed(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	log.Errorf("cleanup migration target of disk %s failed: %s", disk.Id, data)
+	self.OnTargetDiskCleanup(ctx, disk, data)
+}
+
+func (self *DiskMigrateStorageTask) OnDiskMigratedFailed(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	self.cleanupTargetDisk(ctx, disk, data)
+}
+
+func (self *DiskMigrateStorageTask) OnDiskMigrated(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	sourceStorage := self.getSourceStorage()
+	targetStorage := self.getTargetStorage()
+	_, err := db.Update(disk, func() error {
+		disk.StorageId = targetStorage.Id
+		if diskFormat, _ := data.GetString("disk_format"); len(diskFormat) > 0 {
+			disk.DiskFormat = diskFormat
+		}
+		disk.AccessPath, _ = data.GetString("disk_path")
+		return nil
+	})
+	if err != nil {
+		// 宿主机上虚机已切换到新磁盘而数据库仍指向源磁盘，保留源磁盘，由管理员修复
+		log.Errorf("update storage of disk %s to %s fail: %s", disk.Id, targetStorage.Id, err)
+		reason := jsonutils.NewString(fmt.Sprintf("update storage of disk to %s: %s", targetStorage.Name, err))
+		self.taskFailedWithStatus(ctx, disk, api.DISK_MIGRATE_STORAGE_FAILED, reason)
+		return
+	}
+	sourceStorage.ClearSchedDescCache()
+	targetStorage.ClearSchedDescCache()
+	notes := fmt.Sprintf("%s => %s", sourceStorage.Name, targetStorage.Name)
+	db.OpsLog.LogEvent(disk, db.ACT_MIGRATE, notes, self.UserCred)
+	logclient.AddActionLogWithStartable(self, disk, logclient.ACT_MIGRATE, notes, self.UserCred, true)
+
+	host := self.getHost()
+	if host == nil {
+		self.OnSourceDiskDeleted(ctx, disk, nil)
+		return
+	}
+	self.SetStage("OnSourceDiskDeleted", nil)
+	err = host.GetHostDriver().RequestDeallocateDiskOnHost(ctx, host, sourceStorage, disk, self)
+	if err != nil {
+		log.Errorf("delete source of disk %s on storage %s: %s", disk.Id, sourceStorage.Name, err)
+		self.OnSourceDiskDeleted(ctx, disk, nil)
+	}
+}
+
+func (self *DiskMigrateStorageTask) OnSourceDiskDeleted(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	disk.SetStatus(self.UserCred, api.DISK_READY, "")
+	if guest := self.getGuest(); guest != nil {
+		guest.StartSyncstatus(ctx, self.UserCred, "")
+	}
+	self.SetStageComplete(ctx, nil)
+}
+
+func (self *DiskMigrateStorageTask) OnSourceDiskDeletedFailed(ctx context.Context, disk *models.SDisk, data jsonutils.JSONObject) {
+	// 数据已迁移完成，源磁盘残留不影响使用
+	sourceStorage := self.getSourceStorage()
+	log.Errorf("delete source of disk %s on storage %s fail: %s", disk.Id, sourceStorage.Name, data)
+	db.OpsLog.LogEvent(disk, db.ACT_DELOCATE_FAIL, data, self.UserCred)
+	self.OnSourceDiskDeleted(ctx, disk, data)
+}
@@ -70,6 +70,7 @@ func (self *GuestCreateTask) OnDiskPreparedFailed(ctx context.Context, obj db.IS
 	db.OpsLog.LogEvent(guest, db.ACT_ALLOCATE_FAIL, data, self.UserCred)
 	logclient.AddActionLogWithStartable(self, guest, logclient.ACT_ALLOCATE, data, self.UserCred, false)
 	notifyclient.NotifySystemErrorWithCtx(ctx, guest.Id, guest.Name, api.VM_DISK_FAILED, data.String())
+	self.notifyServerCreateFailed(ctx, guest)
 	self.SetStageFailed(ctx, data)
 }
 
@@ -99,6 +100,7 @@ func (self *GuestCreateTask) OnCdromPreparedFailed(ctx context.Context, obj db.I
 	db.OpsLog.LogEvent(guest, db.ACT_ALLOCATE_FAIL, data, self.UserCred)
 	logclient.AddActionLogWithStartable(self, guest, logclient.ACT_ALLOCATE, data, self.UserCred, false)
 	notifyclient.NotifySystemErrorWithCtx(ctx, guest.Id, guest.Name, api.VM_DISK_FAILED, fmt.Sprintf("cdrom_failed %s", data))
+	self.notifyServerCreateFailed(ctx, guest)
 	self.SetStageFailed(ctx, data)
 }
 
@@ -158,6 +160,18 @@ func (self *GuestCreateTask) notifyServerCreated(ctx context.Context, guest *mod
 		notify.NotifyPriorityImportant, true, nil, false,
 	)
 	guest.NotifyAdminServerEvent(ctx, notifyclient.SERVER_CREATED_ADMIN, notify.NotifyPriorityImportant)
+	notifyclient.EventNotify(ctx, self.UserCred, notifyclient.SEventNotifyParam{
+		Obj:    guest,
+		Action: notifyclient.ActionCreate,
+	})
+}
+
+func (self *GuestCreateTask) notifyServerCreateFailed(ctx context.Context, guest *models.SGuest) {
+	notifyclient.EventNotify(ctx, self.UserCred, notifyclient.SEventNotifyParam{
+		Obj:      guest,
+		Action:   notifyclient.ActionFailed,
+		Priority: notify.NotifyPriorityImportant,
+	})
 }
 
 func (self *GuestCreateTask) OnDeployGuestDescCompleteFailed(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
@@ -166,6 +180,7 @@ func (self *GuestCreateTask) OnDeployGuestDescCompleteFailed(ctx context.Context
 	db.OpsLog.LogEvent(guest, db.ACT_ALLOCATE_FAIL, data, self.UserCred)
 	logclient.AddActionLogWithStartable(self, guest, logclient.ACT_ALLOCATE, data, self.UserCred, false)
 	notifyclient.NotifySystemErrorWithCtx(ctx, guest.Id, guest.Name, api.VM_DEPLOY_FAILED, data.String())
+	self.notifyServerCreateFailed(ctx, guest)
 	self.SetStageFailed(ctx, data)
 }
 
@@ -207,6 +222,7 @@ func (self *GuestCreateTask) OnDeployEipCompleteFailed(ctx context.Context, obj
 	db.OpsLog.LogEvent(guest, db.ACT_EIP_ATTACH, data, self.UserCred)
 	logclient.AddActionLogWithStartable(self, guest, logclient.ACT_EIP_ASSOCIATE, data, self.UserCred, false)
 	notifyclient.NotifySystemErrorWithCtx(ctx, guest.Id, guest.Name, api.VM_ASSOCIATE_EIP_FAILED, data.String())
+	self.notifyServerCreateFailed(ctx, guest)
 	self.SetStageFailed(ctx, data)
 }
 
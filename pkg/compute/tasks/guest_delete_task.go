@@ -348,6 +348,10 @@ func (self *GuestDeleteTask) DeleteGuest(ctx context.Context, guest *models.SGue
 		} else {
 			notifyclient.NotifyWebhook(ctx, self.UserCred, guest, notifyclient.ActionDelete)
 		}
+		notifyclient.EventNotify(ctx, self.UserCred, notifyclient.SEventNotifyParam{
+			Obj:    guest,
+			Action: notifyclient.ActionDelete,
+		})
 	}
 	models.HostManager.ClearSchedDescCache(guest.HostId)
 	self.SetStageComplete(ctx, nil)
@@ -19,6 +19,7 @@ import (
 	"fmt"
 
 	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
 	"yunion.io/x/pkg/utils"
 
 	api "yunion.io/x/onecloud/pkg/apis/compute"
@@ -27,6 +28,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/notifyclient"
 	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/util/httputils"
 	"yunion.io/x/onecloud/pkg/util/logclient"
 )
@@ -178,6 +180,7 @@ func (self *GuestMigrateTask) OnCachedCdromComplete(ctx context.Context, guest *
 		host := guest.GetHost()
 		url := fmt.Sprintf("%s/servers/%s/src-prepare-migrate", host.ManagerUri, guest.Id)
 		self.SetStage("OnSrcPrepareComplete", nil)
+		self.SetProgress(10, "preparing migration on source host")
 		_, _, err := httputils.JSONRequest(httputils.GetDefaultClient(), ctx, "POST",
 			url, header, body, false)
 		if err != nil {
@@ -223,6 +226,7 @@ func (self *GuestMigrateTask) OnSrcPrepareComplete(ctx context.Context, guest *m
 
 	url := fmt.Sprintf("%s/servers/%s/dest-prepare-migrate", targetHost.ManagerUri, guest.Id)
 	self.SetStage("OnMigrateConfAndDiskComplete", nil)
+	self.SetProgress(30, "preparing migration on target host")
 	_, _, err := httputils.JSONRequest(httputils.GetDefaultClient(),
 		ctx, "POST", url, headers, body, false)
 	if err != nil {
@@ -363,6 +367,7 @@ func (self *GuestLiveMigrateTask) OnStartDestComplete(ctx context.Context, guest
 	host := guest.GetHost()
 	url := fmt.Sprintf("%s/servers/%s/live-migrate", host.ManagerUri, guest.Id)
 	self.SetStage("OnLiveMigrateComplete", nil)
+	self.SetProgress(50, "live migrating")
 	_, _, err = httputils.JSONRequest(httputils.GetDefaultClient(),
 		ctx, "POST", url, headers, body, false)
 	if err != nil {
@@ -406,6 +411,29 @@ func (self *GuestMigrateTask) setGuest(ctx context.Context, guest *models.SGuest
 	return nil
 }
 
+// OnCancel cancels the qemu migration and stops the block jobs of the
+// migrating disks on source host, the guest prepared on target host is
+// undeployed by the failed handler
+func (self *GuestLiveMigrateTask) OnCancel(ctx context.Context, guest *models.SGuest, data jsonutils.JSONObject) {
+	if self.Stage != "OnLiveMigrateComplete" {
+		return
+	}
+	host := guest.GetHost()
+	if host == nil {
+		return
+	}
+	// without task id, host will not callback to the canceled task
+	headers := mcclient.GetTokenHeaders(self.UserCred)
+	for _, action := range []string{"cancel-live-migrate", "cancel-block-jobs"} {
+		url := fmt.Sprintf("%s/servers/%s/%s", host.ManagerUri, guest.Id, action)
+		_, _, err := httputils.JSONRequest(httputils.GetDefaultClient(),
+			ctx, "POST", url, headers, nil, false)
+		if err != nil {
+			log.Errorf("%s of guest %s on host %s fail %s", action, guest.Name, host.Name, err)
+		}
+	}
+}
+
 func (self *GuestLiveMigrateTask) OnLiveMigrateCompleteFailed(ctx context.Context, guest *models.SGuest, data jsonutils.JSONObject) {
 	targetHostId, _ := self.Params.GetString("target_host_id")
 	guest.StartUndeployGuestTask(ctx, self.UserCred, "", targetHostId)
@@ -419,6 +447,7 @@ func (self *GuestLiveMigrateTask) OnLiveMigrateComplete(ctx context.Context, gue
 	targetHostId, _ := self.Params.GetString("target_host_id")
 
 	self.SetStage("OnResumeDestGuestComplete", nil)
+	self.SetProgress(80, "resuming guest on target host")
 	targetHost := models.HostManager.FetchHostById(targetHostId)
 	url := fmt.Sprintf("%s/servers/%s/resume", targetHost.ManagerUri, guest.Id)
 	_, _, err := httputils.JSONRequest(httputils.GetDefaultClient(),
@@ -64,6 +64,17 @@ func (self *SNatDEntryCreateTask) OnBindIPCompleteFailed(ctx context.Context, dn
 func (self *SNatDEntryCreateTask) OnBindIPComplete(ctx context.Context, dnatEntry *models.SNatDEntry,
 	body jsonutils.JSONObject) {
 
+	natgateway, err := dnatEntry.GetNatgateway()
+	if err != nil {
+		self.TaskFailed(ctx, dnatEntry, jsonutils.NewString(fmt.Sprintf("fetch natgateway failed: %s", err)))
+		return
+	}
+	if len(natgateway.ExternalId) == 0 {
+		// on-premise nat entries are realized by vpcagent
+		self.taskComplete(ctx, dnatEntry)
+		return
+	}
+
 	cloudNatGateway, err := dnatEntry.GetINatGateway()
 	if err != nil {
 		self.TaskFailed(ctx, dnatEntry, jsonutils.NewString(fmt.Sprintf("Get NatGateway failed: %s", err)))
@@ -105,6 +116,10 @@ func (self *SNatDEntryCreateTask) OnBindIPComplete(ctx context.Context, dnatEntr
 		return
 	}
 
+	self.taskComplete(ctx, dnatEntry)
+}
+
+func (self *SNatDEntryCreateTask) taskComplete(ctx context.Context, dnatEntry *models.SNatDEntry) {
 	dnatEntry.SetStatus(self.UserCred, api.NAT_STAUTS_AVAILABLE, "")
 	db.OpsLog.LogEvent(dnatEntry, db.ACT_ALLOCATE, dnatEntry.GetShortDesc(ctx), self.UserCred)
 	natgateway, err := dnatEntry.GetNatgateway()
@@ -0,0 +1,71 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+)
+
+type NatGatewayCreateTask struct {
+	taskman.STask
+}
+
+func init() {
+	taskman.RegisterTask(NatGatewayCreateTask{})
+}
+
+func (self *NatGatewayCreateTask) taskFailed(ctx context.Context, natgateway *models.SNatGateway, reason jsonutils.JSONObject) {
+	natgateway.SetStatus(self.GetUserCred(), api.NAT_STATUS_FAILED, reason.String())
+	db.OpsLog.LogEvent(natgateway, db.ACT_ALLOCATE_FAIL, reason, self.GetUserCred())
+	logclient.AddActionLogWithStartable(self, natgateway, logclient.ACT_ALLOCATE, reason, self.UserCred, false)
+	self.SetStageFailed(ctx, reason)
+}
+
+func (self *NatGatewayCreateTask) OnInit(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+	natgateway := obj.(*models.SNatGateway)
+
+	region := natgateway.GetRegion()
+	if region == nil {
+		self.taskFailed(ctx, natgateway, jsonutils.NewString(fmt.Sprintf("failed to found cloudregion for natgateway %s(%s)", natgateway.Name, natgateway.Id)))
+		return
+	}
+
+	self.SetStage("OnNatGatewayCreateComplete", nil)
+	err := region.GetDriver().RequestCreateNatGateway(ctx, self.GetUserCred(), natgateway, self)
+	if err != nil {
+		self.taskFailed(ctx, natgateway, jsonutils.NewString(err.Error()))
+		return
+	}
+}
+
+func (self *NatGatewayCreateTask) OnNatGatewayCreateComplete(ctx context.Context, natgateway *models.SNatGateway, data jsonutils.JSONObject) {
+	natgateway.SetStatus(self.GetUserCred(), api.NAT_STAUTS_AVAILABLE, "")
+	db.OpsLog.LogEvent(natgateway, db.ACT_ALLOCATE, natgateway.GetShortDesc(ctx), self.GetUserCred())
+	logclient.AddActionLogWithStartable(self, natgateway, logclient.ACT_ALLOCATE, nil, self.UserCred, true)
+	self.SetStageComplete(ctx, nil)
+}
+
+func (self *NatGatewayCreateTask) OnNatGatewayCreateCompleteFailed(ctx context.Context, natgateway *models.SNatGateway, data jsonutils.JSONObject) {
+	self.taskFailed(ctx, natgateway, data)
+}
@@ -64,6 +64,17 @@ func (self *SNatSEntryCreateTask) OnBindIPCompleteFailed(ctx context.Context, sn
 func (self *SNatSEntryCreateTask) OnBindIPComplete(ctx context.Context, snatEntry *models.SNatSEntry,
 	body jsonutils.JSONObject) {
 
+	natgateway, err := snatEntry.GetNatgateway()
+	if err != nil {
+		self.TaskFailed(ctx, snatEntry, jsonutils.NewString(fmt.Sprintf("fetch natgateway failed: %s", err)))
+		return
+	}
+	if len(natgateway.ExternalId) == 0 {
+		// on-premise nat entries are realized by vpcagent
+		self.taskComplete(ctx, snatEntry)
+		return
+	}
+
 	cloudNatGateway, err := snatEntry.GetINatGateway()
 	if err != nil {
 		self.TaskFailed(ctx, snatEntry, jsonutils.NewString(fmt.Sprintf("Get NatGateway failed: %s", err)))
@@ -108,6 +119,10 @@ func (self *SNatSEntryCreateTask) OnBindIPComplete(ctx context.Context, snatEntr
 		return
 	}
 
+	self.taskComplete(ctx, snatEntry)
+}
+
+func (self *SNatSEntryCreateTask) taskComplete(ctx context.Context, snatEntry *models.SNatSEntry) {
 	snatEntry.SetStatus(self.UserCred, api.NAT_STAUTS_AVAILABLE, "")
 	db.OpsLog.LogEvent(snatEntry, db.ACT_ALLOCATE, snatEntry.GetShortDesc(ctx), self.UserCred)
 	natgateway, err := snatEntry.GetNatgateway()
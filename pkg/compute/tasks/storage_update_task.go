@@ -72,7 +72,7 @@ func (self *RbdStorageUpdateTask) OnInit(ctx context.Context, obj db.IStandalone
 		headers := mcclient.GetTokenHeaders(self.GetUserCred())
 		body := jsonutils.Marshal(map[string]interface{}{
 			"storage_id":   storage.Id,
-			"storage_conf": storage.StorageConf,
+			"storage_conf": storage.GetHostStorageConf(),
 		})
 		_, _, err := httputils.JSONRequest(httputils.GetDefaultClient(), ctx, "POST", url, headers, body, false)
 		//这里尽可能的更新所有在线的hoststorage信息,仅打印warning信息
@@ -58,6 +58,11 @@ func (self *VpcPeeringConnectionCreateTask) OnInit(ctx context.Context, obj db.I
 		return
 	}
 
+	if !vpc.IsManaged() {
+		self.onPremiseCreate(ctx, peer, vpc, peerVpc)
+		return
+	}
+
 	iVpc, err := vpc.GetIVpc()
 	if err != nil {
 		self.taskFailed(ctx, peer, errors.Wrapf(err, "GetIVpc"))
@@ -98,6 +103,22 @@ func (self *VpcPeeringConnectionCreateTask) OnInit(ctx context.Context, obj db.I
 	self.taskComplete(ctx, peer)
 }
 
+// onPremiseCreate allocates link for the peering, which vpcagent realizes.
+// Peering with vpc of another domain waits for acceptance from that domain
+func (self *VpcPeeringConnectionCreateTask) onPremiseCreate(ctx context.Context, peer *models.SVpcPeeringConnection, vpc, peerVpc *models.SVpc) {
+	err := peer.AllocateLinkIndex(ctx, self.GetUserCred())
+	if err != nil {
+		self.taskFailed(ctx, peer, errors.Wrapf(err, "AllocateLinkIndex"))
+		return
+	}
+	status := api.VPC_PEERING_CONNECTION_STATUS_ACTIVE
+	if vpc.DomainId != peerVpc.DomainId {
+		status = api.VPC_PEERING_CONNECTION_STATUS_PENDING_ACCEPT
+	}
+	peer.SetStatus(self.GetUserCred(), status, "")
+	self.taskComplete(ctx, peer)
+}
+
 func (self *VpcPeeringConnectionCreateTask) taskComplete(ctx context.Context, peer *models.SVpcPeeringConnection) {
 	logclient.AddActionLogWithStartable(self, peer, logclient.ACT_CREATE, nil, self.UserCred, true)
 	self.SetStageComplete(ctx, nil)
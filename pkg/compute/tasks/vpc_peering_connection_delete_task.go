@@ -52,6 +52,12 @@ func (self *VpcPeeringConnectionDeleteTask) taskComplete(ctx context.Context, pe
 func (self *VpcPeeringConnectionDeleteTask) OnInit(ctx context.Context, obj db.IStandaloneModel, body jsonutils.JSONObject) {
 	peer := obj.(*models.SVpcPeeringConnection)
 
+	// on-premise vpc peering is torn down by vpcagent once it's gone
+	if len(peer.ExternalId) == 0 {
+		self.taskComplete(ctx, peer)
+		return
+	}
+
 	vpc, err := peer.GetVpc()
 	if err != nil {
 		self.taskFailed(ctx, peer, errors.Wrapf(err, "GetVpc"))
@@ -64,11 +70,6 @@ func (self *VpcPeeringConnectionDeleteTask) OnInit(ctx context.Context, obj db.I
 		return
 	}
 
-	if len(peer.ExternalId) == 0 {
-		self.taskComplete(ctx, peer)
-		return
-	}
-
 	iPeer, err := iVpc.GetICloudVpcPeeringConnectionById(peer.ExternalId)
 	if err != nil {
 		if errors.Cause(err) != cloudprovider.ErrNotFound {
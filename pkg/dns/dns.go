@@ -88,6 +88,7 @@ type SRegionDNS struct {
 	K8sSkip       bool
 
 	K8sManager            *k8s.SKubeClusterManager
+	PrivateZoneCache      *sPrivateZoneCache
 	primaryZoneLabelCount int
 }
 
@@ -117,6 +118,11 @@ func (r *SRegionDNS) initDB(c *caddy.Controller) error {
 	return nil
 }
 
+func (r *SRegionDNS) initPrivateZoneCache() {
+	r.PrivateZoneCache = newPrivateZoneCache(30 * time.Second)
+	r.PrivateZoneCache.Start()
+}
+
 func (r *SRegionDNS) initK8s() {
 	r.initAuth()
 	r.K8sManager = k8s.NewKubeClusterManager(r.Region, 30*time.Second)
@@ -141,6 +147,14 @@ func (r *SRegionDNS) ServeDNS(ctx context.Context, w dns.ResponseWriter, rmsg *d
 
 	opt := plugin.Options{}
 	state := request.Request{W: w, Req: rmsg, Context: ctx}
+	switch state.QType() {
+	case dns.TypeAXFR, dns.TypeIXFR:
+		return r.Transfer(ctx, state)
+	}
+	if pz := r.getPrivateZone(state); pz != nil {
+		return r.servePrivateZone(pz, state)
+	}
+
 	zone := plugin.Zones(r.Zones).Matches(state.Name())
 	switch state.QType() {
 	case dns.TypeA:
@@ -0,0 +1,358 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+
+	ylog "yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+	"yunion.io/x/onecloud/pkg/compute/models"
+)
+
+// maxCNAMEDepth limits how many in-zone CNAME targets are chased for a
+// single query
+const maxCNAMEDepth = 8
+
+// sPrivateZone is a PrivateZone dns zone bound to the vpc where the query
+// comes from
+type sPrivateZone struct {
+	zone       *models.SDnsZone
+	origin     string
+	recordSets []models.SDnsRecordSet
+	// OneCloud traffic policies keyed by record set id
+	policies map[string]*models.SDnsTrafficPolicy
+
+	// availability zone of the querying guest, used by geo traffic policy
+	srcZone *models.SZone
+}
+
+// getPrivateZone returns the private zone answering the request, or nil
+// if the request should be served by the region records
+func (r *SRegionDNS) getPrivateZone(state request.Request) *sPrivateZone {
+	if r.PrivateZoneCache == nil {
+		return nil
+	}
+	return r.PrivateZoneCache.getPrivateZone(state.IP(), state.Name())
+}
+
+// servePrivateZone answers the request from the record sets of pz
+func (r *SRegionDNS) servePrivateZone(pz *sPrivateZone, state request.Request) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(state.Req)
+	m.Authoritative, m.RecursionAvailable = true, true
+
+	answer, nxdomain := pz.lookup(state.Name(), state.QType(), 0)
+	if len(answer) == 0 {
+		m.Ns = []dns.RR{pz.soa(0, r.MinTTL(state))}
+		if nxdomain {
+			m.Rcode = dns.RcodeNameError
+		}
+	} else {
+		m.Answer = answer
+		m.Extra = pz.glue(answer)
+	}
+
+	state.SizeAndDo(m)
+	m = state.Scrub(m)
+	state.W.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// relName returns the record set name of qname relative to the zone
+// origin, "@" for the apex
+func (pz *sPrivateZone) relName(qname string) string {
+	labels := dns.SplitDomainName(strings.ToLower(qname))
+	n := len(labels) - dns.CountLabel(pz.origin)
+	if n <= 0 {
+		return "@"
+	}
+	return strings.Join(labels[:n], ".")
+}
+
+func (pz *sPrivateZone) recordSetsByName(name string) []models.SDnsRecordSet {
+	ret := []models.SDnsRecordSet{}
+	for i := range pz.recordSets {
+		if strings.ToLower(pz.recordSets[i].Name) == name {
+			ret = append(ret, pz.recordSets[i])
+		}
+	}
+	return ret
+}
+
+// matchRecordSets returns record sets named rel, falling back to the
+// closest wildcard record sets
+func (pz *sPrivateZone) matchRecordSets(rel string) []models.SDnsRecordSet {
+	sets := pz.recordSetsByName(rel)
+	if len(sets) > 0 || rel == "@" {
+		return sets
+	}
+	labels := strings.Split(rel, ".")
+	for i := 1; i <= len(labels); i++ {
+		name := "*"
+		if i < len(labels) {
+			name = "*." + strings.Join(labels[i:], ".")
+		}
+		sets = pz.recordSetsByName(name)
+		if len(sets) > 0 {
+			return sets
+		}
+	}
+	return nil
+}
+
+// lookup returns answers of qname for qtype and whether the name does
+// not exist in the zone at all
+func (pz *sPrivateZone) lookup(qname string, qtype uint16, depth int) ([]dns.RR, bool) {
+	rel := pz.relName(qname)
+	sets := pz.matchRecordSets(rel)
+	if len(sets) == 0 && rel != "@" {
+		return nil, true
+	}
+
+	typeSets := func(typ uint16) []models.SDnsRecordSet {
+		ret := []models.SDnsRecordSet{}
+		for i := range sets {
+			if sets[i].DnsType == dns.TypeToString[typ] {
+				ret = append(ret, sets[i])
+			}
+		}
+		return ret
+	}
+
+	if rel == "@" {
+		switch qtype {
+		case dns.TypeSOA:
+			return []dns.RR{pz.soa(0, 0)}, false
+		case dns.TypeNS:
+			if rrs := pz.recordSetRRs(qname, pz.applyTrafficPolicies(typeSets(dns.TypeNS))); len(rrs) > 0 {
+				return rrs, false
+			}
+			return []dns.RR{pz.ns()}, false
+		}
+	}
+
+	if qtype != dns.TypeCNAME {
+		cnames := pz.recordSetRRs(qname, pz.applyTrafficPolicies(typeSets(dns.TypeCNAME)))
+		if len(cnames) > 0 {
+			target := cnames[0].(*dns.CNAME).Target
+			if depth < maxCNAMEDepth && dns.IsSubDomain(pz.origin, target) {
+				rrs, _ := pz.lookup(target, qtype, depth+1)
+				cnames = append(cnames[:1], rrs...)
+			}
+			return cnames, false
+		}
+	}
+	return pz.recordSetRRs(qname, pz.applyTrafficPolicies(typeSets(qtype))), false
+}
+
+// applyTrafficPolicies selects record sets according to the OneCloud
+// traffic policies.  Record sets without policy are always returned,
+// geo located ones are returned when matching the availability zone of
+// the querying guest, falling back to the Default ones, and only one of
+// the weighted ones is returned
+func (pz *sPrivateZone) applyTrafficPolicies(sets []models.SDnsRecordSet) []models.SDnsRecordSet {
+	var (
+		ret        = []models.SDnsRecordSet{}
+		geo        = []models.SDnsRecordSet{}
+		geoDefault = []models.SDnsRecordSet{}
+		weighted   = []models.SDnsRecordSet{}
+		weights    = []int{}
+		total      = 0
+	)
+	for i := range sets {
+		policy, ok := pz.policies[sets[i].Id]
+		if !ok {
+			ret = append(ret, sets[i])
+			continue
+		}
+		switch cloudprovider.TDnsPolicyType(policy.PolicyType) {
+		case cloudprovider.DnsPolicyTypeByGeoLocation:
+			if pz.srcZone != nil && (policy.PolicyValue == pz.srcZone.Id || policy.PolicyValue == pz.srcZone.Name) {
+				geo = append(geo, sets[i])
+			} else if policy.PolicyValue == string(cloudprovider.DnsPolicyValueEmpty) || policy.PolicyValue == string(cloudprovider.DnsPolicyValueDefault) {
+				geoDefault = append(geoDefault, sets[i])
+			}
+		case cloudprovider.DnsPolicyTypeWeighted:
+			weight, _ := strconv.Atoi(policy.PolicyValue)
+			if weight > 0 {
+				weighted = append(weighted, sets[i])
+				weights = append(weights, weight)
+				total += weight
+			}
+		default:
+			ret = append(ret, sets[i])
+		}
+	}
+	if len(geo) == 0 {
+		geo = geoDefault
+	}
+	ret = append(ret, geo...)
+	if total > 0 {
+		n := rand.Intn(total)
+		for i := range weighted {
+			if n < weights[i] {
+				ret = append(ret, weighted[i])
+				break
+			}
+			n -= weights[i]
+		}
+	}
+	return ret
+}
+
+func (pz *sPrivateZone) recordSetRRs(owner string, sets []models.SDnsRecordSet) []dns.RR {
+	rrs := []dns.RR{}
+	for i := range sets {
+		rr, err := recordSetRR(owner, &sets[i])
+		if err != nil {
+			ylog.Errorf("invalid record set %s(%s) of dns zone %s: %v", sets[i].Name, sets[i].Id, pz.zone.Name, err)
+			continue
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs
+}
+
+// recordSetRR builds the resource record of rec owned by owner
+func recordSetRR(owner string, rec *models.SDnsRecordSet) (dns.RR, error) {
+	value := strings.TrimSpace(rec.DnsValue)
+	switch rec.DnsType {
+	case "A", "AAAA", "CAA":
+	case "CNAME", "NS", "PTR":
+		value = dns.Fqdn(value)
+	case "MX":
+		value = fmt.Sprintf("%d %s", rec.MxPriority, dns.Fqdn(value))
+	case "SRV":
+		// priority weight port target
+		fields := strings.Fields(value)
+		if len(fields) != 4 {
+			return nil, errors.Errorf("invalid SRV value %q", rec.DnsValue)
+		}
+		fields[3] = dns.Fqdn(fields[3])
+		value = strings.Join(fields, " ")
+	case "TXT":
+		if !strings.HasPrefix(value, `"`) {
+			value = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+		}
+	default:
+		return nil, errors.Errorf("unsupported dns type %s", rec.DnsType)
+	}
+	ttl := uint32(rec.TTL)
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	return dns.NewRR(fmt.Sprintf("%s %d IN %s %s", owner, ttl, rec.DnsType, value))
+}
+
+// glue returns in-zone addresses of MX, SRV and NS targets in answer
+func (pz *sPrivateZone) glue(answer []dns.RR) []dns.RR {
+	extra := []dns.RR{}
+	for _, rr := range answer {
+		var target string
+		switch v := rr.(type) {
+		case *dns.MX:
+			target = v.Mx
+		case *dns.SRV:
+			target = v.Target
+		case *dns.NS:
+			target = v.Ns
+		default:
+			continue
+		}
+		if !dns.IsSubDomain(pz.origin, target) {
+			continue
+		}
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			rrs, _ := pz.lookup(target, qtype, maxCNAMEDepth)
+			for _, rr := range rrs {
+				if rr.Header().Rrtype == qtype {
+					extra = append(extra, rr)
+				}
+			}
+		}
+	}
+	return extra
+}
+
+// serial is derived from the last update of the zone, which is touched on
+// every record set change
+func (pz *sPrivateZone) serial() uint32 {
+	updatedAt := pz.zone.UpdatedAt
+	for i := range pz.recordSets {
+		if pz.recordSets[i].UpdatedAt.After(updatedAt) {
+			updatedAt = pz.recordSets[i].UpdatedAt
+		}
+	}
+	return uint32(updatedAt.Unix())
+}
+
+func (pz *sPrivateZone) soa(serial, minTTL uint32) dns.RR {
+	if serial == 0 {
+		serial = pz.serial()
+	}
+	if minTTL == 0 {
+		minTTL = defaultTTL
+	}
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: pz.origin, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: minTTL},
+		Ns:      defaultNSName,
+		Mbox:    "hostmaster." + pz.origin,
+		Serial:  serial,
+		Refresh: 7200,
+		Retry:   1800,
+		Expire:  86400,
+		Minttl:  minTTL,
+	}
+}
+
+func (pz *sPrivateZone) ns() dns.RR {
+	return &dns.NS{
+		Hdr: dns.RR_Header{Name: pz.origin, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: defaultTTL},
+		Ns:  defaultNSName,
+	}
+}
+
+// all returns every record of the zone for zone transfer, led by SOA and
+// NS.  Traffic policies are not applied
+func (pz *sPrivateZone) all(serial, minTTL uint32) []dns.RR {
+	rrs := []dns.RR{pz.soa(serial, minTTL)}
+	hasNS := false
+	for i := range pz.recordSets {
+		if pz.recordSets[i].Name == "@" && pz.recordSets[i].DnsType == "NS" {
+			hasNS = true
+			break
+		}
+	}
+	if !hasNS {
+		rrs = append(rrs, pz.ns())
+	}
+	for i := range pz.recordSets {
+		owner := pz.origin
+		if name := pz.recordSets[i].Name; name != "@" {
+			owner = strings.ToLower(name) + "." + pz.origin
+		}
+		rrs = append(rrs, pz.recordSetRRs(owner, pz.recordSets[i:i+1])...)
+	}
+	return rrs
+}
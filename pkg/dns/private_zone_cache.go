@@ -0,0 +1,413 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	ylog "yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/netutils"
+	"yunion.io/x/sqlchemy"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+	"yunion.io/x/onecloud/pkg/compute/models"
+)
+
+// privateZoneCacheMaxAge forces a reload even if the cached tables are
+// not changed, so that guests migrated to another availability zone are
+// picked up by geo traffic policies
+const privateZoneCacheMaxAge = 10 * time.Minute
+
+// sPrivateZoneSource is the vpc and availability zone of a querying
+// address
+type sPrivateZoneSource struct {
+	vpcId string
+	zone  *models.SZone
+}
+
+// sPrivateZoneNetwork is an on-premise network of the default vpc
+type sPrivateZoneNetwork struct {
+	network models.SNetwork
+	zone    *models.SZone
+}
+
+// sCachedPrivateZone is an enabled PrivateZone dns zone with its enabled
+// record sets and their OneCloud traffic policies keyed by record set id
+type sCachedPrivateZone struct {
+	zone       *models.SDnsZone
+	origin     string
+	recordSets []models.SDnsRecordSet
+	policies   map[string]*models.SDnsTrafficPolicy
+}
+
+// sPrivateZoneCache keeps PrivateZone zones, their record sets and
+// traffic policies, and the addresses of guests in memory, so that
+// queries are answered without touching the database.  The cache is
+// reloaded when any of the tables it is built from changes
+type sPrivateZoneCache struct {
+	lock     *sync.RWMutex
+	interval time.Duration
+
+	version  string
+	loadedAt time.Time
+
+	// vpc id -> zones bound to the vpc
+	vpcZones map[string][]*sCachedPrivateZone
+	// guest mapped_ip_addr and ip_addr of classic guests -> source
+	mappedSources map[string]sPrivateZoneSource
+	sources       map[string]sPrivateZoneSource
+	// on-premise networks, loaded only when the default vpc has zones
+	networks []sPrivateZoneNetwork
+}
+
+func newPrivateZoneCache(interval time.Duration) *sPrivateZoneCache {
+	return &sPrivateZoneCache{
+		lock:     new(sync.RWMutex),
+		interval: interval,
+	}
+}
+
+func (cache *sPrivateZoneCache) Start() {
+	go cache.startRefresh()
+}
+
+func (cache *sPrivateZoneCache) startRefresh() {
+	cache.refresh()
+	tick := time.Tick(cache.interval)
+	for {
+		select {
+		case <-tick:
+			cache.refresh()
+		}
+	}
+}
+
+func (cache *sPrivateZoneCache) refresh() {
+	version, err := privateZoneCacheVersion()
+	if err != nil {
+		ylog.Errorf("get private zone cache version: %v", err)
+		return
+	}
+	cache.lock.RLock()
+	fresh := version == cache.version && time.Since(cache.loadedAt) < privateZoneCacheMaxAge
+	cache.lock.RUnlock()
+	if fresh {
+		return
+	}
+	loaded := newPrivateZoneCache(cache.interval)
+	err = loaded.load()
+	if err != nil {
+		ylog.Errorf("load private zone cache: %v", err)
+		return
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.version = version
+	cache.loadedAt = time.Now()
+	cache.vpcZones = loaded.vpcZones
+	cache.mappedSources = loaded.mappedSources
+	cache.sources = loaded.sources
+	cache.networks = loaded.networks
+}
+
+// privateZoneCacheVersion fingerprints the tables the cache is built from
+// with their row count and last update, deleted rows included
+func privateZoneCacheVersion() (string, error) {
+	managers := []db.IModelManager{
+		models.DnsZoneManager,
+		models.DnsZoneVpcManager,
+		models.DnsRecordSetManager,
+		models.DnsRecordSetTrafficPolicyManager,
+		models.DnsTrafficPolicyManager,
+		models.GuestnetworkManager,
+		models.NetworkManager,
+	}
+	version := []string{}
+	for _, man := range managers {
+		t := man.TableSpec().Instance()
+		q := t.Query(sqlchemy.COUNT("count"), sqlchemy.MAX("updated_at", t.Field("updated_at")))
+		rows, err := q.AllStringMap()
+		if err != nil {
+			return "", errors.Wrapf(err, "query %s", man.KeywordPlural())
+		}
+		for _, row := range rows {
+			version = append(version, fmt.Sprintf("%s:%s", row["count"], row["updated_at"]))
+		}
+	}
+	return strings.Join(version, ","), nil
+}
+
+func (cache *sPrivateZoneCache) load() error {
+	zones := []models.SZone{}
+	err := db.FetchModelObjects(models.ZoneManager, models.ZoneManager.Query(), &zones)
+	if err != nil {
+		return errors.Wrap(err, "fetch zones")
+	}
+	zoneMap := map[string]*models.SZone{}
+	for i := range zones {
+		zoneMap[zones[i].Id] = &zones[i]
+	}
+
+	err = cache.loadZones()
+	if err != nil {
+		return err
+	}
+	if len(cache.vpcZones) == 0 {
+		return nil
+	}
+	err = cache.loadSources(zoneMap)
+	if err != nil {
+		return err
+	}
+	if _, ok := cache.vpcZones[api.DEFAULT_VPC_ID]; ok {
+		err = cache.loadNetworks(zoneMap)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cache *sPrivateZoneCache) loadZones() error {
+	q := models.DnsZoneManager.Query()
+	q = q.Equals("zone_type", string(cloudprovider.PrivateZone)).IsTrue("enabled")
+	q = q.NotEquals("status", api.DNS_ZONE_STATUS_DELETING)
+	zones := []models.SDnsZone{}
+	err := db.FetchModelObjects(models.DnsZoneManager, q, &zones)
+	if err != nil {
+		return errors.Wrap(err, "fetch dns zones")
+	}
+	cached := map[string]*sCachedPrivateZone{}
+	for i := range zones {
+		cached[zones[i].Id] = &sCachedPrivateZone{
+			zone:     &zones[i],
+			origin:   strings.ToLower(dns.Fqdn(zones[i].Name)),
+			policies: map[string]*models.SDnsTrafficPolicy{},
+		}
+	}
+
+	recordSets := []models.SDnsRecordSet{}
+	q = models.DnsRecordSetManager.Query().IsTrue("enabled")
+	err = db.FetchModelObjects(models.DnsRecordSetManager, q, &recordSets)
+	if err != nil {
+		return errors.Wrap(err, "fetch dns record sets")
+	}
+	recordSetZones := map[string]*sCachedPrivateZone{}
+	for i := range recordSets {
+		if zone, ok := cached[recordSets[i].DnsZoneId]; ok {
+			zone.recordSets = append(zone.recordSets, recordSets[i])
+			recordSetZones[recordSets[i].Id] = zone
+		}
+	}
+
+	policies := []models.SDnsTrafficPolicy{}
+	q = models.DnsTrafficPolicyManager.Query().Equals("provider", api.CLOUD_PROVIDER_ONECLOUD)
+	err = db.FetchModelObjects(models.DnsTrafficPolicyManager, q, &policies)
+	if err != nil {
+		return errors.Wrap(err, "fetch dns traffic policies")
+	}
+	policyMap := map[string]*models.SDnsTrafficPolicy{}
+	for i := range policies {
+		policyMap[policies[i].Id] = &policies[i]
+	}
+	joints := []models.SDnsRecordSetTrafficPolicy{}
+	err = db.FetchModelObjects(models.DnsRecordSetTrafficPolicyManager, models.DnsRecordSetTrafficPolicyManager.Query(), &joints)
+	if err != nil {
+		return errors.Wrap(err, "fetch dns record set traffic policies")
+	}
+	duplicated := map[string]bool{}
+	for i := range joints {
+		zone, ok := recordSetZones[joints[i].DnsRecordsetId]
+		if !ok {
+			continue
+		}
+		policy, ok := policyMap[joints[i].DnsTrafficPolicyId]
+		if !ok {
+			continue
+		}
+		if _, ok := zone.policies[joints[i].DnsRecordsetId]; ok {
+			ylog.Errorf("duplicate %s traffic policy of record set %s", api.CLOUD_PROVIDER_ONECLOUD, joints[i].DnsRecordsetId)
+			duplicated[joints[i].DnsRecordsetId] = true
+		}
+		zone.policies[joints[i].DnsRecordsetId] = policy
+	}
+	for id := range duplicated {
+		delete(recordSetZones[id].policies, id)
+	}
+
+	zoneVpcs := []models.SDnsZoneVpc{}
+	err = db.FetchModelObjects(models.DnsZoneVpcManager, models.DnsZoneVpcManager.Query(), &zoneVpcs)
+	if err != nil {
+		return errors.Wrap(err, "fetch dns zone vpcs")
+	}
+	cache.vpcZones = map[string][]*sCachedPrivateZone{}
+	for i := range zoneVpcs {
+		if zone, ok := cached[zoneVpcs[i].DnsZoneId]; ok {
+			cache.vpcZones[zoneVpcs[i].VpcId] = append(cache.vpcZones[zoneVpcs[i].VpcId], zone)
+		}
+	}
+	return nil
+}
+
+type sGuestAddr struct {
+	IpAddr       string
+	MappedIpAddr string
+	VpcId        string
+	ZoneId       string
+}
+
+// loadSources loads addresses of all guests.  Guests of vpcs without any
+// zone are kept as well, so that they are not mistaken as hosts of the
+// classic network
+func (cache *sPrivateZoneCache) loadSources(zoneMap map[string]*models.SZone) error {
+	gns := models.GuestnetworkManager.Query().SubQuery()
+	networks := models.NetworkManager.Query().SubQuery()
+	wires := models.WireManager.Query().SubQuery()
+	guests := models.GuestManager.Query().SubQuery()
+	hosts := models.HostManager.Query().SubQuery()
+	q := gns.Query(
+		gns.Field("ip_addr"),
+		gns.Field("mapped_ip_addr"),
+		wires.Field("vpc_id"),
+		hosts.Field("zone_id"),
+	)
+	q = q.Join(networks, sqlchemy.Equals(gns.Field("network_id"), networks.Field("id")))
+	q = q.Join(wires, sqlchemy.Equals(networks.Field("wire_id"), wires.Field("id")))
+	q = q.LeftJoin(guests, sqlchemy.Equals(gns.Field("guest_id"), guests.Field("id")))
+	q = q.LeftJoin(hosts, sqlchemy.Equals(guests.Field("host_id"), hosts.Field("id")))
+	addrs := make([]sGuestAddr, 0)
+	err := q.All(&addrs)
+	if err != nil {
+		return errors.Wrap(err, "query guest addresses")
+	}
+	cache.setSources(addrs, zoneMap)
+	return nil
+}
+
+// setSources keys guests in vpc by mapped_ip_addr only.  Their ip_addr is
+// private to the vpc and may well be taken by a host or guest of the classic
+// network
+func (cache *sPrivateZoneCache) setSources(addrs []sGuestAddr, zoneMap map[string]*models.SZone) {
+	cache.mappedSources = map[string]sPrivateZoneSource{}
+	cache.sources = map[string]sPrivateZoneSource{}
+	for _, addr := range addrs {
+		src := sPrivateZoneSource{vpcId: addr.VpcId, zone: zoneMap[addr.ZoneId]}
+		if len(addr.MappedIpAddr) > 0 {
+			cache.mappedSources[addr.MappedIpAddr] = src
+		}
+		if len(addr.IpAddr) > 0 && addr.VpcId == api.DEFAULT_VPC_ID {
+			cache.sources[addr.IpAddr] = src
+		}
+	}
+}
+
+func (cache *sPrivateZoneCache) loadNetworks(zoneMap map[string]*models.SZone) error {
+	wires := []models.SWire{}
+	q := models.WireManager.Query().Equals("vpc_id", api.DEFAULT_VPC_ID)
+	err := db.FetchModelObjects(models.WireManager, q, &wires)
+	if err != nil {
+		return errors.Wrap(err, "fetch wires")
+	}
+	wireZones := map[string]*models.SZone{}
+	wireIds := []string{}
+	for i := range wires {
+		wireZones[wires[i].Id] = zoneMap[wires[i].ZoneId]
+		wireIds = append(wireIds, wires[i].Id)
+	}
+	networks := []models.SNetwork{}
+	q = models.NetworkManager.Query().In("wire_id", wireIds)
+	err = db.FetchModelObjects(models.NetworkManager, q, &networks)
+	if err != nil {
+		return errors.Wrap(err, "fetch networks")
+	}
+	cache.networks = make([]sPrivateZoneNetwork, len(networks))
+	for i := range networks {
+		cache.networks[i] = sPrivateZoneNetwork{
+			network: networks[i],
+			zone:    wireZones[networks[i].WireId],
+		}
+	}
+	return nil
+}
+
+// source returns the vpc and availability zone of srcIP.
+//
+// Guests in vpc reach region dns with their mapped address, so
+// mapped_ip_addr is tried before ip_addr of classic guests.  Addresses not
+// owned by any guest are resolved against on-premise networks, which cover hosts in
+// the classic network
+func (cache *sPrivateZoneCache) source(srcIP string) sPrivateZoneSource {
+	if src, ok := cache.mappedSources[srcIP]; ok {
+		return src
+	}
+	if src, ok := cache.sources[srcIP]; ok {
+		return src
+	}
+	if len(cache.networks) == 0 {
+		return sPrivateZoneSource{}
+	}
+	address, err := netutils.NewIPV4Addr(srcIP)
+	if err != nil {
+		return sPrivateZoneSource{}
+	}
+	for i := range cache.networks {
+		if cache.networks[i].network.IsAddressInRange(address) {
+			return sPrivateZoneSource{vpcId: api.DEFAULT_VPC_ID, zone: cache.networks[i].zone}
+		}
+	}
+	return sPrivateZoneSource{}
+}
+
+// getPrivateZone returns the zone bound to the vpc of srcIP with the
+// longest name containing qname
+func (cache *sPrivateZoneCache) getPrivateZone(srcIP, qname string) *sPrivateZone {
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+
+	if len(cache.vpcZones) == 0 {
+		return nil
+	}
+	src := cache.source(srcIP)
+	if len(src.vpcId) == 0 {
+		return nil
+	}
+	var found *sCachedPrivateZone
+	for _, zone := range cache.vpcZones[src.vpcId] {
+		if !dns.IsSubDomain(zone.origin, qname) {
+			continue
+		}
+		if found == nil || dns.CountLabel(zone.origin) > dns.CountLabel(found.origin) {
+			found = zone
+		}
+	}
+	if found == nil {
+		return nil
+	}
+	return &sPrivateZone{
+		zone:       found.zone,
+		origin:     found.origin,
+		recordSets: found.recordSets,
+		policies:   found.policies,
+		srcZone:    src.zone,
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"testing"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/compute/models"
+)
+
+func testCachedPrivateZone(name string) *sCachedPrivateZone {
+	pz := testPrivateZone(name)
+	return &sCachedPrivateZone{
+		zone:   pz.zone,
+		origin: pz.origin,
+	}
+}
+
+func TestPrivateZoneCacheGetPrivateZone(t *testing.T) {
+	zone0 := &models.SZone{}
+	zone0.Id = "zone0"
+	zone1 := &models.SZone{}
+	zone1.Id = "zone1"
+
+	classic := models.SNetwork{
+		GuestIpStart: "192.168.1.1",
+		GuestIpEnd:   "192.168.1.254",
+	}
+	cache := newPrivateZoneCache(0)
+	cache.vpcZones = map[string][]*sCachedPrivateZone{
+		"vpc0": {
+			testCachedPrivateZone("example.com"),
+			testCachedPrivateZone("sub.example.com"),
+		},
+		api.DEFAULT_VPC_ID: {
+			testCachedPrivateZone("classic.com"),
+		},
+	}
+	cache.setSources([]sGuestAddr{
+		{IpAddr: "10.0.0.2", MappedIpAddr: "100.64.0.2", VpcId: "vpc0", ZoneId: "zone0"},
+		{IpAddr: "10.0.0.3", MappedIpAddr: "100.64.0.3", VpcId: "vpc1", ZoneId: "zone0"},
+		// ip_addr of guest in vpc taken by a classic guest
+		{IpAddr: "192.168.1.3", MappedIpAddr: "100.64.0.4", VpcId: "vpc0", ZoneId: "zone0"},
+		{IpAddr: "192.168.1.3", VpcId: api.DEFAULT_VPC_ID, ZoneId: "zone1"},
+	}, map[string]*models.SZone{
+		"zone0": zone0,
+		"zone1": zone1,
+	})
+	cache.networks = []sPrivateZoneNetwork{
+		{network: classic, zone: zone1},
+	}
+
+	cases := []struct {
+		name        string
+		srcIP       string
+		qname       string
+		wantOrigin  string
+		wantSrcZone string
+	}{
+		{
+			name:        "mapped address",
+			srcIP:       "100.64.0.2",
+			qname:       "www.example.com.",
+			wantOrigin:  "example.com.",
+			wantSrcZone: "zone0",
+		},
+		{
+			name:        "longest zone",
+			srcIP:       "100.64.0.4",
+			qname:       "www.sub.example.com.",
+			wantOrigin:  "sub.example.com.",
+			wantSrcZone: "zone0",
+		},
+		{
+			name:  "name out of zones",
+			srcIP: "100.64.0.2",
+			qname: "www.classic.com.",
+		},
+		{
+			name:  "ip_addr of guest in vpc",
+			srcIP: "10.0.0.2",
+			qname: "www.example.com.",
+		},
+		{
+			name:        "classic guest with address of guest in vpc",
+			srcIP:       "192.168.1.3",
+			qname:       "www.classic.com.",
+			wantOrigin:  "classic.com.",
+			wantSrcZone: "zone1",
+		},
+		{
+			name:        "classic network host",
+			srcIP:       "192.168.1.5",
+			qname:       "www.classic.com.",
+			wantOrigin:  "classic.com.",
+			wantSrcZone: "zone1",
+		},
+		{
+			name:  "guest of vpc without zone",
+			srcIP: "100.64.0.3",
+			qname: "www.classic.com.",
+		},
+		{
+			name:  "unknown address",
+			srcIP: "172.16.0.1",
+			qname: "www.example.com.",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pz := cache.getPrivateZone(c.srcIP, c.qname)
+			if pz == nil {
+				if c.wantOrigin != "" {
+					t.Errorf("getPrivateZone(%s, %s) = nil, want %s", c.srcIP, c.qname, c.wantOrigin)
+				}
+				return
+			}
+			if pz.origin != c.wantOrigin {
+				t.Errorf("getPrivateZone(%s, %s) = %s, want %q", c.srcIP, c.qname, pz.origin, c.wantOrigin)
+			}
+			if pz.srcZone == nil || pz.srcZone.Id != c.wantSrcZone {
+				t.Errorf("getPrivateZone(%s, %s) srcZone = %v, want %s", c.srcIP, c.qname, pz.srcZone, c.wantSrcZone)
+			}
+		})
+	}
+}
@@ -0,0 +1,401 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+	"yunion.io/x/onecloud/pkg/compute/models"
+)
+
+func testRecordSet(id, name, dnsType, value string) models.SDnsRecordSet {
+	rec := models.SDnsRecordSet{
+		DnsType:  dnsType,
+		DnsValue: value,
+	}
+	rec.Id = id
+	rec.Name = name
+	return rec
+}
+
+func testPolicy(policyType cloudprovider.TDnsPolicyType, value string) *models.SDnsTrafficPolicy {
+	return &models.SDnsTrafficPolicy{
+		PolicyType:  string(policyType),
+		PolicyValue: value,
+	}
+}
+
+func testPrivateZone(name string, recordSets ...models.SDnsRecordSet) *sPrivateZone {
+	zone := &models.SDnsZone{}
+	zone.Name = name
+	return &sPrivateZone{
+		zone:       zone,
+		origin:     dns.Fqdn(name),
+		recordSets: recordSets,
+		policies:   map[string]*models.SDnsTrafficPolicy{},
+	}
+}
+
+func testRRsString(rrs []dns.RR) string {
+	ret := []string{}
+	for _, rr := range rrs {
+		ret = append(ret, strings.Join(strings.Fields(rr.String()), " "))
+	}
+	return strings.Join(ret, "; ")
+}
+
+func testRecordSetIds(sets []models.SDnsRecordSet) string {
+	ids := []string{}
+	for i := range sets {
+		ids = append(ids, sets[i].Id)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestPrivateZoneMatchRecordSets(t *testing.T) {
+	pz := testPrivateZone("example.com",
+		testRecordSet("www", "www", "A", "10.0.0.1"),
+		testRecordSet("WWW", "WWW", "A", "10.0.0.2"),
+		testRecordSet("any", "*", "A", "10.0.0.3"),
+		testRecordSet("dev", "*.dev", "A", "10.0.0.4"),
+	)
+	cases := []struct {
+		rel  string
+		want string
+	}{
+		{rel: "www", want: "www,WWW"},
+		{rel: "a.dev", want: "dev"},
+		{rel: "b.a.dev", want: "dev"},
+		{rel: "dev", want: "any"},
+		{rel: "other", want: "any"},
+		{rel: "@", want: ""},
+	}
+	for _, c := range cases {
+		t.Run(c.rel, func(t *testing.T) {
+			got := testRecordSetIds(pz.matchRecordSets(c.rel))
+			if got != c.want {
+				t.Errorf("matchRecordSets(%q) = %q, want %q", c.rel, got, c.want)
+			}
+		})
+	}
+}
+
+func TestPrivateZoneLookup(t *testing.T) {
+	mx := testRecordSet("mx", "@", "MX", "mail")
+	mx.MxPriority = 10
+	pz := testPrivateZone("example.com",
+		testRecordSet("apex", "@", "A", "10.0.0.10"),
+		mx,
+		testRecordSet("www1", "www", "A", "10.0.0.1"),
+		testRecordSet("www2", "www", "A", "10.0.0.2"),
+		testRecordSet("alias", "alias", "CNAME", "www.example.com"),
+		testRecordSet("ext", "ext", "CNAME", "www.example.org"),
+		testRecordSet("dev", "*.dev", "A", "10.0.1.1"),
+		testRecordSet("loop1", "loop1", "CNAME", "loop2.example.com"),
+		testRecordSet("loop2", "loop2", "CNAME", "loop1.example.com"),
+	)
+	cases := []struct {
+		name         string
+		qname        string
+		qtype        uint16
+		want         string
+		wantNxdomain bool
+	}{
+		{
+			name:  "a",
+			qname: "www.example.com.",
+			qtype: dns.TypeA,
+			want:  "www.example.com. 10 IN A 10.0.0.1; www.example.com. 10 IN A 10.0.0.2",
+		},
+		{
+			name:  "no record of type",
+			qname: "www.example.com.",
+			qtype: dns.TypeAAAA,
+			want:  "",
+		},
+		{
+			name:         "nxdomain",
+			qname:        "missing.example.com.",
+			qtype:        dns.TypeA,
+			want:         "",
+			wantNxdomain: true,
+		},
+		{
+			name:  "cname chased in zone",
+			qname: "alias.example.com.",
+			qtype: dns.TypeA,
+			want:  "alias.example.com. 10 IN CNAME www.example.com.; www.example.com. 10 IN A 10.0.0.1; www.example.com. 10 IN A 10.0.0.2",
+		},
+		{
+			name:  "cname query",
+			qname: "alias.example.com.",
+			qtype: dns.TypeCNAME,
+			want:  "alias.example.com. 10 IN CNAME www.example.com.",
+		},
+		{
+			name:  "cname out of zone",
+			qname: "ext.example.com.",
+			qtype: dns.TypeA,
+			want:  "ext.example.com. 10 IN CNAME www.example.org.",
+		},
+		{
+			name:  "wildcard",
+			qname: "a.b.dev.example.com.",
+			qtype: dns.TypeA,
+			want:  "a.b.dev.example.com. 10 IN A 10.0.1.1",
+		},
+		{
+			name:  "apex",
+			qname: "example.com.",
+			qtype: dns.TypeA,
+			want:  "example.com. 10 IN A 10.0.0.10",
+		},
+		{
+			name:  "apex mx",
+			qname: "example.com.",
+			qtype: dns.TypeMX,
+			want:  "example.com. 10 IN MX 10 mail.",
+		},
+		{
+			name:  "apex default ns",
+			qname: "example.com.",
+			qtype: dns.TypeNS,
+			want:  "example.com. 10 IN NS " + defaultNSName,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rrs, nxdomain := pz.lookup(c.qname, c.qtype, 0)
+			if got := testRRsString(rrs); got != c.want {
+				t.Errorf("lookup(%s) = %q, want %q", c.qname, got, c.want)
+			}
+			if nxdomain != c.wantNxdomain {
+				t.Errorf("lookup(%s) nxdomain = %v, want %v", c.qname, nxdomain, c.wantNxdomain)
+			}
+		})
+	}
+
+	t.Run("cname loop", func(t *testing.T) {
+		rrs, _ := pz.lookup("loop1.example.com.", dns.TypeA, 0)
+		if len(rrs) != maxCNAMEDepth+1 {
+			t.Errorf("lookup(loop1) = %d records, want %d", len(rrs), maxCNAMEDepth+1)
+		}
+	})
+}
+
+func TestRecordSetRR(t *testing.T) {
+	cases := []struct {
+		name    string
+		rec     models.SDnsRecordSet
+		ttl     int64
+		prio    int64
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "a",
+			rec:  testRecordSet("", "www", "A", " 10.0.0.1 "),
+			want: "www.example.com. 10 IN A 10.0.0.1",
+		},
+		{
+			name: "aaaa with ttl",
+			rec:  testRecordSet("", "www", "AAAA", "fd00::1"),
+			ttl:  300,
+			want: "www.example.com. 300 IN AAAA fd00::1",
+		},
+		{
+			name: "cname",
+			rec:  testRecordSet("", "www", "CNAME", "web.example.com"),
+			want: "www.example.com. 10 IN CNAME web.example.com.",
+		},
+		{
+			name: "ptr",
+			rec:  testRecordSet("", "www", "PTR", "web.example.com."),
+			want: "www.example.com. 10 IN PTR web.example.com.",
+		},
+		{
+			name: "mx",
+			rec:  testRecordSet("", "www", "MX", "mail.example.com"),
+			prio: 5,
+			want: "www.example.com. 10 IN MX 5 mail.example.com.",
+		},
+		{
+			name: "srv",
+			rec:  testRecordSet("", "www", "SRV", "10 20 5060 sip.example.com"),
+			want: "www.example.com. 10 IN SRV 10 20 5060 sip.example.com.",
+		},
+		{
+			name:    "invalid srv",
+			rec:     testRecordSet("", "www", "SRV", "10 20 sip.example.com"),
+			wantErr: true,
+		},
+		{
+			name: "txt",
+			rec:  testRecordSet("", "www", "TXT", `say "hi"`),
+			want: `www.example.com. 10 IN TXT "say \"hi\""`,
+		},
+		{
+			name: "quoted txt",
+			rec:  testRecordSet("", "www", "TXT", `"v=spf1 -all"`),
+			want: `www.example.com. 10 IN TXT "v=spf1 -all"`,
+		},
+		{
+			name: "caa",
+			rec:  testRecordSet("", "www", "CAA", `0 issue "ca.example.net"`),
+			want: `www.example.com. 10 IN CAA 0 issue "ca.example.net"`,
+		},
+		{
+			name:    "unsupported",
+			rec:     testRecordSet("", "www", "HINFO", "x y"),
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.rec.TTL = c.ttl
+			c.rec.MxPriority = c.prio
+			rr, err := recordSetRR("www.example.com.", &c.rec)
+			if c.wantErr {
+				if err == nil {
+					t.Errorf("recordSetRR() = %s, want error", rr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("recordSetRR() error: %v", err)
+			}
+			if got := testRRsString([]dns.RR{rr}); got != c.want {
+				t.Errorf("recordSetRR() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestPrivateZoneApplyTrafficPolicies(t *testing.T) {
+	srcZone := &models.SZone{}
+	srcZone.Id = "zone0"
+	srcZone.Name = "zone-name0"
+
+	cases := []struct {
+		name     string
+		sets     []string
+		policies map[string]*models.SDnsTrafficPolicy
+		srcZone  *models.SZone
+		want     string
+	}{
+		{
+			name: "no policy",
+			sets: []string{"r0", "r1"},
+			want: "r0,r1",
+		},
+		{
+			name: "simple",
+			sets: []string{"r0", "r1"},
+			policies: map[string]*models.SDnsTrafficPolicy{
+				"r0": testPolicy(cloudprovider.DnsPolicyTypeSimple, ""),
+			},
+			want: "r0,r1",
+		},
+		{
+			name: "geo by zone id",
+			sets: []string{"r0", "r1", "r2", "r3"},
+			policies: map[string]*models.SDnsTrafficPolicy{
+				"r0": testPolicy(cloudprovider.DnsPolicyTypeByGeoLocation, "zone0"),
+				"r1": testPolicy(cloudprovider.DnsPolicyTypeByGeoLocation, "zone1"),
+				"r2": testPolicy(cloudprovider.DnsPolicyTypeByGeoLocation, string(cloudprovider.DnsPolicyValueDefault)),
+			},
+			srcZone: srcZone,
+			want:    "r3,r0",
+		},
+		{
+			name: "geo by zone name",
+			sets: []string{"r0", "r1"},
+			policies: map[string]*models.SDnsTrafficPolicy{
+				"r0": testPolicy(cloudprovider.DnsPolicyTypeByGeoLocation, "zone-name0"),
+				"r1": testPolicy(cloudprovider.DnsPolicyTypeByGeoLocation, ""),
+			},
+			srcZone: srcZone,
+			want:    "r0",
+		},
+		{
+			name: "geo falls back to default",
+			sets: []string{"r0", "r1", "r2"},
+			policies: map[string]*models.SDnsTrafficPolicy{
+				"r0": testPolicy(cloudprovider.DnsPolicyTypeByGeoLocation, "zone1"),
+				"r1": testPolicy(cloudprovider.DnsPolicyTypeByGeoLocation, string(cloudprovider.DnsPolicyValueDefault)),
+				"r2": testPolicy(cloudprovider.DnsPolicyTypeByGeoLocation, ""),
+			},
+			srcZone: srcZone,
+			want:    "r1,r2",
+		},
+		{
+			name: "geo without source zone",
+			sets: []string{"r0", "r1"},
+			policies: map[string]*models.SDnsTrafficPolicy{
+				"r0": testPolicy(cloudprovider.DnsPolicyTypeByGeoLocation, "zone0"),
+				"r1": testPolicy(cloudprovider.DnsPolicyTypeByGeoLocation, string(cloudprovider.DnsPolicyValueDefault)),
+			},
+			want: "r1",
+		},
+		{
+			name: "weighted",
+			sets: []string{"r0", "r1", "r2", "r3"},
+			policies: map[string]*models.SDnsTrafficPolicy{
+				"r0": testPolicy(cloudprovider.DnsPolicyTypeWeighted, "0"),
+				"r1": testPolicy(cloudprovider.DnsPolicyTypeWeighted, "5"),
+				"r2": testPolicy(cloudprovider.DnsPolicyTypeWeighted, "invalid"),
+			},
+			want: "r3,r1",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pz := testPrivateZone("example.com")
+			pz.srcZone = c.srcZone
+			if c.policies != nil {
+				pz.policies = c.policies
+			}
+			sets := []models.SDnsRecordSet{}
+			for _, id := range c.sets {
+				sets = append(sets, testRecordSet(id, "www", "A", "10.0.0.1"))
+			}
+			got := testRecordSetIds(pz.applyTrafficPolicies(sets))
+			if got != c.want {
+				t.Errorf("applyTrafficPolicies() = %q, want %q", got, c.want)
+			}
+		})
+	}
+
+	t.Run("weighted picks one", func(t *testing.T) {
+		pz := testPrivateZone("example.com")
+		pz.policies = map[string]*models.SDnsTrafficPolicy{
+			"r0": testPolicy(cloudprovider.DnsPolicyTypeWeighted, "1"),
+			"r1": testPolicy(cloudprovider.DnsPolicyTypeWeighted, "1"),
+		}
+		sets := []models.SDnsRecordSet{
+			testRecordSet("r0", "www", "A", "10.0.0.1"),
+			testRecordSet("r1", "www", "A", "10.0.0.2"),
+		}
+		for i := 0; i < 10; i++ {
+			if got := pz.applyTrafficPolicies(sets); len(got) != 1 {
+				t.Fatalf("applyTrafficPolicies() = %q, want one record set", testRecordSetIds(got))
+			}
+		}
+	})
+}
@@ -54,6 +54,7 @@ func setup(c *caddy.Controller) error {
 	if err != nil {
 		return plugin.Error(PluginName, err)
 	}
+	rDNS.initPrivateZoneCache()
 
 	if !rDNS.K8sSkip {
 		go rDNS.initK8s()
@@ -16,14 +16,23 @@ package dns
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/coredns/coredns/request"
 	"github.com/miekg/dns"
+
+	ylog "yunion.io/x/log"
 )
 
+// transferLength is the size in bytes after which a new envelope is started
+const transferLength = 1000
+
 // Serial implements the Transferer interface
 func (r *SRegionDNS) Serial(state request.Request) uint32 {
+	if pz := r.getPrivateZone(state); pz != nil {
+		return pz.serial()
+	}
 	return uint32(time.Now().Unix())
 }
 
@@ -33,6 +42,36 @@ func (r *SRegionDNS) MinTTL(state request.Request) uint32 {
 }
 
 // Transferer implements the Transferer interface
+//
+// Only private zones bound to the vpc of the requester can be transferred
 func (r *SRegionDNS) Transfer(ctx context.Context, state request.Request) (int, error) {
-	return dns.RcodeServerFailure, nil
+	pz := r.getPrivateZone(state)
+	if pz == nil || strings.ToLower(state.Name()) != pz.origin {
+		return dns.RcodeServerFailure, nil
+	}
+
+	records := pz.all(0, r.MinTTL(state))
+	records = append(records, records[0]) // add closing SOA to the end
+
+	ch := make(chan *dns.Envelope)
+	defer close(ch)
+	tr := new(dns.Transfer)
+	go tr.Out(state.W, state.Req, ch)
+
+	ylog.Infof("Outgoing transfer of %d records of zone %s to %s started", len(records), pz.origin, state.IP())
+	j, l := 0, 0
+	for i, rr := range records {
+		l += dns.Len(rr)
+		if l > transferLength {
+			ch <- &dns.Envelope{RR: records[j:i]}
+			l = 0
+			j = i
+		}
+	}
+	if j < len(records) {
+		ch <- &dns.Envelope{RR: records[j:]}
+	}
+
+	state.W.Hijack()
+	return dns.RcodeSuccess, nil
 }
@@ -75,6 +75,7 @@ func AddGuestTaskHandler(prefix string, app *appsrv.Application) {
 			"drive-mirror":         guestDriveMirror,
 			"hotplug-cpu-mem":      guestHotplugCpuMem,
 			"cancel-block-jobs":    guestCancelBlockJobs,
+			"cancel-live-migrate":  guestCancelLiveMigrate,
 			"create-from-libvirt":  guestCreateFromLibvirt,
 			"create-form-esxi":     guestCreateFromEsxi,
 			"open-forward":         guestOpenForward,
@@ -424,6 +425,14 @@ func guestCancelBlockJobs(ctx context.Context, sid string, body jsonutils.JSONOb
 	return nil, nil
 }
 
+func guestCancelLiveMigrate(ctx context.Context, sid string, body jsonutils.JSONObject) (interface{}, error) {
+	if !guestman.GetGuestManager().IsGuestExist(sid) {
+		return nil, httperrors.NewNotFoundError("Guest %s not found", sid)
+	}
+	hostutils.DelayTaskWithoutReqctx(ctx, guestman.GetGuestManager().CancelLiveMigrate, sid)
+	return nil, nil
+}
+
 func guestHotplugCpuMem(ctx context.Context, sid string, body jsonutils.JSONObject) (interface{}, error) {
 	if !guestman.GetGuestManager().IsGuestExist(sid) {
 		return nil, httperrors.NewNotFoundError("Guest %s not found", sid)
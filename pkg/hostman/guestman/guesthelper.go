@@ -83,6 +83,13 @@ type SDiskSnapshot struct {
 	Disk       storageman.IDisk
 }
 
+type SGuestDiskMigrateStorage struct {
+	Sid             string
+	Disk            storageman.IDisk
+	TargetDisk      storageman.IDisk
+	TargetStorageId string
+}
+
 type SDeleteDiskSnapshot struct {
 	Sid             string
 	DeleteSnapshot  string
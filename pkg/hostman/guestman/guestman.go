@@ -969,6 +969,17 @@ func (m *SGuestManager) StartDriveMirror(ctx context.Context, params interface{}
 	return nil, nil
 }
 
+func (m *SGuestManager) MigrateDiskStorage(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	migrateParams, ok := params.(*SGuestDiskMigrateStorage)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	guest, _ := m.GetServer(migrateParams.Sid)
+	NewGuestDiskMigrateStorageTask(ctx, guest, migrateParams.Disk,
+		migrateParams.TargetDisk, migrateParams.TargetStorageId).Start()
+	return nil, nil
+}
+
 func (m *SGuestManager) CancelBlockJobs(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
 	sid, ok := params.(string)
 	if !ok {
@@ -990,6 +1001,25 @@ func (m *SGuestManager) CancelBlockJobs(ctx context.Context, params interface{})
 	return nil, nil
 }
 
+// CancelLiveMigrate cancels the outgoing migration of the guest, the live
+// migrate task waiting for it on this host fails on status cancelled
+func (m *SGuestManager) CancelLiveMigrate(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	sid, ok := params.(string)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	guest, _ := m.GetServer(sid)
+	if guest == nil || !guest.IsMonitorAlive() {
+		return nil, nil
+	}
+	guest.Monitor.MigrateCancel(func(res string) {
+		if len(res) > 0 {
+			log.Errorf("Guest %s cancel live migrate: %s", sid, res)
+		}
+	})
+	return nil, nil
+}
+
 func (m *SGuestManager) HotplugCpuMem(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
 	hotplugParams, ok := params.(*SGuestHotplugCpuMem)
 	if !ok {
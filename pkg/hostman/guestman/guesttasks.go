@@ -27,6 +27,7 @@ import (
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/utils"
 
+	api "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/appctx"
 	"yunion.io/x/onecloud/pkg/hostman/hostutils"
 	"yunion.io/x/onecloud/pkg/hostman/options"
@@ -293,6 +294,9 @@ func (d *SGuestDiskSyncTask) startAddDisk(disk jsonutils.JSONObject) {
 		"cache": cacheMode,
 		"aio":   aio,
 	}
+	if iDisk.GetType() == api.STORAGE_LVM {
+		params["format"] = "raw"
+	}
 
 	var bus string
 	switch diskDirver {
@@ -531,7 +535,7 @@ func (s *SGuestLiveMigrateTask) onGetMigrateStatus(status string) {
 	if status == "completed" {
 		close(s.c)
 		hostutils.TaskComplete(s.ctx, nil)
-	} else if status == "failed" {
+	} else if status == "failed" || status == "cancelled" {
 		close(s.c)
 		hostutils.TaskFailed(s.ctx, fmt.Sprintf("Query migrate got status: %s", status))
 	}
@@ -686,6 +690,7 @@ type SGuestStreamDisksTask struct {
 
 	c          chan struct{}
 	streamDevs []string
+	streamCnt  int
 }
 
 func NewGuestStreamDisksTask(ctx context.Context, guest *SKVMGuestInstance, callback func(), disksIdx []int) *SGuestStreamDisksTask {
@@ -743,6 +748,7 @@ func (s *SGuestStreamDisksTask) onBlockDrivesSucc(res *jsonutils.JSONArray) {
 		}
 	}
 	log.Infof("Stream devices: %v", s.streamDevs)
+	s.streamCnt = len(s.streamDevs)
 	if len(s.streamDevs) == 0 {
 		s.taskComplete()
 	} else {
@@ -755,6 +761,10 @@ func (s *SGuestStreamDisksTask) startDoBlockStream() {
 	if len(s.streamDevs) > 0 {
 		dev := s.streamDevs[0]
 		s.streamDevs = s.streamDevs[1:]
+		if s.streamCnt > 0 {
+			done := s.streamCnt - len(s.streamDevs) - 1
+			hostutils.TaskProgress(s.ctx, float32(done*100)/float32(s.streamCnt), fmt.Sprintf("stream %s", dev))
+		}
 		s.Monitor.BlockStream(dev, s.startWaitBlockStream)
 	} else {
 		s.taskComplete()
@@ -1148,6 +1158,133 @@ func (s *SDriveMirrorTask) startMirror(res string) {
 	}
 }
 
+/**
+ *  GuestDiskMigrateStorageTask
+**/
+
+type SGuestDiskMigrateStorageTask struct {
+	*SKVMGuestInstance
+
+	ctx             context.Context
+	disk            storageman.IDisk
+	targetDisk      storageman.IDisk
+	targetStorageId string
+	drive           string
+}
+
+func NewGuestDiskMigrateStorageTask(
+	ctx context.Context, s *SKVMGuestInstance, disk, targetDisk storageman.IDisk, targetStorageId string,
+) *SGuestDiskMigrateStorageTask {
+	return &SGuestDiskMigrateStorageTask{
+		SKVMGuestInstance: s,
+		ctx:               ctx,
+		disk:              disk,
+		targetDisk:        targetDisk,
+		targetStorageId:   targetStorageId,
+	}
+}
+
+func (s *SGuestDiskMigrateStorageTask) Start() {
+	disk := s.getDiskDesc()
+	if disk == nil {
+		s.taskFailed(fmt.Sprintf("disk %s not found on this guest", s.disk.GetId()))
+		return
+	}
+	index, _ := disk.Int("index")
+	s.drive = fmt.Sprintf("drive_%d", index)
+	log.Infof("Guest %s start mirror %s to %s", s.GetName(), s.drive, s.targetDisk.GetPath())
+	s.Monitor.DriveMirror(s.onDriveMirror, s.drive, s.targetDisk.GetPath(), "full", true, false)
+}
+
+func (s *SGuestDiskMigrateStorageTask) getDiskDesc() *jsonutils.JSONDict {
+	disks, _ := s.Desc.GetArray("disks")
+	for _, disk := range disks {
+		diskId, _ := disk.GetString("disk_id")
+		if diskId == s.disk.GetId() {
+			return disk.(*jsonutils.JSONDict)
+		}
+	}
+	return nil
+}
+
+func (s *SGuestDiskMigrateStorageTask) onDriveMirror(res string) {
+	if len(res) > 0 {
+		s.taskFailed(fmt.Sprintf("drive mirror %s: %s", s.drive, res))
+		return
+	}
+	s.waitBlockJob(s.onMirrorJob)
+}
+
+func (s *SGuestDiskMigrateStorageTask) waitBlockJob(callback func(jsonutils.JSONObject)) {
+	time.AfterFunc(time.Second*3, func() {
+		s.Monitor.GetBlockJobs(func(jobs *jsonutils.JSONArray) {
+			var job jsonutils.JSONObject
+			if jobs != nil {
+				for _, j := range jobs.Value() {
+					if device, _ := j.GetString("device"); device == s.drive {
+						job = j
+						break
+					}
+				}
+			}
+			callback(job)
+		})
+	})
+}
+
+func (s *SGuestDiskMigrateStorageTask) onMirrorJob(job jsonutils.JSONObject) {
+	if job == nil {
+		s.taskFailed(fmt.Sprintf("mirror job of %s aborted", s.drive))
+		return
+	}
+	offset, _ := job.Int("offset")
+	length, _ := job.Int("len")
+	// hmp monitor has no ready flag, mirror is ready once all data synced
+	if jsonutils.QueryBoolean(job, "ready", false) || (!job.Contains("ready") && length > 0 && offset == length) {
+		s.Monitor.BlockJobComplete(s.drive, s.onBlockJobComplete)
+		return
+	}
+	log.Infof("Guest %s mirror %s: %d/%d", s.GetName(), s.drive, offset, length)
+	if length > 0 {
+		hostutils.TaskProgress(s.ctx, float32(offset*100)/float32(length), fmt.Sprintf("mirror %s", s.drive))
+	}
+	s.waitBlockJob(s.onMirrorJob)
+}
+
+func (s *SGuestDiskMigrateStorageTask) onBlockJobComplete(res string) {
+	if len(res) > 0 {
+		s.Monitor.CancelBlockJob(s.drive, true, func(string) {
+			s.taskFailed(fmt.Sprintf("block job complete %s: %s", s.drive, res))
+		})
+		return
+	}
+	s.waitBlockJob(s.onPivotJob)
+}
+
+func (s *SGuestDiskMigrateStorageTask) onPivotJob(job jsonutils.JSONObject) {
+	if job != nil {
+		s.waitBlockJob(s.onPivotJob)
+		return
+	}
+	targetDesc := s.targetDisk.GetDiskDesc()
+	disk := s.getDiskDesc()
+	disk.Set("path", jsonutils.NewString(s.targetDisk.GetPath()))
+	disk.Set("storage_id", jsonutils.NewString(s.targetStorageId))
+	if format, _ := targetDesc.GetString("disk_format"); len(format) > 0 {
+		disk.Set("format", jsonutils.NewString(format))
+	}
+	if err := s.SaveDesc(s.Desc); err != nil {
+		log.Errorf("guest %s save desc after disk migrated: %s", s.GetName(), err)
+	}
+	log.Infof("Guest %s disk %s migrated to %s", s.GetName(), s.disk.GetId(), s.targetDisk.GetPath())
+	hostutils.TaskComplete(s.ctx, targetDesc)
+}
+
+func (s *SGuestDiskMigrateStorageTask) taskFailed(reason string) {
+	log.Errorf("SGuestDiskMigrateStorageTask error: %s", reason)
+	hostutils.TaskFailed(s.ctx, reason)
+}
+
 /**
  *  GuestOnlineResizeDiskTask
 **/
@@ -27,6 +27,7 @@ import (
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/utils"
 
+	api "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/hostman/options"
 	"yunion.io/x/onecloud/pkg/hostman/storageman"
 	"yunion.io/x/onecloud/pkg/util/fileutils2"
@@ -139,6 +140,11 @@ func (s *SKVMGuestInstance) getDriveDesc(disk jsonutils.JSONObject, format strin
 	diskIndex, _ := disk.Int("index")
 	cacheMode, _ := disk.GetString("cache_mode")
 	aioMode, _ := disk.GetString("aio_mode")
+	diskPath, _ := disk.GetString("path")
+	if d := storageman.GetManager().GetDiskByPath(diskPath); d != nil && d.GetType() == api.STORAGE_LVM {
+		// lvm volumes are raw block devices, don't let qemu probe the format
+		format = "raw"
+	}
 
 	cmd := " -drive"
 	cmd += fmt.Sprintf(" file=$DISK_%d", diskIndex)
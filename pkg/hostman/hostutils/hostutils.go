@@ -91,6 +91,20 @@ func TaskComplete(ctx context.Context, params jsonutils.JSONObject) {
 	}
 }
 
+// TaskProgress reports progress of the region task waiting on ctx, it is a
+// no-op for works not started by a region task
+func TaskProgress(ctx context.Context, progress float32, message string) {
+	if ctx == nil {
+		return
+	}
+	if taskId := ctx.Value(appctx.APP_CONTEXT_KEY_TASK_ID); taskId != nil {
+		err := modules.ComputeTasks.SetProgress(GetComputeSession(ctx), taskId.(string), progress, message)
+		if err != nil {
+			log.Errorf("Set task %s progress error: %v", taskId, err)
+		}
+	}
+}
+
 func K8sTaskFailed(ctx context.Context, reason string) {
 	if taskId := ctx.Value(appctx.APP_CONTEXT_KEY_TASK_ID); taskId != nil {
 		k8s.KubeTasks.TaskFailed(GetK8sSession(ctx), taskId.(string), reason)
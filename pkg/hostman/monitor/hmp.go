@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -341,6 +342,10 @@ func (m *HmpMonitor) GetMigrateStatus(callback StringCallback) {
 	m.Query("info migrate", cb)
 }
 
+func (m *HmpMonitor) MigrateCancel(callback StringCallback) {
+	m.Query("migrate_cancel", callback)
+}
+
 func (m *HmpMonitor) GetBlockJobCounts(callback func(jobs int)) {
 	cb := func(output string) {
 		lines := strings.Split(strings.TrimSuffix(output, "\r\n"), "\r\n")
@@ -354,28 +359,43 @@ func (m *HmpMonitor) GetBlockJobCounts(callback func(jobs int)) {
 	m.Query("info block-jobs", cb)
 }
 
-func (m *HmpMonitor) GetBlockJobs(callback func(*jsonutils.JSONArray)) {
-	cb := func(output string) {
-		lines := strings.Split(strings.TrimSuffix(output, "\r\n"), "\r\n")
-		if lines[0] == "No active jobs" {
-			callback(nil)
-		} else {
-			res := jsonutils.NewArray()
-			re := regexp.MustCompile(`Type (?P<type>\w+), device (?P<device>\w+)`)
-			for i := 0; i < len(lines); i++ {
-				m := regutils2.GetParams(re, lines[i])
-				if len(m) > 0 {
-					jobType, _ := m["type"]
-					device, _ := m["device"]
-					jobInfo := jsonutils.NewDict()
-					jobInfo.Set("type", jsonutils.NewString(jobType))
-					jobInfo.Set("device", jsonutils.NewString(device))
-					res.Add(jobInfo)
-				}
+var (
+	hmpBlockJobPattern         = regexp.MustCompile(`Type (?P<type>\w+), device (?P<device>[^:,\s]+)`)
+	hmpBlockJobProgressPattern = regexp.MustCompile(`Completed (?P<offset>\d+) of (?P<len>\d+) bytes`)
+)
+
+// parseHmpBlockJobs parses the output of info block-jobs, e.g.
+// Type mirror, device drive_0: Completed 1048576 of 10737418240 bytes, speed limit 0 bytes/s
+func parseHmpBlockJobs(output string) *jsonutils.JSONArray {
+	lines := strings.Split(strings.TrimSuffix(output, "\r\n"), "\r\n")
+	if lines[0] == "No active jobs" {
+		return nil
+	}
+	res := jsonutils.NewArray()
+	for i := 0; i < len(lines); i++ {
+		m := regutils2.GetParams(hmpBlockJobPattern, lines[i])
+		if len(m) > 0 {
+			jobType, _ := m["type"]
+			device, _ := m["device"]
+			jobInfo := jsonutils.NewDict()
+			jobInfo.Set("type", jsonutils.NewString(jobType))
+			jobInfo.Set("device", jsonutils.NewString(device))
+			if p := regutils2.GetParams(hmpBlockJobProgressPattern, lines[i]); len(p) > 0 {
+				offset, _ := strconv.ParseInt(p["offset"], 10, 64)
+				length, _ := strconv.ParseInt(p["len"], 10, 64)
+				jobInfo.Set("offset", jsonutils.NewInt(offset))
+				jobInfo.Set("len", jsonutils.NewInt(length))
 			}
-			callback(res)
+			res.Add(jobInfo)
 		}
 	}
+	return res
+}
+
+func (m *HmpMonitor) GetBlockJobs(callback func(*jsonutils.JSONArray)) {
+	cb := func(output string) {
+		callback(parseHmpBlockJobs(output))
+	}
 
 	m.Query("info block-jobs", cb)
 }
@@ -477,6 +497,10 @@ func (m *HmpMonitor) CancelBlockJob(driveName string, force bool, callback Strin
 	m.Query(cmd, callback)
 }
 
+func (m *HmpMonitor) BlockJobComplete(driveName string, callback StringCallback) {
+	m.Query(fmt.Sprintf("block_job_complete %s", driveName), callback)
+}
+
 func (m *HmpMonitor) NetdevAdd(id, netType string, params map[string]string, callback StringCallback) {
 	cmd := fmt.Sprintf("netdev_add %s,id=%s", netType, id)
 	for k, v := range params {
@@ -39,3 +39,44 @@ func TestHmpMonitor_Connect(t *testing.T) {
 	time.Sleep(3 * time.Second)
 	m.Disconnect()
 }
+
+func TestParseHmpBlockJobs(t *testing.T) {
+	cases := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{
+			name:   "no jobs",
+			output: "No active jobs\r\n",
+			want:   "",
+		},
+		{
+			name:   "mirror in progress",
+			output: "Type mirror, device drive_0: Completed 1048576 of 10737418240 bytes, speed limit 0 bytes/s\r\n",
+			want:   `[{"device":"drive_0","len":10737418240,"offset":1048576,"type":"mirror"}]`,
+		},
+		{
+			name: "multiple jobs",
+			output: "Type mirror, device drive-virtio-disk0: Completed 0 of 0 bytes, speed limit 0 bytes/s\r\n" +
+				"Type stream, device drive_1: Completed 512 of 1024 bytes, speed limit 0 bytes/s\r\n",
+			want: `[{"device":"drive-virtio-disk0","len":0,"offset":0,"type":"mirror"},{"device":"drive_1","len":1024,"offset":512,"type":"stream"}]`,
+		},
+		{
+			name:   "job without progress",
+			output: "Type commit, device drive_0\r\n",
+			want:   `[{"device":"drive_0","type":"commit"}]`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := ""
+			if jobs := parseHmpBlockJobs(c.output); jobs != nil {
+				got = jobs.String()
+			}
+			if got != c.want {
+				t.Errorf("parseHmpBlockJobs() = %s, want %s", got, c.want)
+			}
+		})
+	}
+}
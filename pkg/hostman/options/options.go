@@ -67,6 +67,7 @@ type SHostOptions struct {
 	PrivatePrefixes []string `help:"IPv4 private prefixes"`
 	LocalImagePath  []string `help:"Local image storage paths"`
 	SharedStorages  []string `help:"Path of shared storages"`
+	LvmThinPools    []string `help:"LVM thin pools used as local storage, format is <vg>/<thin_pool>"`
 
 	DefaultQemuVersion string `help:"Default qemu version" default:"2.12.1"`
 
@@ -53,6 +53,7 @@ type SStorageManager struct {
 
 	RbdStorageImagecacheManagers        map[string]IImageCacheManger
 	SharedFileStorageImagecacheManagers map[string]IImageCacheManger
+	LVMStorageImagecacheManagers        map[string]IImageCacheManger
 }
 
 func NewStorageManager(host hostutils.IHost) (*SStorageManager, error) {
@@ -77,6 +78,18 @@ func NewStorageManager(host hostutils.IHost) (*SStorageManager, error) {
 		}
 	}
 
+	for i, d := range options.HostOptions.LvmThinPools {
+		s := NewLVMStorage(ret, d, i)
+		if err := s.Accessible(); err == nil {
+			ret.Storages = append(ret.Storages, s)
+			if allFull && s.GetFreeSizeMb() > MINIMAL_FREE_SPACE {
+				allFull = false
+			}
+		} else {
+			log.Errorf("lvm thin pool %s not accessible: %v", d, err)
+		}
+	}
+
 	for _, d := range options.HostOptions.SharedStorages {
 		s := ret.NewSharedStorageInstance(d, "")
 		if s != nil {
@@ -101,6 +114,8 @@ func (s *SStorageManager) Remove(storage IStorage) {
 		delete(s.SharedFileStorageImagecacheManagers, storage.GetStoragecacheId())
 	} else if storage.StorageType() == api.STORAGE_RBD {
 		delete(s.RbdStorageImagecacheManagers, storage.GetStoragecacheId())
+	} else if storage.StorageType() == api.STORAGE_LVM {
+		delete(s.LVMStorageImagecacheManagers, storage.GetStoragecacheId())
 	}
 	for index, iS := range s.Storages {
 		if iS.GetId() == storage.GetId() {
@@ -235,6 +250,9 @@ func (s *SStorageManager) GetStoragecacheById(scId string) IImageCacheManger {
 	if sc, ok := s.RbdStorageImagecacheManagers[scId]; ok {
 		return sc
 	}
+	if sc, ok := s.LVMStorageImagecacheManagers[scId]; ok {
+		return sc
+	}
 	return nil
 }
 
@@ -277,6 +295,19 @@ func (s *SStorageManager) AddRbdStorageImagecache(imagecachePath string, storage
 	}
 }
 
+func (s *SStorageManager) AddLVMStorageImagecache(storage IStorage, storagecacheId string) {
+	if s.LVMStorageImagecacheManagers == nil {
+		s.LVMStorageImagecacheManagers = map[string]IImageCacheManger{}
+	}
+	if _, ok := s.LVMStorageImagecacheManagers[storagecacheId]; !ok {
+		if imagecache := NewImageCacheManager(s, storage.GetPath(), storage, storagecacheId, api.STORAGE_LVM); imagecache != nil {
+			s.LVMStorageImagecacheManagers[storagecacheId] = imagecache
+			return
+		}
+		log.Errorf("failed init storagecache %s for storage %s", storagecacheId, storage.GetStorageName())
+	}
+}
+
 var storageManager *SStorageManager
 
 func GetManager() *SStorageManager {
@@ -345,7 +376,7 @@ func StartSyncStorageSizeTask(interval time.Duration) {
 		manager := GetManager()
 		for i := 0; i < len(manager.Storages); i++ {
 			iS := manager.Storages[i]
-			if iS.StorageType() == api.STORAGE_LOCAL {
+			if utils.IsInStringArray(iS.StorageType(), []string{api.STORAGE_LOCAL, api.STORAGE_CIFS, api.STORAGE_LVM}) {
 				err := iS.SyncStorageSize()
 				if err != nil {
 					log.Errorf("sync storage %s size failed: %s", iS.GetStorageName(), err)
@@ -26,6 +26,8 @@ import (
 
 	deployapi "yunion.io/x/onecloud/pkg/hostman/hostdeployer/apis"
 	"yunion.io/x/onecloud/pkg/hostman/hostdeployer/deployclient"
+	"yunion.io/x/onecloud/pkg/hostman/hostutils"
+	"yunion.io/x/onecloud/pkg/util/qemuimg"
 )
 
 type IDisk interface {
@@ -179,3 +181,25 @@ func (d *SBaseDisk) CreateFromRbdSnapshot(ctx context.Context, napshotUrl, srcDi
 func (d *SBaseDisk) DoDeleteSnapshot(snapshotId string) error {
 	return fmt.Errorf("Not implement disk.DoDeleteSnapshot")
 }
+
+// MigrateDiskStorage copies the data of an offline disk to the disk already
+// prepared on the target storage, the source disk is kept for region to delete
+func MigrateDiskStorage(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	migrateParams, ok := params.(*SDiskMigrateStorage)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	srcImg, err := qemuimg.NewQemuImage(migrateParams.Disk.GetPath())
+	if err != nil {
+		return nil, errors.Wrapf(err, "open source disk %s", migrateParams.Disk.GetPath())
+	}
+	targetImg, err := qemuimg.NewQemuImage(migrateParams.TargetDisk.GetPath())
+	if err != nil {
+		return nil, errors.Wrapf(err, "open target disk %s", migrateParams.TargetDisk.GetPath())
+	}
+	log.Infof("Migrate disk %s to %s offline", srcImg.Path, targetImg.Path)
+	if err := srcImg.ConvertToExisting(targetImg); err != nil {
+		return nil, err
+	}
+	return migrateParams.TargetDisk.GetDiskDesc(), nil
+}
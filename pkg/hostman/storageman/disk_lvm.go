@@ -0,0 +1,246 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageman
+
+import (
+	"context"
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/utils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/appctx"
+	"yunion.io/x/onecloud/pkg/hostman/hostutils"
+)
+
+type SLVMDisk struct {
+	SBaseDisk
+}
+
+func NewLVMDisk(storage IStorage, id string) *SLVMDisk {
+	var ret = new(SLVMDisk)
+	ret.SBaseDisk = *NewBaseDisk(storage, id)
+	return ret
+}
+
+func (d *SLVMDisk) GetType() string {
+	return api.STORAGE_LVM
+}
+
+func (d *SLVMDisk) getStorage() *SLVMStorage {
+	return d.Storage.(*SLVMStorage)
+}
+
+func (d *SLVMDisk) Probe() error {
+	storage := d.getStorage()
+	if !storage.volumeExist(d.Id) {
+		return fmt.Errorf("logical volume %s not found", storage.lvName(d.Id))
+	}
+	return storage.activateVolume(d.Id)
+}
+
+func (d *SLVMDisk) GetPath() string {
+	return d.getStorage().lvPath(d.Id)
+}
+
+func (d *SLVMDisk) GetSnapshotDir() string {
+	return ""
+}
+
+func (d *SLVMDisk) GetDiskDesc() jsonutils.JSONObject {
+	desc := map[string]interface{}{
+		"disk_id":     d.Id,
+		"disk_format": "raw",
+		"disk_path":   d.GetPath(),
+		"disk_size":   d.getStorage().getVolumeSizeMb(d.Id),
+	}
+	return jsonutils.Marshal(desc)
+}
+
+func (d *SLVMDisk) GetDiskSetupScripts(idx int) string {
+	return fmt.Sprintf("DISK_%d=%s\n", idx, d.GetPath())
+}
+
+func (d *SLVMDisk) DeleteAllSnapshot() error {
+	return d.getStorage().deleteDiskSnapshots(d.Id)
+}
+
+func (d *SLVMDisk) Delete(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	log.Infof("Delete guest disk %s", d.GetPath())
+	if err := d.getStorage().removeVolume(d.Id); err != nil {
+		return nil, err
+	}
+	d.Storage.RemoveDisk(d)
+	return nil, nil
+}
+
+// thin snapshots don't depend on the origin volume, remove the old volume directly
+func (d *SLVMDisk) OnRebuildRoot(ctx context.Context, params jsonutils.JSONObject) error {
+	_, err := d.Delete(ctx, params)
+	return err
+}
+
+func (d *SLVMDisk) Resize(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	diskInfo, ok := params.(*jsonutils.JSONDict)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	storage := d.getStorage()
+	sizeMb, _ := diskInfo.Int("size")
+	if int(sizeMb) > storage.getVolumeSizeMb(d.Id) {
+		if err := storage.resizeVolume(d.Id, int(sizeMb)); err != nil {
+			return nil, err
+		}
+	}
+
+	d.ResizeFs(d.GetPath())
+	return d.GetDiskDesc(), nil
+}
+
+func (d *SLVMDisk) PrepareSaveToGlance(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	if err := d.Probe(); err != nil {
+		return nil, err
+	}
+	storage := d.getStorage()
+	backupName := fmt.Sprintf("%s%s_%s", LVM_IMAGESAVE_PREFIX, d.Id, appctx.AppContextTaskId(ctx))
+	if err := storage.cloneVolume(d.Id, backupName); err != nil {
+		return nil, err
+	}
+	res := jsonutils.NewDict()
+	res.Set("backup", jsonutils.NewString(storage.lvPath(backupName)))
+	return res, nil
+}
+
+func (d *SLVMDisk) ResetFromSnapshot(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	resetParams, ok := params.(*SDiskReset)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	storage := d.getStorage()
+	snapshotName := lvmSnapshotName(d.Id, resetParams.SnapshotId)
+	if !storage.volumeExist(snapshotName) {
+		return nil, fmt.Errorf("snapshot %s not found", storage.lvName(snapshotName))
+	}
+	if err := storage.removeVolume(d.Id); err != nil {
+		return nil, err
+	}
+	if err := storage.cloneVolume(snapshotName, d.Id); err != nil {
+		return nil, err
+	}
+	return d.GetDiskDesc(), nil
+}
+
+func (d *SLVMDisk) CleanupSnapshots(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	cleanupParams, ok := params.(*SDiskCleanupSnapshots)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	for _, snapshotId := range cleanupParams.DeleteSnapshots {
+		snapId, _ := snapshotId.GetString()
+		if err := d.DeleteSnapshot(snapId, "", false); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (d *SLVMDisk) PrepareMigrate(liveMigrate bool) (string, error) {
+	return "", fmt.Errorf("Not support")
+}
+
+func (d *SLVMDisk) CreateFromTemplate(ctx context.Context, imageId string, format string, size int64) (jsonutils.JSONObject, error) {
+	imageCacheManager := storageManager.GetStoragecacheById(d.Storage.GetStoragecacheId())
+	if imageCacheManager == nil {
+		return nil, fmt.Errorf("failed to find image cache manger for storage %s", d.Storage.GetStorageName())
+	}
+	imageCache := imageCacheManager.AcquireImage(ctx, imageId, d.GetZoneName(), "", "")
+	if imageCache == nil {
+		return nil, fmt.Errorf("failed to acquire image for storage %s", d.Storage.GetStorageName())
+	}
+	defer imageCacheManager.ReleaseImage(ctx, imageId)
+
+	storage := d.getStorage()
+	if err := storage.removeVolume(d.Id); err != nil {
+		return nil, err
+	}
+	if err := storage.cloneVolume(imageCache.GetName(), d.Id); err != nil {
+		return nil, err
+	}
+	log.Infof("REQSIZE: %d, RETSIZE: %d", size, storage.getVolumeSizeMb(d.Id))
+	if int(size) > storage.getVolumeSizeMb(d.Id) {
+		params := jsonutils.NewDict()
+		params.Set("size", jsonutils.NewInt(size))
+		return d.Resize(ctx, params)
+	}
+	return d.GetDiskDesc(), nil
+}
+
+func (d *SLVMDisk) CreateFromImageFuse(ctx context.Context, url string, size int64) error {
+	return fmt.Errorf("Not support")
+}
+
+func (d *SLVMDisk) CreateRaw(ctx context.Context, sizeMb int, diskFromat string, fsFormat string, encryption bool, diskId string, back string) (jsonutils.JSONObject, error) {
+	if err := d.getStorage().createVolume(d.Id, sizeMb); err != nil {
+		return nil, err
+	}
+
+	if utils.IsInStringArray(fsFormat, []string{"swap", "ext2", "ext3", "ext4", "xfs"}) {
+		d.FormatFs(fsFormat, diskId, d.GetPath())
+	}
+
+	return d.GetDiskDesc(), nil
+}
+
+func (d *SLVMDisk) PostCreateFromImageFuse() {
+	log.Errorf("Not support PostCreateFromImageFuse")
+}
+
+func (d *SLVMDisk) CreateSnapshot(snapshotId string) error {
+	return d.getStorage().snapshotVolume(d.Id, lvmSnapshotName(d.Id, snapshotId))
+}
+
+func (d *SLVMDisk) DeleteSnapshot(snapshotId, convertSnapshot string, pendingDelete bool) error {
+	return d.getStorage().removeVolume(lvmSnapshotName(d.Id, snapshotId))
+}
+
+func (d *SLVMDisk) DiskSnapshot(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	snapshotId, ok := params.(string)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	if err := d.CreateSnapshot(snapshotId); err != nil {
+		return nil, err
+	}
+	res := jsonutils.NewDict()
+	res.Set("location", jsonutils.NewString(d.Storage.GetSnapshotPathByIds(d.Id, snapshotId)))
+	return res, nil
+}
+
+func (d *SLVMDisk) DiskDeleteSnapshot(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	snapshotId, ok := params.(string)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	err := d.DeleteSnapshot(snapshotId, "", false)
+	if err != nil {
+		return nil, err
+	} else {
+		res := jsonutils.NewDict()
+		res.Set("deleted", jsonutils.JSONTrue)
+		return res, nil
+	}
+}
@@ -123,6 +123,20 @@ func (d *SNFSDisk) GetType() string {
 	return api.STORAGE_NFS
 }
 
+type SCIFSDisk struct {
+	SNasDisk
+}
+
+func NewCIFSDisk(storage IStorage, id string) *SCIFSDisk {
+	return &SCIFSDisk{
+		SNasDisk: *NewNasDisk(storage, id),
+	}
+}
+
+func (d *SCIFSDisk) GetType() string {
+	return api.STORAGE_CIFS
+}
+
 type SGPFSDisk struct {
 	SNasDisk
 }
@@ -46,6 +46,7 @@ var (
 		"snapshot":          diskSnapshot,
 		"delete-snapshot":   diskDeleteSnapshot,
 		"cleanup-snapshots": diskCleanupSnapshots,
+		"migrate-storage":   diskMigrateStorage,
 	}
 )
 
@@ -329,3 +330,41 @@ func diskCleanupSnapshots(ctx context.Context, storage storageman.IStorage, disk
 	})
 	return nil, nil
 }
+
+func diskMigrateStorage(ctx context.Context, storage storageman.IStorage, diskId string, disk storageman.IDisk, body jsonutils.JSONObject) (interface{}, error) {
+	if disk == nil {
+		return nil, httperrors.NewNotFoundError("Disk %s not found", diskId)
+	}
+	diskInfo, err := body.Get("disk")
+	if err != nil {
+		return nil, httperrors.NewMissingParameterError("disk")
+	}
+	targetStorageId, err := diskInfo.GetString("target_storage_id")
+	if err != nil {
+		return nil, httperrors.NewMissingParameterError("target_storage_id")
+	}
+	targetStorage := storageman.GetManager().GetStorage(targetStorageId)
+	if targetStorage == nil {
+		return nil, httperrors.NewNotFoundError("Storage %s not found", targetStorageId)
+	}
+	targetDisk := targetStorage.GetDiskById(diskId)
+	if targetDisk == nil {
+		return nil, httperrors.NewNotFoundError("Disk %s not found on storage %s", diskId, targetStorageId)
+	}
+	serverId, _ := diskInfo.GetString("server_id")
+	if len(serverId) > 0 && guestman.GetGuestManager().Status(serverId) == "running" {
+		hostutils.DelayTaskWithoutReqctx(ctx, guestman.GetGuestManager().MigrateDiskStorage,
+			&guestman.SGuestDiskMigrateStorage{
+				Sid:             serverId,
+				Disk:            disk,
+				TargetDisk:      targetDisk,
+				TargetStorageId: targetStorageId,
+			})
+	} else {
+		hostutils.DelayTask(ctx, storageman.MigrateDiskStorage, &storageman.SDiskMigrateStorage{
+			Disk:       disk,
+			TargetDisk: targetDisk,
+		})
+	}
+	return nil, nil
+}
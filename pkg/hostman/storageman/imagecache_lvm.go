@@ -0,0 +1,140 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageman
+
+import (
+	"context"
+
+	"yunion.io/x/log"
+
+	"yunion.io/x/onecloud/pkg/hostman/hostutils"
+	"yunion.io/x/onecloud/pkg/hostman/storageman/remotefile"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+	"yunion.io/x/onecloud/pkg/util/procutils"
+	"yunion.io/x/onecloud/pkg/util/qemuimg"
+	"yunion.io/x/onecloud/pkg/util/qemutils"
+)
+
+type SLVMImageCache struct {
+	imageId   string
+	imageName string
+	Manager   *SLVMImageCacheManager
+}
+
+func NewLVMImageCache(imageId string, imagecacheManager *SLVMImageCacheManager) *SLVMImageCache {
+	imageCache := new(SLVMImageCache)
+	imageCache.imageId = imageId
+	imageCache.Manager = imagecacheManager
+	return imageCache
+}
+
+func (r *SLVMImageCache) GetName() string {
+	return LVM_IMAGECACHE_PREFIX + r.imageId
+}
+
+func (r *SLVMImageCache) GetPath() string {
+	return r.Manager.storage.lvPath(r.GetName())
+}
+
+func (r *SLVMImageCache) Load() bool {
+	log.Debugf("loading lvm imagecache %s", r.GetPath())
+	storage := r.Manager.storage
+	if !storage.volumeExist(r.GetName()) {
+		return false
+	}
+	if err := storage.activateVolume(r.GetName()); err != nil {
+		log.Errorf("failed to activate image cache %s: %s", r.GetPath(), err)
+		return false
+	}
+	return true
+}
+
+func (r *SLVMImageCache) Acquire(ctx context.Context, zone, srcUrl, format string) bool {
+	if r.Load() {
+		return true
+	}
+	localImageCache := storageManager.LocalStorageImagecacheManager.AcquireImage(ctx, r.imageId, zone, srcUrl, format)
+	if localImageCache == nil {
+		log.Errorf("failed to acquireimage %s ", r.imageId)
+		return false
+	}
+	defer storageManager.LocalStorageImagecacheManager.ReleaseImage(ctx, r.imageId)
+	r.imageName = localImageCache.GetName()
+
+	origin, err := qemuimg.NewQemuImage(localImageCache.GetPath())
+	if err != nil {
+		log.Errorf("failed to open local image %s: %s", localImageCache.GetPath(), err)
+		return false
+	}
+	log.Infof("convert local image %s to thin pool %s", r.imageId, r.Manager.storage.lvName(r.Manager.storage.ThinPool))
+	storage := r.Manager.storage
+	tmpName := r.GetName() + "_tmp"
+	if err := storage.removeVolume(tmpName); err != nil {
+		log.Errorf("failed to remove stale volume %s: %s", tmpName, err)
+		return false
+	}
+	sizeMb := int((origin.SizeBytes + 1024*1024 - 1) / 1024 / 1024)
+	if err := storage.createVolume(tmpName, sizeMb); err != nil {
+		log.Errorf("failed to create volume for image %s: %s", r.imageId, err)
+		return false
+	}
+	// -n: the target block device already exists
+	err = procutils.NewRemoteCommandAsFarAsPossible(qemutils.GetQemuImg(),
+		"convert", "-n", "-O", "raw", localImageCache.GetPath(), storage.lvPath(tmpName)).Run()
+	if err != nil {
+		log.Errorf("failed to convert image %s", err)
+		storage.removeVolume(tmpName)
+		return false
+	}
+	if err := storage.renameVolume(tmpName, r.GetName()); err != nil {
+		log.Errorf("failed to rename volume %s: %s", tmpName, err)
+		storage.removeVolume(tmpName)
+		return false
+	}
+	if err := storage.setVolumeReadonly(r.GetName()); err != nil {
+		log.Errorf("failed to set image cache %s readonly: %s", r.GetName(), err)
+	}
+	return r.Load()
+}
+
+func (r *SLVMImageCache) Release() {
+	return
+}
+
+func (r *SLVMImageCache) Remove(ctx context.Context) error {
+	if err := r.Manager.storage.removeVolume(r.GetName()); err != nil {
+		return err
+	}
+
+	go func() {
+		_, err := modules.Storagecachedimages.Detach(hostutils.GetComputeSession(ctx),
+			r.Manager.GetId(), r.imageId, nil)
+		if err != nil {
+			log.Errorf("Fail to delete host cached image: %s", err)
+		}
+	}()
+	return nil
+}
+
+func (r *SLVMImageCache) GetDesc() *remotefile.SImageDesc {
+	return &remotefile.SImageDesc{
+		Size: int64(r.Manager.storage.getVolumeSizeMb(r.GetName())),
+		Name: r.imageName,
+	}
+}
+
+func (r *SLVMImageCache) GetImageId() string {
+	return r.imageId
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageman
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
+	"yunion.io/x/onecloud/pkg/util/fileutils2"
+)
+
+// newLoopLVMStorage creates a thin pool on a loop device backed by a sparse
+// file, the test is skipped unless running as root with lvm tools installed
+func newLoopLVMStorage(t *testing.T) (*SLVMStorage, func()) {
+	if os.Geteuid() != 0 {
+		t.Skip("lvm tests require root")
+	}
+	for _, cmd := range []string{"losetup", "vgcreate", "lvcreate", "lvchange", "vgremove"} {
+		if _, err := exec.LookPath(cmd); err != nil {
+			t.Skipf("lvm tests require %s", cmd)
+		}
+	}
+	run := func(name string, args ...string) string {
+		output, err := exec.Command(name, args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s %s: %v: %s", name, strings.Join(args, " "), err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	file, err := ioutil.TempFile("", "lvm-imagecache")
+	if err != nil {
+		t.Fatalf("TempFile: %v", err)
+	}
+	defer file.Close()
+	if err := file.Truncate(256 * 1024 * 1024); err != nil {
+		os.Remove(file.Name())
+		t.Fatalf("Truncate: %v", err)
+	}
+	dev := run("losetup", "--find", "--show", file.Name())
+	vg := fmt.Sprintf("imagecachetest%d", time.Now().UnixNano())
+	cleanup := func() {
+		exec.Command("vgremove", "-f", vg).Run()
+		exec.Command("pvremove", "-f", dev).Run()
+		exec.Command("losetup", "-d", dev).Run()
+		os.Remove(file.Name())
+	}
+	if output, err := exec.Command("vgcreate", vg, dev).CombinedOutput(); err != nil {
+		cleanup()
+		t.Fatalf("vgcreate: %v: %s", err, output)
+	}
+	if output, err := exec.Command("lvcreate", "-y", "-L", "128m", "-T", vg+"/pool").CombinedOutput(); err != nil {
+		cleanup()
+		t.Fatalf("lvcreate thin pool: %v: %s", err, output)
+	}
+	lockman.Init(lockman.NewInMemoryLockManager())
+	return NewLVMStorage(nil, vg+"/pool", 0), cleanup
+}
+
+func TestLVMImageCacheManager(t *testing.T) {
+	storage, cleanup := newLoopLVMStorage(t)
+	defer cleanup()
+
+	const imageId = "e7d6c1c1-6f7d-4b0e-9a2a-1e0d1b0c5f3a"
+	cacheName := LVM_IMAGECACHE_PREFIX + imageId
+	if err := storage.createVolume(cacheName, 8); err != nil {
+		t.Fatalf("createVolume: %v", err)
+	}
+	if err := storage.setVolumeReadonly(cacheName); err != nil {
+		t.Fatalf("setVolumeReadonly: %v", err)
+	}
+	// a disk volume is not an image cache
+	if err := storage.createVolume("disk", 8); err != nil {
+		t.Fatalf("createVolume: %v", err)
+	}
+	// the cache is loaded again after reboot with its volume inactive
+	if _, err := storage.lvm("lvchange", "-an", storage.lvName(cacheName)); err != nil {
+		t.Fatalf("lvchange: %v", err)
+	}
+
+	manager := NewLVMImageCacheManager(nil, storage, "storagecache")
+	if len(manager.cachedImages) != 1 {
+		t.Fatalf("cachedImages = %v, want only %s", manager.cachedImages, imageId)
+	}
+	cache, ok := manager.cachedImages[imageId]
+	if !ok {
+		t.Fatalf("image cache %s is not loaded", imageId)
+	}
+	if got, want := cache.GetPath(), "/dev/"+storage.VolumeGroup+"/"+cacheName; got != want {
+		t.Errorf("GetPath() = %s, want %s", got, want)
+	}
+	if !fileutils2.Exists(cache.GetPath()) {
+		t.Errorf("image cache %s is not activated", cache.GetPath())
+	}
+	if got := cache.GetDesc().Size; got != 8 {
+		t.Errorf("GetDesc().Size = %d, want 8", got)
+	}
+
+	// the loaded cache is acquired without fetching the image
+	if img := manager.AcquireImage(context.Background(), imageId, "", "", ""); img != cache {
+		t.Errorf("AcquireImage() = %v, want the loaded cache", img)
+	}
+
+	// disks are thin snapshots of the cache
+	if err := storage.cloneVolume(cacheName, "clone"); err != nil {
+		t.Fatalf("cloneVolume: %v", err)
+	}
+	if !storage.volumeExist("clone") {
+		t.Errorf("clone of image cache does not exist")
+	}
+
+	if err := storage.removeVolume(cacheName); err != nil {
+		t.Fatalf("removeVolume: %v", err)
+	}
+	if cache.(*SLVMImageCache).Load() {
+		t.Errorf("Load() of removed image cache = true, want false")
+	}
+	// the clone is independent from the removed origin
+	if !storage.volumeExist("clone") {
+		t.Errorf("clone is removed with its origin")
+	}
+}
+
+func TestLVMImageCacheRenameVolume(t *testing.T) {
+	storage, cleanup := newLoopLVMStorage(t)
+	defer cleanup()
+
+	// Acquire converts the image to a temporary volume and renames it
+	const imageId = "0a4f5e8e-3c0b-4b57-8f1f-0d3c8a1e2b7d"
+	cache := NewLVMImageCache(imageId, &SLVMImageCacheManager{storage: storage})
+	tmpName := cache.GetName() + "_tmp"
+	if err := storage.createVolume(tmpName, 4); err != nil {
+		t.Fatalf("createVolume: %v", err)
+	}
+	if cache.Load() {
+		t.Fatalf("Load() before rename = true, want false")
+	}
+	if err := storage.renameVolume(tmpName, cache.GetName()); err != nil {
+		t.Fatalf("renameVolume: %v", err)
+	}
+	if !cache.Load() {
+		t.Errorf("Load() after rename = false, want true")
+	}
+	if storage.volumeExist(tmpName) {
+		t.Errorf("temporary volume %s still exists", tmpName)
+	}
+	// removing a volume which does not exist is not an error
+	if err := storage.removeVolume(tmpName); err != nil {
+		t.Errorf("removeVolume() of missing volume: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageman
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
+	"yunion.io/x/onecloud/pkg/hostman/hostutils"
+)
+
+// SLVMImageCacheManager caches images as read only thin volumes of the
+// thin pool, disks are created as thin snapshots of the cached volumes.
+type SLVMImageCacheManager struct {
+	SBaseImageCacheManager
+	storage *SLVMStorage
+}
+
+func NewLVMImageCacheManager(manager IStorageManager, storage IStorage, storagecacheId string) *SLVMImageCacheManager {
+	imageCacheManager := new(SLVMImageCacheManager)
+
+	imageCacheManager.storageManager = manager
+	imageCacheManager.storagecacaheId = storagecacheId
+	imageCacheManager.storage = storage.(*SLVMStorage)
+	imageCacheManager.cachePath = storage.GetPath()
+	imageCacheManager.cachedImages = make(map[string]IImageCache, 0)
+	imageCacheManager.loadCache(context.Background())
+	return imageCacheManager
+}
+
+type SLVMImageCacheManagerFactory struct {
+}
+
+func (factory *SLVMImageCacheManagerFactory) NewImageCacheManager(manager *SStorageManager, cachePath string, storage IStorage, storagecacheId string) IImageCacheManger {
+	return NewLVMImageCacheManager(manager, storage, storagecacheId)
+}
+
+func (factory *SLVMImageCacheManagerFactory) StorageType() string {
+	return api.STORAGE_LVM
+}
+
+func init() {
+	registerimageCacheManagerFactory(&SLVMImageCacheManagerFactory{})
+}
+
+func (c *SLVMImageCacheManager) loadCache(ctx context.Context) {
+	lockman.LockRawObject(ctx, "LVM", c.storage.VolumeGroup)
+	defer lockman.ReleaseRawObject(ctx, "LVM", c.storage.VolumeGroup)
+
+	names, err := c.storage.listVolumes()
+	if err != nil {
+		log.Errorf("get storage %s volumes error; %v", c.storage.GetStorageName(), err)
+		return
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, LVM_IMAGECACHE_PREFIX) {
+			c.LoadImageCache(strings.TrimPrefix(name, LVM_IMAGECACHE_PREFIX))
+		}
+	}
+}
+
+func (c *SLVMImageCacheManager) LoadImageCache(imageId string) {
+	imageCache := NewLVMImageCache(imageId, c)
+	if imageCache.Load() {
+		c.cachedImages[imageId] = imageCache
+	}
+}
+
+func (c *SLVMImageCacheManager) PrefetchImageCache(ctx context.Context, data interface{}) (jsonutils.JSONObject, error) {
+	body, ok := data.(*jsonutils.JSONDict)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+
+	imageId, err := body.GetString("image_id")
+	if err != nil {
+		return nil, err
+	}
+	format, _ := body.GetString("format")
+	srcUrl, _ := body.GetString("src_url")
+	zone, _ := body.GetString("zone")
+
+	cache := c.AcquireImage(ctx, imageId, zone, srcUrl, format)
+	if cache == nil {
+		return nil, fmt.Errorf("failed to cache image %s.%s", imageId, format)
+	}
+
+	res := map[string]interface{}{
+		"image_id": imageId,
+		"path":     cache.GetPath(),
+	}
+	if desc := cache.GetDesc(); desc != nil {
+		res["name"] = desc.Name
+		res["size"] = desc.Size
+	}
+	return jsonutils.Marshal(res), nil
+}
+
+func (c *SLVMImageCacheManager) DeleteImageCache(ctx context.Context, data interface{}) (jsonutils.JSONObject, error) {
+	body, ok := data.(*jsonutils.JSONDict)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+
+	imageId, _ := body.GetString("image_id")
+	return nil, c.removeImage(ctx, imageId)
+}
+
+func (c *SLVMImageCacheManager) removeImage(ctx context.Context, imageId string) error {
+	lockman.LockRawObject(ctx, "image-cache", imageId)
+	defer lockman.ReleaseRawObject(ctx, "image-cache", imageId)
+
+	if img, ok := c.cachedImages[imageId]; ok {
+		delete(c.cachedImages, imageId)
+		return img.Remove(ctx)
+	}
+	return nil
+}
+
+func (c *SLVMImageCacheManager) AcquireImage(ctx context.Context, imageId, zone, srcUrl, format string) IImageCache {
+	lockman.LockRawObject(ctx, "image-cache", imageId)
+	defer lockman.ReleaseRawObject(ctx, "image-cache", imageId)
+
+	img, ok := c.cachedImages[imageId]
+	if !ok {
+		img = NewLVMImageCache(imageId, c)
+		c.cachedImages[imageId] = img
+	}
+	if img.Acquire(ctx, zone, srcUrl, format) {
+		return img
+	}
+	return nil
+}
+
+func (c *SLVMImageCacheManager) ReleaseImage(ctx context.Context, imageId string) {
+	lockman.LockRawObject(ctx, "image-cache", imageId)
+	defer lockman.ReleaseRawObject(ctx, "image-cache", imageId)
+	if img, ok := c.cachedImages[imageId]; ok {
+		img.Release()
+	}
+}
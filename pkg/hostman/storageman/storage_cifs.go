@@ -0,0 +1,236 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageman
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/hostman/hostutils"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+	"yunion.io/x/onecloud/pkg/util/fileutils2"
+	"yunion.io/x/onecloud/pkg/util/procutils"
+)
+
+const (
+	// credentials of cifs shares are kept in files instead of mount options,
+	// which are visible in the process list
+	CifsCredentialsPath = "/opt/cloud/workspace/cifs-credentials"
+)
+
+func init() {
+	registerStorageFactory(&SCIFSStorageFactory{})
+}
+
+type SCIFSStorageFactory struct {
+}
+
+func (factory *SCIFSStorageFactory) NewStorage(manager *SStorageManager, mountPoint string) IStorage {
+	return NewCIFSStorage(manager, mountPoint)
+}
+
+func (factory *SCIFSStorageFactory) StorageType() string {
+	return api.STORAGE_CIFS
+}
+
+type SCIFSStorage struct {
+	SNasStorage
+}
+
+func NewCIFSStorage(manager *SStorageManager, path string) *SCIFSStorage {
+	ret := &SCIFSStorage{}
+	ret.SNasStorage = *NewNasStorage(manager, path, ret)
+	if !fileutils2.Exists(path) {
+		procutils.NewCommand("mkdir", "-p", path).Run()
+	}
+	return ret
+}
+
+func (s *SCIFSStorage) newDisk(diskId string) IDisk {
+	return NewCIFSDisk(s, diskId)
+}
+
+func (s *SCIFSStorage) StorageType() string {
+	return api.STORAGE_CIFS
+}
+
+func (s *SCIFSStorage) SyncStorageInfo() (jsonutils.JSONObject, error) {
+	if len(s.StorageId) == 0 {
+		return nil, fmt.Errorf("Sync cifs storage without storage id")
+	}
+	content := jsonutils.NewDict()
+	content.Set("capacity", jsonutils.NewInt(int64(s.GetAvailSizeMb())))
+	content.Set("actual_capacity_used", jsonutils.NewInt(int64(s.GetUsedSizeMb())))
+	content.Set("storage_type", jsonutils.NewString(s.StorageType()))
+	content.Set("status", jsonutils.NewString(api.STORAGE_ONLINE))
+	content.Set("zone", jsonutils.NewString(s.GetZoneName()))
+	log.Infof("Sync storage info %s", s.StorageId)
+	res, err := modules.Storages.Put(
+		hostutils.GetComputeSession(context.Background()),
+		s.StorageId, content)
+	if err != nil {
+		log.Errorf("SyncStorageInfo Failed: %s: %s", content, err)
+	}
+	return res, err
+}
+
+// SyncStorageSize reports the capacity as well, the share may be resized on the file server
+func (s *SCIFSStorage) SyncStorageSize() error {
+	if len(s.StorageId) == 0 {
+		return nil
+	}
+	content := jsonutils.NewDict()
+	content.Set("capacity", jsonutils.NewInt(int64(s.GetAvailSizeMb())))
+	content.Set("actual_capacity_used", jsonutils.NewInt(int64(s.GetUsedSizeMb())))
+	_, err := modules.Storages.Put(
+		hostutils.GetComputeSession(context.Background()),
+		s.StorageId, content)
+	return err
+}
+
+func (s *SCIFSStorage) SetStorageInfo(storageId, storageName string, conf jsonutils.JSONObject) error {
+	s.StorageId = storageId
+	s.StorageName = storageName
+	if dconf, ok := conf.(*jsonutils.JSONDict); ok {
+		s.StorageConf = dconf
+	}
+	if err := s.fetchSecretConf(); err != nil {
+		return errors.Wrap(err, "fetchSecretConf")
+	}
+	if err := s.checkAndMount(); err != nil {
+		return errors.Errorf("Fail to mount storage to mountpoint: %s, %s", s.Path, err)
+	}
+	if !s.isSetStorageInfo && !strings.HasPrefix(s.Path, "/opt/cloud") {
+		err := s.bindMountTo(s.Path)
+		if err != nil {
+			return err
+		}
+		s.isSetStorageInfo = true
+	}
+	return nil
+}
+
+// fetchSecretConf fetches the password of the share, which is left out of
+// the storage conf listed with host storages
+func (s *SCIFSStorage) fetchSecretConf() error {
+	if s.StorageConf == nil || s.StorageConf.Contains("cifs_password") {
+		return nil
+	}
+	if username, _ := s.StorageConf.GetString("cifs_username"); len(username) == 0 {
+		return nil
+	}
+	conf, err := modules.Storages.GetSpecific(
+		hostutils.GetComputeSession(context.Background()),
+		s.StorageId, "host-storage-conf", nil)
+	if err != nil {
+		return err
+	}
+	if dconf, ok := conf.(*jsonutils.JSONDict); ok {
+		s.StorageConf = dconf
+	}
+	return nil
+}
+
+func (s *SCIFSStorage) credentialsFile() string {
+	return path.Join(CifsCredentialsPath, s.StorageId)
+}
+
+func (s *SCIFSStorage) getMountOptions() ([]string, error) {
+	opts := []string{"rw"}
+	username, _ := s.StorageConf.GetString("cifs_username")
+	if len(username) > 0 {
+		password, _ := s.StorageConf.GetString("cifs_password")
+		domain, _ := s.StorageConf.GetString("cifs_domain")
+		content := fmt.Sprintf("username=%s\npassword=%s\n", username, password)
+		if len(domain) > 0 {
+			content += fmt.Sprintf("domain=%s\n", domain)
+		}
+		if err := os.MkdirAll(CifsCredentialsPath, 0700); err != nil {
+			return nil, errors.Wrapf(err, "mkdir %s", CifsCredentialsPath)
+		}
+		if err := ioutil.WriteFile(s.credentialsFile(), []byte(content), 0600); err != nil {
+			return nil, errors.Wrap(err, "write credentials file")
+		}
+		opts = append(opts, "credentials="+s.credentialsFile())
+	} else {
+		opts = append(opts, "guest")
+	}
+	if version, _ := s.StorageConf.GetString("cifs_version"); len(version) > 0 {
+		opts = append(opts, "vers="+version)
+	}
+	return opts, nil
+}
+
+func (s *SCIFSStorage) checkAndMount() error {
+	if err := procutils.NewRemoteCommandAsFarAsPossible("mountpoint", s.Path).Run(); err == nil {
+		return nil
+	}
+	if s.StorageConf == nil {
+		return fmt.Errorf("Storage conf is nil")
+	}
+	host, err := s.StorageConf.GetString("cifs_host")
+	if err != nil {
+		return fmt.Errorf("Storage conf missing cifs_host")
+	}
+	sharedDir, err := s.StorageConf.GetString("cifs_shared_dir")
+	if err != nil {
+		return fmt.Errorf("Storage conf missing cifs_shared_dir")
+	}
+	opts, err := s.getMountOptions()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := procutils.NewRemoteCommandContextAsFarAsPossible(ctx,
+		"mount", "-t", "cifs", fmt.Sprintf("//%s/%s", host, strings.TrimPrefix(sharedDir, "/")), s.Path,
+		"-o", strings.Join(opts, ",")).Output()
+	if err != nil {
+		return errors.Wrapf(err, "mount cifs %s", out)
+	}
+	return nil
+}
+
+func (s *SCIFSStorage) Detach() error {
+	if !strings.HasPrefix(s.Path, "/opt/cloud") {
+		tmpPath := path.Join(TempBindMountPath, s.Path)
+		out, err := procutils.NewCommand("umount", s.Path).Output()
+		if err != nil {
+			return errors.Wrapf(err, "1. umount %s failed %s", s.Path, out)
+		}
+		out, err = procutils.NewRemoteCommandAsFarAsPossible("umount", tmpPath).Output()
+		if err != nil {
+			return errors.Wrapf(err, "2. umount %s failed %s", tmpPath, out)
+		}
+	}
+	out, err := procutils.NewRemoteCommandAsFarAsPossible("umount", s.Path).Output()
+	if err != nil {
+		return errors.Wrapf(err, "3. umount %s failed %s", s.Path, out)
+	}
+	if err := os.Remove(s.credentialsFile()); err != nil && !os.IsNotExist(err) {
+		log.Errorf("remove credentials file of storage %s: %v", s.StorageId, err)
+	}
+	return nil
+}
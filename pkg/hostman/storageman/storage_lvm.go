@@ -0,0 +1,539 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageman
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	deployapi "yunion.io/x/onecloud/pkg/hostman/hostdeployer/apis"
+	"yunion.io/x/onecloud/pkg/hostman/hostdeployer/deployclient"
+	"yunion.io/x/onecloud/pkg/hostman/hostutils"
+	"yunion.io/x/onecloud/pkg/hostman/options"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+	"yunion.io/x/onecloud/pkg/util/fileutils2"
+	"yunion.io/x/onecloud/pkg/util/procutils"
+	"yunion.io/x/onecloud/pkg/util/qemutils"
+)
+
+const (
+	LVM_SNAPSHOT_PREFIX   = "snap_"
+	LVM_IMAGESAVE_PREFIX  = "imgsave_"
+	LVM_IMAGECACHE_PREFIX = "imagecache_"
+)
+
+// SLVMStorage keeps disks as thin logical volumes of a thin pool,
+// the logical volumes are attached to guests as raw block devices.
+type SLVMStorage struct {
+	SBaseStorage
+
+	VolumeGroup string
+	ThinPool    string
+	Index       int
+}
+
+// NewLVMStorage creates the storage of thin pool like `vg/thinpool`,
+// the path of the storage is the device directory of the volume group
+func NewLVMStorage(manager *SStorageManager, thinPool string, index int) *SLVMStorage {
+	var ret = new(SLVMStorage)
+	segs := strings.SplitN(strings.Trim(thinPool, "/"), "/", 2)
+	ret.VolumeGroup = segs[0]
+	if len(segs) == 2 {
+		ret.ThinPool = segs[1]
+	}
+	ret.SBaseStorage = *NewBaseStorage(manager, path.Join("/dev", ret.VolumeGroup))
+	ret.Index = index
+	return ret
+}
+
+func (s *SLVMStorage) StorageType() string {
+	return api.STORAGE_LVM
+}
+
+func (s *SLVMStorage) GetComposedName() string {
+	return fmt.Sprintf("host_%s_%s_storage_%d", s.Manager.host.GetMasterIp(), s.StorageType(), s.Index)
+}
+
+func (s *SLVMStorage) GetSnapshotDir() string {
+	return ""
+}
+
+func (s *SLVMStorage) GetSnapshotPathByIds(diskId, snapshotId string) string {
+	return s.lvPath(lvmSnapshotName(diskId, snapshotId))
+}
+
+func (s *SLVMStorage) IsSnapshotExist(diskId, snapshotId string) (bool, error) {
+	return s.volumeExist(lvmSnapshotName(diskId, snapshotId)), nil
+}
+
+func (s *SLVMStorage) GetFuseTmpPath() string {
+	return ""
+}
+
+func (s *SLVMStorage) GetFuseMountPath() string {
+	return ""
+}
+
+func (s *SLVMStorage) GetImgsaveBackupPath() string {
+	return ""
+}
+
+func lvmSnapshotName(diskId, snapshotId string) string {
+	return fmt.Sprintf("%s%s_%s", LVM_SNAPSHOT_PREFIX, diskId, snapshotId)
+}
+
+func (s *SLVMStorage) lvPath(name string) string {
+	return path.Join(s.Path, name)
+}
+
+func (s *SLVMStorage) lvName(name string) string {
+	return fmt.Sprintf("%s/%s", s.VolumeGroup, name)
+}
+
+func (s *SLVMStorage) lvm(cmd string, args ...string) (string, error) {
+	output, err := procutils.NewRemoteCommandAsFarAsPossible(cmd, args...).Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "%s %s: %s", cmd, strings.Join(args, " "), output)
+	}
+	return string(output), nil
+}
+
+// getPoolSizeMb returns the virtual size and the used size of the thin pool
+func (s *SLVMStorage) getPoolSizeMb() (int, int, error) {
+	output, err := s.lvm("lvs", "--noheadings", "--nosuffix", "--units", "m",
+		"--separator", ":", "-o", "lv_size,data_percent", s.lvName(s.ThinPool))
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Split(strings.TrimSpace(output), ":")
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("invalid lvs output %q", output)
+	}
+	size, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "parse pool size %q", fields[0])
+	}
+	percent, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "parse pool data percent %q", fields[1])
+	}
+	return int(size), int(size * percent / 100), nil
+}
+
+func (s *SLVMStorage) GetCapacity() int {
+	return s.GetAvailSizeMb()
+}
+
+func (s *SLVMStorage) GetAvailSizeMb() int {
+	size, _, err := s.getPoolSizeMb()
+	if err != nil {
+		log.Errorf("failed get thin pool %s size: %s", s.lvName(s.ThinPool), err)
+		return -1
+	}
+	return size
+}
+
+func (s *SLVMStorage) GetUsedSizeMb() int {
+	_, used, err := s.getPoolSizeMb()
+	if err != nil {
+		log.Errorf("failed get thin pool %s used size: %s", s.lvName(s.ThinPool), err)
+		return -1
+	}
+	return used
+}
+
+func (s *SLVMStorage) GetFreeSizeMb() int {
+	size, used, err := s.getPoolSizeMb()
+	if err != nil {
+		log.Errorf("failed get thin pool %s free size: %s", s.lvName(s.ThinPool), err)
+		return -1
+	}
+	return size - used
+}
+
+func (s *SLVMStorage) getVolumeSizeMb(name string) int {
+	output, err := s.lvm("lvs", "--noheadings", "--nosuffix", "--units", "m",
+		"-o", "lv_size", s.lvName(name))
+	if err != nil {
+		log.Errorf("failed get logical volume %s size: %s", s.lvName(name), err)
+		return -1
+	}
+	size, err := strconv.ParseFloat(strings.TrimSpace(output), 64)
+	if err != nil {
+		log.Errorf("failed parse logical volume %s size %q: %s", s.lvName(name), output, err)
+		return -1
+	}
+	return int(size)
+}
+
+func (s *SLVMStorage) listVolumes() ([]string, error) {
+	output, err := s.lvm("lvs", "--noheadings", "-o", "lv_name", s.VolumeGroup)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if name := strings.TrimSpace(line); len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (s *SLVMStorage) volumeExist(name string) bool {
+	return procutils.NewRemoteCommandAsFarAsPossible("lvs", s.lvName(name)).Run() == nil
+}
+
+// activateVolume makes sure the device of the volume exists, thin snapshots
+// are skipped on activation by default and volumes may be inactive after reboot
+func (s *SLVMStorage) activateVolume(name string) error {
+	if fileutils2.Exists(s.lvPath(name)) {
+		return nil
+	}
+	_, err := s.lvm("lvchange", "-ay", "-K", s.lvName(name))
+	return err
+}
+
+func (s *SLVMStorage) createVolume(name string, sizeMb int) error {
+	_, err := s.lvm("lvcreate", "-y", "-n", name, "-V", fmt.Sprintf("%dm", sizeMb),
+		"-T", s.lvName(s.ThinPool))
+	return err
+}
+
+// cloneVolume creates a writable and active thin snapshot of the origin volume
+func (s *SLVMStorage) cloneVolume(origin, name string) error {
+	_, err := s.lvm("lvcreate", "-y", "-s", "-kn", "-p", "rw", "-n", name, s.lvName(origin))
+	return err
+}
+
+func (s *SLVMStorage) snapshotVolume(origin, name string) error {
+	_, err := s.lvm("lvcreate", "-y", "-s", "-p", "r", "-n", name, s.lvName(origin))
+	return err
+}
+
+func (s *SLVMStorage) resizeVolume(name string, sizeMb int) error {
+	_, err := s.lvm("lvextend", "-L", fmt.Sprintf("%dm", sizeMb), s.lvName(name))
+	return err
+}
+
+func (s *SLVMStorage) renameVolume(name, newName string) error {
+	_, err := s.lvm("lvrename", s.VolumeGroup, name, newName)
+	return err
+}
+
+func (s *SLVMStorage) setVolumeReadonly(name string) error {
+	_, err := s.lvm("lvchange", "-p", "r", s.lvName(name))
+	return err
+}
+
+func (s *SLVMStorage) removeVolume(name string) error {
+	if !s.volumeExist(name) {
+		return nil
+	}
+	_, err := s.lvm("lvremove", "-f", s.lvName(name))
+	return err
+}
+
+func (s *SLVMStorage) SyncStorageSize() error {
+	content := jsonutils.NewDict()
+	content.Set("actual_capacity_used", jsonutils.NewInt(int64(s.GetUsedSizeMb())))
+	_, err := modules.Storages.Put(
+		hostutils.GetComputeSession(context.Background()),
+		s.StorageId, content)
+	return err
+}
+
+func (s *SLVMStorage) SyncStorageInfo() (jsonutils.JSONObject, error) {
+	content := jsonutils.NewDict()
+	content.Set("name", jsonutils.NewString(s.GetName(s.GetComposedName)))
+	content.Set("capacity", jsonutils.NewInt(int64(s.GetAvailSizeMb())))
+	content.Set("actual_capacity_used", jsonutils.NewInt(int64(s.GetUsedSizeMb())))
+	content.Set("storage_type", jsonutils.NewString(s.StorageType()))
+	content.Set("medium_type", jsonutils.NewString(s.GetMediumType()))
+	content.Set("zone", jsonutils.NewString(s.GetZoneName()))
+	content.Set("lvm_vg", jsonutils.NewString(s.VolumeGroup))
+	content.Set("lvm_thin_pool", jsonutils.NewString(s.ThinPool))
+	var (
+		err error
+		res jsonutils.JSONObject
+	)
+
+	log.Infof("Sync storage info %s", s.StorageId)
+
+	if len(s.StorageId) > 0 {
+		res, err = modules.Storages.Put(
+			hostutils.GetComputeSession(context.Background()),
+			s.StorageId, content)
+	} else {
+		res, err = modules.Storages.Create(
+			hostutils.GetComputeSession(context.Background()), content)
+	}
+	if err != nil {
+		log.Errorf("SyncStorageInfo Failed: %s: %s", content, err)
+		return nil, err
+	}
+	// storagecache of the thin pool is created along with the storage
+	if storagecacheId, _ := res.GetString("storagecache_id"); len(storagecacheId) > 0 {
+		s.SetStoragecacheId(storagecacheId)
+		s.Manager.AddLVMStorageImagecache(s, storagecacheId)
+	}
+	return res, nil
+}
+
+func (s *SLVMStorage) SetStorageInfo(storageId, storageName string, conf jsonutils.JSONObject) error {
+	s.StorageId = storageId
+	s.StorageName = storageName
+	if dconf, ok := conf.(*jsonutils.JSONDict); ok {
+		s.StorageConf = dconf
+	}
+	if len(s.StoragecacheId) > 0 {
+		s.Manager.AddLVMStorageImagecache(s, s.StoragecacheId)
+	}
+	return nil
+}
+
+func (s *SLVMStorage) GetDiskById(diskId string) IDisk {
+	s.DiskLock.Lock()
+	defer s.DiskLock.Unlock()
+	for i := 0; i < len(s.Disks); i++ {
+		if s.Disks[i].GetId() == diskId {
+			if s.Disks[i].Probe() == nil {
+				return s.Disks[i]
+			} else {
+				return nil
+			}
+		}
+	}
+	var disk = NewLVMDisk(s, diskId)
+	if disk.Probe() == nil {
+		s.Disks = append(s.Disks, disk)
+		return disk
+	} else {
+		return nil
+	}
+}
+
+func (s *SLVMStorage) CreateDisk(diskId string) IDisk {
+	s.DiskLock.Lock()
+	defer s.DiskLock.Unlock()
+	disk := NewLVMDisk(s, diskId)
+	s.Disks = append(s.Disks, disk)
+	return disk
+}
+
+func (s *SLVMStorage) Accessible() error {
+	if len(s.VolumeGroup) == 0 || len(s.ThinPool) == 0 {
+		return fmt.Errorf("invalid thin pool %s, format is <vg>/<thin_pool>", s.lvName(s.ThinPool))
+	}
+	var c = make(chan error)
+	go func() {
+		output, err := s.lvm("lvs", "--noheadings", "-o", "lv_attr", s.lvName(s.ThinPool))
+		if err != nil {
+			c <- err
+			return
+		}
+		// the first attribute of thin pool is `t`
+		if !strings.HasPrefix(strings.TrimSpace(output), "t") {
+			c <- fmt.Errorf("%s isn't thin pool", s.lvName(s.ThinPool))
+			return
+		}
+		c <- nil
+	}()
+	var err error
+	select {
+	case err = <-c:
+		break
+	case <-time.After(time.Second * 10):
+		err = ErrStorageTimeout
+	}
+	return err
+}
+
+func (s *SLVMStorage) Detach() error {
+	return nil
+}
+
+func (s *SLVMStorage) DeleteDiskfile(diskPath string) error {
+	return s.removeVolume(path.Base(diskPath))
+}
+
+func (s *SLVMStorage) DestinationPrepareMigrate(
+	ctx context.Context, liveMigrate bool, disksUri string, snapshotsUri string,
+	disksBackingFile, srcSnapshots jsonutils.JSONObject, rebaseDisks bool, diskinfo jsonutils.JSONObject,
+) error {
+	return fmt.Errorf("Not support migrate disk of %s storage", s.StorageType())
+}
+
+func (s *SLVMStorage) SaveToGlance(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	data, ok := params.(*jsonutils.JSONDict)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+
+	var (
+		imageId, _   = data.GetString("image_id")
+		imagePath, _ = data.GetString("image_path")
+		compress     = jsonutils.QueryBoolean(data, "compress", true)
+		format, _    = data.GetString("format")
+	)
+
+	if err := s.saveToGlance(ctx, imageId, imagePath, compress, format); err != nil {
+		log.Errorf("Save to glance failed: %s", err)
+		s.onSaveToGlanceFailed(ctx, imageId, err.Error())
+		return nil, s.removeVolume(path.Base(imagePath))
+	}
+
+	// keep the saved volume as the cached base image
+	imagecacheManager := s.Manager.GetStoragecacheById(s.GetStoragecacheId())
+	if imagecacheManager == nil {
+		return nil, s.removeVolume(path.Base(imagePath))
+	}
+	imageName := LVM_IMAGECACHE_PREFIX + imageId
+	if err := s.renameVolume(path.Base(imagePath), imageName); err != nil {
+		log.Errorf("Fail to move saved image to cache: %s", err)
+		return nil, s.removeVolume(path.Base(imagePath))
+	}
+	if err := s.setVolumeReadonly(imageName); err != nil {
+		log.Errorf("Fail to set image cache %s readonly: %s", imageName, err)
+	}
+	imagecacheManager.LoadImageCache(imageId)
+	_, err := hostutils.RemoteStoragecacheCacheImage(ctx,
+		imagecacheManager.GetId(), imageId, "ready", s.lvPath(imageName))
+	if err != nil {
+		log.Errorf("Fail to remote cache image: %s", err)
+	}
+	return nil, nil
+}
+
+func (s *SLVMStorage) saveToGlance(ctx context.Context, imageId, imagePath string, compress bool, format string) error {
+	ret, err := deployclient.GetDeployClient().SaveToGlance(context.Background(),
+		&deployapi.SaveToGlanceParams{DiskPath: imagePath, Compress: compress})
+	if err != nil {
+		return err
+	}
+
+	tmpImageFile := fmt.Sprintf("/tmp/%s.img", imageId)
+	if len(format) == 0 {
+		format = options.HostOptions.DefaultImageSaveFormat
+	}
+
+	err = procutils.NewRemoteCommandAsFarAsPossible(qemutils.GetQemuImg(),
+		"convert", "-f", "raw", "-O", format, imagePath, tmpImageFile).Run()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(tmpImageFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpImageFile)
+	defer f.Close()
+
+	finfo, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := finfo.Size()
+
+	var params = jsonutils.NewDict()
+	if len(ret.OsInfo) > 0 {
+		params.Set("os_type", jsonutils.NewString(ret.OsInfo))
+	}
+	relInfo := ret.ReleaseInfo
+	if relInfo != nil {
+		params.Set("os_distribution", jsonutils.NewString(relInfo.Distro))
+		if len(relInfo.Version) > 0 {
+			params.Set("os_version", jsonutils.NewString(relInfo.Version))
+		}
+		if len(relInfo.Arch) > 0 {
+			params.Set("os_arch", jsonutils.NewString(relInfo.Arch))
+		}
+		if len(relInfo.Version) > 0 {
+			params.Set("os_language", jsonutils.NewString(relInfo.Language))
+		}
+	}
+	params.Set("image_id", jsonutils.NewString(imageId))
+
+	_, err = modules.Images.Upload(hostutils.GetImageSession(ctx, s.GetZoneName()),
+		params, f, size)
+	return err
+}
+
+func (s *SLVMStorage) onSaveToGlanceFailed(ctx context.Context, imageId string, reason string) {
+	params := jsonutils.NewDict()
+	params.Set("status", jsonutils.NewString("killed"))
+	params.Set("reason", jsonutils.NewString(reason))
+	_, err := modules.Images.PerformAction(
+		hostutils.GetImageSession(ctx, s.GetZoneName()),
+		imageId, "update-status", params,
+	)
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (s *SLVMStorage) CreateSnapshotFormUrl(ctx context.Context, snapshotUrl, diskId, snapshotPath string) error {
+	return fmt.Errorf("Not support")
+}
+
+func (s *SLVMStorage) DeleteSnapshots(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	diskId, ok := params.(string)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	return nil, s.deleteDiskSnapshots(diskId)
+}
+
+func (s *SLVMStorage) deleteDiskSnapshots(diskId string) error {
+	names, err := s.listVolumes()
+	if err != nil {
+		return err
+	}
+	prefix := lvmSnapshotName(diskId, "")
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			if err := s.removeVolume(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *SLVMStorage) CreateDiskFromSnapshot(
+	ctx context.Context, disk IDisk, createParams *SDiskCreateByDiskinfo,
+) error {
+	var (
+		snapshotId, _ = createParams.DiskInfo.GetString("snapshot_url")
+		srcDiskId, _  = createParams.DiskInfo.GetString("src_disk_id")
+		snapshotName  = lvmSnapshotName(srcDiskId, snapshotId)
+	)
+	if !s.volumeExist(snapshotName) {
+		return fmt.Errorf("snapshot %s not found in volume group %s", snapshotId, s.VolumeGroup)
+	}
+	return s.cloneVolume(snapshotName, disk.GetId())
+}
@@ -41,3 +41,8 @@ type SDiskCleanupSnapshots struct {
 	ConvertSnapshots []jsonutils.JSONObject
 	DeleteSnapshots  []jsonutils.JSONObject
 }
+
+type SDiskMigrateStorage struct {
+	Disk       IDisk
+	TargetDisk IDisk
+}
@@ -207,3 +207,7 @@ func NewUnclassifiedError(msg string, params ...interface{}) *httputils.JSONClie
 func NewTooLargeEntityError(msg string, params ...interface{}) *httputils.JSONClientError {
 	return httputils.NewJsonClientError(httpErrorCode[ErrTooLarge], string(ErrTooLarge), msg, params...)
 }
+
+func NewTooManyRequestsError(msg string, params ...interface{}) *httputils.JSONClientError {
+	return httputils.NewJsonClientError(httpErrorCode[ErrTooManyRequests], string(ErrTooManyRequests), msg, params...)
+}
@@ -15,12 +15,14 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
 
 	api "yunion.io/x/onecloud/pkg/apis/image"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
@@ -93,8 +95,8 @@ func (manager *SImageSubformatManager) GetAllSubImages(id string) []SImageSubfor
 	return subImgs
 }
 
-func (self *SImageSubformat) DoConvert(image *SImage) error {
-	err := self.Save(image)
+func (self *SImageSubformat) DoConvert(ctx context.Context, image *SImage) error {
+	err := self.Save(ctx, image)
 	if err != nil {
 		log.Errorf("fail to convert image %s", err)
 		return err
@@ -115,7 +117,7 @@ func (self *SImageSubformat) DoConvert(image *SImage) error {
 	return nil
 }
 
-func (self *SImageSubformat) Save(image *SImage) error {
+func (self *SImageSubformat) Save(ctx context.Context, image *SImage) error {
 	if self.Status == api.IMAGE_STATUS_ACTIVE {
 		return nil
 	}
@@ -132,14 +134,19 @@ func (self *SImageSubformat) Save(image *SImage) error {
 		log.Errorf("updateStatus fail %s", err)
 		return err
 	}
-	img, err := image.getQemuImage()
+	img, err := image.getQemuImage(ctx)
 	if err != nil {
 		log.Errorf("image.getQemuImage fail %s", err)
 		return err
 	}
-	nimg, err := img.Clone(location, qemuimg.String2ImageFormat(self.Format), true)
+	nimg, err := img.CloneContext(ctx, location, qemuimg.String2ImageFormat(self.Format), true)
 	if err != nil {
 		log.Errorf("img.Clone fail %s", err)
+		// back to queued so that a later conversion retries it
+		db.Update(self, func() error {
+			self.Status = api.IMAGE_STATUS_QUEUED
+			return nil
+		})
 		return err
 	}
 	checksum, err := fileutils2.MD5(location)
@@ -175,6 +182,9 @@ func (self *SImageSubformat) SaveTorrent() error {
 		return nil // httperrors.NewInvalidStatusError("cannot save torrent in status %s", self.Status)
 	}
 	imgPath := self.getLocalLocation()
+	if len(imgPath) == 0 {
+		return errors.Errorf("subformat %s of image %s is not a local file", self.Format, self.ImageId)
+	}
 	torrentPath := filepath.Join(options.Options.TorrentStoreDir, fmt.Sprintf("%s.torrent", filepath.Base(imgPath)))
 	_, err := db.Update(self, func() error {
 		self.TorrentStatus = api.IMAGE_STATUS_SAVING
@@ -210,12 +220,32 @@ func (self *SImageSubformat) SaveTorrent() error {
 }
 
 func (self *SImageSubformat) getLocalLocation() string {
-	if len(self.Location) > len(LocalFilePrefix) {
+	if isLocalLocation(self.Location) {
 		return self.Location[len(LocalFilePrefix):]
 	}
 	return ""
 }
 
+func (self *SImageSubformat) saveToStorage(ctx context.Context, storage IImageStorage, oldImageLocation, imageLocation string) error {
+	if !isLocalLocation(self.Location) {
+		return nil
+	}
+	location := imageLocation
+	// subformat migrated from the image shares the same file
+	if self.Location != oldImageLocation {
+		var err error
+		location, err = storage.SaveImage(ctx, self.getLocalLocation(), self.Checksum)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := db.Update(self, func() error {
+		self.Location = location
+		return nil
+	})
+	return err
+}
+
 func (self *SImageSubformat) getLocalTorrentLocation() string {
 	if len(self.TorrentLocation) > len(LocalFilePrefix) {
 		return self.TorrentLocation[len(LocalFilePrefix):]
@@ -235,7 +265,7 @@ func (self *SImageSubformat) StopTorrent() {
 	}
 }
 
-func (self *SImageSubformat) RemoveFiles() error {
+func (self *SImageSubformat) RemoveFiles(ctx context.Context) error {
 	self.StopTorrent()
 	location := self.getLocalTorrentLocation()
 	if len(location) > 0 && fileutils2.IsFile(location) {
@@ -244,7 +274,15 @@ func (self *SImageSubformat) RemoveFiles() error {
 			return err
 		}
 	}
-	location = self.getLocalLocation()
+	if len(self.Location) > 0 && !isLocalLocation(self.Location) {
+		err := getStorageByLocation(self.Location).RemoveImage(ctx, self.Location)
+		if err != nil {
+			return err
+		}
+		location = localWorkingPath(self.Location)
+	} else {
+		location = self.getLocalLocation()
+	}
 	if len(location) > 0 && fileutils2.IsFile(location) {
 		err := os.Remove(location)
 		if err != nil {
@@ -289,7 +327,10 @@ func (self *SImageSubformat) GetDetails() SImageSubformatDetails {
 	return details
 }
 
-func (self *SImageSubformat) isActive(useFast bool) bool {
+func (self *SImageSubformat) isActive(ctx context.Context, useFast bool) bool {
+	if len(self.Location) > 0 && !isLocalLocation(self.Location) {
+		return isStoredActive(ctx, self.Location, self.Size)
+	}
 	return isActive(self.getLocalLocation(), self.Size, self.Checksum, self.FastHash, useFast)
 }
 
@@ -313,12 +354,12 @@ func (self *SImageSubformat) setTorrentStatus(status string) error {
 	return err
 }
 
-func (self *SImageSubformat) checkStatus(useFast bool) {
-	if self.isActive(useFast) {
+func (self *SImageSubformat) checkStatus(ctx context.Context, useFast bool) {
+	if self.isActive(ctx, useFast) {
 		if self.Status != api.IMAGE_STATUS_ACTIVE {
 			self.setStatus(api.IMAGE_STATUS_ACTIVE)
 		}
-		if len(self.FastHash) == 0 {
+		if len(self.FastHash) == 0 && isLocalLocation(self.Location) {
 			fastHash, err := fileutils2.FastCheckSum(self.getLocalLocation())
 			if err != nil {
 				log.Errorf("checkStatus fileutils2.FastChecksum fail %s", err)
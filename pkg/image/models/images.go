@@ -204,7 +204,7 @@ func (manager *SImageManager) IsCustomizedGetDetailsBody() bool {
 }
 
 func (self *SImage) CustomizedGetDetailsBody(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) (jsonutils.JSONObject, error) {
-	filePath := self.getLocalLocation()
+	location := self.Location
 	status := self.Status
 
 	if self.IsGuestImage.IsFalse() {
@@ -214,10 +214,10 @@ func (self *SImage) CustomizedGetDetailsBody(ctx context.Context, userCred mccli
 			if subimg != nil {
 				isTorrent := jsonutils.QueryBoolean(query, "torrent", false)
 				if !isTorrent {
-					filePath = subimg.getLocalLocation()
+					location = subimg.Location
 					status = subimg.Status
 				} else {
-					filePath = subimg.getLocalTorrentLocation()
+					location = subimg.TorrentLocation
 					status = subimg.TorrentStatus
 				}
 			} else {
@@ -230,26 +230,21 @@ func (self *SImage) CustomizedGetDetailsBody(ctx context.Context, userCred mccli
 		return nil, httperrors.NewInvalidStatusError("cannot download in status %s", status)
 	}
 
-	if filePath == "" {
+	if location == "" {
 		return nil, httperrors.NewInvalidStatusError("empty file path")
 	}
 
 	appParams := appsrv.AppContextGetParams(ctx)
 
-	fstat, err := os.Stat(filePath)
+	size, reader, err := getStorageByLocation(location).GetImage(ctx, location)
 	if err != nil {
-		return nil, errors.Wrap(err, "os.Stat")
+		return nil, errors.Wrap(err, "GetImage")
 	}
+	defer reader.Close()
 
-	appParams.Response.Header().Set("Content-Length", strconv.FormatInt(fstat.Size(), 10))
-
-	fp, err := os.Open(filePath)
-	if err != nil {
-		return nil, errors.Wrap(err, "os.Open")
-	}
-	defer fp.Close()
+	appParams.Response.Header().Set("Content-Length", strconv.FormatInt(size, 10))
 
-	_, err = streamutils.StreamPipe(fp, appParams.Response, false, nil)
+	_, err = streamutils.StreamPipe(reader, appParams.Response, false, nil)
 	if err != nil {
 		return nil, httperrors.NewGeneralError(err)
 	}
@@ -620,7 +615,8 @@ func (self *SImage) ValidateUpdateData(ctx context.Context, userCred mcclient.To
 		if appParams != nil && appParams.Request.ContentLength > 0 {
 			return nil, httperrors.NewInvalidStatusError("cannot upload in status %s", self.Status)
 		}
-		if minDiskSize, err := data.Int("min_disk"); err == nil {
+		// virtual size is only probed on local image files
+		if minDiskSize, err := data.Int("min_disk"); err == nil && isLocalLocation(self.Location) {
 			img, err := qemuimg.NewQemuImage(self.getLocalLocation())
 			if err != nil {
 				return nil, errors.Wrap(err, "open image")
@@ -1031,7 +1027,12 @@ func (self *SImage) MigrateSubImage(ctx context.Context) error {
 		return nil
 	}
 
-	imgInst, err := self.getQemuImage()
+	if !isLocalLocation(self.Location) {
+		// image already moved to the backend store is not sparse converted again
+		return self.newSubformat(ctx, qemuimg.String2ImageFormat(self.DiskFormat), true)
+	}
+
+	imgInst, err := self.getQemuImage(ctx)
 	if err != nil {
 		return err
 	}
@@ -1082,29 +1083,100 @@ func (self *SImage) MakeSubImages(ctx context.Context) error {
 	return nil
 }
 
-func (self *SImage) ConvertAllSubformats() error {
+func (self *SImage) ConvertAllSubformats(ctx context.Context) error {
 	subimgs := ImageSubformatManager.GetAllSubImages(self.Id)
 	for i := 0; i < len(subimgs); i += 1 {
 		if !utils.IsInStringArray(subimgs[i].Format, options.Options.TargetImageFormats) {
 			continue
 		}
-		err := subimgs[i].DoConvert(self)
+		err := subimgs[i].DoConvert(ctx, self)
 		if err != nil {
 			return err
 		}
 	}
+	return self.SaveToStorage(ctx)
+}
+
+// SaveToStorage moves the image and its active subformats from the local working
+// directory to the configured backend store, local images are migrated by this as well
+func (self *SImage) SaveToStorage(ctx context.Context) error {
+	storage := GetImageStorage()
+	if storage.Type() == api.IMAGE_STORAGE_DRIVER_LOCAL {
+		return nil
+	}
+	oldLocation := self.Location
+	if isLocalLocation(self.Location) {
+		log.Infof("save image %s(%s) to %s storage", self.Name, self.Id, storage.Type())
+		location, err := storage.SaveImage(ctx, self.getLocalLocation(), self.Checksum)
+		if err != nil {
+			return errors.Wrapf(err, "save image %s", self.Id)
+		}
+		_, err = db.Update(self, func() error {
+			self.Location = location
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "update location")
+		}
+	}
+	subimgs := ImageSubformatManager.GetAllSubImages(self.Id)
+	for i := 0; i < len(subimgs); i += 1 {
+		if subimgs[i].Status != api.IMAGE_STATUS_ACTIVE {
+			continue
+		}
+		err := subimgs[i].saveToStorage(ctx, storage, oldLocation, self.Location)
+		if err != nil {
+			return errors.Wrapf(err, "save subformat %s of image %s", subimgs[i].Format, self.Id)
+		}
+	}
+	// torrents are seeded from the local files
+	if !options.Options.EnableTorrentService {
+		self.removeLocalFiles(subimgs)
+	}
 	return nil
 }
 
+func (self *SImage) removeLocalFiles(subimgs []SImageSubformat) {
+	files := []string{}
+	if !isLocalLocation(self.Location) && len(self.Location) > 0 {
+		files = append(files, localWorkingPath(self.Location))
+	}
+	for i := 0; i < len(subimgs); i += 1 {
+		if !isLocalLocation(subimgs[i].Location) && len(subimgs[i].Location) > 0 {
+			files = append(files, localWorkingPath(subimgs[i].Location))
+		}
+	}
+	for _, filePath := range files {
+		if fileutils2.IsFile(filePath) {
+			if err := os.Remove(filePath); err != nil {
+				log.Errorf("fail to remove local file %s: %s", filePath, err)
+			}
+		}
+	}
+}
+
 func (self *SImage) getLocalLocation() string {
-	if len(self.Location) > len(LocalFilePrefix) {
+	if isLocalLocation(self.Location) {
 		return self.Location[len(LocalFilePrefix):]
 	}
 	return ""
 }
 
-func (self *SImage) getQemuImage() (*qemuimg.SQemuImage, error) {
-	return qemuimg.NewQemuImageWithIOLevel(self.getLocalLocation(), qemuimg.IONiceIdle)
+// getLocalImagePath returns the path of the local image file,
+// images in the backend store are downloaded on demand
+func (self *SImage) getLocalImagePath(ctx context.Context) (string, error) {
+	if isLocalLocation(self.Location) {
+		return self.getLocalLocation(), nil
+	}
+	return getStorageByLocation(self.Location).FetchImage(ctx, self.Location, self.Checksum)
+}
+
+func (self *SImage) getQemuImage(ctx context.Context) (*qemuimg.SQemuImage, error) {
+	localPath, err := self.getLocalImagePath(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "getLocalImagePath")
+	}
+	return qemuimg.NewQemuImageWithIOLevel(localPath, qemuimg.IONiceIdle)
 }
 
 func (self *SImage) StopTorrents() {
@@ -1121,11 +1193,11 @@ func (self *SImage) seedTorrents() {
 	}
 }
 
-func (self *SImage) RemoveFiles() error {
+func (self *SImage) RemoveFiles(ctx context.Context) error {
 	subimgs := ImageSubformatManager.GetAllSubImages(self.Id)
 	for i := 0; i < len(subimgs); i += 1 {
 		subimgs[i].StopTorrent()
-		err := subimgs[i].RemoveFiles()
+		err := subimgs[i].RemoveFiles(ctx)
 		if err != nil {
 			return err
 		}
@@ -1134,6 +1206,13 @@ func (self *SImage) RemoveFiles() error {
 	if len(filePath) == 0 {
 		filePath = self.GetPath("")
 	}
+	if len(self.Location) > 0 && !isLocalLocation(self.Location) {
+		err := getStorageByLocation(self.Location).RemoveImage(ctx, self.Location)
+		if err != nil {
+			return errors.Wrapf(err, "RemoveImage %s", self.Location)
+		}
+		filePath = localWorkingPath(self.Location)
+	}
 	if len(filePath) > 0 && fileutils2.IsFile(filePath) {
 		return os.Remove(filePath)
 	}
@@ -1257,6 +1336,21 @@ func (manager *SImageManager) QueryDistinctExtraField(q *sqlchemy.SQuery, field
 	return q, httperrors.ErrNotFound
 }
 
+// isStoredActive checks images in the backend store, the checksum of
+// which is verified on saving and fetching
+func isStoredActive(ctx context.Context, location string, size int64) bool {
+	storedSize, err := getStorageByLocation(location).Stat(ctx, location)
+	if err != nil {
+		log.Errorf("stat %s fail: %s", location, err)
+		return false
+	}
+	if size != storedSize {
+		log.Errorf("size mistmatch: %s", location)
+		return false
+	}
+	return true
+}
+
 func isActive(localPath string, size int64, chksum string, fastHash string, useFastHash bool) bool {
 	if len(localPath) == 0 || !fileutils2.Exists(localPath) {
 		log.Errorf("invalid file: %s", localPath)
@@ -1301,7 +1395,10 @@ func (self *SImage) IsIso() (error, bool) {
 	return nil, img.Format == qemuimg.ISO
 }
 
-func (self *SImage) isActive(useFast bool) bool {
+func (self *SImage) isActive(ctx context.Context, useFast bool) bool {
+	if len(self.Location) > 0 && !isLocalLocation(self.Location) {
+		return isStoredActive(ctx, self.Location, self.Size)
+	}
 	return isActive(self.getLocalLocation(), self.Size, self.Checksum, self.FastHash, useFast)
 }
 
@@ -1309,11 +1406,11 @@ func (self *SImage) DoCheckStatus(ctx context.Context, userCred mcclient.TokenCr
 	if utils.IsInStringArray(self.Status, api.ImageDeadStatus) {
 		return
 	}
-	if self.isActive(useFast) {
+	if self.isActive(ctx, useFast) {
 		if self.Status != api.IMAGE_STATUS_ACTIVE {
 			self.SetStatus(userCred, api.IMAGE_STATUS_ACTIVE, "check active")
 		}
-		if len(self.FastHash) == 0 {
+		if len(self.FastHash) == 0 && isLocalLocation(self.Location) {
 			fastHash, err := fileutils2.FastCheckSum(self.getLocalLocation())
 			if err != nil {
 				log.Errorf("DoCheckStatus fileutils2.FastChecksum fail %s", err)
@@ -1327,23 +1424,26 @@ func (self *SImage) DoCheckStatus(ctx context.Context, userCred mcclient.TokenCr
 				}
 			}
 		}
-		img, err := qemuimg.NewQemuImage(self.getLocalLocation())
-		if err == nil {
-			format := string(img.Format)
-			virtualSizeMB := int32(img.SizeBytes / 1024 / 1024)
-			if (len(format) > 0 && self.DiskFormat != format) || (virtualSizeMB > 0 && self.MinDiskMB != virtualSizeMB) {
-				db.Update(self, func() error {
-					if len(format) > 0 {
-						self.DiskFormat = format
-					}
-					if virtualSizeMB > 0 && self.MinDiskMB < virtualSizeMB {
-						self.MinDiskMB = virtualSizeMB
-					}
-					return nil
-				})
+		// format and size of images in the backend store are probed before saving
+		if isLocalLocation(self.Location) {
+			img, err := qemuimg.NewQemuImage(self.getLocalLocation())
+			if err == nil {
+				format := string(img.Format)
+				virtualSizeMB := int32(img.SizeBytes / 1024 / 1024)
+				if (len(format) > 0 && self.DiskFormat != format) || (virtualSizeMB > 0 && self.MinDiskMB != virtualSizeMB) {
+					db.Update(self, func() error {
+						if len(format) > 0 {
+							self.DiskFormat = format
+						}
+						if virtualSizeMB > 0 && self.MinDiskMB < virtualSizeMB {
+							self.MinDiskMB = virtualSizeMB
+						}
+						return nil
+					})
+				}
+			} else {
+				log.Warningf("fail to check image size of %s(%s)", self.Id, self.Name)
 			}
-		} else {
-			log.Warningf("fail to check image size of %s(%s)", self.Id, self.Name)
 		}
 	} else {
 		if self.Status != api.IMAGE_STATUS_QUEUED {
@@ -1357,7 +1457,7 @@ func (self *SImage) DoCheckStatus(ctx context.Context, userCred mcclient.TokenCr
 		needConvert = true
 	}
 	for i := 0; i < len(subimgs); i += 1 {
-		subimgs[i].checkStatus(useFast)
+		subimgs[i].checkStatus(ctx, useFast)
 		if (subimgs[i].Status != api.IMAGE_STATUS_ACTIVE || subimgs[i].TorrentStatus != api.IMAGE_STATUS_ACTIVE) && utils.IsInStringArray(subimgs[i].Format, options.Options.TargetImageFormats) {
 			needConvert = true
 		}
@@ -1366,8 +1466,14 @@ func (self *SImage) DoCheckStatus(ctx context.Context, userCred mcclient.TokenCr
 		if needConvert {
 			log.Infof("Image %s is active and need convert", self.Name)
 			self.StartImageConvertTask(ctx, userCred, "")
-		} else if options.Options.EnableTorrentService {
-			self.seedTorrents()
+		} else {
+			// migrate the images saved before switching to the backend store
+			if err := self.SaveToStorage(ctx); err != nil {
+				log.Errorf("fail to save image %s to storage: %s", self.Id, err)
+			}
+			if options.Options.EnableTorrentService {
+				self.seedTorrents()
+			}
 		}
 	}
 }
@@ -0,0 +1,350 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/image"
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+	"yunion.io/x/onecloud/pkg/image/options"
+	"yunion.io/x/onecloud/pkg/multicloud/objectstore"
+	"yunion.io/x/onecloud/pkg/util/fileutils2"
+)
+
+// IImageStorage is the backend store of image and subformat files.
+// Image files are always probed and converted on the local working
+// directory, the backend store keeps the final copies.
+type IImageStorage interface {
+	Type() string
+
+	// SaveImage stores the local file and returns the location of the stored copy
+	SaveImage(ctx context.Context, localPath string, checksum string) (string, error)
+	// GetImage opens the stored image for streaming read
+	GetImage(ctx context.Context, location string) (int64, io.ReadCloser, error)
+	// FetchImage makes a local copy of the stored image and returns the local path
+	FetchImage(ctx context.Context, location string, checksum string) (string, error)
+	// Stat returns size of the stored image
+	Stat(ctx context.Context, location string) (int64, error)
+	RemoveImage(ctx context.Context, location string) error
+}
+
+const (
+	errInvalidLocation = errors.Error("invalid image location")
+)
+
+var (
+	localImageStorage = &SLocalImageStorage{}
+	s3ImageStorage    = &SS3ImageStorage{}
+)
+
+// GetImageStorage returns the backend store new images are saved to
+func GetImageStorage() IImageStorage {
+	if options.Options.StorageDriver == api.IMAGE_STORAGE_DRIVER_S3 {
+		return s3ImageStorage
+	}
+	return localImageStorage
+}
+
+// getStorageByLocation returns the backend store holding the image at location,
+// images may still be on local disks during migration to s3
+func getStorageByLocation(location string) IImageStorage {
+	if strings.HasPrefix(location, api.S3Prefix) {
+		return s3ImageStorage
+	}
+	return localImageStorage
+}
+
+func isLocalLocation(location string) bool {
+	return strings.HasPrefix(location, LocalFilePrefix)
+}
+
+// localWorkingPath is the path of the local working copy of a stored image
+func localWorkingPath(location string) string {
+	return filepath.Join(options.Options.FilesystemStoreDatadir, filepath.Base(location))
+}
+
+type SLocalImageStorage struct{}
+
+func (s *SLocalImageStorage) Type() string {
+	return api.IMAGE_STORAGE_DRIVER_LOCAL
+}
+
+func (s *SLocalImageStorage) getPath(location string) string {
+	return strings.TrimPrefix(location, LocalFilePrefix)
+}
+
+func (s *SLocalImageStorage) SaveImage(ctx context.Context, localPath string, checksum string) (string, error) {
+	return fmt.Sprintf("%s%s", LocalFilePrefix, localPath), nil
+}
+
+func (s *SLocalImageStorage) GetImage(ctx context.Context, location string) (int64, io.ReadCloser, error) {
+	filePath := s.getPath(location)
+	fstat, err := os.Stat(filePath)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "os.Stat")
+	}
+	fp, err := os.Open(filePath)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "os.Open")
+	}
+	return fstat.Size(), fp, nil
+}
+
+func (s *SLocalImageStorage) FetchImage(ctx context.Context, location string, checksum string) (string, error) {
+	filePath := s.getPath(location)
+	if !fileutils2.IsFile(filePath) {
+		return "", errors.Wrapf(errors.ErrNotFound, "image file %s", filePath)
+	}
+	return filePath, nil
+}
+
+func (s *SLocalImageStorage) Stat(ctx context.Context, location string) (int64, error) {
+	fstat, err := os.Stat(s.getPath(location))
+	if err != nil {
+		return 0, errors.Wrap(err, "os.Stat")
+	}
+	return fstat.Size(), nil
+}
+
+func (s *SLocalImageStorage) RemoveImage(ctx context.Context, location string) error {
+	filePath := s.getPath(location)
+	if len(filePath) > 0 && fileutils2.IsFile(filePath) {
+		return os.Remove(filePath)
+	}
+	return nil
+}
+
+type SS3ImageStorage struct {
+	lock   sync.Mutex
+	client *objectstore.SObjectStoreClient
+	bucket cloudprovider.ICloudBucket
+}
+
+func InitS3ImageStorage() error {
+	_, err := s3ImageStorage.getBucket()
+	return err
+}
+
+func (s *SS3ImageStorage) Type() string {
+	return api.IMAGE_STORAGE_DRIVER_S3
+}
+
+func (s *SS3ImageStorage) getBucket() (cloudprovider.ICloudBucket, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.bucket != nil {
+		return s.bucket, nil
+	}
+	if len(options.Options.S3Endpoint) == 0 {
+		return nil, errors.Error("missing s3_endpoint")
+	}
+	if s.client == nil {
+		cfg := objectstore.NewObjectStoreClientConfig(options.Options.S3Endpoint, options.Options.S3AccessKey, options.Options.S3SecretKey)
+		client, err := objectstore.NewObjectStoreClient(cfg)
+		if err != nil {
+			return nil, errors.Wrap(err, "NewObjectStoreClient")
+		}
+		s.client = client
+	}
+	bucketName := options.Options.S3BucketName
+	bucket, err := cloudprovider.GetIBucketByName(s.client, bucketName)
+	if err != nil {
+		if errors.Cause(err) != cloudprovider.ErrNotFound {
+			return nil, errors.Wrapf(err, "GetIBucketByName %s", bucketName)
+		}
+		log.Infof("bucket %s not found, create it", bucketName)
+		err = s.client.CreateIBucket(bucketName, "", "")
+		if err != nil {
+			return nil, errors.Wrapf(err, "CreateIBucket %s", bucketName)
+		}
+		bucket, err = cloudprovider.GetIBucketByName(s.client, bucketName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "GetIBucketByName %s", bucketName)
+		}
+	}
+	s.bucket = bucket
+	return s.bucket, nil
+}
+
+// getKey parses location in the form of s3://<bucket>/<key>
+func (s *SS3ImageStorage) getKey(location string) (string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(location, api.S3Prefix), "/", 2)
+	if len(parts) != 2 || len(parts[1]) == 0 {
+		return "", errors.Wrapf(errInvalidLocation, "%s", location)
+	}
+	if parts[0] != options.Options.S3BucketName {
+		return "", errors.Wrapf(errInvalidLocation, "bucket %s of %s not match %s", parts[0], location, options.Options.S3BucketName)
+	}
+	return parts[1], nil
+}
+
+func (s *SS3ImageStorage) SaveImage(ctx context.Context, localPath string, checksum string) (string, error) {
+	bucket, err := s.getBucket()
+	if err != nil {
+		return "", err
+	}
+	fp, err := os.Open(localPath)
+	if err != nil {
+		return "", errors.Wrap(err, "os.Open")
+	}
+	defer fp.Close()
+	fstat, err := fp.Stat()
+	if err != nil {
+		return "", errors.Wrap(err, "Stat")
+	}
+
+	key := filepath.Base(localPath)
+	hash := md5.New()
+	reader := io.TeeReader(fp, hash)
+	blockSize := int64(options.Options.S3BlockSizeMb) * 1024 * 1024
+	err = cloudprovider.UploadObject(ctx, bucket, key, blockSize, reader, fstat.Size(), cloudprovider.ACLPrivate, "", nil, false)
+	if err != nil {
+		return "", errors.Wrapf(err, "UploadObject %s", key)
+	}
+
+	// the etag of multipart uploads is not the md5 of the object, verify
+	// with the checksum calculated on the fly and the size of the object
+	if sum := fmt.Sprintf("%x", hash.Sum(nil)); len(checksum) > 0 && sum != checksum {
+		s.removeObject(ctx, bucket, key)
+		return "", errors.Errorf("checksum mismatch of %s: uploaded %s expect %s", key, sum, checksum)
+	}
+	obj, err := cloudprovider.GetIObject(bucket, key)
+	if err != nil {
+		return "", errors.Wrapf(err, "GetIObject %s", key)
+	}
+	if obj.GetSizeBytes() != fstat.Size() {
+		s.removeObject(ctx, bucket, key)
+		return "", errors.Errorf("size mismatch of %s: uploaded %d expect %d", key, obj.GetSizeBytes(), fstat.Size())
+	}
+	return fmt.Sprintf("%s%s/%s", api.S3Prefix, bucket.GetName(), key), nil
+}
+
+func (s *SS3ImageStorage) removeObject(ctx context.Context, bucket cloudprovider.ICloudBucket, key string) {
+	if err := bucket.DeleteObject(ctx, key); err != nil {
+		log.Errorf("fail to remove object %s: %s", key, err)
+	}
+}
+
+func (s *SS3ImageStorage) GetImage(ctx context.Context, location string) (int64, io.ReadCloser, error) {
+	key, err := s.getKey(location)
+	if err != nil {
+		return 0, nil, err
+	}
+	bucket, err := s.getBucket()
+	if err != nil {
+		return 0, nil, err
+	}
+	obj, err := cloudprovider.GetIObject(bucket, key)
+	if err != nil {
+		return 0, nil, errors.Wrapf(err, "GetIObject %s", key)
+	}
+	reader, err := bucket.GetObject(ctx, key, nil)
+	if err != nil {
+		return 0, nil, errors.Wrapf(err, "GetObject %s", key)
+	}
+	return obj.GetSizeBytes(), reader, nil
+}
+
+func (s *SS3ImageStorage) FetchImage(ctx context.Context, location string, checksum string) (string, error) {
+	localPath := localWorkingPath(location)
+	if fileutils2.IsFile(localPath) {
+		if len(checksum) == 0 {
+			return localPath, nil
+		}
+		if sum, err := fileutils2.MD5(localPath); err == nil && sum == checksum {
+			return localPath, nil
+		}
+	}
+
+	_, reader, err := s.GetImage(ctx, location)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	// concurrent fetches of the same image download to their own temporary files
+	fp, err := ioutil.TempFile(filepath.Dir(localPath), filepath.Base(localPath)+".tmp")
+	if err != nil {
+		return "", errors.Wrap(err, "ioutil.TempFile")
+	}
+	tmpPath := fp.Name()
+	// keep the permission of image files created by os.Create
+	if err := fp.Chmod(0644); err != nil {
+		fp.Close()
+		os.Remove(tmpPath)
+		return "", errors.Wrap(err, "Chmod")
+	}
+	hash := md5.New()
+	_, err = io.Copy(io.MultiWriter(fp, hash), reader)
+	fp.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", errors.Wrapf(err, "download %s", location)
+	}
+	if sum := fmt.Sprintf("%x", hash.Sum(nil)); len(checksum) > 0 && sum != checksum {
+		os.Remove(tmpPath)
+		return "", errors.Errorf("checksum mismatch of %s: downloaded %s expect %s", location, sum, checksum)
+	}
+	if err := os.Rename(tmpPath, localPath); err != nil {
+		os.Remove(tmpPath)
+		return "", errors.Wrap(err, "os.Rename")
+	}
+	return localPath, nil
+}
+
+func (s *SS3ImageStorage) Stat(ctx context.Context, location string) (int64, error) {
+	key, err := s.getKey(location)
+	if err != nil {
+		return 0, err
+	}
+	bucket, err := s.getBucket()
+	if err != nil {
+		return 0, err
+	}
+	obj, err := cloudprovider.GetIObject(bucket, key)
+	if err != nil {
+		return 0, errors.Wrapf(err, "GetIObject %s", key)
+	}
+	return obj.GetSizeBytes(), nil
+}
+
+func (s *SS3ImageStorage) RemoveImage(ctx context.Context, location string) error {
+	key, err := s.getKey(location)
+	if err != nil {
+		return err
+	}
+	bucket, err := s.getBucket()
+	if err != nil {
+		return err
+	}
+	err = bucket.DeleteObject(ctx, key)
+	if err != nil {
+		return errors.Wrapf(err, "DeleteObject %s", key)
+	}
+	return nil
+}
@@ -0,0 +1,356 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+	"yunion.io/x/onecloud/pkg/image/options"
+)
+
+// fakeObject and fakeBucket stand in for an s3 bucket, only the methods
+// used by SS3ImageStorage are implemented
+type fakeObject struct {
+	cloudprovider.ICloudObject
+
+	key  string
+	size int64
+}
+
+func (o *fakeObject) GetKey() string {
+	return o.key
+}
+
+func (o *fakeObject) GetSizeBytes() int64 {
+	return o.size
+}
+
+type fakeBucket struct {
+	cloudprovider.ICloudBucket
+
+	lock    sync.Mutex
+	name    string
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+}
+
+func newFakeBucket(name string) *fakeBucket {
+	return &fakeBucket{
+		name:    name,
+		objects: map[string][]byte{},
+		uploads: map[string]map[int][]byte{},
+	}
+}
+
+func (b *fakeBucket) GetName() string {
+	return b.name
+}
+
+func (b *fakeBucket) MaxPartCount() int {
+	return 10000
+}
+
+func (b *fakeBucket) MaxPartSizeBytes() int64 {
+	return 5 * 1024 * 1024 * 1024
+}
+
+func (b *fakeBucket) ListObjects(prefix string, marker string, delimiter string, maxCount int) (cloudprovider.SListObjectResult, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	keys := []string{}
+	for key := range b.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	result := cloudprovider.SListObjectResult{}
+	for _, key := range keys {
+		if maxCount > 0 && len(result.Objects) >= maxCount {
+			result.IsTruncated = true
+			break
+		}
+		result.Objects = append(result.Objects, &fakeObject{key: key, size: int64(len(b.objects[key]))})
+	}
+	return result, nil
+}
+
+func (b *fakeBucket) GetObject(ctx context.Context, key string, rangeOpt *cloudprovider.SGetObjectRange) (io.ReadCloser, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	data, ok := b.objects[key]
+	if !ok {
+		return nil, errors.Wrapf(cloudprovider.ErrNotFound, "%s", key)
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (b *fakeBucket) PutObject(ctx context.Context, key string, input io.Reader, sizeBytes int64, cannedAcl cloudprovider.TBucketACLType, storageClassStr string, meta http.Header) error {
+	data, err := ioutil.ReadAll(input)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != sizeBytes {
+		return errors.Errorf("put %d bytes, expect %d", len(data), sizeBytes)
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.objects[key] = data
+	return nil
+}
+
+func (b *fakeBucket) DeleteObject(ctx context.Context, key string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.objects, key)
+	return nil
+}
+
+func (b *fakeBucket) NewMultipartUpload(ctx context.Context, key string, cannedAcl cloudprovider.TBucketACLType, storageClassStr string, meta http.Header) (string, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	uploadId := fmt.Sprintf("%s-%d", key, len(b.uploads))
+	b.uploads[uploadId] = map[int][]byte{}
+	return uploadId, nil
+}
+
+func (b *fakeBucket) UploadPart(ctx context.Context, key string, uploadId string, partIndex int, input io.Reader, partSize int64, offset, totalSize int64) (string, error) {
+	data, err := ioutil.ReadAll(input)
+	if err != nil {
+		return "", err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.uploads[uploadId][partIndex] = data
+	return fmt.Sprintf("%x", md5.Sum(data)), nil
+}
+
+func (b *fakeBucket) CompleteMultipartUpload(ctx context.Context, key string, uploadId string, partEtags []string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	parts := b.uploads[uploadId]
+	buf := bytes.Buffer{}
+	for i := range partEtags {
+		buf.Write(parts[i+1])
+	}
+	b.objects[key] = buf.Bytes()
+	delete(b.uploads, uploadId)
+	return nil
+}
+
+func (b *fakeBucket) AbortMultipartUpload(ctx context.Context, key string, uploadId string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.uploads, uploadId)
+	return nil
+}
+
+func setupS3ImageStorage(t *testing.T) (*SS3ImageStorage, *fakeBucket, string) {
+	dir, err := ioutil.TempDir("", "image-storage")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	options.Options.FilesystemStoreDatadir = dir
+	options.Options.S3BucketName = "images"
+	options.Options.S3BlockSizeMb = 1
+	bucket := newFakeBucket("images")
+	return &SS3ImageStorage{bucket: bucket}, bucket, dir
+}
+
+func writeTestImage(t *testing.T, path string, size int) (string, []byte) {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return fmt.Sprintf("%x", md5.Sum(data)), data
+}
+
+func TestS3ImageStorageGetKey(t *testing.T) {
+	options.Options.S3BucketName = "images"
+	s := &SS3ImageStorage{}
+	tests := []struct {
+		name     string
+		location string
+		want     string
+		wantErr  bool
+	}{
+		{"key", "s3://images/abc", "abc", false},
+		{"nested key", "s3://images/a/b", "a/b", false},
+		{"other bucket", "s3://others/abc", "", true},
+		{"missing key", "s3://images/", "", true},
+		{"missing bucket", "s3://images", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.getKey(tt.location)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getKey(%q) error = %v, wantErr %v", tt.location, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getKey(%q) = %q, want %q", tt.location, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestS3ImageStorageSaveAndFetch(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"put object", 512 * 1024},
+		{"multipart upload", 5*1024*1024/2 + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, bucket, dir := setupS3ImageStorage(t)
+			defer os.RemoveAll(dir)
+
+			localPath := filepath.Join(dir, "image-id")
+			checksum, data := writeTestImage(t, localPath, tt.size)
+			location, err := s.SaveImage(ctx, localPath, checksum)
+			if err != nil {
+				t.Fatalf("SaveImage: %v", err)
+			}
+			if want := "s3://images/image-id"; location != want {
+				t.Errorf("SaveImage() = %s, want %s", location, want)
+			}
+			if !bytes.Equal(bucket.objects["image-id"], data) {
+				t.Errorf("stored object differs from the image")
+			}
+			if size, err := s.Stat(ctx, location); err != nil || size != int64(tt.size) {
+				t.Errorf("Stat() = %d, %v, want %d", size, err, tt.size)
+			}
+
+			// the local working copy is downloaded again
+			os.Remove(localPath)
+			got, err := s.FetchImage(ctx, location, checksum)
+			if err != nil {
+				t.Fatalf("FetchImage: %v", err)
+			}
+			if got != localPath {
+				t.Errorf("FetchImage() = %s, want %s", got, localPath)
+			}
+			content, err := ioutil.ReadFile(localPath)
+			if err != nil || !bytes.Equal(content, data) {
+				t.Errorf("fetched image differs from the stored object: %v", err)
+			}
+			files, _ := ioutil.ReadDir(dir)
+			if len(files) != 1 {
+				t.Errorf("files left in the working directory: %d, want 1", len(files))
+			}
+
+			if err := s.RemoveImage(ctx, location); err != nil {
+				t.Fatalf("RemoveImage: %v", err)
+			}
+			if _, err := s.Stat(ctx, location); errors.Cause(err) != cloudprovider.ErrNotFound {
+				t.Errorf("Stat() of removed image error = %v, want not found", err)
+			}
+		})
+	}
+}
+
+func TestS3ImageStorageChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	s, bucket, dir := setupS3ImageStorage(t)
+	defer os.RemoveAll(dir)
+
+	localPath := filepath.Join(dir, "image-id")
+	checksum, _ := writeTestImage(t, localPath, 1024)
+	if _, err := s.SaveImage(ctx, localPath, "0123456789abcdef0123456789abcdef"); err == nil {
+		t.Fatalf("SaveImage() with wrong checksum should fail")
+	}
+	if _, ok := bucket.objects["image-id"]; ok {
+		t.Errorf("object of wrong checksum is not removed")
+	}
+
+	location, err := s.SaveImage(ctx, localPath, checksum)
+	if err != nil {
+		t.Fatalf("SaveImage: %v", err)
+	}
+	// the stale local copy is replaced
+	if err := ioutil.WriteFile(localPath, []byte("stale"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := s.FetchImage(ctx, location, checksum); err != nil {
+		t.Fatalf("FetchImage: %v", err)
+	}
+	if sum, _ := ioutil.ReadFile(localPath); fmt.Sprintf("%x", md5.Sum(sum)) != checksum {
+		t.Errorf("stale local copy is not replaced")
+	}
+
+	// a corrupted download is discarded
+	bucket.objects["image-id"] = []byte("corrupted")
+	os.Remove(localPath)
+	if _, err := s.FetchImage(ctx, location, checksum); err == nil {
+		t.Errorf("FetchImage() of corrupted object should fail")
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("files left in the working directory: %d, want 0", len(files))
+	}
+}
+
+func TestS3ImageStorageConcurrentFetch(t *testing.T) {
+	ctx := context.Background()
+	s, _, dir := setupS3ImageStorage(t)
+	defer os.RemoveAll(dir)
+
+	localPath := filepath.Join(dir, "image-id")
+	checksum, data := writeTestImage(t, localPath, 3*1024*1024)
+	location, err := s.SaveImage(ctx, localPath, checksum)
+	if err != nil {
+		t.Fatalf("SaveImage: %v", err)
+	}
+	os.Remove(localPath)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = s.FetchImage(ctx, location, checksum)
+		}(i)
+	}
+	wg.Wait()
+	for i := range errs {
+		if errs[i] != nil {
+			t.Errorf("FetchImage %d: %v", i, errs[i])
+		}
+	}
+	content, err := ioutil.ReadFile(localPath)
+	if err != nil || !bytes.Equal(content, data) {
+		t.Errorf("fetched image differs from the stored object: %v", err)
+	}
+}
@@ -41,6 +41,14 @@ type SImageOptions struct {
 	TorrentClientPath string `help:"path to torrent executable" default:"/opt/yunion/bin/torrent"`
 
 	DeployServerSocketPath string `help:"Deploy server listen socket path" default:"/var/run/onecloud/deploy.sock"`
+
+	StorageDriver string `help:"Backend store of image files, local files are only used as working copies with s3" choices:"local|s3" default:"local"`
+
+	S3Endpoint    string `help:"Endpoint of the s3 compatible object storage, e.g. http://minio:9000"`
+	S3AccessKey   string `help:"Access key of the s3 object storage"`
+	S3SecretKey   string `help:"Secret key of the s3 object storage"`
+	S3BucketName  string `help:"Bucket to store image files" default:"onecloud-images"`
+	S3BlockSizeMb int    `help:"Part size in MB of multipart uploads to s3" default:"100"`
 }
 
 var (
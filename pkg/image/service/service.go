@@ -28,6 +28,7 @@ import (
 	app_common "yunion.io/x/onecloud/pkg/cloudcommon/app"
 	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
 	"yunion.io/x/onecloud/pkg/hostman/hostdeployer/deployclient"
 	"yunion.io/x/onecloud/pkg/image/models"
@@ -79,6 +80,12 @@ func StartService() {
 
 	log.Infof("Target image formats %#v", opts.TargetImageFormats)
 
+	if opts.StorageDriver == api.IMAGE_STORAGE_DRIVER_S3 {
+		if err := models.InitS3ImageStorage(); err != nil {
+			log.Fatalf("fail to init s3 image storage: %s", err)
+		}
+	}
+
 	app_common.InitAuth(commonOpts, func() {
 		log.Infof("Auth complete!!")
 	})
@@ -109,6 +116,7 @@ func StartService() {
 		cron.AddJobAtIntervals("CalculateQuotaUsages", time.Duration(opts.CalculateQuotaUsageIntervalSeconds)*time.Second, models.QuotaManager.CalculateQuotaUsages)
 		cron.AddJobAtIntervals("CleanPendingDeleteGuestImages",
 			time.Duration(options.Options.PendingDeleteCheckSeconds)*time.Second, models.GuestImageManager.CleanPendingDeleteImages)
+		cron.AddJobAtIntervalsWithStartRun("RecoverTasks", time.Duration(60)*time.Second, taskman.TaskManager.RecoverTasks, true)
 
 		cron.Start()
 	}
@@ -16,6 +16,7 @@ package tasks
 
 import (
 	"context"
+	"time"
 
 	"yunion.io/x/jsonutils"
 
@@ -31,7 +32,11 @@ type ImageCheckTask struct {
 
 func init() {
 	checkWorker := appsrv.NewWorkerManager("ImageCheckTaskWorkerManager", 2, 1024, true)
-	taskman.RegisterTaskAndWorker(ImageCheckTask{}, checkWorker)
+	taskman.RegisterTaskWithPolicy(ImageCheckTask{}, checkWorker, taskman.STaskPolicy{
+		MaxAttempts:  3,
+		Backoff:      time.Minute,
+		StageTimeout: time.Hour,
+	})
 }
 
 func (self *ImageCheckTask) OnInit(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
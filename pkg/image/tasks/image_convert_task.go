@@ -17,6 +17,7 @@ package tasks
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"yunion.io/x/jsonutils"
 
@@ -33,6 +34,9 @@ type ImageConvertTask struct {
 	taskman.STask
 }
 
+// convertCancels holds the context.CancelFunc of running conversions by task id
+var convertCancels sync.Map
+
 func init() {
 	convertWorker := appsrv.NewWorkerManager("ImageConvertTaskWorkerManager", 2, 512, true)
 	taskman.RegisterTaskAndWorker(ImageConvertTask{}, convertWorker)
@@ -45,7 +49,13 @@ func (self *ImageConvertTask) OnInit(ctx context.Context, obj db.IStandaloneMode
 	taskman.LocalTaskRun(self, func() (jsonutils.JSONObject, error) {
 		imgOldStatus := image.Status
 		image.SetStatus(self.UserCred, api.IMAGE_STATUS_CONVERTING, "start convert")
-		err := image.ConvertAllSubformats()
+		convertCtx, cancel := context.WithCancel(ctx)
+		convertCancels.Store(self.Id, cancel)
+		defer func() {
+			convertCancels.Delete(self.Id)
+			cancel()
+		}()
+		err := image.ConvertAllSubformats(convertCtx)
 		var msg string
 		if err != nil {
 			msg = fmt.Sprintf("convert failed: %s", err)
@@ -75,3 +85,10 @@ func (self *ImageConvertTask) OnConvertComplete(ctx context.Context, obj db.ISta
 func (self *ImageConvertTask) OnConvertCompleteFailed(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
 	self.SetStageFailed(ctx, data)
 }
+
+// OnCancel kills the qemu-img process converting the image
+func (self *ImageConvertTask) OnCancel(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+	if cancel, ok := convertCancels.Load(self.Id); ok {
+		cancel.(context.CancelFunc)()
+	}
+}
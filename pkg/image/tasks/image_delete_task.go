@@ -62,7 +62,7 @@ func (self *ImageDeleteTask) startPendingDeleteImage(ctx context.Context, image
 }
 
 func (self *ImageDeleteTask) startDeleteImage(ctx context.Context, image *models.SImage) {
-	err := image.RemoveFiles()
+	err := image.RemoveFiles(ctx)
 	if err != nil {
 		msg := fmt.Sprintf("fail to remove %s %s", image.GetPath(""), err)
 		log.Errorf(msg)
@@ -179,6 +179,9 @@ func (self *ImageProbeTask) OnProbeFailed(ctx context.Context, image *models.SIm
 			self.SetStageFailed(ctx, jsonutils.NewString(err.Error()))
 		}
 	} else {
+		if err := image.SaveToStorage(ctx); err != nil {
+			log.Errorf("fail to save image %s to storage: %s", image.Id, err)
+		}
 		image.SetStatus(self.UserCred, api.IMAGE_STATUS_ACTIVE, "")
 		self.SetStageFailed(ctx, reason)
 	}
@@ -197,6 +200,9 @@ func (self *ImageProbeTask) OnProbeSuccess(ctx context.Context, image *models.SI
 			self.SetStageFailed(ctx, jsonutils.NewString(err.Error()))
 		}
 	} else {
+		if err := image.SaveToStorage(ctx); err != nil {
+			log.Errorf("fail to save image %s to storage: %s", image.Id, err)
+		}
 		image.SetStatus(self.UserCred, api.IMAGE_STATUS_ACTIVE, "")
 		self.SetStageComplete(ctx, nil)
 	}
@@ -0,0 +1,432 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+	"yunion.io/x/sqlchemy"
+
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
+	"yunion.io/x/onecloud/pkg/util/seclib2"
+	"yunion.io/x/onecloud/pkg/util/stringutils2"
+)
+
+const (
+	applicationCredentialSecretBytes = 32
+	// minimal length of the secret specified by user
+	applicationCredentialSecretMinLength = 32
+)
+
+type SApplicationCredentialManager struct {
+	db.SStandaloneResourceBaseManager
+	SUserResourceBaseManager
+	SProjectResourceBaseManager
+}
+
+var ApplicationCredentialManager *SApplicationCredentialManager
+
+func init() {
+	ApplicationCredentialManager = &SApplicationCredentialManager{
+		SStandaloneResourceBaseManager: db.NewStandaloneResourceBaseManager(
+			SApplicationCredential{},
+			"application_credential",
+			"application_credential",
+			"application_credentials",
+		),
+	}
+	ApplicationCredentialManager.SetVirtualObject(ApplicationCredentialManager)
+}
+
+// SApplicationCredential is bound to one project of its owner with a subset of
+// the owner's roles in that project, the secret is only kept as a bcrypt hash
+type SApplicationCredential struct {
+	db.SStandaloneResourceBase
+
+	UserId    string `width:"64" charset:"ascii" nullable:"false" index:"true" list:"user" create:"required"`
+	ProjectId string `width:"64" charset:"ascii" nullable:"false" list:"user" create:"required"`
+	// comma separated role ids
+	RoleIds string `width:"1024" charset:"ascii" nullable:"false" list:"user" create:"required"`
+
+	SecretHash string `width:"128" charset:"ascii" nullable:"false"`
+
+	ExpiresAt time.Time `nullable:"true" list:"user" create:"optional"`
+
+	AccessRules *api.SAccessRules `nullable:"true" list:"user" create:"optional"`
+
+	// plain secret, only available in the response of creation
+	secret string `ignore:"true"`
+}
+
+func generateApplicationCredentialSecret() (string, error) {
+	buf := make([]byte, applicationCredentialSecretBytes)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", errors.Wrap(err, "rand.Read")
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func validateApplicationCredentialSecret(secret string) error {
+	if len(secret) > 0 && len(secret) < applicationCredentialSecretMinLength {
+		return httperrors.NewInputParameterError("secret should be at least %d characters", applicationCredentialSecretMinLength)
+	}
+	return nil
+}
+
+func isApplicationCredentialToken(userCred mcclient.TokenCredential) bool {
+	appCredToken, ok := userCred.(mcclient.IApplicationCredentialToken)
+	return ok && len(appCredToken.GetApplicationCredentialId()) > 0
+}
+
+func (manager *SApplicationCredentialManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data *jsonutils.JSONDict) (*jsonutils.JSONDict, error) {
+	if isApplicationCredentialToken(userCred) {
+		return nil, httperrors.NewForbiddenError("cannot create application credential with an application credential")
+	}
+	input := api.ApplicationCredentialCreateInput{}
+	err := data.Unmarshal(&input)
+	if err != nil {
+		return nil, httperrors.NewInternalServerError("unmarshal ApplicationCredentialCreateInput fail %s", err)
+	}
+
+	userId := ownerId.GetUserId()
+	if len(userId) == 0 {
+		userId = userCred.GetUserId()
+	}
+	data.Set("user_id", jsonutils.NewString(userId))
+
+	var project *SProject
+	if len(input.ProjectId) == 0 {
+		project, err = ProjectManager.FetchProjectById(userCred.GetProjectId())
+	} else {
+		var projObj db.IModel
+		projObj, err = ProjectManager.FetchByIdOrName(userCred, input.ProjectId)
+		if projObj != nil {
+			project = projObj.(*SProject)
+		}
+	}
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, httperrors.NewResourceNotFoundError2(ProjectManager.Keyword(), input.ProjectId)
+		}
+		return nil, httperrors.NewGeneralError(err)
+	}
+	data.Set("project_id", jsonutils.NewString(project.Id))
+
+	userRoles, err := AssignmentManager.FetchUserProjectRoles(userId, project.Id)
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	if len(userRoles) == 0 {
+		return nil, httperrors.NewForbiddenError("user has no role in project %s", project.Name)
+	}
+	roleIds := make([]string, 0)
+	if len(input.Roles) == 0 {
+		for i := range userRoles {
+			roleIds = append(roleIds, userRoles[i].Id)
+		}
+	} else {
+		for _, roleStr := range input.Roles {
+			found := false
+			for i := range userRoles {
+				if userRoles[i].Id == roleStr || userRoles[i].Name == roleStr {
+					if !utils.IsInStringArray(userRoles[i].Id, roleIds) {
+						roleIds = append(roleIds, userRoles[i].Id)
+					}
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, httperrors.NewForbiddenError("role %s is not assigned to the user in project %s", roleStr, project.Name)
+			}
+		}
+	}
+	data.Set("role_ids", jsonutils.NewString(strings.Join(roleIds, ",")))
+
+	err = validateApplicationCredentialSecret(input.Secret)
+	if err != nil {
+		return nil, err
+	}
+	if !input.ExpiresAt.IsZero() && input.ExpiresAt.Before(time.Now()) {
+		return nil, httperrors.NewInputParameterError("expires_at %s is in the past", input.ExpiresAt)
+	}
+	if len(input.AccessRules) > 0 {
+		err = input.AccessRules.Validate()
+		if err != nil {
+			return nil, err
+		}
+		data.Set("access_rules", jsonutils.Marshal(input.AccessRules))
+	}
+
+	input.StandaloneResourceCreateInput, err = manager.SStandaloneResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, input.StandaloneResourceCreateInput)
+	if err != nil {
+		return nil, err
+	}
+	data.Update(jsonutils.Marshal(input.StandaloneResourceCreateInput))
+	return data, nil
+}
+
+func (appCred *SApplicationCredential) CustomizeCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data jsonutils.JSONObject) error {
+	secret, _ := data.GetString("secret")
+	err := validateApplicationCredentialSecret(secret)
+	if err != nil {
+		return err
+	}
+	if len(secret) == 0 {
+		secret, err = generateApplicationCredentialSecret()
+		if err != nil {
+			return errors.Wrap(err, "generateApplicationCredentialSecret")
+		}
+	}
+	// never keep the plain secret in the create data
+	data.(*jsonutils.JSONDict).Remove("secret")
+	hash, err := seclib2.BcryptPassword(secret)
+	if err != nil {
+		return errors.Wrap(err, "BcryptPassword")
+	}
+	appCred.SecretHash = hash
+	appCred.secret = secret
+	return appCred.SStandaloneResourceBase.CustomizeCreate(ctx, userCred, ownerId, query, data)
+}
+
+func (appCred *SApplicationCredential) ValidateUpdateData(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.ApplicationCredentialUpdateInput) (api.ApplicationCredentialUpdateInput, error) {
+	var err error
+	input.StandaloneResourceBaseUpdateInput, err = appCred.SStandaloneResourceBase.ValidateUpdateData(ctx, userCred, query, input.StandaloneResourceBaseUpdateInput)
+	if err != nil {
+		return input, errors.Wrap(err, "SStandaloneResourceBase.ValidateUpdateData")
+	}
+	return input, nil
+}
+
+func (appCred *SApplicationCredential) PostDelete(ctx context.Context, userCred mcclient.TokenCredential) {
+	appCred.SStandaloneResourceBase.PostDelete(ctx, userCred)
+
+	// tokens issued for the application credential carry its id as the audit chain id
+	err := RevocationEventManager.RevokeToken(ctx, appCred.Id)
+	if err != nil {
+		log.Errorf("revoke tokens of application credential %s fail %s", appCred.Id, err)
+	}
+}
+
+func (manager *SApplicationCredentialManager) deleteByUser(ctx context.Context, userCred mcclient.TokenCredential, userId string) error {
+	q := manager.Query().Equals("user_id", userId)
+	appCreds := make([]SApplicationCredential, 0)
+	err := db.FetchModelObjects(manager, q, &appCreds)
+	if err != nil {
+		return errors.Wrap(err, "FetchModelObjects")
+	}
+	for i := range appCreds {
+		err = appCreds[i].Delete(ctx, userCred)
+		if err != nil {
+			return errors.Wrapf(err, "delete application credential %s", appCreds[i].Id)
+		}
+	}
+	return nil
+}
+
+func (manager *SApplicationCredentialManager) FetchApplicationCredentialById(appCredId string) (*SApplicationCredential, error) {
+	obj, err := manager.FetchById(appCredId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "FetchById %s", appCredId)
+	}
+	return obj.(*SApplicationCredential), nil
+}
+
+func (manager *SApplicationCredentialManager) FetchApplicationCredentialByName(name string, userId string) (*SApplicationCredential, error) {
+	q := manager.Query().Equals("name", name).Equals("user_id", userId)
+	appCred := &SApplicationCredential{}
+	appCred.SetModelManager(manager, appCred)
+	err := q.First(appCred)
+	if err != nil {
+		return nil, errors.Wrapf(err, "query application credential %s", name)
+	}
+	return appCred, nil
+}
+
+func (appCred *SApplicationCredential) IsExpired() bool {
+	return !appCred.ExpiresAt.IsZero() && appCred.ExpiresAt.Before(time.Now())
+}
+
+func (appCred *SApplicationCredential) VerifySecret(secret string) error {
+	return seclib2.BcryptVerifyPassword(secret, appCred.SecretHash)
+}
+
+func (appCred *SApplicationCredential) GetRoleIds() []string {
+	if len(appCred.RoleIds) == 0 {
+		return nil
+	}
+	return strings.Split(appCred.RoleIds, ",")
+}
+
+func (appCred *SApplicationCredential) GetAccessRules() api.SAccessRules {
+	if appCred.AccessRules == nil {
+		return nil
+	}
+	return *appCred.AccessRules
+}
+
+// FetchRoles returns the roles of the application credential which are still
+// assigned to the owner in the project, roles revoked from the owner are dropped
+func (appCred *SApplicationCredential) FetchRoles() ([]SRole, error) {
+	userRoles, err := AssignmentManager.FetchUserProjectRoles(appCred.UserId, appCred.ProjectId)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchUserProjectRoles")
+	}
+	roleIds := appCred.GetRoleIds()
+	roles := make([]SRole, 0)
+	for i := range userRoles {
+		if utils.IsInStringArray(userRoles[i].Id, roleIds) {
+			roles = append(roles, userRoles[i])
+		}
+	}
+	return roles, nil
+}
+
+func (appCred *SApplicationCredential) GetApplicationCredentialInfo() *api.SApplicationCredentialInfo {
+	return &api.SApplicationCredentialInfo{
+		Id:          appCred.Id,
+		Name:        appCred.Name,
+		AccessRules: appCred.GetAccessRules(),
+	}
+}
+
+func (manager *SApplicationCredentialManager) FetchCustomizeColumns(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	objs []interface{},
+	fields stringutils2.SSortedStrings,
+	isList bool,
+) []api.ApplicationCredentialDetails {
+	rows := make([]api.ApplicationCredentialDetails, len(objs))
+
+	stdRows := manager.SStandaloneResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
+
+	for i := range rows {
+		rows[i] = api.ApplicationCredentialDetails{
+			StandaloneResourceDetails: stdRows[i],
+		}
+		rows[i] = applicationCredentialExtra(objs[i].(*SApplicationCredential), rows[i])
+	}
+
+	return rows
+}
+
+func applicationCredentialExtra(appCred *SApplicationCredential, out api.ApplicationCredentialDetails) api.ApplicationCredentialDetails {
+	out.Secret = appCred.secret
+
+	usr, _ := UserManager.FetchUserExtended(appCred.UserId, "", "", "")
+	if usr != nil {
+		out.User = usr.Name
+		out.Domain = usr.DomainName
+		out.DomainId = usr.DomainId
+	}
+	proj, _ := ProjectManager.FetchProjectById(appCred.ProjectId)
+	if proj != nil {
+		out.Project = proj.Name
+	}
+	out.Roles = make([]api.SIdentityObject, 0)
+	for _, roleId := range appCred.GetRoleIds() {
+		role, _ := RoleManager.FetchRoleById(roleId)
+		if role != nil {
+			out.Roles = append(out.Roles, api.SIdentityObject{Id: role.Id, Name: role.Name})
+		}
+	}
+	return out
+}
+
+func (manager *SApplicationCredentialManager) ResourceScope() rbacutils.TRbacScope {
+	return rbacutils.ScopeUser
+}
+
+func (manager *SApplicationCredentialManager) FilterByOwner(q *sqlchemy.SQuery, owner mcclient.IIdentityProvider, scope rbacutils.TRbacScope) *sqlchemy.SQuery {
+	if owner != nil {
+		if scope == rbacutils.ScopeUser {
+			if len(owner.GetUserId()) > 0 {
+				q = q.Equals("user_id", owner.GetUserId())
+			}
+		}
+	}
+	return q
+}
+
+func (appCred *SApplicationCredential) GetOwnerId() mcclient.IIdentityProvider {
+	owner := db.SOwnerId{UserId: appCred.UserId}
+	return &owner
+}
+
+// 应用凭证列表
+func (manager *SApplicationCredentialManager) ListItemFilter(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query api.ApplicationCredentialListInput,
+) (*sqlchemy.SQuery, error) {
+	var err error
+	q, err = manager.SStandaloneResourceBaseManager.ListItemFilter(ctx, q, userCred, query.StandaloneResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SStandaloneResourceBaseManager.ListItemFilter")
+	}
+	q, err = manager.SUserResourceBaseManager.ListItemFilter(ctx, q, userCred, query.UserFilterListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SUserResourceBaseManager.ListItemFilter")
+	}
+	q, err = manager.SProjectResourceBaseManager.ListItemFilter(ctx, q, userCred, query.ProjectFilterListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SProjectResourceBaseManager.ListItemFilter")
+	}
+	return q, nil
+}
+
+func (manager *SApplicationCredentialManager) OrderByExtraFields(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query api.ApplicationCredentialListInput,
+) (*sqlchemy.SQuery, error) {
+	var err error
+
+	q, err = manager.SStandaloneResourceBaseManager.OrderByExtraFields(ctx, q, userCred, query.StandaloneResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SStandaloneResourceBaseManager.OrderByExtraFields")
+	}
+
+	return q, nil
+}
+
+func (manager *SApplicationCredentialManager) QueryDistinctExtraField(q *sqlchemy.SQuery, field string) (*sqlchemy.SQuery, error) {
+	var err error
+
+	q, err = manager.SStandaloneResourceBaseManager.QueryDistinctExtraField(q, field)
+	if err == nil {
+		return q, nil
+	}
+
+	return q, httperrors.ErrNotFound
+}
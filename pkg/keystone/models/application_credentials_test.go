@@ -0,0 +1,45 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateApplicationCredentialSecret(t *testing.T) {
+	cases := []struct {
+		Secret  string
+		WantErr bool
+	}{
+		{"", false},
+		{"secret", true},
+		{strings.Repeat("a", applicationCredentialSecretMinLength-1), true},
+		{strings.Repeat("a", applicationCredentialSecretMinLength), false},
+	}
+	for _, c := range cases {
+		err := validateApplicationCredentialSecret(c.Secret)
+		if (err != nil) != c.WantErr {
+			t.Errorf("validateApplicationCredentialSecret %q got %v want error %v", c.Secret, err, c.WantErr)
+		}
+	}
+	secret, err := generateApplicationCredentialSecret()
+	if err != nil {
+		t.Fatalf("generateApplicationCredentialSecret fail %s", err)
+	}
+	if err := validateApplicationCredentialSecret(secret); err != nil {
+		t.Errorf("generated secret %q is invalid: %s", secret, err)
+	}
+}
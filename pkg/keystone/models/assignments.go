@@ -301,6 +301,10 @@ func (manager *SAssignmentManager) projectRemoveAllUser(ctx context.Context, use
 	if err != nil {
 		return errors.Wrap(err, "manager.batchRemove")
 	}
+	err = RevocationEventManager.RevokeUser(ctx, user.Id)
+	if err != nil {
+		return errors.Wrap(err, "RevocationEventManager.RevokeUser")
+	}
 	db.OpsLog.LogEvent(user, "leave_all_projects", user.GetShortDesc(ctx), userCred)
 	return nil
 }
@@ -310,6 +314,10 @@ func (manager *SAssignmentManager) projectRemoveAllGroup(ctx context.Context, us
 	if err != nil {
 		return errors.Wrap(err, "manager.batchRemove")
 	}
+	err = RevocationEventManager.revokeGroupUsers(ctx, group.Id, "")
+	if err != nil {
+		return errors.Wrap(err, "RevocationEventManager.revokeGroupUsers")
+	}
 	db.OpsLog.LogEvent(group, "leave_all_projects", group.GetShortDesc(ctx), userCred)
 	return nil
 }
@@ -338,6 +346,10 @@ func (manager *SAssignmentManager) projectRemoveUser(ctx context.Context, userCr
 	if err != nil {
 		return errors.Wrap(err, "manager.remove")
 	}
+	err = RevocationEventManager.RevokeUserProject(ctx, user.Id, project.Id)
+	if err != nil {
+		return errors.Wrap(err, "RevocationEventManager.RevokeUserProject")
+	}
 	db.OpsLog.LogEvent(user, db.ACT_DETACH, project.GetShortDesc(ctx), userCred)
 	db.OpsLog.LogEvent(project, db.ACT_DETACH, user.GetShortDesc(ctx), userCred)
 	return nil
@@ -385,6 +397,10 @@ func (manager *SAssignmentManager) projectRemoveGroup(ctx context.Context, userC
 	if err != nil {
 		return errors.Wrap(err, "manager.remove")
 	}
+	err = RevocationEventManager.revokeGroupUsers(ctx, group.Id, project.Id)
+	if err != nil {
+		return errors.Wrap(err, "RevocationEventManager.revokeGroupUsers")
+	}
 	db.OpsLog.LogEvent(group, db.ACT_DETACH, project.GetShortDesc(ctx), userCred)
 	db.OpsLog.LogEvent(project, db.ACT_DETACH, group.GetShortDesc(ctx), userCred)
 	return nil
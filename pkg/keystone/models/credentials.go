@@ -16,11 +16,13 @@ package models
 
 import (
 	"context"
+	"crypto/subtle"
 	"database/sql"
 	"fmt"
 	"time"
 
 	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/pkg/gotypes"
 	"yunion.io/x/pkg/tristate"
@@ -171,6 +173,10 @@ func (self *SCredential) ValidateDeleteCondition(ctx context.Context) error {
 func (self *SCredential) ValidateUpdateData(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.CredentialUpdateInput) (api.CredentialUpdateInput, error) {
 	var err error
 
+	if len(input.Blob) > 0 && self.Type != api.WEBAUTHN_CREDENTIAL_TYPE {
+		return input, httperrors.NewForbiddenError("blob of %s credential is immutable", self.Type)
+	}
+
 	input.StandaloneResourceBaseUpdateInput, err = self.SStandaloneResourceBase.ValidateUpdateData(ctx, userCred, query, input.StandaloneResourceBaseUpdateInput)
 	if err != nil {
 		return input, errors.Wrap(err, "SStandaloneResourceBase.ValidateUpdateData")
@@ -179,6 +185,34 @@ func (self *SCredential) ValidateUpdateData(ctx context.Context, userCred mcclie
 	return input, nil
 }
 
+func (self *SCredential) PostUpdate(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) {
+	self.SStandaloneResourceBase.PostUpdate(ctx, userCred, query, data)
+	blob, _ := data.GetString("blob")
+	if len(blob) == 0 {
+		return
+	}
+	err := self.setBlob(blob)
+	if err != nil {
+		log.Errorf("update blob of credential %s fail %s", self.Id, err)
+	}
+}
+
+func (self *SCredential) setBlob(blob string) error {
+	blobEnc, err := keys.CredentialKeyManager.Encrypt([]byte(blob))
+	if err != nil {
+		return errors.Wrap(err, "Encrypt")
+	}
+	_, err = db.Update(self, func() error {
+		self.EncryptedBlob = string(blobEnc)
+		self.KeyHash = keys.CredentialKeyManager.PrimaryKeyHash()
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "Update")
+	}
+	return nil
+}
+
 func (self *SCredential) GetExtraDetails(
 	ctx context.Context,
 	userCred mcclient.TokenCredential,
@@ -242,6 +276,48 @@ func (self *SCredential) GetAccessKeySecret() (*api.SAccessKeySecretBlob, error)
 	return nil, errors.Error("no an AK/SK credential")
 }
 
+// consumeWebAuthnRecoveryCode verifies the recovery code against the latest recovery
+// codes of the user and removes it, the caller holds the lock of the user so that
+// a code can not be used twice by concurrent requests
+func (manager *SCredentialManager) consumeWebAuthnRecoveryCode(userId string, code string) (int, error) {
+	q := manager.Query().Equals("user_id", userId).Equals("type", api.WEBAUTHN_RECOVERY_TYPE)
+	creds := make([]SCredential, 0)
+	err := db.FetchModelObjects(manager, q, &creds)
+	if err != nil {
+		return 0, errors.Wrap(err, "FetchModelObjects")
+	}
+	var latest *SCredential
+	recovery := api.SWebAuthnRecoveryCodes{}
+	for i := range creds {
+		blobJson, err := jsonutils.Parse(creds[i].getBlob())
+		if err != nil {
+			continue
+		}
+		curr := api.SWebAuthnRecoveryCodes{}
+		blobJson.Unmarshal(&curr)
+		if latest == nil || curr.Timestamp > recovery.Timestamp {
+			latest = &creds[i]
+			recovery = curr
+		}
+	}
+	if latest == nil {
+		return 0, httperrors.NewInvalidCredentialError("no webauthn recovery codes")
+	}
+	hash := api.HashWebAuthnRecoveryCode(code)
+	for i := range recovery.Codes {
+		if subtle.ConstantTimeCompare([]byte(recovery.Codes[i]), []byte(hash)) == 1 {
+			recovery.Codes = append(recovery.Codes[:i], recovery.Codes[i+1:]...)
+			recovery.Timestamp = time.Now().Unix()
+			err = latest.setBlob(jsonutils.Marshal(&recovery).String())
+			if err != nil {
+				return 0, errors.Wrap(err, "setBlob")
+			}
+			return len(recovery.Codes), nil
+		}
+	}
+	return 0, httperrors.NewInvalidCredentialError("invalid recovery code")
+}
+
 func (manager *SCredentialManager) ResourceScope() rbacutils.TRbacScope {
 	return rbacutils.ScopeUser
 }
@@ -23,6 +23,7 @@ import (
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/pkg/tristate"
+	"yunion.io/x/pkg/utils"
 	"yunion.io/x/sqlchemy"
 
 	api "yunion.io/x/onecloud/pkg/apis/identity"
@@ -66,6 +67,9 @@ type SDomain struct {
 
 	DomainId string `width:"64" charset:"ascii" default:"default" nullable:"false" index:"true"`
 	ParentId string `width:"64" charset:"ascii"`
+
+	// 域内用户的多因子认证策略
+	MfaPolicy string `width:"16" charset:"ascii" nullable:"false" default:"optional" list:"domain" update:"admin" create:"admin_optional"`
 }
 
 func (manager *SDomainManager) InitializeData() error {
@@ -336,6 +340,9 @@ func (domain *SDomain) ValidateUpdateData(ctx context.Context, userCred mcclient
 			}
 		}
 	}
+	if len(input.MfaPolicy) > 0 && !utils.IsInStringArray(input.MfaPolicy, api.MFA_POLICIES) {
+		return input, httperrors.NewInputParameterError("invalid mfa_policy %s, must be one of %s", input.MfaPolicy, api.MFA_POLICIES)
+	}
 	var err error
 	input.StandaloneResourceBaseUpdateInput, err = domain.SStandaloneResourceBase.ValidateUpdateData(ctx, userCred, query, input.StandaloneResourceBaseUpdateInput)
 	if err != nil {
@@ -479,6 +486,30 @@ func (domain *SDomain) PostCreate(ctx context.Context, userCred mcclient.TokenCr
 func (domain *SDomain) PostUpdate(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) {
 	domain.SStandaloneResourceBase.PostUpdate(ctx, userCred, query, data)
 	logclient.AddActionLogWithContext(ctx, domain, logclient.ACT_UPDATE, data, userCred, true)
+	if data.Contains("enabled") && !jsonutils.QueryBoolean(data, "enabled", false) {
+		err := domain.revokeTokens(ctx)
+		if err != nil {
+			log.Errorf("fail to revoke tokens of disabled domain %s: %s", domain.Name, err)
+		}
+	}
+}
+
+func (domain *SDomain) revokeTokens(ctx context.Context) error {
+	err := RevocationEventManager.RevokeProject(ctx, domain.Id)
+	if err != nil {
+		return errors.Wrap(err, "RevokeProject")
+	}
+	usrs, err := domain.getUsers()
+	if err != nil {
+		return errors.Wrap(err, "domain.getUsers")
+	}
+	for i := range usrs {
+		err = RevocationEventManager.RevokeUser(ctx, usrs[i].Id)
+		if err != nil {
+			return errors.Wrap(err, "RevokeUser")
+		}
+	}
+	return nil
 }
 
 func (domain *SDomain) PostDelete(ctx context.Context, userCred mcclient.TokenCredential) {
@@ -523,6 +554,9 @@ func (manager *SDomainManager) ValidateCreateData(
 ) (api.DomainCreateInput, error) {
 	var err error
 
+	if len(input.MfaPolicy) > 0 && !utils.IsInStringArray(input.MfaPolicy, api.MFA_POLICIES) {
+		return input, httperrors.NewInputParameterError("invalid mfa_policy %s, must be one of %s", input.MfaPolicy, api.MFA_POLICIES)
+	}
 	input.StandaloneResourceCreateInput, err = manager.SStandaloneResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, input.StandaloneResourceCreateInput)
 	if err != nil {
 		return input, errors.Wrap(err, "SStandaloneResourceBaseManager.ValidateCreateData")
@@ -16,7 +16,11 @@ package models
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/xml"
 	"fmt"
 	"strings"
@@ -106,6 +110,9 @@ type SIdentityProvider struct {
 	IsSso tristate.TriState `nullable:"true" list:"domain"`
 	// 是否是缺省SSO登录方式
 	IsDefault tristate.TriState `nullable:"true" list:"domain"`
+
+	// SCIM bearer token的sha256摘要，为空表示未开启SCIM
+	ScimTokenHash string `width:"64" charset:"ascii" nullable:"true" index:"true"`
 }
 
 func (manager *SIdentityProviderManager) initializeAutoCreateUser() error {
@@ -623,6 +630,7 @@ func (self *SIdentityProvider) getMoreDetails(out api.IdentityProviderDetails) a
 	out.ProjectCount, _ = self.GetProjectCount()
 	out.GroupCount, _ = self.GetGroupCount()
 	out.SyncIntervalSeconds = self.getSyncIntervalSeconds()
+	out.ScimEnabled = len(self.ScimTokenHash) > 0
 	if len(self.TargetDomainId) > 0 {
 		domain, _ := DomainManager.FetchDomainById(self.TargetDomainId)
 		if domain != nil {
@@ -1605,3 +1613,74 @@ func (idp *SIdentityProvider) setIsDefault(val tristate.TriState) error {
 	})
 	return errors.Wrap(err, "update")
 }
+
+func scimTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// 生成SCIM bearer token，token仅在此时返回一次，再次调用将使旧token失效
+func (idp *SIdentityProvider) PerformScimToken(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input jsonutils.JSONObject,
+) (jsonutils.JSONObject, error) {
+	if idp.Driver == api.IdentityDriverSQL {
+		return nil, errors.Wrap(httperrors.ErrNotSupported, "sql idp does not support scim")
+	}
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "rand.Read")
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	_, err = db.Update(idp, func() error {
+		idp.ScimTokenHash = scimTokenHash(token)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "update scim_token_hash")
+	}
+	db.OpsLog.LogEvent(idp, "scim_token", "", userCred)
+	return jsonutils.Marshal(api.IdentityProviderScimTokenOutput{Token: token}), nil
+}
+
+// 关闭SCIM，已下发的bearer token立即失效
+func (idp *SIdentityProvider) PerformDisableScim(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input jsonutils.JSONObject,
+) (jsonutils.JSONObject, error) {
+	if len(idp.ScimTokenHash) == 0 {
+		return nil, nil
+	}
+	_, err := db.Update(idp, func() error {
+		idp.ScimTokenHash = ""
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "update scim_token_hash")
+	}
+	db.OpsLog.LogEvent(idp, "disable_scim", "", userCred)
+	return nil, nil
+}
+
+// FetchIdentityProviderByScimToken returns the enabled identity provider the SCIM bearer token was issued for
+func (manager *SIdentityProviderManager) FetchIdentityProviderByScimToken(token string) (*SIdentityProvider, error) {
+	if len(token) == 0 {
+		return nil, errors.Wrap(httperrors.ErrInvalidCredential, "empty token")
+	}
+	q := manager.Query().Equals("scim_token_hash", scimTokenHash(token)).IsTrue("enabled")
+	idp := SIdentityProvider{}
+	idp.SetModelManager(manager, &idp)
+	err := q.First(&idp)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, errors.Wrap(httperrors.ErrInvalidCredential, "invalid token")
+		}
+		return nil, errors.Wrap(err, "Query")
+	}
+	return &idp, nil
+}
@@ -0,0 +1,284 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/keystone/keys"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+	"yunion.io/x/onecloud/pkg/util/stringutils2"
+)
+
+type SInformerWebhookManager struct {
+	db.SStandaloneResourceBaseManager
+	db.SEnabledResourceBaseManager
+}
+
+var InformerWebhookManager *SInformerWebhookManager
+
+func init() {
+	InformerWebhookManager = &SInformerWebhookManager{
+		SStandaloneResourceBaseManager: db.NewStandaloneResourceBaseManager(
+			SInformerWebhook{},
+			"informer_webhook",
+			"informer_webhook",
+			"informer_webhooks",
+		),
+	}
+	InformerWebhookManager.SetVirtualObject(InformerWebhookManager)
+}
+
+// SInformerWebhook is a subscription of the resource change events published
+// by the informer webhook backend of every service
+type SInformerWebhook struct {
+	db.SStandaloneResourceBase
+	db.SEnabledResourceBase `nullable:"false" default:"true" create:"optional" list:"admin"`
+
+	Url string `width:"512" charset:"ascii" nullable:"false" list:"admin" create:"admin_required" update:"admin"`
+	// comma separated keyword plurals of the subscribed resources, empty for all resources
+	Resources string `width:"1024" charset:"ascii" nullable:"true" list:"admin"`
+
+	EncryptedSecret string `nullable:"false"`
+	KeyHash         string `width:"64" charset:"ascii" nullable:"false"`
+}
+
+func validateInformerWebhookUrl(urlStr string) error {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return httperrors.NewInputParameterError("invalid url %s: %s", urlStr, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return httperrors.NewInputParameterError("invalid url %s, expect http(s)://<host>/<path>", urlStr)
+	}
+	return nil
+}
+
+func normalizeInformerWebhookResources(resources []string) string {
+	ret := make([]string, 0, len(resources))
+	for _, res := range resources {
+		res = strings.ToLower(strings.TrimSpace(res))
+		if len(res) > 0 && !utils.IsInStringArray(res, ret) {
+			ret = append(ret, res)
+		}
+	}
+	return strings.Join(ret, ",")
+}
+
+func encryptInformerWebhookSecret(secret string, data *jsonutils.JSONDict) error {
+	secretEnc, err := keys.CredentialKeyManager.Encrypt([]byte(secret))
+	if err != nil {
+		return errors.Wrap(err, "encrypt")
+	}
+	data.Set("encrypted_secret", jsonutils.NewString(string(secretEnc)))
+	data.Set("key_hash", jsonutils.NewString(keys.CredentialKeyManager.PrimaryKeyHash()))
+	// never keep the plain secret in the data, which is written to the action logs
+	data.Remove("secret")
+	return nil
+}
+
+func (manager *SInformerWebhookManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data *jsonutils.JSONDict) (*jsonutils.JSONDict, error) {
+	input := api.InformerWebhookCreateInput{}
+	err := data.Unmarshal(&input)
+	if err != nil {
+		return nil, httperrors.NewInternalServerError("unmarshal InformerWebhookCreateInput fail %s", err)
+	}
+	err = validateInformerWebhookUrl(input.Url)
+	if err != nil {
+		return nil, err
+	}
+	data.Set("resources", jsonutils.NewString(normalizeInformerWebhookResources(input.Resources)))
+	if len(input.Secret) == 0 {
+		input.Secret, err = generateApplicationCredentialSecret()
+		if err != nil {
+			return nil, httperrors.NewGeneralError(err)
+		}
+	}
+	err = encryptInformerWebhookSecret(input.Secret, data)
+	if err != nil {
+		return nil, httperrors.NewInternalServerError("encrypt error %s", err)
+	}
+
+	input.StandaloneResourceCreateInput, err = manager.SStandaloneResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, input.StandaloneResourceCreateInput)
+	if err != nil {
+		return nil, err
+	}
+	data.Update(jsonutils.Marshal(input.StandaloneResourceCreateInput))
+	return data, nil
+}
+
+func (webhook *SInformerWebhook) ValidateUpdateData(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data *jsonutils.JSONDict) (*jsonutils.JSONDict, error) {
+	input := api.InformerWebhookUpdateInput{}
+	err := data.Unmarshal(&input)
+	if err != nil {
+		return nil, httperrors.NewInternalServerError("unmarshal InformerWebhookUpdateInput fail %s", err)
+	}
+	if len(input.Url) > 0 {
+		err = validateInformerWebhookUrl(input.Url)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if data.Contains("resources") {
+		data.Set("resources", jsonutils.NewString(normalizeInformerWebhookResources(input.Resources)))
+	}
+	if len(input.Secret) > 0 {
+		err = encryptInformerWebhookSecret(input.Secret, data)
+		if err != nil {
+			return nil, httperrors.NewInternalServerError("encrypt error %s", err)
+		}
+	}
+
+	input.StandaloneResourceBaseUpdateInput, err = webhook.SStandaloneResourceBase.ValidateUpdateData(ctx, userCred, query, input.StandaloneResourceBaseUpdateInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SStandaloneResourceBase.ValidateUpdateData")
+	}
+	data.Update(jsonutils.Marshal(input.StandaloneResourceBaseUpdateInput))
+	return data, nil
+}
+
+func (webhook *SInformerWebhook) PostCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data jsonutils.JSONObject) {
+	webhook.SStandaloneResourceBase.PostCreate(ctx, userCred, ownerId, query, data)
+	logclient.AddActionLogWithContext(ctx, webhook, logclient.ACT_CREATE, data, userCred, true)
+}
+
+func (webhook *SInformerWebhook) PostUpdate(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) {
+	webhook.SStandaloneResourceBase.PostUpdate(ctx, userCred, query, data)
+	logclient.AddActionLogWithContext(ctx, webhook, logclient.ACT_UPDATE, data, userCred, true)
+}
+
+func (webhook *SInformerWebhook) PostDelete(ctx context.Context, userCred mcclient.TokenCredential) {
+	webhook.SStandaloneResourceBase.PostDelete(ctx, userCred)
+	logclient.AddActionLogWithContext(ctx, webhook, logclient.ACT_DELETE, nil, userCred, true)
+}
+
+func (webhook *SInformerWebhook) AllowPerformEnable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowPerform(userCred, webhook, "enable")
+}
+
+func (webhook *SInformerWebhook) PerformEnable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformEnableInput) (jsonutils.JSONObject, error) {
+	err := db.EnabledPerformEnable(webhook, ctx, userCred, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "db.EnabledPerformEnable")
+	}
+	return nil, nil
+}
+
+func (webhook *SInformerWebhook) AllowPerformDisable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowPerform(userCred, webhook, "disable")
+}
+
+func (webhook *SInformerWebhook) PerformDisable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformDisableInput) (jsonutils.JSONObject, error) {
+	err := db.EnabledPerformEnable(webhook, ctx, userCred, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "db.EnabledPerformEnable")
+	}
+	return nil, nil
+}
+
+func (webhook *SInformerWebhook) getSecret() string {
+	return string(keys.CredentialKeyManager.Decrypt([]byte(webhook.EncryptedSecret), time.Duration(-1)))
+}
+
+func (manager *SInformerWebhookManager) FetchCustomizeColumns(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	objs []interface{},
+	fields stringutils2.SSortedStrings,
+	isList bool,
+) []api.InformerWebhookDetails {
+	rows := make([]api.InformerWebhookDetails, len(objs))
+
+	stdRows := manager.SStandaloneResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
+
+	for i := range rows {
+		rows[i] = api.InformerWebhookDetails{
+			StandaloneResourceDetails: stdRows[i],
+			Secret:                    objs[i].(*SInformerWebhook).getSecret(),
+		}
+	}
+
+	return rows
+}
+
+// 资源变更事件Webhook订阅列表
+func (manager *SInformerWebhookManager) ListItemFilter(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query api.InformerWebhookListInput,
+) (*sqlchemy.SQuery, error) {
+	var err error
+	q, err = manager.SStandaloneResourceBaseManager.ListItemFilter(ctx, q, userCred, query.StandaloneResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SStandaloneResourceBaseManager.ListItemFilter")
+	}
+	q, err = manager.SEnabledResourceBaseManager.ListItemFilter(ctx, q, userCred, query.EnabledResourceBaseListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SEnabledResourceBaseManager.ListItemFilter")
+	}
+	if len(query.Resource) > 0 {
+		resField := q.Field("resources")
+		q = q.Filter(sqlchemy.OR(
+			sqlchemy.IsNullOrEmpty(resField),
+			sqlchemy.Equals(resField, query.Resource),
+			sqlchemy.Startswith(resField, query.Resource+","),
+			sqlchemy.Endswith(resField, ","+query.Resource),
+			sqlchemy.Contains(resField, ","+query.Resource+","),
+		))
+	}
+	return q, nil
+}
+
+func (manager *SInformerWebhookManager) OrderByExtraFields(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query api.InformerWebhookListInput,
+) (*sqlchemy.SQuery, error) {
+	var err error
+
+	q, err = manager.SStandaloneResourceBaseManager.OrderByExtraFields(ctx, q, userCred, query.StandaloneResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SStandaloneResourceBaseManager.OrderByExtraFields")
+	}
+
+	return q, nil
+}
+
+func (manager *SInformerWebhookManager) QueryDistinctExtraField(q *sqlchemy.SQuery, field string) (*sqlchemy.SQuery, error) {
+	var err error
+
+	q, err = manager.SStandaloneResourceBaseManager.QueryDistinctExtraField(q, field)
+	if err == nil {
+		return q, nil
+	}
+
+	return q, httperrors.ErrNotFound
+}
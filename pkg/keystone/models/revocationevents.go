@@ -0,0 +1,200 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/keystone/options"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+const (
+	// revocation events written by other keystone instances become
+	// effective on this instance no later than this interval
+	revocationEventCacheSeconds = 5
+)
+
+// +onecloud:swagger-gen-ignore
+type SRevocationEventManager struct {
+	db.SModelBaseManager
+
+	lock     sync.Mutex
+	events   []api.SRevocationEvent
+	lastSync time.Time
+}
+
+var RevocationEventManager *SRevocationEventManager
+
+func init() {
+	RevocationEventManager = &SRevocationEventManager{
+		SModelBaseManager: db.NewModelBaseManager(
+			SRevocationEvent{},
+			"revocation_event",
+			"revocation_event",
+			"revocation_events",
+		),
+	}
+	RevocationEventManager.SetVirtualObject(RevocationEventManager)
+}
+
+// SRevocationEvent revokes fernet tokens issued before IssuedBefore by
+// audit id, user, project or the combination of them
+type SRevocationEvent struct {
+	db.SModelBase
+
+	Id int64 `primary:"true" auto_increment:"true"`
+
+	AuditId   string `width:"64" charset:"ascii" nullable:"true"`
+	UserId    string `width:"64" charset:"ascii" nullable:"true" index:"true"`
+	ProjectId string `width:"64" charset:"ascii" nullable:"true"`
+
+	IssuedBefore time.Time `nullable:"false"`
+	RevokedAt    time.Time `nullable:"false" index:"true"`
+	ExpiresAt    time.Time `nullable:"false" index:"true"`
+}
+
+func (event *SRevocationEvent) toApi() api.SRevocationEvent {
+	return api.SRevocationEvent{
+		Id:           event.Id,
+		AuditId:      event.AuditId,
+		UserId:       event.UserId,
+		ProjectId:    event.ProjectId,
+		IssuedBefore: event.IssuedBefore,
+		RevokedAt:    event.RevokedAt,
+		ExpiresAt:    event.ExpiresAt,
+	}
+}
+
+func (manager *SRevocationEventManager) revoke(ctx context.Context, auditId, userId, projectId string) error {
+	now := time.Now().UTC()
+	event := SRevocationEvent{
+		AuditId:   auditId,
+		UserId:    userId,
+		ProjectId: projectId,
+		// the issue time of fernet tokens is accurate to seconds
+		IssuedBefore: now.Truncate(time.Second),
+		RevokedAt:    now,
+		ExpiresAt:    now.Add(time.Duration(options.Options.TokenExpirationSeconds) * time.Second),
+	}
+	event.SetModelManager(manager, &event)
+	err := manager.TableSpec().Insert(ctx, &event)
+	if err != nil {
+		return errors.Wrap(err, "Insert")
+	}
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	manager.events = append(manager.events, event.toApi())
+	return nil
+}
+
+// RevokeToken revokes the token of the audit id, i.e. logout
+func (manager *SRevocationEventManager) RevokeToken(ctx context.Context, auditId string) error {
+	if len(auditId) == 0 {
+		return httperrors.NewInputParameterError("empty audit id")
+	}
+	return manager.revoke(ctx, auditId, "", "")
+}
+
+// RevokeUser revokes all tokens of the user
+func (manager *SRevocationEventManager) RevokeUser(ctx context.Context, userId string) error {
+	return manager.revoke(ctx, "", userId, "")
+}
+
+// RevokeProject revokes all tokens scoped to the project or domain
+func (manager *SRevocationEventManager) RevokeProject(ctx context.Context, projectId string) error {
+	return manager.revoke(ctx, "", "", projectId)
+}
+
+// RevokeUserProject revokes tokens of the user scoped to the project or domain
+func (manager *SRevocationEventManager) RevokeUserProject(ctx context.Context, userId, projectId string) error {
+	return manager.revoke(ctx, "", userId, projectId)
+}
+
+func (manager *SRevocationEventManager) revokeGroupUsers(ctx context.Context, groupId, projectId string) error {
+	for _, userId := range UsergroupManager.FetchGroupUserIds(groupId) {
+		err := manager.revoke(ctx, "", userId, projectId)
+		if err != nil {
+			return errors.Wrapf(err, "revoke user %s", userId)
+		}
+	}
+	return nil
+}
+
+// FetchEvents returns the unexpired events revoked since the time
+func (manager *SRevocationEventManager) FetchEvents(since time.Time) ([]api.SRevocationEvent, error) {
+	q := manager.Query().GT("expires_at", time.Now().UTC())
+	if !since.IsZero() {
+		q = q.GE("revoked_at", since)
+	}
+	q = q.Asc("id")
+	events := make([]SRevocationEvent, 0)
+	err := db.FetchModelObjects(manager, q, &events)
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "FetchModelObjects")
+	}
+	ret := make([]api.SRevocationEvent, len(events))
+	for i := range events {
+		ret[i] = events[i].toApi()
+	}
+	return ret, nil
+}
+
+func (manager *SRevocationEventManager) getEvents() []api.SRevocationEvent {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	if time.Since(manager.lastSync) > revocationEventCacheSeconds*time.Second {
+		events, err := manager.FetchEvents(time.Time{})
+		if err != nil {
+			// keep using the stale events
+			log.Errorf("fetch revocation events fail: %s", err)
+		} else {
+			manager.events = events
+			manager.lastSync = time.Now()
+		}
+	}
+	return manager.events
+}
+
+// IsRevoked tells whether the token issued at issuedAt is revoked
+func (manager *SRevocationEventManager) IsRevoked(userId, projectId string, auditIds []string, issuedAt time.Time) bool {
+	for _, event := range manager.getEvents() {
+		if event.IsRevoked(userId, projectId, auditIds, issuedAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// PurgeExpiredEvents removes the events after all of the tokens they revoke are expired
+func (manager *SRevocationEventManager) PurgeExpiredEvents(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	sqlStr := fmt.Sprintf("DELETE FROM `%s` WHERE `expires_at` < ?", manager.TableSpec().Name())
+	_, err := sqlchemy.Exec(sqlStr, time.Now().UTC())
+	if err != nil {
+		log.Errorf("purge expired revocation events fail: %s", err)
+	}
+}
@@ -73,7 +73,7 @@ func (membership *SUsergroupMembership) GetName() string {
 	return fmt.Sprintf("%s-%s", membership.UserId, membership.GroupId)
 }
 
-func (manager *SUsergroupManager) getUserGroupIds(userId string) []string {
+func (manager *SUsergroupManager) FetchUserGroupIds(userId string) []string {
 	members := make([]SUsergroupMembership, 0)
 	q := manager.Query().Equals("user_id", userId)
 	err := db.FetchModelObjects(manager, q, &members)
@@ -88,12 +88,12 @@ func (manager *SUsergroupManager) getUserGroupIds(userId string) []string {
 	return groupIds
 }
 
-func (manager *SUsergroupManager) getGroupUserIds(groupId string) []string {
+func (manager *SUsergroupManager) FetchGroupUserIds(groupId string) []string {
 	members := make([]SUsergroupMembership, 0)
 	q := manager.Query().Equals("group_id", groupId)
 	err := db.FetchModelObjects(manager, q, &members)
 	if err != nil {
-		log.Errorf("getGroupUserIds fail %s", err)
+		log.Errorf("FetchGroupUserIds fail %s", err)
 		return nil
 	}
 	userIds := make([]string, len(members))
@@ -104,7 +104,7 @@ func (manager *SUsergroupManager) getGroupUserIds(groupId string) []string {
 }
 
 func (manager *SUsergroupManager) SyncUserGroups(ctx context.Context, userCred mcclient.TokenCredential, userId string, groupIds []string) {
-	oldGroupIds := manager.getUserGroupIds(userId)
+	oldGroupIds := manager.FetchUserGroupIds(userId)
 	sort.Strings(oldGroupIds)
 	sort.Strings(groupIds)
 
@@ -122,7 +122,7 @@ func (manager *SUsergroupManager) SyncUserGroups(ctx context.Context, userCred m
 }
 
 func (manager *SUsergroupManager) SyncGroupUsers(ctx context.Context, userCred mcclient.TokenCredential, groupId string, userIds []string) {
-	oldUserIds := manager.getGroupUserIds(groupId)
+	oldUserIds := manager.FetchGroupUserIds(groupId)
 	sort.Strings(oldUserIds)
 	sort.Strings(userIds)
 
@@ -159,6 +159,11 @@ func (manager *SUsergroupManager) remove(ctx context.Context, userCred mcclient.
 	if err != nil {
 		return errors.Wrap(err, "MarkDelete")
 	}
+	// roles inherited from the group are gone
+	err = RevocationEventManager.RevokeUser(ctx, usr.Id)
+	if err != nil {
+		return errors.Wrap(err, "RevocationEventManager.RevokeUser")
+	}
 	db.OpsLog.LogEvent(usr, db.ACT_DETACH, grp.GetShortDesc(ctx), userCred)
 	return nil
 }
@@ -736,6 +736,12 @@ func (user *SUser) PostUpdate(ctx context.Context, userCred mcclient.TokenCreden
 		}
 		logclient.AddActionLogWithContext(ctx, user, logclient.ACT_UPDATE_PASSWORD, nil, userCred, true)
 	}
+	if data.Contains("enabled") && !jsonutils.QueryBoolean(data, "enabled", false) {
+		err := RevocationEventManager.RevokeUser(ctx, user.Id)
+		if err != nil {
+			log.Errorf("fail to revoke tokens of disabled user %s: %s", user.Name, err)
+		}
+	}
 	if enabled, _ := data.Bool("enabled"); enabled {
 		localUser, err := LocalUserManager.fetchLocalUser(user.Id, user.DomainId, 0)
 		if err != nil {
@@ -800,6 +806,11 @@ func (user *SUser) Delete(ctx context.Context, userCred mcclient.TokenCredential
 		return errors.Wrap(err, "UsergroupManager.delete")
 	}
 
+	err = ApplicationCredentialManager.deleteByUser(ctx, userCred, user.Id)
+	if err != nil {
+		return errors.Wrap(err, "ApplicationCredentialManager.deleteByUser")
+	}
+
 	localUser, err := LocalUserManager.delete(user.Id, user.DomainId)
 	if err != nil {
 		return errors.Wrap(err, "LocalUserManager.delete")
@@ -960,6 +971,31 @@ func (user *SUser) UnlinkIdp(idpId string) error {
 	return IdmappingManager.deleteAny(idpId, api.IdMappingEntityUser, user.Id)
 }
 
+func (user *SUser) AllowPerformConsumeWebauthnRecoveryCode(ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input api.UserConsumeWebAuthnRecoveryCodeInput,
+) bool {
+	return db.IsAdminAllowPerform(userCred, user, "consume-webauthn-recovery-code")
+}
+
+// 校验并消耗用户的一个WebAuthn恢复码，操作在用户锁内完成，每个恢复码只能使用一次
+func (user *SUser) PerformConsumeWebauthnRecoveryCode(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input api.UserConsumeWebAuthnRecoveryCodeInput,
+) (jsonutils.JSONObject, error) {
+	if len(input.Code) == 0 {
+		return nil, httperrors.NewMissingParameterError("code")
+	}
+	cnt, err := CredentialManager.consumeWebAuthnRecoveryCode(user.Id, input.Code)
+	if err != nil {
+		return nil, err
+	}
+	return jsonutils.Marshal(api.UserConsumeWebAuthnRecoveryCodeOutput{RecoveryCodesLeft: cnt}), nil
+}
+
 func (user *SUser) AllowPerformJoin(ctx context.Context,
 	userCred mcclient.TokenCredential,
 	query jsonutils.JSONObject,
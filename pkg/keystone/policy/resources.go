@@ -24,6 +24,7 @@ var (
 		"domains",
 		"services",
 		"endpoints",
+		"informer_webhooks",
 	}
 	identityDomainResources = []string{
 		"identity_providers",
@@ -35,6 +36,7 @@ var (
 	}
 	identityUserResources = []string{
 		"credentials",
+		"application_credentials",
 	}
 )
 
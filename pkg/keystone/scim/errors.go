@@ -0,0 +1,89 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+const (
+	SCIM_TYPE_INVALID_FILTER = "invalidFilter"
+	SCIM_TYPE_INVALID_PATH   = "invalidPath"
+	SCIM_TYPE_INVALID_SYNTAX = "invalidSyntax"
+	SCIM_TYPE_INVALID_VALUE  = "invalidValue"
+	SCIM_TYPE_NO_TARGET      = "noTarget"
+	SCIM_TYPE_TOO_MANY       = "tooMany"
+	SCIM_TYPE_UNIQUENESS     = "uniqueness"
+)
+
+type sScimError struct {
+	status   int
+	scimType string
+	detail   string
+}
+
+func (e *sScimError) Error() string {
+	if len(e.scimType) > 0 {
+		return fmt.Sprintf("%d %s: %s", e.status, e.scimType, e.detail)
+	}
+	return fmt.Sprintf("%d: %s", e.status, e.detail)
+}
+
+func newScimError(status int, scimType string, msg string, params ...interface{}) error {
+	if len(params) > 0 {
+		msg = fmt.Sprintf(msg, params...)
+	}
+	return &sScimError{status: status, scimType: scimType, detail: msg}
+}
+
+func newBadRequestError(scimType string, msg string, params ...interface{}) error {
+	return newScimError(http.StatusBadRequest, scimType, msg, params...)
+}
+
+func newNotFoundError(msg string, params ...interface{}) error {
+	return newScimError(http.StatusNotFound, "", msg, params...)
+}
+
+func newConflictError(msg string, params ...interface{}) error {
+	return newScimError(http.StatusConflict, SCIM_TYPE_UNIQUENESS, msg, params...)
+}
+
+func toScimError(err error) *sScimError {
+	if e, ok := err.(*sScimError); ok {
+		return e
+	}
+	switch errors.Cause(err) {
+	case sql.ErrNoRows, httperrors.ErrNotFound, httperrors.ErrResourceNotFound:
+		return &sScimError{status: http.StatusNotFound, detail: err.Error()}
+	case httperrors.ErrInvalidCredential:
+		return &sScimError{status: http.StatusUnauthorized, detail: err.Error()}
+	case httperrors.ErrForbidden:
+		return &sScimError{status: http.StatusForbidden, detail: err.Error()}
+	case httperrors.ErrDuplicateName, httperrors.ErrConflict:
+		return &sScimError{status: http.StatusConflict, scimType: SCIM_TYPE_UNIQUENESS, detail: err.Error()}
+	case httperrors.ErrInputParameter:
+		return &sScimError{status: http.StatusBadRequest, scimType: SCIM_TYPE_INVALID_VALUE, detail: err.Error()}
+	}
+	if e, ok := errors.Cause(err).(*sScimError); ok {
+		return &sScimError{status: e.status, scimType: e.scimType, detail: err.Error()}
+	}
+	return &sScimError{status: http.StatusInternalServerError, detail: err.Error()}
+}
@@ -0,0 +1,471 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+)
+
+// IFilter is a parsed SCIM filter expression, see RFC 7644 section 3.4.2.2
+type IFilter interface {
+	Match(obj jsonutils.JSONObject) bool
+}
+
+const (
+	FILTER_OP_EQ = "eq"
+	FILTER_OP_NE = "ne"
+	FILTER_OP_CO = "co"
+	FILTER_OP_SW = "sw"
+	FILTER_OP_EW = "ew"
+	FILTER_OP_PR = "pr"
+	FILTER_OP_GT = "gt"
+	FILTER_OP_GE = "ge"
+	FILTER_OP_LT = "lt"
+	FILTER_OP_LE = "le"
+)
+
+type sAndFilter struct {
+	left  IFilter
+	right IFilter
+}
+
+func (f *sAndFilter) Match(obj jsonutils.JSONObject) bool {
+	return f.left.Match(obj) && f.right.Match(obj)
+}
+
+type sOrFilter struct {
+	left  IFilter
+	right IFilter
+}
+
+func (f *sOrFilter) Match(obj jsonutils.JSONObject) bool {
+	return f.left.Match(obj) || f.right.Match(obj)
+}
+
+type sNotFilter struct {
+	filter IFilter
+}
+
+func (f *sNotFilter) Match(obj jsonutils.JSONObject) bool {
+	return !f.filter.Match(obj)
+}
+
+// sValuePathFilter matches if any element of a multi-valued attribute matches the filter, e.g. emails[type eq "work"]
+type sValuePathFilter struct {
+	attr   string
+	filter IFilter
+}
+
+func (f *sValuePathFilter) Match(obj jsonutils.JSONObject) bool {
+	for _, val := range resolveAttrPath(obj, f.attr) {
+		if f.filter.Match(val) {
+			return true
+		}
+	}
+	return false
+}
+
+type sCompareFilter struct {
+	attr  string
+	op    string
+	value jsonutils.JSONObject
+}
+
+func (f *sCompareFilter) Match(obj jsonutils.JSONObject) bool {
+	vals := resolveAttrPath(obj, f.attr)
+	if f.op == FILTER_OP_PR {
+		for _, val := range vals {
+			if !isEmptyValue(val) {
+				return true
+			}
+		}
+		return false
+	}
+	if f.op == FILTER_OP_NE {
+		for _, val := range vals {
+			if compareValue(val, FILTER_OP_EQ, f.value) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, val := range vals {
+		if compareValue(val, f.op, f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmptyValue(val jsonutils.JSONObject) bool {
+	switch v := val.(type) {
+	case *jsonutils.JSONString:
+		return len(v.Value()) == 0
+	case *jsonutils.JSONArray:
+		return v.Length() == 0
+	case *jsonutils.JSONDict:
+		return v.Length() == 0
+	}
+	return val == nil || val == jsonutils.JSONNull
+}
+
+func normalizeValue(val jsonutils.JSONObject) string {
+	if s, ok := val.(*jsonutils.JSONString); ok {
+		return strings.ToLower(s.Value())
+	}
+	return strings.ToLower(val.String())
+}
+
+func compareValue(val jsonutils.JSONObject, op string, target jsonutils.JSONObject) bool {
+	if target == jsonutils.JSONNull {
+		return op == FILTER_OP_EQ && isEmptyValue(val)
+	}
+	if dict, ok := val.(*jsonutils.JSONDict); ok {
+		// a complex attribute without sub-attribute is compared by its value
+		v, err := getAttr(dict, "value")
+		if err != nil {
+			return false
+		}
+		val = v
+	}
+	v1, v2 := normalizeValue(val), normalizeValue(target)
+	switch op {
+	case FILTER_OP_EQ:
+		return v1 == v2
+	case FILTER_OP_CO:
+		return strings.Contains(v1, v2)
+	case FILTER_OP_SW:
+		return strings.HasPrefix(v1, v2)
+	case FILTER_OP_EW:
+		return strings.HasSuffix(v1, v2)
+	}
+	cmp := strings.Compare(v1, v2)
+	if f1, err := strconv.ParseFloat(v1, 64); err == nil {
+		if f2, err := strconv.ParseFloat(v2, 64); err == nil {
+			switch {
+			case f1 < f2:
+				cmp = -1
+			case f1 > f2:
+				cmp = 1
+			default:
+				cmp = 0
+			}
+		}
+	}
+	switch op {
+	case FILTER_OP_GT:
+		return cmp > 0
+	case FILTER_OP_GE:
+		return cmp >= 0
+	case FILTER_OP_LT:
+		return cmp < 0
+	case FILTER_OP_LE:
+		return cmp <= 0
+	}
+	return false
+}
+
+// stripSchemaUrn turns a fully qualified attribute such as
+// urn:ietf:params:scim:schemas:core:2.0:User:name.givenName into name.givenName
+func stripSchemaUrn(attr string) string {
+	if strings.HasPrefix(strings.ToLower(attr), "urn:") {
+		if pos := strings.LastIndexByte(attr, ':'); pos >= 0 {
+			return attr[pos+1:]
+		}
+	}
+	return attr
+}
+
+// getAttr looks up an attribute of a dict case insensitively
+func getAttr(dict *jsonutils.JSONDict, attr string) (jsonutils.JSONObject, error) {
+	if val, err := dict.Get(attr); err == nil {
+		return val, nil
+	}
+	m, _ := dict.GetMap()
+	for k, val := range m {
+		if strings.EqualFold(k, attr) {
+			return val, nil
+		}
+	}
+	return nil, jsonutils.ErrJsonDictKeyNotFound
+}
+
+// resolveAttrPath returns all values of a dotted attribute path, multi-valued attributes are flattened
+func resolveAttrPath(obj jsonutils.JSONObject, path string) []jsonutils.JSONObject {
+	current := []jsonutils.JSONObject{obj}
+	for _, attr := range strings.Split(path, ".") {
+		next := make([]jsonutils.JSONObject, 0)
+		for _, val := range current {
+			dict, ok := val.(*jsonutils.JSONDict)
+			if !ok {
+				continue
+			}
+			child, err := getAttr(dict, attr)
+			if err != nil {
+				continue
+			}
+			if arr, ok := child.(*jsonutils.JSONArray); ok {
+				elems, _ := arr.GetArray()
+				next = append(next, elems...)
+			} else {
+				next = append(next, child)
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+const (
+	tokenWord = iota
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+)
+
+type sToken struct {
+	kind  int
+	value string
+}
+
+func tokenizeFilter(filter string) ([]sToken, error) {
+	tokens := make([]sToken, 0)
+	for i := 0; i < len(filter); {
+		c := filter[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, sToken{kind: tokenLParen, value: "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, sToken{kind: tokenRParen, value: ")"})
+			i++
+		case c == '[':
+			tokens = append(tokens, sToken{kind: tokenLBracket, value: "["})
+			i++
+		case c == ']':
+			tokens = append(tokens, sToken{kind: tokenRBracket, value: "]"})
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(filter) && filter[j] != '"'; j++ {
+				if filter[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(filter) {
+				return nil, newBadRequestError(SCIM_TYPE_INVALID_FILTER, "unterminated string in filter %q", filter)
+			}
+			str, err := strconv.Unquote(filter[i : j+1])
+			if err != nil {
+				return nil, newBadRequestError(SCIM_TYPE_INVALID_FILTER, "invalid string %s in filter", filter[i:j+1])
+			}
+			tokens = append(tokens, sToken{kind: tokenString, value: str})
+			i = j + 1
+		default:
+			j := i
+			for ; j < len(filter) && !strings.ContainsRune(" \t\n\r()[]\"", rune(filter[j])); j++ {
+			}
+			tokens = append(tokens, sToken{kind: tokenWord, value: filter[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type sFilterParser struct {
+	filter string
+	tokens []sToken
+	pos    int
+}
+
+// ParseFilter parses a SCIM filter expression,
+// attribute operators eq, ne, co, sw, ew, pr, gt, ge, lt, le,
+// logical operators and, or, not, grouping with parentheses and value path filters are supported
+func ParseFilter(filter string) (IFilter, error) {
+	tokens, err := tokenizeFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, newBadRequestError(SCIM_TYPE_INVALID_FILTER, "empty filter")
+	}
+	parser := &sFilterParser{filter: filter, tokens: tokens}
+	f, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return nil, parser.errorf("unexpected %s", parser.tokens[parser.pos].value)
+	}
+	return f, nil
+}
+
+func (p *sFilterParser) errorf(msg string, params ...interface{}) error {
+	return newBadRequestError(SCIM_TYPE_INVALID_FILTER, "filter %q: %s", p.filter, fmt.Sprintf(msg, params...))
+}
+
+func (p *sFilterParser) peek() *sToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *sFilterParser) next() *sToken {
+	tok := p.peek()
+	if tok != nil {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *sFilterParser) peekKeyword(keyword string) bool {
+	tok := p.peek()
+	return tok != nil && tok.kind == tokenWord && strings.EqualFold(tok.value, keyword)
+}
+
+func (p *sFilterParser) expect(kind int, value string) error {
+	tok := p.next()
+	if tok == nil {
+		return p.errorf("expect %s at end", value)
+	}
+	if tok.kind != kind {
+		return p.errorf("expect %s, got %s", value, tok.value)
+	}
+	return nil
+}
+
+func (p *sFilterParser) parseOr() (IFilter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &sOrFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sFilterParser) parseAnd() (IFilter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &sAndFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sFilterParser) parseUnary() (IFilter, error) {
+	if p.peekKeyword("not") {
+		p.next()
+		f, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &sNotFilter{filter: f}, nil
+	}
+	tok := p.peek()
+	if tok == nil {
+		return nil, p.errorf("unexpected end")
+	}
+	if tok.kind == tokenLParen {
+		p.next()
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expect(tokenRParen, ")")
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	return p.parseAttrExp()
+}
+
+func (p *sFilterParser) parseAttrExp() (IFilter, error) {
+	tok := p.next()
+	if tok.kind != tokenWord {
+		return nil, p.errorf("expect attribute, got %s", tok.value)
+	}
+	attr := stripSchemaUrn(tok.value)
+	if next := p.peek(); next != nil && next.kind == tokenLBracket {
+		p.next()
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expect(tokenRBracket, "]")
+		if err != nil {
+			return nil, err
+		}
+		return &sValuePathFilter{attr: attr, filter: f}, nil
+	}
+	opTok := p.next()
+	if opTok == nil || opTok.kind != tokenWord {
+		return nil, p.errorf("expect operator after %s", attr)
+	}
+	op := strings.ToLower(opTok.value)
+	switch op {
+	case FILTER_OP_PR:
+		return &sCompareFilter{attr: attr, op: op}, nil
+	case FILTER_OP_EQ, FILTER_OP_NE, FILTER_OP_CO, FILTER_OP_SW, FILTER_OP_EW, FILTER_OP_GT, FILTER_OP_GE, FILTER_OP_LT, FILTER_OP_LE:
+	default:
+		return nil, p.errorf("unsupported operator %s", opTok.value)
+	}
+	valTok := p.next()
+	if valTok == nil {
+		return nil, p.errorf("expect value after %s %s", attr, op)
+	}
+	var value jsonutils.JSONObject
+	switch {
+	case valTok.kind == tokenString:
+		value = jsonutils.NewString(valTok.value)
+	case valTok.kind == tokenWord && strings.EqualFold(valTok.value, "true"):
+		value = jsonutils.JSONTrue
+	case valTok.kind == tokenWord && strings.EqualFold(valTok.value, "false"):
+		value = jsonutils.JSONFalse
+	case valTok.kind == tokenWord && strings.EqualFold(valTok.value, "null"):
+		value = jsonutils.JSONNull
+	case valTok.kind == tokenWord:
+		if _, err := strconv.ParseFloat(valTok.value, 64); err != nil {
+			return nil, p.errorf("invalid value %s", valTok.value)
+		}
+		value = jsonutils.NewString(valTok.value)
+	default:
+		return nil, p.errorf("invalid value %s", valTok.value)
+	}
+	return &sCompareFilter{attr: attr, op: op, value: value}, nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/sqlchemy"
+)
+
+// sFilterColumns maps the lower cased SCIM attributes to the columns they are stored in,
+// "a|b" stands for column a, or column b if a is empty
+type sFilterColumns map[string]string
+
+var (
+	userFilterColumns = sFilterColumns{
+		"id":                 "id",
+		"username":           "name",
+		"displayname":        "displayname",
+		"name.formatted":     "displayname",
+		"emails":             "email",
+		"emails.value":       "email",
+		"phonenumbers":       "mobile",
+		"phonenumbers.value": "mobile",
+	}
+	groupFilterColumns = sFilterColumns{
+		"id":          "id",
+		"displayname": "displayname|name",
+	}
+)
+
+// sqlCondition translates the filter to a condition of the query, it returns
+// nil if any part of the filter can not be evaluated by the database
+func (columns sFilterColumns) sqlCondition(q *sqlchemy.SQuery, filter IFilter) sqlchemy.ICondition {
+	switch f := filter.(type) {
+	case *sAndFilter:
+		left := columns.sqlCondition(q, f.left)
+		right := columns.sqlCondition(q, f.right)
+		if left == nil || right == nil {
+			return nil
+		}
+		return sqlchemy.AND(left, right)
+	case *sOrFilter:
+		left := columns.sqlCondition(q, f.left)
+		right := columns.sqlCondition(q, f.right)
+		if left == nil || right == nil {
+			return nil
+		}
+		return sqlchemy.OR(left, right)
+	case *sNotFilter:
+		cond := columns.sqlCondition(q, f.filter)
+		if cond == nil {
+			return nil
+		}
+		return sqlchemy.NOT(cond)
+	case *sCompareFilter:
+		return columns.compareCondition(q, f)
+	}
+	return nil
+}
+
+func (columns sFilterColumns) compareCondition(q *sqlchemy.SQuery, f *sCompareFilter) sqlchemy.ICondition {
+	column, ok := columns[strings.ToLower(stripSchemaUrn(f.attr))]
+	if !ok {
+		return nil
+	}
+	if pos := strings.IndexByte(column, '|'); pos > 0 {
+		primary := q.Field(column[:pos])
+		cond := compareFieldCondition(primary, f)
+		fallback := compareFieldCondition(q.Field(column[pos+1:]), f)
+		if cond == nil || fallback == nil {
+			return nil
+		}
+		return sqlchemy.OR(
+			sqlchemy.AND(sqlchemy.IsNotEmpty(primary), cond),
+			sqlchemy.AND(sqlchemy.IsNullOrEmpty(primary), fallback),
+		)
+	}
+	return compareFieldCondition(q.Field(column), f)
+}
+
+func compareFieldCondition(field sqlchemy.IQueryField, f *sCompareFilter) sqlchemy.ICondition {
+	if f.op == FILTER_OP_PR {
+		return sqlchemy.IsNotEmpty(field)
+	}
+	if f.value == jsonutils.JSONNull {
+		if f.op == FILTER_OP_EQ {
+			return sqlchemy.IsNullOrEmpty(field)
+		}
+		return nil
+	}
+	value, ok := f.value.(*jsonutils.JSONString)
+	if !ok {
+		return nil
+	}
+	// columns are compared case insensitively by the collation, as the SCIM attributes
+	var cond sqlchemy.ICondition
+	switch f.op {
+	case FILTER_OP_EQ:
+		cond = sqlchemy.Equals(field, value.Value())
+	case FILTER_OP_NE:
+		return sqlchemy.OR(sqlchemy.NotEquals(field, value.Value()), sqlchemy.IsNull(field))
+	case FILTER_OP_CO:
+		cond = sqlchemy.Contains(field, value.Value())
+	case FILTER_OP_SW:
+		cond = sqlchemy.Startswith(field, value.Value())
+	case FILTER_OP_EW:
+		cond = sqlchemy.Endswith(field, value.Value())
+	default:
+		// gt, ge, lt and le compare numbers and dates, which the columns can not do as strings
+		return nil
+	}
+	// a NULL column makes the comparison NULL instead of false, which could not be negated
+	return sqlchemy.AND(sqlchemy.IsNotNull(field), cond)
+}
+
+// narrowCondition returns the conditions of the conjuncts of the filter that
+// can be evaluated by the database, the whole filter still needs to be matched
+func (columns sFilterColumns) narrowCondition(q *sqlchemy.SQuery, filter IFilter) sqlchemy.ICondition {
+	if f, ok := filter.(*sAndFilter); ok {
+		left := columns.narrowCondition(q, f.left)
+		right := columns.narrowCondition(q, f.right)
+		switch {
+		case left == nil:
+			return right
+		case right == nil:
+			return left
+		}
+		return sqlchemy.AND(left, right)
+	}
+	return columns.sqlCondition(q, filter)
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"testing"
+
+	"yunion.io/x/sqlchemy"
+)
+
+type sTestIdentity struct {
+	Id          string `width:"128" charset:"ascii" primary:"true"`
+	Name        string `width:"128" charset:"utf8"`
+	Displayname string `width:"128" charset:"utf8"`
+	Email       string `width:"64" charset:"utf8"`
+	Mobile      string `width:"20" charset:"ascii"`
+}
+
+func TestFilterSqlCondition(t *testing.T) {
+	q := sqlchemy.NewTableSpecFromStruct(sTestIdentity{}, "identities_tbl").Query()
+	cases := []struct {
+		Columns sFilterColumns
+		Filter  string
+		Where   string
+		Narrow  string
+	}{
+		{
+			userFilterColumns,
+			`userName eq "alice"`,
+			"(`t1`.`name` IS NOT NULL) AND (`t1`.`name` = ( ? ))",
+			"(`t1`.`name` IS NOT NULL) AND (`t1`.`name` = ( ? ))",
+		},
+		{
+			userFilterColumns,
+			`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "al" and not (emails co "example")`,
+			"(`t1`.`name` IS NOT NULL) AND (`t1`.`name` LIKE ( ? )) AND (NOT ((`t1`.`email` IS NOT NULL) AND (`t1`.`email` LIKE ( ? ))))",
+			"(`t1`.`name` IS NOT NULL) AND (`t1`.`name` LIKE ( ? )) AND (NOT ((`t1`.`email` IS NOT NULL) AND (`t1`.`email` LIKE ( ? ))))",
+		},
+		{
+			userFilterColumns,
+			`displayName ne "alice" or phoneNumbers pr`,
+			"(`t1`.`displayname` <> ( ? )) OR (`t1`.`displayname` IS NULL) OR (`t1`.`mobile` IS NOT NULL AND LENGTH(`t1`.`mobile`) > 0)",
+			"(`t1`.`displayname` <> ( ? )) OR (`t1`.`displayname` IS NULL) OR (`t1`.`mobile` IS NOT NULL AND LENGTH(`t1`.`mobile`) > 0)",
+		},
+		{
+			userFilterColumns,
+			`userName eq "alice" and active eq true`,
+			"",
+			"(`t1`.`name` IS NOT NULL) AND (`t1`.`name` = ( ? ))",
+		},
+		{
+			userFilterColumns,
+			`userName eq "alice" or active eq true`,
+			"",
+			"",
+		},
+		{
+			userFilterColumns,
+			`emails[type eq "work"]`,
+			"",
+			"",
+		},
+		{
+			userFilterColumns,
+			`meta.created gt "2020-01-01T00:00:00Z"`,
+			"",
+			"",
+		},
+		{
+			groupFilterColumns,
+			`displayName eq "admins"`,
+			"((`t1`.`displayname` IS NOT NULL AND LENGTH(`t1`.`displayname`) > 0) AND (`t1`.`displayname` IS NOT NULL) AND (`t1`.`displayname` = ( ? ))) OR ((`t1`.`displayname` IS NULL OR LENGTH(`t1`.`displayname`) = 0) AND (`t1`.`name` IS NOT NULL) AND (`t1`.`name` = ( ? )))",
+			"((`t1`.`displayname` IS NOT NULL AND LENGTH(`t1`.`displayname`) > 0) AND (`t1`.`displayname` IS NOT NULL) AND (`t1`.`displayname` = ( ? ))) OR ((`t1`.`displayname` IS NULL OR LENGTH(`t1`.`displayname`) = 0) AND (`t1`.`name` IS NOT NULL) AND (`t1`.`name` = ( ? )))",
+		},
+	}
+	for _, c := range cases {
+		filter, err := ParseFilter(c.Filter)
+		if err != nil {
+			t.Fatalf("ParseFilter %s fail %s", c.Filter, err)
+		}
+		where := ""
+		if cond := c.Columns.sqlCondition(q, filter); cond != nil {
+			where = cond.WhereClause()
+		}
+		if where != c.Where {
+			t.Errorf("sqlCondition %s got %q want %q", c.Filter, where, c.Where)
+		}
+		narrow := ""
+		if cond := c.Columns.narrowCondition(q, filter); cond != nil {
+			narrow = cond.WhereClause()
+		}
+		if narrow != c.Narrow {
+			t.Errorf("narrowCondition %s got %q want %q", c.Filter, narrow, c.Narrow)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"testing"
+
+	"yunion.io/x/jsonutils"
+)
+
+func TestParseFilter(t *testing.T) {
+	user, _ := jsonutils.ParseString(`{
+		"userName": "Alice",
+		"externalId": "00u1",
+		"active": true,
+		"name": {"givenName": "Alice", "familyName": "Smith"},
+		"emails": [{"value": "alice@example.com", "type": "work", "primary": true}, {"value": "alice@home.com", "type": "home"}],
+		"meta": {"created": "2020-01-01T00:00:00Z"}
+	}`)
+	cases := []struct {
+		Filter string
+		Want   bool
+	}{
+		{`userName eq "alice"`, true},
+		{`UserName Eq "ALICE"`, true},
+		{`userName ne "alice"`, false},
+		{`userName sw "al" and active eq true`, true},
+		{`userName ew "ce" and active eq false`, false},
+		{`externalId eq "00u2" or name.familyName co "mit"`, true},
+		{`not (userName eq "bob")`, true},
+		{`emails.value eq "alice@home.com"`, true},
+		{`emails eq "alice@example.com"`, true},
+		{`emails[type eq "work" and value ew "example.com"]`, true},
+		{`emails[type eq "other"]`, false},
+		{`title pr`, false},
+		{`name.givenName pr`, true},
+		{`meta.created gt "2019-12-31T00:00:00Z"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "alice"`, true},
+		{`(userName eq "bob" or userName eq "alice") and not (active eq false)`, true},
+	}
+	for _, c := range cases {
+		f, err := ParseFilter(c.Filter)
+		if err != nil {
+			t.Errorf("ParseFilter %s: %s", c.Filter, err)
+			continue
+		}
+		if got := f.Match(user); got != c.Want {
+			t.Errorf("filter %s got %v want %v", c.Filter, got, c.Want)
+		}
+	}
+
+	for _, filter := range []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName like "a"`,
+		`userName eq "a`,
+		`(userName eq "a"`,
+		`userName eq "a" and`,
+		`emails[type eq "work"`,
+	} {
+		if _, err := ParseFilter(filter); err == nil {
+			t.Errorf("ParseFilter %q should fail", filter)
+		}
+	}
+}
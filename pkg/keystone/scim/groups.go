@@ -0,0 +1,328 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/keystone/models"
+)
+
+func (req *sScimRequest) groupToScim(group *models.SGroup) (SGroup, error) {
+	ret := SGroup{
+		Schemas:     []string{SCHEMA_GROUP},
+		Id:          group.Id,
+		ExternalId:  req.getExternalId(group.Id, api.IdMappingEntityGroup),
+		DisplayName: group.Displayname,
+		Meta: &SMeta{
+			ResourceType: RESOURCE_TYPE_GROUP,
+			Created:      group.CreatedAt,
+			LastModified: group.UpdatedAt,
+			Location:     req.baseUrl + "/Groups/" + group.Id,
+		},
+	}
+	if len(ret.DisplayName) == 0 {
+		ret.DisplayName = group.Name
+	}
+	userIds := models.UsergroupManager.FetchGroupUserIds(group.Id)
+	if len(userIds) == 0 {
+		return ret, nil
+	}
+	users := make([]models.SUser, 0)
+	q := models.UserManager.Query().In("id", userIds).Asc("name")
+	err := db.FetchModelObjects(models.UserManager, q, &users)
+	if err != nil {
+		return ret, errors.Wrap(err, "FetchModelObjects")
+	}
+	for i := range users {
+		ret.Members = append(ret.Members, SMultiValuedAttribute{
+			Value:   users[i].Id,
+			Display: users[i].Name,
+			Ref:     req.baseUrl + "/Users/" + users[i].Id,
+		})
+	}
+	return ret, nil
+}
+
+func (req *sScimRequest) marshalGroup(group *models.SGroup) (jsonutils.JSONObject, error) {
+	ret, err := req.groupToScim(group)
+	if err != nil {
+		return nil, err
+	}
+	return jsonutils.Marshal(ret), nil
+}
+
+func (req *sScimRequest) fetchGroup(ctx context.Context) (*models.SGroup, error) {
+	groupId := req.params["<id>"]
+	obj, err := models.GroupManager.FetchById(groupId)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, newNotFoundError("Group %s not found", groupId)
+		}
+		return nil, errors.Wrap(err, "GroupManager.FetchById")
+	}
+	group := obj.(*models.SGroup)
+	if !group.LinkedWithIdp(req.idp.Id) {
+		return nil, newNotFoundError("Group %s not found", groupId)
+	}
+	return group, nil
+}
+
+func (req *sScimRequest) parseGroup() (SGroup, error) {
+	input := SGroup{}
+	err := req.parseBody(&input)
+	if err != nil {
+		return input, err
+	}
+	if len(input.DisplayName) == 0 {
+		return input, newBadRequestError(SCIM_TYPE_INVALID_VALUE, "displayName is required")
+	}
+	return input, nil
+}
+
+// memberIds resolves the members of a SCIM group, members must be users provisioned by the same identity provider
+func (req *sScimRequest) memberIds(members []SMultiValuedAttribute) ([]string, error) {
+	userIds := make([]string, 0, len(members))
+	visited := make(map[string]bool)
+	for i := range members {
+		userId := members[i].Value
+		if visited[userId] {
+			continue
+		}
+		visited[userId] = true
+		obj, err := models.UserManager.FetchById(userId)
+		if err != nil {
+			if errors.Cause(err) == sql.ErrNoRows {
+				return nil, newBadRequestError(SCIM_TYPE_INVALID_VALUE, "member %s not found", userId)
+			}
+			return nil, errors.Wrap(err, "UserManager.FetchById")
+		}
+		if !obj.(*models.SUser).LinkedWithIdp(req.idp.Id) {
+			return nil, newBadRequestError(SCIM_TYPE_INVALID_VALUE, "member %s is not provisioned by %s", userId, req.idp.Name)
+		}
+		userIds = append(userIds, userId)
+	}
+	return userIds, nil
+}
+
+func checkGroupName(domainId string, name string, groupId string) error {
+	q := models.GroupManager.Query().Equals("domain_id", domainId).Equals("name", name)
+	if len(groupId) > 0 {
+		q = q.NotEquals("id", groupId)
+	}
+	cnt, err := q.CountWithError()
+	if err != nil {
+		return errors.Wrap(err, "CountWithError")
+	}
+	if cnt > 0 {
+		return newConflictError("displayName %s already exists", name)
+	}
+	return nil
+}
+
+// updateGroup applies a SCIM group to the keystone group, removed members have their tokens revoked
+func (req *sScimRequest) updateGroup(ctx context.Context, group *models.SGroup, input SGroup) error {
+	userIds, err := req.memberIds(input.Members)
+	if err != nil {
+		return err
+	}
+	if input.DisplayName != group.Name {
+		err := checkGroupName(group.DomainId, input.DisplayName, group.Id)
+		if err != nil {
+			return err
+		}
+		diff, err := db.Update(group, func() error {
+			group.Name = input.DisplayName
+			group.Displayname = input.DisplayName
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "Update")
+		}
+		db.OpsLog.LogEvent(group, db.ACT_UPDATE, diff, models.GetDefaultAdminCred())
+	}
+	models.UsergroupManager.SyncGroupUsers(ctx, models.GetDefaultAdminCred(), group.Id, userIds)
+	return nil
+}
+
+func listGroups(ctx context.Context, req *sScimRequest) (int, jsonutils.JSONObject, error) {
+	query, err := req.parseListQuery()
+	if err != nil {
+		return 0, nil, err
+	}
+	idpGroups := models.IdmappingManager.FetchPublicIdsExcludesQuery(req.idp.Id, api.IdMappingEntityGroup, nil)
+	q := models.GroupManager.Query().In("id", idpGroups.SubQuery())
+	q = q.Asc("created_at").Asc("id")
+	ret, err := query.list(q, groupFilterColumns, req.fetchGroups)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, ret, nil
+}
+
+func (req *sScimRequest) fetchGroups(q *sqlchemy.SQuery) ([]jsonutils.JSONObject, error) {
+	groups := make([]models.SGroup, 0)
+	err := db.FetchModelObjects(models.GroupManager, q, &groups)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchModelObjects")
+	}
+	resources := make([]jsonutils.JSONObject, len(groups))
+	for i := range groups {
+		resources[i], err = req.marshalGroup(&groups[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
+}
+
+func createGroup(ctx context.Context, req *sScimRequest) (int, jsonutils.JSONObject, error) {
+	input, err := req.parseGroup()
+	if err != nil {
+		return 0, nil, err
+	}
+	extId := input.ExternalId
+	if len(extId) == 0 {
+		extId = input.DisplayName
+	}
+	groupId, _ := models.IdmappingManager.FetchByIdpAndEntityId(ctx, req.idp.Id, extId, api.IdMappingEntityGroup)
+	if len(groupId) > 0 {
+		if _, err := models.GroupManager.FetchById(groupId); err == nil {
+			return 0, nil, newConflictError("Group %s already exists", extId)
+		}
+	}
+	userIds, err := req.memberIds(input.Members)
+	if err != nil {
+		return 0, nil, err
+	}
+	domainId, err := req.getDomainId(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	err = checkGroupName(domainId, input.DisplayName, groupId)
+	if err != nil {
+		return 0, nil, err
+	}
+	group, err := models.GroupManager.RegisterExternalGroup(ctx, req.idp.Id, domainId, extId, input.DisplayName)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "RegisterExternalGroup")
+	}
+	models.UsergroupManager.SyncGroupUsers(ctx, models.GetDefaultAdminCred(), group.Id, userIds)
+	ret, err := req.marshalGroup(group)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, ret, nil
+}
+
+func getGroup(ctx context.Context, req *sScimRequest) (int, jsonutils.JSONObject, error) {
+	group, err := req.fetchGroup(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	ret, err := req.marshalGroup(group)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, ret, nil
+}
+
+func replaceGroup(ctx context.Context, req *sScimRequest) (int, jsonutils.JSONObject, error) {
+	group, err := req.fetchGroup(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	input, err := req.parseGroup()
+	if err != nil {
+		return 0, nil, err
+	}
+	err = req.updateGroup(ctx, group, input)
+	if err != nil {
+		return 0, nil, err
+	}
+	ret, err := req.marshalGroup(group)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, ret, nil
+}
+
+func patchGroup(ctx context.Context, req *sScimRequest) (int, jsonutils.JSONObject, error) {
+	group, err := req.fetchGroup(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	patch := SPatchRequest{}
+	err = req.parseBody(&patch)
+	if err != nil {
+		return 0, nil, err
+	}
+	current, err := req.marshalGroup(group)
+	if err != nil {
+		return 0, nil, err
+	}
+	err = ApplyPatchOperations(current.(*jsonutils.JSONDict), patch.Operations)
+	if err != nil {
+		return 0, nil, err
+	}
+	input := SGroup{}
+	err = canonicalize(current).Unmarshal(&input)
+	if err != nil {
+		return 0, nil, newBadRequestError(SCIM_TYPE_INVALID_VALUE, "invalid patch result: %s", err)
+	}
+	if len(input.DisplayName) == 0 {
+		return 0, nil, newBadRequestError(SCIM_TYPE_INVALID_VALUE, "displayName is required")
+	}
+	err = req.updateGroup(ctx, group, input)
+	if err != nil {
+		return 0, nil, err
+	}
+	ret, err := req.marshalGroup(group)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, ret, nil
+}
+
+// deleteGroup removes the group together with its memberships and project assignments
+func deleteGroup(ctx context.Context, req *sScimRequest) (int, jsonutils.JSONObject, error) {
+	group, err := req.fetchGroup(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	// the group is readonly while linked with the idp, so skip the readonly
+	// check and validate the rest before unlinking
+	err = group.SIdentityBaseResource.ValidateDeleteCondition(ctx)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "ValidateDeleteCondition")
+	}
+	err = group.UnlinkIdp(req.idp.Id)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "UnlinkIdp")
+	}
+	err = group.Delete(ctx, models.GetDefaultAdminCred())
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "Delete")
+	}
+	return http.StatusNoContent, nil, nil
+}
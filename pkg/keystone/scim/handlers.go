@@ -0,0 +1,287 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/keystone/models"
+)
+
+const (
+	SCIM_PREFIX = "/scim/v2"
+)
+
+// AddHandler registers the SCIM 2.0 endpoints, every identity provider issued
+// a scim token is provisioned through its own bearer token
+func AddHandler(app *appsrv.Application) {
+	app.AddHandler2("GET", SCIM_PREFIX+"/ServiceProviderConfig", scimHandler(getServiceProviderConfig), nil, "scim_service_provider_config", nil)
+
+	app.AddHandler2("GET", SCIM_PREFIX+"/Users", scimHandler(listUsers), nil, "scim_list_users", nil)
+	app.AddHandler2("POST", SCIM_PREFIX+"/Users", scimHandler(createUser), nil, "scim_create_user", nil)
+	app.AddHandler2("GET", SCIM_PREFIX+"/Users/<id>", scimHandler(getUser), nil, "scim_get_user", nil)
+	app.AddHandler2("PUT", SCIM_PREFIX+"/Users/<id>", scimHandler(replaceUser), nil, "scim_replace_user", nil)
+	app.AddHandler2("PATCH", SCIM_PREFIX+"/Users/<id>", scimHandler(patchUser), nil, "scim_patch_user", nil)
+	app.AddHandler2("DELETE", SCIM_PREFIX+"/Users/<id>", scimHandler(deleteUser), nil, "scim_delete_user", nil)
+
+	app.AddHandler2("GET", SCIM_PREFIX+"/Groups", scimHandler(listGroups), nil, "scim_list_groups", nil)
+	app.AddHandler2("POST", SCIM_PREFIX+"/Groups", scimHandler(createGroup), nil, "scim_create_group", nil)
+	app.AddHandler2("GET", SCIM_PREFIX+"/Groups/<id>", scimHandler(getGroup), nil, "scim_get_group", nil)
+	app.AddHandler2("PUT", SCIM_PREFIX+"/Groups/<id>", scimHandler(replaceGroup), nil, "scim_replace_group", nil)
+	app.AddHandler2("PATCH", SCIM_PREFIX+"/Groups/<id>", scimHandler(patchGroup), nil, "scim_patch_group", nil)
+	app.AddHandler2("DELETE", SCIM_PREFIX+"/Groups/<id>", scimHandler(deleteGroup), nil, "scim_delete_group", nil)
+}
+
+type sScimRequest struct {
+	idp     *models.SIdentityProvider
+	params  map[string]string
+	query   jsonutils.JSONObject
+	body    jsonutils.JSONObject
+	baseUrl string
+}
+
+type scimHandlerFunc func(ctx context.Context, req *sScimRequest) (int, jsonutils.JSONObject, error)
+
+func scimHandler(handler scimHandlerFunc) func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+			sendError(w, newScimError(http.StatusUnauthorized, "", "missing bearer token"))
+			return
+		}
+		idp, err := models.IdentityProviderManager.FetchIdentityProviderByScimToken(strings.TrimSpace(auth[7:]))
+		if err != nil {
+			log.Errorf("scim authenticate fail %s", err)
+			sendError(w, newScimError(http.StatusUnauthorized, "", "invalid bearer token"))
+			return
+		}
+		params, query, body := appsrv.FetchEnv(ctx, w, r)
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		if proto := r.Header.Get("X-Forwarded-Proto"); len(proto) > 0 {
+			scheme = proto
+		}
+		req := &sScimRequest{
+			idp:     idp,
+			params:  params,
+			query:   query,
+			body:    body,
+			baseUrl: fmt.Sprintf("%s://%s%s", scheme, r.Host, SCIM_PREFIX),
+		}
+		status, result, err := handler(ctx, req)
+		if err != nil {
+			sendError(w, err)
+			return
+		}
+		send(w, status, result)
+	}
+}
+
+func send(w http.ResponseWriter, status int, obj jsonutils.JSONObject) {
+	if obj == nil {
+		appsrv.SendNoContent(w)
+		return
+	}
+	output := []byte(obj.String())
+	w.Header().Set("Content-Type", CONTENT_TYPE)
+	w.Header().Set("Content-Length", strconv.Itoa(len(output)))
+	w.WriteHeader(status)
+	w.Write(output)
+}
+
+func sendError(w http.ResponseWriter, err error) {
+	e := toScimError(err)
+	if e.status >= http.StatusInternalServerError {
+		log.Errorf("scim request fail %s", err)
+	}
+	send(w, e.status, jsonutils.Marshal(SError{
+		Schemas:  []string{SCHEMA_ERROR},
+		Status:   strconv.Itoa(e.status),
+		ScimType: e.scimType,
+		Detail:   e.detail,
+	}))
+}
+
+func getServiceProviderConfig(ctx context.Context, req *sScimRequest) (int, jsonutils.JSONObject, error) {
+	conf := SServiceProviderConfig{
+		Schemas: []string{SCHEMA_SERVICE_PROVIDER_CONFIG},
+		Patch:   SSupported{Supported: true},
+		Filter: SFilterSupported{
+			Supported:  true,
+			MaxResults: MAX_PAGE_SIZE,
+		},
+		AuthenticationSchemes: []SAuthenticationScheme{
+			{
+				Type:        "oauthbearertoken",
+				Name:        "OAuth Bearer Token",
+				Description: "Bearer token issued by the scim-token action of the identity provider",
+				Primary:     true,
+			},
+		},
+	}
+	return http.StatusOK, jsonutils.Marshal(conf), nil
+}
+
+func (req *sScimRequest) parseBody(obj interface{}) error {
+	if req.body == nil {
+		return newBadRequestError(SCIM_TYPE_INVALID_SYNTAX, "empty request body")
+	}
+	err := canonicalize(req.body).Unmarshal(obj)
+	if err != nil {
+		return newBadRequestError(SCIM_TYPE_INVALID_SYNTAX, "invalid request body: %s", err)
+	}
+	return nil
+}
+
+type sListQuery struct {
+	filter     IFilter
+	startIndex int
+	count      int
+}
+
+func (req *sScimRequest) parseListQuery() (*sListQuery, error) {
+	ret := &sListQuery{
+		startIndex: 1,
+		count:      DEFAULT_PAGE_SIZE,
+	}
+	if req.query == nil {
+		return ret, nil
+	}
+	if filter, _ := req.query.GetString("filter"); len(filter) > 0 {
+		f, err := ParseFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		ret.filter = f
+	}
+	if idx, err := req.query.Int("startIndex"); err == nil && idx > 1 {
+		ret.startIndex = int(idx)
+	}
+	if cnt, err := req.query.Int("count"); err == nil {
+		ret.count = int(cnt)
+		if ret.count < 0 {
+			ret.count = 0
+		} else if ret.count > MAX_PAGE_SIZE {
+			ret.count = MAX_PAGE_SIZE
+		}
+	}
+	return ret, nil
+}
+
+// list returns a page of the resources of the query matching the filter, the filter
+// is evaluated by the database if possible, otherwise the resources narrowed by the
+// database are matched in memory and at most MAX_FILTER_CANDIDATES of them are allowed
+func (query *sListQuery) list(q *sqlchemy.SQuery, columns sFilterColumns, fetch func(q *sqlchemy.SQuery) ([]jsonutils.JSONObject, error)) (jsonutils.JSONObject, error) {
+	var cond sqlchemy.ICondition
+	if query.filter != nil {
+		cond = columns.sqlCondition(q, query.filter)
+	}
+	if query.filter == nil || cond != nil {
+		if cond != nil {
+			q = q.Filter(cond)
+		}
+		total, err := q.CountWithError()
+		if err != nil {
+			return nil, errors.Wrap(err, "CountWithError")
+		}
+		if query.count == 0 {
+			return query.listResponse(total, []jsonutils.JSONObject{}), nil
+		}
+		q = q.Offset(query.startIndex - 1).Limit(query.count)
+		resources, err := fetch(q)
+		if err != nil {
+			return nil, err
+		}
+		return query.listResponse(total, resources), nil
+	}
+	if cond := columns.narrowCondition(q, query.filter); cond != nil {
+		q = q.Filter(cond)
+	}
+	resources, err := fetch(q.Limit(MAX_FILTER_CANDIDATES + 1))
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) > MAX_FILTER_CANDIDATES {
+		return nil, newBadRequestError(SCIM_TYPE_TOO_MANY, "filter matches more than %d resources, narrow it with eq, ne, co, sw, ew or pr on indexed attributes", MAX_FILTER_CANDIDATES)
+	}
+	return query.page(resources), nil
+}
+
+// page filters and pages the resources in memory
+func (q *sListQuery) page(resources []jsonutils.JSONObject) jsonutils.JSONObject {
+	if q.filter != nil {
+		matched := make([]jsonutils.JSONObject, 0, len(resources))
+		for i := range resources {
+			if q.filter.Match(resources[i]) {
+				matched = append(matched, resources[i])
+			}
+		}
+		resources = matched
+	}
+	total := len(resources)
+	start := q.startIndex - 1
+	if start > total {
+		start = total
+	}
+	end := start + q.count
+	if end > total {
+		end = total
+	}
+	return q.listResponse(total, resources[start:end])
+}
+
+func (q *sListQuery) listResponse(total int, resources []jsonutils.JSONObject) jsonutils.JSONObject {
+	return jsonutils.Marshal(SListResponse{
+		Schemas:      []string{SCHEMA_LIST_RESPONSE},
+		TotalResults: total,
+		ItemsPerPage: len(resources),
+		StartIndex:   q.startIndex,
+		Resources:    resources,
+	})
+}
+
+func (req *sScimRequest) getDomainId(ctx context.Context) (string, error) {
+	domain, err := req.idp.GetSingleDomain(ctx, api.DefaultRemoteDomainId, req.idp.Name, fmt.Sprintf("%s provider %s", req.idp.Driver, req.idp.Name), false)
+	if err != nil {
+		return "", errors.Wrap(err, "GetSingleDomain")
+	}
+	return domain.Id, nil
+}
+
+func (req *sScimRequest) getExternalId(publicId string, entityType string) string {
+	mappings, err := models.IdmappingManager.FetchEntities(publicId, entityType)
+	if err != nil {
+		return ""
+	}
+	for i := range mappings {
+		if mappings[i].IdpId == req.idp.Id {
+			return mappings[i].IdpEntityId
+		}
+	}
+	return ""
+}
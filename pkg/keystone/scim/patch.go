@@ -0,0 +1,314 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"strings"
+
+	"yunion.io/x/jsonutils"
+)
+
+const (
+	PATCH_OP_ADD     = "add"
+	PATCH_OP_REPLACE = "replace"
+	PATCH_OP_REMOVE  = "remove"
+)
+
+// sPatchPath is a parsed PATCH path: attr[filter].subAttr
+type sPatchPath struct {
+	attr    string
+	filter  IFilter
+	subAttr string
+}
+
+func parsePatchPath(path string) (*sPatchPath, error) {
+	path = strings.TrimSpace(path)
+	ret := &sPatchPath{}
+	bracket := strings.IndexByte(path, '[')
+	if bracket < 0 {
+		parts := strings.SplitN(stripSchemaUrn(path), ".", 2)
+		ret.attr = parts[0]
+		if len(parts) > 1 {
+			ret.subAttr = parts[1]
+		}
+	} else {
+		end := strings.LastIndexByte(path, ']')
+		if end < bracket {
+			return nil, newBadRequestError(SCIM_TYPE_INVALID_PATH, "invalid path %q", path)
+		}
+		ret.attr = stripSchemaUrn(path[:bracket])
+		filter, err := ParseFilter(path[bracket+1 : end])
+		if err != nil {
+			return nil, err
+		}
+		ret.filter = filter
+		if rest := path[end+1:]; len(rest) > 0 {
+			if rest[0] != '.' || len(rest) == 1 {
+				return nil, newBadRequestError(SCIM_TYPE_INVALID_PATH, "invalid path %q", path)
+			}
+			ret.subAttr = rest[1:]
+		}
+	}
+	if len(ret.attr) == 0 || strings.ContainsAny(ret.subAttr, ".[]") {
+		return nil, newBadRequestError(SCIM_TYPE_INVALID_PATH, "invalid path %q", path)
+	}
+	return ret, nil
+}
+
+// ApplyPatchOperations applies the operations of a PATCH request to the SCIM representation of a resource
+func ApplyPatchOperations(obj *jsonutils.JSONDict, ops []SPatchOperation) error {
+	for i := range ops {
+		err := applyPatchOperation(obj, ops[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyPatchOperation(obj *jsonutils.JSONDict, op SPatchOperation) error {
+	opName := strings.ToLower(op.Op)
+	switch opName {
+	case PATCH_OP_ADD, PATCH_OP_REPLACE, PATCH_OP_REMOVE:
+	default:
+		return newBadRequestError(SCIM_TYPE_INVALID_SYNTAX, "unsupported patch op %q", op.Op)
+	}
+	if opName != PATCH_OP_REMOVE && op.Value == nil {
+		return newBadRequestError(SCIM_TYPE_INVALID_VALUE, "missing value of %s %s", op.Op, op.Path)
+	}
+	if len(op.Path) == 0 {
+		if opName == PATCH_OP_REMOVE {
+			return newBadRequestError(SCIM_TYPE_NO_TARGET, "path is required by remove")
+		}
+		values, ok := op.Value.(*jsonutils.JSONDict)
+		if !ok {
+			return newBadRequestError(SCIM_TYPE_INVALID_VALUE, "value of %s without path must be an object", op.Op)
+		}
+		m, _ := values.GetMap()
+		for k, v := range m {
+			err := applyPatchOperation(obj, SPatchOperation{Op: opName, Path: k, Value: v})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	path, err := parsePatchPath(op.Path)
+	if err != nil {
+		return err
+	}
+	if path.filter != nil {
+		return patchFilteredAttr(obj, opName, path, op.Value)
+	}
+	if len(path.subAttr) == 0 {
+		return patchAttr(obj, opName, path.attr, op.Value)
+	}
+	child, _ := getAttr(obj, path.attr)
+	switch v := child.(type) {
+	case *jsonutils.JSONDict:
+		return patchAttr(v, opName, path.subAttr, op.Value)
+	case *jsonutils.JSONArray:
+		elems, _ := v.GetArray()
+		if len(elems) == 0 && opName != PATCH_OP_REMOVE {
+			elem := jsonutils.NewDict()
+			elem.Set(path.subAttr, op.Value)
+			obj.Set(findKey(obj, path.attr), jsonutils.NewArray(elem))
+			return nil
+		}
+		for i := range elems {
+			if elem, ok := elems[i].(*jsonutils.JSONDict); ok {
+				err := patchAttr(elem, opName, path.subAttr, op.Value)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	default:
+		if opName == PATCH_OP_REMOVE {
+			return nil
+		}
+		elem := jsonutils.NewDict()
+		elem.Set(path.subAttr, op.Value)
+		obj.Set(findKey(obj, path.attr), elem)
+		return nil
+	}
+}
+
+// patchFilteredAttr applies an operation to the elements of a multi-valued attribute selected by a value filter,
+// add or replace on a filter selecting nothing creates the element described by the filter
+func patchFilteredAttr(obj *jsonutils.JSONDict, opName string, path *sPatchPath, value jsonutils.JSONObject) error {
+	elems := make([]jsonutils.JSONObject, 0)
+	if child, err := getAttr(obj, path.attr); err == nil {
+		if arr, ok := child.(*jsonutils.JSONArray); ok {
+			elems, _ = arr.GetArray()
+		}
+	}
+	matched := false
+	newElems := make([]jsonutils.JSONObject, 0, len(elems))
+	for i := range elems {
+		elem, ok := elems[i].(*jsonutils.JSONDict)
+		if !ok || !path.filter.Match(elem) {
+			newElems = append(newElems, elems[i])
+			continue
+		}
+		matched = true
+		if opName == PATCH_OP_REMOVE && len(path.subAttr) == 0 {
+			continue
+		}
+		err := patchElement(elem, opName, path.subAttr, value)
+		if err != nil {
+			return err
+		}
+		newElems = append(newElems, elem)
+	}
+	if !matched {
+		if opName == PATCH_OP_REMOVE {
+			return nil
+		}
+		elem := elementFromFilter(path.filter)
+		if elem == nil {
+			return newBadRequestError(SCIM_TYPE_NO_TARGET, "no %s matches the filter", path.attr)
+		}
+		err := patchElement(elem, opName, path.subAttr, value)
+		if err != nil {
+			return err
+		}
+		newElems = append(newElems, elem)
+	}
+	obj.Set(findKey(obj, path.attr), jsonutils.NewArray(newElems...))
+	return nil
+}
+
+func patchElement(elem *jsonutils.JSONDict, opName string, subAttr string, value jsonutils.JSONObject) error {
+	if len(subAttr) > 0 {
+		return patchAttr(elem, opName, subAttr, value)
+	}
+	values, ok := value.(*jsonutils.JSONDict)
+	if !ok {
+		return newBadRequestError(SCIM_TYPE_INVALID_VALUE, "value must be an object")
+	}
+	mergeDict(elem, values)
+	return nil
+}
+
+// elementFromFilter builds the element a filter like type eq "work" selects
+func elementFromFilter(filter IFilter) *jsonutils.JSONDict {
+	switch f := filter.(type) {
+	case *sCompareFilter:
+		if f.op != FILTER_OP_EQ || strings.Contains(f.attr, ".") {
+			return nil
+		}
+		elem := jsonutils.NewDict()
+		elem.Set(f.attr, f.value)
+		return elem
+	case *sAndFilter:
+		left := elementFromFilter(f.left)
+		right := elementFromFilter(f.right)
+		if left == nil || right == nil {
+			return nil
+		}
+		mergeDict(left, right)
+		return left
+	}
+	return nil
+}
+
+func patchAttr(obj *jsonutils.JSONDict, opName string, attr string, value jsonutils.JSONObject) error {
+	key := findKey(obj, attr)
+	existing, _ := obj.Get(key)
+	switch opName {
+	case PATCH_OP_REMOVE:
+		arr, ok := existing.(*jsonutils.JSONArray)
+		if value == nil || !ok {
+			obj.Remove(key)
+			return nil
+		}
+		// remove the listed elements, e.g. members removed by value
+		removes := make(map[string]bool)
+		for _, v := range toElements(value) {
+			removes[multiValueKey(v)] = true
+		}
+		elems, _ := arr.GetArray()
+		newElems := make([]jsonutils.JSONObject, 0, len(elems))
+		for i := range elems {
+			if !removes[multiValueKey(elems[i])] {
+				newElems = append(newElems, elems[i])
+			}
+		}
+		obj.Set(key, jsonutils.NewArray(newElems...))
+	case PATCH_OP_ADD:
+		if arr, ok := existing.(*jsonutils.JSONArray); ok {
+			elems, _ := arr.GetArray()
+			exists := make(map[string]bool)
+			for i := range elems {
+				exists[multiValueKey(elems[i])] = true
+			}
+			for _, v := range toElements(value) {
+				if !exists[multiValueKey(v)] {
+					elems = append(elems, v)
+					exists[multiValueKey(v)] = true
+				}
+			}
+			obj.Set(key, jsonutils.NewArray(elems...))
+			return nil
+		}
+		fallthrough
+	case PATCH_OP_REPLACE:
+		dict, ok1 := existing.(*jsonutils.JSONDict)
+		values, ok2 := value.(*jsonutils.JSONDict)
+		if ok1 && ok2 {
+			mergeDict(dict, values)
+		} else {
+			obj.Set(key, value)
+		}
+	}
+	return nil
+}
+
+func findKey(obj *jsonutils.JSONDict, attr string) string {
+	m, _ := obj.GetMap()
+	for k := range m {
+		if strings.EqualFold(k, attr) {
+			return k
+		}
+	}
+	return attr
+}
+
+func mergeDict(dict *jsonutils.JSONDict, values *jsonutils.JSONDict) {
+	m, _ := values.GetMap()
+	for k, v := range m {
+		dict.Set(findKey(dict, k), v)
+	}
+}
+
+func toElements(value jsonutils.JSONObject) []jsonutils.JSONObject {
+	if arr, ok := value.(*jsonutils.JSONArray); ok {
+		elems, _ := arr.GetArray()
+		return elems
+	}
+	return []jsonutils.JSONObject{value}
+}
+
+// multiValueKey identifies an element of a multi-valued attribute by its value
+func multiValueKey(elem jsonutils.JSONObject) string {
+	if dict, ok := elem.(*jsonutils.JSONDict); ok {
+		if v, err := getAttr(dict, "value"); err == nil {
+			return normalizeValue(v)
+		}
+	}
+	return normalizeValue(elem)
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"testing"
+
+	"yunion.io/x/jsonutils"
+)
+
+func TestApplyPatchOperations(t *testing.T) {
+	cases := []struct {
+		Name string
+		In   string
+		Ops  string
+		Want string
+	}{
+		{
+			Name: "replace active",
+			In:   `{"userName":"alice","active":true}`,
+			Ops:  `[{"op":"Replace","path":"active","value":"False"}]`,
+			Want: `{"active":"False","userName":"alice"}`,
+		},
+		{
+			Name: "replace without path",
+			In:   `{"userName":"alice","name":{"givenName":"Alice"}}`,
+			Ops:  `[{"op":"replace","value":{"userName":"alice2","name.familyName":"Smith"}}]`,
+			Want: `{"name":{"familyName":"Smith","givenName":"Alice"},"userName":"alice2"}`,
+		},
+		{
+			Name: "replace filtered sub attribute",
+			In:   `{"emails":[{"type":"work","value":"a@example.com"},{"type":"home","value":"a@home.com"}]}`,
+			Ops:  `[{"op":"replace","path":"emails[type eq \"work\"].value","value":"b@example.com"}]`,
+			Want: `{"emails":[{"type":"work","value":"b@example.com"},{"type":"home","value":"a@home.com"}]}`,
+		},
+		{
+			Name: "add filtered sub attribute creates element",
+			In:   `{"userName":"alice"}`,
+			Ops:  `[{"op":"add","path":"emails[type eq \"work\"].value","value":"a@example.com"}]`,
+			Want: `{"emails":[{"type":"work","value":"a@example.com"}],"userName":"alice"}`,
+		},
+		{
+			Name: "add members",
+			In:   `{"displayName":"dev","members":[{"value":"u1"}]}`,
+			Ops:  `[{"op":"add","path":"members","value":[{"value":"u1"},{"value":"u2"}]}]`,
+			Want: `{"displayName":"dev","members":[{"value":"u1"},{"value":"u2"}]}`,
+		},
+		{
+			Name: "remove member by filter",
+			In:   `{"displayName":"dev","members":[{"value":"u1"},{"value":"u2"}]}`,
+			Ops:  `[{"op":"remove","path":"members[value eq \"u1\"]"}]`,
+			Want: `{"displayName":"dev","members":[{"value":"u2"}]}`,
+		},
+		{
+			Name: "remove member by value",
+			In:   `{"displayName":"dev","members":[{"value":"u1"},{"value":"u2"}]}`,
+			Ops:  `[{"op":"remove","path":"members","value":[{"value":"u2"}]}]`,
+			Want: `{"displayName":"dev","members":[{"value":"u1"}]}`,
+		},
+		{
+			Name: "remove all members",
+			In:   `{"displayName":"dev","members":[{"value":"u1"}]}`,
+			Ops:  `[{"op":"remove","path":"members"}]`,
+			Want: `{"displayName":"dev"}`,
+		},
+	}
+	for _, c := range cases {
+		in, _ := jsonutils.ParseString(c.In)
+		opsJson, _ := jsonutils.ParseString(c.Ops)
+		ops := make([]SPatchOperation, 0)
+		opsJson.Unmarshal(&ops)
+		err := ApplyPatchOperations(in.(*jsonutils.JSONDict), ops)
+		if err != nil {
+			t.Errorf("%s: %s", c.Name, err)
+			continue
+		}
+		if got := in.String(); got != c.Want {
+			t.Errorf("%s got %s want %s", c.Name, got, c.Want)
+		}
+	}
+
+	for _, ops := range []string{
+		`[{"op":"move","path":"userName","value":"a"}]`,
+		`[{"op":"remove"}]`,
+		`[{"op":"add","path":"userName"}]`,
+		`[{"op":"replace","path":"emails[type eq \"work\"","value":"a"}]`,
+	} {
+		in, _ := jsonutils.ParseString(`{"userName":"alice"}`)
+		opsJson, _ := jsonutils.ParseString(ops)
+		patch := make([]SPatchOperation, 0)
+		opsJson.Unmarshal(&patch)
+		if err := ApplyPatchOperations(in.(*jsonutils.JSONDict), patch); err == nil {
+			t.Errorf("patch %s should fail", ops)
+		}
+	}
+}
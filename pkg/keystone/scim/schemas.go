@@ -0,0 +1,211 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+)
+
+const (
+	SCHEMA_USER                    = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCHEMA_GROUP                   = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SCHEMA_SERVICE_PROVIDER_CONFIG = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SCHEMA_LIST_RESPONSE           = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCHEMA_PATCH_OP                = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCHEMA_ERROR                   = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	CONTENT_TYPE = "application/scim+json"
+
+	RESOURCE_TYPE_USER  = "User"
+	RESOURCE_TYPE_GROUP = "Group"
+
+	DEFAULT_PAGE_SIZE = 100
+	MAX_PAGE_SIZE     = 1000
+	// max number of resources matched by a filter in memory
+	MAX_FILTER_CANDIDATES = 1000
+)
+
+type SMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+}
+
+type SName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+// SMultiValuedAttribute is an element of emails, phoneNumbers, groups and members
+type SMultiValuedAttribute struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type SUser struct {
+	Schemas      []string                `json:"schemas"`
+	Id           string                  `json:"id,omitempty"`
+	ExternalId   string                  `json:"externalId,omitempty"`
+	UserName     string                  `json:"userName"`
+	Name         *SName                  `json:"name,omitempty"`
+	DisplayName  string                  `json:"displayName,omitempty"`
+	Active       *bool                   `json:"active,omitempty"`
+	Emails       []SMultiValuedAttribute `json:"emails,omitempty"`
+	PhoneNumbers []SMultiValuedAttribute `json:"phoneNumbers,omitempty"`
+	Groups       []SMultiValuedAttribute `json:"groups,omitempty"`
+	Meta         *SMeta                  `json:"meta,omitempty"`
+}
+
+// GetDisplayName prefers displayName, then the formatted name and at last given name plus family name
+func (user SUser) GetDisplayName() string {
+	if len(user.DisplayName) > 0 {
+		return user.DisplayName
+	}
+	if user.Name == nil {
+		return ""
+	}
+	if len(user.Name.Formatted) > 0 {
+		return user.Name.Formatted
+	}
+	return strings.TrimSpace(user.Name.GivenName + " " + user.Name.FamilyName)
+}
+
+func primaryValue(attrs []SMultiValuedAttribute) string {
+	for i := range attrs {
+		if attrs[i].Primary {
+			return attrs[i].Value
+		}
+	}
+	if len(attrs) > 0 {
+		return attrs[0].Value
+	}
+	return ""
+}
+
+type SGroup struct {
+	Schemas     []string                `json:"schemas"`
+	Id          string                  `json:"id,omitempty"`
+	ExternalId  string                  `json:"externalId,omitempty"`
+	DisplayName string                  `json:"displayName"`
+	Members     []SMultiValuedAttribute `json:"members,omitempty"`
+	Meta        *SMeta                  `json:"meta,omitempty"`
+}
+
+type SListResponse struct {
+	Schemas      []string               `json:"schemas"`
+	TotalResults int                    `json:"totalResults,allowempty"`
+	ItemsPerPage int                    `json:"itemsPerPage,allowempty"`
+	StartIndex   int                    `json:"startIndex"`
+	Resources    []jsonutils.JSONObject `json:"Resources,allowempty"`
+}
+
+type SPatchOperation struct {
+	Op    string               `json:"op"`
+	Path  string               `json:"path"`
+	Value jsonutils.JSONObject `json:"value"`
+}
+
+type SPatchRequest struct {
+	Schemas    []string          `json:"schemas"`
+	Operations []SPatchOperation `json:"Operations"`
+}
+
+type SError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+type SSupported struct {
+	Supported bool `json:"supported,allowempty"`
+}
+
+type SFilterSupported struct {
+	Supported  bool `json:"supported,allowempty"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type SBulkSupported struct {
+	Supported      bool `json:"supported,allowempty"`
+	MaxOperations  int  `json:"maxOperations,allowempty"`
+	MaxPayloadSize int  `json:"maxPayloadSize,allowempty"`
+}
+
+type SAuthenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary,omitempty"`
+}
+
+type SServiceProviderConfig struct {
+	Schemas               []string                `json:"schemas"`
+	Patch                 SSupported              `json:"patch"`
+	Bulk                  SBulkSupported          `json:"bulk"`
+	Filter                SFilterSupported        `json:"filter"`
+	ChangePassword        SSupported              `json:"changePassword"`
+	Sort                  SSupported              `json:"sort"`
+	Etag                  SSupported              `json:"etag"`
+	AuthenticationSchemes []SAuthenticationScheme `json:"authenticationSchemes"`
+}
+
+// canonicalAttributes are the attribute names used by the schemas above,
+// attribute names are case insensitive in SCIM
+var canonicalAttributes = map[string]string{}
+
+func init() {
+	for _, attr := range []string{
+		"schemas", "id", "externalId", "meta",
+		"userName", "name", "formatted", "familyName", "givenName",
+		"displayName", "active", "emails", "phoneNumbers", "groups",
+		"members", "value", "display", "type", "primary", "$ref",
+		"Operations", "op", "path",
+	} {
+		canonicalAttributes[strings.ToLower(attr)] = attr
+	}
+}
+
+// canonicalize rewrites the keys of a request body to the canonical case of the schema attributes
+func canonicalize(obj jsonutils.JSONObject) jsonutils.JSONObject {
+	switch v := obj.(type) {
+	case *jsonutils.JSONDict:
+		ret := jsonutils.NewDict()
+		m, _ := v.GetMap()
+		for k, val := range m {
+			if attr, ok := canonicalAttributes[strings.ToLower(k)]; ok {
+				k = attr
+			}
+			ret.Set(k, canonicalize(val))
+		}
+		return ret
+	case *jsonutils.JSONArray:
+		ret := jsonutils.NewArray()
+		arr, _ := v.GetArray()
+		for i := range arr {
+			ret.Add(canonicalize(arr[i]))
+		}
+		return ret
+	}
+	return obj
+}
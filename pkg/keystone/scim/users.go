@@ -0,0 +1,284 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/tristate"
+	"yunion.io/x/sqlchemy"
+
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/keystone/models"
+)
+
+func (req *sScimRequest) userToScim(user *models.SUser) SUser {
+	active := user.Enabled.IsTrue()
+	ret := SUser{
+		Schemas:     []string{SCHEMA_USER},
+		Id:          user.Id,
+		ExternalId:  req.getExternalId(user.Id, api.IdMappingEntityUser),
+		UserName:    user.Name,
+		DisplayName: user.Displayname,
+		Active:      &active,
+		Meta: &SMeta{
+			ResourceType: RESOURCE_TYPE_USER,
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     req.baseUrl + "/Users/" + user.Id,
+		},
+	}
+	if len(user.Displayname) > 0 {
+		ret.Name = &SName{Formatted: user.Displayname}
+	}
+	if len(user.Email) > 0 {
+		ret.Emails = []SMultiValuedAttribute{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	if len(user.Mobile) > 0 {
+		ret.PhoneNumbers = []SMultiValuedAttribute{{Value: user.Mobile, Type: "mobile"}}
+	}
+	for _, groupId := range models.UsergroupManager.FetchUserGroupIds(user.Id) {
+		obj, err := models.GroupManager.FetchById(groupId)
+		if err != nil {
+			continue
+		}
+		group := obj.(*models.SGroup)
+		if !group.LinkedWithIdp(req.idp.Id) {
+			continue
+		}
+		ret.Groups = append(ret.Groups, SMultiValuedAttribute{
+			Value:   group.Id,
+			Display: group.Name,
+			Ref:     req.baseUrl + "/Groups/" + group.Id,
+		})
+	}
+	return ret
+}
+
+func (req *sScimRequest) fetchUser(ctx context.Context) (*models.SUser, error) {
+	userId := req.params["<id>"]
+	obj, err := models.UserManager.FetchById(userId)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, newNotFoundError("User %s not found", userId)
+		}
+		return nil, errors.Wrap(err, "UserManager.FetchById")
+	}
+	user := obj.(*models.SUser)
+	if !user.LinkedWithIdp(req.idp.Id) {
+		return nil, newNotFoundError("User %s not found", userId)
+	}
+	return user, nil
+}
+
+func (req *sScimRequest) parseUser() (SUser, error) {
+	input := SUser{}
+	err := req.parseBody(&input)
+	if err != nil {
+		return input, err
+	}
+	if len(input.UserName) == 0 {
+		return input, newBadRequestError(SCIM_TYPE_INVALID_VALUE, "userName is required")
+	}
+	return input, nil
+}
+
+func checkUserName(domainId string, name string, userId string) error {
+	q := models.UserManager.Query().Equals("domain_id", domainId).Equals("name", name)
+	if len(userId) > 0 {
+		q = q.NotEquals("id", userId)
+	}
+	cnt, err := q.CountWithError()
+	if err != nil {
+		return errors.Wrap(err, "CountWithError")
+	}
+	if cnt > 0 {
+		return newConflictError("userName %s already exists", name)
+	}
+	return nil
+}
+
+func syncUserAttributes(user *models.SUser, input SUser) {
+	user.Displayname = input.GetDisplayName()
+	user.Email = primaryValue(input.Emails)
+	user.Mobile = primaryValue(input.PhoneNumbers)
+}
+
+// updateUser applies a SCIM user to the keystone user, a user deactivated by the identity provider
+// is disabled and all of its tokens are revoked
+func updateUser(ctx context.Context, user *models.SUser, input SUser) error {
+	if input.UserName != user.Name {
+		err := checkUserName(user.DomainId, input.UserName, user.Id)
+		if err != nil {
+			return err
+		}
+	}
+	enabled := user.Enabled.IsTrue()
+	diff, err := db.Update(user, func() error {
+		user.Name = input.UserName
+		syncUserAttributes(user, input)
+		if input.Active != nil {
+			user.Enabled = tristate.NewFromBool(*input.Active)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "Update")
+	}
+	db.OpsLog.LogEvent(user, db.ACT_UPDATE, diff, models.GetDefaultAdminCred())
+	if enabled && !user.Enabled.IsTrue() {
+		err = models.RevocationEventManager.RevokeUser(ctx, user.Id)
+		if err != nil {
+			return errors.Wrap(err, "RevokeUser")
+		}
+	}
+	return nil
+}
+
+func listUsers(ctx context.Context, req *sScimRequest) (int, jsonutils.JSONObject, error) {
+	query, err := req.parseListQuery()
+	if err != nil {
+		return 0, nil, err
+	}
+	idpUsers := models.IdmappingManager.FetchPublicIdsExcludesQuery(req.idp.Id, api.IdMappingEntityUser, nil)
+	q := models.UserManager.Query().In("id", idpUsers.SubQuery())
+	q = q.Asc("created_at").Asc("id")
+	ret, err := query.list(q, userFilterColumns, req.fetchUsers)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, ret, nil
+}
+
+func (req *sScimRequest) fetchUsers(q *sqlchemy.SQuery) ([]jsonutils.JSONObject, error) {
+	users := make([]models.SUser, 0)
+	err := db.FetchModelObjects(models.UserManager, q, &users)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchModelObjects")
+	}
+	resources := make([]jsonutils.JSONObject, len(users))
+	for i := range users {
+		resources[i] = jsonutils.Marshal(req.userToScim(&users[i]))
+	}
+	return resources, nil
+}
+
+func createUser(ctx context.Context, req *sScimRequest) (int, jsonutils.JSONObject, error) {
+	input, err := req.parseUser()
+	if err != nil {
+		return 0, nil, err
+	}
+	extId := input.ExternalId
+	if len(extId) == 0 {
+		extId = input.UserName
+	}
+	userId, _ := models.IdmappingManager.FetchByIdpAndEntityId(ctx, req.idp.Id, extId, api.IdMappingEntityUser)
+	if len(userId) > 0 {
+		if _, err := models.UserManager.FetchById(userId); err == nil {
+			return 0, nil, newConflictError("User %s already exists", extId)
+		}
+	}
+	domainId, err := req.getDomainId(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	err = checkUserName(domainId, input.UserName, userId)
+	if err != nil {
+		return 0, nil, err
+	}
+	active := input.Active == nil || *input.Active
+	user, err := req.idp.SyncOrCreateUser(ctx, extId, input.UserName, domainId, active, func(user *models.SUser) {
+		syncUserAttributes(user, input)
+	})
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "SyncOrCreateUser")
+	}
+	return http.StatusCreated, jsonutils.Marshal(req.userToScim(user)), nil
+}
+
+func getUser(ctx context.Context, req *sScimRequest) (int, jsonutils.JSONObject, error) {
+	user, err := req.fetchUser(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, jsonutils.Marshal(req.userToScim(user)), nil
+}
+
+func replaceUser(ctx context.Context, req *sScimRequest) (int, jsonutils.JSONObject, error) {
+	user, err := req.fetchUser(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	input, err := req.parseUser()
+	if err != nil {
+		return 0, nil, err
+	}
+	err = updateUser(ctx, user, input)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, jsonutils.Marshal(req.userToScim(user)), nil
+}
+
+func patchUser(ctx context.Context, req *sScimRequest) (int, jsonutils.JSONObject, error) {
+	user, err := req.fetchUser(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	patch := SPatchRequest{}
+	err = req.parseBody(&patch)
+	if err != nil {
+		return 0, nil, err
+	}
+	current := jsonutils.Marshal(req.userToScim(user)).(*jsonutils.JSONDict)
+	err = ApplyPatchOperations(current, patch.Operations)
+	if err != nil {
+		return 0, nil, err
+	}
+	input := SUser{}
+	err = canonicalize(current).Unmarshal(&input)
+	if err != nil {
+		return 0, nil, newBadRequestError(SCIM_TYPE_INVALID_VALUE, "invalid patch result: %s", err)
+	}
+	if len(input.UserName) == 0 {
+		return 0, nil, newBadRequestError(SCIM_TYPE_INVALID_VALUE, "userName is required")
+	}
+	err = updateUser(ctx, user, input)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, jsonutils.Marshal(req.userToScim(user)), nil
+}
+
+// deleteUser deprovisions a user by disabling it, the user and its assignments are kept
+func deleteUser(ctx context.Context, req *sScimRequest) (int, jsonutils.JSONObject, error) {
+	user, err := req.fetchUser(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	input := req.userToScim(user)
+	inactive := false
+	input.Active = &inactive
+	err = updateUser(ctx, user, input)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, nil
+}
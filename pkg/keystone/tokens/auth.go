@@ -21,7 +21,6 @@ import (
 
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
-	"yunion.io/x/pkg/utils"
 	"yunion.io/x/sqlchemy"
 
 	api "yunion.io/x/onecloud/pkg/apis/identity"
@@ -35,21 +34,30 @@ import (
 	"yunion.io/x/onecloud/pkg/util/s3auth"
 )
 
-func authUserByTokenV2(ctx context.Context, input mcclient.SAuthenticationInputV2) (*api.SUserExtended, error) {
+func authUserByTokenV2(ctx context.Context, input mcclient.SAuthenticationInputV2) (*api.SUserExtended, []string, error) {
 	return authUserByToken(ctx, input.Auth.Token.Id)
 }
 
-func authUserByTokenV3(ctx context.Context, input mcclient.SAuthenticationInputV3) (*api.SUserExtended, error) {
+func authUserByTokenV3(ctx context.Context, input mcclient.SAuthenticationInputV3) (*api.SUserExtended, []string, error) {
 	return authUserByToken(ctx, input.Auth.Identity.Token.Id)
 }
 
-func authUserByToken(ctx context.Context, tokenStr string) (*api.SUserExtended, error) {
+// authUserByToken returns the user and the audit ids of the token
+func authUserByToken(ctx context.Context, tokenStr string) (*api.SUserExtended, []string, error) {
 	token := SAuthToken{}
 	err := token.ParseFernetToken(tokenStr)
 	if err != nil {
-		return nil, errors.Wrap(err, "token.ParseFernetToken")
+		return nil, nil, errors.Wrap(err, "token.ParseFernetToken")
 	}
-	return models.UserManager.FetchUserExtended(token.UserId, "", "", "")
+	if len(token.AppCredId) > 0 {
+		// tokens of application credentials are bound to the project, never rescope them
+		return nil, nil, errors.Wrap(httperrors.ErrForbidden, "cannot rescope token of application credential")
+	}
+	user, err := models.UserManager.FetchUserExtended(token.UserId, "", "", "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, token.AuditIds, nil
 }
 
 func authUserByPasswordV2(ctx context.Context, input mcclient.SAuthenticationInputV2) (*api.SUserExtended, error) {
@@ -367,6 +375,43 @@ func authUserByAccessKeyV3(ctx context.Context, input mcclient.SAuthenticationIn
 	return usrExt, credential.ProjectId, aksk, nil
 }
 
+func authUserByApplicationCredentialV3(ctx context.Context, input mcclient.SAuthenticationInputV3) (*api.SUserExtended, *models.SApplicationCredential, error) {
+	ident := input.Auth.Identity.ApplicationCredential
+	var appCred *models.SApplicationCredential
+	var err error
+	if len(ident.Id) > 0 {
+		appCred, err = models.ApplicationCredentialManager.FetchApplicationCredentialById(ident.Id)
+	} else if len(ident.Name) > 0 {
+		// application credential names are only unique for the owner
+		var usr *api.SUserExtended
+		usr, err = models.UserManager.FetchUserExtended(ident.User.Id, ident.User.Name, ident.User.Domain.Id, ident.User.Domain.Name)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "UserManager.FetchUserExtended")
+		}
+		appCred, err = models.ApplicationCredentialManager.FetchApplicationCredentialByName(ident.Name, usr.Id)
+	} else {
+		return nil, nil, ErrEmptyAuth
+	}
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, nil, ErrInvalidApplicationCredential
+		}
+		return nil, nil, errors.Wrap(err, "fetch application credential")
+	}
+	err = appCred.VerifySecret(ident.Secret)
+	if err != nil {
+		return nil, nil, ErrInvalidApplicationCredential
+	}
+	if appCred.IsExpired() {
+		return nil, nil, ErrExpiredApplicationCredential
+	}
+	usrExt, err := models.UserManager.FetchUserExtended(appCred.UserId, "", "", "")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "UserManager.FetchUserExtended")
+	}
+	return usrExt, appCred, nil
+}
+
 // +onecloud:swagger-gen-route-method=POST
 // +onecloud:swagger-gen-route-path=/v3/auth/tokens
 // +onecloud:swagger-gen-route-tag=authentication
@@ -378,7 +423,9 @@ func authUserByAccessKeyV3(ctx context.Context, input mcclient.SAuthenticationIn
 // keystone v3认证API
 func AuthenticateV3(ctx context.Context, input mcclient.SAuthenticationInputV3) (*mcclient.TokenCredentialV3, error) {
 	var akskInfo api.SAccessKeySecretInfo
+	var appCred *models.SApplicationCredential
 	var user *api.SUserExtended
+	var parentAuditIds []string
 	var err error
 	if len(input.Auth.Identity.Methods) != 1 {
 		return nil, ErrInvalidAuthMethod
@@ -387,7 +434,7 @@ func AuthenticateV3(ctx context.Context, input mcclient.SAuthenticationInputV3)
 	switch method {
 	case api.AUTH_METHOD_TOKEN:
 		// auth by token
-		user, err = authUserByTokenV3(ctx, input)
+		user, parentAuditIds, err = authUserByTokenV3(ctx, input)
 		if err != nil {
 			return nil, errors.Wrap(err, "authUserByTokenV3")
 		}
@@ -397,6 +444,16 @@ func AuthenticateV3(ctx context.Context, input mcclient.SAuthenticationInputV3)
 		if err != nil {
 			return nil, errors.Wrap(err, "authUserByAccessKeyV3")
 		}
+	case api.AUTH_METHOD_APPCRED:
+		// auth by application credential, the token is always scoped to the project of the credential
+		user, appCred, err = authUserByApplicationCredentialV3(ctx, input)
+		if err != nil {
+			return nil, errors.Wrap(err, "authUserByApplicationCredentialV3")
+		}
+		input.Auth.Scope.Project.Id = appCred.ProjectId
+		input.Auth.Scope.Project.Name = ""
+		input.Auth.Scope.Domain.Id = ""
+		input.Auth.Scope.Domain.Name = ""
 	case api.AUTH_METHOD_CAS:
 		// auth by apereo CAS
 		user, err = authUserByCASV3(ctx, input)
@@ -445,9 +502,16 @@ func AuthenticateV3(ctx context.Context, input mcclient.SAuthenticationInputV3)
 	token := SAuthToken{}
 	token.UserId = user.Id
 	token.Method = method
-	token.AuditIds = []string{utils.GenRequestId(16)}
+	if appCred != nil {
+		// tokens of the application credential share its id as the audit chain id,
+		// so that deleting the credential revokes all of them
+		token.AppCredId = appCred.Id
+		parentAuditIds = []string{appCred.Id}
+	}
+	token.AuditIds = newAuditIds(parentAuditIds)
 	now := time.Now().UTC()
 	token.ExpiresAt = now.Add(time.Duration(options.Options.TokenExpirationSeconds) * time.Second)
+	token.IssuedAt = now
 	token.Context = input.Auth.Context
 
 	if len(input.Auth.Scope.Project.Id) == 0 && len(input.Auth.Scope.Project.Name) == 0 && len(input.Auth.Scope.Domain.Id) == 0 && len(input.Auth.Scope.Domain.Name) == 0 {
@@ -516,11 +580,12 @@ func AuthenticateV2(ctx context.Context, input mcclient.SAuthenticationInputV2)
 
 func _authenticateV2(ctx context.Context, input mcclient.SAuthenticationInputV2) (*mcclient.TokenCredentialV2, error) {
 	var user *api.SUserExtended
+	var parentAuditIds []string
 	var err error
 	var method string
 	if len(input.Auth.Token.Id) > 0 {
 		// auth by token
-		user, err = authUserByTokenV2(ctx, input)
+		user, parentAuditIds, err = authUserByTokenV2(ctx, input)
 		if err != nil {
 			return nil, errors.Wrap(err, "authUserByTokenV2")
 		}
@@ -549,9 +614,10 @@ func _authenticateV2(ctx context.Context, input mcclient.SAuthenticationInputV2)
 	token := SAuthToken{}
 	token.UserId = user.Id
 	token.Method = method
-	token.AuditIds = []string{utils.GenRequestId(16)}
+	token.AuditIds = newAuditIds(parentAuditIds)
 	now := time.Now().UTC()
 	token.ExpiresAt = now.Add(time.Duration(options.Options.TokenExpirationSeconds) * time.Second)
+	token.IssuedAt = now
 	token.Context = input.Auth.Context
 
 	if len(input.Auth.TenantId) == 0 && len(input.Auth.TenantName) == 0 {
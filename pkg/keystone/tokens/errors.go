@@ -21,6 +21,7 @@ const (
 	ErrProjectDisabled    = errors.Error("project disabled")
 	ErrUserDisabled       = errors.Error("user disabled")
 	ErrExpiredToken       = errors.Error("expired token")
+	ErrRevokedToken       = errors.Error("revoked token")
 	ErrInvalidFernetToken = errors.Error("invalid fernet token")
 	ErrInvalidAuthMethod  = errors.Error("invalid auth methods")
 	ErrUserNotFound       = errors.Error("user not found")
@@ -29,4 +30,7 @@ const (
 	ErrUserNotInProject   = errors.Error("user not in project")
 	ErrInvalidAccessKeyId = errors.Error("invalid access key id")
 	ErrExpiredAccessKey   = errors.Error("expired access key")
+
+	ErrInvalidApplicationCredential = errors.Error("invalid application credential")
+	ErrExpiredApplicationCredential = errors.Error("expired application credential")
 )
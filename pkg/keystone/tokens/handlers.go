@@ -17,10 +17,12 @@ package tokens
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/timeutils"
 	"yunion.io/x/sqlchemy"
 
 	api "yunion.io/x/onecloud/pkg/apis/identity"
@@ -39,6 +41,8 @@ func AddHandler(app *appsrv.Application) {
 	app.AddHandler2("POST", "/v3/auth/tokens", authenticateTokensV3, nil, "auth_tokens_v3", nil)
 	app.AddHandler2("GET", "/v2.0/tokens/<token>", authenticateToken(verifyTokensV2), nil, "verify_tokens_v2", nil)
 	app.AddHandler2("GET", "/v3/auth/tokens", authenticateToken(verifyTokensV3), nil, "verify_tokens_v3", nil)
+	app.AddHandler2("DELETE", "/v3/auth/tokens", authenticateToken(revokeTokensV3), nil, "revoke_tokens_v3", nil)
+	app.AddHandler2("GET", "/v3/auth/revocations", authenticateToken(fetchRevocationEvents), nil, "fetch_revocation_events", nil)
 	app.AddHandler2("GET", "/v3/auth/policies", authenticateToken(fetchTokenPolicies), nil, "fetch_token_policies", nil)
 }
 
@@ -216,9 +220,13 @@ func verifyTokensV3(ctx context.Context, w http.ResponseWriter, r *http.Request)
 	appsrv.SendJSON(w, jsonutils.Marshal(v3token))
 }
 
-func verifyCommon(ctx context.Context, w http.ResponseWriter, tokenStr string) (*SAuthToken, error) {
+func isAllowAuth(ctx context.Context) bool {
 	adminToken := policy.FetchUserCredential(ctx)
-	if adminToken == nil || !adminToken.IsAllow(rbacutils.ScopeSystem, api.SERVICE_TYPE, "tokens", "perform", "auth") {
+	return adminToken != nil && adminToken.IsAllow(rbacutils.ScopeSystem, api.SERVICE_TYPE, "tokens", "perform", "auth")
+}
+
+func verifyCommon(ctx context.Context, w http.ResponseWriter, tokenStr string) (*SAuthToken, error) {
+	if !isAllowAuth(ctx) {
 		return nil, httperrors.NewForbiddenError("not allow to auth")
 	}
 	token := SAuthToken{}
@@ -230,6 +238,82 @@ func verifyCommon(ctx context.Context, w http.ResponseWriter, tokenStr string) (
 	return &token, nil
 }
 
+// swagger:parameters revokeTokensV3
+type RevokeTokenV3Param struct {
+	// 注销的keystone V3 token
+	// in:header
+	// required:true
+	Token string `json:"X-Subject-Token"`
+}
+
+// swagger:route DELETE /v3/auth/tokens authentication revokeTokensV3
+//
+// keystone v3注销token API
+//
+// 注销token及由其切换项目得到的token，用户可以注销自己的token
+//
+//     Responses:
+//       204:
+func revokeTokensV3(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	tokenStr := r.Header.Get(api.AUTH_SUBJECT_TOKEN_HEADER)
+	token := SAuthToken{}
+	err := token.ParseFernetToken(tokenStr)
+	if err != nil {
+		httperrors.NotFoundError(ctx, w, "token not found")
+		return
+	}
+	userCred := policy.FetchUserCredential(ctx)
+	if userCred == nil || (userCred.GetUserId() != token.UserId && !userCred.IsAllow(rbacutils.ScopeSystem, api.SERVICE_TYPE, "tokens", "delete")) {
+		httperrors.ForbiddenError(ctx, w, "not allow to revoke token")
+		return
+	}
+	err = models.RevocationEventManager.RevokeToken(ctx, token.getAuditChainId())
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// swagger:parameters fetchRevocationEvents
+type FetchRevocationEventsParam struct {
+	// 只返回该时间之后的注销事件
+	// in:query
+	Since string `json:"since"`
+}
+
+// swagger:route GET /v3/auth/revocations authentication fetchRevocationEvents
+//
+// 获取未过期的token注销事件
+//
+// 各服务定期拉取注销事件，使缓存的已注销token失效
+//
+//     Responses:
+//       200: api.RevocationEventListOutput
+func fetchRevocationEvents(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if !isAllowAuth(ctx) {
+		httperrors.ForbiddenError(ctx, w, "not allow to fetch revocation events")
+		return
+	}
+	_, query, _ := appsrv.FetchEnv(ctx, w, r)
+	var since time.Time
+	if query != nil && query.Contains("since") {
+		sinceStr, _ := query.GetString("since")
+		var err error
+		since, err = timeutils.ParseTimeStr(sinceStr)
+		if err != nil {
+			httperrors.InputParameterError(ctx, w, "invalid since %s", sinceStr)
+			return
+		}
+	}
+	events, err := models.RevocationEventManager.FetchEvents(since)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	appsrv.SendJSON(w, jsonutils.Marshal(api.RevocationEventListOutput{Events: events}))
+}
+
 func authenticateToken(f appsrv.FilterHandler) appsrv.FilterHandler {
 	return authenticateTokenWithDelayDecision(f, true)
 }
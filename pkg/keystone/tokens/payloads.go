@@ -39,6 +39,8 @@ const (
 	SProjectScopedPayloadWithContextVersion = TScopedPayloadVersion(5)
 	SDomainScopedPayloadWithContextVersion  = TScopedPayloadVersion(4)
 	SUnscopedPayloadWithContextVersion      = TScopedPayloadVersion(3)
+
+	SApplicationCredentialPayloadVersion = TScopedPayloadVersion(6)
 )
 
 type ITokenPayload interface {
@@ -135,7 +137,8 @@ type SProjectScopedPayload struct {
 
 type SProjectScopedPayloadWithContext struct {
 	SProjectScopedPayload
-	Context SAuthContextPayload
+	Context  SAuthContextPayload
+	IssuedAt float64
 }
 
 func (p *SProjectScopedPayload) GetVersion() TScopedPayloadVersion {
@@ -165,6 +168,7 @@ func (p *SProjectScopedPayloadWithContext) Unmarshal(tk []byte) error {
 func (p *SProjectScopedPayloadWithContext) Decode(token *SAuthToken) {
 	p.SProjectScopedPayload.Decode(token)
 	token.Context = p.Context.getAuthContext()
+	token.IssuedAt = issuedAt2Time(p.IssuedAt)
 }
 
 func (p *SProjectScopedPayloadWithContext) Encode() ([]byte, error) {
@@ -182,7 +186,8 @@ type SDomainScopedPayload struct {
 
 type SDomainScopedPayloadWithContext struct {
 	SDomainScopedPayload
-	Context SAuthContextPayload
+	Context  SAuthContextPayload
+	IssuedAt float64
 }
 
 func (p *SDomainScopedPayload) GetVersion() TScopedPayloadVersion {
@@ -212,6 +217,7 @@ func (p *SDomainScopedPayloadWithContext) Unmarshal(tk []byte) error {
 func (p *SDomainScopedPayloadWithContext) Decode(token *SAuthToken) {
 	p.SDomainScopedPayload.Decode(token)
 	token.Context = p.Context.getAuthContext()
+	token.IssuedAt = issuedAt2Time(p.IssuedAt)
 }
 
 func (p *SDomainScopedPayloadWithContext) Encode() ([]byte, error) {
@@ -228,7 +234,8 @@ type SUnscopedPayload struct {
 
 type SUnscopedPayloadWithContext struct {
 	SUnscopedPayload
-	Context SAuthContextPayload
+	Context  SAuthContextPayload
+	IssuedAt float64
 }
 
 func (p *SUnscopedPayload) GetVersion() TScopedPayloadVersion {
@@ -257,12 +264,42 @@ func (p *SUnscopedPayloadWithContext) Unmarshal(tk []byte) error {
 func (p *SUnscopedPayloadWithContext) Decode(token *SAuthToken) {
 	p.SUnscopedPayload.Decode(token)
 	token.Context = p.Context.getAuthContext()
+	token.IssuedAt = issuedAt2Time(p.IssuedAt)
 }
 
 func (p *SUnscopedPayloadWithContext) Encode() ([]byte, error) {
 	return msgpackEncoder(p)
 }
 
+// SApplicationCredentialPayload is the payload of tokens issued for
+// application credentials, which are always project scoped
+type SApplicationCredentialPayload struct {
+	SProjectScopedPayloadWithContext
+	AppCredId SUuidPayload
+}
+
+func (p *SApplicationCredentialPayload) Unmarshal(tk []byte) error {
+	return msgpackDecoder(p, tk, SApplicationCredentialPayloadVersion)
+}
+
+func (p *SApplicationCredentialPayload) Decode(token *SAuthToken) {
+	p.SProjectScopedPayloadWithContext.Decode(token)
+	token.AppCredId = p.AppCredId.getUuid()
+}
+
+func (p *SApplicationCredentialPayload) Encode() ([]byte, error) {
+	return msgpackEncoder(p)
+}
+
+// issuedAt2Time converts the issue time in payload to time, tokens issued
+// before issued_at was added to the payload have no issue time
+func issuedAt2Time(issuedAt float64) time.Time {
+	if issuedAt <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(issuedAt), 0).UTC()
+}
+
 func auditString2Bytes(str string) string {
 	bt, _ := base64.URLEncoding.DecodeString(str + "==")
 	return string(bt)
@@ -16,6 +16,7 @@ package tokens
 
 import (
 	"context"
+	"database/sql"
 	"strings"
 	"time"
 
@@ -43,25 +44,43 @@ func GetDefaultToken() (string, error) {
 			Method:    api.AUTH_METHOD_TOKEN,
 			ProjectId: simpleToken.GetProjectId(),
 			ExpiresAt: now.Add(24 * time.Hour),
+			IssuedAt:  now,
 			AuditIds:  []string{utils.GenRequestId(16)},
 		}
 	}
 	return defaultAuthToken.EncodeFernetToken()
 }
 
+// newAuditIds returns the audit ids of a new token, tokens rescoped from
+// an existing token inherit the audit id of the original token so that
+// revoking the original token revokes the whole chain
+func newAuditIds(parentAuditIds []string) []string {
+	auditIds := []string{utils.GenRequestId(16)}
+	if len(parentAuditIds) > 0 {
+		auditIds = append(auditIds, parentAuditIds[len(parentAuditIds)-1])
+	}
+	return auditIds
+}
+
 type SAuthToken struct {
 	UserId    string
 	Method    string
 	ProjectId string
 	DomainId  string
 	ExpiresAt time.Time
-	AuditIds  []string
+	// zero for tokens issued before the issue time was kept in the payload,
+	// such tokens are revoked by any matching revocation event
+	IssuedAt time.Time
+	AuditIds []string
+	// id of the application credential the token is issued for
+	AppCredId string
 
 	Context mcclient.SAuthContext
 }
 
 func (t *SAuthToken) Decode(tk []byte) error {
 	for _, payload := range []ITokenPayload{
+		&SApplicationCredentialPayload{},
 		&SProjectScopedPayloadWithContext{},
 		&SDomainScopedPayloadWithContext{},
 		&SUnscopedPayloadWithContext{},
@@ -98,6 +117,21 @@ func (t *SAuthToken) getProjectScopedPayloadWithContext() ITokenPayload {
 	p.ExpiresAt = float64(t.ExpiresAt.Unix())
 	p.AuditIds = auditStrings2Bytes(t.AuditIds)
 	p.Context = authContext2Payload(t.Context)
+	p.IssuedAt = float64(t.IssuedAt.Unix())
+	return &p
+}
+
+func (t *SAuthToken) getApplicationCredentialPayload() ITokenPayload {
+	p := SApplicationCredentialPayload{}
+	p.Version = SApplicationCredentialPayloadVersion
+	p.UserId.parse(t.UserId)
+	p.ProjectId.parse(t.ProjectId)
+	p.Method = authMethodStr2Id(t.Method)
+	p.ExpiresAt = float64(t.ExpiresAt.Unix())
+	p.AuditIds = auditStrings2Bytes(t.AuditIds)
+	p.Context = authContext2Payload(t.Context)
+	p.IssuedAt = float64(t.IssuedAt.Unix())
+	p.AppCredId.parse(t.AppCredId)
 	return &p
 }
 
@@ -114,13 +148,14 @@ func (t *SAuthToken) getDomainScopedPayload() ITokenPayload {
 
 func (t *SAuthToken) getDomainScopedPayloadWithContext() ITokenPayload {
 	p := SDomainScopedPayloadWithContext{}
-	p.Version = SDomainScopedPayloadVersion
+	p.Version = SDomainScopedPayloadWithContextVersion
 	p.UserId.parse(t.UserId)
 	p.DomainId.parse(t.DomainId)
 	p.Method = authMethodStr2Id(t.Method)
 	p.ExpiresAt = float64(t.ExpiresAt.Unix())
 	p.AuditIds = auditStrings2Bytes(t.AuditIds)
 	p.Context = authContext2Payload(t.Context)
+	p.IssuedAt = float64(t.IssuedAt.Unix())
 	return &p
 }
 
@@ -136,16 +171,20 @@ func (t *SAuthToken) getUnscopedPayload() ITokenPayload {
 
 func (t *SAuthToken) getUnscopedPayloadWithContext() ITokenPayload {
 	p := SUnscopedPayloadWithContext{}
-	p.Version = SUnscopedPayloadVersion
+	p.Version = SUnscopedPayloadWithContextVersion
 	p.UserId.parse(t.UserId)
 	p.Method = authMethodStr2Id(t.Method)
 	p.ExpiresAt = float64(t.ExpiresAt.Unix())
 	p.AuditIds = auditStrings2Bytes(t.AuditIds)
 	p.Context = authContext2Payload(t.Context)
+	p.IssuedAt = float64(t.IssuedAt.Unix())
 	return &p
 }
 
 func (t *SAuthToken) getPayload() ITokenPayload {
+	if len(t.AppCredId) > 0 {
+		return t.getApplicationCredentialPayload()
+	}
 	if len(t.ProjectId) > 0 {
 		return t.getProjectScopedPayloadWithContext()
 	}
@@ -168,9 +207,28 @@ func (t *SAuthToken) ParseFernetToken(tokenStr string) error {
 	if err != nil {
 		return errors.Wrap(err, "decode error")
 	}
+	if t.isRevoked() {
+		return ErrRevokedToken
+	}
 	return nil
 }
 
+// getAuditChainId returns the audit id shared by all tokens rescoped from the same origin
+func (t *SAuthToken) getAuditChainId() string {
+	if len(t.AuditIds) == 0 {
+		return ""
+	}
+	return t.AuditIds[len(t.AuditIds)-1]
+}
+
+func (t *SAuthToken) isRevoked() bool {
+	projectId := t.ProjectId
+	if len(projectId) == 0 {
+		projectId = t.DomainId
+	}
+	return models.RevocationEventManager.IsRevoked(t.UserId, projectId, t.AuditIds, t.IssuedAt)
+}
+
 func (t *SAuthToken) EncodeFernetToken() (string, error) {
 	tk, err := t.Encode()
 	if err != nil {
@@ -207,7 +265,17 @@ func (t *SAuthToken) GetSimpleUserCred(token string) (mcclient.TokenCredential,
 		ret.Project = proj.Name
 		ret.ProjectDomainId = proj.DomainId
 		ret.ProjectDomain = proj.GetDomain().Name
-		roles, err = models.AssignmentManager.FetchUserProjectRoles(t.UserId, t.ProjectId)
+		if len(t.AppCredId) > 0 {
+			appCred, err := t.fetchApplicationCredential()
+			if err != nil {
+				return nil, errors.Wrap(err, "fetchApplicationCredential")
+			}
+			ret.ApplicationCredentialId = appCred.Id
+			ret.AccessRules = appCred.GetAccessRules()
+			roles, err = appCred.FetchRoles()
+		} else {
+			roles, err = models.AssignmentManager.FetchUserProjectRoles(t.UserId, t.ProjectId)
+		}
 	} else if len(t.DomainId) > 0 {
 		domain, err := models.DomainManager.FetchDomainById(t.DomainId)
 		if err != nil {
@@ -228,7 +296,28 @@ func (t *SAuthToken) GetSimpleUserCred(token string) (mcclient.TokenCredential,
 	return &ret, nil
 }
 
+func (t *SAuthToken) fetchApplicationCredential() (*models.SApplicationCredential, error) {
+	appCred, err := models.ApplicationCredentialManager.FetchApplicationCredentialById(t.AppCredId)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, ErrInvalidApplicationCredential
+		}
+		return nil, errors.Wrap(err, "FetchApplicationCredentialById")
+	}
+	if appCred.IsExpired() {
+		return nil, ErrExpiredApplicationCredential
+	}
+	return appCred, nil
+}
+
 func (t *SAuthToken) getRoles() ([]models.SRole, error) {
+	if len(t.AppCredId) > 0 {
+		appCred, err := t.fetchApplicationCredential()
+		if err != nil {
+			return nil, errors.Wrap(err, "fetchApplicationCredential")
+		}
+		return appCred.FetchRoles()
+	}
 	var roleProjectId string
 	if len(t.ProjectId) > 0 {
 		roleProjectId = t.ProjectId
@@ -251,7 +340,7 @@ func (t *SAuthToken) getTokenV3(
 	token := mcclient.TokenCredentialV3{}
 	token.Token.AccessKey = akskInfo
 	token.Token.ExpiresAt = t.ExpiresAt
-	token.Token.IssuedAt = t.ExpiresAt.Add(-time.Duration(options.Options.TokenExpirationSeconds) * time.Second)
+	token.Token.IssuedAt = t.IssuedAt
 	token.Token.AuditIds = t.AuditIds
 	token.Token.Methods = []string{t.Method}
 	token.Token.User.Id = user.Id
@@ -266,6 +355,13 @@ func (t *SAuthToken) getTokenV3(
 	token.Token.User.Email = user.Email
 	token.Token.User.Mobile = user.Mobile
 	token.Token.Context = t.Context
+	if len(t.AppCredId) > 0 {
+		appCred, err := t.fetchApplicationCredential()
+		if err != nil {
+			return nil, errors.Wrap(err, "fetchApplicationCredential")
+		}
+		token.Token.ApplicationCredential = appCred.GetApplicationCredentialInfo()
+	}
 
 	tk, err := t.EncodeFernetToken()
 	if err != nil {
@@ -66,3 +66,145 @@ func TestSAuthToken_Encode(t *testing.T) {
 		}
 	}
 }
+
+func TestNewAuditIds(t *testing.T) {
+	origin := newAuditIds(nil)
+	if len(origin) != 1 {
+		t.Fatalf("expect 1 audit id, got %v", origin)
+	}
+	rescoped := newAuditIds(origin)
+	if len(rescoped) != 2 || rescoped[1] != origin[0] || rescoped[0] == origin[0] {
+		t.Fatalf("rescoped token should inherit the audit id of the origin: %v %v", rescoped, origin)
+	}
+	rescoped2 := newAuditIds(rescoped)
+	if len(rescoped2) != 2 || rescoped2[1] != origin[0] {
+		t.Fatalf("rescoped token should inherit the audit id of the origin: %v %v", rescoped2, origin)
+	}
+
+	event := api.SRevocationEvent{
+		AuditId:      origin[0],
+		IssuedBefore: time.Now(),
+	}
+	if !event.IsRevoked("", "", rescoped2, time.Now().Add(-time.Minute)) {
+		t.Fatalf("revoking the origin token should revoke the rescoped tokens")
+	}
+	event = api.SRevocationEvent{
+		UserId:       "user",
+		ProjectId:    "project",
+		IssuedBefore: time.Now(),
+	}
+	if !event.IsRevoked("user", "project", origin, time.Now().Add(-time.Minute)) {
+		t.Fatalf("token of the user in the project should be revoked")
+	}
+	if event.IsRevoked("user", "project2", origin, time.Now().Add(-time.Minute)) {
+		t.Fatalf("token of the user in other project should not be revoked")
+	}
+	if event.IsRevoked("user", "project", origin, time.Now().Add(time.Minute)) {
+		t.Fatalf("token issued after the event should not be revoked")
+	}
+}
+
+func TestApplicationCredentialPayload(t *testing.T) {
+	token := SAuthToken{}
+	token.UserId = newUuid()
+	token.Method = api.AUTH_METHOD_APPCRED
+	token.ProjectId = newUuid()
+	token.ExpiresAt = time.Now().UTC().Truncate(time.Second)
+	token.AppCredId = newUuid()
+	token.AuditIds = newAuditIds([]string{token.AppCredId})
+
+	tk, err := token.Encode()
+	if err != nil {
+		t.Fatalf("SAuthToken encode fail %s", err)
+	}
+	token2 := SAuthToken{}
+	err = token2.Decode(tk)
+	if err != nil {
+		t.Fatalf("SAuthToken decode fail %s", err)
+	}
+	if token2.AppCredId != token.AppCredId || token2.ProjectId != token.ProjectId || token2.Method != token.Method {
+		t.Fatalf("decode application credential token mismatch %#v != %#v", token2, token)
+	}
+	if token2.getAuditChainId() != token.AppCredId {
+		t.Fatalf("audit chain id %s should be the application credential id %s", token2.getAuditChainId(), token.AppCredId)
+	}
+
+	token.AppCredId = ""
+	tk, err = token.Encode()
+	if err != nil {
+		t.Fatalf("SAuthToken encode fail %s", err)
+	}
+	token3 := SAuthToken{}
+	err = token3.Decode(tk)
+	if err != nil {
+		t.Fatalf("SAuthToken decode fail %s", err)
+	}
+	if len(token3.AppCredId) > 0 {
+		t.Fatalf("project scoped token should not carry application credential id")
+	}
+}
+
+func TestIssuedAtPayload(t *testing.T) {
+	now := time.Now().UTC()
+	for _, scope := range []string{"project", "domain", "unscoped", "appcred"} {
+		token := SAuthToken{}
+		token.UserId = newUuid()
+		token.Method = api.AUTH_METHOD_PASSWORD
+		switch scope {
+		case "project":
+			token.ProjectId = newUuid()
+		case "domain":
+			token.DomainId = newUuid()
+		case "appcred":
+			token.ProjectId = newUuid()
+			token.AppCredId = newUuid()
+		}
+		// the issue time no longer depends on the token expiration
+		token.ExpiresAt = now.Add(24 * time.Hour)
+		token.IssuedAt = now
+		token.AuditIds = newAuditIds(nil)
+
+		tk, err := token.Encode()
+		if err != nil {
+			t.Fatalf("%s: SAuthToken encode fail %s", scope, err)
+		}
+		token2 := SAuthToken{}
+		err = token2.Decode(tk)
+		if err != nil {
+			t.Fatalf("%s: SAuthToken decode fail %s", scope, err)
+		}
+		if !token2.IssuedAt.Equal(now.Truncate(time.Second)) {
+			t.Errorf("%s: issued at %s, want %s", scope, token2.IssuedAt, now.Truncate(time.Second))
+		}
+		if token2.AppCredId != token.AppCredId {
+			t.Errorf("%s: application credential id %s, want %s", scope, token2.AppCredId, token.AppCredId)
+		}
+	}
+
+	// tokens issued before issued_at was kept in the payload
+	token := SAuthToken{}
+	token.UserId = newUuid()
+	token.Method = api.AUTH_METHOD_PASSWORD
+	token.ProjectId = newUuid()
+	token.ExpiresAt = now
+	token.AuditIds = newAuditIds(nil)
+	tk, err := token.getProjectScopedPayload().Encode()
+	if err != nil {
+		t.Fatalf("SAuthToken encode fail %s", err)
+	}
+	token2 := SAuthToken{}
+	err = token2.Decode(tk)
+	if err != nil {
+		t.Fatalf("SAuthToken decode fail %s", err)
+	}
+	if !token2.IssuedAt.IsZero() {
+		t.Errorf("legacy token issued at %s, want zero", token2.IssuedAt)
+	}
+	event := api.SRevocationEvent{
+		UserId:       token.UserId,
+		IssuedBefore: now.Add(-time.Hour),
+	}
+	if !event.IsRevoked(token2.UserId, token2.ProjectId, token2.AuditIds, token2.IssuedAt) {
+		t.Errorf("legacy token of the user should be revoked")
+	}
+}
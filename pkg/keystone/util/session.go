@@ -42,6 +42,7 @@ func getDefaultAdminCredWithToken() (mcclient.TokenCredential, error) {
 			Method:    api.AUTH_METHOD_TOKEN,
 			ProjectId: simpleToken.GetProjectId(),
 			ExpiresAt: now.Add(24 * time.Hour),
+			IssuedAt:  now,
 			AuditIds:  []string{utils.GenRequestId(16)},
 		}
 		simpleToken.Token, err = authTokenTmp.EncodeFernetToken()
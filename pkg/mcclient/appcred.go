@@ -0,0 +1,36 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcclient
+
+import (
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+// AuthenticateApplicationCredential authenticates by application credential id and secret,
+// the token is always scoped to the project the application credential is bound to
+func (this *Client) AuthenticateApplicationCredential(appCredId string, secret string, source string) (TokenCredential, error) {
+	if this.AuthVersion() != "v3" {
+		return nil, httperrors.ErrNotSupported
+	}
+	input := SAuthenticationInputV3{}
+	input.Auth.Identity.Methods = []string{api.AUTH_METHOD_APPCRED}
+	input.Auth.Identity.ApplicationCredential.Id = appCredId
+	input.Auth.Identity.ApplicationCredential.Secret = secret
+	input.Auth.Context = SAuthContext{
+		Source: source,
+	}
+	return this._authV3Input(input)
+}
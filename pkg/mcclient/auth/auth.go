@@ -74,6 +74,21 @@ func NewAuthInfo(authUrl, domain, user, passwd, project, projectDomain string) *
 
 type cacheItem struct {
 	credential mcclient.TokenCredential
+
+	// issue time and audit ids of v3 tokens, checked against the revocation events
+	issuedAt time.Time
+	auditIds []string
+}
+
+func newCacheItem(cred mcclient.TokenCredential) *cacheItem {
+	item := &cacheItem{
+		credential: mcclient.SimplifyToken(cred),
+	}
+	if v3, ok := cred.(*mcclient.TokenCredentialV3); ok {
+		item.issuedAt = v3.Token.IssuedAt
+		item.auditIds = v3.Token.AuditIds
+	}
+	return item
 }
 
 func (item *cacheItem) Size() int {
@@ -82,28 +97,40 @@ func (item *cacheItem) Size() int {
 
 type TokenCacheVerify struct {
 	*cache.LRUCache
+
+	revocations *sRevocationCache
 }
 
 func NewTokenCacheVerify() *TokenCacheVerify {
 	return &TokenCacheVerify{
-		LRUCache: cache.NewLRUCache(defaultCacheCount),
+		LRUCache:    cache.NewLRUCache(defaultCacheCount),
+		revocations: newRevocationCache(),
 	}
 }
 
 func (c *TokenCacheVerify) AddToken(cred mcclient.TokenCredential) error {
-	item := &cacheItem{cred}
-	c.Set(cred.GetTokenString(), item)
+	c.Set(cred.GetTokenString(), newCacheItem(cred))
 	return nil
 }
 
-func (c *TokenCacheVerify) GetToken(token string) (mcclient.TokenCredential, bool) {
+func (c *TokenCacheVerify) getItem(token string) (*cacheItem, bool) {
 	item, found := c.Get(token)
 
 	if !found {
 		return nil, false
 	}
 
-	return item.(*cacheItem).credential, true
+	return item.(*cacheItem), true
+}
+
+func (c *TokenCacheVerify) GetToken(token string) (mcclient.TokenCredential, bool) {
+	item, found := c.getItem(token)
+
+	if !found {
+		return nil, false
+	}
+
+	return item.credential, true
 }
 
 func (c *TokenCacheVerify) DeleteToken(token string) bool {
@@ -111,13 +138,17 @@ func (c *TokenCacheVerify) DeleteToken(token string) bool {
 }
 
 func (c *TokenCacheVerify) Verify(ctx context.Context, cli *mcclient.Client, adminToken, token string) (mcclient.TokenCredential, error) {
-	cred, found := c.GetToken(token)
+	item, found := c.getItem(token)
 	if found {
-		if cred.IsValid() {
-			return cred, nil
-		} else {
+		if !item.credential.IsValid() {
 			c.DeleteToken(token)
 			log.Infof("Remove expired cache token: %s", token)
+		} else if c.revocations.isRevoked(item) {
+			// let keystone decide whether the token is revoked
+			c.DeleteToken(token)
+			log.Infof("Remove revoked cache token: %s", token)
+		} else {
+			return item.credential, nil
 		}
 	}
 
@@ -125,11 +156,11 @@ func (c *TokenCacheVerify) Verify(ctx context.Context, cli *mcclient.Client, adm
 	if err != nil {
 		return nil, err
 	}
-	cred = mcclient.SimplifyToken(cred)
 	err = c.AddToken(cred)
 	if err != nil {
 		return nil, fmt.Errorf("Add %s credential to cache: %#v", cred.GetTokenString(), err)
 	}
+	cred = mcclient.SimplifyToken(cred)
 	callbackAuthhooks(ctx, cred)
 	// log.Debugf("Add token: %s", cred)
 	return cred, nil
@@ -332,6 +363,12 @@ func ReAuth() {
 	manager.reAuth()
 }
 
+// StartTokenRevocationSync polls the token revocation events from keystone at
+// the interval, cached tokens revoked by the events are verified again
+func StartTokenRevocationSync(interval time.Duration) {
+	go manager.startRevocationSync(interval)
+}
+
 func GetAdminSession(ctx context.Context, region string,
 	apiVersion string) *mcclient.ClientSession {
 	return GetSession(ctx, manager.adminCredential, region, apiVersion)
@@ -23,6 +23,7 @@ import (
 	api "yunion.io/x/onecloud/pkg/apis/identity"
 	"yunion.io/x/onecloud/pkg/appctx"
 	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/util/rbacutils"
@@ -72,6 +73,11 @@ func AuthenticateWithDelayDecision(f appsrv.FilterHandler, delayDecision bool) a
 				token = &GuestToken
 			}
 		}
+		if !isAllowedByAccessRules(token, r) {
+			log.Errorf("request %s %s denied by access rules of application credential", r.Method, r.URL.Path)
+			httperrors.ForbiddenError(ctx, w, "request is not allowed by the access rules of application credential")
+			return
+		}
 		ctx = context.WithValue(ctx, appctx.APP_CONTEXT_KEY_AUTH_TOKEN, token)
 
 		if taskId := r.Header.Get(mcclient.TASK_ID); taskId != "" {
@@ -85,6 +91,21 @@ func AuthenticateWithDelayDecision(f appsrv.FilterHandler, delayDecision bool) a
 	}
 }
 
+// isAllowedByAccessRules checks the request against the access rules of the
+// application credential the token is issued for, tokens without access rules
+// are not restricted
+func isAllowedByAccessRules(token mcclient.TokenCredential, r *http.Request) bool {
+	appCredToken, ok := token.(mcclient.IApplicationCredentialToken)
+	if !ok {
+		return true
+	}
+	rules := appCredToken.GetAccessRules()
+	if len(rules) == 0 {
+		return true
+	}
+	return rules.Match(consts.GetServiceType(), r.Method, r.URL.Path)
+}
+
 func FetchUserCredential(ctx context.Context, filter func(mcclient.TokenCredential) mcclient.TokenCredential) mcclient.TokenCredential {
 	tokenValue := ctx.Value(appctx.APP_CONTEXT_KEY_AUTH_TOKEN)
 	if tokenValue != nil {
@@ -0,0 +1,108 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+const (
+	// events are polled with an overlap to tolerate clock skew between
+	// keystone and the service
+	revocationSyncOverlap = time.Minute
+)
+
+type sRevocationCache struct {
+	lock     sync.RWMutex
+	events   map[int64]identity.SRevocationEvent
+	lastSync time.Time
+}
+
+func newRevocationCache() *sRevocationCache {
+	return &sRevocationCache{
+		events: make(map[int64]identity.SRevocationEvent),
+	}
+}
+
+// isRevoked tells whether the cached token may be revoked, tokens without
+// issue time, e.g. v2 tokens, are considered revoked once any event matches
+func (c *sRevocationCache) isRevoked(item *cacheItem) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	cred := item.credential
+	for _, event := range c.events {
+		if item.issuedAt.IsZero() {
+			if event.Match(cred.GetUserId(), cred.GetProjectId(), item.auditIds) {
+				return true
+			}
+		} else if event.IsRevoked(cred.GetUserId(), cred.GetProjectId(), item.auditIds, item.issuedAt) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *sRevocationCache) addEvents(events []identity.SRevocationEvent, now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for id, event := range c.events {
+		if event.ExpiresAt.Before(now) {
+			delete(c.events, id)
+		}
+	}
+	for i := range events {
+		c.events[events[i].Id] = events[i]
+	}
+	c.lastSync = now
+}
+
+func (c *sRevocationCache) sync(cli *mcclient.Client, adminToken string) error {
+	now := time.Now()
+	c.lock.RLock()
+	since := c.lastSync
+	c.lock.RUnlock()
+	if !since.IsZero() {
+		since = since.Add(-revocationSyncOverlap)
+	}
+	events, err := cli.FetchRevocationEvents(adminToken, since)
+	if err != nil {
+		return errors.Wrap(err, "FetchRevocationEvents")
+	}
+	c.addEvents(events, now)
+	return nil
+}
+
+func (a *authManager) startRevocationSync(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if a.isAuthed() {
+			err := a.tokenCacheVerify.revocations.sync(a.client, a.getTokenString())
+			if err != nil {
+				log.Errorf("sync token revocation events fail: %s", err)
+			}
+		}
+		<-ticker.C
+	}
+}
@@ -83,6 +83,7 @@ type SAuthenticationIdentity struct {
 	// | saml     | 作为SAML 2.0 SP通过IDP认证                                            |
 	// | oidc     | 作为OpenID Connect/OAuth2 Client认证                                 |
 	// | oauth2   | OAuth2认证                                                          |
+	// | application_credential | 应用凭证认证                                            |
 	//
 	Methods []string `json:"methods,omitempty"`
 	// 当认证方式为password时，通过该字段提供密码认证信息
@@ -128,6 +129,30 @@ type SAuthenticationIdentity struct {
 	OAuth2 struct {
 		Code string `json:"code,omitempty"`
 	}
+	// 当认证方式为application_credential时，通过该字段提供应用凭证信息
+	// 通过ID认证，或者通过名称和所属用户认证
+	ApplicationCredential struct {
+		// 应用凭证ID
+		Id string `json:"id,omitempty"`
+		// 应用凭证名称
+		Name string `json:"name,omitempty"`
+		// 应用凭证密钥
+		Secret string `json:"secret,omitempty"`
+		// 应用凭证所属用户，通过名称认证时必须提供
+		User struct {
+			// 用户ID
+			Id string `json:"id,omitempty"`
+			// 用户名称
+			Name string `json:"name,omitempty"`
+			// 用户所属域的信息
+			Domain struct {
+				// 域ID
+				Id string `json:"id,omitempty"`
+				// 域名称
+				Name string `json:"name,omitempty"`
+			}
+		} `json:"user,omitempty"`
+	} `json:"application_credential,omitempty"`
 }
 
 type SAuthenticationInputV3 struct {
@@ -27,6 +27,7 @@ import (
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/util/timeutils"
 
 	"yunion.io/x/onecloud/pkg/apis"
 	api "yunion.io/x/onecloud/pkg/apis/identity"
@@ -313,6 +314,46 @@ func (this *Client) Verify(adminToken, token string) (cred TokenCredential, err
 	return this.verifyV2(adminToken, token)
 }
 
+// RevokeToken revokes the token, tokens can be revoked by the owner or by admin
+func (this *Client) RevokeToken(authToken, token string) error {
+	if this.AuthVersion() != "v3" {
+		return errors.Errorf("current version %s not support revoking token", this.AuthVersion())
+	}
+	header := http.Header{}
+	header.Add(api.AUTH_TOKEN_HEADER, authToken)
+	header.Add(api.AUTH_SUBJECT_TOKEN_HEADER, token)
+	_, _, err := this.jsonRequest(context.Background(), this.authUrl, "", "DELETE", "/auth/tokens", header, nil)
+	if err != nil {
+		return errors.Wrap(err, "revoke token")
+	}
+	return nil
+}
+
+// FetchRevocationEvents fetches the unexpired token revocation events revoked since the time
+func (this *Client) FetchRevocationEvents(adminToken string, since time.Time) ([]api.SRevocationEvent, error) {
+	if this.AuthVersion() != "v3" {
+		return nil, errors.Errorf("current version %s not support token revocation events", this.AuthVersion())
+	}
+	header := http.Header{}
+	header.Add(api.AUTH_TOKEN_HEADER, adminToken)
+	eventsUrl := "/auth/revocations"
+	if !since.IsZero() {
+		params := jsonutils.NewDict()
+		params.Add(jsonutils.NewString(timeutils.FullIsoTime(since)), "since")
+		eventsUrl += "?" + params.QueryString()
+	}
+	_, rbody, err := this.jsonRequest(context.Background(), this.authUrl, "", "GET", eventsUrl, header, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetch revocation events")
+	}
+	output := api.RevocationEventListOutput{}
+	err = rbody.Unmarshal(&output)
+	if err != nil {
+		return nil, errors.Wrap(err, "unmarshal revocation events")
+	}
+	return output.Events, nil
+}
+
 func (this *Client) SetTenant(tenantId, tenantName, tenantDomain string, token TokenCredential) (TokenCredential, error) {
 	return this.SetProject(tenantId, tenantName, tenantDomain, token)
 }
@@ -0,0 +1,26 @@
+package modules
+
+import "yunion.io/x/onecloud/pkg/mcclient/modulebase"
+
+type SAlertSilenceManager struct {
+	*modulebase.ResourceManager
+}
+
+var (
+	AlertSilenceManager *SAlertSilenceManager
+)
+
+func init() {
+	AlertSilenceManager = NewAlertSilenceManager()
+	register(AlertSilenceManager)
+}
+
+func NewAlertSilenceManager() *SAlertSilenceManager {
+	man := NewMonitorV2Manager("alertsilence", "alertsilences",
+		[]string{"id", "name", "enabled", "active", "alert_id", "res_id", "project_id", "metric",
+			"start_time", "end_time", "cycle_type", "week_days", "hour", "minute", "duration", "comment", "created_by"},
+		[]string{"matchers"})
+	return &SAlertSilenceManager{
+		ResourceManager: &man,
+	}
+}
@@ -15,8 +15,10 @@
 package modules
 
 import (
+	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"math/big"
 	"net/url"
 	"strings"
 	"time"
@@ -30,6 +32,8 @@ import (
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/mcclient/modulebase"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+	"yunion.io/x/onecloud/pkg/util/webauthn"
 )
 
 type SCredentialManager struct {
@@ -43,6 +47,11 @@ const (
 	TOTP_TYPE             = api.TOTP_TYPE
 	RECOVERY_SECRETS_TYPE = api.RECOVERY_SECRETS_TYPE
 	OIDC_CREDENTIAL_TYPE  = api.OIDC_CREDENTIAL_TYPE
+
+	WEBAUTHN_CREDENTIAL_TYPE = api.WEBAUTHN_CREDENTIAL_TYPE
+	WEBAUTHN_RECOVERY_TYPE   = api.WEBAUTHN_RECOVERY_TYPE
+
+	WEBAUTHN_RECOVERY_CODE_COUNT = 10
 )
 
 type STotpSecret struct {
@@ -67,6 +76,17 @@ type SRecoverySecretSet struct {
 	Timestamp int64
 }
 
+// WebAuthn安全密钥，每个用户可以注册多个
+type SWebAuthnCredential struct {
+	Id        string    `json:"-"`
+	Name      string    `json:"-"`
+	CreatedAt time.Time `json:"-"`
+	webauthn.SCredential
+	LastUsedAt int64 `json:"last_used_at"`
+}
+
+type SWebAuthnRecoveryCodes = api.SWebAuthnRecoveryCodes
+
 type SOpenIDConnectCredential struct {
 	ClientId string `json:"client_id"`
 	// Secret      string `json:"secret"`
@@ -406,6 +426,199 @@ func (manager *SCredentialManager) RemoveOIDCSecrets(s *mcclient.ClientSession,
 	return manager.removeCredentials(s, OIDC_CREDENTIAL_TYPE, uid, pid)
 }
 
+func (manager *SCredentialManager) FetchWebAuthnCredentials(s *mcclient.ClientSession, uid string) ([]jsonutils.JSONObject, error) {
+	return manager.fetchCredentials(s, WEBAUTHN_CREDENTIAL_TYPE, uid, "")
+}
+
+func DecodeWebAuthnCredential(secret jsonutils.JSONObject) (SWebAuthnCredential, error) {
+	curr := SWebAuthnCredential{}
+	blobStr, err := secret.GetString("blob")
+	if err != nil {
+		return curr, errors.Wrap(err, "secret.GetString")
+	}
+	blobJson, err := jsonutils.ParseString(blobStr)
+	if err != nil {
+		return curr, errors.Wrap(err, "jsonutils.ParseString")
+	}
+	err = blobJson.Unmarshal(&curr)
+	if err != nil {
+		return curr, errors.Wrap(err, "blobJson.Unmarshal")
+	}
+	curr.Id, err = secret.GetString("id")
+	if err != nil {
+		return curr, errors.Wrap(err, "secret.GetString('id')")
+	}
+	curr.Name, _ = secret.GetString("name")
+	curr.CreatedAt, _ = secret.GetTime("created_at")
+	return curr, nil
+}
+
+func (manager *SCredentialManager) GetWebAuthnCredentials(s *mcclient.ClientSession, uid string) ([]SWebAuthnCredential, error) {
+	secrets, err := manager.FetchWebAuthnCredentials(s, uid)
+	if err != nil {
+		return nil, err
+	}
+	creds := make([]SWebAuthnCredential, 0)
+	for i := range secrets {
+		curr, err := DecodeWebAuthnCredential(secrets[i])
+		if err != nil {
+			return nil, errors.Wrap(err, "DecodeWebAuthnCredential")
+		}
+		creds = append(creds, curr)
+	}
+	return creds, nil
+}
+
+func (manager *SCredentialManager) CreateWebAuthnCredential(s *mcclient.ClientSession, uid string, name string, cred webauthn.SCredential) (SWebAuthnCredential, error) {
+	webauthnCred := SWebAuthnCredential{
+		SCredential: cred,
+	}
+	blobJson := jsonutils.Marshal(&webauthnCred)
+	if len(name) == 0 {
+		name = fmt.Sprintf("webauthn-%s-%d", uid, time.Now().Unix())
+	}
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.NewString(DEFAULT_PROJECT), "project_id")
+	params.Add(jsonutils.NewString(WEBAUTHN_CREDENTIAL_TYPE), "type")
+	params.Add(jsonutils.NewString(uid), "user_id")
+	params.Add(jsonutils.NewString(blobJson.String()), "blob")
+	params.Add(jsonutils.NewString(name), "name")
+	result, err := manager.Create(s, params)
+	if err != nil {
+		return webauthnCred, err
+	}
+	webauthnCred.Id, _ = result.GetString("id")
+	webauthnCred.Name, _ = result.GetString("name")
+	webauthnCred.CreatedAt, _ = result.GetTime("created_at")
+	return webauthnCred, nil
+}
+
+// 更新签名计数器和最近使用时间
+func (manager *SCredentialManager) UpdateWebAuthnCredential(s *mcclient.ClientSession, cred SWebAuthnCredential) error {
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.NewString(jsonutils.Marshal(&cred).String()), "blob")
+	_, err := manager.Update(s, cred.Id, params)
+	return err
+}
+
+func (manager *SCredentialManager) RemoveWebAuthnCredentials(s *mcclient.ClientSession, uid string) error {
+	return manager.removeCredentials(s, WEBAUTHN_CREDENTIAL_TYPE, uid, "")
+}
+
+const webauthnRecoveryCodeChars = "23456789abcdefghjkmnpqrstuvwxyz"
+
+func newWebAuthnRecoveryCode() (string, error) {
+	code := make([]byte, 0, 9)
+	max := big.NewInt(int64(len(webauthnRecoveryCodeChars)))
+	for i := 0; i < 8; i++ {
+		if i == 4 {
+			code = append(code, '-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", errors.Wrap(err, "rand.Int")
+		}
+		code = append(code, webauthnRecoveryCodeChars[n.Int64()])
+	}
+	return string(code), nil
+}
+
+func (manager *SCredentialManager) getWebAuthnRecoveryCodes(s *mcclient.ClientSession, uid string) (SWebAuthnRecoveryCodes, error) {
+	latest := SWebAuthnRecoveryCodes{}
+	secrets, err := manager.fetchCredentials(s, WEBAUTHN_RECOVERY_TYPE, uid, "")
+	if err != nil {
+		return latest, err
+	}
+	find := false
+	for i := range secrets {
+		blobStr, _ := secrets[i].GetString("blob")
+		blobJson, _ := jsonutils.ParseString(blobStr)
+		if blobJson != nil {
+			curr := SWebAuthnRecoveryCodes{}
+			blobJson.Unmarshal(&curr)
+			if latest.Timestamp == 0 || curr.Timestamp > latest.Timestamp {
+				latest = curr
+				find = true
+			}
+		}
+	}
+	if !find {
+		return latest, httperrors.NewNotFoundError("no webauthn recovery codes for %s", uid)
+	}
+	return latest, nil
+}
+
+func (manager *SCredentialManager) saveWebAuthnRecoveryCodes(s *mcclient.ClientSession, uid string, codes SWebAuthnRecoveryCodes) error {
+	err := manager.RemoveWebAuthnRecoveryCodes(s, uid)
+	if err != nil {
+		return err
+	}
+	if len(codes.Codes) == 0 {
+		return nil
+	}
+	blobJson := jsonutils.Marshal(&codes)
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.NewString(DEFAULT_PROJECT), "project_id")
+	params.Add(jsonutils.NewString(WEBAUTHN_RECOVERY_TYPE), "type")
+	params.Add(jsonutils.NewString(uid), "user_id")
+	params.Add(jsonutils.NewString(blobJson.String()), "blob")
+	_, err = manager.Create(s, params)
+	return err
+}
+
+// 重新生成WebAuthn恢复码，旧的恢复码失效。返回的明文恢复码只能查看一次
+func (manager *SCredentialManager) ResetWebAuthnRecoveryCodes(s *mcclient.ClientSession, uid string) ([]string, error) {
+	codes := make([]string, 0, WEBAUTHN_RECOVERY_CODE_COUNT)
+	recovery := SWebAuthnRecoveryCodes{
+		Timestamp: time.Now().Unix(),
+	}
+	for i := 0; i < WEBAUTHN_RECOVERY_CODE_COUNT; i++ {
+		code, err := newWebAuthnRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		recovery.Codes = append(recovery.Codes, api.HashWebAuthnRecoveryCode(code))
+	}
+	err := manager.saveWebAuthnRecoveryCodes(s, uid, recovery)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (manager *SCredentialManager) GetWebAuthnRecoveryCodeCount(s *mcclient.ClientSession, uid string) (int, error) {
+	recovery, err := manager.getWebAuthnRecoveryCodes(s, uid)
+	if err != nil {
+		if httputils.ErrorCode(err) == 404 {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return len(recovery.Codes), nil
+}
+
+// 校验并消耗一个WebAuthn恢复码，返回剩余的恢复码数量
+func (manager *SCredentialManager) ConsumeWebAuthnRecoveryCode(s *mcclient.ClientSession, uid string, code string) (int, error) {
+	input := api.UserConsumeWebAuthnRecoveryCodeInput{
+		Code: code,
+	}
+	result, err := UsersV3.PerformAction(s, uid, "consume-webauthn-recovery-code", jsonutils.Marshal(input))
+	if err != nil {
+		return 0, err
+	}
+	output := api.UserConsumeWebAuthnRecoveryCodeOutput{}
+	err = result.Unmarshal(&output)
+	if err != nil {
+		return 0, errors.Wrap(err, "Unmarshal")
+	}
+	return output.RecoveryCodesLeft, nil
+}
+
+func (manager *SCredentialManager) RemoveWebAuthnRecoveryCodes(s *mcclient.ClientSession, uid string) error {
+	return manager.removeCredentials(s, WEBAUTHN_RECOVERY_TYPE, uid, "")
+}
+
 var (
 	Credentials SCredentialManager
 )
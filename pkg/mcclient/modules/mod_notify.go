@@ -21,11 +21,13 @@ type ConfigsManager struct {
 }
 
 var (
-	NotifyReceiver modulebase.ResourceManager
-	NotifyConfig   modulebase.ResourceManager
-	Notification   modulebase.ResourceManager
-	NotifyTemplate modulebase.ResourceManager
-	Configs        ConfigsManager
+	NotifyReceiver   modulebase.ResourceManager
+	NotifyConfig     modulebase.ResourceManager
+	Notification     modulebase.ResourceManager
+	NotifyTemplate   modulebase.ResourceManager
+	NotifyTopic      modulebase.ResourceManager
+	NotifySubscriber modulebase.ResourceManager
+	Configs          ConfigsManager
 )
 
 func init() {
@@ -60,4 +62,20 @@ func init() {
 		[]string{},
 	)
 	register(&NotifyTemplate)
+
+	NotifyTopic = NewNotifyv2Manager(
+		"topic",
+		"topics",
+		[]string{"ID", "Name", "Enabled", "Resources", "Actions"},
+		[]string{},
+	)
+	register(&NotifyTopic)
+
+	NotifySubscriber = NewNotifyv2Manager(
+		"subscriber",
+		"subscribers",
+		[]string{"ID", "Name", "Enabled", "Topic_Id", "Topic", "Type", "Receiver", "Robot", "Scope", "Project_Domain", "Tenant"},
+		[]string{},
+	)
+	register(&NotifySubscriber)
 }
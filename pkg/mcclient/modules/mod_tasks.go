@@ -20,6 +20,7 @@ import (
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
 
+	"yunion.io/x/onecloud/pkg/apis"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/mcclient/modulebase"
 )
@@ -42,7 +43,7 @@ func init() {
 	ComputeTasks = ComputeTasksManager{
 		ResourceManager: NewComputeManager("task", "tasks",
 			[]string{},
-			[]string{"Id", "Obj_name", "Obj_Id", "Task_name", "Stage", "Created_at"}),
+			[]string{"Id", "Obj_name", "Obj_Id", "Task_name", "Stage", "Progress", "Created_at"}),
 	}
 	registerCompute(&ComputeTasks)
 }
@@ -90,3 +91,12 @@ func (man ComputeTasksManager) TaskFailed3(session *mcclient.ClientSession, task
 	params.Add(jsonutils.NewString(reason), "__reason__")
 	man.TaskComplete(session, taskId, params)
 }
+
+func (man ComputeTasksManager) SetProgress(session *mcclient.ClientSession, taskId string, progress float32, message string) error {
+	input := apis.TaskSetProgressInput{
+		Progress: progress,
+		Message:  message,
+	}
+	_, err := man.PerformAction(session, taskId, "set-progress", jsonutils.Marshal(input))
+	return err
+}
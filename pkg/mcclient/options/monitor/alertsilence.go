@@ -0,0 +1,146 @@
+package monitor
+
+import (
+	"fmt"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/util/timeutils"
+
+	"yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/mcclient/options"
+)
+
+type AlertSilenceCreateOptions struct {
+	NAME      string   `help:"Name of silence"`
+	AlertId   string   `help:"ID or name of the silenced alert"`
+	ResId     string   `help:"ID of the silenced resource, e.g. host or server id"`
+	ProjectId string   `help:"ID of the project of silenced resources"`
+	Metric    string   `help:"Silenced metric, e.g. cpu or cpu.usage_active"`
+	Matcher   []string `help:"Tag matcher, e.g. host=node1, host!=node1, host=~node.* or host!~node.*"`
+	StartTime string   `help:"Start time of the silence, default is now, e.g. 2021-01-01 00:00:00"`
+	EndTime   string   `help:"End time of the silence, e.g. 2021-01-02 00:00:00"`
+	CycleType string   `help:"Recurring cycle of the silence window" choices:"day|week"`
+	WeekDays  []int    `help:"Days of the week of the window, 1: Monday, 7: Sunday"`
+	Hour      int      `help:"Start hour of the window, 0-23"`
+	Minute    int      `help:"Start minute of the window, 0-59"`
+	Duration  int      `help:"Length of the window in minutes"`
+	Comment   string   `help:"Reason of the silence"`
+}
+
+func (o *AlertSilenceCreateOptions) Params() (jsonutils.JSONObject, error) {
+	input := monitor.AlertSilenceCreateInput{
+		AlertId:   o.AlertId,
+		ResId:     o.ResId,
+		ProjectId: o.ProjectId,
+		Metric:    o.Metric,
+		CycleType: o.CycleType,
+		WeekDays:  o.WeekDays,
+		Hour:      o.Hour,
+		Minute:    o.Minute,
+		Duration:  o.Duration,
+		Comment:   o.Comment,
+	}
+	input.Name = o.NAME
+	for _, m := range o.Matcher {
+		matcher, err := parseAlertSilenceMatcher(m)
+		if err != nil {
+			return nil, err
+		}
+		input.Matchers = append(input.Matchers, matcher)
+	}
+	var err error
+	if len(o.StartTime) > 0 {
+		input.StartTime, err = timeutils.ParseTimeStr(o.StartTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start time %q: %v", o.StartTime, err)
+		}
+	}
+	if len(o.EndTime) > 0 {
+		input.EndTime, err = timeutils.ParseTimeStr(o.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end time %q: %v", o.EndTime, err)
+		}
+	}
+	return jsonutils.Marshal(input), nil
+}
+
+func parseAlertSilenceMatcher(str string) (monitor.AlertSilenceMatcher, error) {
+	// longer operators first
+	for _, op := range []string{
+		monitor.AlertSilenceMatcherOpNotEqual,
+		monitor.AlertSilenceMatcherOpRegex,
+		monitor.AlertSilenceMatcherOpNotRegex,
+		monitor.AlertSilenceMatcherOpEqual,
+	} {
+		if idx := strings.Index(str, op); idx > 0 {
+			return monitor.AlertSilenceMatcher{
+				Key:      strings.TrimSpace(str[:idx]),
+				Operator: op,
+				Value:    strings.TrimSpace(str[idx+len(op):]),
+			}, nil
+		}
+	}
+	return monitor.AlertSilenceMatcher{}, fmt.Errorf("invalid matcher %q", str)
+}
+
+type AlertSilenceListOptions struct {
+	options.BaseListOptions
+
+	AlertId   string `help:"ID of alert"`
+	ResId     string `help:"ID of resource"`
+	ProjectId string `help:"ID of project"`
+}
+
+func (o *AlertSilenceListOptions) Params() (jsonutils.JSONObject, error) {
+	return options.ListStructToParams(o)
+}
+
+type AlertSilenceShowOptions struct {
+	ID string `help:"ID or name of silence" json:"-"`
+}
+
+func (o *AlertSilenceShowOptions) Params() (jsonutils.JSONObject, error) {
+	return options.StructToParams(o)
+}
+
+func (o *AlertSilenceShowOptions) GetId() string {
+	return o.ID
+}
+
+type AlertSilenceUpdateOptions struct {
+	ID      string `help:"ID or name of silence" json:"-"`
+	EndTime string `help:"End time of the silence, e.g. 2021-01-02 00:00:00" json:"-"`
+	Comment string `help:"Reason of the silence"`
+}
+
+func (o *AlertSilenceUpdateOptions) Params() (jsonutils.JSONObject, error) {
+	params, err := options.StructToParams(o)
+	if err != nil {
+		return nil, err
+	}
+	if len(o.EndTime) > 0 {
+		endTime, err := timeutils.ParseTimeStr(o.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end time %q: %v", o.EndTime, err)
+		}
+		params.Set("end_time", jsonutils.NewTimeString(endTime))
+	}
+	return params, nil
+}
+
+func (o *AlertSilenceUpdateOptions) GetId() string {
+	return o.ID
+}
+
+type AlertSilenceDeleteOptions struct {
+	ID string `help:"ID or name of silence" json:"-"`
+}
+
+func (o *AlertSilenceDeleteOptions) GetId() string {
+	return o.ID
+}
+
+func (o *AlertSilenceDeleteOptions) Params() (jsonutils.JSONObject, error) {
+	return options.StructToParams(o)
+}
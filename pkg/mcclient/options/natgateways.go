@@ -21,6 +21,13 @@ type NatGatewayListOptions struct {
 	BaseListOptions
 }
 
+type NatGatewayCreateOptions struct {
+	NAME    string `help:"Name of the nat gateway"`
+	VPC     string `help:"Vpc id or name" json:"vpc_id"`
+	EIP     string `help:"Eip id or name bound to the nat gateway" json:"eip"`
+	NatSpec string `help:"Nat gateway spec"`
+}
+
 type NatDTableListOptions struct {
 	Natgateway string `help:"Natgateway name or id"`
 
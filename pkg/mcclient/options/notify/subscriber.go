@@ -0,0 +1,43 @@
+package notify
+
+import (
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient/options"
+)
+
+type SubscriberListOptions struct {
+	options.BaseListOptions
+	TopicId  string `help:"id or name of topic"`
+	Type     string `help:"subscriber type" choices:"receiver|robot|webhook"`
+	Receiver string `help:"id or name of receiver"`
+}
+
+func (sl *SubscriberListOptions) Params() (jsonutils.JSONObject, error) {
+	return options.ListStructToParams(sl)
+}
+
+type SubscriberCreateOptions struct {
+	NAME     string `help:"name of subscriber"`
+	TOPIC    string `help:"id or name of topic" json:"topic_id"`
+	TYPE     string `help:"subscriber type" choices:"receiver|robot|webhook"`
+	Receiver string `help:"id or name of receiver, required if type is receiver"`
+	Robot    string `help:"robot contact type, required if type is robot" choices:"feishu-robot|dingtalk-robot|workwx-robot"`
+	Scope    string `help:"scope of subscriber" choices:"system|domain|project"`
+}
+
+func (sc *SubscriberCreateOptions) Params() (jsonutils.JSONObject, error) {
+	return options.StructToParams(sc)
+}
+
+type SubscriberOptions struct {
+	ID string `help:"Id or Name of subscriber"`
+}
+
+func (s *SubscriberOptions) GetId() string {
+	return s.ID
+}
+
+func (s *SubscriberOptions) Params() (jsonutils.JSONObject, error) {
+	return nil, nil
+}
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient/options"
+)
+
+type TopicListOptions struct {
+	options.BaseListOptions
+	Resource string `help:"list topics contain the resource type" choices:"server|disk|cloudaccount|alert"`
+	Action   string `help:"list topics contain the action" choices:"create|delete|failed|trigger"`
+}
+
+func (tl *TopicListOptions) Params() (jsonutils.JSONObject, error) {
+	return options.ListStructToParams(tl)
+}
+
+type TopicCreateOptions struct {
+	NAME      string   `help:"name of topic"`
+	Resources []string `help:"resource types of the events" choices:"server|disk|cloudaccount|alert"`
+	Actions   []string `help:"actions of the events" choices:"create|delete|failed|trigger"`
+}
+
+func (tc *TopicCreateOptions) Params() (jsonutils.JSONObject, error) {
+	return options.StructToParams(tc)
+}
+
+type TopicOptions struct {
+	ID string `help:"Id or Name of topic"`
+}
+
+func (t *TopicOptions) GetId() string {
+	return t.ID
+}
+
+func (t *TopicOptions) Params() (jsonutils.JSONObject, error) {
+	return nil, nil
+}
+
+type TopicUpdateOptions struct {
+	TopicOptions
+	topicUpdateOptions
+}
+
+type topicUpdateOptions struct {
+	Resources []string `help:"resource types of the events" choices:"server|disk|cloudaccount|alert"`
+	Actions   []string `help:"actions of the events" choices:"create|delete|failed|trigger"`
+}
+
+func (tu *TopicUpdateOptions) Params() (jsonutils.JSONObject, error) {
+	return jsonutils.Marshal(tu.topicUpdateOptions), nil
+}
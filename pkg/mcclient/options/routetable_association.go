@@ -37,3 +37,13 @@ func (opts *RouteTableAssociationIdOptions) GetId() string {
 func (opts *RouteTableAssociationIdOptions) Params() (jsonutils.JSONObject, error) {
 	return nil, nil
 }
+
+type RouteTableAssociationCreateOptions struct {
+	ROUTETABLE string `help:"route table id or name" json:"route_table_id"`
+	NETWORK    string `help:"network id or name to associate with" json:"associated_resource_id"`
+	Name       string
+}
+
+func (opts *RouteTableAssociationCreateOptions) Params() (jsonutils.JSONObject, error) {
+	return jsonutils.Marshal(opts).(*jsonutils.JSONDict), nil
+}
@@ -0,0 +1,240 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+const (
+	pollInterval = 5 * time.Second
+)
+
+type SApplyOptions struct {
+	// timeout of waiting for each resource to be ready or deleted
+	Timeout time.Duration
+}
+
+// Apply executes the plan, resources are deleted first and then created or
+// updated in dependency order, each of them is waited to be ready before the
+// resources depending on it are handled
+func Apply(s *mcclient.ClientSession, plan *SPlan, opts SApplyOptions) error {
+	ids := make(map[string]string)
+	for _, change := range plan.Changes {
+		if change.Live != nil && change.Action != ActionReplace {
+			ids[change.Key] = change.Live.Id
+		}
+	}
+	for _, change := range plan.Deletions {
+		log.Infof("%s: deleting %s", change.Key, change.Live.Id)
+		err := deleteResource(s, change.Live, opts.Timeout)
+		if err != nil {
+			return errors.Wrapf(err, "delete %s", change.Key)
+		}
+	}
+	for _, change := range plan.Changes {
+		switch change.Action {
+		case ActionCreate, ActionReplace:
+			log.Infof("%s: creating", change.Key)
+			id, err := createResource(s, plan.Stack.Name, change.Resource, ids, opts.Timeout)
+			if err != nil {
+				return errors.Wrapf(err, "create %s", change.Key)
+			}
+			ids[change.Key] = id
+		case ActionUpdate:
+			log.Infof("%s: updating %s", change.Key, change.Live.Id)
+			err := updateResource(s, change.Resource, change.Live, ids, opts.Timeout)
+			if err != nil {
+				return errors.Wrapf(err, "update %s", change.Key)
+			}
+		}
+	}
+	return nil
+}
+
+func resolveSpec(res *SResource, ids map[string]string) (*jsonutils.JSONDict, error) {
+	spec, err := resolveRefs(res.getSpec(), ids)
+	if err != nil {
+		return nil, err
+	}
+	return spec.(*jsonutils.JSONDict), nil
+}
+
+func createResource(s *mcclient.ClientSession, stackName string, res *SResource, ids map[string]string, timeout time.Duration) (string, error) {
+	kind, err := GetKind(res.Kind)
+	if err != nil {
+		return "", err
+	}
+	spec, err := resolveSpec(res, ids)
+	if err != nil {
+		return "", err
+	}
+	params := kind.createParams(spec)
+	params.Set("name", jsonutils.NewString(res.Name))
+	meta := make(map[string]string)
+	params.Unmarshal(&meta, "__meta__")
+	createHash, updateHash := res.hashes(kind)
+	meta[STACK_TAG] = stackName
+	meta[STACK_RESOURCE_TAG] = res.Key()
+	meta[STACK_CREATE_HASH_TAG] = createHash
+	meta[STACK_UPDATE_HASH_TAG] = updateHash
+	params.Set("__meta__", jsonutils.Marshal(meta))
+
+	obj, err := kind.Manager.Create(s, params)
+	if err != nil {
+		return "", err
+	}
+	id, err := obj.GetString("id")
+	if err != nil {
+		return "", errors.Wrap(err, "get id")
+	}
+	_, err = waitStatus(s, kind, id, timeout)
+	if err != nil {
+		return id, err
+	}
+	if kind.postCreate != nil {
+		err = kind.postCreate(s, kind, id, spec, timeout)
+		if err != nil {
+			return id, err
+		}
+	}
+	return id, nil
+}
+
+func updateResource(s *mcclient.ClientSession, res *SResource, live *SLiveResource, ids map[string]string, timeout time.Duration) error {
+	kind, err := GetKind(res.Kind)
+	if err != nil {
+		return err
+	}
+	spec, err := resolveSpec(res, ids)
+	if err != nil {
+		return err
+	}
+	// refresh the resource, it may be changed by the deletions before,
+	// e.g. an eip dissociated from the server replaced
+	obj, err := kind.Manager.Get(s, live.Id, nil)
+	if err != nil {
+		return err
+	}
+	live = newLiveResource(live.Kind, obj)
+	err = updateDescription(s, kind, live, spec)
+	if err != nil {
+		return err
+	}
+	if kind.update != nil {
+		err = kind.update(s, kind, live, spec, timeout)
+		if err != nil {
+			return err
+		}
+	}
+	_, updateHash := res.hashes(kind)
+	meta := jsonutils.NewDict()
+	meta.Set(STACK_UPDATE_HASH_TAG, jsonutils.NewString(updateHash))
+	_, err = kind.Manager.PerformAction(s, live.Id, "metadata", meta)
+	if err != nil {
+		return errors.Wrap(err, "set metadata")
+	}
+	return nil
+}
+
+func deleteResource(s *mcclient.ClientSession, live *SLiveResource, timeout time.Duration) error {
+	kind, err := GetKind(live.Kind)
+	if err != nil {
+		return err
+	}
+	if kind.preDelete != nil {
+		// refresh the resource, it may be changed by the deletions before
+		obj, err := kind.Manager.Get(s, live.Id, nil)
+		if err != nil {
+			if httputils.ErrorCode(err) == 404 {
+				return nil
+			}
+			return err
+		}
+		live = newLiveResource(live.Kind, obj)
+		err = kind.preDelete(s, kind, live, timeout)
+		if err != nil {
+			return err
+		}
+	}
+	params := jsonutils.NewDict()
+	params.Set("override_pending_delete", jsonutils.JSONTrue)
+	_, err = kind.Manager.DeleteWithParam(s, live.Id, params, nil)
+	if err != nil {
+		if httputils.ErrorCode(err) == 404 {
+			return nil
+		}
+		return err
+	}
+	return waitDeleted(s, kind, live.Id, timeout)
+}
+
+func isFailedStatus(status string) bool {
+	return strings.Contains(status, "fail")
+}
+
+func waitStatus(s *mcclient.ClientSession, kind *SKind, id string, timeout time.Duration) (jsonutils.JSONObject, error) {
+	expire := time.Now().Add(timeout)
+	for {
+		obj, err := kind.Manager.Get(s, id, nil)
+		if err != nil {
+			return nil, err
+		}
+		status, _ := obj.GetString("status")
+		if utils.IsInStringArray(status, kind.ReadyStatus) {
+			return obj, nil
+		}
+		if isFailedStatus(status) {
+			return nil, errors.Errorf("%s %s is in status %s", kind.Kind, id, status)
+		}
+		if time.Now().After(expire) {
+			return nil, errors.Wrapf(errors.ErrTimeout, "wait %s %s ready, current status %s", kind.Kind, id, status)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+func waitDeleted(s *mcclient.ClientSession, kind *SKind, id string, timeout time.Duration) error {
+	expire := time.Now().Add(timeout)
+	for {
+		obj, err := kind.Manager.Get(s, id, nil)
+		if err != nil {
+			if httputils.ErrorCode(err) == 404 {
+				return nil
+			}
+			return err
+		}
+		status, _ := obj.GetString("status")
+		if jsonutils.QueryBoolean(obj, "pending_deleted", false) || jsonutils.QueryBoolean(obj, "deleted", false) {
+			return nil
+		}
+		if isFailedStatus(status) {
+			return errors.Errorf("%s %s is in status %s", kind.Kind, id, status)
+		}
+		if time.Now().After(expire) {
+			return errors.Wrapf(errors.ErrTimeout, "wait %s %s deleted, current status %s", kind.Kind, id, status)
+		}
+		time.Sleep(pollInterval)
+	}
+}
@@ -0,0 +1,315 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"sort"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/modulebase"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+)
+
+// SKind describes how the resources of a kind are managed
+type SKind struct {
+	Kind    string
+	Manager modulebase.Manager
+
+	// spec fields updated in place, changes of the other fields replace the resource
+	UpdateFields []string
+	// spec fields handled by the hooks instead of being sent as create params
+	ExtraFields []string
+	// statuses in which the resource is ready to use
+	ReadyStatus []string
+	// resources of lower rank are created earlier and deleted later when the
+	// dependencies are unknown, e.g. resources removed from the stack file
+	Rank int
+
+	// called after the resource is ready, e.g. attach to a server
+	postCreate func(s *mcclient.ClientSession, kind *SKind, id string, spec *jsonutils.JSONDict, timeout time.Duration) error
+	// update the fields changed, defaults to update description
+	update func(s *mcclient.ClientSession, kind *SKind, live *SLiveResource, spec *jsonutils.JSONDict, timeout time.Duration) error
+	// called before the resource is deleted, e.g. detach from a server
+	preDelete func(s *mcclient.ClientSession, kind *SKind, live *SLiveResource, timeout time.Duration) error
+}
+
+var kinds = make(map[string]*SKind)
+
+func registerKind(kind *SKind) {
+	if len(kind.ReadyStatus) == 0 {
+		kind.ReadyStatus = []string{"ready"}
+	}
+	kind.UpdateFields = append(kind.UpdateFields, "description")
+	kinds[kind.Kind] = kind
+}
+
+func GetKind(kind string) (*SKind, error) {
+	k, ok := kinds[kind]
+	if !ok {
+		return nil, errors.Wrapf(ErrInvalidStack, "unsupported kind %q, supported kinds: %v", kind, GetKinds())
+	}
+	return k, nil
+}
+
+func GetKinds() []string {
+	ret := make([]string, 0, len(kinds))
+	for k := range kinds {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func init() {
+	registerKind(&SKind{
+		Kind:        "network",
+		Manager:     &modules.Networks,
+		ReadyStatus: []string{api.NETWORK_STATUS_AVAILABLE},
+		Rank:        1,
+	})
+	registerKind(&SKind{
+		Kind:        "secgroup",
+		Manager:     &modules.SecGroups,
+		ReadyStatus: []string{api.SECGROUP_STATUS_READY},
+		Rank:        1,
+	})
+	registerKind(&SKind{
+		Kind:         "server",
+		Manager:      &modules.Servers,
+		UpdateFields: []string{"vcpu_count", "vmem_size", "secgroups"},
+		ReadyStatus:  []string{api.VM_RUNNING, api.VM_READY},
+		Rank:         2,
+		update:       updateServer,
+	})
+	registerKind(&SKind{
+		Kind:         "disk",
+		Manager:      &modules.Disks,
+		UpdateFields: []string{"size"},
+		ExtraFields:  []string{"server"},
+		ReadyStatus:  []string{api.DISK_READY},
+		Rank:         3,
+		postCreate:   attachDisk,
+		update:       updateDisk,
+		preDelete:    detachDisk,
+	})
+	registerKind(&SKind{
+		Kind:         "eip",
+		Manager:      &modules.Elasticips,
+		UpdateFields: []string{"bandwidth", "server"},
+		ExtraFields:  []string{"server"},
+		ReadyStatus:  []string{api.EIP_STATUS_READY},
+		Rank:         3,
+		postCreate:   associateEip,
+		update:       updateEip,
+		preDelete:    dissociateEip,
+	})
+	lbStatus := []string{api.LB_STATUS_ENABLED, api.LB_STATUS_DISABLED}
+	registerKind(&SKind{
+		Kind:        "loadbalancer",
+		Manager:     &modules.Loadbalancers,
+		ReadyStatus: lbStatus,
+		Rank:        2,
+	})
+	registerKind(&SKind{
+		Kind:        "loadbalancer_backendgroup",
+		Manager:     &modules.LoadbalancerBackendGroups,
+		ReadyStatus: lbStatus,
+		Rank:        3,
+	})
+	registerKind(&SKind{
+		Kind:        "loadbalancer_backend",
+		Manager:     &modules.LoadbalancerBackends,
+		ReadyStatus: lbStatus,
+		Rank:        4,
+	})
+	registerKind(&SKind{
+		Kind:        "loadbalancer_listener",
+		Manager:     &modules.LoadbalancerListeners,
+		ReadyStatus: lbStatus,
+		Rank:        4,
+	})
+}
+
+// isUpdateFields reports whether all of the fields are updated in place
+func (kind *SKind) isUpdateFields(fields []string) bool {
+	for _, field := range fields {
+		if !utils.IsInStringArray(field, kind.UpdateFields) {
+			return false
+		}
+	}
+	return true
+}
+
+func (kind *SKind) createParams(spec *jsonutils.JSONDict) *jsonutils.JSONDict {
+	return spec.CopyExcludes(kind.ExtraFields...)
+}
+
+func isFieldChanged(spec *jsonutils.JSONDict, live jsonutils.JSONObject, field string, liveField string) bool {
+	if !spec.Contains(field) {
+		return false
+	}
+	val, _ := spec.Get(field)
+	liveVal, _ := live.Get(liveField)
+	return liveVal == nil || val.String() != liveVal.String()
+}
+
+func updateDescription(s *mcclient.ClientSession, kind *SKind, live *SLiveResource, spec *jsonutils.JSONDict) error {
+	if !isFieldChanged(spec, live.Object, "description", "description") {
+		return nil
+	}
+	params := spec.CopyIncludes("description")
+	_, err := kind.Manager.Update(s, live.Id, params)
+	if err != nil {
+		return errors.Wrap(err, "update description")
+	}
+	return nil
+}
+
+func updateServer(s *mcclient.ClientSession, kind *SKind, live *SLiveResource, spec *jsonutils.JSONDict, timeout time.Duration) error {
+	params := jsonutils.NewDict()
+	for _, field := range []string{"vcpu_count", "vmem_size"} {
+		if isFieldChanged(spec, live.Object, field, field) {
+			val, _ := spec.Get(field)
+			params.Set(field, val)
+		}
+	}
+	if params.Length() > 0 {
+		_, err := kind.Manager.PerformAction(s, live.Id, "change-config", params)
+		if err != nil {
+			return errors.Wrap(err, "change-config")
+		}
+		_, err = waitStatus(s, kind, live.Id, timeout)
+		if err != nil {
+			return err
+		}
+	}
+	if spec.Contains("secgroups") {
+		secgroups := make([]string, 0)
+		spec.Unmarshal(&secgroups, "secgroups")
+		params := jsonutils.NewDict()
+		params.Set("secgroup_ids", jsonutils.NewStringArray(secgroups))
+		_, err := kind.Manager.PerformAction(s, live.Id, "set-secgroup", params)
+		if err != nil {
+			return errors.Wrap(err, "set-secgroup")
+		}
+	}
+	return nil
+}
+
+func attachDisk(s *mcclient.ClientSession, kind *SKind, id string, spec *jsonutils.JSONDict, timeout time.Duration) error {
+	serverId, _ := spec.GetString("server")
+	if len(serverId) == 0 {
+		return nil
+	}
+	params := jsonutils.NewDict()
+	params.Set("disk_id", jsonutils.NewString(id))
+	_, err := modules.Servers.PerformAction(s, serverId, "attachdisk", params)
+	if err != nil {
+		return errors.Wrapf(err, "attach disk to server %s", serverId)
+	}
+	_, err = waitStatus(s, kind, id, timeout)
+	return err
+}
+
+func updateDisk(s *mcclient.ClientSession, kind *SKind, live *SLiveResource, spec *jsonutils.JSONDict, timeout time.Duration) error {
+	if !isFieldChanged(spec, live.Object, "size", "disk_size") {
+		return nil
+	}
+	params := spec.CopyIncludes("size")
+	_, err := kind.Manager.PerformAction(s, live.Id, "resize", params)
+	if err != nil {
+		return errors.Wrap(err, "resize")
+	}
+	_, err = waitStatus(s, kind, live.Id, timeout)
+	return err
+}
+
+func detachDisk(s *mcclient.ClientSession, kind *SKind, live *SLiveResource, timeout time.Duration) error {
+	guests := make([]api.SimpleGuest, 0)
+	live.Object.Unmarshal(&guests, "guests")
+	for _, guest := range guests {
+		params := jsonutils.NewDict()
+		params.Set("disk_id", jsonutils.NewString(live.Id))
+		_, err := modules.Servers.PerformAction(s, guest.Id, "detachdisk", params)
+		if err != nil {
+			return errors.Wrapf(err, "detach disk from server %s", guest.Name)
+		}
+	}
+	if len(guests) > 0 {
+		_, err := waitStatus(s, kind, live.Id, timeout)
+		return err
+	}
+	return nil
+}
+
+func associateEip(s *mcclient.ClientSession, kind *SKind, id string, spec *jsonutils.JSONDict, timeout time.Duration) error {
+	serverId, _ := spec.GetString("server")
+	if len(serverId) == 0 {
+		return nil
+	}
+	params := jsonutils.NewDict()
+	params.Set("instance_id", jsonutils.NewString(serverId))
+	params.Set("instance_type", jsonutils.NewString(api.EIP_ASSOCIATE_TYPE_SERVER))
+	_, err := kind.Manager.PerformAction(s, id, "associate", params)
+	if err != nil {
+		return errors.Wrapf(err, "associate eip with server %s", serverId)
+	}
+	_, err = waitStatus(s, kind, id, timeout)
+	return err
+}
+
+func updateEip(s *mcclient.ClientSession, kind *SKind, live *SLiveResource, spec *jsonutils.JSONDict, timeout time.Duration) error {
+	if isFieldChanged(spec, live.Object, "bandwidth", "bandwidth") {
+		_, err := kind.Manager.PerformAction(s, live.Id, "change-bandwidth", spec.CopyIncludes("bandwidth"))
+		if err != nil {
+			return errors.Wrap(err, "change-bandwidth")
+		}
+		_, err = waitStatus(s, kind, live.Id, timeout)
+		if err != nil {
+			return err
+		}
+	}
+	serverId, _ := spec.GetString("server")
+	associateId, _ := live.Object.GetString("associate_id")
+	if serverId == associateId {
+		return nil
+	}
+	if len(associateId) > 0 {
+		err := dissociateEip(s, kind, live, timeout)
+		if err != nil {
+			return err
+		}
+	}
+	return associateEip(s, kind, live.Id, spec, timeout)
+}
+
+func dissociateEip(s *mcclient.ClientSession, kind *SKind, live *SLiveResource, timeout time.Duration) error {
+	associateId, _ := live.Object.GetString("associate_id")
+	if len(associateId) == 0 {
+		return nil
+	}
+	_, err := kind.Manager.PerformAction(s, live.Id, "dissociate", nil)
+	if err != nil {
+		return errors.Wrap(err, "dissociate")
+	}
+	_, err = waitStatus(s, kind, live.Id, timeout)
+	return err
+}
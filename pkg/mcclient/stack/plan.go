@@ -0,0 +1,242 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+type TAction string
+
+const (
+	ActionCreate  = TAction("create")
+	ActionUpdate  = TAction("update")
+	ActionReplace = TAction("replace")
+	ActionDelete  = TAction("delete")
+	ActionNoop    = TAction("noop")
+)
+
+// SLiveResource is a resource tagged with the stack
+type SLiveResource struct {
+	Kind string
+	Name string
+	Id   string
+
+	Status     string
+	CreateHash string
+	UpdateHash string
+
+	Object jsonutils.JSONObject
+}
+
+func (live *SLiveResource) Key() string {
+	return resourceKey(live.Kind, live.Name)
+}
+
+type SChange struct {
+	Action TAction
+	Key    string
+
+	// nil for resources removed from the stack file
+	Resource *SResource
+	// nil for resources to be created
+	Live *SLiveResource
+
+	// why the resource is replaced or updated
+	Reason string
+}
+
+type SPlan struct {
+	Stack *SStack
+	// resources removed from the stack file and the old resources of the
+	// replacements, dependents are deleted before the resources they depend on
+	Deletions []*SChange
+	// resources of the stack file in dependency order
+	Changes []*SChange
+}
+
+func newLiveResource(kind string, obj jsonutils.JSONObject) *SLiveResource {
+	live := &SLiveResource{Kind: kind, Object: obj}
+	live.Id, _ = obj.GetString("id")
+	live.Status, _ = obj.GetString("status")
+	resKey, _ := obj.GetString("metadata", STACK_RESOURCE_TAG)
+	live.Name = strings.TrimPrefix(resKey, kind+".")
+	live.CreateHash, _ = obj.GetString("metadata", STACK_CREATE_HASH_TAG)
+	live.UpdateHash, _ = obj.GetString("metadata", STACK_UPDATE_HASH_TAG)
+	return live
+}
+
+// FetchLiveResources lists the resources of all kinds tagged with the stack
+func FetchLiveResources(s *mcclient.ClientSession, stackName string) ([]*SLiveResource, error) {
+	ret := make([]*SLiveResource, 0)
+	for _, k := range GetKinds() {
+		kind := kinds[k]
+		params := jsonutils.NewDict()
+		params.Set("tags.0.key", jsonutils.NewString(STACK_TAG))
+		params.Set("tags.0.value", jsonutils.NewString(stackName))
+		params.Set("with_meta", jsonutils.JSONTrue)
+		params.Set("limit", jsonutils.NewInt(0))
+		result, err := kind.Manager.List(s, params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "list %s", kind.Manager.KeyString())
+		}
+		for i := range result.Data {
+			ret = append(ret, newLiveResource(k, result.Data[i]))
+		}
+	}
+	return ret, nil
+}
+
+func Plan(s *mcclient.ClientSession, stack *SStack) (*SPlan, error) {
+	lives, err := FetchLiveResources(s, stack.Name)
+	if err != nil {
+		return nil, err
+	}
+	return computePlan(stack, lives)
+}
+
+// PlanDestroy plans the deletion of all of the resources of the stack, the
+// stack file is optional and only used to order the deletions
+func PlanDestroy(s *mcclient.ClientSession, stack *SStack) (*SPlan, error) {
+	lives, err := FetchLiveResources(s, stack.Name)
+	if err != nil {
+		return nil, err
+	}
+	return computePlan(&SStack{Name: stack.Name}, lives, stack)
+}
+
+func computePlan(stack *SStack, lives []*SLiveResource, orderBy ...*SStack) (*SPlan, error) {
+	sorted, err := stack.SortedResources()
+	if err != nil {
+		return nil, err
+	}
+	liveMap := make(map[string]*SLiveResource)
+	for _, live := range lives {
+		liveMap[live.Key()] = live
+	}
+
+	changes := make(map[string]*SChange)
+	creates := make([]*SChange, 0)
+	for _, res := range sorted {
+		kind, _ := GetKind(res.Kind)
+		change := &SChange{Action: ActionNoop, Key: res.Key(), Resource: res}
+		live, ok := liveMap[res.Key()]
+		if !ok {
+			change.Action = ActionCreate
+		} else {
+			change.Live = live
+			createHash, updateHash := res.hashes(kind)
+			if createHash != live.CreateHash {
+				change.Action = ActionReplace
+				change.Reason = "spec changed"
+			} else {
+				if updateHash != live.UpdateHash {
+					change.Action = ActionUpdate
+				}
+				// the references to the resources recreated are changed, the
+				// resource is replaced only if they can not be updated in place
+				for _, dep := range res.Dependencies() {
+					if depChange := changes[dep]; depChange.Action != ActionCreate && depChange.Action != ActionReplace {
+						continue
+					}
+					fields := res.referenceFields(dep)
+					if len(fields) == 0 {
+						// depends_on only orders the resources
+						continue
+					}
+					change.Reason = fmt.Sprintf("%s is recreated", dep)
+					if !kind.isUpdateFields(fields) {
+						change.Action = ActionReplace
+						break
+					}
+					change.Action = ActionUpdate
+				}
+			}
+		}
+		changes[res.Key()] = change
+		creates = append(creates, change)
+	}
+
+	deletes := make([]*SChange, 0)
+	for _, live := range lives {
+		if _, ok := changes[live.Key()]; !ok {
+			deletes = append(deletes, &SChange{Action: ActionDelete, Key: live.Key(), Live: live})
+		}
+	}
+	for _, change := range creates {
+		if change.Action == ActionReplace {
+			deletes = append(deletes, change)
+		}
+	}
+	order := make(map[string]int)
+	for _, s := range append(orderBy, stack) {
+		if resources, err := s.SortedResources(); err == nil {
+			for i, res := range resources {
+				if _, ok := order[res.Key()]; !ok {
+					order[res.Key()] = i
+				}
+			}
+		}
+	}
+	// resources of unknown dependencies are deleted first by the rank of the
+	// kinds, the others in reverse dependency order
+	sort.SliceStable(deletes, func(i, j int) bool {
+		oi, iok := order[deletes[i].Key]
+		oj, jok := order[deletes[j].Key]
+		if iok != jok {
+			return !iok
+		}
+		if iok {
+			return oi > oj
+		}
+		return kinds[deletes[i].Live.Kind].Rank > kinds[deletes[j].Live.Kind].Rank
+	})
+
+	return &SPlan{Stack: stack, Deletions: deletes, Changes: creates}, nil
+}
+
+// HasChanges reports whether the plan changes anything
+func (plan *SPlan) HasChanges() bool {
+	if len(plan.Deletions) > 0 {
+		return true
+	}
+	for _, change := range plan.Changes {
+		if change.Action != ActionNoop {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary counts the resources to be created, updated, replaced and deleted
+func (plan *SPlan) Summary() map[TAction]int {
+	ret := make(map[TAction]int)
+	for _, change := range plan.Deletions {
+		if change.Action == ActionDelete {
+			ret[ActionDelete] += 1
+		}
+	}
+	for _, change := range plan.Changes {
+		ret[change.Action] += 1
+	}
+	return ret
+}
@@ -0,0 +1,277 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+)
+
+const (
+	// tags marking the resources managed by a stack, so that no state file is needed
+	STACK_TAG             = "user:climc-stack"
+	STACK_RESOURCE_TAG    = "user:climc-stack-resource"
+	STACK_CREATE_HASH_TAG = "user:climc-stack-create-hash"
+	STACK_UPDATE_HASH_TAG = "user:climc-stack-update-hash"
+
+	ErrInvalidStack = errors.Error("InvalidStackError")
+)
+
+var (
+	// ${<kind>.<name>} is replaced with the id of the resource
+	refPattern  = regexp.MustCompile(`\$\{([a-z_]+)\.([A-Za-z0-9_-]+)\}`)
+	namePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+)
+
+// SStack describes a set of resources, e.g.
+//
+//	name: web
+//	resources:
+//	- kind: network
+//	  name: web-net
+//	  spec:
+//	    vpc: default
+//	    guest_ip_start: 192.168.10.2
+//	    guest_ip_end: 192.168.10.254
+//	    guest_ip_mask: 24
+//	- kind: server
+//	  name: web-1
+//	  spec:
+//	    vcpu_count: 2
+//	    vmem_size: 2048
+//	    disks:
+//	    - image_id: centos-7
+//	    nets:
+//	    - network: ${network.web-net}
+type SStack struct {
+	Name      string
+	Resources []SResource
+}
+
+type SResource struct {
+	Kind string
+	Name string
+	// keys of the resources depended on besides the ones referenced in spec
+	DependsOn []string
+	// create params of the resource
+	Spec *jsonutils.JSONDict
+}
+
+func resourceKey(kind, name string) string {
+	return fmt.Sprintf("%s.%s", kind, name)
+}
+
+func (res *SResource) Key() string {
+	return resourceKey(res.Kind, res.Name)
+}
+
+func (res *SResource) getSpec() *jsonutils.JSONDict {
+	if res.Spec == nil {
+		return jsonutils.NewDict()
+	}
+	return res.Spec
+}
+
+// Dependencies returns the keys of the resources depended on
+func (res *SResource) Dependencies() []string {
+	deps := make([]string, 0)
+	for _, dep := range res.DependsOn {
+		if !utils.IsInStringArray(dep, deps) {
+			deps = append(deps, dep)
+		}
+	}
+	for _, match := range refPattern.FindAllStringSubmatch(res.getSpec().String(), -1) {
+		dep := resourceKey(match[1], match[2])
+		if !utils.IsInStringArray(dep, deps) {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+// referenceFields returns the spec fields referencing the resource of the key
+func (res *SResource) referenceFields(key string) []string {
+	fields := make([]string, 0)
+	specMap, _ := res.getSpec().GetMap()
+	for field, val := range specMap {
+		for _, match := range refPattern.FindAllStringSubmatch(val.String(), -1) {
+			if resourceKey(match[1], match[2]) == key {
+				fields = append(fields, field)
+				break
+			}
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func hashSpec(spec *jsonutils.JSONDict) string {
+	sum := sha256.Sum256([]byte(spec.String()))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// hashes of the spec fields which replace the resource on change and
+// of the fields which are updated in place
+func (res *SResource) hashes(kind *SKind) (string, string) {
+	spec := res.getSpec()
+	return hashSpec(spec.CopyExcludes(kind.UpdateFields...)), hashSpec(spec.CopyIncludes(kind.UpdateFields...))
+}
+
+func ParseStack(content string) (*SStack, error) {
+	obj, err := jsonutils.ParseYAML(content)
+	if err != nil {
+		return nil, errors.Wrap(err, "ParseYAML")
+	}
+	stack := &SStack{}
+	err = obj.Unmarshal(stack)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unmarshal")
+	}
+	err = stack.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return stack, nil
+}
+
+func (stack *SStack) Validate() error {
+	if !namePattern.MatchString(stack.Name) {
+		return errors.Wrapf(ErrInvalidStack, "invalid stack name %q", stack.Name)
+	}
+	keys := make(map[string]bool)
+	for i := range stack.Resources {
+		res := &stack.Resources[i]
+		if _, err := GetKind(res.Kind); err != nil {
+			return err
+		}
+		if !namePattern.MatchString(res.Name) {
+			return errors.Wrapf(ErrInvalidStack, "invalid name %q of %s", res.Name, res.Kind)
+		}
+		if keys[res.Key()] {
+			return errors.Wrapf(ErrInvalidStack, "duplicate resource %s", res.Key())
+		}
+		keys[res.Key()] = true
+	}
+	for i := range stack.Resources {
+		for _, dep := range stack.Resources[i].Dependencies() {
+			if !keys[dep] {
+				return errors.Wrapf(ErrInvalidStack, "%s depends on undefined resource %s", stack.Resources[i].Key(), dep)
+			}
+		}
+	}
+	_, err := stack.SortedResources()
+	return err
+}
+
+func (stack *SStack) GetResource(key string) *SResource {
+	for i := range stack.Resources {
+		if stack.Resources[i].Key() == key {
+			return &stack.Resources[i]
+		}
+	}
+	return nil
+}
+
+// SortedResources returns the resources in dependency order, resources are
+// kept in the order of the stack file if possible
+func (stack *SStack) SortedResources() ([]*SResource, error) {
+	sorted := make([]*SResource, 0, len(stack.Resources))
+	done := make(map[string]bool)
+	for len(sorted) < len(stack.Resources) {
+		progress := false
+		for i := range stack.Resources {
+			res := &stack.Resources[i]
+			if done[res.Key()] {
+				continue
+			}
+			ready := true
+			for _, dep := range res.Dependencies() {
+				if !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				sorted = append(sorted, res)
+				done[res.Key()] = true
+				progress = true
+				break
+			}
+		}
+		if !progress {
+			pending := make([]string, 0)
+			for i := range stack.Resources {
+				if !done[stack.Resources[i].Key()] {
+					pending = append(pending, stack.Resources[i].Key())
+				}
+			}
+			return nil, errors.Wrapf(ErrInvalidStack, "circular dependencies among %s", strings.Join(pending, ", "))
+		}
+	}
+	return sorted, nil
+}
+
+// resolveRefs replaces the references in the object with the ids of the resources
+func resolveRefs(obj jsonutils.JSONObject, ids map[string]string) (jsonutils.JSONObject, error) {
+	switch val := obj.(type) {
+	case *jsonutils.JSONDict:
+		ret := jsonutils.NewDict()
+		objMap, _ := val.GetMap()
+		for k, v := range objMap {
+			nv, err := resolveRefs(v, ids)
+			if err != nil {
+				return nil, err
+			}
+			ret.Set(k, nv)
+		}
+		return ret, nil
+	case *jsonutils.JSONArray:
+		ret := jsonutils.NewArray()
+		objs, _ := val.GetArray()
+		for i := range objs {
+			nv, err := resolveRefs(objs[i], ids)
+			if err != nil {
+				return nil, err
+			}
+			ret.Add(nv)
+		}
+		return ret, nil
+	case *jsonutils.JSONString:
+		str, _ := val.GetString()
+		var err error
+		str = refPattern.ReplaceAllStringFunc(str, func(ref string) string {
+			match := refPattern.FindStringSubmatch(ref)
+			id, ok := ids[resourceKey(match[1], match[2])]
+			if !ok {
+				err = errors.Wrapf(errors.ErrNotFound, "unresolved reference %s", ref)
+			}
+			return id
+		})
+		if err != nil {
+			return nil, err
+		}
+		return jsonutils.NewString(str), nil
+	default:
+		return obj, nil
+	}
+}
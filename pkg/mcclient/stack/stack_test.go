@@ -0,0 +1,236 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"testing"
+
+	"yunion.io/x/jsonutils"
+)
+
+const testStack = `
+name: web
+resources:
+- kind: server
+  name: web-1
+  spec:
+    vcpu_count: 2
+    vmem_size: 2048
+    nets:
+    - network: ${network.web-net}
+    secgroups: ["${secgroup.web-sg}"]
+- kind: eip
+  name: web-eip
+  spec:
+    bandwidth: 10
+    server: ${server.web-1}
+- kind: network
+  name: web-net
+  spec:
+    vpc: default
+    guest_ip_start: 192.168.10.2
+    guest_ip_end: 192.168.10.254
+    guest_ip_mask: 24
+- kind: secgroup
+  name: web-sg
+  depends_on: [network.web-net]
+`
+
+func TestParseStack(t *testing.T) {
+	stack, err := ParseStack(testStack)
+	if err != nil {
+		t.Fatalf("ParseStack: %s", err)
+	}
+	sorted, err := stack.SortedResources()
+	if err != nil {
+		t.Fatalf("SortedResources: %s", err)
+	}
+	keys := make([]string, 0)
+	for _, res := range sorted {
+		keys = append(keys, res.Key())
+	}
+	want := []string{"network.web-net", "secgroup.web-sg", "server.web-1", "eip.web-eip"}
+	if jsonutils.Marshal(keys).String() != jsonutils.Marshal(want).String() {
+		t.Errorf("want order %s got %s", want, keys)
+	}
+
+	cases := []string{
+		"name: web\nresources:\n- kind: unknown\n  name: a\n",
+		"name: web\nresources:\n- kind: network\n  name: a\n- kind: network\n  name: a\n",
+		"name: web\nresources:\n- kind: network\n  name: a\n  depends_on: [network.b]\n",
+		"name: web\nresources:\n- kind: network\n  name: a\n  depends_on: [network.b]\n- kind: network\n  name: b\n  spec: {vpc: '${network.a}'}\n",
+		"name: 'web stack'\n",
+	}
+	for _, c := range cases {
+		if _, err := ParseStack(c); err == nil {
+			t.Errorf("expect error for stack %q", c)
+		}
+	}
+}
+
+func TestResolveRefs(t *testing.T) {
+	stack, _ := ParseStack(testStack)
+	spec, err := resolveSpec(stack.GetResource("server.web-1"), map[string]string{
+		"network.web-net": "net-id",
+		"secgroup.web-sg": "sg-id",
+	})
+	if err != nil {
+		t.Fatalf("resolveSpec: %s", err)
+	}
+	nets, _ := spec.GetArray("nets")
+	if len(nets) != 1 {
+		t.Fatalf("want 1 net got %d", len(nets))
+	}
+	if net, _ := nets[0].GetString("network"); net != "net-id" {
+		t.Errorf("want network net-id got %s", net)
+	}
+	if sgs, _ := spec.GetArray("secgroups"); len(sgs) != 1 || sgs[0].String() != `"sg-id"` {
+		t.Errorf("want secgroup sg-id got %s", sgs)
+	}
+	if vcpu, _ := spec.Int("vcpu_count"); vcpu != 2 {
+		t.Errorf("want vcpu_count 2 got %d", vcpu)
+	}
+	_, err = resolveSpec(stack.GetResource("eip.web-eip"), map[string]string{})
+	if err == nil {
+		t.Errorf("expect unresolved reference error")
+	}
+}
+
+func newTestLive(stack *SStack, key string, id string) *SLiveResource {
+	res := stack.GetResource(key)
+	kind, _ := GetKind(res.Kind)
+	createHash, updateHash := res.hashes(kind)
+	return &SLiveResource{
+		Kind:       res.Kind,
+		Name:       res.Name,
+		Id:         id,
+		CreateHash: createHash,
+		UpdateHash: updateHash,
+		Object:     jsonutils.NewDict(),
+	}
+}
+
+func TestComputePlan(t *testing.T) {
+	stack, _ := ParseStack(testStack)
+	lives := []*SLiveResource{
+		newTestLive(stack, "network.web-net", "net-id"),
+		newTestLive(stack, "secgroup.web-sg", "sg-id"),
+		newTestLive(stack, "server.web-1", "server-id"),
+		newTestLive(stack, "eip.web-eip", "eip-id"),
+		{Kind: "disk", Name: "old-data", Id: "disk-id", Object: jsonutils.NewDict()},
+	}
+
+	plan, err := computePlan(stack, lives)
+	if err != nil {
+		t.Fatalf("computePlan: %s", err)
+	}
+	if len(plan.Deletions) != 1 || plan.Deletions[0].Key != "disk.old-data" {
+		t.Errorf("expect to delete disk.old-data only")
+	}
+	for _, change := range plan.Changes {
+		if change.Action != ActionNoop {
+			t.Errorf("expect %s unchanged, got %s", change.Key, change.Action)
+		}
+	}
+
+	// updatable field
+	stack.GetResource("eip.web-eip").Spec.Set("bandwidth", jsonutils.NewInt(20))
+	// field forcing replacement
+	stack.GetResource("network.web-net").Spec.Set("guest_ip_end", jsonutils.NewString("192.168.10.200"))
+	plan, err = computePlan(stack, lives[:4])
+	if err != nil {
+		t.Fatalf("computePlan: %s", err)
+	}
+	// the server references the network in nets which can not be updated, the
+	// eip references the server in server which is updated in place, and the
+	// secgroup only depends on the network
+	want := map[string]TAction{
+		"network.web-net": ActionReplace,
+		"secgroup.web-sg": ActionNoop,
+		"server.web-1":    ActionReplace,
+		"eip.web-eip":     ActionUpdate,
+	}
+	for _, change := range plan.Changes {
+		if change.Action != want[change.Key] {
+			t.Errorf("%s: want %s got %s", change.Key, want[change.Key], change.Action)
+		}
+	}
+	deletions := make([]string, 0)
+	for _, change := range plan.Deletions {
+		deletions = append(deletions, change.Key)
+	}
+	wantDeletions := []string{"server.web-1", "network.web-net"}
+	if jsonutils.Marshal(deletions).String() != jsonutils.Marshal(wantDeletions).String() {
+		t.Errorf("want deletions %s got %s", wantDeletions, deletions)
+	}
+
+	// the secgroup referenced by the updatable secgroups of the server is recreated
+	stack, _ = ParseStack(testStack)
+	stack.GetResource("secgroup.web-sg").Spec = jsonutils.NewDict()
+	stack.GetResource("secgroup.web-sg").Spec.Set("description", jsonutils.NewString("web"))
+	stack.GetResource("secgroup.web-sg").Spec.Set("is_public", jsonutils.JSONTrue)
+	plan, err = computePlan(stack, lives[:4])
+	if err != nil {
+		t.Fatalf("computePlan: %s", err)
+	}
+	want = map[string]TAction{
+		"network.web-net": ActionNoop,
+		"secgroup.web-sg": ActionReplace,
+		"server.web-1":    ActionUpdate,
+		"eip.web-eip":     ActionNoop,
+	}
+	for _, change := range plan.Changes {
+		if change.Action != want[change.Key] {
+			t.Errorf("%s: want %s got %s", change.Key, want[change.Key], change.Action)
+		}
+	}
+	if len(plan.Deletions) != 1 || plan.Deletions[0].Key != "secgroup.web-sg" {
+		t.Errorf("expect to delete secgroup.web-sg only")
+	}
+
+	// only the updatable field is changed
+	stack, _ = ParseStack(testStack)
+	stack.GetResource("eip.web-eip").Spec.Set("bandwidth", jsonutils.NewInt(20))
+	plan, err = computePlan(stack, lives[:4])
+	if err != nil {
+		t.Fatalf("computePlan: %s", err)
+	}
+	summary := plan.Summary()
+	if summary[ActionUpdate] != 1 || summary[ActionNoop] != 3 || len(plan.Deletions) != 0 {
+		t.Errorf("unexpected summary %v", summary)
+	}
+}
+
+func TestComputeDestroyPlan(t *testing.T) {
+	stack, _ := ParseStack(testStack)
+	lives := []*SLiveResource{
+		newTestLive(stack, "network.web-net", "net-id"),
+		newTestLive(stack, "eip.web-eip", "eip-id"),
+		newTestLive(stack, "server.web-1", "server-id"),
+		newTestLive(stack, "secgroup.web-sg", "sg-id"),
+	}
+	for _, orderBy := range [][]*SStack{{stack}, nil} {
+		plan, err := computePlan(&SStack{Name: stack.Name}, lives, orderBy...)
+		if err != nil {
+			t.Fatalf("computePlan: %s", err)
+		}
+		if len(plan.Deletions) != 4 || len(plan.Changes) != 0 {
+			t.Fatalf("expect to delete all resources")
+		}
+		if plan.Deletions[0].Key != "eip.web-eip" || plan.Deletions[3].Live.Kind == "server" {
+			t.Errorf("unexpected deletion order")
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/pkg/gotypes"
 
+	api "yunion.io/x/onecloud/pkg/apis/identity"
 	"yunion.io/x/onecloud/pkg/util/rbacutils"
 )
 
@@ -95,3 +96,9 @@ type TokenCredential interface {
 	GetLoginSource() string
 	GetLoginIp() string
 }
+
+// IApplicationCredentialToken is implemented by tokens which may be issued for an application credential
+type IApplicationCredentialToken interface {
+	GetApplicationCredentialId() string
+	GetAccessRules() api.SAccessRules
+}
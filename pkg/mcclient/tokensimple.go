@@ -44,6 +44,9 @@ type SSimpleToken struct {
 	Expires time.Time
 
 	Context SAuthContext
+
+	ApplicationCredentialId string           `json:",omitempty"`
+	AccessRules             api.SAccessRules `json:",omitempty"`
 }
 
 func (self *SSimpleToken) GetTokenString() string {
@@ -182,12 +185,20 @@ func (this *SSimpleToken) GetLoginIp() string {
 	return this.Context.Ip
 }
 
+func (this *SSimpleToken) GetApplicationCredentialId() string {
+	return this.ApplicationCredentialId
+}
+
+func (this *SSimpleToken) GetAccessRules() api.SAccessRules {
+	return this.AccessRules
+}
+
 func SimplifyToken(token TokenCredential) TokenCredential {
 	simToken, ok := token.(*SSimpleToken)
 	if ok {
 		return simToken
 	}
-	return &SSimpleToken{Token: token.GetTokenString(),
+	simToken = &SSimpleToken{Token: token.GetTokenString(),
 		Domain:    token.GetDomainName(),
 		DomainId:  token.GetDomainId(),
 		User:      token.GetUserName(),
@@ -206,6 +217,11 @@ func SimplifyToken(token TokenCredential) TokenCredential {
 			Ip:     token.GetLoginIp(),
 		},
 	}
+	if appCredToken, ok := token.(IApplicationCredentialToken); ok {
+		simToken.ApplicationCredentialId = appCredToken.GetApplicationCredentialId()
+		simToken.AccessRules = appCredToken.GetAccessRules()
+	}
+	return simToken
 }
 
 func (self *SSimpleToken) GetCatalogData(serviceTypes []string, region string) jsonutils.JSONObject {
@@ -229,6 +229,8 @@ func (c *NoDataQueryCondition) createEvalMatchTagFromHostJson(evalContext *alert
 	evalMatch.Tags["ip"] = ip
 	evalMatch.Tags[HOST_TAG_NAME] = name
 	evalMatch.Tags[HOST_TAG_BRAND] = brand
+	evalMatch.ResId, _ = host.GetString("id")
+	evalMatch.ProjectId, _ = host.GetString("tenant_id")
 
 	switch evalContext.Rule.RuleDescription[0].ResType {
 	case monitor.METRIC_RES_TYPE_GUEST:
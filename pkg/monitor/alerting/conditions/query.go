@@ -214,6 +214,12 @@ func (c *QueryCondition) NewEvalMatch(context *alerting.EvalContext, series tsdb
 	}
 	//evalMatch.Condition = c.GenerateFormatCond(meta, queryKeyInfo).String()
 	evalMatch.Tags = c.filterTags(series.Tags, *alertDetails)
+	resIdTag := monitor.MEASUREMENT_TAG_ID[alertDetails.ResType]
+	if len(resIdTag) == 0 {
+		resIdTag = "host_id"
+	}
+	evalMatch.ResId = series.Tags[resIdTag]
+	evalMatch.ProjectId = series.Tags["tenant_id"]
 	evalMatch.Value = value
 	evalMatch.ValueStr = alerting.RationalizeValueFromUnit(*value, alertDetails.FieldDescription.Unit,
 		alertDetails.FieldOpt)
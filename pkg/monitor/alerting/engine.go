@@ -24,6 +24,7 @@ import (
 	"golang.org/x/xerrors"
 
 	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
 
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
 	"yunion.io/x/onecloud/pkg/monitor/options"
@@ -40,6 +41,7 @@ type AlertEngine struct {
 	evalHandler   evalHandler
 	ruleReader    ruleReader
 	resultHandler resultHandler
+	sharder       sharder
 }
 
 func init() {
@@ -58,7 +60,16 @@ func (e *AlertEngine) Init() error {
 	e.execQueue = make(chan *Job, 1000)
 	e.Scheduler = newScheduler()
 	e.evalHandler = NewEvalHandler()
-	e.ruleReader = newRuleReader()
+	if len(options.Options.EtcdEndpoints) != 0 {
+		sharder, err := newEtcdSharder(&options.Options)
+		if err != nil {
+			return errors.Wrap(err, "new etcd alerting sharder")
+		}
+		e.sharder = sharder
+	} else {
+		e.sharder = newLocalSharder()
+	}
+	e.ruleReader = newRuleReader(e.sharder)
 	e.resultHandler = newResultHandler()
 	return nil
 }
@@ -66,6 +77,7 @@ func (e *AlertEngine) Init() error {
 // Run starts the alerting service background process.
 func (e *AlertEngine) Run(ctx context.Context) error {
 	alertGroup, ctx := errgroup.WithContext(ctx)
+	alertGroup.Go(func() error { return e.sharder.run(ctx) })
 	alertGroup.Go(func() error { return e.alertingTicker(ctx) })
 	alertGroup.Go(func() error { return e.runJobDispatcher(ctx) })
 
@@ -95,6 +107,9 @@ func (e *AlertEngine) alertingTicker(ctx context.Context) error {
 
 			e.Scheduler.Tick(tick, e.execQueue)
 			tickIndex++
+		case <-e.sharder.changed():
+			// shard members changed, take over or release rules at once
+			e.Scheduler.Update(e.ruleReader.fetch())
 		}
 	}
 }
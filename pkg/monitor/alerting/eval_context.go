@@ -46,6 +46,9 @@ type EvalContext struct {
 
 	NoDataFound    bool
 	PrevAlertState monitor.AlertStateType
+	// Silenced is true if all the matches are muted by alert silences,
+	// the alert record is still created but no notification is sent
+	Silenced bool
 
 	Ctx      context.Context
 	UserCred mcclient.TokenCredential
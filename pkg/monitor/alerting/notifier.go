@@ -25,8 +25,11 @@ import (
 
 	"yunion.io/x/onecloud/pkg/apis"
 	"yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/apis/notify"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/notifyclient"
 	"yunion.io/x/onecloud/pkg/mcclient"
+	npk "yunion.io/x/onecloud/pkg/mcclient/modules/notify"
 	"yunion.io/x/onecloud/pkg/monitor/models"
 	"yunion.io/x/onecloud/pkg/monitor/notifydrivers"
 )
@@ -48,9 +51,26 @@ func (n *notificationService) SendIfNeeded(evalCtx *EvalContext) error {
 		return nil
 	}
 
+	if evalCtx.Silenced {
+		log.Infof("alert %s(%s) is silenced, skip sending notifications", evalCtx.Rule.Name, evalCtx.Rule.Id)
+		return nil
+	}
+	evalCtx.EvalMatches = filterSilencedMatches(evalCtx.EvalMatches)
+	evalCtx.AlertOkEvalMatches = filterSilencedMatches(evalCtx.AlertOkEvalMatches)
+
 	return n.sendNotifications(evalCtx, notifierStates)
 }
 
+func filterSilencedMatches(matches []*monitor.EvalMatch) []*monitor.EvalMatch {
+	ret := make([]*monitor.EvalMatch, 0, len(matches))
+	for _, match := range matches {
+		if !match.Silenced {
+			ret = append(ret, match)
+		}
+	}
+	return ret
+}
+
 type notifierState struct {
 	notifier Notifier
 	state    *models.SAlertnotification
@@ -167,6 +187,7 @@ func (n *notificationService) createAlertRecordWhenNotify(evalCtx *EvalContext)
 		State:     string(evalCtx.Rule.State),
 		EvalData:  matches,
 		AlertRule: newAlertRecordRule(evalCtx),
+		Silenced:  evalCtx.Silenced,
 	}
 	recordCreateInput.ResType = recordCreateInput.AlertRule.ResType
 	createData := recordCreateInput.JSON(recordCreateInput)
@@ -188,6 +209,14 @@ func (n *notificationService) createAlertRecordWhenNotify(evalCtx *EvalContext)
 		}
 	}
 	record.PostCreate(evalCtx.Ctx, evalCtx.UserCred, evalCtx.UserCred, nil, createData)
+	if evalCtx.Firing {
+		notifyclient.EventNotify(evalCtx.Ctx, evalCtx.UserCred, notifyclient.SEventNotifyParam{
+			Obj:          record,
+			ResourceType: notify.TOPIC_RESOURCE_ALERT,
+			Action:       notifyclient.ActionTrigger,
+			Priority:     npk.NotifyPriorityImportant,
+		})
+	}
 }
 
 func (n *notificationService) detachAlertResourceWhenNodata(evalCtx *EvalContext) {
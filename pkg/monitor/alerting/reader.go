@@ -28,10 +28,13 @@ type ruleReader interface {
 
 type defaultRuleReader struct {
 	sync.RWMutex
+	sharder sharder
 }
 
-func newRuleReader() *defaultRuleReader {
-	ruleReader := &defaultRuleReader{}
+func newRuleReader(sharder sharder) *defaultRuleReader {
+	ruleReader := &defaultRuleReader{
+		sharder: sharder,
+	}
 	return ruleReader
 }
 
@@ -43,6 +46,9 @@ func (arr *defaultRuleReader) fetch() []*Rule {
 	}
 	res := make([]*Rule, 0)
 	for _, alert := range alerts {
+		if !arr.sharder.owns(alert.GetId()) {
+			continue
+		}
 		obj, err := NewRuleFromDBAlert(&alert)
 		if err != nil {
 			log.Errorf("Build alert rule %s from db error: %v", alert.GetId(), err)
@@ -21,6 +21,7 @@ import (
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
 
+	"yunion.io/x/onecloud/pkg/apis/monitor"
 	"yunion.io/x/onecloud/pkg/monitor/models"
 )
 
@@ -77,8 +78,54 @@ func (handler *defaultResultHandler) handle(evalCtx *EvalContext) error {
 		// TODO: save opslog
 	}
 
+	handler.applySilences(evalCtx)
+
 	if err := handler.notifier.SendIfNeeded(evalCtx); err != nil {
 		return err
 	}
 	return nil
 }
+
+// applySilences flags the matches muted by the active alert silences
+func (handler *defaultResultHandler) applySilences(evalCtx *EvalContext) {
+	if evalCtx.IsTestRun {
+		return
+	}
+	silences, err := models.AlertSilenceManager.GetActiveSilences(time.Now())
+	if err != nil {
+		log.Errorf("get active alert silences error: %v", err)
+		return
+	}
+	if len(silences) == 0 {
+		return
+	}
+	matches := evalCtx.EvalMatches
+	if !evalCtx.Firing {
+		matches = evalCtx.AlertOkEvalMatches
+	}
+	silencedCount := 0
+	for _, match := range matches {
+		for i := range silences {
+			if silences[i].Match(evalCtx.Rule.Id, match) {
+				match.Silenced = true
+				silencedCount++
+				break
+			}
+		}
+	}
+	if len(matches) == 0 {
+		// the alert has no series to match, e.g. no data, only the
+		// silences of the alert itself apply
+		for i := range silences {
+			if silences[i].Match(evalCtx.Rule.Id, &monitor.EvalMatch{}) {
+				evalCtx.Silenced = true
+				break
+			}
+		}
+	} else {
+		evalCtx.Silenced = silencedCount == len(matches)
+	}
+	if silencedCount > 0 || evalCtx.Silenced {
+		log.Infof("alert %s(%s) silenced: %v, %d/%d matches silenced", evalCtx.Rule.Name, evalCtx.Rule.Id, evalCtx.Silenced, silencedCount, len(matches))
+	}
+}
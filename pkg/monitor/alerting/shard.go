@@ -0,0 +1,103 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"sort"
+)
+
+const (
+	// hashRingReplicas is the number of virtual nodes of each member,
+	// more virtual nodes make the rules spread more evenly.
+	hashRingReplicas = 100
+)
+
+// sharder decides which alert rules should be evaluated by this replica.
+type sharder interface {
+	// run keeps the shard membership up to date until ctx is done.
+	run(ctx context.Context) error
+	// owns returns true if the rule should be evaluated by this replica.
+	owns(ruleId string) bool
+	// changed notifies that the members of shard have changed and
+	// rules should be re-fetched.
+	changed() <-chan struct{}
+}
+
+// localSharder is used when monitor runs as single replica,
+// it evaluates all the rules.
+type localSharder struct{}
+
+func newLocalSharder() *localSharder {
+	return &localSharder{}
+}
+
+func (s *localSharder) run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (s *localSharder) owns(ruleId string) bool {
+	return true
+}
+
+func (s *localSharder) changed() <-chan struct{} {
+	return nil
+}
+
+// hashRing is a consistent hash ring, removing a member only moves
+// the keys owned by it to the others.
+type hashRing struct {
+	hashes  []uint32
+	members map[uint32]string
+}
+
+func newHashRing(members []string, replicas int) *hashRing {
+	ring := &hashRing{
+		hashes:  make([]uint32, 0, len(members)*replicas),
+		members: make(map[uint32]string, len(members)*replicas),
+	}
+	for _, member := range members {
+		for i := 0; i < replicas; i++ {
+			hash := crc32.ChecksumIEEE([]byte(fmt.Sprintf("%s#%d", member, i)))
+			if _, ok := ring.members[hash]; ok {
+				continue
+			}
+			ring.members[hash] = member
+			ring.hashes = append(ring.hashes, hash)
+		}
+	}
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+	return ring
+}
+
+func (r *hashRing) isEmpty() bool {
+	return len(r.hashes) == 0
+}
+
+// get returns the member which owns the key.
+func (r *hashRing) get(key string) string {
+	if r.isEmpty() {
+		return ""
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	idx := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= hash })
+	if idx == len(r.hashes) {
+		idx = 0
+	}
+	return r.members[r.hashes[idx]]
+}
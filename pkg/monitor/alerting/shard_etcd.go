@@ -0,0 +1,198 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/stringutils"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/etcd"
+	"yunion.io/x/onecloud/pkg/monitor/options"
+)
+
+// etcdSharder registers this replica under the members prefix of etcd with
+// a session lease, and hashes the rules to the live members. When a replica
+// dies its lease expires, the member key is removed and the rules owned by
+// it are rebalanced to the others.
+type etcdSharder struct {
+	client        *etcd.SEtcdClient
+	membersPrefix string
+	memberId      string
+	ttl           time.Duration
+
+	lock    sync.RWMutex
+	members []string
+	ring    *hashRing
+
+	changedCh chan struct{}
+}
+
+func newEtcdSharder(opt *options.AlerterOptions) (*etcdSharder, error) {
+	tlsCfg, err := opt.GetEtcdTLSConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "get etcd tls config")
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, errors.Wrap(err, "get hostname")
+	}
+	ttl := opt.AlertingShardTTLSeconds
+	if ttl <= 0 {
+		ttl = 10
+	}
+	s := &etcdSharder{
+		membersPrefix: fmt.Sprintf("%s/members/", strings.TrimSuffix(opt.AlertingShardPrefix, "/")),
+		// uuid suffix keeps the member unique when the pod is recreated with the same hostname
+		memberId:  fmt.Sprintf("%s-%s", hostname, stringutils.UUID4()),
+		ttl:       time.Duration(ttl) * time.Second,
+		changedCh: make(chan struct{}, 1),
+	}
+	s.client, err = etcd.NewEtcdClient(&etcd.SEtcdOptions{
+		EtcdEndpoint:              opt.EtcdEndpoints,
+		EtcdTimeoutSeconds:        5,
+		EtcdRequestTimeoutSeconds: 2,
+		EtcdLeaseExpireSeconds:    ttl,
+		EtcdUsername:              opt.EtcdUsername,
+		EtcdPassword:              opt.EtcdPassword,
+		EtcdEnabldSsl:             opt.EtcdUseTLS,
+		TLSConfig:                 tlsCfg,
+	}, s.onKeepaliveFailure)
+	if err != nil {
+		return nil, errors.Wrap(err, "new etcd client")
+	}
+	ctx := context.Background()
+	if err := s.register(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.refresh(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *etcdSharder) memberKey() string {
+	return s.membersPrefix + s.memberId
+}
+
+func (s *etcdSharder) register(ctx context.Context) error {
+	if err := s.client.PutSession(ctx, s.memberKey(), s.memberId); err != nil {
+		return errors.Wrapf(err, "register alerting shard member %s", s.memberKey())
+	}
+	log.Infof("Alerting shard member %s registered", s.memberId)
+	return nil
+}
+
+func (s *etcdSharder) onKeepaliveFailure() {
+	// the session is restarted by the run loop, don't exit the process
+	log.Errorf("Alerting shard member %s etcd keepalive failed", s.memberId)
+}
+
+// refresh rebuilds the hash ring from the registered members.
+func (s *etcdSharder) refresh(ctx context.Context) error {
+	kvs, err := s.client.List(ctx, s.membersPrefix)
+	if err != nil {
+		return errors.Wrap(err, "list alerting shard members")
+	}
+	members := make([]string, 0, len(kvs))
+	for _, kv := range kvs {
+		members = append(members, strings.TrimPrefix(kv.Key, s.membersPrefix))
+	}
+	sort.Strings(members)
+	s.setMembers(members)
+	return nil
+}
+
+func (s *etcdSharder) setMembers(members []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.ring != nil && strings.Join(s.members, ",") == strings.Join(members, ",") {
+		return
+	}
+	log.Infof("Alerting shard members changed: %v", members)
+	s.members = members
+	s.ring = newHashRing(members, hashRingReplicas)
+	select {
+	case s.changedCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *etcdSharder) onMembersEvent(ctx context.Context) {
+	if err := s.refresh(ctx); err != nil {
+		log.Errorf("Refresh alerting shard members: %v", err)
+	}
+}
+
+// run watches the members prefix and keeps this replica registered. When
+// etcd is unreachable the last known ring is kept: a dead replica may leave
+// some rules unevaluated and a new one may cause duplicate evaluation, but
+// alerts are not lost for all the rules owned by the live replicas.
+func (s *etcdSharder) run(ctx context.Context) error {
+	defer s.client.Close()
+
+	onCreate := func(ctx context.Context, key, value []byte) { s.onMembersEvent(ctx) }
+	onModify := func(ctx context.Context, key, oldvalue, value []byte) { s.onMembersEvent(ctx) }
+	onDelete := func(ctx context.Context, key []byte) { s.onMembersEvent(ctx) }
+	if err := s.client.Watch(ctx, s.membersPrefix, onCreate, onModify, onDelete); err != nil {
+		return errors.Wrapf(err, "watch %s", s.membersPrefix)
+	}
+
+	ticker := time.NewTicker(s.ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if !s.client.SessionLiving() {
+				if err := s.client.RestartSession(); err != nil {
+					log.Errorf("Restart alerting shard etcd session: %v", err)
+					continue
+				}
+				if err := s.register(ctx); err != nil {
+					log.Errorf("%v", err)
+					continue
+				}
+			}
+			// resync in case of missing watch events
+			if err := s.refresh(ctx); err != nil {
+				log.Errorf("Refresh alerting shard members: %v", err)
+			}
+		}
+	}
+}
+
+func (s *etcdSharder) owns(ruleId string) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.ring.isEmpty() {
+		// our own registration is gone, evaluate all the rules rather than none
+		return true
+	}
+	return s.ring.get(ruleId) == s.memberId
+}
+
+func (s *etcdSharder) changed() <-chan struct{} {
+	return s.changedCh
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerting
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashRing(t *testing.T) {
+	members := []string{"monitor-0", "monitor-1", "monitor-2"}
+	keys := make([]string, 0, 3000)
+	for i := 0; i < 3000; i++ {
+		keys = append(keys, fmt.Sprintf("alert-%d", i))
+	}
+
+	t.Run("empty ring", func(t *testing.T) {
+		ring := newHashRing(nil, hashRingReplicas)
+		assert.True(t, ring.isEmpty())
+		assert.Equal(t, "", ring.get("alert-0"))
+	})
+
+	t.Run("stable regardless of member order", func(t *testing.T) {
+		r1 := newHashRing(members, hashRingReplicas)
+		r2 := newHashRing([]string{"monitor-2", "monitor-0", "monitor-1"}, hashRingReplicas)
+		for _, key := range keys {
+			assert.Equal(t, r1.get(key), r2.get(key))
+		}
+	})
+
+	t.Run("spread over members", func(t *testing.T) {
+		ring := newHashRing(members, hashRingReplicas)
+		counts := map[string]int{}
+		for _, key := range keys {
+			counts[ring.get(key)]++
+		}
+		assert.Len(t, counts, len(members))
+		for member, cnt := range counts {
+			if cnt < len(keys)/len(members)/2 {
+				t.Errorf("member %s owns too few keys: %d", member, cnt)
+			}
+		}
+	})
+
+	t.Run("only rebalance keys of removed member", func(t *testing.T) {
+		before := newHashRing(members, hashRingReplicas)
+		after := newHashRing(members[:2], hashRingReplicas)
+		for _, key := range keys {
+			owner := before.get(key)
+			if owner == "monitor-2" {
+				assert.NotEqual(t, "monitor-2", after.get(key))
+			} else {
+				assert.Equal(t, owner, after.get(key), "key %s moved", key)
+			}
+		}
+	})
+}
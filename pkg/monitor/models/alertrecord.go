@@ -41,6 +41,8 @@ type SAlertRecord struct {
 	EvalData  jsonutils.JSONObject `list:"user" update:"user"`
 	AlertRule jsonutils.JSONObject `list:"user" update:"user"`
 	ResType   string               `width:"36" list:"user" update:"user"`
+	// Silenced is true if the notifications were muted by alert silences
+	Silenced bool `nullable:"false" default:"false" list:"user" create:"optional"`
 }
 
 func init() {
@@ -113,6 +115,13 @@ func (manager *SAlertRecordManager) ListItemFilter(
 	if len(query.ResTypes) != 0 {
 		q.Filter(sqlchemy.In(q.Field("res_type"), query.ResTypes))
 	}
+	if query.Silenced != nil {
+		if *query.Silenced {
+			q = q.IsTrue("silenced")
+		} else {
+			q = q.IsFalse("silenced")
+		}
+	}
 	return q, nil
 }
 
@@ -0,0 +1,443 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/bitmap"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
+	"yunion.io/x/onecloud/pkg/util/stringutils2"
+)
+
+var (
+	AlertSilenceManager *SAlertSilenceManager
+)
+
+func init() {
+	AlertSilenceManager = &SAlertSilenceManager{
+		SStandaloneResourceBaseManager: db.NewStandaloneResourceBaseManager(
+			SAlertSilence{},
+			"alertsilences_tbl",
+			"alertsilence",
+			"alertsilences",
+		),
+	}
+	AlertSilenceManager.SetVirtualObject(AlertSilenceManager)
+}
+
+// SAlertSilenceManager manages the silences which mute the notifications of
+// alerts matched during planned maintenance, the alert records are still
+// created and flagged as silenced.
+type SAlertSilenceManager struct {
+	db.SEnabledResourceBaseManager
+	db.SStandaloneResourceBaseManager
+}
+
+type SAlertSilence struct {
+	db.SEnabledResourceBase
+	db.SStandaloneResourceBase
+
+	// matchers, empty matcher matches everything
+	AlertId   string               `width:"36" charset:"ascii" nullable:"true" list:"user" create:"optional"`
+	ResId     string               `width:"36" charset:"ascii" nullable:"true" list:"user" create:"optional"`
+	ProjectId string               `width:"128" charset:"ascii" nullable:"true" list:"user" create:"optional"`
+	Metric    string               `width:"256" charset:"utf8" nullable:"true" list:"user" create:"optional"`
+	Matchers  jsonutils.JSONObject `nullable:"true" list:"user" create:"optional"`
+
+	StartTime time.Time `nullable:"false" list:"user" create:"optional"`
+	EndTime   time.Time `nullable:"true" list:"user" create:"optional" update:"user"`
+
+	// Cycle type, empty means the silence is active all the time between
+	// StartTime and EndTime
+	CycleType string `width:"8" charset:"ascii" nullable:"true" list:"user" create:"optional"`
+	// 0-7 1 is Monday 0 is unlimited
+	WeekDays uint8 `nullable:"false" default:"0"`
+	// start of the cycle window, 0-23
+	Hour int `nullable:"false" default:"0" list:"user" create:"optional"`
+	// start of the cycle window, 0-59
+	Minute int `nullable:"false" default:"0" list:"user" create:"optional"`
+	// length of the cycle window in minutes
+	Duration int `nullable:"false" default:"0" list:"user" create:"optional"`
+
+	Comment   string `width:"256" charset:"utf8" nullable:"true" list:"user" create:"optional" update:"user"`
+	CreatedBy string `width:"128" charset:"utf8" nullable:"true" list:"user"`
+}
+
+func (man *SAlertSilenceManager) NamespaceScope() rbacutils.TRbacScope {
+	return rbacutils.ScopeSystem
+}
+
+func (man *SAlertSilenceManager) AllowCreateItem(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowCreate(userCred, man)
+}
+
+func (man *SAlertSilenceManager) ValidateCreateData(
+	ctx context.Context, userCred mcclient.TokenCredential,
+	ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject,
+	data monitor.AlertSilenceCreateInput) (monitor.AlertSilenceCreateInput, error) {
+	var err error
+	data.StandaloneResourceCreateInput, err = man.SStandaloneResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, data.StandaloneResourceCreateInput)
+	if err != nil {
+		return data, errors.Wrap(err, "SStandaloneResourceBaseManager.ValidateCreateData")
+	}
+	if len(data.AlertId) > 0 {
+		alert, err := CommonAlertManager.FetchByIdOrName(userCred, data.AlertId)
+		if err != nil {
+			return data, httperrors.NewResourceNotFoundError2(CommonAlertManager.Keyword(), data.AlertId)
+		}
+		data.AlertId = alert.GetId()
+	}
+	for _, m := range data.Matchers {
+		if len(m.Key) == 0 {
+			return data, httperrors.NewInputParameterError("empty matcher key")
+		}
+		switch m.Operator {
+		case monitor.AlertSilenceMatcherOpEqual, monitor.AlertSilenceMatcherOpNotEqual:
+		case monitor.AlertSilenceMatcherOpRegex, monitor.AlertSilenceMatcherOpNotRegex:
+			if _, err := compileSilenceMatcherRegex(m.Value); err != nil {
+				return data, httperrors.NewInputParameterError("invalid matcher regex %q: %v", m.Value, err)
+			}
+		default:
+			return data, httperrors.NewInputParameterError("invalid matcher operator %q", m.Operator)
+		}
+	}
+
+	now := time.Now()
+	if data.StartTime.IsZero() {
+		data.StartTime = now
+	}
+	if !data.EndTime.IsZero() {
+		if !data.EndTime.After(data.StartTime) {
+			return data, httperrors.NewInputParameterError("end_time should be later than start_time")
+		}
+		if now.After(data.EndTime) {
+			return data, httperrors.NewInputParameterError("end_time is earlier than now")
+		}
+	}
+	switch data.CycleType {
+	case "":
+		if data.EndTime.IsZero() {
+			return data, httperrors.NewMissingParameterError("end_time")
+		}
+		data.WeekDays = []int{}
+		data.Hour, data.Minute, data.Duration = 0, 0, 0
+	case monitor.AlertSilenceCycleTypeDay, monitor.AlertSilenceCycleTypeWeek:
+		if data.CycleType == monitor.AlertSilenceCycleTypeWeek {
+			if len(data.WeekDays) == 0 {
+				return data, httperrors.NewMissingParameterError("week_days")
+			}
+			for _, day := range data.WeekDays {
+				if day < 1 || day > 7 {
+					return data, httperrors.NewInputParameterError("week_days should between 1 and 7")
+				}
+			}
+		} else {
+			data.WeekDays = []int{}
+		}
+		if data.Hour < 0 || data.Hour > 23 {
+			return data, httperrors.NewInputParameterError("hour should between 0 and 23")
+		}
+		if data.Minute < 0 || data.Minute > 59 {
+			return data, httperrors.NewInputParameterError("minute should between 0 and 59")
+		}
+		if data.Duration <= 0 || data.Duration > 24*60 {
+			return data, httperrors.NewInputParameterError("duration should between 1 and 1440 minutes")
+		}
+	default:
+		return data, httperrors.NewInputParameterError("unknown cycle type %s", data.CycleType)
+	}
+	return data, nil
+}
+
+func (silence *SAlertSilence) CustomizeCreate(
+	ctx context.Context, userCred mcclient.TokenCredential,
+	ownerId mcclient.IIdentityProvider,
+	query jsonutils.JSONObject,
+	data jsonutils.JSONObject,
+) error {
+	input := monitor.AlertSilenceCreateInput{}
+	if err := data.Unmarshal(&input); err != nil {
+		return errors.Wrap(err, "Unmarshal AlertSilenceCreateInput")
+	}
+	silence.SetWeekDays(input.WeekDays)
+	silence.CreatedBy = userCred.GetUserName()
+	if !data.Contains("enabled") {
+		silence.SetEnabled(true)
+	}
+	return silence.SStandaloneResourceBase.CustomizeCreate(ctx, userCred, ownerId, query, data)
+}
+
+func (silence *SAlertSilence) ValidateUpdateData(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input monitor.AlertSilenceUpdateInput) (monitor.AlertSilenceUpdateInput, error) {
+	var err error
+	input.StandaloneResourceBaseUpdateInput, err = silence.SStandaloneResourceBase.ValidateUpdateData(ctx, userCred, query, input.StandaloneResourceBaseUpdateInput)
+	if err != nil {
+		return input, errors.Wrap(err, "SStandaloneResourceBase.ValidateUpdateData")
+	}
+	if input.EndTime != nil {
+		if input.EndTime.IsZero() && len(silence.CycleType) == 0 {
+			return input, httperrors.NewInputParameterError("end_time is required by a silence without cycle")
+		}
+		if !input.EndTime.IsZero() && !input.EndTime.After(silence.StartTime) {
+			return input, httperrors.NewInputParameterError("end_time should be later than start_time")
+		}
+	}
+	return input, nil
+}
+
+func (man *SAlertSilenceManager) ListItemFilter(
+	ctx context.Context, q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query monitor.AlertSilenceListInput,
+) (*sqlchemy.SQuery, error) {
+	var err error
+	q, err = man.SStandaloneResourceBaseManager.ListItemFilter(ctx, q, userCred, query.StandaloneResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SStandaloneResourceBaseManager.ListItemFilter")
+	}
+	q, err = man.SEnabledResourceBaseManager.ListItemFilter(ctx, q, userCred, query.EnabledResourceBaseListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SEnabledResourceBaseManager.ListItemFilter")
+	}
+	if len(query.AlertId) > 0 {
+		q = q.Equals("alert_id", query.AlertId)
+	}
+	if len(query.ResId) > 0 {
+		q = q.Equals("res_id", query.ResId)
+	}
+	if len(query.ProjectId) > 0 {
+		q = q.Equals("project_id", query.ProjectId)
+	}
+	return q, nil
+}
+
+func (man *SAlertSilenceManager) OrderByExtraFields(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query monitor.AlertSilenceListInput,
+) (*sqlchemy.SQuery, error) {
+	q, err := man.SStandaloneResourceBaseManager.OrderByExtraFields(ctx, q, userCred, query.StandaloneResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SStandaloneResourceBaseManager.OrderByExtraFields")
+	}
+	return q, nil
+}
+
+func (man *SAlertSilenceManager) FetchCustomizeColumns(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	objs []interface{},
+	fields stringutils2.SSortedStrings,
+	isList bool,
+) []monitor.AlertSilenceDetails {
+	rows := make([]monitor.AlertSilenceDetails, len(objs))
+	stdRows := man.SStandaloneResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
+	now := time.Now()
+	for i := range rows {
+		silence := objs[i].(*SAlertSilence)
+		rows[i] = monitor.AlertSilenceDetails{
+			StandaloneResourceDetails: stdRows[i],
+			WeekDays:                  silence.GetWeekDays(),
+			Active:                    silence.IsActive(now),
+		}
+	}
+	return rows
+}
+
+func (silence *SAlertSilence) AllowPerformEnable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformEnableInput) bool {
+	return db.IsAdminAllowPerform(userCred, silence, "enable")
+}
+
+func (silence *SAlertSilence) PerformEnable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformEnableInput) (jsonutils.JSONObject, error) {
+	err := db.EnabledPerformEnable(silence, ctx, userCred, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "EnabledPerformEnable")
+	}
+	return nil, nil
+}
+
+func (silence *SAlertSilence) AllowPerformDisable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformDisableInput) bool {
+	return db.IsAdminAllowPerform(userCred, silence, "disable")
+}
+
+func (silence *SAlertSilence) PerformDisable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformDisableInput) (jsonutils.JSONObject, error) {
+	err := db.EnabledPerformEnable(silence, ctx, userCred, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "EnabledPerformEnable")
+	}
+	return nil, nil
+}
+
+func (silence *SAlertSilence) GetWeekDays() []int {
+	return bitmap.Uint2IntArray(uint32(silence.WeekDays))
+}
+
+func (silence *SAlertSilence) SetWeekDays(days []int) {
+	silence.WeekDays = uint8(bitmap.IntArray2Uint(days))
+}
+
+func (silence *SAlertSilence) GetMatchers() ([]monitor.AlertSilenceMatcher, error) {
+	ret := make([]monitor.AlertSilenceMatcher, 0)
+	if silence.Matchers == nil {
+		return ret, nil
+	}
+	if err := silence.Matchers.Unmarshal(&ret); err != nil {
+		return nil, errors.Wrap(err, "unmarshal matchers")
+	}
+	return ret, nil
+}
+
+// IsActive reports whether now is inside the silence window, a cycle window
+// started on the previous day may last over midnight.
+func (silence *SAlertSilence) IsActive(now time.Time) bool {
+	if !silence.GetEnabled() {
+		return false
+	}
+	if now.Before(silence.StartTime) {
+		return false
+	}
+	if !silence.EndTime.IsZero() && !now.Before(silence.EndTime) {
+		return false
+	}
+	if len(silence.CycleType) == 0 {
+		return true
+	}
+	now = now.Local()
+	for _, offset := range []int{0, -1} {
+		day := now.AddDate(0, 0, offset)
+		begin := time.Date(day.Year(), day.Month(), day.Day(), silence.Hour, silence.Minute, 0, 0, now.Location())
+		end := begin.Add(time.Duration(silence.Duration) * time.Minute)
+		if now.Before(begin) || !now.Before(end) {
+			continue
+		}
+		if silence.CycleType == monitor.AlertSilenceCycleTypeWeek {
+			weekday := int(begin.Weekday())
+			if weekday == 0 {
+				weekday = 7
+			}
+			if !silence.isWeekDay(weekday) {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (silence *SAlertSilence) isWeekDay(weekday int) bool {
+	for _, day := range silence.GetWeekDays() {
+		if day == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// silenceMatcherRegexps caches the compiled matcher patterns, silences are
+// fetched on every evaluation so that a pattern is compiled once it is
+// validated on creation
+var silenceMatcherRegexps sync.Map
+
+// compileSilenceMatcherRegex compiles the matcher pattern anchored at both
+// ends, so that it has to match the whole tag value
+func compileSilenceMatcherRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := silenceMatcherRegexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, err
+	}
+	silenceMatcherRegexps.Store(pattern, re)
+	return re, nil
+}
+
+// Match reports whether the eval match of alert is muted by the silence
+func (silence *SAlertSilence) Match(alertId string, match *monitor.EvalMatch) bool {
+	if len(silence.AlertId) > 0 && silence.AlertId != alertId {
+		return false
+	}
+	if len(silence.ResId) > 0 && silence.ResId != match.ResId {
+		return false
+	}
+	if len(silence.ProjectId) > 0 && silence.ProjectId != match.ProjectId {
+		return false
+	}
+	if len(silence.Metric) > 0 && silence.Metric != match.Metric && !strings.HasPrefix(match.Metric, silence.Metric+".") {
+		return false
+	}
+	matchers, err := silence.GetMatchers()
+	if err != nil {
+		log.Errorf("alert silence %s get matchers: %v", silence.GetName(), err)
+		return false
+	}
+	for _, m := range matchers {
+		val := match.Tags[m.Key]
+		var hit bool
+		switch m.Operator {
+		case monitor.AlertSilenceMatcherOpEqual:
+			hit = val == m.Value
+		case monitor.AlertSilenceMatcherOpNotEqual:
+			hit = val != m.Value
+		case monitor.AlertSilenceMatcherOpRegex, monitor.AlertSilenceMatcherOpNotRegex:
+			re, err := compileSilenceMatcherRegex(m.Value)
+			if err != nil {
+				log.Errorf("alert silence %s invalid regex %q: %v", silence.GetName(), m.Value, err)
+				return false
+			}
+			hit = re.MatchString(val) == (m.Operator == monitor.AlertSilenceMatcherOpRegex)
+		}
+		if !hit {
+			return false
+		}
+	}
+	return true
+}
+
+// GetActiveSilences returns the enabled silences whose window covers now
+func (man *SAlertSilenceManager) GetActiveSilences(now time.Time) ([]SAlertSilence, error) {
+	silences := make([]SAlertSilence, 0)
+	q := man.Query().IsTrue("enabled").LE("start_time", now)
+	q = q.Filter(sqlchemy.OR(
+		sqlchemy.IsNull(q.Field("end_time")),
+		sqlchemy.GT(q.Field("end_time"), now),
+	))
+	if err := db.FetchModelObjects(man, q, &silences); err != nil {
+		return nil, errors.Wrap(err, "FetchModelObjects")
+	}
+	ret := make([]SAlertSilence, 0, len(silences))
+	for i := range silences {
+		if silences[i].IsActive(now) {
+			ret = append(ret, silences[i])
+		}
+	}
+	return ret, nil
+}
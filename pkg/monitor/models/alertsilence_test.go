@@ -0,0 +1,153 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/apis/monitor"
+)
+
+func newTestSilence() *SAlertSilence {
+	silence := &SAlertSilence{}
+	silence.SetEnabled(true)
+	silence.StartTime = time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local)
+	return silence
+}
+
+func TestAlertSilenceIsActive(t *testing.T) {
+	// 2021-01-03 is Sunday
+	sunday := func(hour, minute int) time.Time {
+		return time.Date(2021, 1, 3, hour, minute, 0, 0, time.Local)
+	}
+
+	oneOff := newTestSilence()
+	oneOff.EndTime = sunday(12, 0)
+
+	weekly := newTestSilence()
+	weekly.CycleType = monitor.AlertSilenceCycleTypeWeek
+	weekly.SetWeekDays([]int{7})
+	weekly.Hour = 2
+	weekly.Duration = 120
+
+	overMidnight := newTestSilence()
+	overMidnight.CycleType = monitor.AlertSilenceCycleTypeDay
+	overMidnight.Hour = 23
+	overMidnight.Duration = 180
+
+	disabled := newTestSilence()
+	disabled.EndTime = sunday(12, 0)
+	disabled.SetEnabled(false)
+
+	tests := []struct {
+		name    string
+		silence *SAlertSilence
+		now     time.Time
+		want    bool
+	}{
+		{"one-off in window", oneOff, sunday(11, 59), true},
+		{"one-off before start", oneOff, time.Date(2020, 12, 31, 0, 0, 0, 0, time.Local), false},
+		{"one-off expired", oneOff, sunday(12, 0), false},
+		{"weekly window begin", weekly, sunday(2, 0), true},
+		{"weekly window end", weekly, sunday(4, 0), false},
+		{"weekly before window", weekly, sunday(1, 59), false},
+		{"weekly other day", weekly, sunday(2, 30).AddDate(0, 0, 1), false},
+		{"weekly next week", weekly, sunday(3, 0).AddDate(0, 0, 7), true},
+		{"daily before midnight", overMidnight, sunday(23, 30), true},
+		{"daily after midnight", overMidnight, sunday(1, 59), true},
+		{"daily window end", overMidnight, sunday(2, 0), false},
+		{"disabled", disabled, sunday(11, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.silence.IsActive(tt.now); got != tt.want {
+				t.Errorf("IsActive(%s) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertSilenceMatch(t *testing.T) {
+	match := &monitor.EvalMatch{
+		Metric:    "cpu.usage_active",
+		ResId:     "host-1",
+		ProjectId: "project-1",
+		Tags: map[string]string{
+			"name": "node1",
+			"zone": "zone1",
+		},
+	}
+	newSilence := func(f func(s *SAlertSilence)) *SAlertSilence {
+		s := newTestSilence()
+		f(s)
+		return s
+	}
+	matchers := func(ms ...monitor.AlertSilenceMatcher) jsonutils.JSONObject {
+		return jsonutils.Marshal(ms)
+	}
+
+	tests := []struct {
+		name    string
+		silence *SAlertSilence
+		want    bool
+	}{
+		{"match all", newSilence(func(s *SAlertSilence) {}), true},
+		{"alert id", newSilence(func(s *SAlertSilence) { s.AlertId = "alert-1" }), true},
+		{"other alert id", newSilence(func(s *SAlertSilence) { s.AlertId = "alert-2" }), false},
+		{"res id", newSilence(func(s *SAlertSilence) { s.ResId = "host-1" }), true},
+		{"other res id", newSilence(func(s *SAlertSilence) { s.ResId = "host-2" }), false},
+		{"project", newSilence(func(s *SAlertSilence) { s.ProjectId = "project-2" }), false},
+		{"measurement", newSilence(func(s *SAlertSilence) { s.Metric = "cpu" }), true},
+		{"metric", newSilence(func(s *SAlertSilence) { s.Metric = "cpu.usage_active" }), true},
+		{"measurement prefix", newSilence(func(s *SAlertSilence) { s.Metric = "cp" }), false},
+		{"tag matchers", newSilence(func(s *SAlertSilence) {
+			s.Matchers = matchers(
+				monitor.AlertSilenceMatcher{Key: "name", Operator: "=~", Value: "^node[0-9]$"},
+				monitor.AlertSilenceMatcher{Key: "zone", Operator: "!=", Value: "zone2"},
+			)
+		}), true},
+		{"regex matches whole value", newSilence(func(s *SAlertSilence) {
+			s.Matchers = matchers(
+				monitor.AlertSilenceMatcher{Key: "name", Operator: "=~", Value: "node[0-9]|host"},
+			)
+		}), true},
+		{"regex does not match substring", newSilence(func(s *SAlertSilence) {
+			s.Matchers = matchers(
+				monitor.AlertSilenceMatcher{Key: "name", Operator: "=~", Value: "node"},
+			)
+		}), false},
+		{"negative regex does not match substring", newSilence(func(s *SAlertSilence) {
+			s.Matchers = matchers(
+				monitor.AlertSilenceMatcher{Key: "zone", Operator: "!~", Value: "zone"},
+			)
+		}), true},
+		{"tag matchers not match", newSilence(func(s *SAlertSilence) {
+			s.Matchers = matchers(
+				monitor.AlertSilenceMatcher{Key: "name", Operator: "=", Value: "node1"},
+				monitor.AlertSilenceMatcher{Key: "zone", Operator: "!~", Value: "zone.*"},
+			)
+		}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.silence.Match("alert-1", match); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"database/sql"
 	"fmt"
 	"math"
+	"net/url"
 	"regexp"
 	"sort"
 	"strings"
@@ -38,6 +39,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/hostman/hostinfo/hostconsts"
 	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
 	merrors "yunion.io/x/onecloud/pkg/monitor/errors"
 	"yunion.io/x/onecloud/pkg/monitor/options"
@@ -170,6 +172,32 @@ func (m *SDataSourceManager) GetSource(id string) (*SDataSource, error) {
 	return ret.(*SDataSource), nil
 }
 
+func (man *SDataSourceManager) AllowCreateItem(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowCreate(userCred, man)
+}
+
+func (man *SDataSourceManager) ValidateCreateData(
+	ctx context.Context, userCred mcclient.TokenCredential,
+	ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject,
+	data monitor.DataSourceCreateInput) (monitor.DataSourceCreateInput, error) {
+	var err error
+	data.StandaloneResourceCreateInput, err = man.SStandaloneResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, data.StandaloneResourceCreateInput)
+	if err != nil {
+		return data, errors.Wrap(err, "SStandaloneResourceBaseManager.ValidateCreateData")
+	}
+	if !utils.IsInStringArray(data.Type, []string{monitor.DataSourceTypeInfluxdb, monitor.DataSourceTypePrometheus}) {
+		return data, httperrors.NewInputParameterError("unsupported datasource type %q", data.Type)
+	}
+	u, err := url.Parse(data.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return data, httperrors.NewInputParameterError("invalid datasource url %q", data.Url)
+	}
+	if data.Name == DefaultDataSource {
+		return data, httperrors.NewDuplicateNameError("name", data.Name)
+	}
+	return data, nil
+}
+
 func (ds *SDataSource) ToTSDBDataSource(db string) *tsdb.DataSource {
 	if db == "" {
 		db = ds.Database
@@ -405,6 +405,9 @@ func setDefaultValue(query *monitor.AlertQuery, inputQuery *monitor.MetricInputQ
 }
 
 func setDataSourceId(query *monitor.AlertQuery) {
+	if len(query.DataSourceId) > 0 {
+		return
+	}
 	datasource, _ := DataSourceManager.GetDefaultSource()
 	query.DataSourceId = datasource.Id
 }
@@ -22,13 +22,15 @@ type AlerterOptions struct {
 	common_options.CommonOptions
 	common_options.DBOptions
 
-	DataProxyTimeout                               int   `help:"query data source proxy timeout" default:"30"`
-	AlertingMinIntervalSeconds                     int64 `help:"alerting min schedule frequency" default:"10"`
-	AlertingMaxAttempts                            int   `help:"alerting engine max attempt" default:"3"`
-	AlertingEvaluationTimeoutSeconds               int64 `help:"alerting evaluation timeout" default:"5"`
-	AlertingNotificationTimeoutSeconds             int64 `help:"alerting notification timeout" default:"30"`
-	InitScopeSuggestConfigIntervalSeconds          int   `help:"internal to init scope suggest configs" default:"900"`
-	InitAlertResourceAdminRoleUsersIntervalSeconds int   `help:"internal to init alert resource admin role users " default:"3600"`
+	DataProxyTimeout                               int    `help:"query data source proxy timeout" default:"30"`
+	AlertingMinIntervalSeconds                     int64  `help:"alerting min schedule frequency" default:"10"`
+	AlertingMaxAttempts                            int    `help:"alerting engine max attempt" default:"3"`
+	AlertingEvaluationTimeoutSeconds               int64  `help:"alerting evaluation timeout" default:"5"`
+	AlertingNotificationTimeoutSeconds             int64  `help:"alerting notification timeout" default:"30"`
+	AlertingShardPrefix                            string `help:"etcd prefix of alerting shard members" default:"/onecloud/monitor/alerting"`
+	AlertingShardTTLSeconds                        int    `help:"ttl of alerting shard member registration, a dead replica's rules are rebalanced after it expires" default:"10"`
+	InitScopeSuggestConfigIntervalSeconds          int    `help:"internal to init scope suggest configs" default:"900"`
+	InitAlertResourceAdminRoleUsersIntervalSeconds int    `help:"internal to init alert resource admin role users " default:"3600"`
 }
 
 var (
@@ -59,6 +59,7 @@ func InitHandlers(app *appsrv.Application) {
 		models.MetricMeasurementManager,
 		models.MetricFieldManager,
 		models.AlertRecordManager,
+		models.AlertSilenceManager,
 		models.AlertDashBoardManager,
 		models.GetAlertResourceManager(),
 		models.AlertPanelManager,
@@ -126,6 +127,7 @@ func addMiscHandlers(root *mux.Router) {
 	root.HandleFunc("/stats", adapterF(appsrv.StatisticHandler))
 	root.HandleFunc("/ping", adapterF(appsrv.PingHandler))
 	root.HandleFunc("/worker_stats", adapterF(appsrv.WorkerStatsHandler))
+	root.HandleFunc(appsrv.MetricsPath, adapterF(appsrv.MetricsHandler))
 
 	// pprof handler
 	root.PathPrefix("/debug/pprof/").Handler(http.DefaultServeMux)
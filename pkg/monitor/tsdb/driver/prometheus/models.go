@@ -0,0 +1,51 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"time"
+)
+
+// Query is a single PromQL range query translated from one select of a
+// monitor.MetricQuery
+type Query struct {
+	Expr        string
+	Measurement string
+	Field       string
+	// Column is the name of the reducer applied to the field, or the field
+	// itself if the raw samples are queried
+	Column string
+	Alias  string
+	Step   time.Duration
+}
+
+type Response struct {
+	Status    string       `json:"status"`
+	Data      ResponseData `json:"data"`
+	ErrorType string       `json:"errorType,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	Warnings  []string     `json:"warnings,omitempty"`
+}
+
+type ResponseData struct {
+	ResultType string   `json:"resultType"`
+	Result     []Series `json:"result"`
+}
+
+type Series struct {
+	Metric map[string]string `json:"metric"`
+	// Value item is a pair of unix timestamp in seconds and value string
+	Values [][]interface{} `json:"values"`
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context/ctxhttp"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/monitor/tsdb"
+)
+
+func init() {
+	tsdb.RegisterTsdbQueryEndpoint(monitor.DataSourceTypePrometheus, NewPrometheusExecutor)
+}
+
+type PrometheusExecutor struct {
+	QueryParser    *PrometheusQueryParser
+	ResponseParser *ResponseParser
+}
+
+func NewPrometheusExecutor(datasource *tsdb.DataSource) (tsdb.TsdbQueryEndpoint, error) {
+	return &PrometheusExecutor{
+		QueryParser:    &PrometheusQueryParser{},
+		ResponseParser: &ResponseParser{},
+	}, nil
+}
+
+func (e *PrometheusExecutor) Query(ctx context.Context, dsInfo *tsdb.DataSource, tsdbQuery *tsdb.TsdbQuery) (*tsdb.Response, error) {
+	if len(tsdbQuery.Queries) == 0 {
+		return nil, errors.Error("query request contains no queries")
+	}
+	httpClient, err := dsInfo.GetHttpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &tsdb.Response{
+		Results: make(map[string]*tsdb.QueryResult),
+	}
+	for _, query := range tsdbQuery.Queries {
+		promQs, err := e.QueryParser.Parse(query, dsInfo, tsdbQuery)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse query %s", query.RefId)
+		}
+		ret := tsdb.NewQueryResult()
+		ret.RefId = query.RefId
+		rawQuerys := make([]string, 0, len(promQs))
+		for _, promQ := range promQs {
+			series, err := e.queryRange(ctx, httpClient, dsInfo, tsdbQuery.TimeRange, promQ)
+			if err != nil {
+				return nil, errors.Wrapf(err, "query %q", promQ.Expr)
+			}
+			ret.Series = append(ret.Series, series...)
+			rawQuerys = append(rawQuerys, promQ.Expr)
+		}
+		ret.Meta = tsdb.QueryResultMeta{
+			RawQuery: strings.Join(rawQuerys, ";"),
+		}
+		result.Results[query.RefId] = ret
+	}
+	return result, nil
+}
+
+func (e *PrometheusExecutor) queryRange(ctx context.Context, httpClient *http.Client, dsInfo *tsdb.DataSource, timeRange *tsdb.TimeRange, query *Query) (tsdb.TimeSeriesSlice, error) {
+	req, err := e.createRequest(dsInfo, timeRange, query)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ctxhttp.Do(ctx, httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// prometheus returns error details in the body with 4xx/5xx status
+	var response Response
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&response); err != nil {
+		if resp.StatusCode/100 != 2 {
+			return nil, errors.Wrapf(ErrPrometheusInvalidResponse, "status code: %v", resp.Status)
+		}
+		return nil, err
+	}
+	return e.ResponseParser.Parse(&response, query)
+}
+
+func (e *PrometheusExecutor) createRequest(dsInfo *tsdb.DataSource, timeRange *tsdb.TimeRange, query *Query) (*http.Request, error) {
+	u, err := url.Parse(dsInfo.Url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid datasource url %q", dsInfo.Url)
+	}
+	u.Path = path.Join(u.Path, "api/v1/query_range")
+
+	from, err := timeRange.ParseFrom()
+	if err != nil {
+		return nil, errors.Wrap(err, "parse time range from")
+	}
+	to, err := timeRange.ParseTo()
+	if err != nil {
+		return nil, errors.Wrap(err, "parse time range to")
+	}
+
+	// use POST mode, the expression may be too long for url
+	bodyValues := url.Values{}
+	bodyValues.Add("query", query.Expr)
+	bodyValues.Add("start", strconv.FormatInt(from.Unix(), 10))
+	bodyValues.Add("end", strconv.FormatInt(to.Unix(), 10))
+	bodyValues.Add("step", strconv.FormatFloat(query.Step.Seconds(), 'f', -1, 64))
+	req, err := http.NewRequest(http.MethodPost, u.String(), strings.NewReader(bodyValues.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "OneCloud Monitor")
+	req.Header.Set("Content-type", "application/x-www-form-urlencoded")
+	if dsInfo.User != "" {
+		req.SetBasicAuth(dsInfo.User, dsInfo.Password)
+	}
+
+	log.Debugf("Prometheus range query: %q, start: %s, end: %s, step: %s", query.Expr, from, to, query.Step)
+	return req, nil
+}
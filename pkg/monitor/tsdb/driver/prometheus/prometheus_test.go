@@ -0,0 +1,171 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	api "yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/monitor/tsdb"
+)
+
+const matrixResponse = `{
+  "status": "success",
+  "data": {
+    "resultType": "matrix",
+    "result": [
+      {
+        "metric": {"__name__": "cpu_usage_active", "hostname": "server1"},
+        "values": [[1590000000, "10.5"], [1590000060, "NaN"], [1590000120.5, "12"]]
+      },
+      {
+        "metric": {"hostname": "server2"},
+        "values": [[1590000000, "20"]]
+      }
+    ]
+  }
+}`
+
+const errorResponse = `{
+  "status": "error",
+  "errorType": "bad_data",
+  "error": "parse error at char 5"
+}`
+
+func TestPrometheusExecutor(t *testing.T) {
+	Convey("Prometheus executor", t, func() {
+		var (
+			reqPath   string
+			reqForm   map[string]string
+			reqUser   string
+			respCode  int
+			respBody  string
+			basicAuth bool
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqPath = r.URL.Path
+			r.ParseForm()
+			reqForm = map[string]string{}
+			for k := range r.PostForm {
+				reqForm[k] = r.PostForm.Get(k)
+			}
+			reqUser, _, basicAuth = r.BasicAuth()
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(respCode)
+			fmt.Fprint(w, respBody)
+		}))
+		defer server.Close()
+
+		dsInfo := &tsdb.DataSource{
+			Id:   "prometheus-test",
+			Type: api.DataSourceTypePrometheus,
+			Url:  server.URL + "/prom",
+			User: "admin",
+		}
+		tsdbQuery := &tsdb.TsdbQuery{
+			TimeRange: tsdb.NewTimeRange("1590000000000", "1590003600000"),
+			Queries: []*tsdb.Query{
+				{
+					RefId: "A",
+					MetricQuery: api.MetricQuery{
+						Measurement: "cpu",
+						Alias:       "$m $col of $tag_hostname",
+						Tags: []api.MetricQueryTag{
+							{Key: "hostname", Operator: "!=", Value: "server3"},
+						},
+						GroupBy: []api.MetricQueryPart{
+							{Type: "time", Params: []string{"1m"}},
+							{Type: "tag", Params: []string{"hostname"}},
+						},
+						Selects: []api.MetricQuerySelect{
+							newSelect("usage_active", api.MetricQueryPart{Type: "mean"}),
+						},
+					},
+				},
+			},
+		}
+		executor, err := NewPrometheusExecutor(dsInfo)
+		So(err, ShouldBeNil)
+
+		Convey("can query range and parse matrix", func() {
+			respCode = http.StatusOK
+			respBody = matrixResponse
+
+			resp, err := executor.Query(context.Background(), dsInfo, tsdbQuery)
+			So(err, ShouldBeNil)
+
+			So(reqPath, ShouldEqual, "/prom/api/v1/query_range")
+			So(reqForm, ShouldResemble, map[string]string{
+				"query": `avg by (hostname) (avg_over_time(cpu_usage_active{hostname!="server3"}[60s]))`,
+				"start": "1590000000",
+				"end":   "1590003600",
+				"step":  "60",
+			})
+			So(basicAuth, ShouldBeTrue)
+			So(reqUser, ShouldEqual, "admin")
+
+			ret := resp.Results["A"]
+			So(ret, ShouldNotBeNil)
+			So(ret.RefId, ShouldEqual, "A")
+			So(ret.Meta.RawQuery, ShouldEqual, reqForm["query"])
+			So(len(ret.Series), ShouldEqual, 2)
+
+			serie := ret.Series[0]
+			So(serie.Name, ShouldEqual, "cpu mean of server1")
+			So(serie.Columns, ShouldResemble, []string{"mean", "time"})
+			So(serie.Tags, ShouldResemble, map[string]string{"hostname": "server1"})
+			So(len(serie.Points), ShouldEqual, 3)
+			So(serie.Points[0].Value(), ShouldEqual, 10.5)
+			So(serie.Points[0].Timestamp(), ShouldEqual, float64(1590000000000))
+			So(serie.Points[1].IsValid(), ShouldBeFalse)
+			So(serie.Points[2].Timestamp(), ShouldEqual, float64(1590000120500))
+			So(ret.Series[1].Name, ShouldEqual, "cpu mean of server2")
+		})
+
+		Convey("can format default serie name", func() {
+			respCode = http.StatusOK
+			respBody = matrixResponse
+			tsdbQuery.Queries[0].Alias = ""
+
+			resp, err := executor.Query(context.Background(), dsInfo, tsdbQuery)
+			So(err, ShouldBeNil)
+			So(resp.Results["A"].Series[0].Name, ShouldEqual, "cpu.mean")
+		})
+
+		Convey("returns prometheus error", func() {
+			respCode = http.StatusBadRequest
+			respBody = errorResponse
+
+			_, err := executor.Query(context.Background(), dsInfo, tsdbQuery)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "parse error at char 5")
+		})
+
+		Convey("returns error on non json response", func() {
+			respCode = http.StatusBadGateway
+			respBody = "bad gateway"
+
+			_, err := executor.Query(context.Background(), dsInfo, tsdbQuery)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "502")
+		})
+	})
+}
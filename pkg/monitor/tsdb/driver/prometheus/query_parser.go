@@ -0,0 +1,337 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/monitor/tsdb"
+)
+
+const (
+	ErrUnsupportedQuery = errors.Error("Unsupported prometheus query")
+)
+
+var (
+	regexpOperatorPattern = regexp.MustCompile(`^\/.*\/$`)
+	invalidNameChars      = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+	durationPattern       = regexp.MustCompile(`^(\d+)(d|w)$`)
+
+	// reducers over the samples of one series in a step window
+	overTimeFunctions = map[string]string{
+		"mean":   "avg_over_time",
+		"max":    "max_over_time",
+		"min":    "min_over_time",
+		"sum":    "sum_over_time",
+		"count":  "count_over_time",
+		"stddev": "stddev_over_time",
+		"last":   "last_over_time",
+	}
+
+	// aggregations merging the reduced series like influxdb does
+	// for the series in one group
+	crossSeriesAggregations = map[string]string{
+		"mean":   "avg",
+		"max":    "max",
+		"min":    "min",
+		"sum":    "sum",
+		"count":  "sum",
+		"stddev": "avg",
+		"last":   "avg",
+	}
+)
+
+type PrometheusQueryParser struct{}
+
+// Parse translates every select of the influxdb style query model into a
+// PromQL range query. The metric name is <measurement>_<field>, which is how
+// telegraf and the influxdb exporters name the metrics.
+func (qp *PrometheusQueryParser) Parse(model *tsdb.Query, dsInfo *tsdb.DataSource, queryCtx *tsdb.TsdbQuery) ([]*Query, error) {
+	if len(model.Selects) == 0 {
+		return nil, errors.Wrap(ErrUnsupportedQuery, "query contains no selects")
+	}
+	minInterval, err := tsdb.GetIntervalFrom(dsInfo, model, time.Millisecond*1)
+	if err != nil {
+		return nil, err
+	}
+	step, groupBy, err := qp.parseGroupBy(model.GroupBy, queryCtx.TimeRange, minInterval)
+	if err != nil {
+		return nil, err
+	}
+	matchers, err := qp.renderMatchers(model.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*Query, 0, len(model.Selects))
+	for _, sel := range model.Selects {
+		query, err := qp.parseSelect(model.Measurement, sel, matchers, groupBy, step)
+		if err != nil {
+			return nil, err
+		}
+		query.Alias = model.Alias
+		ret = append(ret, query)
+	}
+	return ret, nil
+}
+
+// parseGroupBy returns the query step and the labels to aggregate by,
+// nil labels means no aggregation at all, i.e. GROUP BY *
+func (qp *PrometheusQueryParser) parseGroupBy(parts []api.MetricQueryPart, timeRange *tsdb.TimeRange, minInterval time.Duration) (time.Duration, []string, error) {
+	calculator := tsdb.NewIntervalCalculator(&tsdb.IntervalOptions{})
+	step := calculator.Calculate(timeRange, minInterval).Value
+	labels := make([]string, 0)
+	for _, part := range parts {
+		switch part.Type {
+		case "time":
+			if len(part.Params) == 0 {
+				continue
+			}
+			switch part.Params[0] {
+			case "$interval", "$__interval", "auto":
+			default:
+				interval, err := parseDuration(part.Params[0])
+				if err != nil {
+					return 0, nil, errors.Wrapf(err, "invalid group by time %q", part.Params[0])
+				}
+				step = interval
+			}
+		case "tag":
+			if len(part.Params) == 0 {
+				continue
+			}
+			if part.Params[0] == "*" {
+				labels = nil
+				continue
+			}
+			if labels != nil {
+				labels = append(labels, labelName(part.Params[0]))
+			}
+		case "field", "fill":
+			// field * is added by the unified monitor query and fill is
+			// meaningless for a range query, both are ignored
+		default:
+			return 0, nil, errors.Wrapf(ErrUnsupportedQuery, "group by %s", part.Type)
+		}
+	}
+	if step <= 0 {
+		return 0, nil, errors.Wrapf(ErrUnsupportedQuery, "invalid step %s", step)
+	}
+	return step, labels, nil
+}
+
+func (qp *PrometheusQueryParser) renderMatchers(tags []api.MetricQueryTag) ([]string, error) {
+	hasOr := false
+	for i := 1; i < len(tags); i++ {
+		if strings.ToUpper(tags[i].Condition) == "OR" {
+			hasOr = true
+			break
+		}
+	}
+	if hasOr {
+		// PromQL selectors are always ANDed, only 'k = a OR k = b' can be
+		// translated into a regex matcher
+		matcher, err := qp.renderOrMatcher(tags)
+		if err != nil {
+			return nil, err
+		}
+		return []string{matcher}, nil
+	}
+
+	ret := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		op, value, err := tagOperatorValue(tag)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, fmt.Sprintf("%s%s%s", labelName(tag.Key), op, strconv.Quote(value)))
+	}
+	return ret, nil
+}
+
+func (qp *PrometheusQueryParser) renderOrMatcher(tags []api.MetricQueryTag) (string, error) {
+	key := tags[0].Key
+	values := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag.Key != key {
+			return "", errors.Wrapf(ErrUnsupportedQuery, "OR condition between tags %s and %s", key, tag.Key)
+		}
+		op, value, err := tagOperatorValue(tag)
+		if err != nil {
+			return "", err
+		}
+		switch op {
+		case "=":
+			values = append(values, regexp.QuoteMeta(value))
+		case "=~":
+			values = append(values, value)
+		default:
+			return "", errors.Wrapf(ErrUnsupportedQuery, "OR condition with operator %s", op)
+		}
+	}
+	return fmt.Sprintf("%s=~%s", labelName(key), strconv.Quote(strings.Join(values, "|"))), nil
+}
+
+func tagOperatorValue(tag api.MetricQueryTag) (string, string, error) {
+	op := tag.Operator
+	value := tag.Value
+	// If the operator is missing we fall back to sensible defaults
+	if op == "" {
+		if regexpOperatorPattern.MatchString(value) {
+			op = "=~"
+		} else {
+			op = "="
+		}
+	}
+	switch op {
+	case "=", "!=":
+	case "=~", "!~":
+		if regexpOperatorPattern.MatchString(value) {
+			value = value[1 : len(value)-1]
+		}
+	default:
+		return "", "", errors.Wrapf(ErrUnsupportedQuery, "tag %s operator %s", tag.Key, op)
+	}
+	return op, value, nil
+}
+
+func (qp *PrometheusQueryParser) parseSelect(measurement string, sel api.MetricQuerySelect, matchers []string, groupBy []string, step time.Duration) (*Query, error) {
+	if len(sel) == 0 || sel[0].Type != "field" || len(sel[0].Params) == 0 {
+		return nil, errors.Wrap(ErrUnsupportedQuery, "select must start with a field")
+	}
+	field := sel[0].Params[0]
+	selector := metricName(measurement, field)
+	if len(matchers) > 0 {
+		selector = fmt.Sprintf("%s{%s}", selector, strings.Join(matchers, ","))
+	}
+	rangeSelector := fmt.Sprintf("%s[%s]", selector, formatDuration(step))
+
+	expr := selector
+	column := field
+	aggregation := "avg"
+	reduced := false
+	maths := make([]string, 0)
+	for _, part := range sel[1:] {
+		if _, ok := overTimeFunctions[part.Type]; ok || part.Type == "median" || part.Type == "percentile" ||
+			part.Type == "derivative" || part.Type == "non_negative_derivative" {
+			if reduced {
+				return nil, errors.Wrapf(ErrUnsupportedQuery, "more than one reducer on field %s", field)
+			}
+			reduced = true
+			column = part.Type
+		}
+		switch part.Type {
+		case "mean", "max", "min", "sum", "count", "stddev", "last":
+			expr = fmt.Sprintf("%s(%s)", overTimeFunctions[part.Type], rangeSelector)
+			aggregation = crossSeriesAggregations[part.Type]
+		case "median":
+			expr = fmt.Sprintf("quantile_over_time(0.5, %s)", rangeSelector)
+		case "percentile":
+			if len(part.Params) == 0 {
+				return nil, errors.Wrap(ErrUnsupportedQuery, "percentile without nth")
+			}
+			nth, err := strconv.ParseFloat(part.Params[0], 64)
+			if err != nil || nth < 0 || nth > 100 {
+				return nil, errors.Wrapf(ErrUnsupportedQuery, "percentile nth %q", part.Params[0])
+			}
+			expr = fmt.Sprintf("quantile_over_time(%s, %s)", strconv.FormatFloat(nth/100, 'f', -1, 64), rangeSelector)
+		case "derivative", "non_negative_derivative":
+			fn := "deriv"
+			if part.Type == "non_negative_derivative" {
+				fn = "rate"
+			}
+			expr = fmt.Sprintf("%s(%s)", fn, rangeSelector)
+			if len(part.Params) > 0 {
+				unit, err := parseDuration(part.Params[0])
+				if err != nil {
+					return nil, errors.Wrapf(err, "invalid %s unit %q", part.Type, part.Params[0])
+				}
+				if unit != time.Second {
+					expr = fmt.Sprintf("%s * %s", expr, strconv.FormatFloat(unit.Seconds(), 'f', -1, 64))
+				}
+			}
+		case "math":
+			if len(part.Params) > 0 {
+				maths = append(maths, part.Params[0])
+			}
+		case "alias":
+			if len(part.Params) > 0 {
+				column = part.Params[0]
+			}
+		default:
+			return nil, errors.Wrapf(ErrUnsupportedQuery, "select %s", part.Type)
+		}
+	}
+
+	if groupBy != nil {
+		if len(groupBy) > 0 {
+			expr = fmt.Sprintf("%s by (%s) (%s)", aggregation, strings.Join(groupBy, ", "), expr)
+		} else {
+			expr = fmt.Sprintf("%s(%s)", aggregation, expr)
+		}
+	}
+	for _, math := range maths {
+		expr = fmt.Sprintf("(%s) %s", expr, strings.TrimSpace(math))
+	}
+
+	return &Query{
+		Expr:        expr,
+		Measurement: measurement,
+		Field:       field,
+		Column:      column,
+		Step:        step,
+	}, nil
+}
+
+func metricName(measurement, field string) string {
+	return invalidNameChars.ReplaceAllString(fmt.Sprintf("%s_%s", measurement, field), "_")
+}
+
+func labelName(key string) string {
+	return invalidNameChars.ReplaceAllString(key, "_")
+}
+
+// parseDuration also accepts the influxdb day and week units
+func parseDuration(val string) (time.Duration, error) {
+	if matches := durationPattern.FindStringSubmatch(val); matches != nil {
+		num, _ := strconv.Atoi(matches[1])
+		day := 24 * time.Hour
+		if matches[2] == "w" {
+			return time.Duration(num) * 7 * day, nil
+		}
+		return time.Duration(num) * day, nil
+	}
+	return time.ParseDuration(val)
+}
+
+// formatDuration renders a duration in the PromQL range syntax without
+// losing precision, e.g. 90s instead of the 1m from tsdb.FormatDuration
+func formatDuration(d time.Duration) string {
+	if d%time.Second == 0 {
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+	ms := d / time.Millisecond
+	if ms <= 0 {
+		ms = 1
+	}
+	return fmt.Sprintf("%dms", ms)
+}
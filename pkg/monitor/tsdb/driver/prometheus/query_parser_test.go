@@ -0,0 +1,156 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	api "yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/monitor/tsdb"
+)
+
+func newSelect(field string, parts ...api.MetricQueryPart) api.MetricQuerySelect {
+	sel := api.NewMetricQuerySelect(api.MetricQueryPart{Type: "field", Params: []string{field}})
+	return append(sel, parts...)
+}
+
+func TestPrometheusQueryParser(t *testing.T) {
+	Convey("Prometheus query parser", t, func() {
+		parser := &PrometheusQueryParser{}
+		queryCtx := &tsdb.TsdbQuery{
+			TimeRange: tsdb.NewTimeRange("1590000000000", "1590003600000"),
+		}
+		dsInfo := &tsdb.DataSource{}
+
+		Convey("can translate mean group by time and tag", func() {
+			query := &tsdb.Query{
+				MetricQuery: api.MetricQuery{
+					Measurement: "cpu",
+					Tags: []api.MetricQueryTag{
+						{Key: "hostname", Operator: "=", Value: "server1"},
+						{Key: "vm-id", Operator: "=~", Value: "/^abc.*/", Condition: "AND"},
+					},
+					GroupBy: []api.MetricQueryPart{
+						{Type: "time", Params: []string{"1m"}},
+						{Type: "tag", Params: []string{"hostname"}},
+						{Type: "fill", Params: []string{"none"}},
+					},
+					Selects: []api.MetricQuerySelect{
+						newSelect("usage_active", api.MetricQueryPart{Type: "mean"}),
+						newSelect("usage_idle", api.MetricQueryPart{Type: "percentile", Params: []string{"95"}},
+							api.MetricQueryPart{Type: "math", Params: []string{"/ 100"}}),
+					},
+				},
+			}
+			res, err := parser.Parse(query, dsInfo, queryCtx)
+			So(err, ShouldBeNil)
+			So(len(res), ShouldEqual, 2)
+			So(res[0].Expr, ShouldEqual, `avg by (hostname) (avg_over_time(cpu_usage_active{hostname="server1",vm_id=~"^abc.*"}[60s]))`)
+			So(res[0].Step, ShouldEqual, time.Minute)
+			So(res[0].Column, ShouldEqual, "mean")
+			So(res[1].Expr, ShouldEqual, `(avg by (hostname) (quantile_over_time(0.95, cpu_usage_idle{hostname="server1",vm_id=~"^abc.*"}[60s]))) / 100`)
+			So(res[1].Column, ShouldEqual, "percentile")
+		})
+
+		Convey("aggregates all series without tag group by", func() {
+			query := &tsdb.Query{
+				MetricQuery: api.MetricQuery{
+					Measurement: "mem",
+					GroupBy: []api.MetricQueryPart{
+						{Type: "time", Params: []string{"5m"}},
+					},
+					Selects: []api.MetricQuerySelect{
+						newSelect("used", api.MetricQueryPart{Type: "max"}),
+					},
+				},
+			}
+			res, err := parser.Parse(query, dsInfo, queryCtx)
+			So(err, ShouldBeNil)
+			So(res[0].Expr, ShouldEqual, `max(max_over_time(mem_used[300s]))`)
+		})
+
+		Convey("keeps every series with group by *", func() {
+			query := &tsdb.Query{
+				MetricQuery: api.MetricQuery{
+					Measurement: "net",
+					GroupBy: []api.MetricQueryPart{
+						{Type: "time", Params: []string{"1d"}},
+						{Type: "tag", Params: []string{"*"}},
+					},
+					Selects: []api.MetricQuerySelect{
+						newSelect("bytes_recv", api.MetricQueryPart{Type: "non_negative_derivative", Params: []string{"1m"}}),
+					},
+				},
+			}
+			res, err := parser.Parse(query, dsInfo, queryCtx)
+			So(err, ShouldBeNil)
+			So(res[0].Expr, ShouldEqual, `rate(net_bytes_recv[86400s]) * 60`)
+			So(res[0].Step, ShouldEqual, 24*time.Hour)
+		})
+
+		Convey("can translate OR of the same tag into regex", func() {
+			query := &tsdb.Query{
+				MetricQuery: api.MetricQuery{
+					Measurement: "cpu",
+					Tags: []api.MetricQueryTag{
+						{Key: "hostname", Value: "server1"},
+						{Key: "hostname", Value: "server.2", Condition: "OR"},
+					},
+					GroupBy: []api.MetricQueryPart{
+						{Type: "time", Params: []string{"$interval"}},
+					},
+					Selects: []api.MetricQuerySelect{
+						newSelect("usage_active", api.MetricQueryPart{Type: "mean"}),
+					},
+				},
+			}
+			res, err := parser.Parse(query, dsInfo, queryCtx)
+			So(err, ShouldBeNil)
+			So(res[0].Expr, ShouldContainSubstring, `cpu_usage_active{hostname=~"server1|server\\.2"}`)
+			So(res[0].Step, ShouldBeGreaterThan, 0)
+		})
+
+		Convey("rejects unsupported queries", func() {
+			query := &tsdb.Query{
+				MetricQuery: api.MetricQuery{
+					Measurement: "cpu",
+					Tags: []api.MetricQueryTag{
+						{Key: "hostname", Value: "server1"},
+						{Key: "zone", Value: "zone1", Condition: "OR"},
+					},
+					Selects: []api.MetricQuerySelect{
+						newSelect("usage_active", api.MetricQueryPart{Type: "mean"}),
+					},
+				},
+			}
+			_, err := parser.Parse(query, dsInfo, queryCtx)
+			So(err, ShouldNotBeNil)
+
+			query.Tags = []api.MetricQueryTag{{Key: "usage", Operator: ">", Value: "10"}}
+			_, err = parser.Parse(query, dsInfo, queryCtx)
+			So(err, ShouldNotBeNil)
+
+			query.Tags = nil
+			query.Selects = []api.MetricQuerySelect{
+				newSelect("usage_active", api.MetricQueryPart{Type: "mean"}, api.MetricQueryPart{Type: "max"}),
+			}
+			_, err = parser.Parse(query, dsInfo, queryCtx)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/monitor/tsdb"
+)
+
+const (
+	ErrPrometheusInvalidResponse = errors.Error("Prometheus invalid response")
+)
+
+var (
+	legendFormat = regexp.MustCompile(`\[\[(\w+)(\.\w+)*\]\]*|\$\s*(\w+?)*`)
+)
+
+type ResponseParser struct{}
+
+func (rp *ResponseParser) Parse(response *Response, query *Query) (tsdb.TimeSeriesSlice, error) {
+	if response.Status != "success" {
+		return nil, errors.Wrapf(ErrPrometheusInvalidResponse, "%s: %s", response.ErrorType, response.Error)
+	}
+	if response.Data.ResultType != "matrix" {
+		return nil, errors.Wrapf(ErrPrometheusInvalidResponse, "result type %q is not matrix", response.Data.ResultType)
+	}
+
+	result := make(tsdb.TimeSeriesSlice, 0, len(response.Data.Result))
+	for _, series := range response.Data.Result {
+		tags := make(map[string]string)
+		for k, v := range series.Metric {
+			if k == "__name__" {
+				continue
+			}
+			tags[k] = v
+		}
+		points := make(tsdb.TimeSeriesPoints, 0, len(series.Values))
+		for _, valuePair := range series.Values {
+			point, err := rp.parseTimepoint(valuePair)
+			if err == nil {
+				points = append(points, point)
+			}
+		}
+		result = append(result, &tsdb.TimeSeries{
+			Name:    rp.formatSerieName(tags, query),
+			Columns: []string{query.Column, "time"},
+			Points:  points,
+			Tags:    tags,
+		})
+	}
+	return result, nil
+}
+
+func (rp *ResponseParser) formatSerieName(tags map[string]string, query *Query) string {
+	if query.Alias == "" {
+		return fmt.Sprintf("%s.%s", query.Measurement, query.Column)
+	}
+
+	result := legendFormat.ReplaceAllFunc([]byte(query.Alias), func(in []byte) []byte {
+		aliasFormat := string(in)
+		aliasFormat = strings.Replace(aliasFormat, "[[", "", 1)
+		aliasFormat = strings.Replace(aliasFormat, "]]", "", 1)
+		aliasFormat = strings.Replace(aliasFormat, "$", "", 1)
+
+		if aliasFormat == "m" || aliasFormat == "measurement" {
+			return []byte(query.Measurement)
+		}
+		if aliasFormat == "col" {
+			return []byte(query.Column)
+		}
+
+		if !strings.HasPrefix(aliasFormat, "tag_") {
+			return in
+		}
+
+		tagKey := strings.Replace(aliasFormat, "tag_", "", 1)
+		tagValue, exist := tags[tagKey]
+		if exist {
+			return []byte(tagValue)
+		}
+
+		return in
+	})
+
+	return string(result)
+}
+
+// parseTimepoint converts a [<unix seconds>, "<value>"] pair into a point
+// with millisecond timestamp, NaN values are treated as null
+func (rp *ResponseParser) parseTimepoint(valuePair []interface{}) (tsdb.TimePoint, error) {
+	if len(valuePair) != 2 {
+		return nil, errors.Wrapf(ErrPrometheusInvalidResponse, "invalid value pair %v", valuePair)
+	}
+	timestampNumber, ok := valuePair[0].(json.Number)
+	if !ok {
+		return nil, errors.Wrapf(ErrPrometheusInvalidResponse, "invalid timestamp %v", valuePair[0])
+	}
+	timestamp, err := timestampNumber.Float64()
+	if err != nil {
+		return nil, err
+	}
+	valueStr, ok := valuePair[1].(string)
+	if !ok {
+		return nil, errors.Wrapf(ErrPrometheusInvalidResponse, "invalid value %v", valuePair[1])
+	}
+	var value *float64
+	fvalue, err := strconv.ParseFloat(valueStr, 64)
+	if err == nil && !math.IsNaN(fvalue) {
+		value = &fvalue
+	}
+	return tsdb.NewTimePoint(value, math.Round(timestamp*1000)), nil
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"yunion.io/x/jsonutils"
@@ -29,6 +30,7 @@ import (
 
 	api "yunion.io/x/onecloud/pkg/apis/notify"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/image/policy"
@@ -177,14 +179,21 @@ func (n *SNotification) PostCreate(ctx context.Context, userCred mcclient.TokenC
 		}
 	}
 	n.SetStatus(userCred, api.NOTIFICATION_STATUS_RECEIVED, "")
-	task, err := taskman.TaskManager.NewTask(ctx, "NotificationSendTask", n, userCred, nil, "", "")
+	err := n.StartSendTask(ctx, userCred, "")
 	if err != nil {
 		log.Errorf("NotificationSendTask newTask error %v", err)
-	} else {
-		task.ScheduleRun(nil)
 	}
 }
 
+func (n *SNotification) StartSendTask(ctx context.Context, userCred mcclient.TokenCredential, parentTaskId string) error {
+	task, err := taskman.TaskManager.NewTask(ctx, "NotificationSendTask", n, userCred, nil, parentTaskId, "")
+	if err != nil {
+		return err
+	}
+	task.ScheduleRun(nil)
+	return nil
+}
+
 func (nm *SNotificationManager) FetchCustomizeColumns(
 	ctx context.Context,
 	userCred mcclient.TokenCredential,
@@ -218,8 +227,25 @@ func (n *SNotification) GetExtraDetails(
 	return api.NotificationDetails{}, nil
 }
 
+// ReceiverNotificationsToSend returns the deliveries which are neither
+// succeeded nor dead-lettered, and not backing off.
+func (n *SNotification) ReceiverNotificationsToSend() ([]SReceiverNotification, error) {
+	rnq := ReceiverNotificationManager.Query().Equals("notification_id", n.Id).NotIn("status", []string{api.RECEIVER_NOTIFICATION_OK, api.RECEIVER_NOTIFICATION_DEAD})
+	rnq = rnq.Filter(sqlchemy.OR(sqlchemy.IsNull(rnq.Field("next_send_at")), sqlchemy.LE(rnq.Field("next_send_at"), time.Now())))
+	return n.fetchReceiverNotifications(rnq)
+}
+
 func (n *SNotification) ReceiverNotificationsNotOK() ([]SReceiverNotification, error) {
 	rnq := ReceiverNotificationManager.Query().Equals("notification_id", n.Id).NotEquals("status", api.RECEIVER_NOTIFICATION_OK)
+	return n.fetchReceiverNotifications(rnq)
+}
+
+func (n *SNotification) ReceiverNotificationsDead() ([]SReceiverNotification, error) {
+	rnq := ReceiverNotificationManager.Query().Equals("notification_id", n.Id).Equals("status", api.RECEIVER_NOTIFICATION_DEAD)
+	return n.fetchReceiverNotifications(rnq)
+}
+
+func (n *SNotification) fetchReceiverNotifications(rnq *sqlchemy.SQuery) ([]SReceiverNotification, error) {
 	rns := make([]SReceiverNotification, 0, 1)
 	err := db.FetchModelObjects(ReceiverNotificationManager, rnq, &rns)
 	if err == sql.ErrNoRows {
@@ -233,7 +259,7 @@ func (n *SNotification) ReceiverNotificationsNotOK() ([]SReceiverNotification, e
 
 func (n *SNotification) ReceiveDetails(userCred mcclient.TokenCredential, scope string) ([]api.ReceiveDetail, error) {
 	RQ := ReceiverManager.Query("id", "name")
-	q := ReceiverNotificationManager.Query("receiver_id", "notification_id", "contact", "send_at", "send_by", "status", "failed_reason").Equals("notification_id", n.Id)
+	q := ReceiverNotificationManager.Query("receiver_id", "notification_id", "contact", "send_at", "send_by", "status", "failed_reason", "attempts", "next_send_at").Equals("notification_id", n.Id)
 	s := rbacutils.TRbacScope(scope)
 
 	switch s {
@@ -499,25 +525,249 @@ func (nm *SNotificationManager) ListItemFilter(ctx context.Context, q *sqlchemy.
 	if len(input.Tag) > 0 {
 		q = q.Equals("tag", input.Tag)
 	}
+	if input.DeadLetter != nil {
+		subq := ReceiverNotificationManager.Query("notification_id").Equals("status", api.RECEIVER_NOTIFICATION_DEAD).SubQuery()
+		if *input.DeadLetter {
+			q = q.In("id", subq)
+		} else {
+			q = q.NotIn("id", subq)
+		}
+	}
 	return q, nil
 }
 
+// requeueStuck fails the deliveries and notifications stuck in sending for
+// longer than SendTimeoutSeconds, e.g. because the service restarted in the
+// middle of sending, so that they are retried with backoff like other failures.
+func (nm *SNotificationManager) requeueStuck(ctx context.Context, userCred mcclient.TokenCredential, now time.Time) {
+	before := now.Add(-time.Duration(options.Options.SendTimeoutSeconds) * time.Second)
+	rns, err := ReceiverNotificationManager.FetchStuckSending(before)
+	if err != nil {
+		log.Errorf("fail to FetchStuckSending: %v", err)
+		return
+	}
+	for i := range rns {
+		err := rns[i].AfterSend(ctx, false, "send timeout")
+		if err != nil {
+			log.Errorf("fail to requeue receiver %s of notification %s: %v", rns[i].ReceiverID, rns[i].NotificationID, err)
+		}
+	}
+	q := nm.Query().Equals("status", api.NOTIFICATION_STATUS_SENDING).LT("updated_at", before)
+	ns := make([]SNotification, 0)
+	err = db.FetchModelObjects(nm, q, &ns)
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		log.Errorf("fail to fetch notifications stuck in sending: %v", err)
+		return
+	}
+	for i := range ns {
+		ns[i].SetStatus(userCred, api.NOTIFICATION_STATUS_FAILED, "send timeout")
+	}
+	if len(rns) > 0 || len(ns) > 0 {
+		log.Infof("requeue %d deliveries and %d notifications stuck in sending", len(rns), len(ns))
+	}
+}
+
+// ReSend retries the failed deliveries whose backoff is over
 func (nm *SNotificationManager) ReSend(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
-	timeLimit := time.Now().Add(-time.Duration(options.Options.ReSendScope) * time.Second * 2).Format("2006-01-02 15:04:05")
-	q := nm.Query().GT("created_at", timeLimit).In("status", []string{api.NOTIFICATION_STATUS_FAILED, api.NOTIFICATION_STATUS_PART_OK}).LT("send_times", options.Options.MaxSendTimes)
-	ns := make([]SNotification, 0, 2)
-	err := db.FetchModelObjects(nm, q, &ns)
+	nm.requeueStuck(ctx, userCred, time.Now())
+	ids, err := ReceiverNotificationManager.FetchNotificationIdsToRetry(time.Now())
+	if err != nil {
+		log.Errorf("fail to FetchNotificationIdsToRetry: %v", err)
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+	// the notification being sent will pick up its due deliveries by itself
+	q := nm.Query().In("id", ids).NotEquals("status", api.NOTIFICATION_STATUS_SENDING)
+	ns := make([]SNotification, 0, len(ids))
+	err = db.FetchModelObjects(nm, q, &ns)
 	if err != nil {
 		log.Errorf("fail to FetchModelObjects: %v", err)
 		return
 	}
 	log.Infof("need to resend total %d notifications", len(ns))
 	for i := range ns {
-		task, err := taskman.TaskManager.NewTask(ctx, "NotificationSendTask", &ns[i], userCred, nil, "", "")
+		err := ns[i].StartSendTask(ctx, userCred, "")
 		if err != nil {
 			log.Errorf("NotificationSendTask newTask error %v", err)
-		} else {
-			task.ScheduleRun(nil)
 		}
 	}
 }
+
+func (n *SNotification) AllowPerformRedrive(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowPerform(userCred, n, "redrive")
+}
+
+// PerformRedrive sends the dead-lettered notification again
+func (n *SNotification) PerformRedrive(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.NotificationRedriveInput) (jsonutils.JSONObject, error) {
+	if n.Status == api.NOTIFICATION_STATUS_SENDING {
+		return nil, httperrors.NewInvalidStatusError("notification is sending")
+	}
+	count, err := n.redrive(ctx, input.ReceiverIds)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, httperrors.NewInvalidStatusError("no dead-lettered receivers")
+	}
+	err = n.StartSendTask(ctx, userCred, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "StartSendTask")
+	}
+	return nil, nil
+}
+
+func (n *SNotification) redrive(ctx context.Context, receiverIds []string) (int, error) {
+	rns, err := n.ReceiverNotificationsDead()
+	if err != nil {
+		return 0, errors.Wrap(err, "ReceiverNotificationsDead")
+	}
+	count := 0
+	for i := range rns {
+		if len(receiverIds) > 0 && !utils.IsInStringArray(rns[i].ReceiverID, receiverIds) && !utils.IsInStringArray(rns[i].Contact, receiverIds) {
+			continue
+		}
+		err := rns[i].Redrive(ctx)
+		if err != nil {
+			return count, errors.Wrapf(err, "redrive receiver %s", rns[i].ReceiverID)
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (nm *SNotificationManager) AllowPerformRedrive(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) bool {
+	return db.IsAdminAllowClassPerform(userCred, nm, "redrive")
+}
+
+// PerformRedrive sends the dead-lettered notifications again in batch
+func (nm *SNotificationManager) PerformRedrive(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.NotificationManagerRedriveInput) (api.NotificationManagerRedriveOutput, error) {
+	output := api.NotificationManagerRedriveOutput{}
+	if input.Limit <= 0 {
+		input.Limit = 100
+	}
+	subq := ReceiverNotificationManager.Query("notification_id").Equals("status", api.RECEIVER_NOTIFICATION_DEAD).SubQuery()
+	q := nm.Query().In("id", subq).NotEquals("status", api.NOTIFICATION_STATUS_SENDING)
+	if len(input.ContactType) > 0 {
+		q = q.Equals("contact_type", input.ContactType)
+	}
+	q = q.Asc("created_at").Limit(input.Limit)
+	ns := make([]SNotification, 0, input.Limit)
+	err := db.FetchModelObjects(nm, q, &ns)
+	if err != nil {
+		return output, errors.Wrap(err, "FetchModelObjects")
+	}
+	for i := range ns {
+		count, err := ns[i].redrive(ctx, nil)
+		if err != nil {
+			log.Errorf("unable to redrive notification %s: %v", ns[i].Id, err)
+			continue
+		}
+		if count == 0 {
+			continue
+		}
+		err = ns[i].StartSendTask(ctx, userCred, "")
+		if err != nil {
+			log.Errorf("unable to start send task of notification %s: %v", ns[i].Id, err)
+			continue
+		}
+		output.Notifications = append(output.Notifications, ns[i].Id)
+	}
+	return output, nil
+}
+
+func (nm *SNotificationManager) AllowPerformEventNotify(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) bool {
+	return db.IsAdminAllowClassPerform(userCred, nm, "event-notify")
+}
+
+// PerformEventNotify publishes the resource event to the subscribers of topics which the event belongs to
+func (nm *SNotificationManager) PerformEventNotify(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.NotificationManagerEventNotifyInput) (api.NotificationManagerEventNotifyOutput, error) {
+	output := api.NotificationManagerEventNotifyOutput{}
+	if len(input.ResourceType) == 0 {
+		return output, httperrors.NewMissingParameterError("resource_type")
+	}
+	if len(input.Action) == 0 {
+		return output, httperrors.NewMissingParameterError("action")
+	}
+	topics, err := TopicManager.GetTopicsByEvent(input.ResourceType, input.Action)
+	if err != nil {
+		return output, errors.Wrap(err, "GetTopicsByEvent")
+	}
+	topicIds := make([]string, len(topics))
+	for i := range topics {
+		topicIds[i] = topics[i].Id
+	}
+	subscribers, err := SubscriberManager.GetSubscribers(topicIds, input.ProjectDomainId, input.ProjectId)
+	if err != nil {
+		return output, errors.Wrap(err, "GetSubscribers")
+	}
+	if len(subscribers) == 0 {
+		return output, nil
+	}
+
+	// contact type ==> receivers
+	ctReceivers, receiverIds := subscriberContacts(subscribers)
+	if len(receiverIds) > 0 {
+		receivers, err := ReceiverManager.FetchByIDs(ctx, receiverIds...)
+		if err != nil {
+			return output, errors.Wrap(err, "ReceiverManager.FetchByIDs")
+		}
+		for i := range receivers {
+			if !receivers[i].GetEnabled() {
+				continue
+			}
+			cts, err := receivers[i].GetEnabledContactTypes()
+			if err != nil {
+				log.Errorf("unable to get enabled contact types of receiver %s: %v", receivers[i].Id, err)
+				continue
+			}
+			for _, ct := range cts {
+				ctReceivers[ct] = append(ctReceivers[ct], receivers[i].Id)
+			}
+		}
+	}
+
+	msg := jsonutils.NewDict()
+	msg.Set("resource_type", jsonutils.NewString(input.ResourceType))
+	msg.Set("action", jsonutils.NewString(input.Action))
+	if input.ResourceDetails != nil {
+		msg.Set("resource_details", input.ResourceDetails)
+	}
+	priority := input.Priority
+	if len(priority) == 0 {
+		priority = api.NOTIFICATION_PRIORITY_NORMAL
+	}
+	for ct, reIds := range ctReceivers {
+		createInput := api.NotificationCreateInput{
+			Receivers:                 reIds,
+			ContactType:               ct,
+			Topic:                     strings.ToUpper(fmt.Sprintf("%s_%s", input.ResourceType, input.Action)),
+			Priority:                  priority,
+			Message:                   msg.String(),
+			IgnoreNonexistentReceiver: true,
+		}
+		n, err := nm.createNotification(ctx, userCred, createInput)
+		if err != nil {
+			log.Errorf("unable to create %s notification of %s %s: %v", ct, input.ResourceType, input.Action, err)
+			continue
+		}
+		output.Notifications = append(output.Notifications, n.Id)
+	}
+	return output, nil
+}
+
+func (nm *SNotificationManager) createNotification(ctx context.Context, userCred mcclient.TokenCredential, input api.NotificationCreateInput) (*SNotification, error) {
+	data := input.JSON(input)
+	obj, err := db.DoCreate(nm, ctx, userCred, nil, data, userCred)
+	if err != nil {
+		return nil, err
+	}
+	func() {
+		lockman.LockObject(ctx, obj)
+		defer lockman.ReleaseObject(ctx, obj)
+
+		obj.PostCreate(ctx, userCred, userCred, nil, data)
+	}()
+	return obj.(*SNotification), nil
+}
@@ -2,11 +2,15 @@ package models
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
+	"yunion.io/x/pkg/errors"
+
 	api "yunion.io/x/onecloud/pkg/apis/notify"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/notify/options"
 )
 
 var ReceiverNotificationManager *SReceiverNotificationManager
@@ -47,6 +51,9 @@ type SReceiverNotification struct {
 	SendBy       string    `width:"128" nullable:"false"`
 	Status       string    `width:"36" charset:"ascii"`
 	FailedReason string    `width:"1024"`
+	// delivery attempts, the receiver is dead-lettered when it reaches MaxSendTimes
+	Attempts   int       `nullable:"false" default:"0"`
+	NextSendAt time.Time `nullable:"true" index:"true"`
 }
 
 func (self *SReceiverNotificationManager) InitializeData() error {
@@ -105,15 +112,104 @@ func (rn *SReceiverNotification) BeforeSend(ctx context.Context, sendTime time.T
 	return err
 }
 
+// AfterSend records the result of a delivery attempt. A failed delivery is
+// retried with exponential backoff, and dead-lettered with the last error
+// after MaxSendTimes attempts.
 func (rn *SReceiverNotification) AfterSend(ctx context.Context, success bool, reason string) error {
 	_, err := db.Update(rn, func() error {
+		rn.Attempts += 1
 		if success {
 			rn.Status = api.RECEIVER_NOTIFICATION_OK
-		} else {
-			rn.Status = api.RECEIVER_NOTIFICATION_FAIL
-			rn.FailedReason = reason
+			rn.NextSendAt = time.Time{}
+			return nil
+		}
+		rn.FailedReason = reason
+		if rn.Attempts >= options.Options.MaxSendTimes {
+			rn.Status = api.RECEIVER_NOTIFICATION_DEAD
+			rn.NextSendAt = time.Time{}
+			return nil
 		}
+		rn.Status = api.RECEIVER_NOTIFICATION_FAIL
+		rn.NextSendAt = time.Now().Add(sendRetryBackoff(rn.Attempts))
+		return nil
+	})
+	return err
+}
+
+// DeadLetter gives up the delivery at once because of a failure retry won't
+// help, such as a disabled receiver or an unverified contact.
+func (rn *SReceiverNotification) DeadLetter(ctx context.Context, reason string) error {
+	_, err := db.Update(rn, func() error {
+		rn.Attempts += 1
+		rn.Status = api.RECEIVER_NOTIFICATION_DEAD
+		rn.FailedReason = reason
+		rn.NextSendAt = time.Time{}
 		return nil
 	})
 	return err
 }
+
+// Redrive resets the dead-lettered delivery to be sent again
+func (rn *SReceiverNotification) Redrive(ctx context.Context) error {
+	_, err := db.Update(rn, func() error {
+		rn.Attempts = 0
+		rn.Status = api.RECEIVER_NOTIFICATION_RECEIVED
+		rn.NextSendAt = time.Time{}
+		return nil
+	})
+	return err
+}
+
+func sendRetryBackoff(attempts int) time.Duration {
+	return retryBackoff(
+		attempts,
+		time.Duration(options.Options.SendRetryIntervalSeconds)*time.Second,
+		time.Duration(options.Options.SendRetryMaxIntervalSeconds)*time.Second,
+	)
+}
+
+// retryBackoff returns base*2^(attempts-1) capped by max
+func retryBackoff(attempts int, base, max time.Duration) time.Duration {
+	backoff := base
+	for i := 1; i < attempts && backoff < max; i++ {
+		backoff *= 2
+	}
+	if max > 0 && backoff > max {
+		backoff = max
+	}
+	return backoff
+}
+
+// FetchStuckSending returns the deliveries which have been sending since before
+func (rnm *SReceiverNotificationManager) FetchStuckSending(before time.Time) ([]SReceiverNotification, error) {
+	q := rnm.Query().Equals("status", api.RECEIVER_NOTIFICATION_SENT).LT("send_at", before)
+	rns := make([]SReceiverNotification, 0)
+	err := db.FetchModelObjects(rnm, q, &rns)
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, err
+	}
+	return rns, nil
+}
+
+// FetchNotificationIdsToRetry returns the notifications which have failed
+// deliveries whose backoff is over.
+func (rnm *SReceiverNotificationManager) FetchNotificationIdsToRetry(now time.Time) ([]string, error) {
+	q := rnm.Query("notification_id").Equals("status", api.RECEIVER_NOTIFICATION_FAIL).LE("next_send_at", now).Distinct()
+	rows, err := q.Rows()
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "Query")
+	}
+	defer rows.Close()
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
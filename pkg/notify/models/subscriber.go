@@ -0,0 +1,357 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"database/sql"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/sets"
+	"yunion.io/x/pkg/utils"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	api "yunion.io/x/onecloud/pkg/apis/notify"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
+	"yunion.io/x/onecloud/pkg/util/stringutils2"
+)
+
+type SSubscriberManager struct {
+	db.SStandaloneResourceBaseManager
+	db.SScopedResourceBaseManager
+	db.SEnabledResourceBaseManager
+}
+
+var SubscriberManager *SSubscriberManager
+
+func init() {
+	SubscriberManager = &SSubscriberManager{
+		SStandaloneResourceBaseManager: db.NewStandaloneResourceBaseManager(
+			SSubscriber{},
+			"subscribers_tbl",
+			"subscriber",
+			"subscribers",
+		),
+	}
+	SubscriberManager.SetVirtualObject(SubscriberManager)
+}
+
+// SSubscriber subscribes the events of a topic, the scope of subscriber
+// limits the events to the resources of its domain or project.
+type SSubscriber struct {
+	db.SStandaloneResourceBase
+	db.SScopedResourceBase
+	db.SEnabledResourceBase
+
+	TopicId string `width:"36" charset:"ascii" nullable:"false" index:"true" list:"user" get:"user" create:"required"`
+	// receiver | robot | webhook
+	Type string `width:"16" charset:"ascii" nullable:"false" list:"user" get:"user" create:"required"`
+	// id of receiver if type is receiver
+	ReceiverId string `width:"128" charset:"ascii" nullable:"true" index:"true" list:"user" get:"user" create:"optional"`
+	// robot contact type if type is robot
+	Robot string `width:"16" charset:"ascii" nullable:"true" list:"user" get:"user" create:"optional"`
+}
+
+func (sm *SSubscriberManager) AllowCreateItem(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsProjectAllowCreate(userCred, sm)
+}
+
+func (sm *SSubscriberManager) AllowListItems(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) bool {
+	return db.IsProjectAllowList(userCred, sm)
+}
+
+func (s *SSubscriber) AllowGetDetails(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) bool {
+	return s.IsOwner(userCred) || db.IsAdminAllowGet(userCred, s)
+}
+
+func (s *SSubscriber) AllowUpdateItem(ctx context.Context, userCred mcclient.TokenCredential) bool {
+	return s.IsOwner(userCred) || db.IsAdminAllowUpdate(userCred, s)
+}
+
+func (s *SSubscriber) AllowDeleteItem(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return s.IsOwner(userCred) || db.IsAdminAllowDelete(userCred, s)
+}
+
+func (sm *SSubscriberManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, input api.SubscriberCreateInput) (api.SubscriberCreateInput, error) {
+	var err error
+	if len(input.TopicId) == 0 {
+		return input, httperrors.NewMissingParameterError("topic_id")
+	}
+	topicObj, err := TopicManager.FetchByIdOrName(userCred, input.TopicId)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return input, httperrors.NewResourceNotFoundError2(TopicManager.Keyword(), input.TopicId)
+		}
+		return input, errors.Wrap(err, "fetch topic")
+	}
+	input.TopicId = topicObj.GetId()
+
+	switch input.Type {
+	case api.SUBSCRIBER_TYPE_RECEIVER:
+		if len(input.Receiver) == 0 {
+			return input, httperrors.NewMissingParameterError("receiver")
+		}
+		receivers, err := ReceiverManager.FetchByIdOrNames(ctx, input.Receiver)
+		if err != nil {
+			return input, errors.Wrap(err, "fetch receiver")
+		}
+		if len(receivers) == 0 {
+			return input, httperrors.NewResourceNotFoundError2(ReceiverManager.Keyword(), input.Receiver)
+		}
+		if len(receivers) > 1 {
+			return input, httperrors.NewDuplicateResourceError("receiver %s", input.Receiver)
+		}
+		// only admin can subscribe for receivers of other domains
+		if receivers[0].DomainId != ownerId.GetProjectDomainId() && !db.IsAdminAllowCreate(userCred, sm) {
+			return input, httperrors.NewForbiddenError("receiver %s is not in domain %s", input.Receiver, ownerId.GetProjectDomainId())
+		}
+		input.Receiver = receivers[0].Id
+		input.Robot = ""
+	case api.SUBSCRIBER_TYPE_ROBOT:
+		if !utils.IsInStringArray(input.Robot, AllRobotContactTypes) {
+			return input, httperrors.NewInputParameterError("invalid robot %q, should be one of %v", input.Robot, AllRobotContactTypes)
+		}
+		input.Receiver = ""
+	case api.SUBSCRIBER_TYPE_WEBHOOK:
+		input.Receiver = ""
+		input.Robot = ""
+	default:
+		return input, httperrors.NewInputParameterError("invalid type %q", input.Type)
+	}
+
+	input.ScopedResourceCreateInput, err = sm.SScopedResourceBaseManager.ValidateCreateData(sm, ctx, userCred, ownerId, query, input.ScopedResourceCreateInput)
+	if err != nil {
+		return input, err
+	}
+	input.StandaloneResourceCreateInput, err = sm.SStandaloneResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, input.StandaloneResourceCreateInput)
+	if err != nil {
+		return input, err
+	}
+	return input, nil
+}
+
+func (s *SSubscriber) CustomizeCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data jsonutils.JSONObject) error {
+	s.ReceiverId, _ = data.GetString("receiver")
+	if !data.Contains("enabled") {
+		s.SetEnabled(true)
+	}
+	return s.SScopedResourceBase.CustomizeCreate(ctx, userCred, ownerId, query, data)
+}
+
+func (sm *SSubscriberManager) ListItemFilter(ctx context.Context, q *sqlchemy.SQuery, userCred mcclient.TokenCredential, input api.SubscriberListInput) (*sqlchemy.SQuery, error) {
+	q, err := sm.SStandaloneResourceBaseManager.ListItemFilter(ctx, q, userCred, input.StandaloneResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SStandaloneResourceBaseManager.ListItemFilter")
+	}
+	q, err = sm.SScopedResourceBaseManager.ListItemFilter(ctx, q, userCred, input.ScopedResourceBaseListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SScopedResourceBaseManager.ListItemFilter")
+	}
+	q, err = sm.SEnabledResourceBaseManager.ListItemFilter(ctx, q, userCred, input.EnabledResourceBaseListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SEnabledResourceBaseManager.ListItemFilter")
+	}
+	if len(input.TopicId) > 0 {
+		topicObj, err := TopicManager.FetchByIdOrName(userCred, input.TopicId)
+		if err != nil {
+			if errors.Cause(err) == sql.ErrNoRows {
+				return nil, httperrors.NewResourceNotFoundError2(TopicManager.Keyword(), input.TopicId)
+			}
+			return nil, errors.Wrap(err, "fetch topic")
+		}
+		q = q.Equals("topic_id", topicObj.GetId())
+	}
+	if len(input.Type) > 0 {
+		q = q.Equals("type", input.Type)
+	}
+	if len(input.Receiver) > 0 {
+		receivers, err := ReceiverManager.FetchByIdOrNames(ctx, input.Receiver)
+		if err != nil {
+			return nil, errors.Wrap(err, "fetch receiver")
+		}
+		ids := make([]string, 0, len(receivers))
+		for i := range receivers {
+			ids = append(ids, receivers[i].Id)
+		}
+		q = q.In("receiver_id", ids)
+	}
+	return q, nil
+}
+
+func (sm *SSubscriberManager) OrderByExtraFields(ctx context.Context, q *sqlchemy.SQuery, userCred mcclient.TokenCredential, input api.SubscriberListInput) (*sqlchemy.SQuery, error) {
+	q, err := sm.SStandaloneResourceBaseManager.OrderByExtraFields(ctx, q, userCred, input.StandaloneResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SStandaloneResourceBaseManager.OrderByExtraFields")
+	}
+	q, err = sm.SScopedResourceBaseManager.OrderByExtraFields(ctx, q, userCred, input.ScopedResourceBaseListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SScopedResourceBaseManager.OrderByExtraFields")
+	}
+	return q, nil
+}
+
+func (sm *SSubscriberManager) QueryDistinctExtraField(q *sqlchemy.SQuery, field string) (*sqlchemy.SQuery, error) {
+	q, err := sm.SStandaloneResourceBaseManager.QueryDistinctExtraField(q, field)
+	if err == nil {
+		return q, nil
+	}
+	return sm.SScopedResourceBaseManager.QueryDistinctExtraField(q, field)
+}
+
+func (sm *SSubscriberManager) FetchCustomizeColumns(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	objs []interface{},
+	fields stringutils2.SSortedStrings,
+	isList bool,
+) []api.SubscriberDetails {
+	rows := make([]api.SubscriberDetails, len(objs))
+	stdRows := sm.SStandaloneResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
+	scopedRows := sm.SScopedResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
+
+	topicIds := make([]string, 0, len(objs))
+	receiverIds := make([]string, 0, len(objs))
+	for i := range objs {
+		s := objs[i].(*SSubscriber)
+		topicIds = append(topicIds, s.TopicId)
+		if len(s.ReceiverId) > 0 {
+			receiverIds = append(receiverIds, s.ReceiverId)
+		}
+	}
+	topics := make(map[string]STopic)
+	db.FetchStandaloneObjectsByIds(TopicManager, topicIds, &topics)
+	receivers := make(map[string]SReceiver)
+	db.FetchStandaloneObjectsByIds(ReceiverManager, receiverIds, &receivers)
+
+	for i := range rows {
+		s := objs[i].(*SSubscriber)
+		rows[i].StandaloneResourceDetails = stdRows[i]
+		rows[i].ScopedResourceBaseInfo = scopedRows[i]
+		if topic, ok := topics[s.TopicId]; ok {
+			rows[i].Topic = topic.Name
+		}
+		if receiver, ok := receivers[s.ReceiverId]; ok {
+			rows[i].Receiver = receiver.Name
+		}
+	}
+	return rows
+}
+
+func (s *SSubscriber) GetExtraDetails(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, isList bool) (api.SubscriberDetails, error) {
+	return api.SubscriberDetails{}, nil
+}
+
+func (s *SSubscriber) AllowPerformEnable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformEnableInput) bool {
+	return s.IsOwner(userCred) || db.IsAdminAllowPerform(userCred, s, "enable")
+}
+
+func (s *SSubscriber) PerformEnable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformEnableInput) (jsonutils.JSONObject, error) {
+	err := db.EnabledPerformEnable(s, ctx, userCred, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "EnabledPerformEnable")
+	}
+	return nil, nil
+}
+
+func (s *SSubscriber) AllowPerformDisable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformDisableInput) bool {
+	return s.IsOwner(userCred) || db.IsAdminAllowPerform(userCred, s, "disable")
+}
+
+func (s *SSubscriber) PerformDisable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformDisableInput) (jsonutils.JSONObject, error) {
+	err := db.EnabledPerformEnable(s, ctx, userCred, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "EnabledPerformEnable")
+	}
+	return nil, nil
+}
+
+func (sm *SSubscriberManager) ListItemExportKeys(ctx context.Context, q *sqlchemy.SQuery, userCred mcclient.TokenCredential, keys stringutils2.SSortedStrings) (*sqlchemy.SQuery, error) {
+	return db.ApplyListItemExportKeys(ctx, q, userCred, keys,
+		&sm.SStandaloneResourceBaseManager,
+		&sm.SScopedResourceBaseManager,
+	)
+}
+
+func (sm *SSubscriberManager) ResourceScope() rbacutils.TRbacScope {
+	return sm.SScopedResourceBaseManager.ResourceScope()
+}
+
+func (sm *SSubscriberManager) FetchOwnerId(ctx context.Context, data jsonutils.JSONObject) (mcclient.IIdentityProvider, error) {
+	return sm.SScopedResourceBaseManager.FetchOwnerId(ctx, data)
+}
+
+func (sm *SSubscriberManager) FilterByOwner(q *sqlchemy.SQuery, owner mcclient.IIdentityProvider, scope rbacutils.TRbacScope) *sqlchemy.SQuery {
+	return sm.SScopedResourceBaseManager.FilterByOwner(q, owner, scope)
+}
+
+func (s *SSubscriber) GetOwnerId() mcclient.IIdentityProvider {
+	return s.SScopedResourceBase.GetOwnerId()
+}
+
+// GetSubscribers returns the enabled subscribers of topics whose scope
+// covers the resource of projectDomainId and projectId.
+func (sm *SSubscriberManager) GetSubscribers(topicIds []string, projectDomainId, projectId string) ([]SSubscriber, error) {
+	if len(topicIds) == 0 {
+		return []SSubscriber{}, nil
+	}
+	q := sm.Query().In("topic_id", topicIds).IsTrue("enabled")
+	conds := []sqlchemy.ICondition{
+		// system scope
+		sqlchemy.AND(
+			sqlchemy.IsNullOrEmpty(q.Field("domain_id")),
+			sqlchemy.IsNullOrEmpty(q.Field("tenant_id")),
+		),
+	}
+	if len(projectDomainId) > 0 {
+		conds = append(conds, sqlchemy.AND(
+			sqlchemy.Equals(q.Field("domain_id"), projectDomainId),
+			sqlchemy.IsNullOrEmpty(q.Field("tenant_id")),
+		))
+	}
+	if len(projectId) > 0 {
+		conds = append(conds, sqlchemy.Equals(q.Field("tenant_id"), projectId))
+	}
+	q = q.Filter(sqlchemy.OR(conds...))
+	subscribers := make([]SSubscriber, 0)
+	err := db.FetchModelObjects(sm, q, &subscribers)
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, err
+	}
+	return subscribers, nil
+}
+
+// subscriberContacts groups subscribers by contact type, receivers of type
+// receiver are returned separately as their contact types are not known yet.
+func subscriberContacts(subscribers []SSubscriber) (map[string][]string, []string) {
+	ctReceivers := make(map[string][]string)
+	receiverIds := sets.NewString()
+	for i := range subscribers {
+		switch subscribers[i].Type {
+		case api.SUBSCRIBER_TYPE_RECEIVER:
+			receiverIds.Insert(subscribers[i].ReceiverId)
+		case api.SUBSCRIBER_TYPE_ROBOT:
+			ctReceivers[subscribers[i].Robot] = nil
+		case api.SUBSCRIBER_TYPE_WEBHOOK:
+			ctReceivers[api.WEBHOOK] = nil
+		}
+	}
+	return ctReceivers, receiverIds.List()
+}
@@ -0,0 +1,45 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"reflect"
+	"testing"
+
+	api "yunion.io/x/onecloud/pkg/apis/notify"
+)
+
+func TestSubscriberContacts(t *testing.T) {
+	subscribers := []SSubscriber{
+		{Type: api.SUBSCRIBER_TYPE_RECEIVER, ReceiverId: "r2"},
+		{Type: api.SUBSCRIBER_TYPE_RECEIVER, ReceiverId: "r1"},
+		// the same receiver subscribed through another topic
+		{Type: api.SUBSCRIBER_TYPE_RECEIVER, ReceiverId: "r2"},
+		{Type: api.SUBSCRIBER_TYPE_ROBOT, Robot: api.FEISHU_ROBOT},
+		{Type: api.SUBSCRIBER_TYPE_WEBHOOK},
+		{Type: api.SUBSCRIBER_TYPE_WEBHOOK},
+	}
+	ctReceivers, receiverIds := subscriberContacts(subscribers)
+	wantCt := map[string][]string{
+		api.FEISHU_ROBOT: nil,
+		api.WEBHOOK:      nil,
+	}
+	if !reflect.DeepEqual(ctReceivers, wantCt) {
+		t.Errorf("subscriberContacts() contacts = %v, want %v", ctReceivers, wantCt)
+	}
+	if want := []string{"r1", "r2"}; !reflect.DeepEqual(receiverIds, want) {
+		t.Errorf("subscriberContacts() receivers = %v, want %v", receiverIds, want)
+	}
+}
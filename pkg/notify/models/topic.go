@@ -0,0 +1,366 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"database/sql"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	api "yunion.io/x/onecloud/pkg/apis/notify"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/bitmap"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
+	"yunion.io/x/onecloud/pkg/util/stringutils2"
+)
+
+type STopicManager struct {
+	db.SStandaloneResourceBaseManager
+	db.SEnabledResourceBaseManager
+}
+
+var TopicManager *STopicManager
+
+func init() {
+	TopicManager = &STopicManager{
+		SStandaloneResourceBaseManager: db.NewStandaloneResourceBaseManager(
+			STopic{},
+			"topics_tbl",
+			"topic",
+			"topics",
+		),
+	}
+	TopicManager.SetVirtualObject(TopicManager)
+}
+
+// STopic is a class of resource events, an event belongs to the topic
+// if both of its resource type and action are included in the topic.
+type STopic struct {
+	db.SStandaloneResourceBase
+	db.SEnabledResourceBase
+
+	// bitmap of api.TopicResources
+	Resources uint32 `nullable:"false"`
+	// bitmap of api.TopicActions
+	Actions uint32 `nullable:"false"`
+}
+
+func indexOf(s string, array []string) int {
+	for i := range array {
+		if array[i] == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func toBitmap(values []string, all []string, field string) (uint32, error) {
+	idxs := make([]int, 0, len(values))
+	for _, v := range values {
+		idx := indexOf(v, all)
+		if idx < 0 {
+			return 0, httperrors.NewInputParameterError("invalid %s %q, should be one of %v", field, v, all)
+		}
+		idxs = append(idxs, idx)
+	}
+	return bitmap.IntArray2Uint(idxs), nil
+}
+
+func fromBitmap(n uint32, all []string) []string {
+	ret := make([]string, 0, 2)
+	for _, idx := range bitmap.Uint2IntArray(n) {
+		if idx < len(all) {
+			ret = append(ret, all[idx])
+		}
+	}
+	return ret
+}
+
+func (t *STopic) GetResources() []string {
+	return fromBitmap(t.Resources, api.TopicResources)
+}
+
+func (t *STopic) GetActions() []string {
+	return fromBitmap(t.Actions, api.TopicActions)
+}
+
+func (tm *STopicManager) InitializeData() error {
+	defaults := []struct {
+		name      string
+		resources []string
+		actions   []string
+	}{
+		{
+			name:      api.DefaultResourceCreate,
+			resources: []string{api.TOPIC_RESOURCE_SERVER, api.TOPIC_RESOURCE_DISK, api.TOPIC_RESOURCE_CLOUDACCOUNT},
+			actions:   []string{api.ACTION_CREATE},
+		},
+		{
+			name:      api.DefaultResourceDelete,
+			resources: []string{api.TOPIC_RESOURCE_SERVER, api.TOPIC_RESOURCE_DISK, api.TOPIC_RESOURCE_CLOUDACCOUNT},
+			actions:   []string{api.ACTION_DELETE},
+		},
+		{
+			name:      api.DefaultResourceFailure,
+			resources: []string{api.TOPIC_RESOURCE_SERVER, api.TOPIC_RESOURCE_DISK, api.TOPIC_RESOURCE_CLOUDACCOUNT},
+			actions:   []string{api.ACTION_FAILED},
+		},
+		{
+			name:      api.DefaultAlertTrigger,
+			resources: []string{api.TOPIC_RESOURCE_ALERT},
+			actions:   []string{api.ACTION_TRIGGER},
+		},
+	}
+	for _, d := range defaults {
+		cnt, err := tm.Query().Equals("name", d.name).CountWithError()
+		if err != nil {
+			return errors.Wrapf(err, "count topic %s", d.name)
+		}
+		if cnt > 0 {
+			continue
+		}
+		topic := &STopic{}
+		topic.Id = db.DefaultUUIDGenerator()
+		topic.Name = d.name
+		topic.Resources, _ = toBitmap(d.resources, api.TopicResources, "resource")
+		topic.Actions, _ = toBitmap(d.actions, api.TopicActions, "action")
+		topic.SetEnabled(true)
+		topic.SetModelManager(tm, topic)
+		if err := tm.TableSpec().Insert(context.Background(), topic); err != nil {
+			return errors.Wrapf(err, "insert topic %s", d.name)
+		}
+		log.Infof("init topic %s", d.name)
+	}
+	return nil
+}
+
+// GetTopicsByEvent returns the enabled topics which the event belongs to
+func (tm *STopicManager) GetTopicsByEvent(resourceType, action string) ([]STopic, error) {
+	ridx, aidx := indexOf(resourceType, api.TopicResources), indexOf(action, api.TopicActions)
+	if ridx < 0 || aidx < 0 {
+		return []STopic{}, nil
+	}
+	rbit, abit := 1<<uint(ridx), 1<<uint(aidx)
+	q := tm.Query().IsTrue("enabled")
+	q = q.Filter(sqlchemy.Equals(sqlchemy.AND_Val("", q.Field("resources"), rbit), rbit))
+	q = q.Filter(sqlchemy.Equals(sqlchemy.AND_Val("", q.Field("actions"), abit), abit))
+	topics := make([]STopic, 0, 2)
+	err := db.FetchModelObjects(tm, q, &topics)
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, err
+	}
+	return topics, nil
+}
+
+func (tm *STopicManager) ResourceScope() rbacutils.TRbacScope {
+	return rbacutils.ScopeSystem
+}
+
+func (tm *STopicManager) AllowCreateItem(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowCreate(userCred, tm)
+}
+
+func (tm *STopicManager) AllowListItems(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) bool {
+	return true
+}
+
+func (t *STopic) AllowGetDetails(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) bool {
+	return true
+}
+
+func (t *STopic) AllowUpdateItem(ctx context.Context, userCred mcclient.TokenCredential) bool {
+	return db.IsAdminAllowUpdate(userCred, t)
+}
+
+func (t *STopic) AllowDeleteItem(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowDelete(userCred, t)
+}
+
+func (tm *STopicManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, input api.TopicCreateInput) (api.TopicCreateInput, error) {
+	var err error
+	if len(input.Resources) == 0 {
+		return input, httperrors.NewMissingParameterError("resources")
+	}
+	if len(input.Actions) == 0 {
+		return input, httperrors.NewMissingParameterError("actions")
+	}
+	if _, err = toBitmap(input.Resources, api.TopicResources, "resource"); err != nil {
+		return input, err
+	}
+	if _, err = toBitmap(input.Actions, api.TopicActions, "action"); err != nil {
+		return input, err
+	}
+	input.StandaloneResourceCreateInput, err = tm.SStandaloneResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, input.StandaloneResourceCreateInput)
+	if err != nil {
+		return input, err
+	}
+	return input, nil
+}
+
+func (t *STopic) CustomizeCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data jsonutils.JSONObject) error {
+	input := api.TopicCreateInput{}
+	if err := data.Unmarshal(&input); err != nil {
+		return errors.Wrap(err, "unmarshal topic create input")
+	}
+	t.Resources, _ = toBitmap(input.Resources, api.TopicResources, "resource")
+	t.Actions, _ = toBitmap(input.Actions, api.TopicActions, "action")
+	if input.Enabled == nil {
+		t.SetEnabled(true)
+	}
+	return t.SStandaloneResourceBase.CustomizeCreate(ctx, userCred, ownerId, query, data)
+}
+
+func (t *STopic) ValidateUpdateData(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.TopicUpdateInput) (api.TopicUpdateInput, error) {
+	if _, err := toBitmap(input.Resources, api.TopicResources, "resource"); err != nil {
+		return input, err
+	}
+	if _, err := toBitmap(input.Actions, api.TopicActions, "action"); err != nil {
+		return input, err
+	}
+	var err error
+	input.StandaloneResourceBaseUpdateInput, err = t.SStandaloneResourceBase.ValidateUpdateData(ctx, userCred, query, input.StandaloneResourceBaseUpdateInput)
+	if err != nil {
+		return input, err
+	}
+	return input, nil
+}
+
+func (t *STopic) PostUpdate(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) {
+	t.SStandaloneResourceBase.PostUpdate(ctx, userCred, query, data)
+	input := api.TopicUpdateInput{}
+	if err := data.Unmarshal(&input); err != nil {
+		log.Errorf("unmarshal topic update input: %v", err)
+		return
+	}
+	if len(input.Resources) == 0 && len(input.Actions) == 0 {
+		return
+	}
+	_, err := db.Update(t, func() error {
+		if len(input.Resources) > 0 {
+			t.Resources, _ = toBitmap(input.Resources, api.TopicResources, "resource")
+		}
+		if len(input.Actions) > 0 {
+			t.Actions, _ = toBitmap(input.Actions, api.TopicActions, "action")
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("update topic %s: %v", t.Name, err)
+	}
+}
+
+func (t *STopic) ValidateDeleteCondition(ctx context.Context) error {
+	cnt, err := SubscriberManager.Query().Equals("topic_id", t.Id).CountWithError()
+	if err != nil {
+		return errors.Wrap(err, "count subscribers")
+	}
+	if cnt > 0 {
+		return httperrors.NewNotEmptyError("topic %s has %d subscribers", t.Name, cnt)
+	}
+	return t.SStandaloneResourceBase.ValidateDeleteCondition(ctx)
+}
+
+func (tm *STopicManager) ListItemFilter(ctx context.Context, q *sqlchemy.SQuery, userCred mcclient.TokenCredential, input api.TopicListInput) (*sqlchemy.SQuery, error) {
+	q, err := tm.SStandaloneResourceBaseManager.ListItemFilter(ctx, q, userCred, input.StandaloneResourceListInput)
+	if err != nil {
+		return nil, err
+	}
+	q, err = tm.SEnabledResourceBaseManager.ListItemFilter(ctx, q, userCred, input.EnabledResourceBaseListInput)
+	if err != nil {
+		return nil, err
+	}
+	if len(input.Resource) > 0 || len(input.Action) > 0 {
+		topics := make([]STopic, 0)
+		err := db.FetchModelObjects(tm, tm.Query(), &topics)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(topics))
+		for i := range topics {
+			resources, actions := topics[i].GetResources(), topics[i].GetActions()
+			if len(input.Resource) > 0 && indexOf(input.Resource, resources) < 0 {
+				continue
+			}
+			if len(input.Action) > 0 && indexOf(input.Action, actions) < 0 {
+				continue
+			}
+			ids = append(ids, topics[i].Id)
+		}
+		q = q.In("id", ids)
+	}
+	return q, nil
+}
+
+func (tm *STopicManager) OrderByExtraFields(ctx context.Context, q *sqlchemy.SQuery, userCred mcclient.TokenCredential, input api.TopicListInput) (*sqlchemy.SQuery, error) {
+	return tm.SStandaloneResourceBaseManager.OrderByExtraFields(ctx, q, userCred, input.StandaloneResourceListInput)
+}
+
+func (tm *STopicManager) QueryDistinctExtraField(q *sqlchemy.SQuery, field string) (*sqlchemy.SQuery, error) {
+	return tm.SStandaloneResourceBaseManager.QueryDistinctExtraField(q, field)
+}
+
+func (tm *STopicManager) FetchCustomizeColumns(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	objs []interface{},
+	fields stringutils2.SSortedStrings,
+	isList bool,
+) []api.TopicDetails {
+	rows := make([]api.TopicDetails, len(objs))
+	stdRows := tm.SStandaloneResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
+	for i := range rows {
+		topic := objs[i].(*STopic)
+		rows[i].StandaloneResourceDetails = stdRows[i]
+		rows[i].Resources = topic.GetResources()
+		rows[i].Actions = topic.GetActions()
+	}
+	return rows
+}
+
+func (t *STopic) GetExtraDetails(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, isList bool) (api.TopicDetails, error) {
+	return api.TopicDetails{}, nil
+}
+
+func (t *STopic) AllowPerformEnable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformEnableInput) bool {
+	return db.IsAdminAllowPerform(userCred, t, "enable")
+}
+
+func (t *STopic) PerformEnable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformEnableInput) (jsonutils.JSONObject, error) {
+	err := db.EnabledPerformEnable(t, ctx, userCred, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "EnabledPerformEnable")
+	}
+	return nil, nil
+}
+
+func (t *STopic) AllowPerformDisable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformDisableInput) bool {
+	return db.IsAdminAllowPerform(userCred, t, "disable")
+}
+
+func (t *STopic) PerformDisable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformDisableInput) (jsonutils.JSONObject, error) {
+	err := db.EnabledPerformEnable(t, ctx, userCred, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "EnabledPerformEnable")
+	}
+	return nil, nil
+}
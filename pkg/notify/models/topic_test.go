@@ -0,0 +1,50 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"reflect"
+	"testing"
+
+	api "yunion.io/x/onecloud/pkg/apis/notify"
+)
+
+func newTestTopic(t *testing.T, resources, actions []string) *STopic {
+	topic := &STopic{}
+	var err error
+	if topic.Resources, err = toBitmap(resources, api.TopicResources, "resource"); err != nil {
+		t.Fatalf("toBitmap(%v): %v", resources, err)
+	}
+	if topic.Actions, err = toBitmap(actions, api.TopicActions, "action"); err != nil {
+		t.Fatalf("toBitmap(%v): %v", actions, err)
+	}
+	return topic
+}
+
+func TestTopicBitmap(t *testing.T) {
+	topic := newTestTopic(t,
+		[]string{api.TOPIC_RESOURCE_DISK, api.TOPIC_RESOURCE_SERVER},
+		[]string{api.ACTION_DELETE},
+	)
+	if got, want := topic.GetResources(), []string{api.TOPIC_RESOURCE_SERVER, api.TOPIC_RESOURCE_DISK}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetResources() = %v, want %v", got, want)
+	}
+	if got, want := topic.GetActions(), []string{api.ACTION_DELETE}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetActions() = %v, want %v", got, want)
+	}
+	if _, err := toBitmap([]string{"network"}, api.TopicResources, "resource"); err == nil {
+		t.Errorf("toBitmap() of unknown resource should fail")
+	}
+}
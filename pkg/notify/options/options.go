@@ -26,7 +26,13 @@ type NotifyOption struct {
 	UpdateInterval int    `help:"Update send services interval(unit:min)" default:"30"`
 
 	ReSendScope  int `help:"Resend all messages that have not been sent successfully within ReSendScope seconds" default:"60"`
-	MaxSendTimes int `help:"Resend all messages whose sendTimes less than MaxSendTimes" default:"2"`
+	MaxSendTimes int `help:"Max delivery attempts of each receiver, the notification is dead-lettered for the receiver after that" default:"5"`
+
+	SendRetryIntervalSeconds    int `help:"Backoff interval before the first retry of a failed delivery, doubled on each attempt" default:"30"`
+	SendRetryMaxIntervalSeconds int `help:"Max backoff interval between retries of a failed delivery" default:"3600"`
+	SendTimeoutSeconds          int `help:"Deliveries still sending after SendTimeoutSeconds are considered failed and retried" default:"300"`
+
+	ChannelRateLimits []string `help:"Rate limit of sending through a channel, format: <contact_type>:<messages per second>[:<burst>], e.g. dingtalk:20:20"`
 
 	InitNotificationScope int `help:"initialize data of notification with in InitNotificationScope hours" default:"100"`
 	MaxSyncNotification   int `help:"The max number of notification sync from old data source" default:"1000"`
@@ -0,0 +1,78 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpc
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"yunion.io/x/pkg/errors"
+)
+
+// sRateLimiters keeps a token bucket per contact type, so that bulk events
+// won't trip the throttles of the providers.
+type sRateLimiters struct {
+	lock     sync.RWMutex
+	limiters map[string]*rate.Limiter
+}
+
+func newRateLimiters() *sRateLimiters {
+	return &sRateLimiters{
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+func (rl *sRateLimiters) get(contactType string) *rate.Limiter {
+	rl.lock.RLock()
+	defer rl.lock.RUnlock()
+	return rl.limiters[contactType]
+}
+
+func (rl *sRateLimiters) set(contactType string, limit float64, burst int) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	if limit <= 0 {
+		delete(rl.limiters, contactType)
+		return
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(limit))
+	}
+	rl.limiters[contactType] = rate.NewLimiter(rate.Limit(limit), burst)
+}
+
+// parseRateLimit parses rate limit in format <contact_type>:<messages per second>[:<burst>]
+func parseRateLimit(str string) (string, float64, int, error) {
+	segs := strings.Split(str, ":")
+	if len(segs) < 2 || len(segs) > 3 || len(segs[0]) == 0 {
+		return "", 0, 0, errors.Errorf("invalid rate limit %q, should be <contact_type>:<messages per second>[:<burst>]", str)
+	}
+	limit, err := strconv.ParseFloat(segs[1], 64)
+	if err != nil {
+		return "", 0, 0, errors.Wrapf(err, "invalid rate of %q", str)
+	}
+	burst := 0
+	if len(segs) == 3 {
+		burst, err = strconv.Atoi(segs[2])
+		if err != nil {
+			return "", 0, 0, errors.Wrapf(err, "invalid burst of %q", str)
+		}
+	}
+	return segs[0], limit, burst, nil
+}
@@ -24,6 +24,7 @@ import (
 	"strings"
 	"time"
 
+	"golang.org/x/time/rate"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -52,6 +53,7 @@ type SRpcService struct {
 	socketFileDir string
 	configStore   notifyv2.IServiceConfigStore
 	templateStore notifyv2.ITemplateStore
+	rateLimiters  *sRateLimiters
 }
 
 // NewSRpcService create a SRpcService
@@ -62,9 +64,23 @@ func NewSRpcService(socketFileDir string, configStore notifyv2.IServiceConfigSto
 		socketFileDir: socketFileDir,
 		configStore:   configStore,
 		templateStore: tempalteStore,
+		rateLimiters:  newRateLimiters(),
 	}
 }
 
+// SetRateLimits limits the messages sent through the channels, each of the
+// limits is in format <contact_type>:<messages per second>[:<burst>].
+func (self *SRpcService) SetRateLimits(limits []string) error {
+	for _, str := range limits {
+		contactType, limit, burst, err := parseRateLimit(str)
+		if err != nil {
+			return err
+		}
+		self.rateLimiters.set(contactType, limit, burst)
+	}
+	return nil
+}
+
 // InitAll init all Send Services, the init process is that:
 // find all socket file in directory 'self.socketFileDir', if wrong return error;
 // the name of file is the service's name; then try to dial to this rpc service
@@ -118,6 +134,12 @@ func (self *SRpcService) Send(ctx context.Context, p notify.SSendParams) error {
 	args.Contact = p.Contact
 	args.Priority = p.Priority
 
+	if limiter := self.rateLimiters.get(p.ContactType); limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return errors.Wrapf(err, "wait for rate limit of %s", p.ContactType)
+		}
+	}
+
 	f := func(service *apis.SendNotificationClient) (interface{}, error) {
 		log.Debugf("send one")
 		return service.Send(ctx, &args)
@@ -140,19 +162,53 @@ func (self *SRpcService) BatchSend(ctx context.Context, p notify.SBatchSendParam
 		return nil, errors.Wrap(err, "templateStore.NotifyFilter")
 	}
 
-	batchSendParams := apis.BatchSendParams{
-		Contacts:       p.Contacts,
-		Title:          args.Title,
-		Message:        args.Message,
-		Priority:       args.Priority,
-		RemoteTemplate: args.RemoteTemplate,
+	// split contacts into batches no larger than the burst of rate limit
+	limiter := self.rateLimiters.get(p.ContactType)
+	batchSize := len(p.Contacts)
+	if limiter != nil && limiter.Burst() < batchSize {
+		batchSize = limiter.Burst()
+	}
+
+	failedRecords := make([]*apis.FailedRecord, 0)
+	for start := 0; start < len(p.Contacts); start += batchSize {
+		end := start + batchSize
+		if end > len(p.Contacts) {
+			end = len(p.Contacts)
+		}
+		records, err := self.batchSend(ctx, limiter, p.ContactType, apis.BatchSendParams{
+			Contacts:       p.Contacts[start:end],
+			Title:          args.Title,
+			Message:        args.Message,
+			Priority:       args.Priority,
+			RemoteTemplate: args.RemoteTemplate,
+		})
+		if err != nil {
+			if start == 0 {
+				return nil, err
+			}
+			// the previous batches have been sent, only fail the rest
+			for _, contact := range p.Contacts[start:] {
+				failedRecords = append(failedRecords, &apis.FailedRecord{Contact: contact, Reason: err.Error()})
+			}
+			break
+		}
+		failedRecords = append(failedRecords, records...)
+	}
+	return failedRecords, nil
+}
+
+func (self *SRpcService) batchSend(ctx context.Context, limiter *rate.Limiter, contactType string, params apis.BatchSendParams) ([]*apis.FailedRecord, error) {
+	if limiter != nil {
+		if err := limiter.WaitN(ctx, len(params.Contacts)); err != nil {
+			return nil, errors.Wrapf(err, "wait for rate limit of %s", contactType)
+		}
 	}
 
 	f := func(service *apis.SendNotificationClient) (interface{}, error) {
-		return service.BatchSend(ctx, &batchSendParams)
+		return service.BatchSend(ctx, &params)
 	}
 
-	ret, err := self.execute(ctx, f, p.ContactType)
+	ret, err := self.execute(ctx, f, contactType)
 	if err != nil {
 		s, ok := status.FromError(err)
 		if !ok {
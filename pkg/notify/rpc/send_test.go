@@ -0,0 +1,210 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpc
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"yunion.io/x/pkg/errors"
+
+	notifyv2 "yunion.io/x/onecloud/pkg/notify"
+	"yunion.io/x/onecloud/pkg/notify/rpc/apis"
+)
+
+// fakePlugin is a channel plugin which records the batches it received and
+// fails the contacts in failContacts.
+type fakePlugin struct {
+	apis.UnimplementedSendAgentServer
+
+	lock         sync.Mutex
+	batches      [][]string
+	failContacts map[string]string
+}
+
+func (p *fakePlugin) UpdateConfig(ctx context.Context, req *apis.UpdateConfigParams) (*apis.Empty, error) {
+	return &apis.Empty{}, nil
+}
+
+func (p *fakePlugin) Send(ctx context.Context, req *apis.SendParams) (*apis.Empty, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.batches = append(p.batches, []string{req.Contact})
+	return &apis.Empty{}, nil
+}
+
+func (p *fakePlugin) BatchSend(ctx context.Context, req *apis.BatchSendParams) (*apis.BatchSendReply, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.batches = append(p.batches, req.Contacts)
+	reply := &apis.BatchSendReply{}
+	for _, contact := range req.Contacts {
+		if reason, ok := p.failContacts[contact]; ok {
+			reply.FailedRecords = append(reply.FailedRecords, &apis.FailedRecord{Contact: contact, Reason: reason})
+		}
+	}
+	return reply, nil
+}
+
+type fakeConfigStore struct{}
+
+func (fakeConfigStore) GetConfig(serviceName string) (notifyv2.SConfig, error) {
+	return notifyv2.SConfig{}, nil
+}
+
+func (fakeConfigStore) SetConfig(serviceName string, config notifyv2.SConfig) error {
+	return nil
+}
+
+type fakeTemplateStore struct{}
+
+func (fakeTemplateStore) NotifyFilter(contactType, topic, msg, lang string) (apis.SendParams, error) {
+	return apis.SendParams{Title: topic, Message: msg}, nil
+}
+
+func startFakePlugin(t *testing.T, dir, contactType string, plugin *fakePlugin) func() {
+	lis, err := net.Listen("unix", filepath.Join(dir, contactType+".sock"))
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	apis.RegisterSendAgentServer(srv, plugin)
+	go srv.Serve(lis)
+	return srv.Stop
+}
+
+func newTestService(t *testing.T, plugin *fakePlugin) (*SRpcService, func()) {
+	dir, err := ioutil.TempDir("", "notify-rpc")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	stop := startFakePlugin(t, dir, "fake", plugin)
+	return NewSRpcService(dir, fakeConfigStore{}, fakeTemplateStore{}), func() {
+		stop()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestBatchSend(t *testing.T) {
+	plugin := &fakePlugin{
+		failContacts: map[string]string{"c3": "throttled"},
+	}
+	service, cleanup := newTestService(t, plugin)
+	defer cleanup()
+
+	if err := service.SetRateLimits([]string{"fake:1000:2"}); err != nil {
+		t.Fatalf("SetRateLimits: %v", err)
+	}
+	records, err := service.BatchSend(context.Background(), notifyv2.SBatchSendParams{
+		ContactType: "fake",
+		Contacts:    []string{"c1", "c2", "c3", "c4", "c5"},
+		Topic:       "topic",
+		Message:     "message",
+	})
+	if err != nil {
+		t.Fatalf("BatchSend: %v", err)
+	}
+	if len(plugin.batches) != 3 {
+		t.Errorf("want 3 batches no larger than burst, got %v", plugin.batches)
+	}
+	for _, batch := range plugin.batches {
+		if len(batch) > 2 {
+			t.Errorf("batch %v larger than burst", batch)
+		}
+	}
+	if len(records) != 1 || records[0].Contact != "c3" || records[0].Reason != "throttled" {
+		t.Errorf("unexpected failed records %v", records)
+	}
+}
+
+func TestBatchSendRateLimit(t *testing.T) {
+	plugin := &fakePlugin{}
+	service, cleanup := newTestService(t, plugin)
+	defer cleanup()
+
+	if err := service.SetRateLimits([]string{"fake:20:1"}); err != nil {
+		t.Fatalf("SetRateLimits: %v", err)
+	}
+	start := time.Now()
+	_, err := service.BatchSend(context.Background(), notifyv2.SBatchSendParams{
+		ContactType: "fake",
+		Contacts:    []string{"c1", "c2", "c3", "c4", "c5"},
+	})
+	if err != nil {
+		t.Fatalf("BatchSend: %v", err)
+	}
+	// the first token is in the bucket, the other 4 take 50ms each
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("sent 5 messages in %s, rate limit not applied", elapsed)
+	}
+	if len(plugin.batches) != 5 {
+		t.Errorf("want 5 batches, got %v", plugin.batches)
+	}
+}
+
+func TestBatchSendServiceNotFound(t *testing.T) {
+	service, cleanup := newTestService(t, &fakePlugin{})
+	defer cleanup()
+
+	_, err := service.BatchSend(context.Background(), notifyv2.SBatchSendParams{
+		ContactType: "missing",
+		Contacts:    []string{"c1"},
+	})
+	if err == nil {
+		t.Fatalf("BatchSend to missing service should fail")
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	cases := []struct {
+		in          string
+		contactType string
+		limit       float64
+		burst       int
+		wantErr     bool
+	}{
+		{in: "email:10", contactType: "email", limit: 10},
+		{in: "dingtalk:0.5:3", contactType: "dingtalk", limit: 0.5, burst: 3},
+		{in: "email", wantErr: true},
+		{in: ":10", wantErr: true},
+		{in: "email:x", wantErr: true},
+		{in: "email:10:x", wantErr: true},
+		{in: "email:10:1:1", wantErr: true},
+	}
+	for _, c := range cases {
+		contactType, limit, burst, err := parseRateLimit(c.in)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%q: want error", c.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", c.in, errors.Cause(err))
+			continue
+		}
+		if contactType != c.contactType || limit != c.limit || burst != c.burst {
+			t.Errorf("%q: got %s %f %d", c.in, contactType, limit, burst)
+		}
+	}
+}
@@ -48,6 +48,8 @@ func InitHandlers(app *appsrv.Application) {
 		models.NotificationManager,
 		models.ConfigManager,
 		models.TemplateManager,
+		models.TopicManager,
+		models.SubscriberManager,
 	} {
 		db.RegisterModelManager(manager)
 		handler := db.NewModelHandler(manager)
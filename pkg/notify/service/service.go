@@ -64,7 +64,12 @@ func StartService() {
 	}
 
 	// init notify service
-	models.NotifyService = rpc.NewSRpcService(opts.SocketFileDir, models.ConfigManager, models.TemplateManager)
+	rpcService := rpc.NewSRpcService(opts.SocketFileDir, models.ConfigManager, models.TemplateManager)
+	err = rpcService.SetRateLimits(opts.ChannelRateLimits)
+	if err != nil {
+		log.Fatalf("invalid channel_rate_limits: %v", err)
+	}
+	models.NotifyService = rpcService
 	models.NotifyService.InitAll()
 	defer models.NotifyService.StopAll()
 
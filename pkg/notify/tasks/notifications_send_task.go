@@ -43,11 +43,16 @@ func (self *NotificationSendTask) OnInit(ctx context.Context, obj db.IStandalone
 		self.SetStageComplete(ctx, nil)
 		return
 	}
-	rns, err := notification.ReceiverNotificationsNotOK()
+	rns, err := notification.ReceiverNotificationsToSend()
 	if err != nil {
 		self.taskFailed(ctx, notification, "fail to fetch ReceiverNotifications", true)
 		return
 	}
+	if len(rns) == 0 {
+		// all the deliveries are backing off or dead-lettered
+		self.SetStageComplete(ctx, nil)
+		return
+	}
 	notification.SetStatus(self.UserCred, apis.NOTIFICATION_STATUS_SENDING, "")
 
 	// split rns
@@ -69,6 +74,11 @@ func (self *NotificationSendTask) OnInit(ctx context.Context, obj db.IStandalone
 		rn.AfterSend(ctx, false, reason)
 		failedRecord = append(failedRecord, fmt.Sprintf("%s: %s", rn.ReceiverID, reason))
 	}
+	// retry won't help for these failures
+	sendDead := func(rn *models.SReceiverNotification, reason string) {
+		rn.DeadLetter(ctx, reason)
+		failedRecord = append(failedRecord, fmt.Sprintf("%s: %s", rn.ReceiverID, reason))
+	}
 
 	// build contactMap
 	contactMap := make(map[string]*models.SReceiverNotification)
@@ -86,7 +96,7 @@ func (self *NotificationSendTask) OnInit(ctx context.Context, obj db.IStandalone
 		}
 		// check receiver enabled
 		if receiver.Enabled.IsFalse() {
-			sendFail(rnsWithReceiver[i], fmt.Sprintf("disabled receiver"))
+			sendDead(rnsWithReceiver[i], fmt.Sprintf("disabled receiver"))
 			continue
 		}
 		// check contact enabled
@@ -96,7 +106,7 @@ func (self *NotificationSendTask) OnInit(ctx context.Context, obj db.IStandalone
 			continue
 		}
 		if !enabled {
-			sendFail(rnsWithReceiver[i], fmt.Sprintf("disabled contactType %q", notification.ContactType))
+			sendDead(rnsWithReceiver[i], fmt.Sprintf("disabled contactType %q", notification.ContactType))
 			continue
 		}
 
@@ -107,7 +117,7 @@ func (self *NotificationSendTask) OnInit(ctx context.Context, obj db.IStandalone
 			continue
 		}
 		if !verified {
-			sendFail(rnsWithReceiver[i], fmt.Sprintf("unverified contactType %q", notification.ContactType))
+			sendDead(rnsWithReceiver[i], fmt.Sprintf("unverified contactType %q", notification.ContactType))
 			continue
 		}
 
@@ -191,6 +201,17 @@ func (self *NotificationSendTask) OnInit(ctx context.Context, obj db.IStandalone
 		self.taskFailed(ctx, notification, strings.Join(failedRecord, "; "), false)
 		return
 	}
+	// deliveries dead-lettered or backing off in the previous rounds
+	rest, err := notification.ReceiverNotificationsNotOK()
+	if err != nil {
+		self.taskFailed(ctx, notification, "fail to fetch ReceiverNotifications", false)
+		return
+	}
+	if len(rest) > 0 {
+		notification.SetStatus(self.UserCred, apis.NOTIFICATION_STATUS_PART_OK, "")
+		self.SetStageComplete(ctx, nil)
+		return
+	}
 	notification.SetStatus(self.UserCred, apis.NOTIFICATION_STATUS_OK, "")
 	logclient.AddActionLogWithContext(ctx, notification, logclient.ACT_SEND_NOTIFICATION, "", self.UserCred, true)
 	self.SetStageComplete(ctx, nil)
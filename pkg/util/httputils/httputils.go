@@ -42,6 +42,7 @@ import (
 	"yunion.io/x/pkg/utils"
 
 	"yunion.io/x/onecloud/pkg/appctx"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 type THttpMethod string
@@ -478,6 +479,14 @@ func requestInternal(client sClient, ctx context.Context, method THttpMethod, ur
 	if len(ctxData.RequestId) > 0 {
 		header.Set("X-Request-Id", ctxData.RequestId)
 	}
+	var span *tracing.SSpan
+	if len(ctxData.Traceparent) > 0 {
+		ctx, span = tracing.StartSpan(ctx, fmt.Sprintf("HTTP %s", method), tracing.SPAN_KIND_CLIENT)
+		span.SetAttribute("http.method", string(method))
+		span.SetAttribute("http.url", urlStr)
+		defer span.End()
+		tracing.InjectHeader(ctx, header)
+	}
 	req, err := http.NewRequest(string(method), urlStr, body)
 	if err != nil {
 		return nil, nil, err
@@ -519,8 +528,17 @@ func requestInternal(client sClient, ctx context.Context, method THttpMethod, ur
 	resp, err := client.Do(req)
 	if err != nil {
 		red(err.Error())
+		if span != nil {
+			span.SetError(err.Error())
+		}
 		return req, nil, err
 	}
+	if span != nil {
+		span.SetAttribute("http.status_code", strconv.Itoa(resp.StatusCode))
+		if resp.StatusCode >= 500 {
+			span.SetError(resp.Status)
+		}
+	}
 	encoding := resp.Header.Get("Content-Encoding")
 	switch encoding {
 	case "", "identity":
@@ -198,4 +198,6 @@ const (
 	ACT_UPDATE_TAGS = "update_tags"
 
 	ACT_SET_ALERT = "set_alert"
+
+	ACT_WEBHOOK_DELIVERY = "webhook_delivery"
 )
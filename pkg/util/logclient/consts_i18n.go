@@ -665,4 +665,8 @@ func init() {
 		EN("Set Alert").
 		CN("配置报警"),
 	)
+	t.Set(ACT_WEBHOOK_DELIVERY, i18n.NewTableEntry().
+		EN("Webhook Delivery").
+		CN("Webhook投递"),
+	)
 }
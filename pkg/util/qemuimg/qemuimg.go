@@ -15,6 +15,7 @@
 package qemuimg
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -213,7 +214,7 @@ func (img *SQemuImage) IsChained() bool {
 	return len(img.BackFilePath) > 0
 }
 
-func (img *SQemuImage) doConvert(name string, format TImageFormat, options []string, compact bool, password string) error {
+func (img *SQemuImage) doConvert(ctx context.Context, name string, format TImageFormat, options []string, compact bool, password string) error {
 	if !img.IsValid() {
 		return fmt.Errorf("self is not valid")
 	}
@@ -234,7 +235,7 @@ func (img *SQemuImage) doConvert(name string, format TImageFormat, options []str
 	}
 	cmdline = append(cmdline, img.Path, name)
 	log.Infof("XXXX qemu-img command: %s", cmdline)
-	cmd := procutils.NewRemoteCommandAsFarAsPossible("ionice", cmdline...)
+	cmd := procutils.NewRemoteCommandContextAsFarAsPossible(ctx, "ionice", cmdline...)
 	var stdin io.WriteCloser
 	var err error
 	if len(img.Password) > 0 || len(password) > 0 {
@@ -267,22 +268,28 @@ func (img *SQemuImage) doConvert(name string, format TImageFormat, options []str
 }
 
 func (img *SQemuImage) Clone(name string, format TImageFormat, compact bool) (*SQemuImage, error) {
+	return img.CloneContext(context.Background(), name, format, compact)
+}
+
+// CloneContext clones img like Clone, the qemu-img process is killed when
+// ctx is done
+func (img *SQemuImage) CloneContext(ctx context.Context, name string, format TImageFormat, compact bool) (*SQemuImage, error) {
 	switch format {
 	case QCOW2:
-		return img.CloneQcow2(name, compact)
+		return img.clone(ctx, name, QCOW2, qcow2CloneOptions(compact), compact, "")
 	case VMDK:
-		return img.CloneVmdk(name, compact)
+		return img.clone(ctx, name, VMDK, vmdkOptions(compact), compact, "")
 	case RAW:
-		return img.CloneRaw(name)
+		return img.clone(ctx, name, RAW, nil, false, "")
 	case VHD:
-		return img.CloneVhd(name)
+		return img.clone(ctx, name, VHD, nil, false, "")
 	default:
 		return nil, ErrUnsupportedFormat
 	}
 }
 
-func (img *SQemuImage) clone(name string, format TImageFormat, options []string, compact bool, password string) (*SQemuImage, error) {
-	err := img.doConvert(name, format, options, compact, password)
+func (img *SQemuImage) clone(ctx context.Context, name string, format TImageFormat, options []string, compact bool, password string) (*SQemuImage, error) {
+	err := img.doConvert(ctx, name, format, options, compact, password)
 	if err != nil {
 		return nil, err
 	}
@@ -291,7 +298,7 @@ func (img *SQemuImage) clone(name string, format TImageFormat, options []string,
 
 func (img *SQemuImage) convert(format TImageFormat, options []string, compact bool, password string) error {
 	tmpPath := fmt.Sprintf("%s.%s", img.Path, utils.GenRequestId(36))
-	err := img.doConvert(tmpPath, format, options, compact, password)
+	err := img.doConvert(context.Background(), tmpPath, format, options, compact, password)
 	if err != nil {
 		return err
 	}
@@ -309,7 +316,7 @@ func (img *SQemuImage) convert(format TImageFormat, options []string, compact bo
 func (img *SQemuImage) convertTo(
 	format TImageFormat, options []string, compact bool, password string, output string,
 ) error {
-	err := img.doConvert(output, format, options, compact, password)
+	err := img.doConvert(context.Background(), output, format, options, compact, password)
 	if err != nil {
 		return err
 	}
@@ -343,6 +350,27 @@ func (img *SQemuImage) Convert2Qcow2To(output string, compact bool) error {
 	return img.convertTo(QCOW2, options, compact, "", output)
 }
 
+// ConvertToExisting copies the whole chain of img into the already created
+// target image, keeping the format of target, e.g. an rbd image
+func (img *SQemuImage) ConvertToExisting(target *SQemuImage) error {
+	if !img.IsValid() {
+		return fmt.Errorf("self is not valid")
+	}
+	if !target.IsValid() {
+		return fmt.Errorf("target %s is not valid", target.Path)
+	}
+	cmdline := []string{"-c", strconv.Itoa(int(img.IoLevel)),
+		qemutils.GetQemuImg(), "convert", "-n",
+		"-f", img.Format.String(), "-O", target.Format.String(),
+		img.Path, target.Path}
+	log.Infof("qemu-img command: %s", cmdline)
+	output, err := procutils.NewRemoteCommandAsFarAsPossible("ionice", cmdline...).Output()
+	if err != nil {
+		return errors.Wrapf(err, "convert %s to %s: %s", img.Path, target.Path, output)
+	}
+	return nil
+}
+
 func (img *SQemuImage) Convert2Qcow2(compact bool) error {
 	options := make([]string, 0)
 	// if len(backPath) > 0 {
@@ -390,7 +418,7 @@ func (img *SQemuImage) Expand() error {
 	return img.Convert2Qcow2(false)
 }
 
-func (img *SQemuImage) CloneQcow2(name string, compact bool) (*SQemuImage, error) {
+func qcow2CloneOptions(compact bool) []string {
 	options := make([]string, 0)
 	//if len(backPath) > 0 {
 	//	options = append(options, fmt.Sprintf("backing_file=%s", backPath))
@@ -399,7 +427,11 @@ func (img *SQemuImage) CloneQcow2(name string, compact bool) (*SQemuImage, error
 		sparseOpts := qcow2SparseOptions()
 		options = append(options, sparseOpts...)
 	}
-	return img.clone(name, QCOW2, options, compact, "")
+	return options
+}
+
+func (img *SQemuImage) CloneQcow2(name string, compact bool) (*SQemuImage, error) {
+	return img.clone(context.Background(), name, QCOW2, qcow2CloneOptions(compact), compact, "")
 }
 
 func vmdkOptions(compact bool) []string {
@@ -419,15 +451,15 @@ func vmdkOptions(compact bool) []string {
 // }
 
 func (img *SQemuImage) CloneVmdk(name string, compact bool) (*SQemuImage, error) {
-	return img.clone(name, VMDK, vmdkOptions(compact), compact, "")
+	return img.clone(context.Background(), name, VMDK, vmdkOptions(compact), compact, "")
 }
 
 func (img *SQemuImage) CloneVhd(name string) (*SQemuImage, error) {
-	return img.clone(name, VHD, nil, false, "")
+	return img.clone(context.Background(), name, VHD, nil, false, "")
 }
 
 func (img *SQemuImage) CloneRaw(name string) (*SQemuImage, error) {
-	return img.clone(name, RAW, nil, false, "")
+	return img.clone(context.Background(), name, RAW, nil, false, "")
 }
 
 func (img *SQemuImage) create(sizeMB int, format TImageFormat, options []string) error {
@@ -0,0 +1,284 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"yunion.io/x/log"
+)
+
+const (
+	OTLP_EXPORT_BATCH_SIZE = 512
+	OTLP_EXPORT_QUEUE_SIZE = 4096
+	OTLP_EXPORT_INTERVAL   = 5 * time.Second
+	OTLP_EXPORT_TIMEOUT    = 10 * time.Second
+
+	otlpStatusCodeError = 2
+)
+
+var (
+	exporterLock sync.RWMutex
+	exporter     *SOTLPExporter
+)
+
+// Init exports the spans of the service to the OTLP/HTTP endpoint,
+// e.g. http://127.0.0.1:4318/v1/traces
+func Init(serviceName string, endpoint string) {
+	exp := NewOTLPExporter(serviceName, endpoint)
+
+	exporterLock.Lock()
+	defer exporterLock.Unlock()
+
+	if exporter != nil {
+		exporter.Shutdown()
+	}
+	exporter = exp
+	log.Infof("Export tracing spans of %s to %s", serviceName, endpoint)
+}
+
+// Flush sends all the pending spans to the collector
+func Flush() {
+	if exp := getExporter(); exp != nil {
+		exp.Flush()
+	}
+}
+
+func getExporter() *SOTLPExporter {
+	exporterLock.RLock()
+	defer exporterLock.RUnlock()
+
+	return exporter
+}
+
+// SOTLPExporter sends spans in batches to the collector with the JSON
+// encoding of OTLP/HTTP
+type SOTLPExporter struct {
+	serviceName string
+	endpoint    string
+	client      *http.Client
+
+	spans   chan *SSpan
+	flushCh chan chan struct{}
+	stopCh  chan struct{}
+}
+
+func NewOTLPExporter(serviceName string, endpoint string) *SOTLPExporter {
+	exp := &SOTLPExporter{
+		serviceName: serviceName,
+		endpoint:    endpoint,
+		client:      &http.Client{Timeout: OTLP_EXPORT_TIMEOUT},
+		spans:       make(chan *SSpan, OTLP_EXPORT_QUEUE_SIZE),
+		flushCh:     make(chan chan struct{}),
+		stopCh:      make(chan struct{}),
+	}
+	go exp.run()
+	return exp
+}
+
+// export never blocks the caller, spans are dropped if the queue is full
+func (exp *SOTLPExporter) export(span *SSpan) {
+	select {
+	case exp.spans <- span:
+	default:
+		log.Debugf("tracing span queue is full, drop span %s", span.Name)
+	}
+}
+
+func (exp *SOTLPExporter) Flush() {
+	done := make(chan struct{})
+	select {
+	case exp.flushCh <- done:
+		<-done
+	case <-exp.stopCh:
+	}
+}
+
+func (exp *SOTLPExporter) Shutdown() {
+	exp.Flush()
+	close(exp.stopCh)
+}
+
+func (exp *SOTLPExporter) run() {
+	ticker := time.NewTicker(OTLP_EXPORT_INTERVAL)
+	defer ticker.Stop()
+
+	batch := make([]*SSpan, 0, OTLP_EXPORT_BATCH_SIZE)
+	send := func() {
+		if len(batch) > 0 {
+			if err := exp.send(batch); err != nil {
+				log.Errorf("export %d tracing spans fail: %s", len(batch), err)
+			}
+			batch = make([]*SSpan, 0, OTLP_EXPORT_BATCH_SIZE)
+		}
+	}
+	for {
+		select {
+		case span := <-exp.spans:
+			batch = append(batch, span)
+			if len(batch) >= OTLP_EXPORT_BATCH_SIZE {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case done := <-exp.flushCh:
+			for drained := false; !drained; {
+				select {
+				case span := <-exp.spans:
+					batch = append(batch, span)
+					if len(batch) >= OTLP_EXPORT_BATCH_SIZE {
+						send()
+					}
+				default:
+					drained = true
+				}
+			}
+			send()
+			close(done)
+		case <-exp.stopCh:
+			return
+		}
+	}
+}
+
+func (exp *SOTLPExporter) send(spans []*SSpan) error {
+	body, err := json.Marshal(exp.encode(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", exp.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := exp.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector responses %s", resp.Status)
+	}
+	return nil
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceId           string         `json:"traceId"`
+	SpanId            string         `json:"spanId"`
+	ParentSpanId      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func otlpAttributes(attrs map[string]string) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ret := make([]otlpKeyValue, len(keys))
+	for i, k := range keys {
+		ret[i] = otlpKeyValue{Key: k, Value: otlpValue{StringValue: attrs[k]}}
+	}
+	return ret
+}
+
+func (exp *SOTLPExporter) encode(spans []*SSpan) otlpTracesRequest {
+	ospans := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		span.lock.Lock()
+		ospan := otlpSpan{
+			TraceId:           span.SpanContext.TraceId.String(),
+			SpanId:            span.SpanContext.SpanId.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: fmt.Sprintf("%d", span.StartTime.UnixNano()),
+			EndTimeUnixNano:   fmt.Sprintf("%d", span.EndTime.UnixNano()),
+			Attributes:        otlpAttributes(span.Attributes),
+		}
+		if span.ParentSpanId.IsValid() {
+			ospan.ParentSpanId = span.ParentSpanId.String()
+		}
+		if span.Failed {
+			ospan.Status = otlpStatus{Code: otlpStatusCodeError, Message: span.StatusMessage}
+		}
+		span.lock.Unlock()
+		ospans[i] = ospan
+	}
+	return otlpTracesRequest{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: otlpAttributes(map[string]string{"service.name": exp.serviceName}),
+				},
+				ScopeSpans: []otlpScopeSpans{
+					{
+						Scope: otlpScope{Name: "yunion.io/x/onecloud"},
+						Spans: ospans,
+					},
+				},
+			},
+		},
+	}
+}
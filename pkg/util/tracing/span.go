@@ -0,0 +1,153 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SpanKind values follow the definition of OTLP
+type SpanKind int
+
+const (
+	SPAN_KIND_INTERNAL = SpanKind(1)
+	SPAN_KIND_SERVER   = SpanKind(2)
+	SPAN_KIND_CLIENT   = SpanKind(3)
+)
+
+type spanKey struct{}
+type remoteSpanContextKey struct{}
+
+type SSpan struct {
+	Name         string
+	Kind         SpanKind
+	SpanContext  SSpanContext
+	ParentSpanId SpanId
+
+	StartTime time.Time
+	EndTime   time.Time
+
+	Attributes map[string]string
+
+	Failed        bool
+	StatusMessage string
+
+	lock  sync.Mutex
+	ended bool
+}
+
+// StartSpan starts a span as the child of the span in ctx, a new trace is
+// started if ctx carries no span
+func StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *SSpan) {
+	span := &SSpan{
+		Name:       name,
+		Kind:       kind,
+		StartTime:  time.Now(),
+		Attributes: make(map[string]string),
+	}
+	parent := SpanContextFromContext(ctx)
+	if parent.IsValid() {
+		span.SpanContext.TraceId = parent.TraceId
+		span.SpanContext.Flags = parent.Flags
+		span.ParentSpanId = parent.SpanId
+	} else {
+		span.SpanContext.TraceId = newTraceId()
+		span.SpanContext.Flags = TRACE_FLAG_SAMPLED
+	}
+	span.SpanContext.SpanId = newSpanId()
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// StartServerSpan starts a server span continuing the trace of the
+// traceparent header of the incoming request
+func StartServerSpan(ctx context.Context, header http.Header, name string) (context.Context, *SSpan) {
+	if sc, err := ParseTraceparent(header.Get(TRACEPARENT_HEADER)); err == nil {
+		ctx = ContextWithRemoteSpanContext(ctx, sc)
+	}
+	return StartSpan(ctx, name, SPAN_KIND_SERVER)
+}
+
+// ContextWithRemoteSpanContext makes the span context received from other
+// processes the parent of spans started with the returned context
+func ContextWithRemoteSpanContext(ctx context.Context, sc SSpanContext) context.Context {
+	return context.WithValue(ctx, remoteSpanContextKey{}, sc)
+}
+
+func SpanFromContext(ctx context.Context) *SSpan {
+	val := ctx.Value(spanKey{})
+	if val != nil {
+		return val.(*SSpan)
+	}
+	return nil
+}
+
+func SpanContextFromContext(ctx context.Context) SSpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext
+	}
+	val := ctx.Value(remoteSpanContextKey{})
+	if val != nil {
+		return val.(SSpanContext)
+	}
+	return SSpanContext{}
+}
+
+// TraceparentFromContext returns the traceparent of the current span of ctx
+func TraceparentFromContext(ctx context.Context) string {
+	return SpanContextFromContext(ctx).Traceparent()
+}
+
+// InjectHeader sets the traceparent header of the outgoing request
+func InjectHeader(ctx context.Context, header http.Header) {
+	traceparent := TraceparentFromContext(ctx)
+	if len(traceparent) > 0 {
+		header.Set(TRACEPARENT_HEADER, traceparent)
+	}
+}
+
+func (span *SSpan) SetAttribute(key, val string) {
+	span.lock.Lock()
+	defer span.lock.Unlock()
+	span.Attributes[key] = val
+}
+
+func (span *SSpan) SetError(msg string) {
+	span.lock.Lock()
+	defer span.lock.Unlock()
+	span.Failed = true
+	span.StatusMessage = msg
+}
+
+// End finishes the span and submits it to the exporter, ending a span
+// more than once takes no effect
+func (span *SSpan) End() {
+	span.lock.Lock()
+	if span.ended {
+		span.lock.Unlock()
+		return
+	}
+	span.ended = true
+	span.EndTime = time.Now()
+	span.lock.Unlock()
+
+	if span.SpanContext.IsSampled() {
+		if exp := getExporter(); exp != nil {
+			exp.export(span)
+		}
+	}
+}
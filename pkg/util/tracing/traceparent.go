@@ -0,0 +1,130 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	// W3C trace context header, see https://www.w3.org/TR/trace-context/
+	TRACEPARENT_HEADER = "traceparent"
+
+	TRACEPARENT_VERSION = "00"
+
+	TRACE_FLAG_SAMPLED = byte(0x01)
+)
+
+type TraceId [16]byte
+type SpanId [8]byte
+
+func (id TraceId) IsValid() bool {
+	return id != TraceId{}
+}
+
+func (id TraceId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id SpanId) IsValid() bool {
+	return id != SpanId{}
+}
+
+func (id SpanId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func newTraceId() TraceId {
+	var id TraceId
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanId() SpanId {
+	var id SpanId
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+// SSpanContext is the part of a span propagated across process boundaries
+type SSpanContext struct {
+	TraceId TraceId
+	SpanId  SpanId
+	Flags   byte
+}
+
+func (sc SSpanContext) IsValid() bool {
+	return sc.TraceId.IsValid() && sc.SpanId.IsValid()
+}
+
+func (sc SSpanContext) IsSampled() bool {
+	return sc.Flags&TRACE_FLAG_SAMPLED != 0
+}
+
+// Traceparent formats the span context as the value of traceparent header
+func (sc SSpanContext) Traceparent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	return fmt.Sprintf("%s-%s-%s-%02x", TRACEPARENT_VERSION, sc.TraceId, sc.SpanId, sc.Flags)
+}
+
+// ParseTraceparent parses the value of traceparent header in the form of
+// version-traceid-parentid-flags
+func ParseTraceparent(val string) (SSpanContext, error) {
+	sc := SSpanContext{}
+	parts := strings.Split(strings.TrimSpace(val), "-")
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("invalid traceparent %q", val)
+	}
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 || version[0] == 0xff {
+		return sc, fmt.Errorf("invalid traceparent version %q", parts[0])
+	}
+	// future versions may append fields, version 00 must have exactly 4
+	if version[0] == 0 && len(parts) != 4 {
+		return sc, fmt.Errorf("invalid traceparent %q", val)
+	}
+	if err := decodeHex(parts[1], sc.TraceId[:]); err != nil {
+		return sc, fmt.Errorf("invalid trace id %q", parts[1])
+	}
+	if err := decodeHex(parts[2], sc.SpanId[:]); err != nil {
+		return sc, fmt.Errorf("invalid parent id %q", parts[2])
+	}
+	var flags [1]byte
+	if err := decodeHex(parts[3], flags[:]); err != nil {
+		return sc, fmt.Errorf("invalid trace flags %q", parts[3])
+	}
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return SSpanContext{}, fmt.Errorf("invalid traceparent %q: all zero id", val)
+	}
+	return sc, nil
+}
+
+func decodeHex(s string, dst []byte) error {
+	if len(s) != len(dst)*2 || strings.ToLower(s) != s {
+		return fmt.Errorf("invalid length or case")
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
@@ -0,0 +1,167 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	cases := []struct {
+		in    string
+		valid bool
+	}{
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", true},
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00", true},
+		{"01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra", true},
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra", false},
+		{"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", false},
+		{"00-00000000000000000000000000000000-b7ad6b7169203331-01", false},
+		{"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01", false},
+		{"00-0AF7651916CD43DD8448EB211C80319C-b7ad6b7169203331-01", false},
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b71692033-01", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		sc, err := ParseTraceparent(c.in)
+		if c.valid != (err == nil) {
+			t.Errorf("%q: expect valid %v, got error %v", c.in, c.valid, err)
+			continue
+		}
+		if c.valid && c.in[:2] == TRACEPARENT_VERSION && sc.Traceparent() != c.in {
+			t.Errorf("%q: format back to %q", c.in, sc.Traceparent())
+		}
+	}
+}
+
+type collector struct {
+	lock  sync.Mutex
+	spans []otlpSpan
+	names []string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	req := otlpTracesRequest{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, span := range ss.Spans {
+				c.spans = append(c.spans, span)
+				c.names = append(c.names, rs.Resource.Attributes[0].Value.StringValue)
+			}
+		}
+	}
+}
+
+func TestExportSpans(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	Init("region", srv.URL)
+	defer func() {
+		exporterLock.Lock()
+		exporter.Shutdown()
+		exporter = nil
+		exporterLock.Unlock()
+	}()
+
+	parent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	header := http.Header{}
+	header.Set(TRACEPARENT_HEADER, parent)
+	ctx, server := StartServerSpan(context.Background(), header, "POST servers")
+
+	// the trace continues in async task stages with the persisted traceparent
+	sc, err := ParseTraceparent(TraceparentFromContext(ctx))
+	if err != nil {
+		t.Fatalf("ParseTraceparent: %s", err)
+	}
+	taskCtx := ContextWithRemoteSpanContext(context.Background(), sc)
+	taskCtx, stage := StartSpan(taskCtx, "GuestCreateTask.OnInit", SPAN_KIND_INTERNAL)
+	stage.SetError("boom")
+
+	outHeader := http.Header{}
+	InjectHeader(taskCtx, outHeader)
+	if got := outHeader.Get(TRACEPARENT_HEADER); got != stage.SpanContext.Traceparent() {
+		t.Errorf("injected traceparent %q, expect %q", got, stage.SpanContext.Traceparent())
+	}
+
+	stage.End()
+	server.End()
+	server.End()
+	Flush()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.spans) != 2 {
+		t.Fatalf("expect 2 spans, got %d", len(c.spans))
+	}
+	stageSpan, serverSpan := c.spans[0], c.spans[1]
+	for i, span := range c.spans {
+		if span.TraceId != "0af7651916cd43dd8448eb211c80319c" {
+			t.Errorf("span %s trace id %s", span.Name, span.TraceId)
+		}
+		if c.names[i] != "region" {
+			t.Errorf("span %s service name %s", span.Name, c.names[i])
+		}
+	}
+	if serverSpan.ParentSpanId != "b7ad6b7169203331" || serverSpan.Kind != SPAN_KIND_SERVER {
+		t.Errorf("server span parent %s kind %d", serverSpan.ParentSpanId, serverSpan.Kind)
+	}
+	if stageSpan.ParentSpanId != serverSpan.SpanId {
+		t.Errorf("stage span parent %s, expect %s", stageSpan.ParentSpanId, serverSpan.SpanId)
+	}
+	if stageSpan.Status.Code != otlpStatusCodeError || stageSpan.Status.Message != "boom" {
+		t.Errorf("stage span status %#v", stageSpan.Status)
+	}
+}
+
+func TestUnsampledNotExported(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	Init("region", srv.URL)
+	defer func() {
+		exporterLock.Lock()
+		exporter.Shutdown()
+		exporter = nil
+		exporterLock.Unlock()
+	}()
+
+	header := http.Header{}
+	header.Set(TRACEPARENT_HEADER, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00")
+	_, span := StartServerSpan(context.Background(), header, "GET servers")
+	span.End()
+	Flush()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.spans) != 0 {
+		t.Errorf("unsampled spans are exported: %d", len(c.spans))
+	}
+}
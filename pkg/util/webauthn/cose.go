@@ -0,0 +1,189 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"math/big"
+
+	"github.com/ugorji/go/codec"
+	"golang.org/x/crypto/ed25519"
+
+	"yunion.io/x/pkg/errors"
+)
+
+// COSE algorithm identifiers, https://www.iana.org/assignments/cose/cose.xhtml
+const (
+	AlgES256 = int64(-7)
+	AlgEdDSA = int64(-8)
+	AlgRS256 = int64(-257)
+)
+
+const (
+	coseKeyKty = 1
+	coseKeyAlg = 3
+
+	coseKeyCrv = -1
+	coseKeyX   = -2
+	coseKeyY   = -3
+	coseKeyN   = -1
+	coseKeyE   = -2
+
+	coseKtyOKP = 1
+	coseKtyEC2 = 2
+	coseKtyRSA = 3
+
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+)
+
+// SupportedAlgorithms lists the credential public key algorithms in the order of preference
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+var cborHandle = &codec.CborHandle{}
+
+type SPublicKey struct {
+	Algorithm int64
+	key       crypto.PublicKey
+}
+
+func coseInt(v interface{}) (int64, bool) {
+	switch iv := v.(type) {
+	case int64:
+		return iv, true
+	case uint64:
+		return int64(iv), true
+	}
+	return 0, false
+}
+
+func coseBytes(key map[int64]interface{}, label int64) ([]byte, error) {
+	v, ok := key[label].([]byte)
+	if !ok || len(v) == 0 {
+		return nil, errors.Wrapf(ErrMalformed, "missing COSE key parameter %d", label)
+	}
+	return v, nil
+}
+
+// ParsePublicKey decodes a COSE_Key encoded credential public key
+func ParsePublicKey(data []byte) (*SPublicKey, error) {
+	key := make(map[int64]interface{})
+	err := codec.NewDecoderBytes(data, cborHandle).Decode(&key)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode COSE key")
+	}
+	kty, _ := coseInt(key[coseKeyKty])
+	alg, ok := coseInt(key[coseKeyAlg])
+	if !ok {
+		return nil, errors.Wrap(ErrMalformed, "missing COSE key algorithm")
+	}
+	pubKey := &SPublicKey{Algorithm: alg}
+	switch alg {
+	case AlgES256:
+		crv, _ := coseInt(key[coseKeyCrv])
+		if kty != coseKtyEC2 || crv != coseCrvP256 {
+			return nil, errors.Wrapf(errors.ErrNotSupported, "ES256 key with kty %d crv %d", kty, crv)
+		}
+		x, err := coseBytes(key, coseKeyX)
+		if err != nil {
+			return nil, err
+		}
+		y, err := coseBytes(key, coseKeyY)
+		if err != nil {
+			return nil, err
+		}
+		ecKey := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !ecKey.Curve.IsOnCurve(ecKey.X, ecKey.Y) {
+			return nil, errors.Wrap(ErrMalformed, "point not on curve P-256")
+		}
+		pubKey.key = ecKey
+	case AlgEdDSA:
+		crv, _ := coseInt(key[coseKeyCrv])
+		if kty != coseKtyOKP || crv != coseCrvEd25519 {
+			return nil, errors.Wrapf(errors.ErrNotSupported, "EdDSA key with kty %d crv %d", kty, crv)
+		}
+		x, err := coseBytes(key, coseKeyX)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.Wrapf(ErrMalformed, "invalid ed25519 public key size %d", len(x))
+		}
+		pubKey.key = ed25519.PublicKey(x)
+	case AlgRS256:
+		if kty != coseKtyRSA {
+			return nil, errors.Wrapf(errors.ErrNotSupported, "RS256 key with kty %d", kty)
+		}
+		n, err := coseBytes(key, coseKeyN)
+		if err != nil {
+			return nil, err
+		}
+		e, err := coseBytes(key, coseKeyE)
+		if err != nil {
+			return nil, err
+		}
+		eInt := new(big.Int).SetBytes(e)
+		if !eInt.IsInt64() || eInt.Int64() > int64(^uint32(0)>>1) {
+			return nil, errors.Wrap(ErrMalformed, "invalid RSA public exponent")
+		}
+		pubKey.key = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(eInt.Int64()),
+		}
+	default:
+		return nil, errors.Wrapf(errors.ErrNotSupported, "COSE algorithm %d", alg)
+	}
+	return pubKey, nil
+}
+
+// Verify checks the signature over the message with the credential public key
+func (key *SPublicKey) Verify(message []byte, sig []byte) error {
+	switch pub := key.key.(type) {
+	case *ecdsa.PublicKey:
+		ecSig := struct {
+			R, S *big.Int
+		}{}
+		rest, err := asn1.Unmarshal(sig, &ecSig)
+		if err != nil || len(rest) > 0 {
+			return errors.Wrap(ErrMalformed, "malformed ECDSA signature")
+		}
+		digest := sha256.Sum256(message)
+		if !ecdsa.Verify(pub, digest[:], ecSig.R, ecSig.S) {
+			return errors.Wrap(ErrVerifyFailed, "ECDSA signature mismatch")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, message, sig) {
+			return errors.Wrap(ErrVerifyFailed, "EdDSA signature mismatch")
+		}
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig)
+		if err != nil {
+			return errors.Wrap(err, "RSA signature mismatch")
+		}
+	default:
+		return errors.Wrap(errors.ErrNotSupported, "unknown public key type")
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package webauthn implements the relying party side of the W3C Web
+// Authentication registration and assertion ceremonies.
+//
+// Only the "none" attestation conveyance is requested, so attestation
+// statements are accepted without verification. Supported credential
+// public key algorithms are ES256, RS256 and EdDSA.
+package webauthn // import "yunion.io/x/onecloud/pkg/util/webauthn"
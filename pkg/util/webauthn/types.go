@@ -0,0 +1,179 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webauthn
+
+import (
+	"yunion.io/x/pkg/errors"
+)
+
+// The JSON layout of the ceremony options and responses follows the
+// WebAuthn Level 3 JSON serialization, so that browsers can use
+// PublicKeyCredential.parseCreationOptionsFromJSON() and
+// PublicKeyCredential.toJSON() directly.
+
+const (
+	CeremonyCreate = "webauthn.create"
+	CeremonyGet    = "webauthn.get"
+
+	CredentialTypePublicKey = "public-key"
+
+	AttestationNone = "none"
+
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+
+	ResidentKeyDiscouraged = "discouraged"
+
+	DefaultTimeoutMilliseconds = 120000
+)
+
+const (
+	ErrMalformed    = errors.Error("MalformedWebAuthnData")
+	ErrVerifyFailed = errors.Error("WebAuthnVerifyFailed")
+)
+
+const (
+	flagUserPresent        = 0x01
+	flagUserVerified       = 0x04
+	flagAttestedCredential = 0x40
+	flagExtensionData      = 0x80
+)
+
+type SRelyingParty struct {
+	// relying party id, a registrable domain suffix of the origin host
+	Id   string `json:"id"`
+	Name string `json:"name"`
+
+	// allowed origins, such as https://console.example.com
+	// if empty, https origins whose host equals to or is a subdomain of Id are allowed
+	Origins []string `json:"-"`
+}
+
+type SUserEntity struct {
+	// base64url encoded user handle
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type SCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type SCredentialDescriptor struct {
+	Type string `json:"type"`
+	// base64url encoded credential id
+	Id         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type SAuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+type SCreationOptions struct {
+	Rp                     SRelyingParty           `json:"rp"`
+	User                   SUserEntity             `json:"user"`
+	Challenge              string                  `json:"challenge"`
+	PubKeyCredParams       []SCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int                     `json:"timeout"`
+	ExcludeCredentials     []SCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection SAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                  `json:"attestation"`
+}
+
+type SRequestOptions struct {
+	Challenge        string                  `json:"challenge"`
+	Timeout          int                     `json:"timeout"`
+	RpId             string                  `json:"rpId"`
+	AllowCredentials []SCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                  `json:"userVerification"`
+}
+
+type SAttestationResponse struct {
+	// base64url encoded
+	ClientDataJSON string `json:"clientDataJSON"`
+	// base64url encoded
+	AttestationObject string   `json:"attestationObject"`
+	Transports        []string `json:"transports"`
+}
+
+type SRegistrationCredential struct {
+	Id       string               `json:"id"`
+	RawId    string               `json:"rawId"`
+	Type     string               `json:"type"`
+	Response SAttestationResponse `json:"response"`
+}
+
+type SAssertionResponse struct {
+	// base64url encoded
+	ClientDataJSON string `json:"clientDataJSON"`
+	// base64url encoded
+	AuthenticatorData string `json:"authenticatorData"`
+	// base64url encoded
+	Signature string `json:"signature"`
+	// base64url encoded
+	UserHandle string `json:"userHandle"`
+}
+
+type SAssertionCredential struct {
+	Id       string             `json:"id"`
+	RawId    string             `json:"rawId"`
+	Type     string             `json:"type"`
+	Response SAssertionResponse `json:"response"`
+}
+
+type SCollectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+type SAuthenticatorData struct {
+	RpIdHash  []byte
+	Flags     byte
+	SignCount uint32
+
+	// present only when the attested credential data flag is set
+	Aaguid       []byte
+	CredentialId []byte
+	// COSE_Key encoded credential public key
+	PublicKey []byte
+}
+
+func (data SAuthenticatorData) UserPresent() bool {
+	return data.Flags&flagUserPresent != 0
+}
+
+func (data SAuthenticatorData) UserVerified() bool {
+	return data.Flags&flagUserVerified != 0
+}
+
+// SCredential is the outcome of a successful registration ceremony and
+// has to be persisted by the relying party to verify later assertions
+type SCredential struct {
+	// base64url encoded credential id
+	CredentialId string `json:"credential_id"`
+	// base64url encoded COSE_Key
+	PublicKey  string   `json:"public_key"`
+	Algorithm  int64    `json:"algorithm"`
+	SignCount  uint32   `json:"sign_count"`
+	Aaguid     string   `json:"aaguid"`
+	Transports []string `json:"transports"`
+}
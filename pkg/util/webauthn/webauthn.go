@@ -0,0 +1,298 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/ugorji/go/codec"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+)
+
+func EncodeBase64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeBase64 accepts base64url with or without padding
+func DecodeBase64(data string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(data, "="))
+}
+
+// NewChallenge generates a random challenge of 32 bytes, base64url encoded
+func NewChallenge() (string, error) {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", errors.Wrap(err, "rand.Read")
+	}
+	return EncodeBase64(buf), nil
+}
+
+func credentialDescriptors(creds []SCredential) []SCredentialDescriptor {
+	ret := make([]SCredentialDescriptor, 0, len(creds))
+	for i := range creds {
+		ret = append(ret, SCredentialDescriptor{
+			Type:       CredentialTypePublicKey,
+			Id:         creds[i].CredentialId,
+			Transports: creds[i].Transports,
+		})
+	}
+	return ret
+}
+
+// NewCreationOptions returns the options of navigator.credentials.create(),
+// existing credentials of the user are excluded from registering again
+func (rp SRelyingParty) NewCreationOptions(user SUserEntity, challenge string, userVerification string, existing []SCredential) SCreationOptions {
+	opts := SCreationOptions{
+		Rp:                 rp,
+		User:               user,
+		Challenge:          challenge,
+		Timeout:            DefaultTimeoutMilliseconds,
+		ExcludeCredentials: credentialDescriptors(existing),
+		AuthenticatorSelection: SAuthenticatorSelection{
+			ResidentKey:      ResidentKeyDiscouraged,
+			UserVerification: userVerification,
+		},
+		Attestation: AttestationNone,
+	}
+	for _, alg := range SupportedAlgorithms {
+		opts.PubKeyCredParams = append(opts.PubKeyCredParams, SCredentialParameter{
+			Type: CredentialTypePublicKey,
+			Alg:  alg,
+		})
+	}
+	return opts
+}
+
+// NewRequestOptions returns the options of navigator.credentials.get()
+func (rp SRelyingParty) NewRequestOptions(challenge string, userVerification string, allowed []SCredential) SRequestOptions {
+	return SRequestOptions{
+		Challenge:        challenge,
+		Timeout:          DefaultTimeoutMilliseconds,
+		RpId:             rp.Id,
+		AllowCredentials: credentialDescriptors(allowed),
+		UserVerification: userVerification,
+	}
+}
+
+func (rp SRelyingParty) isOriginAllowed(origin string) bool {
+	if len(rp.Origins) > 0 {
+		return utils.IsInStringArray(origin, rp.Origins)
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if u.Scheme != "https" && !(u.Scheme == "http" && host == "localhost") {
+		return false
+	}
+	return host == rp.Id || strings.HasSuffix(host, "."+rp.Id)
+}
+
+func (rp SRelyingParty) verifyClientData(clientDataJSON string, ceremony string, challenge string) ([]byte, error) {
+	raw, err := DecodeBase64(clientDataJSON)
+	if err != nil {
+		return nil, errors.Wrap(ErrMalformed, "clientDataJSON is not base64url encoded")
+	}
+	clientData := SCollectedClientData{}
+	err = json.Unmarshal(raw, &clientData)
+	if err != nil {
+		return nil, errors.Wrap(ErrMalformed, "invalid clientDataJSON")
+	}
+	if clientData.Type != ceremony {
+		return nil, errors.Wrapf(ErrVerifyFailed, "unexpected client data type %s", clientData.Type)
+	}
+	if len(challenge) == 0 || subtle.ConstantTimeCompare([]byte(strings.TrimRight(clientData.Challenge, "=")), []byte(challenge)) != 1 {
+		return nil, errors.Wrap(ErrVerifyFailed, "challenge mismatch")
+	}
+	if !rp.isOriginAllowed(clientData.Origin) {
+		return nil, errors.Wrapf(ErrVerifyFailed, "origin %s not allowed", clientData.Origin)
+	}
+	return raw, nil
+}
+
+func (rp SRelyingParty) verifyAuthenticatorData(authData *SAuthenticatorData, userVerification string) error {
+	rpIdHash := sha256.Sum256([]byte(rp.Id))
+	if !bytes.Equal(authData.RpIdHash, rpIdHash[:]) {
+		return errors.Wrap(ErrVerifyFailed, "rpIdHash mismatch")
+	}
+	if !authData.UserPresent() {
+		return errors.Wrap(ErrVerifyFailed, "user not present")
+	}
+	if userVerification == UserVerificationRequired && !authData.UserVerified() {
+		return errors.Wrap(ErrVerifyFailed, "user not verified")
+	}
+	return nil
+}
+
+// ParseAuthenticatorData decodes the authenticator data structure,
+// https://www.w3.org/TR/webauthn-2/#sctn-authenticator-data
+func ParseAuthenticatorData(data []byte) (*SAuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.Wrapf(ErrMalformed, "authenticator data too short (%d)", len(data))
+	}
+	authData := &SAuthenticatorData{
+		RpIdHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if authData.Flags&flagAttestedCredential == 0 {
+		return authData, nil
+	}
+	rest := data[37:]
+	if len(rest) < 18 {
+		return nil, errors.Wrap(ErrMalformed, "attested credential data too short")
+	}
+	authData.Aaguid = rest[:16]
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || len(rest) < idLen {
+		return nil, errors.Wrapf(ErrMalformed, "invalid credential id length %d", idLen)
+	}
+	authData.CredentialId = rest[:idLen]
+	rest = rest[idLen:]
+	// the COSE key is followed by the extensions if any, decode it from a
+	// reader to find out where it ends
+	reader := bytes.NewReader(rest)
+	var pubKey interface{}
+	err := codec.NewDecoder(reader, cborHandle).Decode(&pubKey)
+	if err != nil {
+		return nil, errors.Wrap(ErrMalformed, "invalid credential public key")
+	}
+	authData.PublicKey = rest[:len(rest)-reader.Len()]
+	return authData, nil
+}
+
+type sAttestationObject struct {
+	Fmt      string `codec:"fmt"`
+	AuthData []byte `codec:"authData"`
+}
+
+// VerifyRegistration verifies the response of navigator.credentials.create()
+// against the challenge issued in SCreationOptions
+func (rp SRelyingParty) VerifyRegistration(cred SRegistrationCredential, challenge string, userVerification string) (*SCredential, error) {
+	if cred.Type != CredentialTypePublicKey {
+		return nil, errors.Wrapf(ErrMalformed, "unsupported credential type %s", cred.Type)
+	}
+	_, err := rp.verifyClientData(cred.Response.ClientDataJSON, CeremonyCreate, challenge)
+	if err != nil {
+		return nil, err
+	}
+	attObjBytes, err := DecodeBase64(cred.Response.AttestationObject)
+	if err != nil {
+		return nil, errors.Wrap(ErrMalformed, "attestationObject is not base64url encoded")
+	}
+	attObj := sAttestationObject{}
+	err = codec.NewDecoderBytes(attObjBytes, cborHandle).Decode(&attObj)
+	if err != nil {
+		return nil, errors.Wrap(ErrMalformed, "invalid attestationObject")
+	}
+	authData, err := ParseAuthenticatorData(attObj.AuthData)
+	if err != nil {
+		return nil, err
+	}
+	err = rp.verifyAuthenticatorData(authData, userVerification)
+	if err != nil {
+		return nil, err
+	}
+	if len(authData.CredentialId) == 0 {
+		return nil, errors.Wrap(ErrMalformed, "no attested credential data")
+	}
+	pubKey, err := ParsePublicKey(authData.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "ParsePublicKey")
+	}
+	return &SCredential{
+		CredentialId: EncodeBase64(authData.CredentialId),
+		PublicKey:    EncodeBase64(authData.PublicKey),
+		Algorithm:    pubKey.Algorithm,
+		SignCount:    authData.SignCount,
+		Aaguid:       hex.EncodeToString(authData.Aaguid),
+		Transports:   cred.Response.Transports,
+	}, nil
+}
+
+// FindCredential returns the index of the credential the assertion is made with, or -1
+func FindCredential(creds []SCredential, assertion SAssertionCredential) int {
+	id := strings.TrimRight(assertion.RawId, "=")
+	if len(id) == 0 {
+		id = strings.TrimRight(assertion.Id, "=")
+	}
+	for i := range creds {
+		if creds[i].CredentialId == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// VerifyAssertion verifies the response of navigator.credentials.get()
+// made with the registered credential and returns the new signature counter.
+// A counter not increasing over the stored one indicates a cloned authenticator.
+func (rp SRelyingParty) VerifyAssertion(cred SCredential, assertion SAssertionCredential, challenge string, userVerification string) (uint32, error) {
+	if assertion.Type != CredentialTypePublicKey {
+		return 0, errors.Wrapf(ErrMalformed, "unsupported credential type %s", assertion.Type)
+	}
+	clientData, err := rp.verifyClientData(assertion.Response.ClientDataJSON, CeremonyGet, challenge)
+	if err != nil {
+		return 0, err
+	}
+	authDataBytes, err := DecodeBase64(assertion.Response.AuthenticatorData)
+	if err != nil {
+		return 0, errors.Wrap(ErrMalformed, "authenticatorData is not base64url encoded")
+	}
+	authData, err := ParseAuthenticatorData(authDataBytes)
+	if err != nil {
+		return 0, err
+	}
+	err = rp.verifyAuthenticatorData(authData, userVerification)
+	if err != nil {
+		return 0, err
+	}
+	sig, err := DecodeBase64(assertion.Response.Signature)
+	if err != nil {
+		return 0, errors.Wrap(ErrMalformed, "signature is not base64url encoded")
+	}
+	keyBytes, err := DecodeBase64(cred.PublicKey)
+	if err != nil {
+		return 0, errors.Wrap(ErrMalformed, "stored public key is not base64url encoded")
+	}
+	pubKey, err := ParsePublicKey(keyBytes)
+	if err != nil {
+		return 0, errors.Wrap(err, "ParsePublicKey")
+	}
+	clientDataHash := sha256.Sum256(clientData)
+	message := append(append([]byte{}, authDataBytes...), clientDataHash[:]...)
+	err = pubKey.Verify(message, sig)
+	if err != nil {
+		return 0, err
+	}
+	if (authData.SignCount > 0 || cred.SignCount > 0) && authData.SignCount <= cred.SignCount {
+		return 0, errors.Wrapf(ErrVerifyFailed, "signature counter %d not greater than %d, the authenticator may be cloned", authData.SignCount, cred.SignCount)
+	}
+	return authData.SignCount, nil
+}
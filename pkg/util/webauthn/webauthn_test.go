@@ -0,0 +1,247 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/ugorji/go/codec"
+	"golang.org/x/crypto/ed25519"
+)
+
+type testAuthenticator struct {
+	credId  []byte
+	coseKey []byte
+	sign    func(msg []byte) []byte
+	counter uint32
+}
+
+func cborEncode(t *testing.T, v interface{}) []byte {
+	var out []byte
+	err := codec.NewEncoderBytes(&out, cborHandle).Encode(v)
+	if err != nil {
+		t.Fatalf("cbor encode fail %s", err)
+	}
+	return out
+}
+
+func newES256Authenticator(t *testing.T) *testAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey fail %s", err)
+	}
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+	coseKey := map[int64]interface{}{
+		coseKeyKty: coseKtyEC2,
+		coseKeyAlg: AlgES256,
+		coseKeyCrv: coseCrvP256,
+		coseKeyX:   x,
+		coseKeyY:   y,
+	}
+	return &testAuthenticator{
+		credId:  []byte("es256-credential-id"),
+		coseKey: cborEncode(t, coseKey),
+		sign: func(msg []byte) []byte {
+			digest := sha256.Sum256(msg)
+			sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+			if err != nil {
+				t.Fatalf("SignASN1 fail %s", err)
+			}
+			return sig
+		},
+	}
+}
+
+func newEd25519Authenticator(t *testing.T) *testAuthenticator {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey fail %s", err)
+	}
+	coseKey := map[int64]interface{}{
+		coseKeyKty: coseKtyOKP,
+		coseKeyAlg: AlgEdDSA,
+		coseKeyCrv: coseCrvEd25519,
+		coseKeyX:   []byte(pub),
+	}
+	return &testAuthenticator{
+		credId:  []byte("ed25519-credential-id"),
+		coseKey: cborEncode(t, coseKey),
+		sign: func(msg []byte) []byte {
+			return ed25519.Sign(priv, msg)
+		},
+	}
+}
+
+func (a *testAuthenticator) authData(rpId string, flags byte, attested bool) []byte {
+	rpIdHash := sha256.Sum256([]byte(rpId))
+	data := append([]byte{}, rpIdHash[:]...)
+	data = append(data, flags)
+	cnt := make([]byte, 4)
+	binary.BigEndian.PutUint32(cnt, a.counter)
+	data = append(data, cnt...)
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		idLen := make([]byte, 2)
+		binary.BigEndian.PutUint16(idLen, uint16(len(a.credId)))
+		data = append(data, idLen...)
+		data = append(data, a.credId...)
+		data = append(data, a.coseKey...)
+	}
+	return data
+}
+
+func clientDataJSON(t *testing.T, ceremony, challenge, origin string) []byte {
+	data, err := json.Marshal(SCollectedClientData{
+		Type:      ceremony,
+		Challenge: challenge,
+		Origin:    origin,
+	})
+	if err != nil {
+		t.Fatalf("json.Marshal fail %s", err)
+	}
+	return data
+}
+
+func (a *testAuthenticator) create(t *testing.T, rpId, challenge, origin string) SRegistrationCredential {
+	attObj := map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authData(rpId, flagUserPresent|flagUserVerified|flagAttestedCredential, true),
+	}
+	return SRegistrationCredential{
+		Id:    EncodeBase64(a.credId),
+		RawId: EncodeBase64(a.credId),
+		Type:  CredentialTypePublicKey,
+		Response: SAttestationResponse{
+			ClientDataJSON:    EncodeBase64(clientDataJSON(t, CeremonyCreate, challenge, origin)),
+			AttestationObject: EncodeBase64(cborEncode(t, attObj)),
+			Transports:        []string{"usb"},
+		},
+	}
+}
+
+func (a *testAuthenticator) get(t *testing.T, rpId, challenge, origin string) SAssertionCredential {
+	a.counter += 1
+	authData := a.authData(rpId, flagUserPresent|flagUserVerified, false)
+	clientData := clientDataJSON(t, CeremonyGet, challenge, origin)
+	clientDataHash := sha256.Sum256(clientData)
+	sig := a.sign(append(append([]byte{}, authData...), clientDataHash[:]...))
+	return SAssertionCredential{
+		Id:    EncodeBase64(a.credId),
+		RawId: EncodeBase64(a.credId),
+		Type:  CredentialTypePublicKey,
+		Response: SAssertionResponse{
+			ClientDataJSON:    EncodeBase64(clientData),
+			AuthenticatorData: EncodeBase64(authData),
+			Signature:         EncodeBase64(sig),
+		},
+	}
+}
+
+func TestCeremonies(t *testing.T) {
+	rp := SRelyingParty{Id: "example.com", Name: "Example"}
+	for name, authenticator := range map[string]*testAuthenticator{
+		"ES256": newES256Authenticator(t),
+		"EdDSA": newEd25519Authenticator(t),
+	} {
+		t.Run(name, func(t *testing.T) {
+			challenge, err := NewChallenge()
+			if err != nil {
+				t.Fatalf("NewChallenge fail %s", err)
+			}
+			cred, err := rp.VerifyRegistration(authenticator.create(t, rp.Id, challenge, "https://console.example.com"), challenge, UserVerificationPreferred)
+			if err != nil {
+				t.Fatalf("VerifyRegistration fail %s", err)
+			}
+			if cred.CredentialId != EncodeBase64(authenticator.credId) {
+				t.Fatalf("credential id mismatch %s", cred.CredentialId)
+			}
+			if cred.PublicKey != EncodeBase64(authenticator.coseKey) {
+				t.Fatalf("public key mismatch")
+			}
+
+			challenge, _ = NewChallenge()
+			assertion := authenticator.get(t, rp.Id, challenge, "https://example.com")
+			if FindCredential([]SCredential{*cred}, assertion) != 0 {
+				t.Fatalf("FindCredential fail")
+			}
+			cnt, err := rp.VerifyAssertion(*cred, assertion, challenge, UserVerificationRequired)
+			if err != nil {
+				t.Fatalf("VerifyAssertion fail %s", err)
+			}
+			if cnt != 1 {
+				t.Fatalf("expect sign count 1, got %d", cnt)
+			}
+			cred.SignCount = cnt
+
+			// replayed assertion
+			_, err = rp.VerifyAssertion(*cred, assertion, challenge, UserVerificationRequired)
+			if err == nil {
+				t.Fatalf("replayed assertion should fail")
+			}
+
+			newChallenge, _ := NewChallenge()
+			_, err = rp.VerifyAssertion(*cred, authenticator.get(t, rp.Id, challenge, "https://example.com"), newChallenge, UserVerificationRequired)
+			if err == nil {
+				t.Fatalf("challenge mismatch should fail")
+			}
+			_, err = rp.VerifyAssertion(*cred, authenticator.get(t, rp.Id, newChallenge, "https://evil.com"), newChallenge, UserVerificationRequired)
+			if err == nil {
+				t.Fatalf("origin mismatch should fail")
+			}
+			_, err = rp.VerifyAssertion(*cred, authenticator.get(t, "evil.com", newChallenge, "https://example.com"), newChallenge, UserVerificationRequired)
+			if err == nil {
+				t.Fatalf("rp id mismatch should fail")
+			}
+
+			tampered := authenticator.get(t, rp.Id, newChallenge, "https://example.com")
+			tampered.Response.ClientDataJSON = EncodeBase64(clientDataJSON(t, CeremonyGet, newChallenge, "https://www.example.com"))
+			_, err = rp.VerifyAssertion(*cred, tampered, newChallenge, UserVerificationRequired)
+			if err == nil {
+				t.Fatalf("tampered client data should fail")
+			}
+		})
+	}
+}
+
+func TestIsOriginAllowed(t *testing.T) {
+	cases := []struct {
+		rp     SRelyingParty
+		origin string
+		want   bool
+	}{
+		{SRelyingParty{Id: "example.com"}, "https://example.com", true},
+		{SRelyingParty{Id: "example.com"}, "https://console.example.com:8443", true},
+		{SRelyingParty{Id: "example.com"}, "http://console.example.com", false},
+		{SRelyingParty{Id: "example.com"}, "https://badexample.com", false},
+		{SRelyingParty{Id: "localhost"}, "http://localhost:8080", true},
+		{SRelyingParty{Id: "example.com", Origins: []string{"https://a.example.com"}}, "https://a.example.com", true},
+		{SRelyingParty{Id: "example.com", Origins: []string{"https://a.example.com"}}, "https://b.example.com", false},
+	}
+	for _, c := range cases {
+		if got := c.rp.isOriginAllowed(c.origin); got != c.want {
+			t.Errorf("%s isOriginAllowed(%s) want %v got %v", c.rp.Id, c.origin, c.want, got)
+		}
+	}
+}
@@ -21,8 +21,15 @@ import (
 type Vpc struct {
 	compute_models.SVpc
 
-	Wire     *Wire    `json:"-"`
-	Networks Networks `json:"-"`
+	Wire        *Wire       `json:"-"`
+	Networks    Networks    `json:"-"`
+	RouteTables RouteTables `json:"-"`
+	NatGateways NatGateways `json:"-"`
+
+	Loadbalancers Loadbalancers `json:"-"`
+
+	// peering connections with this vpc as either requester or accepter
+	PeeringConnections VpcPeeringConnections `json:"-"`
 }
 
 func (el *Vpc) Copy() *Vpc {
@@ -50,6 +57,10 @@ type Network struct {
 	Wire          *Wire         `json:"-"`
 	Guestnetworks Guestnetworks `json:"-"`
 	Elasticips    Elasticips    `json:"-"`
+
+	// RouteTable is the route table explicitly associated with the
+	// network.  It's nil when the network follows vpc-wide route tables
+	RouteTable *RouteTable `json:"-"`
 }
 
 func (el *Network) Copy() *Network {
@@ -58,6 +69,136 @@ func (el *Network) Copy() *Network {
 	}
 }
 
+type RouteTable struct {
+	compute_models.SRouteTable
+
+	Vpc          *Vpc                   `json:"-"`
+	Associations RouteTableAssociations `json:"-"`
+}
+
+func (el *RouteTable) Copy() *RouteTable {
+	return &RouteTable{
+		SRouteTable: el.SRouteTable,
+	}
+}
+
+type RouteTableAssociation struct {
+	compute_models.SRouteTableAssociation
+
+	RouteTable *RouteTable `json:"-"`
+	Network    *Network    `json:"-"`
+}
+
+func (el *RouteTableAssociation) Copy() *RouteTableAssociation {
+	return &RouteTableAssociation{
+		SRouteTableAssociation: el.SRouteTableAssociation,
+	}
+}
+
+type NatGateway struct {
+	compute_models.SNatGateway
+
+	Vpc      *Vpc        `json:"-"`
+	SEntries NatSEntries `json:"-"`
+	DEntries NatDEntries `json:"-"`
+}
+
+func (el *NatGateway) Copy() *NatGateway {
+	return &NatGateway{
+		SNatGateway: el.SNatGateway,
+	}
+}
+
+type NatSEntry struct {
+	compute_models.SNatSEntry
+
+	NatGateway *NatGateway `json:"-"`
+}
+
+func (el *NatSEntry) Copy() *NatSEntry {
+	return &NatSEntry{
+		SNatSEntry: el.SNatSEntry,
+	}
+}
+
+type NatDEntry struct {
+	compute_models.SNatDEntry
+
+	NatGateway *NatGateway `json:"-"`
+}
+
+func (el *NatDEntry) Copy() *NatDEntry {
+	return &NatDEntry{
+		SNatDEntry: el.SNatDEntry,
+	}
+}
+
+type VpcPeeringConnection struct {
+	compute_models.SVpcPeeringConnection
+
+	Vpc     *Vpc `json:"-"`
+	PeerVpc *Vpc `json:"-"`
+}
+
+func (el *VpcPeeringConnection) Copy() *VpcPeeringConnection {
+	return &VpcPeeringConnection{
+		SVpcPeeringConnection: el.SVpcPeeringConnection,
+	}
+}
+
+type Loadbalancer struct {
+	compute_models.SLoadbalancer
+
+	Vpc           *Vpc                      `json:"-"`
+	Listeners     LoadbalancerListeners     `json:"-"`
+	BackendGroups LoadbalancerBackendGroups `json:"-"`
+}
+
+func (el *Loadbalancer) Copy() *Loadbalancer {
+	return &Loadbalancer{
+		SLoadbalancer: el.SLoadbalancer,
+	}
+}
+
+type LoadbalancerListener struct {
+	compute_models.SLoadbalancerListener
+
+	Loadbalancer *Loadbalancer `json:"-"`
+}
+
+func (el *LoadbalancerListener) Copy() *LoadbalancerListener {
+	return &LoadbalancerListener{
+		SLoadbalancerListener: el.SLoadbalancerListener,
+	}
+}
+
+type LoadbalancerBackendGroup struct {
+	compute_models.SLoadbalancerBackendGroup
+
+	Loadbalancer *Loadbalancer        `json:"-"`
+	Backends     LoadbalancerBackends `json:"-"`
+}
+
+func (el *LoadbalancerBackendGroup) Copy() *LoadbalancerBackendGroup {
+	return &LoadbalancerBackendGroup{
+		SLoadbalancerBackendGroup: el.SLoadbalancerBackendGroup,
+	}
+}
+
+type LoadbalancerBackend struct {
+	compute_models.SLoadbalancerBackend
+
+	BackendGroup *LoadbalancerBackendGroup `json:"-"`
+	// Guest could be nil when the backend guest is not found
+	Guest *Guest `json:"-"`
+}
+
+func (el *LoadbalancerBackend) Copy() *LoadbalancerBackend {
+	return &LoadbalancerBackend{
+		SLoadbalancerBackend: el.SLoadbalancerBackend,
+	}
+}
+
 type Guestnetwork struct {
 	compute_models.SGuestnetwork
 
@@ -21,6 +21,7 @@ import (
 
 	computeapis "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudprovider"
 	mcclient_modulebase "yunion.io/x/onecloud/pkg/mcclient/modulebase"
 	mcclient_modules "yunion.io/x/onecloud/pkg/mcclient/modules"
 	"yunion.io/x/onecloud/pkg/vpcagent/apihelper"
@@ -37,6 +38,20 @@ type (
 	Elasticips         map[string]*Elasticip
 	NetworkAddresses   map[string]*NetworkAddress
 
+	RouteTables            map[string]*RouteTable
+	RouteTableAssociations map[string]*RouteTableAssociation
+
+	NatGateways map[string]*NatGateway
+	NatSEntries map[string]*NatSEntry
+	NatDEntries map[string]*NatDEntry
+
+	VpcPeeringConnections map[string]*VpcPeeringConnection
+
+	Loadbalancers             map[string]*Loadbalancer
+	LoadbalancerListeners     map[string]*LoadbalancerListener
+	LoadbalancerBackendGroups map[string]*LoadbalancerBackendGroup
+	LoadbalancerBackends      map[string]*LoadbalancerBackend
+
 	Guestnetworks  map[string]*Guestnetwork  // key: rowId
 	Guestsecgroups map[string]*Guestsecgroup // key: guestId/secgroupId
 
@@ -117,6 +132,87 @@ func (ms Vpcs) joinNetworks(subEntries Networks) bool {
 	return correct
 }
 
+func (ms Vpcs) joinRouteTables(subEntries RouteTables) bool {
+	for _, m := range ms {
+		m.RouteTables = RouteTables{}
+	}
+	for _, subEntry := range subEntries {
+		vpcId := subEntry.VpcId
+		m, ok := ms[vpcId]
+		if !ok {
+			log.Warningf("route table %s(%s): vpc id %s not found",
+				subEntry.Name, subEntry.Id, vpcId)
+			continue
+		}
+		subEntry.Vpc = m
+		m.RouteTables[subEntry.Id] = subEntry
+	}
+	return true
+}
+
+func (ms Vpcs) joinNatGateways(subEntries NatGateways) bool {
+	for _, m := range ms {
+		m.NatGateways = NatGateways{}
+	}
+	for _, subEntry := range subEntries {
+		vpcId := subEntry.VpcId
+		m, ok := ms[vpcId]
+		if !ok {
+			log.Warningf("natgateway %s(%s): vpc id %s not found",
+				subEntry.Name, subEntry.Id, vpcId)
+			continue
+		}
+		subEntry.Vpc = m
+		m.NatGateways[subEntry.Id] = subEntry
+	}
+	return true
+}
+
+func (ms Vpcs) joinLoadbalancers(subEntries Loadbalancers) bool {
+	for _, m := range ms {
+		m.Loadbalancers = Loadbalancers{}
+	}
+	for _, subEntry := range subEntries {
+		vpcId := subEntry.VpcId
+		m, ok := ms[vpcId]
+		if !ok {
+			log.Warningf("loadbalancer %s(%s): vpc id %s not found",
+				subEntry.Name, subEntry.Id, vpcId)
+			continue
+		}
+		subEntry.Vpc = m
+		m.Loadbalancers[subEntry.Id] = subEntry
+	}
+	return true
+}
+
+func (ms Vpcs) joinVpcPeeringConnections(subEntries VpcPeeringConnections) bool {
+	for _, m := range ms {
+		m.PeeringConnections = VpcPeeringConnections{}
+	}
+	for _, subEntry := range subEntries {
+		subEntry.Vpc = nil
+		subEntry.PeerVpc = nil
+		vpc, ok := ms[subEntry.VpcId]
+		if !ok {
+			log.Warningf("vpc peering connection %s(%s): vpc id %s not found",
+				subEntry.Name, subEntry.Id, subEntry.VpcId)
+			continue
+		}
+		peerVpc, ok := ms[subEntry.PeerVpcId]
+		if !ok {
+			log.Warningf("vpc peering connection %s(%s): peer vpc id %s not found",
+				subEntry.Name, subEntry.Id, subEntry.PeerVpcId)
+			continue
+		}
+		subEntry.Vpc = vpc
+		subEntry.PeerVpc = peerVpc
+		vpc.PeeringConnections[subEntry.Id] = subEntry
+		peerVpc.PeeringConnections[subEntry.Id] = subEntry
+	}
+	return true
+}
+
 func (set Wires) ModelManager() mcclient_modulebase.IBaseManager {
 	return &mcclient_modules.Wires
 }
@@ -341,6 +437,372 @@ func (ms Networks) joinElasticips(subEntries Elasticips) bool {
 	return correct
 }
 
+func (ms Networks) joinRouteTableAssociations(subEntries RouteTableAssociations) bool {
+	for _, m := range ms {
+		m.RouteTable = nil
+	}
+	for _, subEntry := range subEntries {
+		if subEntry.AssociationType != string(cloudprovider.RouteTableAssociaToSubnet) {
+			continue
+		}
+		if subEntry.RouteTable == nil {
+			continue
+		}
+		netId := subEntry.AssociatedResourceId
+		m, ok := ms[netId]
+		if !ok {
+			log.Warningf("route table association %s: network %s not found", subEntry.Id, netId)
+			continue
+		}
+		if m.RouteTable != nil && m.RouteTable != subEntry.RouteTable {
+			log.Errorf("network %s(%s) associated to more than 1 route table: %s, %s", m.Name, m.Id,
+				m.RouteTable.Id, subEntry.RouteTableId)
+			continue
+		}
+		subEntry.Network = m
+		m.RouteTable = subEntry.RouteTable
+	}
+	return true
+}
+
+func (set RouteTables) ModelManager() mcclient_modulebase.IBaseManager {
+	return &mcclient_modules.RouteTables
+}
+
+func (set RouteTables) NewModel() db.IModel {
+	return &RouteTable{}
+}
+
+func (set RouteTables) AddModel(i db.IModel) {
+	m := i.(*RouteTable)
+	set[m.Id] = m
+}
+
+func (set RouteTables) Copy() apihelper.IModelSet {
+	setCopy := RouteTables{}
+	for id, el := range set {
+		setCopy[id] = el.Copy()
+	}
+	return setCopy
+}
+
+func (ms RouteTables) joinRouteTableAssociations(subEntries RouteTableAssociations) bool {
+	for _, m := range ms {
+		m.Associations = RouteTableAssociations{}
+	}
+	for _, subEntry := range subEntries {
+		rtId := subEntry.RouteTableId
+		m, ok := ms[rtId]
+		if !ok {
+			log.Warningf("route table association %s: route table %s not found", subEntry.Id, rtId)
+			subEntry.RouteTable = nil
+			continue
+		}
+		subEntry.RouteTable = m
+		m.Associations[subEntry.Id] = subEntry
+	}
+	return true
+}
+
+func (set RouteTableAssociations) ModelManager() mcclient_modulebase.IBaseManager {
+	return &mcclient_modules.RouteTableRouteAssociations
+}
+
+func (set RouteTableAssociations) NewModel() db.IModel {
+	return &RouteTableAssociation{}
+}
+
+func (set RouteTableAssociations) AddModel(i db.IModel) {
+	m := i.(*RouteTableAssociation)
+	set[m.Id] = m
+}
+
+func (set RouteTableAssociations) Copy() apihelper.IModelSet {
+	setCopy := RouteTableAssociations{}
+	for id, el := range set {
+		setCopy[id] = el.Copy()
+	}
+	return setCopy
+}
+
+func (set NatGateways) ModelManager() mcclient_modulebase.IBaseManager {
+	return &mcclient_modules.NatGateways
+}
+
+func (set NatGateways) NewModel() db.IModel {
+	return &NatGateway{}
+}
+
+func (set NatGateways) AddModel(i db.IModel) {
+	m := i.(*NatGateway)
+	set[m.Id] = m
+}
+
+func (set NatGateways) Copy() apihelper.IModelSet {
+	setCopy := NatGateways{}
+	for id, el := range set {
+		setCopy[id] = el.Copy()
+	}
+	return setCopy
+}
+
+func (ms NatGateways) joinNatSEntries(subEntries NatSEntries) bool {
+	for _, m := range ms {
+		m.SEntries = NatSEntries{}
+	}
+	for _, subEntry := range subEntries {
+		natId := subEntry.NatgatewayId
+		m, ok := ms[natId]
+		if !ok {
+			log.Warningf("snat entry %s(%s): natgateway %s not found", subEntry.Name, subEntry.Id, natId)
+			subEntry.NatGateway = nil
+			continue
+		}
+		subEntry.NatGateway = m
+		m.SEntries[subEntry.Id] = subEntry
+	}
+	return true
+}
+
+func (ms NatGateways) joinNatDEntries(subEntries NatDEntries) bool {
+	for _, m := range ms {
+		m.DEntries = NatDEntries{}
+	}
+	for _, subEntry := range subEntries {
+		natId := subEntry.NatgatewayId
+		m, ok := ms[natId]
+		if !ok {
+			log.Warningf("dnat entry %s(%s): natgateway %s not found", subEntry.Name, subEntry.Id, natId)
+			subEntry.NatGateway = nil
+			continue
+		}
+		subEntry.NatGateway = m
+		m.DEntries[subEntry.Id] = subEntry
+	}
+	return true
+}
+
+func (set NatSEntries) ModelManager() mcclient_modulebase.IBaseManager {
+	return &mcclient_modules.NatSTable
+}
+
+func (set NatSEntries) NewModel() db.IModel {
+	return &NatSEntry{}
+}
+
+func (set NatSEntries) AddModel(i db.IModel) {
+	m := i.(*NatSEntry)
+	set[m.Id] = m
+}
+
+func (set NatSEntries) Copy() apihelper.IModelSet {
+	setCopy := NatSEntries{}
+	for id, el := range set {
+		setCopy[id] = el.Copy()
+	}
+	return setCopy
+}
+
+func (set NatDEntries) ModelManager() mcclient_modulebase.IBaseManager {
+	return &mcclient_modules.NatDTable
+}
+
+func (set NatDEntries) NewModel() db.IModel {
+	return &NatDEntry{}
+}
+
+func (set NatDEntries) AddModel(i db.IModel) {
+	m := i.(*NatDEntry)
+	set[m.Id] = m
+}
+
+func (set NatDEntries) Copy() apihelper.IModelSet {
+	setCopy := NatDEntries{}
+	for id, el := range set {
+		setCopy[id] = el.Copy()
+	}
+	return setCopy
+}
+
+func (set VpcPeeringConnections) ModelManager() mcclient_modulebase.IBaseManager {
+	return &mcclient_modules.VpcPeeringConnections
+}
+
+func (set VpcPeeringConnections) NewModel() db.IModel {
+	return &VpcPeeringConnection{}
+}
+
+func (set VpcPeeringConnections) AddModel(i db.IModel) {
+	m := i.(*VpcPeeringConnection)
+	set[m.Id] = m
+}
+
+func (set VpcPeeringConnections) Copy() apihelper.IModelSet {
+	setCopy := VpcPeeringConnections{}
+	for id, el := range set {
+		setCopy[id] = el.Copy()
+	}
+	return setCopy
+}
+
+func (set Loadbalancers) ModelManager() mcclient_modulebase.IBaseManager {
+	return &mcclient_modules.Loadbalancers
+}
+
+func (set Loadbalancers) NewModel() db.IModel {
+	return &Loadbalancer{}
+}
+
+func (set Loadbalancers) AddModel(i db.IModel) {
+	m := i.(*Loadbalancer)
+	set[m.Id] = m
+}
+
+func (set Loadbalancers) Copy() apihelper.IModelSet {
+	setCopy := Loadbalancers{}
+	for id, el := range set {
+		setCopy[id] = el.Copy()
+	}
+	return setCopy
+}
+
+func (ms Loadbalancers) joinLoadbalancerListeners(subEntries LoadbalancerListeners) bool {
+	for _, m := range ms {
+		m.Listeners = LoadbalancerListeners{}
+	}
+	for _, subEntry := range subEntries {
+		lbId := subEntry.LoadbalancerId
+		m, ok := ms[lbId]
+		if !ok {
+			log.Warningf("loadbalancer listener %s(%s): loadbalancer %s not found",
+				subEntry.Name, subEntry.Id, lbId)
+			subEntry.Loadbalancer = nil
+			continue
+		}
+		subEntry.Loadbalancer = m
+		m.Listeners[subEntry.Id] = subEntry
+	}
+	return true
+}
+
+func (ms Loadbalancers) joinLoadbalancerBackendGroups(subEntries LoadbalancerBackendGroups) bool {
+	for _, m := range ms {
+		m.BackendGroups = LoadbalancerBackendGroups{}
+	}
+	for _, subEntry := range subEntries {
+		lbId := subEntry.LoadbalancerId
+		m, ok := ms[lbId]
+		if !ok {
+			log.Warningf("loadbalancer backendgroup %s(%s): loadbalancer %s not found",
+				subEntry.Name, subEntry.Id, lbId)
+			subEntry.Loadbalancer = nil
+			continue
+		}
+		subEntry.Loadbalancer = m
+		m.BackendGroups[subEntry.Id] = subEntry
+	}
+	return true
+}
+
+func (set LoadbalancerListeners) ModelManager() mcclient_modulebase.IBaseManager {
+	return &mcclient_modules.LoadbalancerListeners
+}
+
+func (set LoadbalancerListeners) NewModel() db.IModel {
+	return &LoadbalancerListener{}
+}
+
+func (set LoadbalancerListeners) AddModel(i db.IModel) {
+	m := i.(*LoadbalancerListener)
+	set[m.Id] = m
+}
+
+func (set LoadbalancerListeners) Copy() apihelper.IModelSet {
+	setCopy := LoadbalancerListeners{}
+	for id, el := range set {
+		setCopy[id] = el.Copy()
+	}
+	return setCopy
+}
+
+func (set LoadbalancerBackendGroups) ModelManager() mcclient_modulebase.IBaseManager {
+	return &mcclient_modules.LoadbalancerBackendGroups
+}
+
+func (set LoadbalancerBackendGroups) NewModel() db.IModel {
+	return &LoadbalancerBackendGroup{}
+}
+
+func (set LoadbalancerBackendGroups) AddModel(i db.IModel) {
+	m := i.(*LoadbalancerBackendGroup)
+	set[m.Id] = m
+}
+
+func (set LoadbalancerBackendGroups) Copy() apihelper.IModelSet {
+	setCopy := LoadbalancerBackendGroups{}
+	for id, el := range set {
+		setCopy[id] = el.Copy()
+	}
+	return setCopy
+}
+
+func (ms LoadbalancerBackendGroups) joinLoadbalancerBackends(subEntries LoadbalancerBackends) bool {
+	for _, m := range ms {
+		m.Backends = LoadbalancerBackends{}
+	}
+	for _, subEntry := range subEntries {
+		bgId := subEntry.BackendGroupId
+		m, ok := ms[bgId]
+		if !ok {
+			log.Warningf("loadbalancer backend %s(%s): backendgroup %s not found",
+				subEntry.Name, subEntry.Id, bgId)
+			subEntry.BackendGroup = nil
+			continue
+		}
+		subEntry.BackendGroup = m
+		m.Backends[subEntry.Id] = subEntry
+	}
+	return true
+}
+
+func (set LoadbalancerBackends) ModelManager() mcclient_modulebase.IBaseManager {
+	return &mcclient_modules.LoadbalancerBackends
+}
+
+func (set LoadbalancerBackends) NewModel() db.IModel {
+	return &LoadbalancerBackend{}
+}
+
+func (set LoadbalancerBackends) AddModel(i db.IModel) {
+	m := i.(*LoadbalancerBackend)
+	set[m.Id] = m
+}
+
+func (set LoadbalancerBackends) Copy() apihelper.IModelSet {
+	setCopy := LoadbalancerBackends{}
+	for id, el := range set {
+		setCopy[id] = el.Copy()
+	}
+	return setCopy
+}
+
+func (set LoadbalancerBackends) joinGuests(subEntries Guests) bool {
+	for _, m := range set {
+		m.Guest = nil
+		if m.BackendType != computeapis.LB_BACKEND_GUEST {
+			continue
+		}
+		g, ok := subEntries[m.BackendId]
+		if !ok {
+			log.Warningf("loadbalancer backend %s(%s): guest %s not found",
+				m.Name, m.Id, m.BackendId)
+			continue
+		}
+		m.Guest = g
+	}
+	return true
+}
+
 func (set Guestnetworks) ModelManager() mcclient_modulebase.IBaseManager {
 	return &mcclient_modules.Servernetworks
 }
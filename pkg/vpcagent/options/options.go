@@ -40,6 +40,7 @@ type VpcAgentOptions struct {
 	OvnWorkerCheckInterval int    `default:"180"`
 	OvnNorthDatabase       string `help:"address for accessing ovn north database.  Default to local unix socket"`
 	OvnUnderlayMtu         int    `help:"mtu of ovn underlay network" default:"1500"`
+	OvnNatGatewayChassis   string `help:"chassis on which snat and dnat of on-premise nat gateways are centralized"`
 }
 
 type Options struct {
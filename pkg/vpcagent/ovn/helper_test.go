@@ -0,0 +1,213 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn
+
+import (
+	"fmt"
+
+	"yunion.io/x/ovsdb/schema/ovn_nb"
+	"yunion.io/x/pkg/tristate"
+
+	apis "yunion.io/x/onecloud/pkg/apis/compute"
+	agentmodels "yunion.io/x/onecloud/pkg/vpcagent/models"
+)
+
+func testVpc(id, mode string) *agentmodels.Vpc {
+	vpc := &agentmodels.Vpc{
+		Networks:           agentmodels.Networks{},
+		RouteTables:        agentmodels.RouteTables{},
+		NatGateways:        agentmodels.NatGateways{},
+		Loadbalancers:      agentmodels.Loadbalancers{},
+		PeeringConnections: agentmodels.VpcPeeringConnections{},
+	}
+	vpc.Id = id
+	vpc.ExternalAccessMode = mode
+	return vpc
+}
+
+func testNetwork(vpc *agentmodels.Vpc, id, ipStart string, mask int8) *agentmodels.Network {
+	network := &agentmodels.Network{
+		Vpc:           vpc,
+		Guestnetworks: agentmodels.Guestnetworks{},
+	}
+	network.Id = id
+	network.GuestIpStart = ipStart
+	network.GuestIpMask = mask
+	vpc.Networks[id] = network
+	return network
+}
+
+func testGuestnetwork(network *agentmodels.Network, guestId, ipAddr string, index int8) *agentmodels.Guestnetwork {
+	guestnetwork := &agentmodels.Guestnetwork{
+		Guest:   &agentmodels.Guest{},
+		Network: network,
+	}
+	guestnetwork.Guest.Id = guestId
+	guestnetwork.GuestId = guestId
+	guestnetwork.NetworkId = network.Id
+	guestnetwork.IpAddr = ipAddr
+	guestnetwork.Index = index
+	network.Guestnetworks[fmt.Sprintf("%s/%s/%d", guestId, network.Id, index)] = guestnetwork
+	return guestnetwork
+}
+
+func testRouteTable(vpc *agentmodels.Vpc, id string, routes ...*apis.SRoute) *agentmodels.RouteTable {
+	rt := &agentmodels.RouteTable{
+		Vpc:          vpc,
+		Associations: agentmodels.RouteTableAssociations{},
+	}
+	rt.Id = id
+	rt.VpcId = vpc.Id
+	rt.Routes = (*apis.SRoutes)(&routes)
+	vpc.RouteTables[id] = rt
+	return rt
+}
+
+func testAssociate(rt *agentmodels.RouteTable, network *agentmodels.Network) {
+	assoc := &agentmodels.RouteTableAssociation{
+		RouteTable: rt,
+		Network:    network,
+	}
+	assoc.Id = rt.Id + "/" + network.Id
+	assoc.RouteTableId = rt.Id
+	rt.Associations[assoc.Id] = assoc
+	network.RouteTable = rt
+}
+
+func testRoute(cidr, nexthopType, nexthopId string) *apis.SRoute {
+	return &apis.SRoute{
+		Type:        apis.ROUTE_ENTRY_TYPE_CUSTOM,
+		Cidr:        cidr,
+		NextHopType: nexthopType,
+		NextHopId:   nexthopId,
+	}
+}
+
+func testStaticRoutesString(routes []*ovn_nb.LogicalRouterStaticRoute) []string {
+	var r []string
+	for _, route := range routes {
+		r = append(r, fmt.Sprintf("%s %s %s %s %s",
+			*route.Policy, route.IpPrefix, route.Nexthop, *route.OutputPort, route.ExternalIds[externalKeyOcRef]))
+	}
+	return r
+}
+
+func testNatGateway(vpc *agentmodels.Vpc, id string) *agentmodels.NatGateway {
+	natgateway := &agentmodels.NatGateway{
+		Vpc:      vpc,
+		SEntries: agentmodels.NatSEntries{},
+		DEntries: agentmodels.NatDEntries{},
+	}
+	natgateway.Id = id
+	natgateway.Status = apis.NAT_STAUTS_AVAILABLE
+	vpc.NatGateways[id] = natgateway
+	return natgateway
+}
+
+func testNatSEntry(natgateway *agentmodels.NatGateway, id, ip, sourceCidr, networkId string) *agentmodels.NatSEntry {
+	sentry := &agentmodels.NatSEntry{
+		NatGateway: natgateway,
+	}
+	sentry.Id = id
+	sentry.Status = apis.NAT_STAUTS_AVAILABLE
+	sentry.IP = ip
+	sentry.SourceCIDR = sourceCidr
+	sentry.NetworkId = networkId
+	natgateway.SEntries[id] = sentry
+	return sentry
+}
+
+func testNatDEntry(natgateway *agentmodels.NatGateway, id, proto, externalIp string, externalPort int, internalIp string, internalPort int) *agentmodels.NatDEntry {
+	dentry := &agentmodels.NatDEntry{
+		NatGateway: natgateway,
+	}
+	dentry.Id = id
+	dentry.Status = apis.NAT_STAUTS_AVAILABLE
+	dentry.IpProtocol = proto
+	dentry.ExternalIP = externalIp
+	dentry.ExternalPort = externalPort
+	dentry.InternalIP = internalIp
+	dentry.InternalPort = internalPort
+	natgateway.DEntries[id] = dentry
+	return dentry
+}
+
+func testLoadbalancer(vpc *agentmodels.Vpc, id, networkId, address string) *agentmodels.Loadbalancer {
+	lb := &agentmodels.Loadbalancer{
+		Vpc:           vpc,
+		Listeners:     agentmodels.LoadbalancerListeners{},
+		BackendGroups: agentmodels.LoadbalancerBackendGroups{},
+	}
+	lb.Id = id
+	lb.Status = apis.LB_STATUS_ENABLED
+	lb.NetworkId = networkId
+	lb.Address = address
+	vpc.Loadbalancers[id] = lb
+	return lb
+}
+
+func testLbBackendGroup(lb *agentmodels.Loadbalancer, id string) *agentmodels.LoadbalancerBackendGroup {
+	backendGroup := &agentmodels.LoadbalancerBackendGroup{
+		Loadbalancer: lb,
+		Backends:     agentmodels.LoadbalancerBackends{},
+	}
+	backendGroup.Id = id
+	lb.BackendGroups[id] = backendGroup
+	return backendGroup
+}
+
+func testLbBackend(backendGroup *agentmodels.LoadbalancerBackendGroup, id, guestStatus, address string, port int) *agentmodels.LoadbalancerBackend {
+	backend := &agentmodels.LoadbalancerBackend{
+		BackendGroup: backendGroup,
+	}
+	if guestStatus != "" {
+		backend.Guest = &agentmodels.Guest{}
+		backend.Guest.Status = guestStatus
+	}
+	backend.Id = id
+	backend.Address = address
+	backend.Port = port
+	backendGroup.Backends[id] = backend
+	return backend
+}
+
+func testLbListener(lb *agentmodels.Loadbalancer, id, listenerType string, port int, backendGroupId string) *agentmodels.LoadbalancerListener {
+	listener := &agentmodels.LoadbalancerListener{
+		Loadbalancer: lb,
+	}
+	listener.Id = id
+	listener.Status = apis.LB_STATUS_ENABLED
+	listener.ListenerType = listenerType
+	listener.ListenerPort = port
+	listener.BackendGroupId = backendGroupId
+	lb.Listeners[id] = listener
+	return listener
+}
+
+func testVpcPeering(id string, vpc, peerVpc *agentmodels.Vpc, linkIndex int) *agentmodels.VpcPeeringConnection {
+	pc := &agentmodels.VpcPeeringConnection{
+		Vpc:     vpc,
+		PeerVpc: peerVpc,
+	}
+	pc.Id = id
+	pc.VpcId = vpc.Id
+	pc.PeerVpcId = peerVpc.Id
+	pc.Status = apis.VPC_PEERING_CONNECTION_STATUS_ACTIVE
+	pc.Enabled = tristate.True
+	pc.LinkIndex = linkIndex
+	vpc.PeeringConnections[id] = pc
+	peerVpc.PeeringConnections[id] = pc
+	return pc
+}
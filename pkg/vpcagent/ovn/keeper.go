@@ -25,6 +25,7 @@ import (
 	"yunion.io/x/ovsdb/schema/ovn_nb"
 	"yunion.io/x/ovsdb/types"
 	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
 
 	apis "yunion.io/x/onecloud/pkg/apis/compute"
 	agentmodels "yunion.io/x/onecloud/pkg/vpcagent/models"
@@ -50,6 +51,8 @@ func DumpOVNNorthbound(ctx context.Context, cli *ovnutil.OvnNbCtl) (*OVNNorthbou
 		&db.LogicalRouter,
 		&db.LogicalRouterPort,
 		&db.LogicalRouterStaticRoute,
+		&db.NAT,
+		&db.LoadBalancer,
 		&db.ACL,
 		&db.DHCPOptions,
 		&db.QoS,
@@ -95,8 +98,9 @@ func (keeper *OVNNorthboundKeeper) ClaimVpc(ctx context.Context, vpc *agentmodel
 	irows := []types.IRow{vpcLr}
 
 	var (
-		hasDistgw = vpcHasDistgw(vpc)
-		hasEipgw  = vpcHasEipgw(vpc)
+		hasDistgw        = vpcHasDistgw(vpc)
+		hasEipgw         = vpcHasEipgw(vpc)
+		hasCustomDefault = vpcHasCustomDefaultRoute(vpc)
 	)
 
 	var (
@@ -142,12 +146,6 @@ func (keeper *OVNNorthboundKeeper) ClaimVpc(ctx context.Context, vpc *agentmodel
 				"router-port": vpcR2extpName(vpc.Id),
 			},
 		}
-		vpcDefaultRoute = &ovn_nb.LogicalRouterStaticRoute{
-			Policy:     ptr("dst-ip"),
-			IpPrefix:   "0.0.0.0/0",
-			Nexthop:    apis.VpcInterExtIP2().String(),
-			OutputPort: ptr(vpcR1extpName(vpc.Id)),
-		}
 		vpcExtDefaultRoute = &ovn_nb.LogicalRouterStaticRoute{
 			Policy:     ptr("dst-ip"),
 			IpPrefix:   "0.0.0.0/0",
@@ -161,9 +159,18 @@ func (keeper *OVNNorthboundKeeper) ClaimVpc(ctx context.Context, vpc *agentmodel
 			vpcExtr1p,
 			vpcR2extp,
 			vpcExtr2p,
-			vpcDefaultRoute,
 			vpcExtDefaultRoute,
 		)
+		if !hasCustomDefault {
+			// custom default route from route tables takes over
+			vpcDefaultRoute = &ovn_nb.LogicalRouterStaticRoute{
+				Policy:     ptr("dst-ip"),
+				IpPrefix:   "0.0.0.0/0",
+				Nexthop:    apis.VpcInterExtIP2().String(),
+				OutputPort: ptr(vpcR1extpName(vpc.Id)),
+			}
+			irows = append(irows, vpcDefaultRoute)
+		}
 	}
 
 	// distgw
@@ -238,10 +245,12 @@ func (keeper *OVNNorthboundKeeper) ClaimVpc(ctx context.Context, vpc *agentmodel
 		args = append(args, ovnCreateArgs(vpcExtr1p, vpcExtr1p.Name)...)
 		args = append(args, ovnCreateArgs(vpcR2extp, vpcR2extp.Name)...)
 		args = append(args, ovnCreateArgs(vpcExtr2p, vpcExtr2p.Name)...)
-		args = append(args, ovnCreateArgs(vpcDefaultRoute, "vpcDefaultRoute")...)
 		args = append(args, ovnCreateArgs(vpcExtDefaultRoute, "vpcExtDefaultRoute")...)
-		args = append(args, "--", "add", "Logical_Router", vpcLrName(vpc.Id), "static_routes", "@vpcDefaultRoute")
 		args = append(args, "--", "add", "Logical_Router", vpcExtLrName(vpc.Id), "static_routes", "@vpcExtDefaultRoute")
+		if vpcDefaultRoute != nil {
+			args = append(args, ovnCreateArgs(vpcDefaultRoute, "vpcDefaultRoute")...)
+			args = append(args, "--", "add", "Logical_Router", vpcLrName(vpc.Id), "static_routes", "@vpcDefaultRoute")
+		}
 		args = append(args, "--", "add", "Logical_Switch", vpcExtLs.Name, "ports", "@"+vpcExtr1p.Name)
 		args = append(args, "--", "add", "Logical_Router", vpcLr.Name, "ports", "@"+vpcR1extp.Name)
 		args = append(args, "--", "add", "Logical_Switch", vpcExtLs.Name, "ports", "@"+vpcExtr2p.Name)
@@ -264,6 +273,180 @@ func (keeper *OVNNorthboundKeeper) ClaimVpc(ctx context.Context, vpc *agentmodel
 	return keeper.cli.Must(ctx, "ClaimVpc", args)
 }
 
+func (keeper *OVNNorthboundKeeper) ClaimVpcRouteTables(ctx context.Context, vpc *agentmodels.Vpc) error {
+	var (
+		args      []string
+		ocVersion = fmt.Sprintf("%s.%d", vpc.UpdatedAt, vpc.UpdateVersion)
+	)
+	routes := vpcRouteTableRoutes(vpc)
+	for i, route := range routes {
+		// claim them one by one, so that a changed route does not
+		// cause others to be created again
+		allFound, cmpArgs := cmp(&keeper.DB, ocVersion, route)
+		if allFound {
+			continue
+		}
+		ref := fmt.Sprintf("vpcRoute%d", i)
+		args = append(args, cmpArgs...)
+		args = append(args, ovnCreateArgs(route, ref)...)
+		args = append(args, "--", "add", "Logical_Router", vpcLrName(vpc.Id), "static_routes", "@"+ref)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return keeper.cli.Must(ctx, "ClaimVpcRouteTables", args)
+}
+
+func (keeper *OVNNorthboundKeeper) ClaimVpcNatgateways(ctx context.Context, vpc *agentmodels.Vpc, chassis string) error {
+	var (
+		args      []string
+		ocVersion = fmt.Sprintf("%s.%d", vpc.UpdatedAt, vpc.UpdateVersion)
+		extLrName = vpcExtLrName(vpc.Id)
+	)
+	if !vpcHasEipgw(vpc) {
+		return nil
+	}
+
+	// snat and load balancers on distributed router need a gateway port
+	// to be centralized on.  Without them, the port is left distributed
+	repName := vpcRepName(vpc.Id)
+	vpcRep := keeper.DB.LogicalRouterPort.FindOneMatchNonZeros(&ovn_nb.LogicalRouterPort{
+		Name: repName,
+	})
+	rows := vpcNatGatewayRows(vpc)
+	if !rows.isEmpty() && chassis == "" {
+		log.Warningf("vpc %s(%s) has nat gateways, but no gateway chassis was configured", vpc.Name, vpc.Id)
+	}
+	if rows.isEmpty() || chassis == "" {
+		if vpcRep == nil || vpcRep.Options["redirect-chassis"] == "" {
+			return nil
+		}
+		args = append(args, "--", "--if-exists", "remove", "Logical_Router_Port", repName, "options", "redirect-chassis")
+		return keeper.cli.Must(ctx, "ClaimVpcNatgateways", args)
+	}
+	if vpcRep == nil || vpcRep.Options["redirect-chassis"] != chassis {
+		args = append(args, "--", "set", "Logical_Router_Port", repName, "options:redirect-chassis="+chassis)
+	}
+	for i, nat := range rows.nats {
+		allFound, cmpArgs := cmp(&keeper.DB, ocVersion, nat)
+		if allFound {
+			continue
+		}
+		ref := fmt.Sprintf("natgwNat%d", i)
+		args = append(args, cmpArgs...)
+		args = append(args, ovnCreateArgs(nat, ref)...)
+		args = append(args, "--", "add", "Logical_Router", extLrName, "nat", "@"+ref)
+	}
+	for i, lb := range rows.lbs {
+		allFound, cmpArgs := cmp(&keeper.DB, ocVersion, lb)
+		if allFound {
+			continue
+		}
+		ref := fmt.Sprintf("natgwLb%d", i)
+		args = append(args, cmpArgs...)
+		args = append(args, ovnCreateArgs(lb, ref)...)
+		args = append(args, "--", "add", "Logical_Router", extLrName, "load_balancer", "@"+ref)
+	}
+	for i, route := range rows.routes {
+		allFound, cmpArgs := cmp(&keeper.DB, ocVersion, route)
+		if allFound {
+			continue
+		}
+		ref := fmt.Sprintf("natgwRoute%d", i)
+		args = append(args, cmpArgs...)
+		args = append(args, ovnCreateArgs(route, ref)...)
+		args = append(args, "--", "add", "Logical_Router", extLrName, "static_routes", "@"+ref)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return keeper.cli.Must(ctx, "ClaimVpcNatgateways", args)
+}
+
+func (keeper *OVNNorthboundKeeper) ClaimVpcPeerings(ctx context.Context, vpc *agentmodels.Vpc) error {
+	var (
+		args      []string
+		ocVersion = fmt.Sprintf("%s.%d", vpc.UpdatedAt, vpc.UpdateVersion)
+		lrName    = vpcLrName(vpc.Id)
+	)
+	rows := vpcPeeringRows(vpc)
+	for i, lrp := range rows.lrps {
+		allFound, cmpArgs := cmp(&keeper.DB, ocVersion, lrp)
+		if allFound {
+			continue
+		}
+		ref := fmt.Sprintf("peerLrp%d", i)
+		args = append(args, cmpArgs...)
+		args = append(args, ovnCreateArgs(lrp, ref)...)
+		args = append(args, "--", "add", "Logical_Router", lrName, "ports", "@"+ref)
+	}
+	for i, route := range rows.routes {
+		allFound, cmpArgs := cmp(&keeper.DB, ocVersion, route)
+		if allFound {
+			continue
+		}
+		ref := fmt.Sprintf("peerRoute%d", i)
+		args = append(args, cmpArgs...)
+		args = append(args, ovnCreateArgs(route, ref)...)
+		args = append(args, "--", "add", "Logical_Router", lrName, "static_routes", "@"+ref)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return keeper.cli.Must(ctx, "ClaimVpcPeerings", args)
+}
+
+// ClaimVpcLoadbalancers attaches load balancers to logical switches of all
+// networks in the vpc so that they apply to east-west traffic
+func (keeper *OVNNorthboundKeeper) ClaimVpcLoadbalancers(ctx context.Context, vpc *agentmodels.Vpc) error {
+	var (
+		args      []string
+		ocVersion = fmt.Sprintf("%s.%d", vpc.UpdatedAt, vpc.UpdateVersion)
+		lsNames   []string
+	)
+	rows := vpcLoadbalancerRows(vpc)
+	for netId := range vpc.Networks {
+		lsNames = append(lsNames, netLsName(netId))
+	}
+	sort.Strings(lsNames)
+
+	for i, lb := range rows.lbs {
+		allFound, cmpArgs := cmp(&keeper.DB, ocVersion, lb)
+		if allFound {
+			// attach to logical switches of networks created afterwards
+			m := keeper.DB.LoadBalancer.FindOneMatchNonZeros(lb)
+			for _, lsName := range lsNames {
+				ls := keeper.DB.LogicalSwitch.FindOneMatchNonZeros(&ovn_nb.LogicalSwitch{Name: lsName})
+				if ls != nil && utils.IsInStringArray(m.Uuid, ls.LoadBalancer) {
+					continue
+				}
+				args = append(args, "--", "add", "Logical_Switch", lsName, "load_balancer", m.Uuid)
+			}
+			continue
+		}
+		ref := fmt.Sprintf("lb%d", i)
+		args = append(args, cmpArgs...)
+		args = append(args, ovnCreateArgs(lb, ref)...)
+		for _, lsName := range lsNames {
+			args = append(args, "--", "add", "Logical_Switch", lsName, "load_balancer", "@"+ref)
+		}
+	}
+	for i, lsp := range rows.vipLsps {
+		allFound, cmpArgs := cmp(&keeper.DB, ocVersion, lsp)
+		if allFound {
+			continue
+		}
+		ref := fmt.Sprintf("lbVipLsp%d", i)
+		args = append(args, cmpArgs...)
+		args = append(args, ovnCreateArgs(lsp, ref)...)
+		args = append(args, "--", "add", "Logical_Switch", rows.vipLss[i], "ports", "@"+ref)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return keeper.cli.Must(ctx, "ClaimVpcLoadbalancers", args)
+}
+
 func (keeper *OVNNorthboundKeeper) ClaimNetwork(ctx context.Context, network *agentmodels.Network, mtu int) error {
 	var (
 		rpMac   = mac.HashSubnetRouterPortMac(network.Id)
@@ -561,7 +744,8 @@ func (keeper *OVNNorthboundKeeper) ClaimGuestnetwork(ctx context.Context, guestn
 				}
 			}
 
-		} else if vpcHasDistgw(vpc) {
+		} else if vpcHasDistgw(vpc) && !vpcNatSnatCovers(vpc, guestnetwork.IpAddr) {
+			// snat of nat gateway takes over
 			gnrDefault = &ovn_nb.LogicalRouterStaticRoute{
 				Policy:     &gnrDefaultPolicy,
 				IpPrefix:   guestnetwork.IpAddr + "/32",
@@ -751,6 +935,8 @@ func (keeper *OVNNorthboundKeeper) Mark(ctx context.Context) {
 		&db.LogicalRouter,
 		&db.LogicalRouterPort,
 		&db.LogicalRouterStaticRoute,
+		&db.NAT,
+		&db.LoadBalancer,
 		&db.ACL,
 		&db.DHCPOptions,
 		&db.QoS,
@@ -802,6 +988,38 @@ func (keeper *OVNNorthboundKeeper) Sweep(ctx context.Context) error {
 			keeper.cli.Must(ctx, "Sweep static routes", args)
 		}
 	}
+	{
+		var args []string
+		for _, irow := range db.NAT.Rows() {
+			_, ok := irow.GetExternalId(externalKeyOcVersion)
+			if !ok {
+				for _, lr := range db.LogicalRouter.FindNATReferrer_nat(irow.OvsdbUuid()) {
+					args = append(args, "--", "--if-exists", "remove", "Logical_Router", lr.Name, "nat", irow.OvsdbUuid())
+				}
+			}
+		}
+		if len(args) > 0 {
+			keeper.cli.Must(ctx, "Sweep nats", args)
+		}
+	}
+	{ // load balancers are root rows, references must be removed first
+		var args []string
+		for _, irow := range db.LoadBalancer.Rows() {
+			_, ok := irow.GetExternalId(externalKeyOcVersion)
+			if !ok {
+				for _, lr := range db.LogicalRouter.FindLoadBalancerReferrer_load_balancer(irow.OvsdbUuid()) {
+					args = append(args, "--", "--if-exists", "remove", "Logical_Router", lr.Name, "load_balancer", irow.OvsdbUuid())
+				}
+				for _, ls := range db.LogicalSwitch.FindLoadBalancerReferrer_load_balancer(irow.OvsdbUuid()) {
+					args = append(args, "--", "--if-exists", "remove", "Logical_Switch", ls.Name, "load_balancer", irow.OvsdbUuid())
+				}
+				args = append(args, "--", "--if-exists", "destroy", irow.OvsdbTableName(), irow.OvsdbUuid())
+			}
+		}
+		if len(args) > 0 {
+			keeper.cli.Must(ctx, "Sweep load balancers", args)
+		}
+	}
 	{
 		var args []string
 		for _, irow := range db.ACL.Rows() {
@@ -0,0 +1,134 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"yunion.io/x/log"
+	"yunion.io/x/ovsdb/schema/ovn_nb"
+
+	apis "yunion.io/x/onecloud/pkg/apis/compute"
+	agentmodels "yunion.io/x/onecloud/pkg/vpcagent/models"
+	"yunion.io/x/onecloud/pkg/vpcagent/ovn/mac"
+)
+
+type vpcLbRows struct {
+	lbs []*ovn_nb.LoadBalancer
+
+	// vipLsps answer arp requests for loadbalancer addresses from guests
+	// in the same network.  vipLss are names of their logical switches
+	vipLsps []*ovn_nb.LogicalSwitchPort
+	vipLss  []string
+}
+
+func vpcLoadbalancerIds(vpc *agentmodels.Vpc) []string {
+	var lbIds []string
+	for lbId, lb := range vpc.Loadbalancers {
+		if lb.Status != apis.LB_STATUS_ENABLED || lb.Address == "" {
+			continue
+		}
+		lbIds = append(lbIds, lbId)
+	}
+	sort.Strings(lbIds)
+	return lbIds
+}
+
+// lbBackendGroupEndpoints returns "ip:port" of backends whose guest is
+// running.
+//
+// The OVN northbound schema we speak has no Load_Balancer_Health_Check
+// table, so listeners of vpc loadbalancers come without health check.
+// Guests known to be not running are still left out as they can not
+// serve traffic
+func lbBackendGroupEndpoints(backendGroup *agentmodels.LoadbalancerBackendGroup) []string {
+	var endpoints []string
+	for _, backend := range backendGroup.Backends {
+		if backend.Guest == nil || backend.Guest.Status != apis.VM_RUNNING {
+			continue
+		}
+		if backend.Address == "" || backend.Port <= 0 {
+			continue
+		}
+		endpoints = append(endpoints, fmt.Sprintf("%s:%d", backend.Address, backend.Port))
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+// vpcLoadbalancerRows converts enabled tcp/udp listeners of loadbalancers in
+// the vpc to load balancer rows, one for each loadbalancer and protocol
+func vpcLoadbalancerRows(vpc *agentmodels.Vpc) *vpcLbRows {
+	rows := &vpcLbRows{}
+	for _, lbId := range vpcLoadbalancerIds(vpc) {
+		lb := vpc.Loadbalancers[lbId]
+
+		vips := map[string]map[string]string{}
+		for _, listener := range lb.Listeners {
+			if listener.Status != apis.LB_STATUS_ENABLED {
+				continue
+			}
+			proto := listener.ListenerType
+			switch proto {
+			case apis.LB_LISTENER_TYPE_TCP, apis.LB_LISTENER_TYPE_UDP:
+			default:
+				log.Errorf("loadbalancer listener %s(%s): unsupported type %q",
+					listener.Name, listener.Id, listener.ListenerType)
+				continue
+			}
+			backendGroupId := listener.BackendGroupId
+			if backendGroupId == "" {
+				backendGroupId = lb.BackendGroupId
+			}
+			backendGroup, ok := lb.BackendGroups[backendGroupId]
+			if !ok {
+				continue
+			}
+			endpoints := lbBackendGroupEndpoints(backendGroup)
+			if len(endpoints) == 0 {
+				continue
+			}
+			if vips[proto] == nil {
+				vips[proto] = map[string]string{}
+			}
+			vip := fmt.Sprintf("%s:%d", lb.Address, listener.ListenerPort)
+			vips[proto][vip] = strings.Join(endpoints, ",")
+		}
+		for _, proto := range []string{apis.LB_LISTENER_TYPE_TCP, apis.LB_LISTENER_TYPE_UDP} {
+			if len(vips[proto]) == 0 {
+				continue
+			}
+			rows.lbs = append(rows.lbs, &ovn_nb.LoadBalancer{
+				Name:     lbName(lb.Id, proto),
+				Protocol: ptr(proto),
+				Vips:     vips[proto],
+				ExternalIds: map[string]string{
+					externalKeyOcRef: fmt.Sprintf("lb/%s", lb.Id),
+				},
+			})
+		}
+
+		if _, ok := vpc.Networks[lb.NetworkId]; ok {
+			rows.vipLsps = append(rows.vipLsps, &ovn_nb.LogicalSwitchPort{
+				Name:      lbVipLspName(lb.Id),
+				Addresses: []string{fmt.Sprintf("%s %s", mac.HashLoadbalancerVipMac(lb.Id), lb.Address)},
+			})
+			rows.vipLss = append(rows.vipLss, netLsName(lb.NetworkId))
+		}
+	}
+	return rows
+}
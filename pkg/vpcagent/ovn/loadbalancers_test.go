@@ -0,0 +1,131 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	apis "yunion.io/x/onecloud/pkg/apis/compute"
+	agentmodels "yunion.io/x/onecloud/pkg/vpcagent/models"
+	"yunion.io/x/onecloud/pkg/vpcagent/ovn/mac"
+)
+
+func TestVpcLoadbalancerRows(t *testing.T) {
+	cases := []struct {
+		name    string
+		vpc     func() *agentmodels.Vpc
+		lbs     []string
+		vipLsps []string
+		vipLss  []string
+	}{
+		{
+			name: "listeners by protocol",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_NONE)
+				testNetwork(vpc, "net0", "192.168.0.0", 24)
+				lb := testLoadbalancer(vpc, "lb0", "net0", "192.168.0.100")
+				lb.BackendGroupId = "lbbg0"
+				lbbg0 := testLbBackendGroup(lb, "lbbg0")
+				testLbBackend(lbbg0, "lbb1", apis.VM_RUNNING, "192.168.0.11", 8080)
+				testLbBackend(lbbg0, "lbb0", apis.VM_READY, "192.168.0.10", 8080)
+				lbbg1 := testLbBackendGroup(lb, "lbbg1")
+				testLbBackend(lbbg1, "lbb2", apis.VM_RUNNING, "192.168.0.12", 53)
+				testLbListener(lb, "lis0", apis.LB_LISTENER_TYPE_TCP, 80, "")
+				testLbListener(lb, "lis1", apis.LB_LISTENER_TYPE_TCP, 443, "lbbg0")
+				testLbListener(lb, "lis2", apis.LB_LISTENER_TYPE_UDP, 53, "lbbg1")
+				return vpc
+			},
+			lbs: []string{
+				"lb/lb0/tcp tcp map[192.168.0.100:443:192.168.0.11:8080 192.168.0.100:80:192.168.0.11:8080] lb/lb0",
+				"lb/lb0/udp udp map[192.168.0.100:53:192.168.0.12:53] lb/lb0",
+			},
+			vipLsps: []string{
+				fmt.Sprintf("lb-vip/lb0 %s 192.168.0.100", mac.HashLoadbalancerVipMac("lb0")),
+			},
+			vipLss: []string{"subnet/net0"},
+		},
+		{
+			name: "skipped listeners and backends",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_NONE)
+				testNetwork(vpc, "net0", "192.168.0.0", 24)
+				lb := testLoadbalancer(vpc, "lb0", "net0", "192.168.0.100")
+				lbbg0 := testLbBackendGroup(lb, "lbbg0")
+				testLbBackend(lbbg0, "lbb0", apis.VM_RUNNING, "192.168.0.10", 8080)
+				testLbBackend(lbbg0, "lbb1", "", "192.168.0.11", 8080)
+				testLbBackend(lbbg0, "lbb2", apis.VM_RUNNING, "", 8080)
+				testLbBackend(lbbg0, "lbb3", apis.VM_RUNNING, "192.168.0.13", 0)
+				testLbBackendGroup(lb, "lbbg1")
+				testLbListener(lb, "lis0", apis.LB_LISTENER_TYPE_TCP, 80, "lbbg0")
+				listener := testLbListener(lb, "lis1", apis.LB_LISTENER_TYPE_TCP, 81, "lbbg0")
+				listener.Status = apis.LB_STATUS_DISABLED
+				testLbListener(lb, "lis2", apis.LB_LISTENER_TYPE_HTTP, 82, "lbbg0")
+				testLbListener(lb, "lis3", apis.LB_LISTENER_TYPE_TCP, 83, "lbbg1")
+				testLbListener(lb, "lis4", apis.LB_LISTENER_TYPE_TCP, 84, "lbbg9")
+				testLbListener(lb, "lis5", apis.LB_LISTENER_TYPE_UDP, 85, "")
+				return vpc
+			},
+			lbs: []string{
+				"lb/lb0/tcp tcp map[192.168.0.100:80:192.168.0.10:8080] lb/lb0",
+			},
+			vipLsps: []string{
+				fmt.Sprintf("lb-vip/lb0 %s 192.168.0.100", mac.HashLoadbalancerVipMac("lb0")),
+			},
+			vipLss: []string{"subnet/net0"},
+		},
+		{
+			name: "skipped loadbalancers",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_NONE)
+				testNetwork(vpc, "net0", "192.168.0.0", 24)
+				lb0 := testLoadbalancer(vpc, "lb0", "net0", "192.168.0.100")
+				lb0.Status = apis.LB_STATUS_DISABLED
+				testLoadbalancer(vpc, "lb1", "net0", "")
+				lb2 := testLoadbalancer(vpc, "lb2", "net9", "192.168.9.100")
+				lbbg := testLbBackendGroup(lb2, "lbbg0")
+				testLbBackend(lbbg, "lbb0", apis.VM_RUNNING, "192.168.0.10", 8080)
+				testLbListener(lb2, "lis0", apis.LB_LISTENER_TYPE_TCP, 80, "lbbg0")
+				return vpc
+			},
+			lbs: []string{
+				"lb/lb2/tcp tcp map[192.168.9.100:80:192.168.0.10:8080] lb/lb2",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rows := vpcLoadbalancerRows(c.vpc())
+			var lbs, vipLsps []string
+			for _, lb := range rows.lbs {
+				lbs = append(lbs, fmt.Sprintf("%s %s %v %s",
+					lb.Name, *lb.Protocol, lb.Vips, lb.ExternalIds[externalKeyOcRef]))
+			}
+			for _, lsp := range rows.vipLsps {
+				vipLsps = append(vipLsps, fmt.Sprintf("%s %s", lsp.Name, lsp.Addresses[0]))
+			}
+			if !reflect.DeepEqual(lbs, c.lbs) {
+				t.Errorf("vpcLoadbalancerRows() lbs = %v, want %v", lbs, c.lbs)
+			}
+			if !reflect.DeepEqual(vipLsps, c.vipLsps) {
+				t.Errorf("vpcLoadbalancerRows() vipLsps = %v, want %v", vipLsps, c.vipLsps)
+			}
+			if !reflect.DeepEqual(rows.vipLss, c.vipLss) {
+				t.Errorf("vpcLoadbalancerRows() vipLss = %v, want %v", rows.vipLss, c.vipLss)
+			}
+		})
+	}
+}
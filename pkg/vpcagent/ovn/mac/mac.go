@@ -36,3 +36,11 @@ func HashSubnetDhcpMac(netId string) string {
 func HashSubnetMetadataMac(netId string) string {
 	return HashMac(netId, "md")
 }
+
+func HashLoadbalancerVipMac(lbId string) string {
+	return HashMac(lbId, "lbvip")
+}
+
+func HashVpcPeeringPortMac(peeringId, vpcId string) string {
+	return HashMac(peeringId, vpcId)
+}
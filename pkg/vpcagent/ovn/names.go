@@ -89,3 +89,19 @@ func netMdpName(netId string) string {
 func gnpName(netId string, ifname string) string {
 	return fmt.Sprintf("iface-%s-%s", netId, ifname)
 }
+
+func natgwLbName(natgwId string, proto string) string {
+	return fmt.Sprintf("natgw/%s/%s", natgwId, proto)
+}
+
+func lbName(lbId string, proto string) string {
+	return fmt.Sprintf("lb/%s/%s", lbId, proto)
+}
+
+func lbVipLspName(lbId string) string {
+	return fmt.Sprintf("lb-vip/%s", lbId)
+}
+
+func vpcPeerRpName(peeringId string, vpcId string) string {
+	return fmt.Sprintf("vpc-peer/%s/%s", peeringId, vpcId)
+}
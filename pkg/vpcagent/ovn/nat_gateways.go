@@ -0,0 +1,269 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"yunion.io/x/log"
+	"yunion.io/x/ovsdb/schema/ovn_nb"
+
+	apis "yunion.io/x/onecloud/pkg/apis/compute"
+	agentmodels "yunion.io/x/onecloud/pkg/vpcagent/models"
+)
+
+const (
+	natTypeSnat = "snat"
+)
+
+type vpcNatRows struct {
+	nats   []*ovn_nb.NAT
+	lbs    []*ovn_nb.LoadBalancer
+	routes []*ovn_nb.LogicalRouterStaticRoute
+}
+
+func (rows *vpcNatRows) isEmpty() bool {
+	return len(rows.nats) == 0 && len(rows.lbs) == 0
+}
+
+func vpcNatGatewayIds(vpc *agentmodels.Vpc) []string {
+	var natIds []string
+	for natId, natgateway := range vpc.NatGateways {
+		if natgateway.Status != apis.NAT_STAUTS_AVAILABLE {
+			continue
+		}
+		natIds = append(natIds, natId)
+	}
+	sort.Strings(natIds)
+	return natIds
+}
+
+// natSEntryLogicalIp returns source cidr the snat entry applies to
+func natSEntryLogicalIp(vpc *agentmodels.Vpc, sentry *agentmodels.NatSEntry) (string, error) {
+	if sentry.SourceCIDR != "" {
+		return routeIpPrefix(sentry.SourceCIDR)
+	}
+	network, ok := vpc.Networks[sentry.NetworkId]
+	if !ok {
+		return "", fmt.Errorf("network %s not found in vpc", sentry.NetworkId)
+	}
+	ipnet, err := networkCidr(network)
+	if err != nil {
+		return "", err
+	}
+	return ipnet.String(), nil
+}
+
+// vpcNatGatewayRows converts snat and dnat entries of on-premise nat gateways
+// of the vpc to rows on the vpc external logical router.
+//
+// SNAT entries become NAT rows of type snat.  DNAT entries are port
+// forwarding rules, which OVN NAT rows cannot express, so they are realized
+// as load balancers with one vip for each entry, grouped by protocol.
+//
+// Guests holding their own eip go out with the eip, so their addresses are
+// carved out of source cidrs of the snat rows.
+//
+// Traffic from source cidrs of snat entries and from internal addresses of
+// dnat entries is steered to the eip gateway with source-based routes
+func vpcNatGatewayRows(vpc *agentmodels.Vpc) *vpcNatRows {
+	var (
+		rows = &vpcNatRows{}
+
+		eipgwVip    = apis.VpcEipGatewayIP3().String()
+		routeOutput = vpcRepName(vpc.Id)
+		routeIpnets []*net.IPNet
+		routeHas    = map[string]struct{}{}
+		eipAddrs    = vpcEipHolderAddrs(vpc)
+	)
+	addRoute := func(natId, prefix string) {
+		if _, ok := routeHas[prefix]; ok {
+			return
+		}
+		routeHas[prefix] = struct{}{}
+		rows.routes = append(rows.routes, &ovn_nb.LogicalRouterStaticRoute{
+			Policy:     ptr(routePolicySrcIp),
+			IpPrefix:   prefix,
+			Nexthop:    eipgwVip,
+			OutputPort: ptr(routeOutput),
+			ExternalIds: map[string]string{
+				externalKeyOcRef: fmt.Sprintf("natgw/%s", natId),
+			},
+		})
+	}
+
+	for _, natId := range vpcNatGatewayIds(vpc) {
+		natgateway := vpc.NatGateways[natId]
+
+		var sentryIds []string
+		for sentryId := range natgateway.SEntries {
+			sentryIds = append(sentryIds, sentryId)
+		}
+		sort.Strings(sentryIds)
+		for _, sentryId := range sentryIds {
+			sentry := natgateway.SEntries[sentryId]
+			if sentry.Status != apis.NAT_STAUTS_AVAILABLE {
+				continue
+			}
+			logicalIp, err := natSEntryLogicalIp(vpc, sentry)
+			if err != nil {
+				log.Errorf("snat entry %s(%s): %v", sentry.Name, sentry.Id, err)
+				continue
+			}
+			_, ipnet, err := net.ParseCIDR(logicalIp)
+			if err != nil {
+				log.Errorf("snat entry %s(%s): %v", sentry.Name, sentry.Id, err)
+				continue
+			}
+			for _, snatIpnet := range ipnetExclude(ipnet, eipAddrs) {
+				rows.nats = append(rows.nats, &ovn_nb.NAT{
+					Type:       natTypeSnat,
+					ExternalIp: sentry.IP,
+					LogicalIp:  snatIpnet.String(),
+					ExternalIds: map[string]string{
+						externalKeyOcRef: fmt.Sprintf("snat/%s", sentry.Id),
+					},
+				})
+			}
+			routeIpnets = append(routeIpnets, ipnet)
+			addRoute(natgateway.Id, logicalIp)
+		}
+
+		var (
+			dentryIds []string
+			vips      = map[string]map[string]string{}
+		)
+		for dentryId := range natgateway.DEntries {
+			dentryIds = append(dentryIds, dentryId)
+		}
+		sort.Strings(dentryIds)
+		for _, dentryId := range dentryIds {
+			dentry := natgateway.DEntries[dentryId]
+			if dentry.Status != apis.NAT_STAUTS_AVAILABLE {
+				continue
+			}
+			proto := strings.ToLower(dentry.IpProtocol)
+			switch proto {
+			case "tcp", "udp":
+			default:
+				log.Errorf("dnat entry %s(%s): unsupported protocol %q", dentry.Name, dentry.Id, dentry.IpProtocol)
+				continue
+			}
+			if vips[proto] == nil {
+				vips[proto] = map[string]string{}
+			}
+			vip := fmt.Sprintf("%s:%d", dentry.ExternalIP, dentry.ExternalPort)
+			vips[proto][vip] = fmt.Sprintf("%s:%d", dentry.InternalIP, dentry.InternalPort)
+
+			if ip := net.ParseIP(dentry.InternalIP); ip != nil && !ipnetsContain(routeIpnets, ip) {
+				addRoute(natgateway.Id, dentry.InternalIP+"/32")
+			}
+		}
+		for _, proto := range []string{"tcp", "udp"} {
+			if len(vips[proto]) == 0 {
+				continue
+			}
+			rows.lbs = append(rows.lbs, &ovn_nb.LoadBalancer{
+				Name:     natgwLbName(natgateway.Id, proto),
+				Protocol: ptr(proto),
+				Vips:     vips[proto],
+				ExternalIds: map[string]string{
+					externalKeyOcRef: fmt.Sprintf("dnat/%s", natgateway.Id),
+				},
+			})
+		}
+	}
+	return rows
+}
+
+// vpcNatSnatCovers returns whether addr is in source cidrs of snat entries
+// of the vpc
+func vpcNatSnatCovers(vpc *agentmodels.Vpc, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, natId := range vpcNatGatewayIds(vpc) {
+		for _, sentry := range vpc.NatGateways[natId].SEntries {
+			if sentry.Status != apis.NAT_STAUTS_AVAILABLE {
+				continue
+			}
+			logicalIp, err := natSEntryLogicalIp(vpc, sentry)
+			if err != nil {
+				continue
+			}
+			if _, ipnet, err := net.ParseCIDR(logicalIp); err == nil && ipnet.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// vpcEipHolderAddrs returns addresses of guest nics with eip in the vpc
+func vpcEipHolderAddrs(vpc *agentmodels.Vpc) []net.IP {
+	var ips []net.IP
+	for _, network := range vpc.Networks {
+		for _, guestnetwork := range network.Guestnetworks {
+			if guestnetwork.Elasticip == nil {
+				continue
+			}
+			if ip := net.ParseIP(guestnetwork.IpAddr).To4(); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
+
+// ipnetExclude returns the fewest cidrs covering ipnet except ips, in
+// ascending order
+func ipnetExclude(ipnet *net.IPNet, ips []net.IP) []*net.IPNet {
+	if !ipnetsContainAny(ipnet, ips) {
+		return []*net.IPNet{ipnet}
+	}
+	ones, bits := ipnet.Mask.Size()
+	if ones == bits {
+		return nil
+	}
+	mask := net.CIDRMask(ones+1, bits)
+	lo := &net.IPNet{IP: ipnet.IP.Mask(mask), Mask: mask}
+	hiIp := make(net.IP, len(lo.IP))
+	copy(hiIp, lo.IP)
+	hiIp[ones/8] |= 0x80 >> uint(ones%8)
+	hi := &net.IPNet{IP: hiIp, Mask: mask}
+	return append(ipnetExclude(lo, ips), ipnetExclude(hi, ips)...)
+}
+
+func ipnetsContainAny(ipnet *net.IPNet, ips []net.IP) bool {
+	for _, ip := range ips {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func ipnetsContain(ipnets []*net.IPNet, ip net.IP) bool {
+	for _, ipnet := range ipnets {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	apis "yunion.io/x/onecloud/pkg/apis/compute"
+	agentmodels "yunion.io/x/onecloud/pkg/vpcagent/models"
+)
+
+func TestVpcNatGatewayRows(t *testing.T) {
+	cases := []struct {
+		name   string
+		vpc    func() *agentmodels.Vpc
+		nats   []string
+		lbs    []string
+		routes []string
+	}{
+		{
+			name: "no nat gateway",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_EIP)
+				testNetwork(vpc, "net0", "192.168.0.0", 24)
+				return vpc
+			},
+		},
+		{
+			name: "unavailable nat gateway",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_EIP)
+				testNetwork(vpc, "net0", "192.168.0.0", 24)
+				natgateway := testNatGateway(vpc, "natgw0")
+				natgateway.Status = "deleting"
+				testNatSEntry(natgateway, "snat0", "10.168.0.10", "192.168.0.0/24", "")
+				testNatDEntry(natgateway, "dnat0", "tcp", "10.168.0.10", 80, "192.168.0.10", 8080)
+				return vpc
+			},
+		},
+		{
+			name: "snat",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_EIP)
+				testNetwork(vpc, "net0", "192.168.0.0", 24)
+				testNetwork(vpc, "net1", "192.168.1.0", 24)
+				natgateway := testNatGateway(vpc, "natgw0")
+				testNatSEntry(natgateway, "snat0", "10.168.0.10", "192.168.0.0/25", "")
+				testNatSEntry(natgateway, "snat1", "10.168.0.11", "", "net1")
+				testNatSEntry(natgateway, "snat2", "10.168.0.12", "192.168.0.20", "")
+				testNatSEntry(natgateway, "snat3", "10.168.0.13", "", "net9")
+				sentry := testNatSEntry(natgateway, "snat4", "10.168.0.14", "192.168.0.128/25", "")
+				sentry.Status = "deleting"
+				return vpc
+			},
+			nats: []string{
+				"snat 10.168.0.10 192.168.0.0/25 snat/snat0",
+				"snat 10.168.0.11 192.168.1.0/24 snat/snat1",
+				"snat 10.168.0.12 192.168.0.20/32 snat/snat2",
+			},
+			routes: []string{
+				"src-ip 192.168.0.0/25 100.64.128.3 vpc-re/vpc0 natgw/natgw0",
+				"src-ip 192.168.1.0/24 100.64.128.3 vpc-re/vpc0 natgw/natgw0",
+				"src-ip 192.168.0.20/32 100.64.128.3 vpc-re/vpc0 natgw/natgw0",
+			},
+		},
+		{
+			name: "snat without eip holders",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_EIP)
+				net0 := testNetwork(vpc, "net0", "192.168.0.0", 24)
+				testGuestnetwork(net0, "guest0", "192.168.0.10", 0)
+				guestnetwork := testGuestnetwork(net0, "guest1", "192.168.0.130", 0)
+				guestnetwork.Elasticip = &agentmodels.Elasticip{}
+				guestnetwork = testGuestnetwork(net0, "guest2", "192.168.0.20", 0)
+				guestnetwork.Elasticip = &agentmodels.Elasticip{}
+				natgateway := testNatGateway(vpc, "natgw0")
+				testNatSEntry(natgateway, "snat0", "10.168.0.10", "192.168.0.128/25", "")
+				testNatSEntry(natgateway, "snat1", "10.168.0.11", "192.168.0.20", "")
+				return vpc
+			},
+			nats: []string{
+				"snat 10.168.0.10 192.168.0.128/31 snat/snat0",
+				"snat 10.168.0.10 192.168.0.131/32 snat/snat0",
+				"snat 10.168.0.10 192.168.0.132/30 snat/snat0",
+				"snat 10.168.0.10 192.168.0.136/29 snat/snat0",
+				"snat 10.168.0.10 192.168.0.144/28 snat/snat0",
+				"snat 10.168.0.10 192.168.0.160/27 snat/snat0",
+				"snat 10.168.0.10 192.168.0.192/26 snat/snat0",
+			},
+			routes: []string{
+				"src-ip 192.168.0.128/25 100.64.128.3 vpc-re/vpc0 natgw/natgw0",
+				"src-ip 192.168.0.20/32 100.64.128.3 vpc-re/vpc0 natgw/natgw0",
+			},
+		},
+		{
+			name: "dnat",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_EIP)
+				testNetwork(vpc, "net0", "192.168.0.0", 24)
+				natgateway := testNatGateway(vpc, "natgw0")
+				testNatSEntry(natgateway, "snat0", "10.168.0.10", "192.168.0.0/25", "")
+				testNatDEntry(natgateway, "dnat0", "TCP", "10.168.0.10", 80, "192.168.0.10", 8080)
+				testNatDEntry(natgateway, "dnat1", "tcp", "10.168.0.10", 443, "192.168.0.200", 8443)
+				testNatDEntry(natgateway, "dnat2", "udp", "10.168.0.10", 53, "192.168.0.200", 53)
+				testNatDEntry(natgateway, "dnat3", "icmp", "10.168.0.10", 0, "192.168.0.201", 0)
+				dentry := testNatDEntry(natgateway, "dnat4", "tcp", "10.168.0.10", 22, "192.168.0.202", 22)
+				dentry.Status = "deleting"
+				return vpc
+			},
+			nats: []string{
+				"snat 10.168.0.10 192.168.0.0/25 snat/snat0",
+			},
+			lbs: []string{
+				"natgw/natgw0/tcp tcp map[10.168.0.10:443:192.168.0.200:8443 10.168.0.10:80:192.168.0.10:8080] dnat/natgw0",
+				"natgw/natgw0/udp udp map[10.168.0.10:53:192.168.0.200:53] dnat/natgw0",
+			},
+			routes: []string{
+				"src-ip 192.168.0.0/25 100.64.128.3 vpc-re/vpc0 natgw/natgw0",
+				"src-ip 192.168.0.200/32 100.64.128.3 vpc-re/vpc0 natgw/natgw0",
+			},
+		},
+		{
+			name: "multiple nat gateways",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_EIP)
+				testNetwork(vpc, "net0", "192.168.0.0", 24)
+				natgateway1 := testNatGateway(vpc, "natgw1")
+				testNatSEntry(natgateway1, "snat1", "10.168.0.11", "192.168.0.0/24", "")
+				natgateway0 := testNatGateway(vpc, "natgw0")
+				testNatSEntry(natgateway0, "snat0", "10.168.0.10", "192.168.0.0/24", "")
+				return vpc
+			},
+			nats: []string{
+				"snat 10.168.0.10 192.168.0.0/24 snat/snat0",
+				"snat 10.168.0.11 192.168.0.0/24 snat/snat1",
+			},
+			routes: []string{
+				"src-ip 192.168.0.0/24 100.64.128.3 vpc-re/vpc0 natgw/natgw0",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rows := vpcNatGatewayRows(c.vpc())
+			var nats, lbs []string
+			for _, nat := range rows.nats {
+				nats = append(nats, fmt.Sprintf("%s %s %s %s",
+					nat.Type, nat.ExternalIp, nat.LogicalIp, nat.ExternalIds[externalKeyOcRef]))
+			}
+			for _, lb := range rows.lbs {
+				lbs = append(lbs, fmt.Sprintf("%s %s %v %s",
+					lb.Name, *lb.Protocol, lb.Vips, lb.ExternalIds[externalKeyOcRef]))
+			}
+			if !reflect.DeepEqual(nats, c.nats) {
+				t.Errorf("vpcNatGatewayRows() nats = %v, want %v", nats, c.nats)
+			}
+			if !reflect.DeepEqual(lbs, c.lbs) {
+				t.Errorf("vpcNatGatewayRows() lbs = %v, want %v", lbs, c.lbs)
+			}
+			if routes := testStaticRoutesString(rows.routes); !reflect.DeepEqual(routes, c.routes) {
+				t.Errorf("vpcNatGatewayRows() routes = %v, want %v", routes, c.routes)
+			}
+			if got, want := rows.isEmpty(), len(c.nats) == 0 && len(c.lbs) == 0; got != want {
+				t.Errorf("isEmpty() = %v, want %v", got, want)
+			}
+		})
+	}
+}
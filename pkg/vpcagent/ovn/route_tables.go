@@ -0,0 +1,231 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"yunion.io/x/log"
+	"yunion.io/x/ovsdb/schema/ovn_nb"
+	"yunion.io/x/pkg/errors"
+
+	apis "yunion.io/x/onecloud/pkg/apis/compute"
+	agentmodels "yunion.io/x/onecloud/pkg/vpcagent/models"
+)
+
+const (
+	errUnsupportedNexthop = errors.Error("unsupported next hop")
+	errNexthopNotFound    = errors.Error("next hop not found")
+)
+
+const (
+	routePolicyDstIp = "dst-ip"
+	routePolicySrcIp = "src-ip"
+
+	routeDefaultPrefix = "0.0.0.0/0"
+)
+
+func networkCidr(network *agentmodels.Network) (*net.IPNet, error) {
+	_, ipnet, err := net.ParseCIDR(fmt.Sprintf("%s/%d", network.GuestIpStart, network.GuestIpMask))
+	if err != nil {
+		return nil, errors.Wrapf(err, "network %s(%s) cidr", network.Name, network.Id)
+	}
+	return ipnet, nil
+}
+
+func routeIpPrefix(cidr string) (string, error) {
+	if ip := net.ParseIP(cidr).To4(); ip != nil {
+		return ip.String() + "/32", nil
+	}
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", errors.Wrapf(err, "route cidr %s", cidr)
+	}
+	return ipnet.String(), nil
+}
+
+// routeNexthop returns next hop address and output port of the vpc logical
+// router for the route.  Next hop inside the vpc is returned with inVpc set
+func routeNexthop(vpc *agentmodels.Vpc, route *apis.SRoute) (nexthop, outport string, inVpc bool, err error) {
+	switch route.NextHopType {
+	case apis.Next_HOP_TYPE_IP:
+		ip := net.ParseIP(route.NextHopId).To4()
+		if ip == nil {
+			return "", "", false, errors.Wrapf(errNexthopNotFound, "invalid addr %s", route.NextHopId)
+		}
+		for _, network := range vpc.Networks {
+			ipnet, err := networkCidr(network)
+			if err != nil {
+				continue
+			}
+			if ipnet.Contains(ip) {
+				return ip.String(), netRnpName(network.Id), true, nil
+			}
+		}
+		return "", "", false, errors.Wrapf(errNexthopNotFound, "addr %s not in any network of vpc", route.NextHopId)
+	case apis.Next_HOP_TYPE_INSTANCE:
+		var found *agentmodels.Guestnetwork
+		for _, network := range vpc.Networks {
+			for _, guestnetwork := range network.Guestnetworks {
+				if guestnetwork.GuestId != route.NextHopId || guestnetwork.Guest == nil {
+					continue
+				}
+				if found == nil || guestnetwork.Index < found.Index {
+					found = guestnetwork
+				}
+			}
+		}
+		if found == nil {
+			return "", "", false, errors.Wrapf(errNexthopNotFound, "guest %s has no nic in vpc", route.NextHopId)
+		}
+		return found.IpAddr, netRnpName(found.NetworkId), true, nil
+	case apis.Next_HOP_TYPE_INTERNET:
+		if !vpcHasDistgw(vpc) && !vpcHasEipgw(vpc) {
+			return "", "", false, errors.Wrap(errNexthopNotFound, "vpc has no external access")
+		}
+		return apis.VpcInterExtIP2().String(), vpcR1extpName(vpc.Id), false, nil
+	default:
+		return "", "", false, errors.Wrapf(errUnsupportedNexthop, "%q", route.NextHopType)
+	}
+}
+
+// vpcRouteTableRoutes converts custom routes in route tables of the vpc to
+// static routes of the vpc logical router.
+//
+// Route tables not associated with any network take effect vpc-wide as
+// destination-based routes.  OVN static routes can match either destination
+// or source, but not both.  So for networks with an associated route table,
+// the default route of that table is realized as a source-based route
+// covering the network cidr.  Other routes of associated tables can not be
+// expressed and are left out.  So are networks with a longer prefix than
+// other networks of the vpc, as the source-based route of the network would
+// take precedence over the routes to them
+//
+// Default route via a next hop inside the vpc will have traffic from the
+// next hop itself looping back.  A source-based route for the next hop
+// address to the vpc external gateway is added to let it out
+func vpcRouteTableRoutes(vpc *agentmodels.Vpc) []*ovn_nb.LogicalRouterStaticRoute {
+	var (
+		rtIds  []string
+		routes []*ovn_nb.LogicalRouterStaticRoute
+		has    = map[string]string{}
+	)
+	for rtId := range vpc.RouteTables {
+		rtIds = append(rtIds, rtId)
+	}
+	sort.Strings(rtIds)
+
+	add := func(rt *agentmodels.RouteTable, policy, prefix, nexthop, outport string) {
+		k := policy + "/" + prefix
+		if rtId, ok := has[k]; ok {
+			if rtId != rt.Id {
+				log.Warningf("vpc %s(%s): %s route %s of route table %s conflicts with route table %s",
+					vpc.Name, vpc.Id, policy, prefix, rt.Id, rtId)
+			}
+			return
+		}
+		has[k] = rt.Id
+		routes = append(routes, &ovn_nb.LogicalRouterStaticRoute{
+			Policy:     ptr(policy),
+			IpPrefix:   prefix,
+			Nexthop:    nexthop,
+			OutputPort: ptr(outport),
+			ExternalIds: map[string]string{
+				externalKeyOcRef: fmt.Sprintf("rt/%s", rt.Id),
+			},
+		})
+	}
+	for _, rtId := range rtIds {
+		rt := vpc.RouteTables[rtId]
+		if rt.Routes == nil {
+			continue
+		}
+		var netIpnets []*net.IPNet
+		for _, assoc := range rt.Associations {
+			network := assoc.Network
+			if network == nil || network.RouteTable != rt {
+				continue
+			}
+			if sibling := vpcShorterPrefixNetwork(vpc, network); sibling != nil {
+				log.Errorf("route table %s(%s): network %s(%s) has a longer prefix than network %s(%s)",
+					rt.Name, rt.Id, network.Name, network.Id, sibling.Name, sibling.Id)
+				continue
+			}
+			ipnet, err := networkCidr(network)
+			if err != nil {
+				log.Errorf("route table %s(%s): %v", rt.Name, rt.Id, err)
+				continue
+			}
+			netIpnets = append(netIpnets, ipnet)
+		}
+		sort.Slice(netIpnets, func(i, j int) bool {
+			return netIpnets[i].String() < netIpnets[j].String()
+		})
+
+		for _, route := range *rt.Routes {
+			if route.Type == apis.ROUTE_ENTRY_TYPE_SYSTEM {
+				continue
+			}
+			prefix, err := routeIpPrefix(route.Cidr)
+			if err != nil {
+				log.Errorf("route table %s(%s): %v", rt.Name, rt.Id, err)
+				continue
+			}
+			nexthop, outport, inVpc, err := routeNexthop(vpc, route)
+			if err != nil {
+				log.Errorf("route table %s(%s): route %s: %v", rt.Name, rt.Id, prefix, err)
+				continue
+			}
+			if len(rt.Associations) == 0 {
+				add(rt, routePolicyDstIp, prefix, nexthop, outport)
+			} else if prefix != routeDefaultPrefix {
+				log.Errorf("route table %s(%s): route %s: only the default route is supported in associated route tables", rt.Name, rt.Id, prefix)
+				continue
+			} else {
+				for _, ipnet := range netIpnets {
+					add(rt, routePolicySrcIp, ipnet.String(), nexthop, outport)
+				}
+			}
+			if prefix == routeDefaultPrefix && inVpc && (vpcHasDistgw(vpc) || vpcHasEipgw(vpc)) {
+				add(rt, routePolicySrcIp, nexthop+"/32", apis.VpcInterExtIP2().String(), vpcR1extpName(vpc.Id))
+			}
+		}
+	}
+	return routes
+}
+
+// vpcShorterPrefixNetwork returns a network of the vpc with a shorter prefix
+// than network
+func vpcShorterPrefixNetwork(vpc *agentmodels.Vpc, network *agentmodels.Network) *agentmodels.Network {
+	for _, sibling := range vpc.Networks {
+		if sibling.GuestIpMask < network.GuestIpMask {
+			return sibling
+		}
+	}
+	return nil
+}
+
+// vpcHasCustomDefaultRoute returns true if route tables of the vpc has a
+// vpc-wide default route, which replaces the one via vpc external gateway
+func vpcHasCustomDefaultRoute(vpc *agentmodels.Vpc) bool {
+	for _, route := range vpcRouteTableRoutes(vpc) {
+		if *route.Policy == routePolicyDstIp && route.IpPrefix == routeDefaultPrefix {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn
+
+import (
+	"reflect"
+	"testing"
+
+	apis "yunion.io/x/onecloud/pkg/apis/compute"
+	agentmodels "yunion.io/x/onecloud/pkg/vpcagent/models"
+)
+
+func TestVpcRouteTableRoutes(t *testing.T) {
+	cases := []struct {
+		name string
+		vpc  func() *agentmodels.Vpc
+		want []string
+	}{
+		{
+			name: "unassociated table routes by destination",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_NONE)
+				testNetwork(vpc, "net0", "192.168.0.0", 24)
+				testRouteTable(vpc, "rt0",
+					&apis.SRoute{Type: apis.ROUTE_ENTRY_TYPE_SYSTEM, Cidr: "192.168.0.0/24"},
+					testRoute("10.1.0.0/16", apis.Next_HOP_TYPE_IP, "192.168.0.10"),
+					testRoute("10.2.0.1", apis.Next_HOP_TYPE_IP, "192.168.0.11"),
+					testRoute("0.0.0.0/0", apis.Next_HOP_TYPE_IP, "192.168.0.12"),
+				)
+				return vpc
+			},
+			want: []string{
+				"dst-ip 10.1.0.0/16 192.168.0.10 subnet-rn/net0 rt/rt0",
+				"dst-ip 10.2.0.1/32 192.168.0.11 subnet-rn/net0 rt/rt0",
+				"dst-ip 0.0.0.0/0 192.168.0.12 subnet-rn/net0 rt/rt0",
+			},
+		},
+		{
+			name: "associated table default route by source",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_NONE)
+				net0 := testNetwork(vpc, "net0", "192.168.0.0", 24)
+				net1 := testNetwork(vpc, "net1", "192.168.1.0", 24)
+				testNetwork(vpc, "net2", "192.168.2.0", 24)
+				rt := testRouteTable(vpc, "rt0",
+					testRoute("0.0.0.0/0", apis.Next_HOP_TYPE_IP, "192.168.2.10"),
+					testRoute("10.1.0.0/16", apis.Next_HOP_TYPE_IP, "192.168.2.11"),
+				)
+				testAssociate(rt, net1)
+				testAssociate(rt, net0)
+				return vpc
+			},
+			want: []string{
+				"src-ip 192.168.0.0/24 192.168.2.10 subnet-rn/net2 rt/rt0",
+				"src-ip 192.168.1.0/24 192.168.2.10 subnet-rn/net2 rt/rt0",
+			},
+		},
+		{
+			name: "associated network with longer prefix",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_NONE)
+				net0 := testNetwork(vpc, "net0", "10.0.0.0", 24)
+				net1 := testNetwork(vpc, "net1", "10.0.1.0", 24)
+				testNetwork(vpc, "net2", "10.1.0.0", 16)
+				rt := testRouteTable(vpc, "rt0",
+					testRoute("0.0.0.0/0", apis.Next_HOP_TYPE_IP, "10.1.0.10"),
+				)
+				testAssociate(rt, net0)
+				testAssociate(rt, net1)
+				return vpc
+			},
+		},
+		{
+			name: "association of network bound to another table",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_NONE)
+				net0 := testNetwork(vpc, "net0", "192.168.0.0", 24)
+				net1 := testNetwork(vpc, "net1", "192.168.1.0", 24)
+				rt0 := testRouteTable(vpc, "rt0",
+					testRoute("0.0.0.0/0", apis.Next_HOP_TYPE_IP, "192.168.1.10"),
+				)
+				rt1 := testRouteTable(vpc, "rt1")
+				testAssociate(rt0, net0)
+				testAssociate(rt0, net1)
+				testAssociate(rt1, net1)
+				return vpc
+			},
+			want: []string{
+				"src-ip 192.168.0.0/24 192.168.1.10 subnet-rn/net1 rt/rt0",
+			},
+		},
+		{
+			name: "conflicting tables",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_NONE)
+				testNetwork(vpc, "net0", "192.168.0.0", 24)
+				testRouteTable(vpc, "rt1",
+					testRoute("10.0.0.0/8", apis.Next_HOP_TYPE_IP, "192.168.0.11"),
+					testRoute("10.2.0.0/16", apis.Next_HOP_TYPE_IP, "192.168.0.11"),
+				)
+				testRouteTable(vpc, "rt0",
+					testRoute("10.0.0.0/8", apis.Next_HOP_TYPE_IP, "192.168.0.10"),
+				)
+				return vpc
+			},
+			want: []string{
+				"dst-ip 10.0.0.0/8 192.168.0.10 subnet-rn/net0 rt/rt0",
+				"dst-ip 10.2.0.0/16 192.168.0.11 subnet-rn/net0 rt/rt1",
+			},
+		},
+		{
+			name: "instance next hop",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_NONE)
+				net0 := testNetwork(vpc, "net0", "192.168.0.0", 24)
+				net1 := testNetwork(vpc, "net1", "192.168.1.0", 24)
+				testGuestnetwork(net1, "guest0", "192.168.1.10", 1)
+				testGuestnetwork(net0, "guest0", "192.168.0.10", 0)
+				testRouteTable(vpc, "rt0",
+					testRoute("10.0.0.0/8", apis.Next_HOP_TYPE_INSTANCE, "guest0"),
+					testRoute("10.1.0.0/16", apis.Next_HOP_TYPE_INSTANCE, "guest1"),
+				)
+				return vpc
+			},
+			want: []string{
+				"dst-ip 10.0.0.0/8 192.168.0.10 subnet-rn/net0 rt/rt0",
+			},
+		},
+		{
+			name: "internet next hop",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_DISTGW)
+				testNetwork(vpc, "net0", "192.168.0.0", 24)
+				testRouteTable(vpc, "rt0",
+					testRoute("10.0.0.0/8", apis.Next_HOP_TYPE_INTERNET, ""),
+					testRoute("0.0.0.0/0", apis.Next_HOP_TYPE_INTERNET, ""),
+				)
+				return vpc
+			},
+			want: []string{
+				"dst-ip 10.0.0.0/8 100.65.0.2 vpc-r1ext/vpc0 rt/rt0",
+				"dst-ip 0.0.0.0/0 100.65.0.2 vpc-r1ext/vpc0 rt/rt0",
+			},
+		},
+		{
+			name: "internet next hop without external access",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_NONE)
+				testNetwork(vpc, "net0", "192.168.0.0", 24)
+				testRouteTable(vpc, "rt0",
+					testRoute("0.0.0.0/0", apis.Next_HOP_TYPE_INTERNET, ""),
+				)
+				return vpc
+			},
+		},
+		{
+			name: "appliance loop-back",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_EIP_DISTGW)
+				net0 := testNetwork(vpc, "net0", "192.168.0.0", 24)
+				net1 := testNetwork(vpc, "net1", "192.168.1.0", 24)
+				testGuestnetwork(net1, "guest0", "192.168.1.10", 0)
+				rt0 := testRouteTable(vpc, "rt0",
+					testRoute("0.0.0.0/0", apis.Next_HOP_TYPE_INSTANCE, "guest0"),
+				)
+				testAssociate(rt0, net0)
+				testRouteTable(vpc, "rt1",
+					testRoute("0.0.0.0/0", apis.Next_HOP_TYPE_IP, "192.168.0.10"),
+				)
+				return vpc
+			},
+			want: []string{
+				"src-ip 192.168.0.0/24 192.168.1.10 subnet-rn/net1 rt/rt0",
+				"src-ip 192.168.1.10/32 100.65.0.2 vpc-r1ext/vpc0 rt/rt0",
+				"dst-ip 0.0.0.0/0 192.168.0.10 subnet-rn/net0 rt/rt1",
+				"src-ip 192.168.0.10/32 100.65.0.2 vpc-r1ext/vpc0 rt/rt1",
+			},
+		},
+		{
+			name: "no loop-back without external access",
+			vpc: func() *agentmodels.Vpc {
+				vpc := testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_NONE)
+				testNetwork(vpc, "net0", "192.168.0.0", 24)
+				testRouteTable(vpc, "rt0",
+					testRoute("0.0.0.0/0", apis.Next_HOP_TYPE_IP, "192.168.0.10"),
+				)
+				return vpc
+			},
+			want: []string{
+				"dst-ip 0.0.0.0/0 192.168.0.10 subnet-rn/net0 rt/rt0",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := testStaticRoutesString(vpcRouteTableRoutes(c.vpc()))
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("vpcRouteTableRoutes() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
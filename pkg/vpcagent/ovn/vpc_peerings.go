@@ -0,0 +1,110 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn
+
+import (
+	"fmt"
+	"sort"
+
+	"yunion.io/x/log"
+	"yunion.io/x/ovsdb/schema/ovn_nb"
+
+	apis "yunion.io/x/onecloud/pkg/apis/compute"
+	agentmodels "yunion.io/x/onecloud/pkg/vpcagent/models"
+	"yunion.io/x/onecloud/pkg/vpcagent/ovn/mac"
+)
+
+type vpcPeerRows struct {
+	lrps   []*ovn_nb.LogicalRouterPort
+	routes []*ovn_nb.LogicalRouterStaticRoute
+}
+
+func vpcPeeringIsActive(pc *agentmodels.VpcPeeringConnection) bool {
+	if pc.Status != apis.VPC_PEERING_CONNECTION_STATUS_ACTIVE || pc.Enabled.IsFalse() {
+		return false
+	}
+	if pc.Vpc == nil || pc.PeerVpc == nil {
+		return false
+	}
+	if pc.VpcId == apis.DEFAULT_VPC_ID || pc.PeerVpcId == apis.DEFAULT_VPC_ID {
+		return false
+	}
+	if pc.LinkIndex <= 0 || pc.LinkIndex > apis.VpcPeeringLinkIndexMax {
+		log.Errorf("vpc peering connection %s(%s): invalid link index %d", pc.Name, pc.Id, pc.LinkIndex)
+		return false
+	}
+	return true
+}
+
+// vpcPeeringRows returns router ports and routes on the router of vpc for
+// its active peering connections.
+//
+// Router ports of both sides of a peering connection are peers of each
+// other.  Networks of the other vpc are routed through the link
+func vpcPeeringRows(vpc *agentmodels.Vpc) *vpcPeerRows {
+	var (
+		rows  = &vpcPeerRows{}
+		pcIds []string
+	)
+	for pcId, pc := range vpc.PeeringConnections {
+		if !vpcPeeringIsActive(pc) {
+			continue
+		}
+		pcIds = append(pcIds, pcId)
+	}
+	sort.Strings(pcIds)
+	for _, pcId := range pcIds {
+		var (
+			pc                  = vpc.PeeringConnections[pcId]
+			requesterIp, peerIp = apis.VpcPeeringLinkIPs(pc.LinkIndex)
+			localIp, remoteIp   = requesterIp, peerIp
+			remoteVpc           = pc.PeerVpc
+		)
+		if pc.VpcId != vpc.Id {
+			localIp, remoteIp = peerIp, requesterIp
+			remoteVpc = pc.Vpc
+		}
+		lrpName := vpcPeerRpName(pc.Id, vpc.Id)
+		rows.lrps = append(rows.lrps, &ovn_nb.LogicalRouterPort{
+			Name:     lrpName,
+			Mac:      mac.HashVpcPeeringPortMac(pc.Id, vpc.Id),
+			Networks: []string{fmt.Sprintf("%s/%d", localIp, apis.VpcPeeringLinkMask)},
+			Peer:     ptr(vpcPeerRpName(pc.Id, remoteVpc.Id)),
+		})
+
+		var netIds []string
+		for netId := range remoteVpc.Networks {
+			netIds = append(netIds, netId)
+		}
+		sort.Strings(netIds)
+		for _, netId := range netIds {
+			ipnet, err := networkCidr(remoteVpc.Networks[netId])
+			if err != nil {
+				log.Errorf("vpc peering connection %s(%s): %v", pc.Name, pc.Id, err)
+				continue
+			}
+			rows.routes = append(rows.routes, &ovn_nb.LogicalRouterStaticRoute{
+				Policy:     ptr(routePolicyDstIp),
+				IpPrefix:   ipnet.String(),
+				Nexthop:    remoteIp.String(),
+				OutputPort: ptr(lrpName),
+				ExternalIds: map[string]string{
+					externalKeyOcRef: fmt.Sprintf("peer/%s", pc.Id),
+				},
+			})
+		}
+	}
+	return rows
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn
+
+import (
+	"reflect"
+	"testing"
+
+	"yunion.io/x/pkg/tristate"
+
+	apis "yunion.io/x/onecloud/pkg/apis/compute"
+	agentmodels "yunion.io/x/onecloud/pkg/vpcagent/models"
+	"yunion.io/x/onecloud/pkg/vpcagent/ovn/mac"
+)
+
+func TestVpcPeeringRows(t *testing.T) {
+	var (
+		vpc0 = testVpc("vpc0", apis.VPC_EXTERNAL_ACCESS_MODE_NONE)
+		vpc1 = testVpc("vpc1", apis.VPC_EXTERNAL_ACCESS_MODE_NONE)
+		vpc2 = testVpc("vpc2", apis.VPC_EXTERNAL_ACCESS_MODE_NONE)
+		vpcd = testVpc(apis.DEFAULT_VPC_ID, apis.VPC_EXTERNAL_ACCESS_MODE_NONE)
+	)
+	testNetwork(vpc0, "net0", "192.168.0.0", 24)
+	testNetwork(vpc1, "net11", "172.16.1.0", 24)
+	testNetwork(vpc1, "net10", "172.16.0.0", 24)
+	testNetwork(vpc2, "net2", "10.0.0.0", 16)
+
+	testVpcPeering("pc0", vpc0, vpc1, 1)
+	testVpcPeering("pc1", vpc2, vpc0, 2)
+
+	pending := testVpcPeering("pc2", vpc0, vpc2, 3)
+	pending.Status = apis.VPC_PEERING_CONNECTION_STATUS_PENDING_ACCEPT
+	disabled := testVpcPeering("pc3", vpc0, vpc2, 4)
+	disabled.Enabled = tristate.False
+	testVpcPeering("pc4", vpc0, vpcd, 5)
+	testVpcPeering("pc5", vpc0, vpc2, 0)
+	testVpcPeering("pc6", vpc0, vpc2, apis.VpcPeeringLinkIndexMax+1)
+	detached := testVpcPeering("pc7", vpc0, vpc2, 6)
+	detached.PeerVpc = nil
+
+	type lrp struct {
+		name     string
+		mac      string
+		networks []string
+		peer     string
+	}
+	cases := []struct {
+		name   string
+		vpc    *agentmodels.Vpc
+		lrps   []lrp
+		routes []string
+	}{
+		{
+			name: "requester and accepter",
+			vpc:  vpc0,
+			lrps: []lrp{
+				{
+					name:     "vpc-peer/pc0/vpc0",
+					mac:      mac.HashVpcPeeringPortMac("pc0", "vpc0"),
+					networks: []string{"100.65.0.5/30"},
+					peer:     "vpc-peer/pc0/vpc1",
+				},
+				{
+					name:     "vpc-peer/pc1/vpc0",
+					mac:      mac.HashVpcPeeringPortMac("pc1", "vpc0"),
+					networks: []string{"100.65.0.10/30"},
+					peer:     "vpc-peer/pc1/vpc2",
+				},
+			},
+			routes: []string{
+				"dst-ip 172.16.0.0/24 100.65.0.6 vpc-peer/pc0/vpc0 peer/pc0",
+				"dst-ip 172.16.1.0/24 100.65.0.6 vpc-peer/pc0/vpc0 peer/pc0",
+				"dst-ip 10.0.0.0/16 100.65.0.9 vpc-peer/pc1/vpc0 peer/pc1",
+			},
+		},
+		{
+			name: "accepter",
+			vpc:  vpc1,
+			lrps: []lrp{
+				{
+					name:     "vpc-peer/pc0/vpc1",
+					mac:      mac.HashVpcPeeringPortMac("pc0", "vpc1"),
+					networks: []string{"100.65.0.6/30"},
+					peer:     "vpc-peer/pc0/vpc0",
+				},
+			},
+			routes: []string{
+				"dst-ip 192.168.0.0/24 100.65.0.5 vpc-peer/pc0/vpc1 peer/pc0",
+			},
+		},
+		{
+			name: "requester",
+			vpc:  vpc2,
+			lrps: []lrp{
+				{
+					name:     "vpc-peer/pc1/vpc2",
+					mac:      mac.HashVpcPeeringPortMac("pc1", "vpc2"),
+					networks: []string{"100.65.0.9/30"},
+					peer:     "vpc-peer/pc1/vpc0",
+				},
+			},
+			routes: []string{
+				"dst-ip 192.168.0.0/24 100.65.0.10 vpc-peer/pc1/vpc2 peer/pc1",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rows := vpcPeeringRows(c.vpc)
+			var lrps []lrp
+			for _, row := range rows.lrps {
+				lrps = append(lrps, lrp{
+					name:     row.Name,
+					mac:      row.Mac,
+					networks: row.Networks,
+					peer:     *row.Peer,
+				})
+			}
+			if !reflect.DeepEqual(lrps, c.lrps) {
+				t.Errorf("vpcPeeringRows() lrps = %+v, want %+v", lrps, c.lrps)
+			}
+			if routes := testStaticRoutesString(rows.routes); !reflect.DeepEqual(routes, c.routes) {
+				t.Errorf("vpcPeeringRows() routes = %v, want %v", routes, c.routes)
+			}
+		})
+	}
+}
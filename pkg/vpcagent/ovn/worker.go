@@ -164,6 +164,10 @@ func (w *Worker) run(ctx context.Context, mss *agentmodels.ModelSets) (err error
 				ovndb.ClaimGuestnetwork(ctx, guestnetwork)
 			}
 		}
+		ovndb.ClaimVpcPeerings(ctx, vpc)
+		ovndb.ClaimVpcRouteTables(ctx, vpc)
+		ovndb.ClaimVpcNatgateways(ctx, vpc, w.opts.OvnNatGatewayChassis)
+		ovndb.ClaimVpcLoadbalancers(ctx, vpc)
 	}
 	for _, vpc := range mss.Vpcs {
 		if vpc.Id == apis.DEFAULT_VPC_ID {
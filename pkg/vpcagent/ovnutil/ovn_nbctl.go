@@ -160,6 +160,7 @@ func OvnNbctlArgsDestroy(irows []types.IRow) []string {
 		case *ovn_nb.LogicalRouterPort:
 			newArgs = []string{"--", "--if-exists", "lrp-del", irow.OvsdbUuid()}
 		case *ovn_nb.LogicalRouterStaticRoute:
+		case *ovn_nb.NAT:
 		case *ovn_nb.ACL:
 		case *ovn_nb.QoS:
 		default:
@@ -131,6 +131,7 @@ func addMiscHandlers(root *mux.Router) {
 	root.HandleFunc("/stats", adapterF(appsrv.StatisticHandler))
 	root.HandleFunc("/ping", adapterF(appsrv.PingHandler))
 	root.HandleFunc("/worker_stats", adapterF(appsrv.WorkerStatsHandler))
+	root.HandleFunc(appsrv.MetricsPath, adapterF(appsrv.MetricsHandler))
 
 	// pprof handler
 	root.PathPrefix("/debug/pprof/").Handler(http.DefaultServeMux)